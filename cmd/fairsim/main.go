@@ -1,17 +1,50 @@
 package main
 
 import (
-	"crypto/sha256"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"math"
+	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/akshitanchan/execution-fairness-simulator/internal/artifacts"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/attribution"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/audit"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/batching"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/bookview"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/calibrate"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/clip"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/compat"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/daemon"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/dataset"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/engine"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/expected"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/golden"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/heatmap"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/manifest"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/provenance"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/report"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/repro"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/robustness"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scoring"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sensitivity"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/traderview"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/venue"
 )
 
 const defaultRunsDir = "runs"
@@ -29,8 +62,46 @@ func main() {
 		cmdReport(os.Args[2:])
 	case "demo":
 		cmdDemo(os.Args[2:])
+	case "tutorial":
+		cmdTutorial(os.Args[2:])
+	case "mechanisms":
+		cmdMechanisms(os.Args[2:])
+	case "sensitivity":
+		cmdSensitivity(os.Args[2:])
+	case "experiment":
+		cmdExperiment(os.Args[2:])
+	case "calibrate":
+		cmdCalibrate(os.Args[2:])
+	case "robustness":
+		cmdRobustness(os.Args[2:])
+	case "daemon":
+		cmdDaemon(os.Args[2:])
 	case "replay":
 		cmdReplay(os.Args[2:])
+	case "bench":
+		cmdBench(os.Args[2:])
+	case "golden":
+		cmdGolden(os.Args[2:])
+	case "audit":
+		cmdAudit(os.Args[2:])
+	case "dataset":
+		cmdDataset(os.Args[2:])
+	case "heatmap":
+		cmdHeatmap(os.Args[2:])
+	case "verify-repro":
+		cmdVerifyRepro(os.Args[2:])
+	case "clip":
+		cmdClip(os.Args[2:])
+	case "book":
+		cmdBook(os.Args[2:])
+	case "views":
+		cmdViews(os.Args[2:])
+	case "baseline":
+		cmdBaseline(os.Args[2:])
+	case "upgrade":
+		cmdUpgrade(os.Args[2:])
+	case "verify":
+		cmdVerify(os.Args[2:])
 	case "help", "--help", "-h":
 		printUsage()
 	default:
@@ -51,8 +122,26 @@ func runReplay(args []string) error {
 	runDir := ""
 	runId := ""
 	logPath := ""
+	stdJSON := false
+	skipValidate := false
+	diagnose := false
+	logOnly := false
+	depth := repro.DefaultBookDepth
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
+		case "--std-json":
+			stdJSON = true
+		case "--log-only":
+			logOnly = true
+		case "--skip-validate":
+			skipValidate = true
+		case "--diagnose":
+			diagnose = true
+		case "--depth":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &depth)
+			}
 		case "--run-id":
 			i++
 			if i < len(args) {
@@ -91,28 +180,57 @@ func runReplay(args []string) error {
 		return fmt.Errorf("could not access event log at %s: %w", logPath, err)
 	}
 
-	configFile, err := os.Open(configPath)
-	if err != nil {
-		return fmt.Errorf("could not open config: %w", err)
-	}
-	defer configFile.Close()
-	cfg := &scenario.Config{}
-	if err := json.NewDecoder(configFile).Decode(cfg); err != nil {
-		return fmt.Errorf("could not decode config: %w", err)
-	}
-
-	targetHash, err := simHashFile(logPath)
+	cfg, err := compat.ReadConfig(runDir)
 	if err != nil {
-		return fmt.Errorf("could not hash target event log: %w", err)
+		return fmt.Errorf("could not read config: %w", err)
 	}
 
 	fmt.Printf("Analyzing event log: %s\n", logPath)
-	metricsByTrader, err := computeMetricsFromEventLog(logPath)
-	if err != nil {
+	if stamp, err := provenance.Read(runDir); err == nil {
+		fmt.Printf("Produced by %s\n", stamp)
+	}
+	// A log from another version is expected to replay differently
+	if v, err := provenance.LogVersion(logPath); err == nil && v != provenance.Version {
+		from := fmt.Sprintf("simulator v%d", v)
+		if v == 0 {
+			from = "a simulator that predates version stamps"
+		}
+		if !logOnly {
+			fmt.Fprintf(os.Stderr, "Warning: log was produced by %s, this is v%d; a hash mismatch may be a behavior change, not a bug (--log-only skips regeneration)\n", from, provenance.Version)
+		}
+	}
+	if !skipValidate {
+		// A corrupt or hand-edited log would otherwise yield silently wrong metrics
+		if err := eventlog.Validate(logPath); err != nil {
+			return fmt.Errorf("invalid event log: %w", err)
+		}
+	}
+	metricsByTrader, targetHash, err := scanEventLog(logPath, cfg.Scale(), cfg.Metrics, stdJSON)
+	var levelErr *eventlog.LevelError
+	switch {
+	case errors.As(err, &levelErr):
+		// A reduced log can still be checked against a replay
+		fmt.Printf("\nMetrics skipped: %v\n", err)
+	case err != nil:
 		return fmt.Errorf("could not recompute metrics from event log: %w", err)
+	default:
+		fmt.Println("\nMetrics Summary (Replay):")
+		report.PrintSummary(cfg, metricsByTrader)
+	}
+	if logOnly {
+		if err := printClosingBook(logPath, cfg.Scale()); err != nil {
+			return err
+		}
+		if metricsByTrader == nil {
+			return nil
+		}
+		outDir := filepath.Join(runDir, rebuiltDir)
+		if err := rebuildReport(cfg, metricsByTrader, logPath, outDir); err != nil {
+			return err
+		}
+		fmt.Printf("\nReport rebuilt from the log alone: %s\n", filepath.Join(outDir, "report.md"))
+		return nil
 	}
-	fmt.Println("\nMetrics Summary (Replay):")
-	report.PrintSummary(cfg, metricsByTrader)
 
 	// Deterministically regenerate the run and compare event-log hashes
 	tmpDir, err := os.MkdirTemp("", "fairsim-replay-*")
@@ -125,6 +243,7 @@ func runReplay(args []string) error {
 	if err != nil {
 		return fmt.Errorf("initialize deterministic replay runner: %w", err)
 	}
+	replayRunner.StdJSON = stdJSON
 	replayResult, err := replayRunner.Run()
 	if err != nil {
 		return fmt.Errorf("run deterministic replay: %w", err)
@@ -135,33 +254,162 @@ func runReplay(args []string) error {
 		fmt.Printf("Event log hash matches deterministic replay: %s...\n", targetHash[:16])
 	} else {
 		fmt.Printf("Event log hash MISMATCH!\nTarget: %s...\nReplay: %s...\n", targetHash[:16], replayResult.LogHash[:16])
+		if !diagnose {
+			fmt.Println("Rerun with --diagnose to locate the first divergent event")
+			return nil
+		}
+		return diagnoseLogs(logPath, replayResult.LogPath, depth, cfg.Scale())
+	}
+
+	return nil
+}
+
+// rebuiltDir is where reports rebuilt from a run's log alone are written,
+// inside the run directory, leaving the run's own report as it was
+const rebuiltDir = "rebuilt"
+
+// rebuildReport writes a run's metrics and report to outDir from its
+// config and log alone, without re-running the simulator. Sections that
+// need more runs (gap attribution) or the live run (tie audits, budget
+// truncation, the baseline comparison) are left out
+func rebuildReport(cfg *scenario.Config, m map[string]*metrics.TraderMetrics, logPath, outDir string) error {
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+	gen := report.NewReport(cfg, m, outDir)
+	var err error
+	if len(cfg.Background) > 0 {
+		if gen.Background, err = metrics.BackgroundFromLog(logPath); err != nil {
+			return fmt.Errorf("background metrics: %w", err)
+		}
+	}
+	if len(cfg.Sessions) > 0 {
+		if gen.Sessions, err = metrics.ComputeSessionsFromLog(logPath, cfg.Scale(), cfg.Metrics); err != nil {
+			return fmt.Errorf("session metrics: %w", err)
+		}
+	}
+	if len(cfg.Metrics.Exclude) > 0 {
+		if gen.Excluded, err = metrics.ComputeExcludedFromLog(logPath, cfg.Scale(), cfg.Metrics); err != nil {
+			return fmt.Errorf("excluded-window metrics: %w", err)
+		}
 	}
+	return gen.Generate()
+}
 
+// printClosingBook rebuilds the book through the whole log and prints how
+// it stood at the end, if the log records orders
+func printClosingBook(logPath string, scale domain.Scale) error {
+	r, err := eventlog.NewReader(logPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := r.Require(eventlog.LevelOrders, "book"); err != nil {
+		fmt.Printf("\nBook skipped: %v\n", err)
+		return nil
+	}
+	book := clip.NewBook()
+	var end int64
+	err = r.Each(func(e *domain.Event) error {
+		book.Process(e)
+		end = e.Timestamp
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var bids, asks, traders int
+	var bid, ask *domain.Order
+	resting := book.Resting()
+	for i, o := range resting {
+		if !domain.IsBackground(o.TraderID) {
+			traders++
+		}
+		switch {
+		case o.Side == domain.Buy:
+			if bids++; bid == nil {
+				bid = &resting[i]
+			}
+		default:
+			if asks++; ask == nil {
+				ask = &resting[i]
+			}
+		}
+	}
+	fmt.Printf("\nBook at the end of the log (t=%.3fs): %d bids and %d asks resting, %d of them traders'\n",
+		float64(end)/1e9, bids, asks, traders)
+	if bid != nil && ask != nil {
+		fmt.Printf("  Best bid %s, best ask %s\n", scale.Format(bid.Price), scale.Format(ask.Price))
+	}
 	return nil
 }
 
-func simHashFile(path string) (string, error) {
-	data, err := os.ReadFile(path)
+func diagnoseLogs(targetPath, replayPath string, depth int, scale domain.Scale) error {
+	target, err := os.Open(targetPath)
+	if err != nil {
+		return fmt.Errorf("open event log: %w", err)
+	}
+	defer target.Close()
+	replayed, err := os.Open(replayPath)
+	if err != nil {
+		return fmt.Errorf("open replay log: %w", err)
+	}
+	defer replayed.Close()
+
+	d, err := repro.Diagnose(target, replayed, depth)
 	if err != nil {
-		return "", err
+		return fmt.Errorf("diagnose divergence: %w", err)
 	}
-	h := sha256.Sum256(data)
-	return fmt.Sprintf("%x", h), nil
+	fmt.Println("\nDivergence diagnosis (expected = target log):")
+	if d == nil {
+		fmt.Println("  Logs are identical record by record")
+		return nil
+	}
+	printDiagnosis(d, scale)
+	return nil
 }
 
 func computeMetricsFromEventLog(logPath string) (map[string]*metrics.TraderMetrics, error) {
+	m, _, err := scanEventLog(logPath, domain.DefaultScale, metrics.Options{}, false)
+	return m, err
+}
+
+// scanEventLog streams the log once, computing metrics and its SHA-256
+// without holding the file or its events in memory. Decoding runs ahead
+// of the metrics on its own goroutine, by at most a stream's depth. A log
+// below the level metrics need still yields its hash, with a
+// *eventlog.LevelError
+func scanEventLog(logPath string, scale domain.Scale, opts metrics.Options, stdJSON bool) (map[string]*metrics.TraderMetrics, string, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, "", err
+	}
 	reader, err := eventlog.NewReader(logPath)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer reader.Close()
-
-	events, err := reader.ReadAll()
+	reader.StdJSON = stdJSON
+	have, err := reader.Level()
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	levelErr := metrics.CheckLevel(have)
+
+	c := metrics.NewCollector()
+	c.Scale, c.Options = scale, opts
+	for event := range reader.Stream(context.Background(), eventlog.DefaultStreamDepth) {
+		if levelErr == nil {
+			c.ProcessEvent(event)
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return nil, "", err
+	}
+	if levelErr != nil {
+		return nil, reader.Hash(), levelErr
 	}
 
-	return metrics.ComputeFromEvents(events), nil
+	return c.Compute(), reader.Hash(), nil
 }
 
 func printUsage() {
@@ -170,29 +418,337 @@ func printUsage() {
 Commands:
   run      Run a simulation scenario
   demo     Run all scenarios and generate consolidated report
+  tutorial Run a guided latency walkthrough with an annotated report
+  mechanisms  Run one scenario under each venue mechanism and rank their fairness
+  sensitivity Sweep the slow trader's latency and find where fairness breaks down
+  experiment  Run a canned study: queue-latency charts queue position and
+              fill probability against the slow trader's latency
+  robustness  Rerun a scenario with randomized market parameters and see what the gaps hinge on
+  calibrate   Tune the background flow until the market matches target statistics
   report   Generate a fairness report
   replay   Analyze a run log and verify deterministic replay
+  bench    Time end-to-end runs and report events/second
+  daemon   Serve runs over a local socket, warm, for sweeps of many short runs
+  golden   Check or regenerate the pinned golden snapshots
+  audit    Check a run's event log for accounting violations
+  dataset  Export a run's order-level feature and label table for modelling
+  heatmap  Export a run's resting depth as a time x price matrix
+  verify-repro  Re-run a bundled run on this platform and compare hashes
+  clip     Cut a self-contained window around one moment out of a run's log
+  book     Print the order book, queue by queue, at one moment of a run
+  views    Cut a run's log down to what each trader could observe, and check
+           every decision had something to act on
+  baseline Mark the run later runs' reports are compared against
+  upgrade  Rewrite runs stored by earlier versions in the current schema
+  verify   Check a run's files against the hashes in its artifacts.json
 
 Run options:
-  --scenario <name>   Scenario: calm, thin, spike (required)
-  --seed <n>          Random seed (default: 42)
+  --scenario <name>   Scenario: calm, thin, spike (this or --config is required)
+  --config <file>     JSON config: a scenario name plus the fields to change;
+                      duration fields take "500ms"-style strings or nanoseconds
+  --seed <n>          Random seed (default: 42, or the config file's)
+  --check-invariants  Run the full order book invariant check after every order
+  --std-json          Encode the event log with encoding/json (byte-identical, slower)
+  --serial            Generate, match and log on one goroutine (byte-identical, slower)
+  --price-scale <n>   Fixed-point units per 1.0 of price, a power of ten (default: 10000)
+  --maker-fee <p>     Per-share fee on passive fills in price units; negative is a rebate
+  --taker-fee <p>     Per-share fee on aggressing fills in price units (both default: 0)
+  --slow-signal-delay <d>  Deliver signals to the slow trader late, e.g. 20ms
+  --slow-signal-noise <σ>  Add Gaussian noise to the signals the slow trader sees
+  --slow-no-signal    Hide signal values from the slow trader (likewise --fast-*)
+                      When latency and signal access both differ, the report
+                      attributes each gap to latency vs information
+  --fast-latency <d>  Fast trader base latency as a duration, e.g. 150us, 1.5ms
+  --fast-jitter <d>   Fast trader jitter bound (likewise --slow-latency, --slow-jitter)
+  --fast-gateway <d>  Fast trader's gateway send time per message; bursts queue
+                      behind it (likewise --slow-gateway; default: none)
+  --fast-risk-check <d>  How long the fast trader's pre-trade risk check holds
+                      each message (likewise --slow-risk-check; default: none)
+  --fast-capital <$>  Fast trader's buying power; orders beyond it are rejected
+                      before the book (likewise --slow-capital; default: unlimited)
+  --fast-margin <f>   Share of the fast trader's exposure held against its
+                      capital, e.g. 0.25 for 4:1 (likewise --slow-margin; default: 1)
+  --fast-warmup <d>   Extra delay on the fast trader's first message after
+                      --fast-idle <d> of quiet (likewise --slow-*; default: none)
+  --fast-handshake <d>  Extra delay on the fast trader's first message of the run
+                      (likewise --slow-handshake; default: none)
+  --fast-market-data <d>  How long BBO updates and trade prints take to reach the
+                      fast trader (likewise --slow-*; default: its base latency)
+  --jitter-mode <m>   stream (default) draws jitter in turn from each trader's RNG;
+                      hashed derives it from the trader and the decision it is in
+  --slow-strategy <k=v,...>  Override the slow trader's strategy (likewise --fast-*):
+                      requote_interval_ns, cancel_timeout_ns, cross_threshold,
+                      cross_tif (ioc or fok: cross with a limit at the far touch),
+                      reduce_threshold, target_qty, display_qty (quote icebergs
+                      showing that much), batch_signals (act every N
+                      signals), and the market-data rules pull_sweep_qty and
+                      join_widen. With equal latencies, the run is a strategy A/B
+  --log-level <l>     What the event log records: trades, orders (+ accepted
+                      orders, cancels and iceberg refreshes), bbo (+ BBO
+                      updates), depth (+ depth snapshots) or full (+ signals,
+                      states, accounts; default).
+                      Metrics, the report and the audit need full
+  --external-flow <file>  Inject the orders in an event log at their recorded
+                      arrival times, alongside the generated flow
+  --record-flow       Write the generated background flow and signals to flow.jsonl
+  --background-from <file>  Replace the generated background flow and signals with
+                      a flow.jsonl recorded by an earlier run
+  --guards <k=v,...>  Abort the run if the market degenerates: one_sided_ns (a book
+                      side empty this long), no_trade_ns (no trade this long),
+                      max_backlog (events queued by the run beyond this many)
+  --hedge <k=v,...>   Hedge the traders' fills on a delta-one future: basis_bps
+                      (fair value over the mid), half_spread_bps (the dealer's
+                      quote), ratio (future qty per primary qty; default 1)
+  --max-events <n>    Stop the run cleanly after handling this many events
+  --max-wall-seconds <s>  Stop the run cleanly after this much wall time; the
+                      saved config records the stop as max_events for replay
+  --matching <rule>   Same-price matching: fifo (default) or pro_rata
+  --speed-bump <d>    Hold orders that would take liquidity this long, e.g. 10ms
+  --batch-interval <d>  Match in frequent batch auctions at this interval instead
+                      of continuously, e.g. 50ms
+  --min-resting <d>   Hold cancels until their order has rested this long
+  --mechanism <spec>  Add a venue mechanism as type:key=value,... (repeatable):
+                      pro_rata, speed_bump:delay=<d>, min_resting:time=<d>,
+                      batch_auction:interval=<d>, maker_taker:maker=<p>,taker=<p>,
+                      throttle:max=<n>,window=<d>
+  --benchmark <b>     Slippage benchmark: decision_mid (default), arrival_mid,
+                      touch (far touch at decision) or vwap (run VWAP)
+  --markout <d>       Adverse selection horizon (repeatable; the first is the
+                      headline figure; default: 100ms)
+  --cancel-race-window <d>  How soon after a cancel decision contra flow must reach
+                      its target to count as a fill-vs-cancel race (default: 100ms)
+  --exclude <from>:<to>  Leave orders decided in this window out of the metrics
+                      and report them separately, e.g. 2s:2.5s (repeatable)
+  --percentiles <m>   How distributions are summarized: exact, approx (a streaming
+                      sketch within 0.5%), or auto (default: exact up to 2^20
+                      samples per distribution, then approx)
+  --cancel-at-close   Treat orders still resting at the close as canceled there,
+                      so unfilled ones leave the fill rate
+  --metric-groups <g,...>  Compute only these optional metric groups: races,
+                      cancel_races, priority, toxicity, requotes, activity
+  --custom-metric <name>  Run a registered metric plugin, e.g. max_position or
+                      max_drawdown (repeatable)
+  --assert <rule>     Fail the run when a rule breaks, e.g. "spread <= 0.10",
+                      "sides >= 1", "position.fast within 50" (repeatable)
+  --heatmap <d>       Sample the book's full depth every d and write heatmap.csv
+  --heatmap-format <f>  csv (default) or npy (heatmap.npy with _times/_prices.npy)
+  --decisions         Write each trader's strategy decisions to decisions_<id>.jsonl
+  --mark-baseline     Mark this run as the baseline later runs are compared against
+  --no-baseline       Leave the comparison against the baseline out of the report
+  --check-expected    After the run, fail unless the scenario's metrics fall within
+                      its shipped expected ranges (default seed and duration only)
+  --expected <file>   Check against the ranges in this expected.json instead
 
 Demo options:
   --seed <n>          Random seed (default: 42)
+  --check-invariants  Run the full order book invariant check after every order
+  --serial            Generate, match and log on one goroutine
+
+Tutorial options:
+  --seed <n>          Random seed (default: 42)
+  --duration <d>      Length of each step's run (default: 5s)
+  --out <dir>         Output directory (default: runs/tutorial)
+
+Mechanisms options:
+  --scenario <name>   Scenario: calm, thin, spike (default: calm)
+  --seed <n>          Random seed (default: 42)
+  --duration <d>      Length of each run (default: the scenario's)
+  --weights <k=w,...>  Composite score weights, e.g. "fairness=2,volume=0"; terms are
+                      fill_rate_pct, slippage_bps, time_to_fill_ms, adverse_selection_bps,
+                      net_pnl, spread_bps, top_depth, volume, and the groups fairness and
+                      quality (default: all 1)
+  --exclude <from>:<to>  Leave orders decided in this window out of every run's
+                      metrics, e.g. the shock a mechanism reacts to (repeatable)
+  --out <dir>         Output directory (default: runs/mechanisms)
+
+Sensitivity options:
+  --scenario <name>   Scenario: calm, thin, spike (default: calm)
+  --seed <n>          Random seed (default: 42)
+  --from <d>          Lowest slow-trader latency (default: 1ms)
+  --to <d>            Highest slow-trader latency (default: 200ms)
+  --steps <n>         Latencies to run, log-spaced (default: 9)
+  --linear            Space the latencies evenly instead
+  --vs <name=lo:hi>   Cross the latencies with a market parameter, as in robustness
+                      --vary, and map each gap over the grid, e.g. "depth=2:20"
+  --vs-steps <n>      Parameter values to run, evenly spaced (default: 5)
+  --duration <d>      Length of each run (default: the scenario's)
+  --weights <k=w,...>  Composite score weights, as in mechanisms
+  --out <dir>         Output directory (default: runs/sensitivity)
+
+Experiment usage: fairsim experiment queue-latency [options]
+  --scenario <name>   Scenario: calm, thin, spike (default: calm)
+  --seed <n>          Random seed (default: 42)
+  --from <d>          Lowest slow-trader latency (default: 1ms)
+  --to <d>            Highest slow-trader latency (default: 200ms)
+  --steps <n>         Latencies to run, log-spaced (default: 9)
+  --linear            Space the latencies evenly instead
+  --duration <d>      Length of each run (default: the scenario's)
+  --out <dir>         Output directory (default: runs/experiments/queue-latency)
+
+Robustness options:
+  --scenario <name>   Scenario: calm, thin, spike (default: calm)
+  --seed <n>          Random seed; run i uses seed+i (default: 42)
+  --runs <n>          Randomized runs (default: 20)
+  --vary <bounds>     Parameters and ranges, e.g. "depth=5:30,order_interval=2ms:20ms";
+                      also market_ratio, cancel_rate, max_size (repeatable; default:
+                      depth, order_interval and market_ratio at 0.5x to 1.5x)
+  --fixed-seed        Use --seed on every run, so only the parameters vary
+  --guards <k=v,...>  Abort degenerate runs as in run; they are listed in the
+                      report and left out of the distributions
+  --duration <d>      Length of each run (default: the scenario's)
+  --weights <k=w,...>  Composite score weights, as in mechanisms
+  --out <dir>         Output directory (default: runs/robustness)
+
+Calibrate options:
+  --target <k=v,...>  Market statistics to match (required): spread_bps,
+                      trades_per_sec, top_depth, volatility_bps (per √s)
+  --scenario <name>   Scenario to start from: calm, thin, spike (default: calm)
+  --config <file>     Start from this config instead
+  --seed <n>          Random seed, the same for every candidate (default: 42)
+  --duration <d>      Length of each run (default: the scenario's)
+  --budget <n>        Most candidate runs (default: 60)
+  --out <file>        Where the calibrated config goes (default: calibrated.json)
+
+Daemon options:
+  --socket <path>     Unix socket to listen on (default: runs/fairsim.sock)
+  --runs-dir <dir>    Where runs go unless a request says otherwise (default: runs)
+  --no-warm           Skip the warm-up runs before accepting requests
 
 Report options:
   --last-run          Use the most recent run
   --run-dir <path>    Path to a specific run directory
+  --from-log          Rebuild the report from the run's log alone, into
+                      <run-dir>/rebuilt (the default when the log is from
+                      another simulator version)
+  --stored            Print the saved report even if the log is from another version
 
 Replay options:
   --run-id <id>       Run id (e.g. calm_seed42)
   --run-dir <path>    Path to a specific run directory
-  --log <path>        Path to event log (defaults to <run-dir>/events.jsonl)`)
+  --log <path>        Path to event log (defaults to <run-dir>/events.jsonl)
+  --std-json          Decode and regenerate with encoding/json (byte-identical, slower)
+  --skip-validate     Compute metrics without checking the log against the schema first
+  --diagnose          On a hash mismatch, report the first divergent event and the book before it
+  --log-only          Rebuild metrics, the closing book and the report from the log
+                      alone, into <run-dir>/rebuilt, without regenerating the run
+  --depth <n>         Book levels per side shown by --diagnose (default: 5)
+
+Bench options:
+  --scenario <name>   Scenario to time (default: calm, thin, spike)
+  --seed <n>          Random seed (default: 42)
+  --iterations <n>    Runs per scenario (default: 3)
+  --serial            Time single-goroutine runs instead of the pipeline
+
+Audit options:
+  --run-id <id>       Run id (e.g. calm_seed42)
+  --run-dir <path>    Path to a specific run directory
+  --log <path>        Path to event log (defaults to <run-dir>/events.jsonl)
+  --trail <path>      Also write the per-order audit trail CSV (runs write audit_trail.csv)
+
+Dataset options:
+  --run-id <id>       Run id (e.g. calm_seed42)
+  --run-dir <path>    Path to a specific run directory
+  --log <path>        Path to event log (defaults to <run-dir>/events.jsonl)
+  --horizon <d>       Count fills within this long of the decision (default: 100ms)
+  --out <path>        Output CSV (default: features.csv beside the log)
+
+Heatmap options:
+  --run-id <id>       Run id (e.g. calm_seed42)
+  --run-dir <path>    Path to a specific run directory
+  --log <path>        Path to event log, at log level orders or above
+  --interval <d>      Sampling interval (default: 10ms)
+  --format <f>        csv (default) or npy
+  --out <path>        Output file (default: heatmap.csv or heatmap.npy beside the log)
+
+Verify-repro options:
+  --bundle <path>     Bundle file to verify, or to write with --create (required)
+  --create            Write a bundle from a completed run instead of verifying
+  --run-id <id>       --create: run id (e.g. calm_seed42)
+  --run-dir <path>    --create: path to a specific run directory
+  --no-log            --create: omit the event log (hash check only, no divergence diff)
+  --keep              Keep the re-run's output directory
+
+Clip options:
+  --run-id <id>       Run id (e.g. calm_seed42)
+  --run-dir <path>    Path to a specific run directory
+  --log <path>        Path to event log, at log level orders or above
+  --around <t>        Moment to centre on: a log timestamp in ns, or e.g. 5.2s (required)
+  --window <d>        Span of the clip (default: 200ms)
+  --out <path>        Output log (default: clip_<around>.jsonl beside the log)
+
+Book options:
+  --run-id <id>       Run id (e.g. calm_seed42)
+  --run-dir <path>    Path to a specific run directory
+  --log <path>        Path to event log, at log level orders or above
+  --at <t>            Moment to show: a log timestamp in ns, or e.g. 2.35s (required)
+  --depth <n>         Levels per side, best first; 0 for all (default: 10)
+  --checkpoint <d>    Spacing of the checkpoint index kept beside the log
+                      (default: 100ms)
+
+Views options:
+  --run-id <id>       Run id (e.g. calm_seed42)
+  --run-dir <path>    Path to a specific run directory
+  --trader <id>       Only this trader's view (default: both)
+  --out <dir>         Where view_<trader>.jsonl go (default: the run directory)
+
+Baseline usage: fairsim baseline [set|clear|show] [options]
+  set                 Mark a finished run as the baseline in runs/manifest.json;
+                      later runs' reports end with their change from it
+  clear               Remove the baseline
+  show                Print the baseline (default)
+  --run-id <id>       set: run id (e.g. calm_seed42)
+  --run-dir <path>    set: path to a specific run directory
+  --last-run          set: the most recent run (default)
+
+Upgrade options:
+  --run-id <id>       Run id (e.g. calm_seed42)
+  --run-dir <path>    Path to a specific run directory
+  --all               Every run under runs/
+  --dry-run           List what would change without writing
+
+Verify options:
+  --run-id <id>       Run id (e.g. calm_seed42)
+  --run-dir <path>    Path to a specific run directory
+  --last-run          The most recent run (default)
+
+Golden usage: fairsim golden <check|update> [options]
+  --dir <path>        Snapshot directory (default: test/testdata/golden)
+  --force             update: record changed output without a version bump`)
 }
 
 func cmdRun(args []string) {
 	scenarioName := ""
+	configPath := ""
 	seed := int64(42)
+	seedSet := false
+	checkInvariants := false
+	stdJSON := false
+	serial := false
+	var assertions []string
+	var fastLatency, fastJitter, slowLatency, slowJitter string
+	var fastGateway, slowGateway string
+	var fastMarketData, slowMarketData string
+	var fastIdle, slowIdle, fastWarmup, slowWarmup, fastHandshake, slowHandshake string
+	var fastRiskCheck, slowRiskCheck string
+	var fastCapital, slowCapital, fastMargin, slowMargin string
+	priceScale := int64(0)
+	var makerFee, takerFee string
+	var fastSignal, slowSignal signalFlags
+	var fastStrategy, slowStrategy string
+	var externalFlow, logLevel, guards, hedge string
+	var maxEvents, maxWall, jitterMode string
+	var backgroundFrom string
+	recordFlow := false
+	markBaseline, noBaseline := false, false
+	checkExpected, expectedPath := false, ""
+	var venue venueFlags
+	benchmark := ""
+	var markouts []string
+	var cancelRaceWindow, percentiles string
+	var excludes, customMetrics []string
+	metricGroups := ""
+	var heatmapInterval, heatmapFormat string
+	decisions, cancelAtClose := false, false
 
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
@@ -201,82 +757,836 @@ func cmdRun(args []string) {
 			if i < len(args) {
 				scenarioName = args[i]
 			}
+		case "--config":
+			i++
+			if i < len(args) {
+				configPath = args[i]
+			}
 		case "--seed":
 			i++
 			if i < len(args) {
 				fmt.Sscanf(args[i], "%d", &seed)
+				seedSet = true
+			}
+		case "--check-invariants":
+			checkInvariants = true
+		case "--std-json":
+			stdJSON = true
+		case "--serial":
+			serial = true
+		case "--check-expected":
+			checkExpected = true
+		case "--mark-baseline":
+			markBaseline = true
+		case "--no-baseline":
+			noBaseline = true
+		case "--expected":
+			i++
+			if i < len(args) {
+				expectedPath = args[i]
+			}
+		case "--assert":
+			i++
+			if i < len(args) {
+				assertions = append(assertions, args[i])
+			}
+		case "--price-scale":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &priceScale)
+			}
+		case "--maker-fee":
+			i++
+			if i < len(args) {
+				makerFee = args[i]
+			}
+		case "--taker-fee":
+			i++
+			if i < len(args) {
+				takerFee = args[i]
+			}
+		case "--matching", "--speed-bump", "--batch-interval", "--min-resting":
+			flag := args[i]
+			i++
+			if i < len(args) {
+				switch flag {
+				case "--matching":
+					venue.matching = args[i]
+				case "--speed-bump":
+					venue.speedBump = args[i]
+				case "--batch-interval":
+					venue.batchInterval = args[i]
+				case "--min-resting":
+					venue.minResting = args[i]
+				}
+			}
+		case "--mechanism":
+			i++
+			if i < len(args) {
+				venue.mechanisms = append(venue.mechanisms, args[i])
+			}
+		case "--benchmark":
+			i++
+			if i < len(args) {
+				benchmark = args[i]
+			}
+		case "--markout":
+			i++
+			if i < len(args) {
+				markouts = append(markouts, args[i])
+			}
+		case "--exclude":
+			i++
+			if i < len(args) {
+				excludes = append(excludes, args[i])
+			}
+		case "--cancel-race-window":
+			i++
+			if i < len(args) {
+				cancelRaceWindow = args[i]
+			}
+		case "--percentiles":
+			i++
+			if i < len(args) {
+				percentiles = args[i]
+			}
+		case "--metric-groups":
+			i++
+			if i < len(args) {
+				metricGroups = args[i]
+			}
+		case "--custom-metric":
+			i++
+			if i < len(args) {
+				customMetrics = append(customMetrics, args[i])
+			}
+		case "--heatmap":
+			i++
+			if i < len(args) {
+				heatmapInterval = args[i]
+			}
+		case "--heatmap-format":
+			i++
+			if i < len(args) {
+				heatmapFormat = args[i]
+			}
+		case "--decisions":
+			decisions = true
+		case "--cancel-at-close":
+			cancelAtClose = true
+		case "--fast-no-signal":
+			fastSignal.none = true
+		case "--slow-no-signal":
+			slowSignal.none = true
+		case "--fast-signal-delay", "--slow-signal-delay", "--fast-signal-noise", "--slow-signal-noise":
+			flag := args[i]
+			i++
+			if i < len(args) {
+				switch flag {
+				case "--fast-signal-delay":
+					fastSignal.delay = args[i]
+				case "--slow-signal-delay":
+					slowSignal.delay = args[i]
+				case "--fast-signal-noise":
+					fastSignal.noise = args[i]
+				case "--slow-signal-noise":
+					slowSignal.noise = args[i]
+				}
+			}
+		case "--log-level":
+			i++
+			if i < len(args) {
+				logLevel = args[i]
+			}
+		case "--external-flow":
+			i++
+			if i < len(args) {
+				externalFlow = args[i]
+			}
+		case "--background-from":
+			i++
+			if i < len(args) {
+				backgroundFrom = args[i]
+			}
+		case "--record-flow":
+			recordFlow = true
+		case "--guards":
+			i++
+			if i < len(args) {
+				guards = args[i]
+			}
+		case "--hedge":
+			i++
+			if i < len(args) {
+				hedge = args[i]
+			}
+		case "--max-events":
+			i++
+			if i < len(args) {
+				maxEvents = args[i]
+			}
+		case "--max-wall-seconds":
+			i++
+			if i < len(args) {
+				maxWall = args[i]
+			}
+		case "--fast-strategy":
+			i++
+			if i < len(args) {
+				fastStrategy = args[i]
+			}
+		case "--slow-strategy":
+			i++
+			if i < len(args) {
+				slowStrategy = args[i]
+			}
+		case "--jitter-mode":
+			i++
+			if i < len(args) {
+				jitterMode = args[i]
+			}
+		case "--fast-capital", "--slow-capital", "--fast-margin", "--slow-margin":
+			flag := args[i]
+			i++
+			if i < len(args) {
+				switch flag {
+				case "--fast-capital":
+					fastCapital = args[i]
+				case "--slow-capital":
+					slowCapital = args[i]
+				case "--fast-margin":
+					fastMargin = args[i]
+				case "--slow-margin":
+					slowMargin = args[i]
+				}
+			}
+		case "--fast-latency", "--fast-jitter", "--slow-latency", "--slow-jitter", "--fast-gateway", "--slow-gateway",
+			"--fast-market-data", "--slow-market-data", "--fast-idle", "--slow-idle", "--fast-warmup", "--slow-warmup",
+			"--fast-handshake", "--slow-handshake", "--fast-risk-check", "--slow-risk-check":
+			flag := args[i]
+			i++
+			if i < len(args) {
+				switch flag {
+				case "--fast-gateway":
+					fastGateway = args[i]
+				case "--slow-gateway":
+					slowGateway = args[i]
+				case "--fast-market-data":
+					fastMarketData = args[i]
+				case "--slow-market-data":
+					slowMarketData = args[i]
+				case "--fast-idle":
+					fastIdle = args[i]
+				case "--slow-idle":
+					slowIdle = args[i]
+				case "--fast-warmup":
+					fastWarmup = args[i]
+				case "--slow-warmup":
+					slowWarmup = args[i]
+				case "--fast-handshake":
+					fastHandshake = args[i]
+				case "--slow-handshake":
+					slowHandshake = args[i]
+				case "--fast-risk-check":
+					fastRiskCheck = args[i]
+				case "--slow-risk-check":
+					slowRiskCheck = args[i]
+				case "--fast-latency":
+					fastLatency = args[i]
+				case "--fast-jitter":
+					fastJitter = args[i]
+				case "--slow-latency":
+					slowLatency = args[i]
+				case "--slow-jitter":
+					slowJitter = args[i]
+				}
 			}
 		}
 	}
 
-	if scenarioName == "" {
-		fmt.Fprintln(os.Stderr, "Error: --scenario is required (calm, thin, spike)")
+	if (scenarioName == "") == (configPath == "") {
+		fmt.Fprintln(os.Stderr, "Error: exactly one of --scenario (calm, thin, spike) or --config is required")
 		os.Exit(1)
 	}
 
-	cfg := scenario.GetConfig(scenarioName, seed)
-	if cfg == nil {
+	var cfg *scenario.Config
+	if configPath != "" {
+		var err error
+		if cfg, err = scenario.LoadConfig(configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if seedSet {
+			cfg.Seed = seed
+		}
+		scenarioName, seed = cfg.Name, cfg.Seed
+	} else if cfg = scenario.GetConfig(scenarioName, seed); cfg == nil {
 		fmt.Fprintf(os.Stderr, "Error: unknown scenario '%s'\n", scenarioName)
 		os.Exit(1)
 	}
-
-	fmt.Printf("Running scenario: %s (seed=%d)\n", scenarioName, seed)
-
-	runner, err := sim.NewRunner(cfg, defaultRunsDir)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error initializing: %v\n", err)
-		os.Exit(1)
+	cfg.Assertions = append(cfg.Assertions, assertions...)
+	if externalFlow != "" {
+		cfg.ExternalFlow = externalFlow
 	}
-
-	result, err := runner.Run()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error running simulation: %v\n", err)
-		os.Exit(1)
+	if backgroundFrom != "" {
+		cfg.BackgroundFrom = backgroundFrom
 	}
-
-	fmt.Printf("Simulation complete.\n")
-	fmt.Printf("  Events processed: %d\n", result.EventCount)
-	fmt.Printf("  Trades executed:  %d\n", result.TradeCount)
-	fmt.Printf("  Wall time:        %v\n", result.Duration)
-	fmt.Printf("  Log hash:         %s\n", result.LogHash[:16]+"...")
-	fmt.Printf("  Output:           %s\n", result.OutputDir)
-
-	metricsByTrader, err := metrics.ComputeFromLog(result.LogPath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: could not compute metrics: %v\n", err)
-		return
+	if logLevel != "" {
+		cfg.LogLevel = logLevel
 	}
-
-	fmt.Println("\nMetrics Summary:")
-	report.PrintSummary(cfg, metricsByTrader)
-
-	reportGen := report.NewReport(cfg, metricsByTrader, result.OutputDir)
-	if err := reportGen.Generate(); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: could not generate report: %v\n", err)
-	} else {
-		fmt.Printf("\nReport written to: %s/report.md\n", result.OutputDir)
+	if guards != "" {
+		g, err := scenario.ParseGuards(guards)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --guards: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.Guards = g
 	}
-}
-
-func cmdReport(args []string) {
-	runDir := ""
-	lastRun := false
-	runId := ""
-
-	for i := 0; i < len(args); i++ {
-		switch args[i] {
-		case "--last-run":
-			lastRun = true
-		case "--run-dir":
-			i++
-			if i < len(args) {
-				runDir = args[i]
-			}
-		case "--run-id":
-			i++
-			if i < len(args) {
-				runId = args[i]
-			}
+	if hedge != "" {
+		h, err := scenario.ParseHedge(hedge)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --hedge: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.Hedge = &h
+	}
+	if maxEvents != "" {
+		n, err := strconv.ParseUint(maxEvents, 10, 64)
+		if err != nil || n == 0 {
+			fmt.Fprintf(os.Stderr, "Error: --max-events must be a positive integer, got %q\n", maxEvents)
+			os.Exit(1)
+		}
+		cfg.Budget.MaxEvents = n
+	}
+	if maxWall != "" {
+		v, err := strconv.ParseFloat(maxWall, 64)
+		if err != nil || v <= 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+			fmt.Fprintf(os.Stderr, "Error: --max-wall-seconds must be a positive number, got %q\n", maxWall)
+			os.Exit(1)
+		}
+		cfg.Budget.MaxWallSeconds = v
+	}
+	if priceScale != 0 {
+		if err := cfg.Rescale(domain.Scale(priceScale)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	for _, fee := range []struct {
+		flag, value string
+		dst         *int64
+	}{{"--maker-fee", makerFee, &cfg.MakerFee}, {"--taker-fee", takerFee, &cfg.TakerFee}} {
+		if fee.value == "" {
+			continue
+		}
+		v, err := cfg.Scale().Parse(fee.value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", fee.flag, err)
+			os.Exit(1)
+		}
+		*fee.dst = v
+	}
+	if jitterMode != "" {
+		if err := latency.ValidateJitterMode(jitterMode); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --jitter-mode: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.JitterMode = jitterMode
+	}
+	if err := overrideLatency(&cfg.FastTrader, fastLatency, fastJitter); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: fast trader: %v\n", err)
+		os.Exit(1)
+	}
+	for _, g := range []struct {
+		flag, value string
+		dst         *int64
+	}{
+		{"--fast-gateway", fastGateway, &cfg.FastTrader.GatewayNs},
+		{"--slow-gateway", slowGateway, &cfg.SlowTrader.GatewayNs},
+		{"--fast-market-data", fastMarketData, &cfg.FastTrader.MarketDataNs},
+		{"--slow-market-data", slowMarketData, &cfg.SlowTrader.MarketDataNs},
+		{"--fast-idle", fastIdle, &cfg.FastTrader.IdleNs},
+		{"--slow-idle", slowIdle, &cfg.SlowTrader.IdleNs},
+		{"--fast-warmup", fastWarmup, &cfg.FastTrader.WarmupNs},
+		{"--slow-warmup", slowWarmup, &cfg.SlowTrader.WarmupNs},
+		{"--fast-handshake", fastHandshake, &cfg.FastTrader.HandshakeNs},
+		{"--slow-handshake", slowHandshake, &cfg.SlowTrader.HandshakeNs},
+		{"--fast-risk-check", fastRiskCheck, &cfg.FastTrader.RiskCheckNs},
+		{"--slow-risk-check", slowRiskCheck, &cfg.SlowTrader.RiskCheckNs},
+	} {
+		if g.value == "" {
+			continue
+		}
+		ns, err := scenario.ParseDuration(g.value)
+		if err == nil && ns < 0 {
+			err = errors.New("must not be negative")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", g.flag, err)
+			os.Exit(1)
+		}
+		*g.dst = ns
+	}
+	for _, c := range []struct {
+		flag, value string
+		dst         *float64
+	}{
+		{"--fast-capital", fastCapital, &cfg.FastTrader.Capital},
+		{"--slow-capital", slowCapital, &cfg.SlowTrader.Capital},
+		{"--fast-margin", fastMargin, &cfg.FastTrader.Margin},
+		{"--slow-margin", slowMargin, &cfg.SlowTrader.Margin},
+	} {
+		if c.value == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(c.value, 64)
+		if err != nil || v <= 0 || math.IsInf(v, 0) {
+			fmt.Fprintf(os.Stderr, "Error: %s must be a positive number, got %q\n", c.flag, c.value)
+			os.Exit(1)
+		}
+		*c.dst = v
+	}
+	if err := overrideLatency(&cfg.SlowTrader, slowLatency, slowJitter); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: slow trader: %v\n", err)
+		os.Exit(1)
+	}
+	if err := fastSignal.apply(&cfg.FastTrader); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: fast trader: %v\n", err)
+		os.Exit(1)
+	}
+	if err := slowSignal.apply(&cfg.SlowTrader); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: slow trader: %v\n", err)
+		os.Exit(1)
+	}
+	for _, st := range []struct {
+		spec string
+		tc   *scenario.TraderConfig
+	}{{fastStrategy, &cfg.FastTrader}, {slowStrategy, &cfg.SlowTrader}} {
+		if st.spec == "" {
+			continue
+		}
+		p, err := scenario.ParseStrategyParams(st.spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s trader: %v\n", st.tc.ID, err)
+			os.Exit(1)
+		}
+		st.tc.Strategy = p
+	}
+	if err := venue.apply(&cfg.Venue); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: venue: %v\n", err)
+		os.Exit(1)
+	}
+	if benchmark != "" {
+		cfg.Metrics.Benchmark = benchmark
+	}
+	if len(markouts) > 0 {
+		cfg.Metrics.HorizonsNs = nil
+		for _, m := range markouts {
+			ns, err := scenario.ParseDuration(m)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: --markout: %v\n", err)
+				os.Exit(1)
+			}
+			cfg.Metrics.HorizonsNs = append(cfg.Metrics.HorizonsNs, ns)
+		}
+	}
+	if cancelRaceWindow != "" {
+		ns, err := scenario.ParseDuration(cancelRaceWindow)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --cancel-race-window: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.Metrics.CancelRaceWindowNs = ns
+	}
+	if percentiles != "" {
+		cfg.Metrics.Percentiles = percentiles
+	}
+	if cancelAtClose {
+		cfg.Metrics.CancelAtClose = true
+	}
+	if metricGroups != "" {
+		cfg.Metrics.Groups = strings.Split(metricGroups, ",")
+	}
+	cfg.Metrics.Custom = append(cfg.Metrics.Custom, customMetrics...)
+	for _, x := range excludes {
+		w, err := parseExclusion(x)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --exclude: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.Metrics.Exclude = append(cfg.Metrics.Exclude, w)
+	}
+	if err := cfg.Metrics.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: metrics: %v\n", err)
+		os.Exit(1)
+	}
+
+	var want *expected.File
+	if checkExpected || expectedPath != "" {
+		var err error
+		switch {
+		case expectedPath != "":
+			want, err = expected.Load(expectedPath)
+		case configPath != "":
+			err = errors.New("a --config run needs its ranges from --expected <file>")
+		default:
+			want, err = expected.Builtin(scenarioName)
+		}
+		if err == nil {
+			err = want.Applies(cfg)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --check-expected: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("Running scenario: %s (seed=%d)\n", scenarioName, seed)
+
+	runner, err := sim.NewRunner(cfg, defaultRunsDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing: %v\n", err)
+		os.Exit(1)
+	}
+	if checkInvariants {
+		runner.CheckInvariants = true
+	}
+	runner.StdJSON = stdJSON
+	runner.Pipeline = !serial
+	runner.DecisionLog = decisions
+	runner.RecordFlow = recordFlow
+	if heatmapInterval != "" {
+		ns, err := scenario.ParseDuration(heatmapInterval)
+		if err == nil && ns <= 0 {
+			err = errors.New("interval must be positive")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --heatmap: %v\n", err)
+			os.Exit(1)
+		}
+		runner.HeatmapIntervalNs = ns
+	}
+	switch heatmapFormat {
+	case "", "csv":
+	case "npy":
+		runner.HeatmapNPY = true
+	default:
+		fmt.Fprintf(os.Stderr, "Error: --heatmap-format: unknown format %q (csv, npy)\n", heatmapFormat)
+		os.Exit(1)
+	}
+
+	result, err := runner.Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running simulation: %v\n", err)
+		var runErr *sim.RunError
+		var guardErr *sim.GuardError
+		if errors.As(err, &runErr) {
+			fmt.Fprintf(os.Stderr, "Partial output left in %s\n", runErr.OutputDir)
+		} else if errors.As(err, &guardErr) {
+			fmt.Fprintf(os.Stderr, "Log up to the aborting event left in %s\n", guardErr.OutputDir)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Printf("Simulation complete.\n")
+	fmt.Printf("  Events processed: %d\n", result.EventCount)
+	fmt.Printf("  Trades executed:  %d\n", result.TradeCount)
+	fmt.Printf("  Wall time:        %v\n", result.Duration)
+	fmt.Printf("  Log hash:         %s\n", result.LogHash[:16]+"...")
+	// NewRunner has already validated the level
+	if level, _ := eventlog.ParseLevel(cfg.LogLevel); level == eventlog.LevelFull {
+		fmt.Printf("  Audit:            %s\n", auditSummary(result.AuditViolations))
+	} else {
+		fmt.Printf("  Audit:            skipped (log level %s)\n", level)
+	}
+	fmt.Printf("  Output:           %s\n", result.OutputDir)
+	if ties, contested := countTies(result.Ties); ties > 0 {
+		fmt.Printf("  Same-time ties:   %d, %d contested (broken by event order; see report)\n", ties, contested)
+	}
+	for _, leg := range result.Hedge {
+		fmt.Printf("  %-18s%d hedges, slippage %.2f bps (%.2f bps in flight)\n",
+			"Hedge ("+leg.TraderID+"):", leg.Hedges, leg.SlippageBps, leg.LatencyBps)
+	}
+	for _, u := range result.Capital {
+		fmt.Printf("  %-18s%.2f of %.2f at peak, %d orders rejected\n",
+			"Capital ("+u.TraderID+"):", u.PeakUsed, u.Capital, u.Rejected)
+	}
+	if t := result.Truncated; t != nil {
+		fmt.Printf("  Truncated:        %s: %s, at t=%s; metrics cover the completed portion\n",
+			t.Limit, t.Detail, latency.Format(t.Timestamp))
+	}
+
+	metricsByTrader, err := metrics.ComputeFromLog(result.LogPath, cfg.Scale(), cfg.Metrics)
+	var levelErr *eventlog.LevelError
+	if errors.As(err, &levelErr) {
+		fmt.Printf("\nMetrics and report skipped: %v\n", err)
+		sealRun(result.OutputDir)
+		if want != nil {
+			fmt.Fprintln(os.Stderr, "Error: --check-expected needs metrics")
+			os.Exit(1)
+		}
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not compute metrics: %v\n", err)
+		sealRun(result.OutputDir)
+		if want != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Println("\nMetrics Summary:")
+	report.PrintSummary(cfg, metricsByTrader)
+
+	reportGen := report.ForRun(cfg, metricsByTrader, result)
+	if attribution.Applies(cfg) {
+		fmt.Println("\nLatency and signal access both differ; re-running to attribute the gaps...")
+		gaps, err := attributeGaps(cfg, metricsByTrader)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not attribute gaps: %v\n", err)
+		}
+		reportGen.Attribution = gaps
+	}
+	if batching.Applies(cfg) {
+		fmt.Printf("\nSlow trader batches every %d signals; re-running it reactive to compare...\n", cfg.SlowTrader.Strategy.BatchSignals)
+		cmp, err := compareBatching(cfg, metricsByTrader)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not compare batching: %v\n", err)
+		}
+		reportGen.Batching = cmp
+	}
+	if len(cfg.Background) > 0 {
+		background, err := metrics.BackgroundFromLog(result.LogPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not compute background metrics: %v\n", err)
+		}
+		reportGen.Background = background
+	}
+	if len(cfg.Sessions) > 0 {
+		sessions, err := metrics.ComputeSessionsFromLog(result.LogPath, cfg.Scale(), cfg.Metrics)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not compute session metrics: %v\n", err)
+		}
+		reportGen.Sessions = sessions
+	}
+	if len(cfg.Metrics.Exclude) > 0 {
+		excluded, err := metrics.ComputeExcludedFromLog(result.LogPath, cfg.Scale(), cfg.Metrics)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not compute excluded-window metrics: %v\n", err)
+		}
+		reportGen.Excluded = excluded
+	}
+	if !noBaseline && !markBaseline {
+		man, err := manifest.Load(defaultRunsDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not read the baseline: %v\n", err)
+		} else if man.Baseline != nil {
+			reportGen.Baseline = man.Baseline
+			fmt.Printf("\nCompared against baseline: %s\n", man.Baseline.RunDir)
+		}
+	}
+	if err := reportGen.Generate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not generate report: %v\n", err)
+	} else {
+		fmt.Printf("\nReport written to: %s/report.md\n", result.OutputDir)
+		if markBaseline {
+			if err := setBaseline(result.OutputDir); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not mark the baseline: %v\n", err)
+			}
+		}
+	}
+	sealRun(result.OutputDir)
+
+	if want != nil && !printExpected(want, cfg, metricsByTrader, result.TradeCount) {
+		os.Exit(1)
+	}
+}
+
+// printExpected checks a run against its expected ranges, prints each
+// metric outside them, and reports whether all held
+func printExpected(want *expected.File, cfg *scenario.Config, m map[string]*metrics.TraderMetrics, tradeCount int) bool {
+	results, err := want.Check(m[cfg.FastTrader.ID], m[cfg.SlowTrader.ID], tradeCount)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --check-expected: %v\n", err)
+		return false
+	}
+	failed := expected.Failed(results)
+	fmt.Printf("\nExpected results: %d of %d metrics within range\n", len(results)-failed, len(results))
+	for _, r := range results {
+		if !r.OK {
+			fmt.Printf("  %-28s %12.4f  outside [%g, %g]\n", r.Key, r.Value, r.Range.Min, r.Range.Max)
+		}
+	}
+	return failed == 0
+}
+
+// sealRun writes the run's artifacts.json, once its last file is in place
+func sealRun(dir string) {
+	if _, err := artifacts.Write(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not write %s: %v\n", artifacts.File, err)
+	}
+}
+
+// resealRun re-hashes a run whose files fairsim has rewritten, if it has
+// an artifacts.json to keep current
+func resealRun(dir string) {
+	if _, err := artifacts.Read(dir); err == nil {
+		sealRun(dir)
+	}
+}
+
+// attributeGaps runs the attribution counterfactuals in a scratch directory
+func attributeGaps(cfg *scenario.Config, m map[string]*metrics.TraderMetrics) ([]attribution.Gap, error) {
+	dir, err := os.MkdirTemp("", "fairsim-attribution-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	return attribution.Run(cfg, m, dir)
+}
+
+// compareBatching runs the reactive slow trader in a scratch directory
+func compareBatching(cfg *scenario.Config, m map[string]*metrics.TraderMetrics) (*batching.Comparison, error) {
+	dir, err := os.MkdirTemp("", "fairsim-batching-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	return batching.Run(cfg, m, dir)
+}
+
+// signalFlags holds one trader's --*-signal-* overrides
+type signalFlags struct {
+	delay, noise string
+	none         bool
+}
+
+func (f signalFlags) apply(tc *scenario.TraderConfig) error {
+	if f.delay != "" {
+		d, err := time.ParseDuration(f.delay)
+		if err != nil {
+			return fmt.Errorf("signal delay: %w", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("signal delay must be non-negative")
+		}
+		tc.SignalDelayNs = int64(d)
+	}
+	if f.noise != "" {
+		v, err := strconv.ParseFloat(f.noise, 64)
+		if err != nil || v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+			return fmt.Errorf("signal noise must be a non-negative number, got %q", f.noise)
+		}
+		tc.SignalNoise = v
+	}
+	if f.none {
+		tc.NoSignal = true
+	}
+	return nil
+}
+
+// venueFlags are the run command's venue mechanism overrides
+type venueFlags struct {
+	matching, speedBump, batchInterval, minResting string
+	mechanisms                                     []string
+}
+
+func (f venueFlags) apply(v *scenario.Venue) error {
+	if f.matching != "" {
+		v.Matching = f.matching
+	}
+	for _, d := range []struct {
+		flag, value string
+		dst         *int64
+	}{
+		{"--speed-bump", f.speedBump, &v.SpeedBumpNs},
+		{"--batch-interval", f.batchInterval, &v.BatchIntervalNs},
+		{"--min-resting", f.minResting, &v.MinRestingNs},
+	} {
+		if d.value == "" {
+			continue
+		}
+		ns, err := scenario.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.flag, err)
+		}
+		*d.dst = ns
+	}
+	for _, m := range f.mechanisms {
+		spec, err := scenario.ParseMechanismSpec(m)
+		if err != nil {
+			return fmt.Errorf("--mechanism: %w", err)
+		}
+		v.Mechanisms = append(v.Mechanisms, spec)
+	}
+	return v.Validate()
+}
+
+// overrideLatency replaces a trader's base latency and/or jitter with
+// durations such as "150us" or "1.5ms"; empty strings keep the default
+func overrideLatency(tc *scenario.TraderConfig, base, jitter string) error {
+	if base == "" && jitter == "" {
+		return nil
+	}
+	baseDur := time.Duration(tc.BaseLatencyNs())
+	jitterDur := time.Duration(tc.JitterNs())
+	var err error
+	if base != "" {
+		if baseDur, err = time.ParseDuration(base); err != nil {
+			return fmt.Errorf("latency: %w", err)
+		}
+	}
+	if jitter != "" {
+		if jitterDur, err = time.ParseDuration(jitter); err != nil {
+			return fmt.Errorf("jitter: %w", err)
+		}
+	}
+	return tc.SetLatency(baseDur, jitterDur)
+}
+
+// parseExclusion reads an exclusion window written <from>:<to>, e.g.
+// "2s:2.5s"
+func parseExclusion(s string) (metrics.Window, error) {
+	from, to, ok := strings.Cut(s, ":")
+	if !ok {
+		return metrics.Window{}, fmt.Errorf("%q: want <from>:<to>, e.g. 2s:2.5s", s)
+	}
+	start, err := scenario.ParseDuration(from)
+	if err != nil {
+		return metrics.Window{}, err
+	}
+	end, err := scenario.ParseDuration(to)
+	if err != nil {
+		return metrics.Window{}, err
+	}
+	return metrics.Window{StartNs: start, EndNs: end}, nil
+}
+
+func cmdReport(args []string) {
+	runDir := ""
+	lastRun := false
+	runId := ""
+	stored, fromLog := false, false
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--last-run":
+			lastRun = true
+		case "--stored":
+			stored = true
+		case "--from-log":
+			fromLog = true
+		case "--run-dir":
+			i++
+			if i < len(args) {
+				runDir = args[i]
+			}
+		case "--run-id":
+			i++
+			if i < len(args) {
+				runId = args[i]
+			}
 		}
 	}
 
@@ -298,6 +1608,37 @@ func cmdReport(args []string) {
 		os.Exit(1)
 	}
 
+	if stored && fromLog {
+		fmt.Fprintln(os.Stderr, "Error: --stored and --from-log are exclusive")
+		os.Exit(1)
+	}
+
+	// A log from another version is still analyzable, but its saved report
+	// reflects that version's metrics; rebuild it from the log instead
+	logPath := filepath.Join(runDir, "events.jsonl")
+	if !stored && !fromLog {
+		if v, err := provenance.LogVersion(logPath); err == nil && v != provenance.Version {
+			fmt.Fprintf(os.Stderr, "Log is from simulator v%d, this is v%d; rebuilding the report from the log alone (--stored prints the saved one)\n", v, provenance.Version)
+			fromLog = true
+		}
+	}
+	if fromLog {
+		cfg, err := compat.ReadConfig(runDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: could not read config: %v\n", err)
+			os.Exit(1)
+		}
+		m, _, err := scanEventLog(logPath, cfg.Scale(), cfg.Metrics, false)
+		if err == nil {
+			runDir = filepath.Join(runDir, rebuiltDir)
+			err = rebuildReport(cfg, m, logPath, runDir)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error rebuilding report from %s: %v\n", logPath, err)
+			os.Exit(1)
+		}
+	}
+
 	reportPath := runDir + "/report.md"
 	data, err := os.ReadFile(reportPath)
 	if err != nil {
@@ -316,6 +1657,8 @@ func cmdReport(args []string) {
 
 func cmdDemo(args []string) {
 	seed := int64(42)
+	checkInvariants := false
+	serial := false
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
 		case "--seed":
@@ -323,6 +1666,10 @@ func cmdDemo(args []string) {
 			if i < len(args) {
 				fmt.Sscanf(args[i], "%d", &seed)
 			}
+		case "--check-invariants":
+			checkInvariants = true
+		case "--serial":
+			serial = true
 		}
 	}
 
@@ -338,6 +1685,10 @@ func cmdDemo(args []string) {
 			fmt.Fprintf(os.Stderr, "Error initializing %s: %v\n", name, err)
 			os.Exit(1)
 		}
+		if checkInvariants {
+			runner.CheckInvariants = true
+		}
+		runner.Pipeline = !serial
 
 		result, err := runner.Run()
 		if err != nil {
@@ -348,16 +1699,18 @@ func cmdDemo(args []string) {
 		fmt.Printf("  %s: %d events, %d trades, %v\n",
 			name, result.EventCount, result.TradeCount, result.Duration)
 
-		metricsByTrader, err := metrics.ComputeFromLog(result.LogPath)
+		metricsByTrader, err := metrics.ComputeFromLog(result.LogPath, cfg.Scale(), cfg.Metrics)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: could not compute metrics for %s: %v\n", name, err)
 			continue
 		}
 
 		reportGen := report.NewReport(cfg, metricsByTrader, result.OutputDir)
+		reportGen.Provenance = result.Provenance
 		if err := reportGen.Generate(); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: report generation failed for %s: %v\n", name, err)
 		}
+		sealRun(result.OutputDir)
 
 		results = append(results, report.ScenarioResult{
 			Config:  cfg,
@@ -375,3 +1728,1858 @@ func cmdDemo(args []string) {
 		fmt.Printf("\nCross-scenario report: %s/cross-scenario-report.md\n", defaultRunsDir)
 	}
 }
+
+// tutorialLatencies are the slow trader's latencies, one step each,
+// against a 1ms fast trader
+var tutorialLatencies = []string{"5ms", "20ms", "100ms"}
+
+func cmdTutorial(args []string) {
+	if err := runTutorial(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runTutorial(args []string) error {
+	seed := int64(42)
+	duration := "5s"
+	outDir := filepath.Join(defaultRunsDir, "tutorial")
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--seed":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &seed)
+			}
+		case "--duration":
+			i++
+			if i < len(args) {
+				duration = args[i]
+			}
+		case "--out":
+			i++
+			if i < len(args) {
+				outDir = args[i]
+			}
+		default:
+			return fmt.Errorf("unknown tutorial option %q", args[i])
+		}
+	}
+	durationNs, err := scenario.ParseDuration(duration)
+	if err != nil {
+		return fmt.Errorf("duration: %w", err)
+	}
+	if durationNs == 0 {
+		return errors.New("duration must be positive")
+	}
+
+	var steps []report.ScenarioResult
+	for i, slowLatency := range tutorialLatencies {
+		cfg := scenario.GetConfig("calm", seed)
+		cfg.Duration = durationNs
+		if err := overrideLatency(&cfg.FastTrader, "1ms", "0"); err != nil {
+			return err
+		}
+		if err := overrideLatency(&cfg.SlowTrader, slowLatency, "0"); err != nil {
+			return err
+		}
+		fmt.Printf("Step %d: slow trader at %s...\n", i+1, slowLatency)
+
+		runner, err := sim.NewRunner(cfg, filepath.Join(outDir, "slow_"+slowLatency))
+		if err != nil {
+			return err
+		}
+		result, err := runner.Run()
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+		m, err := metrics.ComputeFromLog(result.LogPath, cfg.Scale(), cfg.Metrics)
+		if err != nil {
+			return fmt.Errorf("step %d metrics: %w", i+1, err)
+		}
+		reportGen := report.NewReport(cfg, m, result.OutputDir)
+		reportGen.Provenance = result.Provenance
+		if err := reportGen.Generate(); err != nil {
+			return fmt.Errorf("step %d report: %w", i+1, err)
+		}
+		sealRun(result.OutputDir)
+		steps = append(steps, report.ScenarioResult{Config: cfg, Metrics: m, RunDir: result.OutputDir})
+	}
+
+	if err := report.NewTutorial(steps, outDir).Generate(); err != nil {
+		return err
+	}
+	fmt.Printf("\nTutorial: %s\n", filepath.Join(outDir, "tutorial.md"))
+	return nil
+}
+
+func cmdMechanisms(args []string) {
+	if err := runMechanisms(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runMechanisms(args []string) error {
+	scenarioName := "calm"
+	seed := int64(42)
+	outDir := filepath.Join(defaultRunsDir, "mechanisms")
+	duration, weightSpec := "", ""
+	var exclude []metrics.Window
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--scenario":
+			i++
+			if i < len(args) {
+				scenarioName = args[i]
+			}
+		case "--duration":
+			i++
+			if i < len(args) {
+				duration = args[i]
+			}
+		case "--seed":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &seed)
+			}
+		case "--out":
+			i++
+			if i < len(args) {
+				outDir = args[i]
+			}
+		case "--weights":
+			i++
+			if i < len(args) {
+				weightSpec = args[i]
+			}
+		case "--exclude":
+			i++
+			if i < len(args) {
+				w, err := parseExclusion(args[i])
+				if err != nil {
+					return fmt.Errorf("--exclude: %w", err)
+				}
+				exclude = append(exclude, w)
+			}
+		default:
+			return fmt.Errorf("unknown mechanisms option %q", args[i])
+		}
+	}
+	weights, err := scoring.ParseWeights(weightSpec)
+	if err != nil {
+		return fmt.Errorf("--weights: %w", err)
+	}
+	base := scenario.GetConfig(scenarioName, seed)
+	if base == nil {
+		return fmt.Errorf("unknown scenario '%s'", scenarioName)
+	}
+	durationNs := base.Duration
+	if duration != "" {
+		var err error
+		if durationNs, err = scenario.ParseDuration(duration); err != nil {
+			return fmt.Errorf("duration: %w", err)
+		}
+		if durationNs == 0 {
+			return errors.New("duration must be positive")
+		}
+	}
+
+	var results []report.MechanismResult
+	var scored []scoring.Run
+	for _, mech := range scenario.Mechanisms() {
+		cfg := scenario.GetConfig(scenarioName, seed)
+		cfg.Duration = durationNs
+		cfg.Venue = mech.Venue
+		cfg.Metrics.Exclude = exclude
+		fmt.Printf("Running %s (%s)...\n", mech.Name, venue.Describe(cfg))
+
+		runner, err := sim.NewRunner(cfg, filepath.Join(outDir, mech.Name))
+		if err != nil {
+			return err
+		}
+		result, err := runner.Run()
+		if err != nil {
+			return fmt.Errorf("%s: %w", mech.Name, err)
+		}
+		m, err := metrics.ComputeFromLog(result.LogPath, cfg.Scale(), cfg.Metrics)
+		if err != nil {
+			return fmt.Errorf("%s metrics: %w", mech.Name, err)
+		}
+		sealRun(result.OutputDir)
+		results = append(results, report.MechanismResult{
+			Name:           mech.Name,
+			ScenarioResult: report.ScenarioResult{Config: cfg, Metrics: m, RunDir: result.OutputDir},
+		})
+		run, err := scoring.NewRun(mech.Name, result.OutputDir, scoring.Gaps(m[cfg.FastTrader.ID], m[cfg.SlowTrader.ID]))
+		if err != nil {
+			return err
+		}
+		scored = append(scored, run)
+	}
+
+	mr := report.NewMechanismReport(results, outDir)
+	mr.Scores = scoring.Rank(scored, weights)
+	if err := mr.Generate(); err != nil {
+		return err
+	}
+	printBestScore(mr.Scores)
+	fmt.Printf("\nMechanism comparison: %s\n", filepath.Join(outDir, "mechanisms.md"))
+	return nil
+}
+
+// printBestScore names a sweep's top-scoring run and the size of its
+// Pareto front
+func printBestScore(r *scoring.Ranking) {
+	if len(r.Scores) == 0 {
+		return
+	}
+	best := r.Scores[0]
+	fmt.Printf("\nBest composite score: %s (%.3f; fairness %.3f, quality %.3f), %d on the Pareto front\n",
+		best.Label, best.Score, best.Fairness, best.Quality, len(r.Front()))
+}
+
+func cmdRobustness(args []string) {
+	if err := runRobustness(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runRobustness(args []string) error {
+	scenarioName := "calm"
+	seed := int64(42)
+	spec := robustness.Spec{Runs: 20}
+	var vary []string
+	duration, guards, weightSpec := "", "", ""
+	outDir := filepath.Join(defaultRunsDir, "robustness")
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--scenario":
+			i++
+			if i < len(args) {
+				scenarioName = args[i]
+			}
+		case "--seed":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &seed)
+			}
+		case "--runs":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &spec.Runs)
+			}
+		case "--vary":
+			i++
+			if i < len(args) {
+				vary = append(vary, args[i])
+			}
+		case "--fixed-seed":
+			spec.FixedSeed = true
+		case "--guards":
+			i++
+			if i < len(args) {
+				guards = args[i]
+			}
+		case "--duration":
+			i++
+			if i < len(args) {
+				duration = args[i]
+			}
+		case "--out":
+			i++
+			if i < len(args) {
+				outDir = args[i]
+			}
+		case "--weights":
+			i++
+			if i < len(args) {
+				weightSpec = args[i]
+			}
+		default:
+			return fmt.Errorf("unknown robustness option %q", args[i])
+		}
+	}
+	weights, err := scoring.ParseWeights(weightSpec)
+	if err != nil {
+		return fmt.Errorf("--weights: %w", err)
+	}
+	cfg := scenario.GetConfig(scenarioName, seed)
+	if cfg == nil {
+		return fmt.Errorf("unknown scenario '%s'", scenarioName)
+	}
+	if duration != "" {
+		d, err := scenario.ParseDuration(duration)
+		if err != nil {
+			return fmt.Errorf("duration: %w", err)
+		}
+		if d == 0 {
+			return errors.New("duration must be positive")
+		}
+		cfg.Duration = d
+	}
+	if guards != "" {
+		g, err := scenario.ParseGuards(guards)
+		if err != nil {
+			return fmt.Errorf("--guards: %w", err)
+		}
+		cfg.Guards = g
+	}
+	if len(vary) == 0 {
+		spec.Bounds = robustness.DefaultBounds(cfg, robustness.DefaultVaried)
+	} else {
+		bounds, err := robustness.ParseBounds(strings.Join(vary, ","))
+		if err != nil {
+			return fmt.Errorf("--vary: %w", err)
+		}
+		spec.Bounds = bounds
+	}
+
+	result, err := robustness.Study(cfg, spec, outDir, func(run int) {
+		fmt.Printf("Run %d/%d...\n", run+1, spec.Runs)
+	})
+	if err != nil {
+		return err
+	}
+	var scored []scoring.Run
+	for _, r := range result.Runs {
+		run, err := scoring.NewRun(fmt.Sprintf("run %d", r.Index), r.RunDir, r.Gaps)
+		if err != nil {
+			return err
+		}
+		scored = append(scored, run)
+	}
+	rr := report.NewRobustnessReport(result, outDir)
+	rr.Scores = scoring.Rank(scored, weights)
+	if err := rr.Generate(); err != nil {
+		return err
+	}
+	if len(result.Aborted) > 0 {
+		fmt.Printf("\n%d of %d runs aborted by guards\n", len(result.Aborted), spec.Runs)
+	}
+	if len(result.Influence) > 0 {
+		fmt.Printf("\nMost influential parameter: %s (mean |rho| %.2f)\n", result.Influence[0].Param, result.Influence[0].MeanAbs)
+	}
+	printBestScore(rr.Scores)
+	fmt.Printf("Robustness report: %s\n", filepath.Join(outDir, "robustness.md"))
+	return nil
+}
+
+func cmdDaemon(args []string) {
+	if err := runDaemon(args, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runDaemon serves until SIGINT or SIGTERM, or until stop is closed
+func runDaemon(args []string, stop <-chan struct{}) error {
+	socket := filepath.Join(defaultRunsDir, "fairsim.sock")
+	runsDir := defaultRunsDir
+	warm := true
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--socket":
+			i++
+			if i < len(args) {
+				socket = args[i]
+			}
+		case "--runs-dir":
+			i++
+			if i < len(args) {
+				runsDir = args[i]
+			}
+		case "--no-warm":
+			warm = false
+		default:
+			return fmt.Errorf("unknown daemon option %q", args[i])
+		}
+	}
+
+	// A socket file nobody answers on is left over from a daemon that died
+	if _, err := os.Stat(socket); err == nil {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			return fmt.Errorf("a daemon is already listening on %s", socket)
+		}
+		os.Remove(socket)
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0755); err != nil {
+		return err
+	}
+
+	srv := daemon.NewServer(runsDir)
+	srv.Logf = func(format string, args ...any) { fmt.Printf(format+"\n", args...) }
+	if warm {
+		start := time.Now()
+		if err := srv.Warm(); err != nil {
+			return err
+		}
+		fmt.Printf("Warmed up in %v\n", time.Since(start).Round(time.Millisecond))
+	}
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socket)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	closed := make(chan struct{})
+	go func() {
+		select {
+		case <-sigs:
+		case <-stop:
+		}
+		srv.Close()
+		close(closed)
+	}()
+	fmt.Printf("Listening on %s\n", socket)
+	if err := srv.Serve(ln); err != nil {
+		return err
+	}
+	// Let in-flight runs answer before exiting
+	<-closed
+	return nil
+}
+
+func cmdCalibrate(args []string) {
+	if err := runCalibrate(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runCalibrate(args []string) error {
+	scenarioName, configPath := "", ""
+	seed := int64(-1)
+	duration, targetSpec := "", ""
+	budget := 60
+	outPath := "calibrated.json"
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--scenario":
+			i++
+			if i < len(args) {
+				scenarioName = args[i]
+			}
+		case "--config":
+			i++
+			if i < len(args) {
+				configPath = args[i]
+			}
+		case "--seed":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &seed)
+			}
+		case "--duration":
+			i++
+			if i < len(args) {
+				duration = args[i]
+			}
+		case "--target":
+			i++
+			if i < len(args) {
+				targetSpec = args[i]
+			}
+		case "--budget":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &budget)
+			}
+		case "--out":
+			i++
+			if i < len(args) {
+				outPath = args[i]
+			}
+		default:
+			return fmt.Errorf("unknown calibrate option %q", args[i])
+		}
+	}
+	targets, err := calibrate.ParseTargets(targetSpec)
+	if err != nil {
+		return fmt.Errorf("--target: %w", err)
+	}
+	var cfg *scenario.Config
+	switch {
+	case scenarioName != "" && configPath != "":
+		return errors.New("--scenario and --config are exclusive")
+	case configPath != "":
+		if cfg, err = scenario.LoadConfig(configPath); err != nil {
+			return err
+		}
+	default:
+		if scenarioName == "" {
+			scenarioName = "calm"
+		}
+		if cfg = scenario.GetConfig(scenarioName, 42); cfg == nil {
+			return fmt.Errorf("unknown scenario '%s'", scenarioName)
+		}
+	}
+	if seed >= 0 {
+		cfg.Seed = seed
+	}
+	if duration != "" {
+		d, err := scenario.ParseDuration(duration)
+		if err != nil {
+			return fmt.Errorf("duration: %w", err)
+		}
+		if d == 0 {
+			return errors.New("duration must be positive")
+		}
+		cfg.Duration = d
+	}
+
+	result, err := calibrate.Calibrate(cfg, targets, budget, func(n int, t calibrate.Trial) {
+		fmt.Printf("Candidate %d: loss %.4f (%s)\n", n, t.Loss, t.Params.Describe())
+	})
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(result.Config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outPath, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nBest of %d candidates: %s\n\n", len(result.Trials), result.Best.Params.Describe())
+	fmt.Printf("%-24s %10s %10s %10s\n", "Statistic", "Target", "Start", "Calibrated")
+	for _, st := range calibrate.Stats {
+		target, ok := targets[st.Key]
+		if !ok {
+			continue
+		}
+		fmt.Printf("%-24s %10.3f %10.3f %10.3f\n", st.Name, target,
+			result.Start.Measured.Get(st.Key), result.Best.Measured.Get(st.Key))
+	}
+	fmt.Printf("%-24s %10s %10.4f %10.4f\n", "Loss", "", result.Start.Loss, result.Best.Loss)
+	fmt.Printf("\nCalibrated config: %s (run it with --config %s)\n", outPath, outPath)
+	return nil
+}
+
+func cmdSensitivity(args []string) {
+	if err := runSensitivity(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runSensitivity(args []string) error {
+	scenarioName := "calm"
+	seed := int64(42)
+	from, to := "1ms", "200ms"
+	steps := 9
+	linear := false
+	duration, weightSpec, vs := "", "", ""
+	vsSteps := 5
+	outDir := filepath.Join(defaultRunsDir, "sensitivity")
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--scenario":
+			i++
+			if i < len(args) {
+				scenarioName = args[i]
+			}
+		case "--seed":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &seed)
+			}
+		case "--from":
+			i++
+			if i < len(args) {
+				from = args[i]
+			}
+		case "--to":
+			i++
+			if i < len(args) {
+				to = args[i]
+			}
+		case "--steps":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &steps)
+			}
+		case "--linear":
+			linear = true
+		case "--vs":
+			i++
+			if i < len(args) {
+				vs = args[i]
+			}
+		case "--vs-steps":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &vsSteps)
+			}
+		case "--duration":
+			i++
+			if i < len(args) {
+				duration = args[i]
+			}
+		case "--out":
+			i++
+			if i < len(args) {
+				outDir = args[i]
+			}
+		case "--weights":
+			i++
+			if i < len(args) {
+				weightSpec = args[i]
+			}
+		default:
+			return fmt.Errorf("unknown sensitivity option %q", args[i])
+		}
+	}
+	weights, err := scoring.ParseWeights(weightSpec)
+	if err != nil {
+		return fmt.Errorf("--weights: %w", err)
+	}
+	cfg := scenario.GetConfig(scenarioName, seed)
+	if cfg == nil {
+		return fmt.Errorf("unknown scenario '%s'", scenarioName)
+	}
+	if duration != "" {
+		d, err := scenario.ParseDuration(duration)
+		if err != nil {
+			return fmt.Errorf("duration: %w", err)
+		}
+		if d == 0 {
+			return errors.New("duration must be positive")
+		}
+		cfg.Duration = d
+	}
+	fromNs, err := scenario.ParseDuration(from)
+	if err != nil {
+		return fmt.Errorf("--from: %w", err)
+	}
+	toNs, err := scenario.ParseDuration(to)
+	if err != nil {
+		return fmt.Errorf("--to: %w", err)
+	}
+	latencies, err := sensitivity.Latencies(fromNs, toNs, steps, linear)
+	if err != nil {
+		return err
+	}
+	if vs != "" {
+		return runSensitivityGrid(cfg, latencies, vs, vsSteps, weights, outDir)
+	}
+
+	result, err := sensitivity.Sweep(cfg, latencies, outDir, func(lat int64) {
+		fmt.Printf("Slow trader at %s...\n", latency.Format(lat))
+	})
+	if err != nil {
+		return err
+	}
+	var scored []scoring.Run
+	for i, lat := range result.Latencies {
+		gaps := make(map[string]float64, len(result.Curves))
+		for j, c := range result.Curves {
+			gaps[sensitivity.Metrics[j].Key] = c.Points[i].Gap
+		}
+		run, err := scoring.NewRun("slow "+latency.Format(lat), result.RunDirs[i], gaps)
+		if err != nil {
+			return err
+		}
+		scored = append(scored, run)
+	}
+	sr := report.NewSensitivityReport(result, outDir)
+	sr.Scores = scoring.Rank(scored, weights)
+	if err := sr.Generate(); err != nil {
+		return err
+	}
+	if knee := result.FirstKneeNs(); knee > 0 {
+		fmt.Printf("\nFirst knee: %s\n", latency.Format(knee))
+	}
+	printBestScore(sr.Scores)
+	fmt.Printf("Sensitivity report: %s\n", filepath.Join(outDir, "sensitivity.md"))
+	return nil
+}
+
+func cmdExperiment(args []string) {
+	if err := runExperiment(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runExperiment runs a canned study. There is one so far: queue-latency,
+// the latency sweep traced in queue position and fill probability
+func runExperiment(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "--") {
+		return errors.New("experiment name required (queue-latency)")
+	}
+	name, args := args[0], args[1:]
+	if name != "queue-latency" {
+		return fmt.Errorf("unknown experiment %q (queue-latency)", name)
+	}
+	scenarioName := "calm"
+	seed := int64(42)
+	from, to := "1ms", "200ms"
+	steps := 9
+	linear := false
+	duration := ""
+	outDir := filepath.Join(defaultRunsDir, "experiments", name)
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--scenario":
+			i++
+			if i < len(args) {
+				scenarioName = args[i]
+			}
+		case "--seed":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &seed)
+			}
+		case "--from":
+			i++
+			if i < len(args) {
+				from = args[i]
+			}
+		case "--to":
+			i++
+			if i < len(args) {
+				to = args[i]
+			}
+		case "--steps":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &steps)
+			}
+		case "--linear":
+			linear = true
+		case "--duration":
+			i++
+			if i < len(args) {
+				duration = args[i]
+			}
+		case "--out":
+			i++
+			if i < len(args) {
+				outDir = args[i]
+			}
+		default:
+			return fmt.Errorf("unknown experiment option %q", args[i])
+		}
+	}
+	cfg := scenario.GetConfig(scenarioName, seed)
+	if cfg == nil {
+		return fmt.Errorf("unknown scenario '%s'", scenarioName)
+	}
+	if duration != "" {
+		d, err := scenario.ParseDuration(duration)
+		if err != nil {
+			return fmt.Errorf("duration: %w", err)
+		}
+		if d == 0 {
+			return errors.New("duration must be positive")
+		}
+		cfg.Duration = d
+	}
+	fromNs, err := scenario.ParseDuration(from)
+	if err != nil {
+		return fmt.Errorf("--from: %w", err)
+	}
+	toNs, err := scenario.ParseDuration(to)
+	if err != nil {
+		return fmt.Errorf("--to: %w", err)
+	}
+	latencies, err := sensitivity.Latencies(fromNs, toNs, steps, linear)
+	if err != nil {
+		return err
+	}
+
+	result, err := sensitivity.SweepMetrics(cfg, latencies, sensitivity.QueueMetrics, outDir, func(lat int64) {
+		fmt.Printf("Slow trader at %s...\n", latency.Format(lat))
+	})
+	if err != nil {
+		return err
+	}
+	if err := report.NewQueueExperimentReport(result, outDir).Generate(); err != nil {
+		return err
+	}
+	fmt.Printf("Queue position vs latency report: %s\n", filepath.Join(outDir, "queue_latency.md"))
+	return nil
+}
+
+// runSensitivityGrid crosses the latency sweep with one market parameter
+func runSensitivityGrid(cfg *scenario.Config, latencies []int64, vs string, steps int, weights scoring.Weights, outDir string) error {
+	bounds, err := robustness.ParseBounds(vs)
+	if err != nil {
+		return fmt.Errorf("--vs: %w", err)
+	}
+	if len(bounds) != 1 {
+		return fmt.Errorf("--vs takes one parameter, got %d", len(bounds))
+	}
+	values, err := robustness.GridValues(bounds[0], steps)
+	if err != nil {
+		return fmt.Errorf("--vs: %w", err)
+	}
+	p, _ := robustness.LookupParam(bounds[0].Param)
+	result, err := robustness.Grid(cfg, p.Name, values, latencies, outDir, func(v float64, lat int64) {
+		fmt.Printf("%s %s, slow trader at %s...\n", p.Name, p.Format(v), latency.Format(lat))
+	})
+	if err != nil {
+		return err
+	}
+	var scored []scoring.Run
+	for i, v := range result.Values {
+		for _, cell := range result.Cells[i] {
+			if cell.Gaps == nil {
+				continue
+			}
+			run, err := scoring.NewRun(fmt.Sprintf("%s %s, slow %s", p.Name, p.Format(v), latency.Format(cell.LatencyNs)), cell.RunDir, cell.Gaps)
+			if err != nil {
+				return err
+			}
+			scored = append(scored, run)
+		}
+	}
+	gr := report.NewGridReport(result, outDir)
+	gr.Scores = scoring.Rank(scored, weights)
+	if err := gr.Generate(); err != nil {
+		return err
+	}
+	printBestScore(gr.Scores)
+	fmt.Printf("Sensitivity grid report: %s\n", filepath.Join(outDir, "grid.md"))
+	return nil
+}
+
+func cmdBaseline(args []string) {
+	if err := runBaseline(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runBaseline(args []string) error {
+	action := "show"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "--") {
+		action, args = args[0], args[1:]
+	}
+	runDir := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--run-id":
+			i++
+			if i < len(args) {
+				runDir = filepath.Join(defaultRunsDir, args[i])
+			}
+		case "--run-dir":
+			i++
+			if i < len(args) {
+				runDir = args[i]
+			}
+		case "--last-run":
+			runDir = ""
+		default:
+			return fmt.Errorf("unknown baseline option %q", args[i])
+		}
+	}
+
+	switch action {
+	case "set":
+		if runDir == "" {
+			data, err := os.ReadFile(filepath.Join(defaultRunsDir, "last-run"))
+			if err != nil {
+				return errors.New("no last run found; run a simulation first")
+			}
+			runDir = string(data)
+		}
+		return setBaseline(runDir)
+	case "clear":
+		man, err := manifest.Load(defaultRunsDir)
+		if err != nil {
+			return err
+		}
+		if man.Baseline == nil {
+			fmt.Println("No baseline set")
+			return nil
+		}
+		man.Baseline = nil
+		if err := man.Save(defaultRunsDir); err != nil {
+			return err
+		}
+		fmt.Println("Baseline cleared")
+		return nil
+	case "show":
+		man, err := manifest.Load(defaultRunsDir)
+		if err != nil {
+			return err
+		}
+		b := man.Baseline
+		if b == nil {
+			fmt.Println("No baseline set")
+			return nil
+		}
+		fmt.Printf("Baseline: %s (%s, seed %d)\n", b.RunDir, b.Scenario, b.Seed)
+		if b.ConfigHash != "" {
+			fmt.Printf("  Config hash: %s\n", b.ConfigHash[:12])
+		}
+		fmt.Printf("  Fill rate:   fast %.1f%%, slow %.1f%%\n", b.Fast().FillRate*100, b.Slow().FillRate*100)
+		fmt.Printf("  Net PnL:     fast %.2f, slow %.2f\n", b.Fast().NetPnL, b.Slow().NetPnL)
+		return nil
+	}
+	return fmt.Errorf("unknown baseline action %q (want set, clear or show)", action)
+}
+
+// setBaseline marks the finished run in runDir as the manifest's baseline
+func setBaseline(runDir string) error {
+	man, err := manifest.Load(defaultRunsDir)
+	if err != nil {
+		return err
+	}
+	b, err := manifest.Mark(runDir)
+	if err != nil {
+		return err
+	}
+	man.Baseline = b
+	if err := man.Save(defaultRunsDir); err != nil {
+		return err
+	}
+	fmt.Printf("Baseline set to %s in %s\n", runDir, filepath.Join(defaultRunsDir, manifest.File))
+	return nil
+}
+
+func cmdUpgrade(args []string) {
+	if err := runUpgrade(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runUpgrade rewrites the config.json and metrics.json of stored runs in
+// the current schema, keeping the originals as .bak files. report and
+// baseline read old runs without it; it is for tools outside fairsim
+func runUpgrade(args []string) error {
+	var dirs []string
+	all, dryRun := false, false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--run-id":
+			i++
+			if i < len(args) {
+				dirs = append(dirs, filepath.Join(defaultRunsDir, args[i]))
+			}
+		case "--run-dir":
+			i++
+			if i < len(args) {
+				dirs = append(dirs, args[i])
+			}
+		case "--all":
+			all = true
+		case "--dry-run":
+			dryRun = true
+		default:
+			return fmt.Errorf("unknown upgrade option %q", args[i])
+		}
+	}
+	if all {
+		runs, err := compat.Runs(defaultRunsDir)
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, runs...)
+	}
+	if len(dirs) == 0 {
+		return fmt.Errorf("--run-id, --run-dir or --all required")
+	}
+
+	upgraded := 0
+	for _, dir := range dirs {
+		done, err := compat.Upgrade(dir, dryRun)
+		if err != nil {
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+		if len(done) == 0 {
+			continue
+		}
+		upgraded++
+		fmt.Println(dir)
+		if !dryRun {
+			resealRun(dir)
+		}
+		for _, file := range []string{compat.ConfigFile, compat.MetricsFile} {
+			for _, name := range done[file] {
+				fmt.Printf("  %s: %s\n", file, name)
+			}
+		}
+	}
+	verb := "Upgraded"
+	if dryRun {
+		verb = "Would upgrade"
+	}
+	fmt.Printf("%s %d of %d runs\n", verb, upgraded, len(dirs))
+	return nil
+}
+
+func cmdVerify(args []string) {
+	ok, err := runVerify(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// runVerify checks a run's files against its artifacts.json and reports
+// whether they all match. Files written into the run after it finished,
+// such as a rebuilt report, are listed but do not fail it
+func runVerify(args []string) (bool, error) {
+	runDir := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--run-id":
+			i++
+			if i < len(args) {
+				runDir = filepath.Join(defaultRunsDir, args[i])
+			}
+		case "--run-dir":
+			i++
+			if i < len(args) {
+				runDir = args[i]
+			}
+		case "--last-run":
+			runDir = ""
+		default:
+			return false, fmt.Errorf("unknown verify option %q", args[i])
+		}
+	}
+	if runDir == "" {
+		data, err := os.ReadFile(filepath.Join(defaultRunsDir, "last-run"))
+		if err != nil {
+			return false, errors.New("no last run found; run a simulation first")
+		}
+		runDir = string(data)
+	}
+
+	m, problems, err := artifacts.Verify(runDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("%s has no %s; it was written before runs recorded one", runDir, artifacts.File)
+	}
+	if err != nil {
+		return false, err
+	}
+	failed := 0
+	for _, p := range problems {
+		if p.Failed() {
+			failed++
+		}
+	}
+	fmt.Printf("%s: %d of %d files match %s\n", runDir, len(m.Files)-failed, len(m.Files), artifacts.File)
+	for _, p := range problems {
+		line := fmt.Sprintf("  %-9s %s", p.Kind, p.Path)
+		if p.Detail != "" {
+			line += " (" + p.Detail + ")"
+		}
+		fmt.Println(line)
+	}
+	return failed == 0, nil
+}
+
+func cmdBench(args []string) {
+	if err := runBench(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runBench(args []string) error {
+	seed := int64(42)
+	iterations := 3
+	serial := false
+	scenarios := []string{"calm", "thin", "spike"}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--scenario":
+			i++
+			if i < len(args) {
+				scenarios = []string{args[i]}
+			}
+		case "--seed":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &seed)
+			}
+		case "--iterations":
+			i++
+			if i < len(args) {
+				fmt.Sscanf(args[i], "%d", &iterations)
+			}
+		case "--serial":
+			serial = true
+		}
+	}
+	if iterations < 1 {
+		return fmt.Errorf("--iterations must be at least 1")
+	}
+
+	// Artifacts are written to a scratch directory so timing includes I/O
+	// without touching runs/
+	tmpDir, err := os.MkdirTemp("", "fairsim-bench-*")
+	if err != nil {
+		return fmt.Errorf("create temp directory for bench: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fmt.Printf("  %-10s %10s %12s %12s %14s\n", "Scenario", "Events", "Mean (ms)", "Best (ms)", "Events/sec")
+	for _, name := range scenarios {
+		var total, best time.Duration
+		var events uint64
+		for i := 0; i < iterations; i++ {
+			cfg := scenario.GetConfig(name, seed)
+			if cfg == nil {
+				return fmt.Errorf("unknown scenario '%s'", name)
+			}
+			runner, err := sim.NewRunner(cfg, tmpDir)
+			if err != nil {
+				return fmt.Errorf("initialize %s: %w", name, err)
+			}
+			runner.Pipeline = !serial
+			result, err := runner.Run()
+			if err != nil {
+				return fmt.Errorf("run %s: %w", name, err)
+			}
+			total += result.Duration
+			if best == 0 || result.Duration < best {
+				best = result.Duration
+			}
+			events = result.EventCount
+		}
+		mean := total / time.Duration(iterations)
+		fmt.Printf("  %-10s %10d %12.2f %12.2f %14.0f\n", name, events,
+			float64(mean)/1e6, float64(best)/1e6, float64(events)/mean.Seconds())
+	}
+	return nil
+}
+
+func cmdGolden(args []string) {
+	if err := runGolden(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runGolden(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("golden requires a subcommand: check or update")
+	}
+	action := args[0]
+	dir := golden.DefaultDir
+	force := false
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--dir":
+			i++
+			if i < len(args) {
+				dir = args[i]
+			}
+		case "--force":
+			force = true
+		}
+	}
+
+	switch action {
+	case "check":
+		drift, err := golden.Check(dir)
+		if err != nil {
+			return err
+		}
+		for _, f := range golden.Fixtures {
+			diffs, ok := drift[f.Name]
+			if !ok {
+				fmt.Printf("  %-14s ok\n", f.Name)
+				continue
+			}
+			fmt.Printf("  %-14s DRIFT\n", f.Name)
+			for _, d := range diffs {
+				fmt.Printf("    %s\n", d)
+			}
+		}
+		if len(drift) > 0 {
+			return fmt.Errorf("%d of %d golden fixtures drifted (version %d)", len(drift), len(golden.Fixtures), golden.Version)
+		}
+		return nil
+	case "update":
+		changed, err := golden.Update(dir, force)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Golden snapshots written to %s (version %d)\n", dir, golden.Version)
+		for _, name := range changed {
+			fmt.Printf("  updated %s\n", name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown golden subcommand '%s' (check, update)", action)
+	}
+}
+
+// countTies totals the same-timestamp ties across trader pairs
+func countTies(pairs []engine.TiePair) (ties, contested int) {
+	for _, p := range pairs {
+		ties += p.Ties
+		contested += p.Contested
+	}
+	return ties, contested
+}
+
+func auditSummary(violations int) string {
+	if violations == 0 {
+		return "ok"
+	}
+	return fmt.Sprintf("%d violations (see audit.json)", violations)
+}
+
+func cmdAudit(args []string) {
+	if err := runAudit(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runAudit(args []string) error {
+	runDir := ""
+	logPath := ""
+	trailPath := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--trail":
+			i++
+			if i < len(args) {
+				trailPath = args[i]
+			}
+		case "--run-id":
+			i++
+			if i < len(args) {
+				runDir = filepath.Join(defaultRunsDir, args[i])
+			}
+		case "--run-dir":
+			i++
+			if i < len(args) {
+				runDir = args[i]
+			}
+		case "--log":
+			i++
+			if i < len(args) {
+				logPath = args[i]
+			}
+		}
+	}
+	if logPath == "" && runDir != "" {
+		logPath = filepath.Join(runDir, "events.jsonl")
+	}
+	if logPath == "" {
+		return fmt.Errorf("--run-id, --run-dir, or --log required")
+	}
+
+	rpt, err := audit.Log(logPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Audited %s\n", logPath)
+	fmt.Printf("  Events: %d, Orders: %d, Trades: %d, Traded qty: %d\n",
+		rpt.Events, rpt.Orders, rpt.Trades, rpt.TradedQty)
+	for _, v := range rpt.Violations {
+		fmt.Printf("  line %d [%s] %s\n", v.Line, v.Kind, v.Message)
+	}
+	if trailPath != "" {
+		trail, err := audit.TrailLog(logPath, logScale(logPath))
+		if err != nil {
+			return err
+		}
+		if err := trail.WriteFile(trailPath); err != nil {
+			return err
+		}
+		fmt.Printf("  Audit trail: %s\n", trailPath)
+	}
+	if !rpt.OK {
+		return fmt.Errorf("%d accounting violations", rpt.ViolationCount)
+	}
+	fmt.Println("  No accounting violations")
+	return nil
+}
+
+// logScale is the price scale of the run that wrote logPath, so prices are
+// formatted in it when its config is at hand
+func logScale(logPath string) domain.Scale {
+	if data, err := os.ReadFile(filepath.Join(filepath.Dir(logPath), "config.json")); err == nil {
+		var cfg scenario.Config
+		if json.Unmarshal(data, &cfg) == nil {
+			return cfg.Scale()
+		}
+	}
+	return domain.DefaultScale
+}
+
+func cmdDataset(args []string) {
+	if err := runDataset(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runDataset(args []string) error {
+	runDir := ""
+	logPath := ""
+	outPath := ""
+	horizon := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--run-id":
+			i++
+			if i < len(args) {
+				runDir = filepath.Join(defaultRunsDir, args[i])
+			}
+		case "--run-dir":
+			i++
+			if i < len(args) {
+				runDir = args[i]
+			}
+		case "--log":
+			i++
+			if i < len(args) {
+				logPath = args[i]
+			}
+		case "--horizon":
+			i++
+			if i < len(args) {
+				horizon = args[i]
+			}
+		case "--out":
+			i++
+			if i < len(args) {
+				outPath = args[i]
+			}
+		default:
+			return fmt.Errorf("unknown dataset option %q", args[i])
+		}
+	}
+	if logPath == "" && runDir != "" {
+		logPath = filepath.Join(runDir, "events.jsonl")
+	}
+	if logPath == "" {
+		return fmt.Errorf("--run-id, --run-dir, or --log required")
+	}
+	if outPath == "" {
+		outPath = filepath.Join(filepath.Dir(logPath), "features.csv")
+	}
+	horizonNs := dataset.DefaultHorizonNs
+	if horizon != "" {
+		var err error
+		if horizonNs, err = scenario.ParseDuration(horizon); err != nil {
+			return fmt.Errorf("horizon: %w", err)
+		}
+		if horizonNs == 0 {
+			return errors.New("horizon must be positive")
+		}
+	}
+
+	b, err := dataset.BuildLog(logPath, logScale(logPath), horizonNs)
+	if err != nil {
+		return err
+	}
+	if err := b.WriteFile(outPath); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d orders to %s\n", len(b.Rows()), outPath)
+	return nil
+}
+
+func cmdHeatmap(args []string) {
+	if err := runHeatmap(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runHeatmap(args []string) error {
+	runDir := ""
+	logPath := ""
+	outPath := ""
+	interval := ""
+	format := "csv"
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--run-id":
+			i++
+			if i < len(args) {
+				runDir = filepath.Join(defaultRunsDir, args[i])
+			}
+		case "--run-dir":
+			i++
+			if i < len(args) {
+				runDir = args[i]
+			}
+		case "--log":
+			i++
+			if i < len(args) {
+				logPath = args[i]
+			}
+		case "--interval":
+			i++
+			if i < len(args) {
+				interval = args[i]
+			}
+		case "--format":
+			i++
+			if i < len(args) {
+				format = args[i]
+			}
+		case "--out":
+			i++
+			if i < len(args) {
+				outPath = args[i]
+			}
+		default:
+			return fmt.Errorf("unknown heatmap option %q", args[i])
+		}
+	}
+	if logPath == "" && runDir != "" {
+		logPath = filepath.Join(runDir, "events.jsonl")
+	}
+	if logPath == "" {
+		return fmt.Errorf("--run-id, --run-dir, or --log required")
+	}
+	if format != "csv" && format != "npy" {
+		return fmt.Errorf("unknown format %q (csv, npy)", format)
+	}
+	if outPath == "" {
+		outPath = filepath.Join(filepath.Dir(logPath), "heatmap."+format)
+	} else if (format == "npy") != strings.HasSuffix(outPath, ".npy") {
+		return fmt.Errorf("--format %s does not match --out %s; npy output ends in .npy", format, outPath)
+	}
+	intervalNs := heatmap.DefaultIntervalNs
+	if interval != "" {
+		var err error
+		if intervalNs, err = scenario.ParseDuration(interval); err != nil {
+			return fmt.Errorf("interval: %w", err)
+		}
+		if intervalNs == 0 {
+			return errors.New("interval must be positive")
+		}
+	}
+
+	g, err := heatmap.BuildLog(logPath, intervalNs)
+	if err != nil {
+		return err
+	}
+	if err := g.WriteFile(outPath, logScale(logPath)); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d samples x %d prices to %s\n", len(g.Times), len(g.Prices), outPath)
+	return nil
+}
+
+func cmdClip(args []string) {
+	if err := runClip(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runClip(args []string) error {
+	runDir := ""
+	logPath := ""
+	outPath := ""
+	around := ""
+	window := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--run-id":
+			i++
+			if i < len(args) {
+				runDir = filepath.Join(defaultRunsDir, args[i])
+			}
+		case "--run-dir":
+			i++
+			if i < len(args) {
+				runDir = args[i]
+			}
+		case "--log":
+			i++
+			if i < len(args) {
+				logPath = args[i]
+			}
+		case "--around":
+			i++
+			if i < len(args) {
+				around = args[i]
+			}
+		case "--window":
+			i++
+			if i < len(args) {
+				window = args[i]
+			}
+		case "--out":
+			i++
+			if i < len(args) {
+				outPath = args[i]
+			}
+		default:
+			return fmt.Errorf("unknown clip option %q", args[i])
+		}
+	}
+	if logPath == "" && runDir != "" {
+		logPath = filepath.Join(runDir, "events.jsonl")
+	}
+	if logPath == "" {
+		return fmt.Errorf("--run-id, --run-dir, or --log required")
+	}
+	if around == "" {
+		return errors.New("--around required")
+	}
+	// Timestamps copied from a log are bare nanoseconds
+	aroundNs, err := strconv.ParseInt(around, 10, 64)
+	if err != nil {
+		if aroundNs, err = scenario.ParseDuration(around); err != nil {
+			return fmt.Errorf("around: %w", err)
+		}
+	}
+	if aroundNs < 0 {
+		return errors.New("around must not be negative")
+	}
+	windowNs := clip.DefaultWindowNs
+	if window != "" {
+		if windowNs, err = scenario.ParseDuration(window); err != nil {
+			return fmt.Errorf("window: %w", err)
+		}
+		if windowNs == 0 {
+			return errors.New("window must be positive")
+		}
+	}
+	if outPath == "" {
+		outPath = filepath.Join(filepath.Dir(logPath), fmt.Sprintf("clip_%d.jsonl", aroundNs))
+	}
+	if abs, _ := filepath.Abs(outPath); abs != "" {
+		if src, _ := filepath.Abs(logPath); abs == src {
+			return errors.New("--out would overwrite the log being clipped")
+		}
+	}
+
+	sum, err := clip.Log(logPath, outPath, aroundNs, windowNs)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d events from t=%.3fms to t=%.3fms, opening with %d resting orders, to %s\n",
+		sum.Events, float64(sum.FromNs)/1e6, float64(sum.ToNs)/1e6, sum.Resting, outPath)
+	return nil
+}
+
+func cmdBook(args []string) {
+	if err := runBook(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runBook(args []string) error {
+	runDir := ""
+	logPath := ""
+	at := ""
+	depth := 10
+	intervalNs := bookview.DefaultIntervalNs
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--run-id":
+			i++
+			if i < len(args) {
+				runDir = filepath.Join(defaultRunsDir, args[i])
+			}
+		case "--run-dir":
+			i++
+			if i < len(args) {
+				runDir = args[i]
+			}
+		case "--log":
+			i++
+			if i < len(args) {
+				logPath = args[i]
+			}
+		case "--at":
+			i++
+			if i < len(args) {
+				at = args[i]
+			}
+		case "--depth":
+			i++
+			if i < len(args) {
+				n, err := strconv.Atoi(args[i])
+				if err != nil || n < 0 {
+					return fmt.Errorf("depth must be a count of levels, 0 for all")
+				}
+				depth = n
+			}
+		case "--checkpoint":
+			i++
+			if i < len(args) {
+				ns, err := scenario.ParseDuration(args[i])
+				if err != nil {
+					return fmt.Errorf("checkpoint: %w", err)
+				}
+				if ns <= 0 {
+					return errors.New("checkpoint interval must be positive")
+				}
+				intervalNs = ns
+			}
+		default:
+			return fmt.Errorf("unknown book option %q", args[i])
+		}
+	}
+	if logPath == "" && runDir != "" {
+		logPath = filepath.Join(runDir, "events.jsonl")
+	}
+	if logPath == "" {
+		return fmt.Errorf("--run-id, --run-dir, or --log required")
+	}
+	if at == "" {
+		return errors.New("--at required")
+	}
+	// Timestamps copied from a log are bare nanoseconds
+	atNs, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		if atNs, err = scenario.ParseDuration(at); err != nil {
+			return fmt.Errorf("at: %w", err)
+		}
+	}
+
+	snap, err := bookview.At(logPath, atNs, intervalNs)
+	if err != nil {
+		return err
+	}
+	return snap.Render(os.Stdout, logScale(logPath), depth)
+}
+
+func cmdViews(args []string) {
+	if err := runViews(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runViews(args []string) error {
+	runDir := ""
+	only := ""
+	outDir := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--run-id":
+			i++
+			if i < len(args) {
+				runDir = filepath.Join(defaultRunsDir, args[i])
+			}
+		case "--run-dir":
+			i++
+			if i < len(args) {
+				runDir = args[i]
+			}
+		case "--trader":
+			i++
+			if i < len(args) {
+				only = args[i]
+			}
+		case "--out":
+			i++
+			if i < len(args) {
+				outDir = args[i]
+			}
+		default:
+			return fmt.Errorf("unknown views option %q", args[i])
+		}
+	}
+	if runDir == "" {
+		return fmt.Errorf("--run-id or --run-dir required")
+	}
+	if outDir == "" {
+		outDir = runDir
+	}
+	cfg, err := compat.ReadConfig(runDir)
+	if err != nil {
+		return fmt.Errorf("could not read config: %w", err)
+	}
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+
+	logPath := filepath.Join(runDir, "events.jsonl")
+	unexplained := 0
+	found := false
+	for _, tc := range []scenario.TraderConfig{cfg.FastTrader, cfg.SlowTrader} {
+		if only != "" && tc.ID != only {
+			continue
+		}
+		found = true
+		outPath := filepath.Join(outDir, "view_"+tc.ID+".jsonl")
+		sum, err := traderview.Write(logPath, outPath, traderview.FromConfig(cfg, tc))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s's view to %s\n", tc.ID, outPath)
+		fmt.Printf("  Events: %d, Orders: %d, Fills: %d, Signals: %d, Re-quotes: %d, Market data: %d\n",
+			sum.Events, sum.Orders, sum.Fills, sum.Signals, sum.ReQuotes, sum.MarketData)
+		for _, u := range sum.Unexplained {
+			fmt.Printf("  order %d decided at t=%d with nothing in view at that time\n", u.OrderID, u.DecisionTime)
+		}
+		unexplained += len(sum.Unexplained)
+	}
+	if !found {
+		return fmt.Errorf("no trader %q in this run", only)
+	}
+	if unexplained > 0 {
+		return fmt.Errorf("%d decisions made on information outside the trader's view", unexplained)
+	}
+	fmt.Println("  Every decision had a trigger in its trader's view")
+	return nil
+}
+
+func cmdVerifyRepro(args []string) {
+	if err := runVerifyRepro(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runVerifyRepro(args []string) error {
+	bundlePath := ""
+	runDir := ""
+	create := false
+	includeLog := true
+	keep := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--bundle":
+			i++
+			if i < len(args) {
+				bundlePath = args[i]
+			}
+		case "--create":
+			create = true
+		case "--run-id":
+			i++
+			if i < len(args) {
+				runDir = filepath.Join(defaultRunsDir, args[i])
+			}
+		case "--run-dir":
+			i++
+			if i < len(args) {
+				runDir = args[i]
+			}
+		case "--no-log":
+			includeLog = false
+		case "--keep":
+			keep = true
+		}
+	}
+	if bundlePath == "" {
+		return fmt.Errorf("--bundle required")
+	}
+
+	if create {
+		if runDir == "" {
+			return fmt.Errorf("--run-id or --run-dir required with --create")
+		}
+		b, err := repro.FromRunDir(runDir, includeLog)
+		if err != nil {
+			return err
+		}
+		if err := b.Write(bundlePath); err != nil {
+			return err
+		}
+		fmt.Printf("Bundled %s -> %s\n", runDir, bundlePath)
+		fmt.Printf("  Platform: %s\n", b.Platform)
+		fmt.Printf("  Log hash: %s (%d records, %d trades)\n", b.LogHash, b.LogRecords, b.TradeCount)
+		return nil
+	}
+
+	b, err := repro.Read(bundlePath)
+	if err != nil {
+		return err
+	}
+	workDir, err := os.MkdirTemp("", "fairsim-repro-")
+	if err != nil {
+		return fmt.Errorf("create work dir: %w", err)
+	}
+	if !keep {
+		defer os.RemoveAll(workDir)
+	}
+
+	res, err := repro.Verify(b, workDir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Bundle:  %s (%s, seed %d)\n", bundlePath, b.Config.Name, b.Config.Seed)
+	fmt.Printf("  Bundled on: %s\n", res.Bundled)
+	fmt.Printf("  Re-run on:  %s\n", res.Current)
+	fmt.Printf("  Expected:   %s (%d records, %d trades)\n", res.WantHash, res.WantRecords, res.WantTrades)
+	fmt.Printf("  Got:        %s (%d records, %d trades)\n", res.GotHash, res.GotRecords, res.GotTrades)
+	if keep {
+		fmt.Printf("  Output:     %s\n", workDir)
+	}
+	if res.Match {
+		fmt.Println("  Reproduced: hashes match")
+		return nil
+	}
+
+	if res.Diagnosis != nil {
+		printDiagnosis(res.Diagnosis, b.Config.Scale())
+	} else if len(b.Log) == 0 {
+		fmt.Println("  Bundle has no event log; cannot locate the divergent event")
+	} else {
+		fmt.Println("  Event log matches the bundled log; the bundle's recorded hash is wrong")
+	}
+	return fmt.Errorf("run did not reproduce")
+}
+
+// printDiagnosis reports the first divergent record and the book state
+// before the event that produced it
+func printDiagnosis(d *repro.Diagnosis, scale domain.Scale) {
+	fmt.Printf("  First divergence at line %d (seq %d, t=%.3fms, %s)\n",
+		d.Line, d.SeqNo, float64(d.Timestamp)/1e6, d.Type)
+	if len(d.Fields) > 0 {
+		fmt.Printf("    Fields:   %s\n", strings.Join(d.Fields, ", "))
+	}
+	fmt.Printf("    Expected: %s\n", orEOF(d.Want))
+	fmt.Printf("    Got:      %s\n", orEOF(d.Got))
+	if d.CauseLine == 0 {
+		return
+	}
+	if d.CauseLine != d.Line {
+		fmt.Printf("  Produced by line %d: %s\n", d.CauseLine, d.Cause)
+	}
+	fmt.Printf("  Book before line %d:\n", d.CauseLine)
+	for i := len(d.Asks) - 1; i >= 0; i-- {
+		printLevel("ASK", d.Asks[i], scale)
+	}
+	if len(d.Asks) == 0 {
+		fmt.Println("    ASK (empty)")
+	}
+	if len(d.Bids) == 0 {
+		fmt.Println("    BID (empty)")
+	}
+	for _, l := range d.Bids {
+		printLevel("BID", l, scale)
+	}
+}
+
+func printLevel(side string, l repro.LevelSnapshot, scale domain.Scale) {
+	orders := make([]string, len(l.Orders))
+	for i, o := range l.Orders {
+		orders[i] = fmt.Sprintf("%d %s x%d", o.ID, o.TraderID, o.Remaining)
+	}
+	fmt.Printf("    %s %s x %-5d [%s]\n", side, scale.Format(l.Price), l.Qty, strings.Join(orders, ", "))
+}
+
+func orEOF(line string) string {
+	if line == "" {
+		return "<end of log>"
+	}
+	return line
+}
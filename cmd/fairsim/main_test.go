@@ -1,12 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/akshitanchan/execution-fairness-simulator/internal/daemon"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
@@ -26,7 +29,7 @@ func TestComputeMetricsFromEventLog(t *testing.T) {
 		{
 			Timestamp: 10,
 			Type:      domain.EventOrderAccepted,
-			Order: &domain.Order{
+			Payload: &domain.Order{
 				ID:           101,
 				TraderID:     "fast",
 				Side:         domain.Buy,
@@ -41,7 +44,7 @@ func TestComputeMetricsFromEventLog(t *testing.T) {
 		{
 			Timestamp: 20,
 			Type:      domain.EventTradeExecuted,
-			Trade: &domain.Trade{
+			Payload: &domain.Trade{
 				ID:          1,
 				BuyOrderID:  101,
 				SellOrderID: 5001,
@@ -149,6 +152,132 @@ func TestRunReplayDetectsHashMismatch(t *testing.T) {
 	}
 }
 
+// TestRunReplayLogOnly replays a run whose config no longer regenerates
+// its log: log-only replay must not regenerate, and must rebuild the
+// book and the report from the log
+func TestRunReplayLogOnly(t *testing.T) {
+	cfg := scenario.DefaultCalm(321)
+	cfg.Duration = latency.MsToNs(200)
+	cfg.Scenario.SignalIntervalNs = latency.MsToNs(40)
+
+	runner, err := sim.NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	result, err := runner.Run()
+	if err != nil {
+		t.Fatalf("run simulation: %v", err)
+	}
+	cfg.Seed++
+	data, _ := json.Marshal(cfg)
+	if err := os.WriteFile(filepath.Join(result.OutputDir, "config.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := captureStdout(t, func() {
+		if err := runReplay([]string{"--run-dir", result.OutputDir, "--log-only"}); err != nil {
+			t.Fatalf("run replay: %v", err)
+		}
+	})
+	if strings.Contains(output, "Deterministic replay log") || !strings.Contains(output, "Book at the end of the log") {
+		t.Fatalf("unexpected log-only replay output:\n%s", output)
+	}
+	rebuilt, err := os.ReadFile(filepath.Join(result.OutputDir, rebuiltDir, "report.md"))
+	if err != nil || !strings.Contains(string(rebuilt), "# Execution Fairness Report") {
+		t.Fatalf("rebuilt report: %v", err)
+	}
+}
+
+func TestRunReplayRejectsInvalidLog(t *testing.T) {
+	cfg := scenario.DefaultCalm(5)
+	cfg.Duration = latency.MsToNs(200)
+
+	runner, err := sim.NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	result, err := runner.Run()
+	if err != nil {
+		t.Fatalf("run simulation: %v", err)
+	}
+
+	// Swap two lines with different timestamps so time runs backwards
+	data, err := os.ReadFile(result.LogPath)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	last := len(lines) - 1
+	lines[1], lines[last] = lines[last], lines[1]
+	badLog := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := os.WriteFile(badLog, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+
+	captureStdout(t, func() {
+		err = runReplay([]string{"--run-dir", result.OutputDir, "--log", badLog})
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid event log") {
+		t.Fatalf("expected invalid event log error, got %v", err)
+	}
+}
+
+func TestRunAuditFlagsForeignCancel(t *testing.T) {
+	cfg := scenario.DefaultCalm(5)
+	cfg.Duration = latency.MsToNs(200)
+
+	runner, err := sim.NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	result, err := runner.Run()
+	if err != nil {
+		t.Fatalf("run simulation: %v", err)
+	}
+	if result.AuditViolations != 0 {
+		t.Fatalf("clean run has %d audit violations", result.AuditViolations)
+	}
+	for _, name := range []string{"audit.json", "audit_trail.csv"} {
+		if _, err := os.Stat(filepath.Join(result.OutputDir, name)); err != nil {
+			t.Fatalf("%s not written: %v", name, err)
+		}
+	}
+
+	// Make a cancel come from a trader that never placed the target order
+	data, err := os.ReadFile(result.LogPath)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	// Rewrite the sender on the first cancel's ORDER_ACCEPTED and ORDER_CANCELED lines
+	bad := strings.Replace(string(data), `"trader_id":"background","side":"SELL","type":"CANCEL"`,
+		`"trader_id":"intruder","side":"SELL","type":"CANCEL"`, 2)
+	if bad == string(data) {
+		t.Fatal("no background cancel to tamper with")
+	}
+	badLog := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := os.WriteFile(badLog, []byte(bad), 0644); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+
+	output := captureStdout(t, func() {
+		err = runAudit([]string{"--log", badLog})
+	})
+	if err == nil || !strings.Contains(output, "[cancel_owner]") {
+		t.Fatalf("expected cancel_owner violation, got err %v output:\n%s", err, output)
+	}
+}
+
+func TestRunBenchReportsThroughput(t *testing.T) {
+	output := captureStdout(t, func() {
+		if err := runBench([]string{"--scenario", "thin", "--iterations", "1"}); err != nil {
+			t.Fatalf("run bench: %v", err)
+		}
+	})
+	if !strings.Contains(output, "Events/sec") || !strings.Contains(output, "thin") {
+		t.Fatalf("expected bench table for thin, got:\n%s", output)
+	}
+}
+
 func captureStdout(t *testing.T, fn func()) string {
 	t.Helper()
 	oldStdout := os.Stdout
@@ -176,3 +305,222 @@ func captureStdout(t *testing.T, fn func()) string {
 	}
 	return string(out)
 }
+
+func TestRunTutorialWritesWalkthrough(t *testing.T) {
+	outDir := t.TempDir()
+	captureStdout(t, func() {
+		if err := runTutorial([]string{"--duration", "300ms", "--out", outDir}); err != nil {
+			t.Fatalf("run tutorial: %v", err)
+		}
+	})
+
+	data, err := os.ReadFile(filepath.Join(outDir, "tutorial.md"))
+	if err != nil {
+		t.Fatalf("read tutorial: %v", err)
+	}
+	text := string(data)
+	for _, want := range []string{
+		"## Step 1", "slow trader at 5 ms", "slow trader at 100 ms",
+		"**Fill Rate (%)**", "**Avg Time-to-Fill (ms)**", "**Adverse Selection (bps)**", "## Recap",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("tutorial missing %q", want)
+		}
+	}
+	// each metric is explained once, when it is introduced
+	if n := strings.Count(text, "**Fill Rate (%)**"); n != 1 {
+		t.Errorf("fill rate explained %d times", n)
+	}
+	for _, lat := range tutorialLatencies {
+		if _, err := os.Stat(filepath.Join(outDir, "slow_"+lat, "calm_seed42", "report.md")); err != nil {
+			t.Errorf("step at %s: %v", lat, err)
+		}
+	}
+}
+
+func TestRunMechanismsRanksEachVenue(t *testing.T) {
+	outDir := t.TempDir()
+	captureStdout(t, func() {
+		if err := runMechanisms([]string{"--scenario", "spike", "--duration", "500ms", "--out", outDir}); err != nil {
+			t.Fatalf("run mechanisms: %v", err)
+		}
+	})
+
+	data, err := os.ReadFile(filepath.Join(outDir, "mechanisms.json"))
+	if err != nil {
+		t.Fatalf("read matrix: %v", err)
+	}
+	var rows []struct {
+		Mechanism   string         `json:"mechanism"`
+		Ranks       map[string]int `json:"ranks"`
+		OverallRank int            `json:"overall_rank"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		t.Fatal(err)
+	}
+	mechs := scenario.Mechanisms()
+	if len(rows) != len(mechs) {
+		t.Fatalf("matrix has %d rows, want %d", len(rows), len(mechs))
+	}
+	seen := make(map[int]bool)
+	for i, row := range rows {
+		if row.Mechanism != mechs[i].Name {
+			t.Errorf("row %d is %s, want %s", i, row.Mechanism, mechs[i].Name)
+		}
+		if len(row.Ranks) == 0 || seen[row.OverallRank] || row.OverallRank < 1 || row.OverallRank > len(rows) {
+			t.Errorf("%s: ranks %v, overall %d", row.Mechanism, row.Ranks, row.OverallRank)
+		}
+		seen[row.OverallRank] = true
+		if _, err := os.Stat(filepath.Join(outDir, row.Mechanism, "spike_seed42", "events.jsonl")); err != nil {
+			t.Errorf("%s: %v", row.Mechanism, err)
+		}
+	}
+	if md, err := os.ReadFile(filepath.Join(outDir, "mechanisms.md")); err != nil || !strings.Contains(string(md), "## Rankings") {
+		t.Errorf("mechanisms.md missing rankings: %v", err)
+	}
+}
+
+func TestRunSensitivityWritesCurves(t *testing.T) {
+	outDir := t.TempDir()
+	captureStdout(t, func() {
+		err := runSensitivity([]string{"--scenario", "thin", "--duration", "500ms", "--from", "1ms", "--to", "100ms",
+			"--steps", "3", "--out", outDir})
+		if err != nil {
+			t.Fatalf("run sensitivity: %v", err)
+		}
+	})
+	for _, name := range []string{"sensitivity.md", "sensitivity.json", "sensitivity.csv"} {
+		if _, err := os.Stat(filepath.Join(outDir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "sensitivity.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header plus one row per metric and latency
+	if n := strings.Count(string(data), "\n"); n != 1+5*3 {
+		t.Errorf("sensitivity.csv has %d lines", n)
+	}
+	if err := runSensitivity([]string{"--from", "10ms", "--to", "5ms"}); err == nil {
+		t.Error("inverted range accepted")
+	}
+}
+
+func TestRunExperimentQueueLatency(t *testing.T) {
+	outDir := t.TempDir()
+	captureStdout(t, func() {
+		err := runExperiment([]string{"queue-latency", "--scenario", "thin", "--duration", "500ms", "--from", "1ms", "--to", "100ms",
+			"--steps", "3", "--out", outDir})
+		if err != nil {
+			t.Fatalf("run experiment: %v", err)
+		}
+	})
+	for _, name := range []string{"queue_latency.md", "queue_latency.json", "queue_latency_queue_pos_place.svg", "queue_latency_fill_prob_pct.svg"} {
+		if _, err := os.Stat(filepath.Join(outDir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "queue_latency.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header plus one row per latency
+	if n := strings.Count(string(data), "\n"); n != 1+3 {
+		t.Errorf("queue_latency.csv has %d lines", n)
+	}
+	if err := runExperiment([]string{"queue-depth"}); err == nil {
+		t.Error("unknown experiment accepted")
+	}
+}
+
+func TestRunRobustnessWritesStudy(t *testing.T) {
+	outDir := t.TempDir()
+	captureStdout(t, func() {
+		err := runRobustness([]string{"--scenario", "thin", "--duration", "500ms", "--runs", "3",
+			"--vary", "depth=2:8", "--vary", "order_interval=10ms:30ms", "--out", outDir})
+		if err != nil {
+			t.Fatalf("run robustness: %v", err)
+		}
+	})
+	for _, name := range []string{"robustness.md", "robustness.json", "robustness.csv"} {
+		if _, err := os.Stat(filepath.Join(outDir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "robustness.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "run,seed,depth,order_interval,") || strings.Count(string(data), "\n") != 1+3 {
+		t.Errorf("robustness.csv:\n%s", data)
+	}
+	if err := runRobustness([]string{"--vary", "depth=9:1"}); err == nil {
+		t.Error("inverted bound accepted")
+	}
+}
+
+func TestRunDaemonServesUntilStopped(t *testing.T) {
+	dir, err := os.MkdirTemp("", "fsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "d.sock")
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	captureStdout(t, func() {
+		go func() { done <- runDaemon([]string{"--socket", socket, "--runs-dir", dir, "--no-warm"}, stop) }()
+
+		var c *daemon.Client
+		for start := time.Now(); c == nil; time.Sleep(10 * time.Millisecond) {
+			if c, err = daemon.Dial(socket); err != nil && time.Since(start) > 5*time.Second {
+				t.Fatal(err)
+			}
+		}
+		resp, err := c.Run(&daemon.Request{Scenario: "calm", Duration: "200ms"})
+		c.Close()
+		if err != nil || resp.Error != "" || resp.RunID != "calm_seed42" {
+			t.Errorf("served run: %v %+v", err, resp)
+		}
+		if err := runDaemon([]string{"--socket", socket}, nil); err == nil {
+			t.Error("second daemon on a live socket started")
+		}
+		close(stop)
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket left behind: %v", err)
+	}
+}
+
+func TestRunVerifyChecksSealedRun(t *testing.T) {
+	runDir := t.TempDir()
+	os.WriteFile(filepath.Join(runDir, "events.jsonl"), []byte("{}\n"), 0644)
+	os.WriteFile(filepath.Join(runDir, "report.md"), []byte("# Report\n"), 0644)
+	if _, err := runVerify([]string{"--run-dir", runDir}); err == nil {
+		t.Fatal("verified a run with no artifacts.json")
+	}
+	sealRun(runDir)
+
+	out := captureStdout(t, func() {
+		if ok, err := runVerify([]string{"--run-dir", runDir}); err != nil || !ok {
+			t.Fatalf("sealed run: %v, %v", ok, err)
+		}
+	})
+	if !strings.Contains(out, "2 of 2 files match") {
+		t.Errorf("output %q", out)
+	}
+
+	os.WriteFile(filepath.Join(runDir, "report.md"), []byte("# Edited\n"), 0644)
+	out = captureStdout(t, func() {
+		if ok, err := runVerify([]string{"--run-dir", runDir}); err != nil || ok {
+			t.Fatalf("edited run: %v, %v", ok, err)
+		}
+	})
+	if !strings.Contains(out, "MODIFIED  report.md") {
+		t.Errorf("output %q", out)
+	}
+}
@@ -0,0 +1,156 @@
+// Package artifacts keeps a run's artifacts.json: the size and SHA-256 of
+// every file in the run directory, written once the run's last file is
+// in place. Checking a run against it finds files edited by hand, cut
+// short by a partial sync, or lost, which the log hash alone only does
+// for events.jsonl
+package artifacts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// File is the manifest's name within the run directory
+const File = "artifacts.json"
+
+// Entry is one file, by its slash-separated path within the run directory
+type Entry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest lists a run's files in path order
+type Manifest struct {
+	Files []Entry `json:"files"`
+}
+
+// Problem kinds
+const (
+	Missing  = "MISSING"  // listed but gone
+	Modified = "MODIFIED" // listed, with other contents
+	Unlisted = "UNLISTED" // present but not listed, e.g. written after the run
+)
+
+// Problem is a file that does not match the manifest
+type Problem struct {
+	Kind   string
+	Path   string
+	Detail string
+}
+
+// Failed reports whether the problem means the run's artifacts are not
+// the ones it wrote. Unlisted files are only noted
+func (p Problem) Failed() bool { return p.Kind != Unlisted }
+
+// Scan hashes every file under dir except the manifest itself
+func Scan(dir string) (*Manifest, error) {
+	m := &Manifest{Files: []Entry{}}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == File {
+			return nil
+		}
+		e, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		e.Path = rel
+		m.Files = append(m.Files, e)
+		return nil
+	})
+	return m, err
+}
+
+func hashFile(path string) (Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// Write scans dir and writes its artifacts.json
+func Write(dir string) (*Manifest, error) {
+	m, err := Scan(dir)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := json.MarshalIndent(m, "", "  ")
+	return m, os.WriteFile(filepath.Join(dir, File), data, 0644)
+}
+
+// Read reads dir's artifacts.json
+func Read(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, File))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", File, err)
+	}
+	return &m, nil
+}
+
+// Verify checks dir against its artifacts.json and returns the manifest
+// and the files that do not match it, in path order
+func Verify(dir string) (*Manifest, []Problem, error) {
+	want, err := Read(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	got, err := Scan(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	present := make(map[string]Entry, len(got.Files))
+	for _, e := range got.Files {
+		present[e.Path] = e
+	}
+	listed := make(map[string]bool, len(want.Files))
+	var problems []Problem
+	for _, w := range want.Files {
+		listed[w.Path] = true
+		g, ok := present[w.Path]
+		switch {
+		case !ok:
+			problems = append(problems, Problem{Kind: Missing, Path: w.Path})
+		case g.Size != w.Size:
+			problems = append(problems, Problem{Kind: Modified, Path: w.Path,
+				Detail: fmt.Sprintf("%d bytes, listed with %d", g.Size, w.Size)})
+		case g.SHA256 != w.SHA256:
+			problems = append(problems, Problem{Kind: Modified, Path: w.Path,
+				Detail: fmt.Sprintf("sha256 %s, listed as %s", short(g.SHA256), short(w.SHA256))})
+		}
+	}
+	for _, g := range got.Files {
+		if !listed[g.Path] {
+			problems = append(problems, Problem{Kind: Unlisted, Path: g.Path})
+		}
+	}
+	return want, problems, nil
+}
+
+func short(hash string) string {
+	return hash[:min(12, len(hash))]
+}
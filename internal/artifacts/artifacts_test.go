@@ -0,0 +1,61 @@
+package artifacts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestVerify seals a run directory, then edits, truncates, removes and
+// adds files behind the manifest's back
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"events.jsonl":     "{\"type\":\"SIM_START\"}\n",
+		"metrics.json":     `{"fast": {"fill_rate": 0.5}}`,
+		"report.md":        "# Report\n",
+		"tape.csv":         "ts,price,qty\n1,100.00,5\n",
+		"sessions/open.md": "open\n",
+	}
+	for name, data := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+	}
+	m, err := Write(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != len(files) || m.Files[0].Path != "events.jsonl" || m.Files[3].Path != "sessions/open.md" {
+		t.Fatalf("manifest %+v", m.Files)
+	}
+	if _, problems, err := Verify(dir); err != nil || len(problems) != 0 {
+		t.Fatalf("fresh run: %v, %v", problems, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "metrics.json"), []byte(`{"fast": {"fill_rate": 0.6}}`), 0644)
+	os.WriteFile(filepath.Join(dir, "tape.csv"), []byte("ts,price,qty\n"), 0644)
+	os.Remove(filepath.Join(dir, "report.md"))
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("mine"), 0644)
+	_, problems, err := Verify(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ kind, path string }{
+		{Modified, "metrics.json"}, {Missing, "report.md"}, {Modified, "tape.csv"}, {Unlisted, "notes.txt"},
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("problems %+v", problems)
+	}
+	for i, w := range want {
+		if problems[i].Kind != w.kind || problems[i].Path != w.path {
+			t.Errorf("problem %d: %+v, want %s %s", i, problems[i], w.kind, w.path)
+		}
+	}
+	if problems[3].Failed() || !problems[0].Failed() {
+		t.Error("only unlisted files should pass")
+	}
+
+	if _, _, err := Verify(t.TempDir()); err == nil {
+		t.Error("verified a run with no manifest")
+	}
+}
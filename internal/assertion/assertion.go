@@ -0,0 +1,212 @@
+// Package assertion implements the small rule language scenarios use to
+// declare runtime assertions, and the checker the runner feeds as events flow
+//
+// A rule is "<metric> <op> <value>", e.g.
+//
+//	spread <= 0.10          top-of-book spread in price units
+//	sides >= 1              the book never empties on both sides
+//	position.fast within 50 fast trader's net filled qty stays in [-50, 50]
+//
+// Metrics: spread, mid (price units, only defined while the book is
+// two-sided), bid_qty, ask_qty (top-of-book quantity), sides (number of
+// non-empty book sides) and position.<trader> (net filled quantity).
+// Operators: < <= > >= == != and within (absolute value <=)
+//
+// Book metrics are armed the first time the book has both sides, so the
+// empty book before initial depth is posted does not trip them
+package assertion
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/ledger"
+)
+
+// Rule is a parsed assertion
+type Rule struct {
+	Source string
+	Metric string // spread, mid, bid_qty, ask_qty, sides or position
+	Trader string // position only
+	Op     string
+	Value  float64
+}
+
+// Parse parses a single rule
+func Parse(src string) (*Rule, error) {
+	fields := strings.Fields(src)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("assertion %q: want \"<metric> <op> <value>\"", src)
+	}
+	r := &Rule{Source: strings.Join(fields, " "), Metric: fields[0], Op: fields[1]}
+
+	switch {
+	case r.Metric == "spread", r.Metric == "mid", r.Metric == "bid_qty",
+		r.Metric == "ask_qty", r.Metric == "sides":
+	case strings.HasPrefix(r.Metric, "position."):
+		r.Trader = strings.TrimPrefix(r.Metric, "position.")
+		r.Metric = "position"
+		if r.Trader == "" {
+			return nil, fmt.Errorf("assertion %q: position needs a trader id", src)
+		}
+	default:
+		return nil, fmt.Errorf("assertion %q: unknown metric %q", src, r.Metric)
+	}
+
+	switch r.Op {
+	case "<", "<=", ">", ">=", "==", "!=", "within":
+	default:
+		return nil, fmt.Errorf("assertion %q: unknown operator %q", src, r.Op)
+	}
+
+	v, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return nil, fmt.Errorf("assertion %q: bad value %q", src, fields[2])
+	}
+	if r.Op == "within" && v < 0 {
+		return nil, fmt.Errorf("assertion %q: within needs a non-negative bound", src)
+	}
+	r.Value = v
+	return r, nil
+}
+
+// holds reports whether x satisfies the rule
+func (r *Rule) holds(x float64) bool {
+	switch r.Op {
+	case "<":
+		return x < r.Value
+	case "<=":
+		return x <= r.Value
+	case ">":
+		return x > r.Value
+	case ">=":
+		return x >= r.Value
+	case "==":
+		return x == r.Value
+	case "!=":
+		return x != r.Value
+	case "within":
+		return x >= -r.Value && x <= r.Value
+	}
+	return false
+}
+
+// Failure describes the first violated assertion. It is returned as the
+// run's error
+type Failure struct {
+	Rule      string
+	Timestamp int64 // sim time in nanos
+	SeqNo     uint64
+	Observed  float64
+	Detail    string // book or position state at the time
+}
+
+func (f *Failure) Error() string {
+	return fmt.Sprintf("assertion %q failed at t=%.3fms (seq %d): observed %s; %s",
+		f.Rule, float64(f.Timestamp)/1e6, f.SeqNo,
+		strconv.FormatFloat(f.Observed, 'f', -1, 64), f.Detail)
+}
+
+// Checker evaluates rules against the running book and the positions in
+// a ledger
+type Checker struct {
+	rules    []*Rule
+	accounts *ledger.Ledger
+	armed    bool
+	scale    domain.Scale
+}
+
+// NewChecker parses rules; an empty list yields a checker that never fails
+// Price metrics are converted with scale and positions read from accounts
+func NewChecker(rules []string, scale domain.Scale, accounts *ledger.Ledger) (*Checker, error) {
+	c := &Checker{accounts: accounts, scale: scale}
+	for _, src := range rules {
+		r, err := Parse(src)
+		if err != nil {
+			return nil, err
+		}
+		c.rules = append(c.rules, r)
+	}
+	return c, nil
+}
+
+// Empty reports whether there is nothing to check
+func (c *Checker) Empty() bool {
+	return len(c.rules) == 0
+}
+
+// Check evaluates every rule against the current state and returns the
+// first failure, or nil
+func (c *Checker) Check(ts int64, seq uint64, bbo *domain.BBO) *Failure {
+	twoSided := bbo.BidPrice > 0 && bbo.AskPrice > 0
+	if twoSided {
+		c.armed = true
+	}
+
+	for _, r := range c.rules {
+		var x float64
+		switch r.Metric {
+		case "position":
+			x = float64(c.accounts.Position(r.Trader))
+		case "spread", "mid":
+			if !twoSided {
+				continue
+			}
+			if r.Metric == "spread" {
+				x = c.scale.ToFloat(bbo.AskPrice - bbo.BidPrice)
+			} else {
+				x = c.scale.ToFloat(bbo.MidPrice)
+			}
+		default:
+			if !c.armed {
+				continue
+			}
+			x = bookMetric(r.Metric, bbo)
+		}
+		if r.holds(x) {
+			continue
+		}
+		return &Failure{
+			Rule:      r.Source,
+			Timestamp: ts,
+			SeqNo:     seq,
+			Observed:  x,
+			Detail:    c.describe(r, bbo),
+		}
+	}
+	return nil
+}
+
+func bookMetric(metric string, bbo *domain.BBO) float64 {
+	switch metric {
+	case "bid_qty":
+		return float64(bbo.BidQty)
+	case "ask_qty":
+		return float64(bbo.AskQty)
+	}
+	sides := 0
+	if bbo.BidPrice > 0 {
+		sides++
+	}
+	if bbo.AskPrice > 0 {
+		sides++
+	}
+	return float64(sides)
+}
+
+func (c *Checker) describe(r *Rule, bbo *domain.BBO) string {
+	if r.Metric == "position" {
+		return fmt.Sprintf("%s net position %d", r.Trader, c.accounts.Position(r.Trader))
+	}
+	return fmt.Sprintf("book bid %s x %d, ask %s x %d",
+		c.sideString(bbo.BidPrice), bbo.BidQty, c.sideString(bbo.AskPrice), bbo.AskQty)
+}
+
+func (c *Checker) sideString(price int64) string {
+	if price == 0 {
+		return "empty"
+	}
+	return c.scale.Format(price)
+}
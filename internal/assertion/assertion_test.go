@@ -0,0 +1,100 @@
+package assertion
+
+import (
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/ledger"
+)
+
+func TestParse(t *testing.T) {
+	r, err := Parse("  position.fast   within 50 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Metric != "position" || r.Trader != "fast" || r.Op != "within" || r.Value != 50 {
+		t.Errorf("parsed %+v", r)
+	}
+	if r.Source != "position.fast within 50" {
+		t.Errorf("source = %q", r.Source)
+	}
+
+	for _, bad := range []string{
+		"",
+		"spread <=",
+		"spread <= 0.1 extra",
+		"depth >= 1",
+		"spread =< 0.1",
+		"spread <= ten",
+		"position. within 5",
+		"position.fast within -1",
+	} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) accepted", bad)
+		}
+	}
+}
+
+func bbo(bid, bidQty, ask, askQty int64) *domain.BBO {
+	b := &domain.BBO{BidPrice: bid, BidQty: bidQty, AskPrice: ask, AskQty: askQty}
+	if bid > 0 && ask > 0 {
+		b.MidPrice = (bid + ask) / 2
+	}
+	return b
+}
+
+func TestBookRulesArmOnceTwoSided(t *testing.T) {
+	c, err := NewChecker([]string{"sides >= 1", "spread <= 0.05"}, domain.DefaultScale, ledger.New(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Empty book before depth is posted is not a violation
+	if f := c.Check(0, 1, bbo(0, 0, 0, 0)); f != nil {
+		t.Fatalf("failed before armed: %v", f)
+	}
+	if f := c.Check(1, 2, bbo(999_900, 5, 1_000_100, 5)); f != nil {
+		t.Fatalf("unexpected failure: %v", f)
+	}
+	// One-sided book: spread is undefined, sides still >= 1
+	if f := c.Check(2, 3, bbo(999_900, 5, 0, 0)); f != nil {
+		t.Fatalf("unexpected failure: %v", f)
+	}
+	f := c.Check(3, 4, bbo(0, 0, 0, 0))
+	if f == nil || f.Rule != "sides >= 1" || f.Observed != 0 || f.SeqNo != 4 {
+		t.Fatalf("failure = %+v, want sides >= 1 at seq 4", f)
+	}
+
+	f = c.Check(4, 5, bbo(999_000, 5, 1_001_000, 5))
+	if f == nil || f.Rule != "spread <= 0.05" || f.Observed != 0.2 {
+		t.Fatalf("failure = %+v, want spread 0.2", f)
+	}
+}
+
+func TestPositionWithin(t *testing.T) {
+	accounts := ledger.New(0, 0)
+	c, err := NewChecker([]string{"position.fast within 10"}, domain.DefaultScale, accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := bbo(999_900, 5, 1_000_100, 5)
+	accounts.Apply(&domain.Trade{BuyTrader: "fast", SellTrader: "background", Price: 1_000_000, Qty: 10})
+	if f := c.Check(1, 1, book); f != nil {
+		t.Fatalf("unexpected failure: %v", f)
+	}
+	accounts.Apply(&domain.Trade{BuyTrader: "background", SellTrader: "fast", Price: 1_000_000, Qty: 25})
+	f := c.Check(2, 2, book)
+	if f == nil || f.Observed != -15 {
+		t.Fatalf("failure = %+v, want position -15", f)
+	}
+	if f.Detail != "fast net position -15" {
+		t.Errorf("detail = %q", f.Detail)
+	}
+}
+
+func TestFailureError(t *testing.T) {
+	f := &Failure{Rule: "spread <= 0.05", Timestamp: 1_234_567_000, SeqNo: 42, Observed: 0.2, Detail: "book bid 99.9000 x 5, ask 100.1000 x 5"}
+	want := `assertion "spread <= 0.05" failed at t=1234.567ms (seq 42): observed 0.2; book bid 99.9000 x 5, ask 100.1000 x 5`
+	if f.Error() != want {
+		t.Errorf("Error() = %q\nwant      %q", f.Error(), want)
+	}
+}
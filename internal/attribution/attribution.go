@@ -0,0 +1,105 @@
+// Package attribution splits the outcome gap between the fast and slow
+// traders into a latency part and an information part when a scenario
+// gives them different latencies and different signal access. It re-runs
+// the scenario with the slow trader given the fast trader's latency, its
+// signal access, and both, then averages the two orders of removing the
+// asymmetries (the Shapley split), so the parts add up to the gap
+package attribution
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+// Gap is one metric's fast-minus-slow difference and its split
+// Total == Latency + Information + Residual
+type Gap struct {
+	Metric      string  `json:"metric"`
+	Total       float64 `json:"total"` // in the configured run
+	Latency     float64 `json:"latency"`
+	Information float64 `json:"information"`
+	Residual    float64 `json:"residual"` // with neither asymmetry: order of dispatch, RNG streams
+}
+
+// metric reads one value off a trader's metrics
+type metric struct {
+	name string
+	get  func(*metrics.TraderMetrics) float64
+}
+
+var attributed = []metric{
+	{"Fill Rate (%)", func(m *metrics.TraderMetrics) float64 { return m.FillRate * 100 }},
+	{"Slippage (bps)", func(m *metrics.TraderMetrics) float64 { return m.SlippageBps }},
+	{"Adverse Selection (bps)", func(m *metrics.TraderMetrics) float64 { return m.AdverseSelectionBps }},
+	{"Net PnL", func(m *metrics.TraderMetrics) float64 { return m.NetPnL }},
+}
+
+// Applies reports whether cfg has both asymmetries to separate
+func Applies(cfg *scenario.Config) bool {
+	return cfg.LatencyAsymmetric() && cfg.InformationAsymmetric()
+}
+
+// Run splits the gaps in actual, the metrics of the configured run. The
+// counterfactual runs write under dir
+func Run(cfg *scenario.Config, actual map[string]*metrics.TraderMetrics, dir string) ([]Gap, error) {
+	variants := []struct {
+		name  string
+		apply func(slow, fast scenario.TraderConfig) scenario.TraderConfig
+	}{
+		{"latency_only", func(slow, fast scenario.TraderConfig) scenario.TraderConfig {
+			return slow.WithSignalAccess(fast)
+		}},
+		{"information_only", func(slow, fast scenario.TraderConfig) scenario.TraderConfig {
+			return slow.WithLatency(fast)
+		}},
+		{"neither", func(slow, fast scenario.TraderConfig) scenario.TraderConfig {
+			return slow.WithSignalAccess(fast).WithLatency(fast)
+		}},
+	}
+	runs := map[string]map[string]*metrics.TraderMetrics{"both": actual}
+	for _, v := range variants {
+		c := *cfg
+		c.SlowTrader = v.apply(c.SlowTrader, c.FastTrader)
+		m, err := runVariant(&c, filepath.Join(dir, v.name))
+		if err != nil {
+			return nil, fmt.Errorf("%s run: %w", v.name, err)
+		}
+		runs[v.name] = m
+	}
+
+	gaps := make([]Gap, 0, len(attributed))
+	for _, mt := range attributed {
+		gap := func(run string) float64 {
+			f, s := runs[run][cfg.FastTrader.ID], runs[run][cfg.SlowTrader.ID]
+			if f == nil || s == nil {
+				return 0
+			}
+			return mt.get(f) - mt.get(s)
+		}
+		both, lat, info, none := gap("both"), gap("latency_only"), gap("information_only"), gap("neither")
+		gaps = append(gaps, Gap{
+			Metric:      mt.name,
+			Total:       both,
+			Latency:     ((both - info) + (lat - none)) / 2,
+			Information: ((both - lat) + (info - none)) / 2,
+			Residual:    none,
+		})
+	}
+	return gaps, nil
+}
+
+func runVariant(cfg *scenario.Config, dir string) (map[string]*metrics.TraderMetrics, error) {
+	runner, err := sim.NewRunner(cfg, dir)
+	if err != nil {
+		return nil, err
+	}
+	result, err := runner.Run()
+	if err != nil {
+		return nil, err
+	}
+	return metrics.ComputeFromLog(result.LogPath, cfg.Scale(), cfg.Metrics)
+}
@@ -0,0 +1,48 @@
+package attribution
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+func TestSplitAddsUpToGap(t *testing.T) {
+	cfg := scenario.GetConfig("thin", 7)
+	cfg.Duration = latency.MsToNs(2_000)
+	cfg.SlowTrader.SignalDelayNs = latency.MsToNs(30)
+	if !Applies(cfg) {
+		t.Fatal("expected latency and information asymmetry")
+	}
+	dir := t.TempDir()
+	actual, err := runVariant(cfg, filepath.Join(dir, "both"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gaps, err := Run(cfg, actual, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != len(attributed) {
+		t.Fatalf("got %d gaps, want %d", len(gaps), len(attributed))
+	}
+	for _, g := range gaps {
+		if sum := g.Latency + g.Information + g.Residual; math.Abs(sum-g.Total) > 1e-9 {
+			t.Errorf("%s: parts sum to %v, gap is %v", g.Metric, sum, g.Total)
+		}
+	}
+}
+
+func TestAppliesNeedsBoth(t *testing.T) {
+	cfg := scenario.GetConfig("calm", 1)
+	if Applies(cfg) {
+		t.Error("latency-only config applies")
+	}
+	cfg.SlowTrader.NoSignal = true
+	cfg.SlowTrader = cfg.SlowTrader.WithLatency(cfg.FastTrader)
+	if Applies(cfg) {
+		t.Error("information-only config applies")
+	}
+}
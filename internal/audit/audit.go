@@ -0,0 +1,284 @@
+// Package audit replays an event log and checks the global accounting
+// invariants that no single component sees on its own: trade legs agree
+// with the orders they reference, fills and cancels never exceed what was
+// submitted, and traded volume reconciles with per-trader fills
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+)
+
+// Violation kinds
+const (
+	KindUnknownOrder   = "unknown_order"   // trade or cancel references an order never accepted
+	KindLegMismatch    = "leg_mismatch"    // trade leg disagrees with its order's side or trader
+	KindOverfill       = "overfill"        // fills plus canceled qty exceed the order's qty
+	KindAggressorFill  = "aggressor_fill"  // an order's own trades disagree with its logged remaining qty
+	KindCancelOwner    = "cancel_owner"    // cancel sent by a trader other than the order's owner
+	KindTradedQty      = "traded_qty"      // total traded qty does not reconcile with per-trader fills
+	KindDuplicateOrder = "duplicate_order" // an order id accepted twice
+)
+
+// maxViolations caps the violations kept in a report; the count is exact
+const maxViolations = 1000
+
+// Violation is one failed check, located by log line
+type Violation struct {
+	Line      int    `json:"line"`
+	Timestamp int64  `json:"timestamp"`
+	Kind      string `json:"kind"`
+	Message   string `json:"message"`
+}
+
+// TraderTotals is the per-trader accounting reconstructed from the log
+type TraderTotals struct {
+	SubmittedQty int64 `json:"submitted_qty"`
+	BoughtQty    int64 `json:"bought_qty"`
+	SoldQty      int64 `json:"sold_qty"`
+	CanceledQty  int64 `json:"canceled_qty"`
+}
+
+// Report is the result of an audit, written as audit.json
+type Report struct {
+	OK             bool                     `json:"ok"`
+	Events         int                      `json:"events"`
+	Orders         int                      `json:"orders"`
+	Trades         int                      `json:"trades"`
+	TradedQty      int64                    `json:"traded_qty"`
+	Traders        map[string]*TraderTotals `json:"traders"`
+	ViolationCount int                      `json:"violation_count"`
+	Violations     []Violation              `json:"violations"`
+}
+
+type orderState struct {
+	trader   string
+	side     domain.Side
+	qty      int64
+	filled   int64
+	canceled int64
+}
+
+// Auditor checks events one at a time in log order
+type Auditor struct {
+	report *Report
+	orders map[uint64]*orderState
+	line   int
+	ts     int64
+
+	// The most recent accepted order and the qty its logged remaining
+	// implies it traded on arrival; its trades follow it in the log
+	aggressor       uint64
+	aggressorWant   int64
+	aggressorFilled int64
+}
+
+// New creates an empty auditor
+func New() *Auditor {
+	return &Auditor{
+		report: &Report{
+			Traders:    make(map[string]*TraderTotals),
+			Violations: []Violation{},
+		},
+		orders: make(map[uint64]*orderState),
+	}
+}
+
+func (a *Auditor) violate(kind, format string, args ...interface{}) {
+	a.report.ViolationCount++
+	if len(a.report.Violations) < maxViolations {
+		a.report.Violations = append(a.report.Violations, Violation{
+			Line:      a.line,
+			Timestamp: a.ts,
+			Kind:      kind,
+			Message:   fmt.Sprintf(format, args...),
+		})
+	}
+}
+
+func (a *Auditor) trader(id string) *TraderTotals {
+	t, ok := a.report.Traders[id]
+	if !ok {
+		t = &TraderTotals{}
+		a.report.Traders[id] = t
+	}
+	return t
+}
+
+// Process audits the next event
+func (a *Auditor) Process(e *domain.Event) {
+	a.line++
+	a.ts = e.Timestamp
+	a.report.Events++
+
+	// Lifecycle, account and iceberg refresh records sit among an order's
+	// trades without ending them
+	switch e.Type {
+	case domain.EventTradeExecuted, domain.EventOrderState, domain.EventAccountUpdate, domain.EventIcebergRefresh:
+	default:
+		a.closeAggressor()
+	}
+
+	switch e.Type {
+	case domain.EventOrderAccepted:
+		if o := e.Order(); o != nil {
+			a.accept(o)
+		}
+	case domain.EventOrderCanceled:
+		if c := e.Cancel(); c != nil {
+			a.cancel(c)
+		}
+	case domain.EventTradeExecuted:
+		if t := e.Trade(); t != nil {
+			a.trade(t)
+		}
+	}
+}
+
+func (a *Auditor) accept(o *domain.Order) {
+	if o.Type == domain.CancelOrder {
+		return // accounted for by the ORDER_CANCELED that follows
+	}
+	if _, dup := a.orders[o.ID]; dup {
+		a.violate(KindDuplicateOrder, "order %d accepted twice", o.ID)
+		return
+	}
+	a.orders[o.ID] = &orderState{trader: o.TraderID, side: o.Side, qty: o.Qty}
+	a.report.Orders++
+	a.trader(o.TraderID).SubmittedQty += o.Qty
+
+	a.aggressor = o.ID
+	a.aggressorWant = o.Qty - o.RemainingQty
+	a.aggressorFilled = 0
+}
+
+// closeAggressor checks the trades after an accepted order add up to
+// what its logged remaining qty says it traded on arrival
+func (a *Auditor) closeAggressor() {
+	if a.aggressor == 0 {
+		return
+	}
+	if a.aggressorFilled != a.aggressorWant {
+		a.violate(KindAggressorFill, "order %d traded %d on arrival but its remaining qty implies %d",
+			a.aggressor, a.aggressorFilled, a.aggressorWant)
+	}
+	a.aggressor = 0
+}
+
+func (a *Auditor) cancel(c *domain.Order) {
+	target, ok := a.orders[c.CancelID]
+	if !ok {
+		a.violate(KindUnknownOrder, "cancel %d targets order %d that was never accepted", c.ID, c.CancelID)
+		return
+	}
+	if c.TraderID != target.trader {
+		a.violate(KindCancelOwner, "cancel %d from %s targets order %d owned by %s",
+			c.ID, c.TraderID, c.CancelID, target.trader)
+	}
+	// The book cancels whatever remains, or for a partial cancel all but
+	// what the ack says is left; nothing if already done
+	remaining := target.qty - target.filled - target.canceled
+	if cut := remaining - c.RemainingQty; cut > 0 {
+		target.canceled += cut
+		a.trader(target.trader).CanceledQty += cut
+	}
+}
+
+func (a *Auditor) trade(t *domain.Trade) {
+	a.report.Trades++
+	a.report.TradedQty += t.Qty
+
+	if t.BuyOrderID == t.SellOrderID {
+		a.violate(KindLegMismatch, "trade %d buys and sells order %d", t.ID, t.BuyOrderID)
+	}
+	if t.PassiveOrderID != 0 || t.AggressorOrderID != 0 {
+		if !(t.PassiveOrderID == t.BuyOrderID && t.AggressorOrderID == t.SellOrderID) &&
+			!(t.PassiveOrderID == t.SellOrderID && t.AggressorOrderID == t.BuyOrderID) {
+			a.violate(KindLegMismatch, "trade %d passive/aggressor %d/%d are not its legs %d/%d",
+				t.ID, t.PassiveOrderID, t.AggressorOrderID, t.BuyOrderID, t.SellOrderID)
+		}
+	}
+	if t.AggressorOrderID != 0 && t.AggressorOrderID == a.aggressor {
+		a.aggressorFilled += t.Qty
+	}
+
+	a.leg(t, t.BuyOrderID, domain.Buy, t.BuyTrader)
+	a.leg(t, t.SellOrderID, domain.Sell, t.SellTrader)
+	a.trader(t.BuyTrader).BoughtQty += t.Qty
+	a.trader(t.SellTrader).SoldQty += t.Qty
+}
+
+func (a *Auditor) leg(t *domain.Trade, orderID uint64, side domain.Side, trader string) {
+	o, ok := a.orders[orderID]
+	if !ok {
+		a.violate(KindUnknownOrder, "trade %d %s leg references order %d that was never accepted", t.ID, side, orderID)
+		return
+	}
+	if o.side != side || o.trader != trader {
+		a.violate(KindLegMismatch, "trade %d %s leg by %s but order %d is %s by %s",
+			t.ID, side, trader, orderID, o.side, o.trader)
+	}
+	o.filled += t.Qty
+	if o.filled+o.canceled > o.qty {
+		a.violate(KindOverfill, "order %d filled %d + canceled %d > qty %d", orderID, o.filled, o.canceled, o.qty)
+	}
+}
+
+// Finish runs the end-of-log reconciliation and returns the report
+func (a *Auditor) Finish() *Report {
+	a.closeAggressor()
+
+	var bought, sold, filled int64
+	for _, t := range detorder.All(a.report.Traders) {
+		bought += t.BoughtQty
+		sold += t.SoldQty
+	}
+	for _, o := range detorder.All(a.orders) {
+		filled += o.filled
+	}
+	if bought != a.report.TradedQty || sold != a.report.TradedQty {
+		a.violate(KindTradedQty, "traded qty %d but traders bought %d and sold %d", a.report.TradedQty, bought, sold)
+	}
+	if filled != 2*a.report.TradedQty {
+		a.violate(KindTradedQty, "orders filled %d, want twice traded qty %d", filled, a.report.TradedQty)
+	}
+
+	a.report.OK = a.report.ViolationCount == 0
+	return a.report
+}
+
+// Log audits the event log at path
+func Log(path string) (*Report, error) {
+	r, err := eventlog.NewReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if err := r.Require(eventlog.LevelFull, "audit"); err != nil {
+		return nil, err
+	}
+
+	a := New()
+	err = r.Each(func(e *domain.Event) error {
+		a.Process(e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("audit line %d: %w", a.line+1, err)
+	}
+	return a.Finish(), nil
+}
+
+// WriteFile writes the report as indented JSON
+func (r *Report) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal audit: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
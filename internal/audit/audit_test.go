@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+func limit(id uint64, trader string, side domain.Side, qty, remaining int64) *domain.Event {
+	return &domain.Event{Type: domain.EventOrderAccepted, Payload: &domain.Order{
+		ID: id, TraderID: trader, Side: side, Type: domain.LimitOrder, Price: 1_000_000, Qty: qty, RemainingQty: remaining}}
+}
+
+func fill(id, buy, sell uint64, buyer, seller string, qty int64, aggressor uint64) *domain.Event {
+	passive := buy
+	if aggressor == buy {
+		passive = sell
+	}
+	return &domain.Event{Type: domain.EventTradeExecuted, Payload: &domain.Trade{
+		ID: id, BuyOrderID: buy, SellOrderID: sell, BuyTrader: buyer, SellTrader: seller,
+		Price: 1_000_000, Qty: qty, PassiveOrderID: passive, AggressorOrderID: aggressor}}
+}
+
+func cancel(id, target uint64, trader string) []*domain.Event {
+	o := &domain.Order{ID: id, TraderID: trader, Type: domain.CancelOrder, CancelID: target}
+	return []*domain.Event{
+		{Type: domain.EventOrderAccepted, Payload: o},
+		{Type: domain.EventOrderCanceled, Payload: o},
+	}
+}
+
+func run(events ...*domain.Event) *Report {
+	a := New()
+	for _, e := range events {
+		a.Process(e)
+	}
+	return a.Finish()
+}
+
+// cleanLog: slow rests 10, fast takes 4, slow cancels the remaining 6
+func cleanLog() []*domain.Event {
+	events := []*domain.Event{
+		limit(1, "slow", domain.Sell, 10, 10),
+		limit(2, "fast", domain.Buy, 4, 0),
+		fill(1, 2, 1, "fast", "slow", 4, 2),
+	}
+	return append(events, cancel(3, 1, "slow")...)
+}
+
+func TestCleanLogPasses(t *testing.T) {
+	r := run(cleanLog()...)
+	if !r.OK || r.ViolationCount != 0 {
+		t.Fatalf("expected clean audit, got %+v", r.Violations)
+	}
+	if r.TradedQty != 4 || r.Traders["fast"].BoughtQty != 4 || r.Traders["slow"].SoldQty != 4 {
+		t.Errorf("unexpected totals: traded %d, traders %+v %+v", r.TradedQty, *r.Traders["fast"], *r.Traders["slow"])
+	}
+	if r.Traders["slow"].CanceledQty != 6 {
+		t.Errorf("slow canceled %d, want 6", r.Traders["slow"].CanceledQty)
+	}
+}
+
+func TestViolationsDetected(t *testing.T) {
+	cases := []struct {
+		name   string
+		events []*domain.Event
+		kind   string
+	}{
+		{"trade on unknown order", []*domain.Event{
+			limit(2, "fast", domain.Buy, 4, 0),
+			fill(1, 2, 99, "fast", "slow", 4, 2),
+		}, KindUnknownOrder},
+		{"fill after cancel", append(append([]*domain.Event{
+			limit(1, "slow", domain.Sell, 10, 10)},
+			cancel(3, 1, "slow")...),
+			limit(2, "fast", domain.Buy, 4, 0),
+			fill(1, 2, 1, "fast", "slow", 4, 2),
+		), KindOverfill},
+		{"leg on wrong side", []*domain.Event{
+			limit(1, "slow", domain.Buy, 10, 10),
+			limit(2, "fast", domain.Buy, 4, 0),
+			fill(1, 2, 1, "fast", "slow", 4, 2),
+		}, KindLegMismatch},
+		{"leg by wrong trader", []*domain.Event{
+			limit(1, "slow", domain.Sell, 10, 10),
+			limit(2, "fast", domain.Buy, 4, 0),
+			fill(1, 2, 1, "fast", "background", 4, 2),
+		}, KindLegMismatch},
+		{"missing aggressor trade", []*domain.Event{
+			limit(1, "slow", domain.Sell, 10, 10),
+			limit(2, "fast", domain.Buy, 4, 0),
+		}, KindAggressorFill},
+		{"cancel by non-owner", append([]*domain.Event{
+			limit(1, "slow", domain.Sell, 10, 10)},
+			cancel(3, 1, "fast")...,
+		), KindCancelOwner},
+		{"cancel of unknown order", cancel(3, 42, "fast"), KindUnknownOrder},
+		{"duplicate order id", []*domain.Event{
+			limit(1, "slow", domain.Sell, 10, 10),
+			limit(1, "slow", domain.Sell, 10, 10),
+		}, KindDuplicateOrder},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := run(tc.events...)
+			if r.OK {
+				t.Fatal("expected violations")
+			}
+			found := false
+			for _, v := range r.Violations {
+				found = found || v.Kind == tc.kind
+			}
+			if !found {
+				t.Errorf("expected a %s violation, got %+v", tc.kind, r.Violations)
+			}
+		})
+	}
+}
+
+func TestViolationLine(t *testing.T) {
+	events := cleanLog()
+	events[2].Trade().SellTrader = "background"
+	r := run(events...)
+	if len(r.Violations) == 0 || r.Violations[0].Line != 3 {
+		t.Fatalf("expected violation on line 3, got %+v", r.Violations)
+	}
+}
@@ -0,0 +1,318 @@
+package audit
+
+import (
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+)
+
+// Trail event names, in the order an order's chain usually runs
+const (
+	TrailOrderDecision  = "ORDER_DECISION"  // the trader decided to send the order
+	TrailOrderArrival   = "ORDER_ARRIVAL"   // the order reached the book through the gateway
+	TrailState          = "STATE"           // a lifecycle transition
+	TrailExecution      = "EXECUTION"       // a fill
+	TrailRefresh        = "ICEBERG_REFRESH" // an iceberg showed its next slice, at the back of the queue
+	TrailCancelDecision = "CANCEL_DECISION" // a trader decided to cancel the order
+	TrailCancelArrival  = "CANCEL_ARRIVAL"  // the cancel reached the book
+	TrailCancelAck      = "CANCEL_ACK"      // the book removed the order, or some of it
+	TrailCancelRejected = "CANCEL_REJECTED" // the book refused the cancel
+)
+
+// TrailHeader is the CSV header of an audit trail
+var TrailHeader = []string{
+	"order_id", "event_line", "timestamp_ns", "event", "trader_id", "side", "order_type",
+	"price", "qty", "leaves_qty", "cum_qty", "state", "ref_id", "contra_trader", "liquidity", "detail",
+}
+
+// TrailRow is one step in an order's chain. OrderID is the trader order
+// the row belongs to; for cancel rows TraderID is the trader who sent the
+// cancel and RefID the cancel instruction, for executions RefID is the
+// trade ID. EventLine is the 1-based events.jsonl line the row comes from
+type TrailRow struct {
+	OrderID   uint64
+	EventLine int
+	Timestamp int64
+	Event     string
+	TraderID  string
+	Side      string
+	OrderType string
+	Price     int64 // 0 when not applicable
+	Qty       int64
+	LeavesQty int64
+	CumQty    int64
+	State     string
+	RefID     uint64
+	Contra    string
+	Liquidity string // ADD for the resting side of a fill, REMOVE for the aggressor
+	Detail    string
+}
+
+type trailOrder struct {
+	trader string
+	side   domain.Side
+	typ    domain.OrderType
+	price  int64
+	qty    int64
+	filled int64
+	cut    int64 // taken off by partial cancels
+}
+
+// leaves is what the order has left on the book, if it is still there
+func (o *trailOrder) leaves() int64 {
+	return max(o.qty-o.filled-o.cut, 0)
+}
+
+// Trail builds a regulator-style audit trail (in the manner of the US
+// Consolidated Audit Trail): for every trader order, the chronological
+// chain from decision through gateway arrival, book events, executions
+// and cancels. Background orders get no chain of their own but appear as
+// contra parties
+type Trail struct {
+	scale   domain.Scale
+	line    int
+	orders  map[uint64]*trailOrder
+	cancels map[uint64]uint64 // cancel instruction -> target order
+	chains  map[uint64][]TrailRow
+}
+
+// NewTrail creates an empty trail; scale formats prices
+func NewTrail(scale domain.Scale) *Trail {
+	return &Trail{
+		scale:   scale,
+		orders:  make(map[uint64]*trailOrder),
+		cancels: make(map[uint64]uint64),
+		chains:  make(map[uint64][]TrailRow),
+	}
+}
+
+func traderOrder(id string) bool {
+	return id != "" && !domain.IsBackground(id)
+}
+
+// Process adds the next event in log order
+func (t *Trail) Process(e *domain.Event) {
+	t.line++
+	switch e.Type {
+	case domain.EventOrderAccepted:
+		if c := e.Cancel(); c != nil {
+			t.cancelRequest(c, e.Timestamp)
+		} else if o := e.Order(); o != nil {
+			t.accept(o, e.Timestamp)
+		}
+	case domain.EventOrderCanceled:
+		if c := e.Cancel(); c != nil && t.cancels[c.ID] != 0 {
+			t.cancelAck(c, e.Timestamp)
+		}
+	case domain.EventTradeExecuted:
+		if tr := e.Trade(); tr != nil {
+			t.execution(tr, tr.BuyOrderID, tr.BuyTrader, tr.SellTrader)
+			t.execution(tr, tr.SellOrderID, tr.SellTrader, tr.BuyTrader)
+		}
+	case domain.EventOrderState:
+		if c := e.StateChange(); c != nil {
+			t.state(c, e.Timestamp)
+		}
+	case domain.EventIcebergRefresh:
+		if o := e.Order(); o != nil {
+			t.refresh(o, e.Timestamp)
+		}
+	}
+}
+
+func (t *Trail) accept(o *domain.Order, ts int64) {
+	if !traderOrder(o.TraderID) {
+		return
+	}
+	t.orders[o.ID] = &trailOrder{trader: o.TraderID, side: o.Side, typ: o.Type, price: o.Price, qty: o.Qty}
+	row := TrailRow{TraderID: o.TraderID, Side: o.Side.String(), OrderType: o.Type.String(), Price: o.Price, Qty: o.Qty}
+	decision := row
+	decision.Timestamp, decision.Event, decision.LeavesQty = o.DecisionTime, TrailOrderDecision, o.Qty
+	t.add(o.ID, decision)
+
+	row.Timestamp, row.Event = ts, TrailOrderArrival
+	row.LeavesQty, row.CumQty = o.Qty, 0
+	if o.ArrivalTime != 0 && o.ArrivalTime != ts {
+		row.Detail = fmt.Sprintf("arrival_time=%d", o.ArrivalTime)
+	}
+	t.add(o.ID, row)
+}
+
+func (t *Trail) cancelRequest(c *domain.Order, ts int64) {
+	target, known := t.orders[c.CancelID]
+	if !known && !traderOrder(c.TraderID) {
+		return // background cancelling background
+	}
+	t.cancels[c.ID] = c.CancelID
+	row := TrailRow{TraderID: c.TraderID, OrderType: c.Type.String(), RefID: c.ID}
+	if c.Price != 0 {
+		row.Side, row.Price = c.Side.String(), c.Price
+	}
+	if known {
+		row.LeavesQty, row.CumQty = target.leaves(), target.filled
+	}
+	row.Qty = c.Qty
+	decision := row
+	decision.Timestamp, decision.Event = c.DecisionTime, TrailCancelDecision
+	if traderOrder(c.TraderID) {
+		t.add(c.CancelID, decision)
+	}
+	row.Timestamp, row.Event = ts, TrailCancelArrival
+	t.add(c.CancelID, row)
+}
+
+// cancelAck records the book acting on a cancel. A partial cancel shows
+// how much it took off and what the order has left
+func (t *Trail) cancelAck(c *domain.Order, ts int64) {
+	row := TrailRow{Timestamp: ts, Event: TrailCancelAck, TraderID: c.TraderID, OrderType: c.Type.String(), RefID: c.ID}
+	if o, ok := t.orders[c.CancelID]; ok && c.RemainingQty > 0 {
+		row.Qty = o.leaves() - c.RemainingQty
+		o.cut += row.Qty
+		row.LeavesQty, row.CumQty = c.RemainingQty, o.filled
+		row.Detail = "partial cancel"
+	}
+	t.add(c.CancelID, row)
+}
+
+func (t *Trail) execution(tr *domain.Trade, id uint64, trader, contra string) {
+	o, ok := t.orders[id]
+	if !ok {
+		return
+	}
+	o.filled += tr.Qty
+	liquidity := "REMOVE"
+	if id == tr.PassiveOrderID {
+		liquidity = "ADD"
+	}
+	t.add(id, TrailRow{Timestamp: tr.Timestamp, Event: TrailExecution, TraderID: trader,
+		Side: o.side.String(), OrderType: o.typ.String(), Price: tr.Price, Qty: tr.Qty,
+		LeavesQty: o.leaves(), CumQty: o.filled, RefID: tr.ID, Contra: contra, Liquidity: liquidity})
+}
+
+// refresh records an iceberg's new slice, with Qty the qty it shows and
+// the queue position it took
+func (t *Trail) refresh(o *domain.Order, ts int64) {
+	tracked, ok := t.orders[o.ID]
+	if !ok {
+		return
+	}
+	t.add(o.ID, TrailRow{Timestamp: ts, Event: TrailRefresh, TraderID: o.TraderID,
+		Side: o.Side.String(), OrderType: o.Type.String(), Price: o.Price, Qty: min(o.DisplayQty, o.RemainingQty),
+		LeavesQty: tracked.leaves(), CumQty: tracked.filled, Detail: fmt.Sprintf("queue_pos=%d", o.QueuePos)})
+}
+
+func (t *Trail) state(c *domain.StateChange, ts int64) {
+	if target, isCancel := t.cancels[c.OrderID]; isCancel {
+		if c.To == domain.StateRejected {
+			t.add(target, TrailRow{Timestamp: ts, Event: TrailCancelRejected, TraderID: c.TraderID,
+				OrderType: domain.CancelOrder.String(), State: c.To.String(), RefID: c.OrderID, Detail: c.Reason})
+		}
+		return
+	}
+	o, ok := t.orders[c.OrderID]
+	if !ok {
+		return
+	}
+	t.add(c.OrderID, TrailRow{Timestamp: ts, Event: TrailState, TraderID: o.trader,
+		Side: o.side.String(), OrderType: o.typ.String(), Price: o.price, Qty: o.qty,
+		LeavesQty: c.RemainingQty, CumQty: c.FilledQty, State: c.To.String(),
+		Detail: c.From.String() + " -> " + c.To.String() + reason(c.Reason)})
+}
+
+func reason(r string) string {
+	if r == "" {
+		return ""
+	}
+	return ": " + r
+}
+
+func (t *Trail) add(id uint64, row TrailRow) {
+	row.OrderID = id
+	row.EventLine = t.line
+	t.chains[id] = append(t.chains[id], row)
+}
+
+// Rows returns every chain, by order ID, each in time order. Rows at the
+// same timestamp keep log order; a decision sorts before its arrival
+func (t *Trail) Rows() []TrailRow {
+	var rows []TrailRow
+	for _, chain := range detorder.Values(t.chains) {
+		slices.SortStableFunc(chain, func(a, b TrailRow) int {
+			return cmp.Compare(a.Timestamp, b.Timestamp)
+		})
+		rows = append(rows, chain...)
+	}
+	return rows
+}
+
+// WriteCSV writes the trail with TrailHeader
+func (t *Trail) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(TrailHeader); err != nil {
+		return err
+	}
+	u := func(v uint64) string {
+		if v == 0 {
+			return ""
+		}
+		return strconv.FormatUint(v, 10)
+	}
+	for _, r := range t.Rows() {
+		price := ""
+		if r.Price != 0 {
+			price = t.scale.Format(r.Price)
+		}
+		if err := cw.Write([]string{
+			u(r.OrderID), strconv.Itoa(r.EventLine), strconv.FormatInt(r.Timestamp, 10), r.Event, r.TraderID,
+			r.Side, r.OrderType, price, strconv.FormatInt(r.Qty, 10), strconv.FormatInt(r.LeavesQty, 10),
+			strconv.FormatInt(r.CumQty, 10), r.State, u(r.RefID), r.Contra, r.Liquidity, r.Detail,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteFile writes the trail as CSV to path
+func (t *Trail) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := t.WriteCSV(f); err != nil {
+		f.Close()
+		return fmt.Errorf("write audit trail: %w", err)
+	}
+	return f.Close()
+}
+
+// TrailLog builds the audit trail of the event log at path
+func TrailLog(path string, scale domain.Scale) (*Trail, error) {
+	r, err := eventlog.NewReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if err := r.Require(eventlog.LevelFull, "audit trail"); err != nil {
+		return nil, err
+	}
+
+	t := NewTrail(scale)
+	err = r.Each(func(e *domain.Event) error {
+		t.Process(e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("audit trail line %d: %w", t.line+1, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// TestTrailChain: slow rests 10, fast takes 4, fast's cancel of slow's
+// order is refused, slow cancels the remaining 6
+func TestTrailChain(t *testing.T) {
+	at := func(ts int64, e *domain.Event) *domain.Event {
+		e.Timestamp = ts
+		if o := e.Order(); o != nil {
+			o.DecisionTime, o.ArrivalTime = ts-5, ts
+		}
+		if tr := e.Trade(); tr != nil {
+			tr.Timestamp = ts
+		}
+		return e
+	}
+	state := func(ts int64, id uint64, trader string, from, to domain.OrderState, filled, remaining int64, reason string) *domain.Event {
+		e := domain.NewStateEvent(ts, &domain.StateChange{OrderID: id, TraderID: trader, From: from, To: to,
+			FilledQty: filled, RemainingQty: remaining, Reason: reason})
+		return &e
+	}
+	foreign := &domain.Order{ID: 4, TraderID: "fast", Type: domain.CancelOrder, CancelID: 1, DecisionTime: 25}
+	own := cancel(5, 1, "slow")
+	own[0].Payload.(*domain.Order).DecisionTime = 35
+	events := []*domain.Event{
+		at(10, limit(1, "slow", domain.Sell, 10, 10)),
+		state(10, 1, "slow", domain.StateNew, domain.StateResting, 0, 10, ""),
+		at(20, limit(2, "fast", domain.Buy, 4, 0)),
+		at(20, fill(1, 2, 1, "fast", "slow", 4, 2)),
+		state(20, 1, "slow", domain.StateResting, domain.StatePartiallyFilled, 4, 6, ""),
+		state(20, 2, "fast", domain.StateNew, domain.StateFilled, 4, 0, ""),
+		{Timestamp: 30, Type: domain.EventOrderAccepted, Payload: foreign},
+		state(30, 4, "fast", domain.StateNew, domain.StateRejected, 0, 0, "target 1 owned by slow"),
+		at(40, own[0]),
+		at(40, own[1]),
+		state(40, 1, "slow", domain.StatePartiallyFilled, domain.StateCanceled, 4, 0, ""),
+		at(50, limit(9, "background", domain.Sell, 3, 3)),
+	}
+	trail := NewTrail(domain.DefaultScale)
+	for _, e := range events {
+		trail.Process(e)
+	}
+
+	var got []string
+	for _, r := range trail.Rows() {
+		got = append(got, strings.Join([]string{strconv.FormatUint(r.OrderID, 10), r.Event, r.TraderID}, " "))
+	}
+	want := []string{
+		"1 ORDER_DECISION slow", "1 ORDER_ARRIVAL slow", "1 STATE slow",
+		"1 EXECUTION slow", "1 STATE slow",
+		"1 CANCEL_DECISION fast", "1 CANCEL_ARRIVAL fast", "1 CANCEL_REJECTED fast",
+		"1 CANCEL_DECISION slow", "1 CANCEL_ARRIVAL slow", "1 CANCEL_ACK slow", "1 STATE slow",
+		"2 ORDER_DECISION fast", "2 ORDER_ARRIVAL fast", "2 EXECUTION fast", "2 STATE fast",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("trail rows:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	rows := trail.Rows()
+	if exec := rows[3]; exec.Liquidity != "ADD" || exec.Contra != "fast" || exec.CumQty != 4 || exec.LeavesQty != 6 || exec.RefID != 1 {
+		t.Errorf("slow's execution = %+v", exec)
+	}
+	if rej := rows[7]; rej.RefID != 4 || rej.Detail != "target 1 owned by slow" {
+		t.Errorf("refused cancel = %+v", rej)
+	}
+	if rows[13].EventLine != 3 {
+		t.Errorf("fast's arrival on line %d, want 3", rows[13].EventLine)
+	}
+
+	var buf bytes.Buffer
+	if err := trail.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(want)+1 || strings.Join(records[0], ",") != strings.Join(TrailHeader, ",") {
+		t.Fatalf("csv has %d records, header %v", len(records), records[0])
+	}
+	if r := records[1]; r[7] != "100.0000" || r[2] != "5" {
+		t.Errorf("first record %v: want price 100.0000 decided at 5", r)
+	}
+}
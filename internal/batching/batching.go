@@ -0,0 +1,99 @@
+// Package batching measures whether batching its decisions helps the
+// slow trader. When the slow trader's strategy acts once every N signals
+// (batch_signals), it re-runs the scenario with the slow trader reacting
+// to each signal instead, everything else equal, and sets the slow
+// trader's outcomes and its gap to the fast trader side by side
+package batching
+
+import (
+	"path/filepath"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+// Row is one metric of the slow trader, batched and reactive, and the
+// fast-minus-slow gap in each run
+type Row struct {
+	Metric      string  `json:"metric"`
+	Batched     float64 `json:"batched"`
+	Reactive    float64 `json:"reactive"`
+	GapBatched  float64 `json:"gap_batched"`
+	GapReactive float64 `json:"gap_reactive"`
+	Count       bool    `json:"count,omitempty"` // a whole number
+}
+
+// Comparison is the configured, batched run against the reactive one
+type Comparison struct {
+	Batch int   `json:"batch_signals"`
+	Rows  []Row `json:"rows"`
+}
+
+// metric reads one value off a trader's metrics
+type metric struct {
+	name  string
+	count bool
+	get   func(*metrics.TraderMetrics) float64
+}
+
+var compared = []metric{
+	{"Orders Sent", true, func(m *metrics.TraderMetrics) float64 { return float64(m.OrdersSent) }},
+	{"Fills", true, func(m *metrics.TraderMetrics) float64 { return float64(m.TotalFills) }},
+	{"Fill Rate (%)", false, func(m *metrics.TraderMetrics) float64 { return m.FillRate * 100 }},
+	{"Slippage (bps)", false, func(m *metrics.TraderMetrics) float64 { return m.SlippageBps }},
+	{"Adverse Selection (bps)", false, func(m *metrics.TraderMetrics) float64 { return m.AdverseSelectionBps }},
+	{"Avg Time-to-Fill (ms)", false, func(m *metrics.TraderMetrics) float64 { return m.AvgTimeToFillMs }},
+	{"Avg Queue Pos (placement)", false, func(m *metrics.TraderMetrics) float64 { return m.AvgQueuePosPlace }},
+	{"Messages per Trade", false, func(m *metrics.TraderMetrics) float64 { return m.MessagesPerTrade }},
+	{"Net PnL", false, func(m *metrics.TraderMetrics) float64 { return m.NetPnL }},
+}
+
+// Applies reports whether cfg's slow trader batches its decisions
+func Applies(cfg *scenario.Config) bool {
+	return cfg.SlowTrader.Strategy.BatchSignals > 1
+}
+
+// Run compares actual, the metrics of the configured run, with a run in
+// which the slow trader reacts to every signal. That run writes under dir
+func Run(cfg *scenario.Config, actual map[string]*metrics.TraderMetrics, dir string) (*Comparison, error) {
+	c := *cfg
+	c.SlowTrader.Strategy.BatchSignals = 0
+	reactive, err := runVariant(&c, filepath.Join(dir, "reactive"))
+	if err != nil {
+		return nil, err
+	}
+
+	fastID, slowID := cfg.FastTrader.ID, cfg.SlowTrader.ID
+	out := &Comparison{Batch: cfg.SlowTrader.Strategy.BatchSignals}
+	for _, mt := range compared {
+		value := func(run map[string]*metrics.TraderMetrics, id string) float64 {
+			if m := run[id]; m != nil {
+				return mt.get(m)
+			}
+			return 0
+		}
+		batched, react := value(actual, slowID), value(reactive, slowID)
+		out.Rows = append(out.Rows, Row{
+			Metric:      mt.name,
+			Batched:     batched,
+			Reactive:    react,
+			GapBatched:  value(actual, fastID) - batched,
+			GapReactive: value(reactive, fastID) - react,
+			Count:       mt.count,
+		})
+	}
+	return out, nil
+}
+
+func runVariant(cfg *scenario.Config, dir string) (map[string]*metrics.TraderMetrics, error) {
+	runner, err := sim.NewRunner(cfg, dir)
+	if err != nil {
+		return nil, err
+	}
+	result, err := runner.Run()
+	if err != nil {
+		return nil, err
+	}
+	return metrics.ComputeFromLog(result.LogPath, cfg.Scale(), cfg.Metrics)
+}
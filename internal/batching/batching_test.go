@@ -0,0 +1,40 @@
+package batching
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+func TestBatchedAgainstReactive(t *testing.T) {
+	cfg := scenario.GetConfig("calm", 7)
+	cfg.Duration = latency.MsToNs(2_000)
+	if Applies(cfg) {
+		t.Fatal("reactive config applies")
+	}
+	cfg.SlowTrader.Strategy.BatchSignals = 4
+	if !Applies(cfg) {
+		t.Fatal("batching config does not apply")
+	}
+	dir := t.TempDir()
+	actual, err := runVariant(cfg, filepath.Join(dir, "batched"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmp, err := Run(cfg, actual, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmp.Batch != 4 || len(cmp.Rows) != len(compared) {
+		t.Fatalf("batch %d, %d rows", cmp.Batch, len(cmp.Rows))
+	}
+	// Orders sent: acting on a quarter of the signals sends fewer
+	if sent := cmp.Rows[0]; sent.Batched >= sent.Reactive {
+		t.Errorf("batched slow trader sent %v orders, reactive %v", sent.Batched, sent.Reactive)
+	}
+	if cfg.SlowTrader.Strategy.BatchSignals != 4 {
+		t.Error("Run changed the config")
+	}
+}
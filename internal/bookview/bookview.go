@@ -0,0 +1,262 @@
+// Package bookview rebuilds the book at any moment of a run and prints
+// it level by level, each order in its queue with its owner, for working
+// through a contested fill. Replaying a long log from the start for every
+// look is slow, so the first look writes a checkpoint index beside the
+// log: the resting book every interval, with the byte offset in the log
+// to resume from. Later looks start from the last checkpoint at or before
+// the moment and replay only the rest
+package bookview
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/clip"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+)
+
+// DefaultIntervalNs is the spacing of checkpoints when none is given
+const DefaultIntervalNs = int64(100_000_000)
+
+// Index is a log's checkpoints, oldest first. It belongs to the log it
+// was built from only while the log's size is unchanged
+type Index struct {
+	LogSize     int64        `json:"log_size"`
+	IntervalNs  int64        `json:"interval_ns"`
+	Checkpoints []Checkpoint `json:"checkpoints"`
+}
+
+// Checkpoint is the book after every event timestamped before
+// TimestampNs, a multiple of the interval, and where the log resumes
+type Checkpoint struct {
+	TimestampNs int64          `json:"timestamp_ns"`
+	Offset      int64          `json:"offset"` // byte offset of the next line
+	Line        int            `json:"line"`   // lines before it
+	LastTrade   *domain.Trade  `json:"last_trade,omitempty"`
+	Orders      []restingEntry `json:"orders"`
+}
+
+// restingEntry is a resting order as a checkpoint keeps it, in time
+// priority within its level
+type restingEntry struct {
+	ID     uint64      `json:"id"`
+	Trader string      `json:"trader"`
+	Side   domain.Side `json:"side"`
+	Price  int64       `json:"price"`
+	Qty    int64       `json:"qty"`
+}
+
+// keep is what a checkpoint keeps of a resting order
+func keep(o domain.Order) restingEntry {
+	return restingEntry{ID: o.ID, Trader: o.TraderID, Side: o.Side, Price: o.Price, Qty: o.Qty}
+}
+
+// order is the resting limit order an entry stands for
+func (e restingEntry) order(queuePos int) domain.Order {
+	return domain.Order{ID: e.ID, TraderID: e.Trader, Side: e.Side, Type: domain.LimitOrder, Price: e.Price,
+		Qty: e.Qty, RemainingQty: e.Qty, QueuePos: queuePos}
+}
+
+// IndexPath is where a log's checkpoint index is kept: beside it, named
+// after it
+func IndexPath(logPath string) string {
+	return strings.TrimSuffix(logPath, filepath.Ext(logPath)) + "_book_index.json"
+}
+
+// LoadIndex reads a log's checkpoint index. It returns nil, with no
+// error, if there is none or it is stale: built at another interval or
+// from a log of another size
+func LoadIndex(logPath string, intervalNs int64) (*Index, error) {
+	info, err := os.Stat(logPath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(IndexPath(logPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, nil
+	}
+	if idx.LogSize != info.Size() || idx.IntervalNs != intervalNs {
+		return nil, nil
+	}
+	return &idx, nil
+}
+
+// BuildIndex replays the whole log, checkpointing the book every
+// intervalNs, and saves the index beside it
+func BuildIndex(logPath string, intervalNs int64) (*Index, error) {
+	if intervalNs <= 0 {
+		return nil, errors.New("checkpoint interval must be positive")
+	}
+	if err := requireOrders(logPath); err != nil {
+		return nil, err
+	}
+	idx := &Index{IntervalNs: intervalNs}
+	r := &replay{book: clip.NewBook()}
+	next := int64(0)
+	err := r.run(logPath, 0, func(e *domain.Event) bool {
+		if e.Timestamp >= next {
+			at := e.Timestamp / intervalNs * intervalNs
+			idx.Checkpoints = append(idx.Checkpoints, r.checkpoint(at))
+			next = at + intervalNs
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	idx.LogSize = r.offset
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(IndexPath(logPath), data, 0o644); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Snapshot is the book at a moment: every event at or before it applied
+type Snapshot struct {
+	AtNs      int64
+	Line      int // the last log line applied
+	LastTrade *domain.Trade
+	Orders    []domain.Order // as a checkpoint keeps them; bids best first, then asks, each level in time priority
+	Resumed   int64          // the checkpoint the replay started from
+}
+
+// At rebuilds the book at atNs, from the log's checkpoint index at
+// intervalNs (building it first if it is missing or stale)
+func At(logPath string, atNs, intervalNs int64) (*Snapshot, error) {
+	if atNs < 0 {
+		return nil, errors.New("time must not be negative")
+	}
+	idx, err := LoadIndex(logPath, intervalNs)
+	if err != nil {
+		return nil, err
+	}
+	if idx == nil {
+		if idx, err = BuildIndex(logPath, intervalNs); err != nil {
+			return nil, err
+		}
+	}
+
+	r := &replay{book: clip.NewBook()}
+	snap := &Snapshot{AtNs: atNs}
+	i := sort.Search(len(idx.Checkpoints), func(i int) bool { return idx.Checkpoints[i].TimestampNs > atNs }) - 1
+	if i >= 0 {
+		cp := idx.Checkpoints[i]
+		r.restore(cp)
+		snap.Resumed = cp.TimestampNs
+	}
+	err = r.run(logPath, r.offset, func(e *domain.Event) bool { return e.Timestamp <= atNs })
+	if err != nil {
+		return nil, err
+	}
+	snap.Line, snap.LastTrade = r.line, r.lastTrade
+	for _, o := range r.book.Resting() {
+		snap.Orders = append(snap.Orders, keep(o).order(o.QueuePos))
+	}
+	return snap, nil
+}
+
+// requireOrders checks the log carries the orders the book is rebuilt from
+func requireOrders(logPath string) error {
+	r, err := eventlog.NewReader(logPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return r.Require(eventlog.LevelOrders, "book")
+}
+
+// replay feeds log lines to a book, tracking where it is in the log
+type replay struct {
+	book      *clip.Book
+	offset    int64 // of the next line
+	line      int   // lines applied
+	lastTrade *domain.Trade
+}
+
+// run applies the log's events from offset on, asking more before each
+// whether to apply it; the first it refuses ends the replay unapplied
+func (r *replay) run(logPath string, offset int64, more func(*domain.Event) bool) error {
+	f, err := os.Open(logPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	r.offset = offset
+	br := bufio.NewReaderSize(f, 1<<20)
+	for {
+		raw, err := br.ReadBytes('\n')
+		if len(raw) > 0 {
+			line := bytes.TrimSpace(raw)
+			if len(line) > 0 {
+				e, derr := eventlog.DecodeLine(line)
+				if derr != nil {
+					return fmt.Errorf("book line %d: %w", r.line+1, derr)
+				}
+				if !more(e) {
+					return nil
+				}
+				r.apply(e)
+			}
+			r.offset += int64(len(raw))
+			r.line++
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (r *replay) apply(e *domain.Event) {
+	r.book.Process(e)
+	if e.Type == domain.EventTradeExecuted {
+		if tr := e.Trade(); tr != nil {
+			t := *tr
+			r.lastTrade = &t
+		}
+	}
+}
+
+func (r *replay) checkpoint(atNs int64) Checkpoint {
+	cp := Checkpoint{TimestampNs: atNs, Offset: r.offset, Line: r.line, LastTrade: r.lastTrade}
+	for _, o := range r.book.Resting() {
+		cp.Orders = append(cp.Orders, keep(o))
+	}
+	return cp
+}
+
+// restore rebuilds the book a checkpoint holds: its orders, accepted in
+// time priority, queue exactly as they did
+func (r *replay) restore(cp Checkpoint) {
+	r.book = clip.NewBook()
+	for _, o := range cp.Orders {
+		order := o.order(0)
+		r.book.Process(&domain.Event{Timestamp: cp.TimestampNs, Type: domain.EventOrderAccepted, Payload: &order})
+	}
+	r.offset, r.line, r.lastTrade = cp.Offset, cp.Line, cp.LastTrade
+}
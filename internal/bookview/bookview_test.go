@@ -0,0 +1,90 @@
+package bookview
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+// TestResumeMatchesFullReplay checks the book rebuilt from a checkpoint
+// is the one a replay from the start gives, and that the index is built
+// once and then reused
+func TestResumeMatchesFullReplay(t *testing.T) {
+	cfg := scenario.DefaultCalm(42)
+	cfg.Duration = latency.MsToNs(1_000)
+	r, err := sim.NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interval := latency.MsToNs(50)
+	for _, at := range []int64{0, latency.MsToNs(50), 123_456_789, latency.MsToNs(999), latency.MsToNs(5_000)} {
+		snap, err := At(result.LogPath, at, interval)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// One checkpoint, at 0, is a replay from the start
+		full, err := At(result.LogPath, at, latency.MsToNs(10_000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(snap.Orders, full.Orders) || snap.Line != full.Line || !reflect.DeepEqual(snap.LastTrade, full.LastTrade) {
+			t.Errorf("t=%d: resuming from %d gave another book than a full replay", at, snap.Resumed)
+		}
+		if at > interval && snap.Resumed == 0 {
+			t.Errorf("t=%d: replayed from the start", at)
+		}
+	}
+
+	idx, err := LoadIndex(result.LogPath, latency.MsToNs(10_000))
+	if err != nil || idx == nil || len(idx.Checkpoints) != 1 {
+		t.Fatalf("index not kept: %v", err)
+	}
+	if idx, _ := LoadIndex(result.LogPath, interval); idx != nil {
+		t.Error("an index at another interval was reused")
+	}
+	f, err := os.OpenFile(result.LogPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("\n")
+	f.Close()
+	if idx, _ := LoadIndex(result.LogPath, latency.MsToNs(10_000)); idx != nil {
+		t.Error("an index for a changed log was reused")
+	}
+}
+
+func TestRender(t *testing.T) {
+	order := func(id uint64, trader string, side domain.Side, price float64, qty int64) domain.Order {
+		return domain.Order{ID: id, TraderID: trader, Side: side, Price: domain.FloatToPrice(price), Qty: qty}
+	}
+	snap := &Snapshot{AtNs: 2_350_000_000, Line: 42, Orders: []domain.Order{
+		order(1, "background", domain.Buy, 99.99, 4), order(7, "slow", domain.Buy, 99.99, 5),
+		order(2, "background", domain.Buy, 99.98, 9),
+		order(3, "fast", domain.Sell, 100.01, 5), order(4, "background", domain.Sell, 100.02, 6),
+	}}
+	var out bytes.Buffer
+	if err := snap.Render(&out, domain.DefaultScale, 1); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"ASK 100.0100       5 qty   1 orders | [fast #3 5]",
+		"--- spread 0.0200, mid 100.0000 ---",
+		"BID  99.9900       9 qty   2 orders | #1 4 · [slow #7 5]",
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if got := lines[len(lines)-3:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,95 @@
+package bookview
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// level is one price's queue
+type level struct {
+	price  int64
+	orders []domain.Order
+}
+
+func (l *level) qty() int64 {
+	var q int64
+	for _, o := range l.orders {
+		q += o.Qty
+	}
+	return q
+}
+
+// levels groups the snapshot's orders by side and price, best first
+func (s *Snapshot) levels(side domain.Side) []*level {
+	var out []*level
+	for _, o := range s.Orders {
+		if o.Side != side {
+			continue
+		}
+		if n := len(out); n == 0 || out[n-1].price != o.Price {
+			out = append(out, &level{price: o.Price})
+		}
+		out[len(out)-1].orders = append(out[len(out)-1].orders, o)
+	}
+	return out
+}
+
+// Render prints the book: asks above bids, each side's depth best levels
+// (0 for all) nearest the spread, and each level's queue front first.
+// Traders' orders are bracketed so they stand out from background flow
+func (s *Snapshot) Render(w io.Writer, scale domain.Scale, depth int) error {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Book at t=%.9fs (%d ns), through log line %d\n", float64(s.AtNs)/1e9, s.AtNs, s.Line))
+	if t := s.LastTrade; t != nil {
+		sb.WriteString(fmt.Sprintf("Last trade: %d @ %s, %s buying from %s, at t=%.9fs\n",
+			t.Qty, scale.Format(t.Price), t.BuyTrader, t.SellTrader, float64(t.Timestamp)/1e9))
+	}
+	sb.WriteString("\n")
+
+	bids, asks := s.levels(domain.Buy), s.levels(domain.Sell)
+	if depth > 0 {
+		bids, asks = bids[:min(depth, len(bids))], asks[:min(depth, len(asks))]
+	}
+	width := len(scale.Format(0))
+	for _, l := range append(bids, asks...) {
+		width = max(width, len(scale.Format(l.price)))
+	}
+	row := func(label string, l *level) {
+		sb.WriteString(fmt.Sprintf("%s %*s  %6d qty %3d orders | ", label, width, scale.Format(l.price), l.qty(), len(l.orders)))
+		for i, o := range l.orders {
+			if i > 0 {
+				sb.WriteString(" · ")
+			}
+			if domain.IsBackground(o.TraderID) {
+				sb.WriteString(fmt.Sprintf("#%d %d", o.ID, o.Qty))
+			} else {
+				sb.WriteString(fmt.Sprintf("[%s #%d %d]", o.TraderID, o.ID, o.Qty))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	if len(asks) == 0 {
+		sb.WriteString("ASK (none)\n")
+	}
+	for i := len(asks) - 1; i >= 0; i-- {
+		row("ASK", asks[i])
+	}
+	switch {
+	case len(bids) > 0 && len(asks) > 0:
+		bid, ask := bids[0].price, asks[0].price
+		sb.WriteString(fmt.Sprintf("--- spread %s, mid %s ---\n", scale.Format(ask-bid), scale.Format((bid+ask)/2)))
+	default:
+		sb.WriteString("--- one-sided ---\n")
+	}
+	for _, l := range bids {
+		row("BID", l)
+	}
+	if len(bids) == 0 {
+		sb.WriteString("BID (none)\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,354 @@
+// Package calibrate tunes the background flow to look like a real symbol.
+// Given target market statistics (quoted spread, trades per second, depth
+// at the touch, volatility), it searches the generator's order rate,
+// cancel rate, market order ratio and depth per level for the config
+// whose run comes closest. Every candidate runs with the same seed, so
+// two candidates differ only in their parameters
+package calibrate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+// Stat is a market statistic a target can be set for
+type Stat struct {
+	Key  string
+	Name string
+	get  func(*Measured) float64
+}
+
+// Stats are the statistics calibration matches, in report order
+var Stats = []Stat{
+	{"spread_bps", "Quoted Spread (bps)", func(m *Measured) float64 { return m.SpreadBps }},
+	{"trades_per_sec", "Trades / s", func(m *Measured) float64 { return m.TradesPerSec }},
+	{"top_depth", "Depth at Touch", func(m *Measured) float64 { return m.TopDepth }},
+	{"volatility_bps", "Volatility (bps / √s)", func(m *Measured) float64 { return m.VolatilityBps }},
+}
+
+// Targets maps stat keys to the values to match
+type Targets map[string]float64
+
+// ParseTargets reads "spread_bps=3,trades_per_sec=20"; at least one
+// target is required and each must be positive
+func ParseTargets(spec string) (Targets, error) {
+	t := Targets{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("target %q is not key=value", part)
+		}
+		if stat(k) == nil {
+			return nil, fmt.Errorf("unknown target %q (%s)", k, strings.Join(keys(), ", "))
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("target %s must be a positive number, got %q", k, v)
+		}
+		t[k] = f
+	}
+	if len(t) == 0 {
+		return nil, fmt.Errorf("no targets given (%s)", strings.Join(keys(), ", "))
+	}
+	return t, nil
+}
+
+func stat(key string) *Stat {
+	for i := range Stats {
+		if Stats[i].Key == key {
+			return &Stats[i]
+		}
+	}
+	return nil
+}
+
+func keys() []string {
+	out := make([]string, len(Stats))
+	for i, s := range Stats {
+		out[i] = s.Key
+	}
+	return out
+}
+
+// Measured is a run's market statistics
+type Measured struct {
+	SpreadBps     float64 `json:"spread_bps"`     // time-weighted over the two-sided book
+	TradesPerSec  float64 `json:"trades_per_sec"` // all trades, traders' included
+	TopDepth      float64 `json:"top_depth"`      // bid qty + ask qty, time-weighted
+	VolatilityBps float64 `json:"volatility_bps"` // standard deviation of mid returns per √s
+}
+
+// Get returns the measured value of the stat with key
+func (m *Measured) Get(key string) float64 {
+	if s := stat(key); s != nil {
+		return s.get(m)
+	}
+	return 0
+}
+
+// volSampleNs is how often the mid is sampled for volatility. Returns are
+// scaled from it to one second by √(1s / volSampleNs)
+const volSampleNs = int64(100_000_000)
+
+// Measure reads a run's market statistics from its log, which must
+// record BBO updates
+func Measure(logPath string, durationNs int64) (*Measured, error) {
+	q, err := metrics.ComputeMarketQuality(logPath)
+	if err != nil {
+		return nil, err
+	}
+	m := &Measured{SpreadBps: q.AvgSpreadBps, TopDepth: q.AvgTopDepth}
+	if durationNs > 0 {
+		m.TradesPerSec = float64(q.Trades) / (float64(durationNs) / 1e9)
+	}
+	if m.VolatilityBps, err = volatility(logPath); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// volatility samples the mid every volSampleNs, as it stood before the
+// first event at or after the sample, and scales the standard deviation
+// of its log returns to one second
+func volatility(logPath string) (float64, error) {
+	r, err := eventlog.NewReader(logPath)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	var mids []float64
+	var mid int64
+	next := volSampleNs
+	err = r.Each(func(e *domain.Event) error {
+		for ; e.Timestamp >= next; next += volSampleNs {
+			if mid > 0 {
+				mids = append(mids, float64(mid))
+			}
+		}
+		if e.Type == domain.EventBBOUpdate {
+			if b := e.BBO(); b != nil && b.MidPrice > 0 {
+				mid = b.MidPrice
+			}
+		}
+		return nil
+	})
+	if err != nil || len(mids) < 3 {
+		return 0, err
+	}
+	var sum, sq float64
+	n := float64(len(mids) - 1)
+	for i := 1; i < len(mids); i++ {
+		ret := math.Log(mids[i] / mids[i-1])
+		sum += ret
+		sq += ret * ret
+	}
+	variance := (sq - sum*sum/n) / (n - 1)
+	return math.Sqrt(max(variance, 0)) * 10_000 * math.Sqrt(1e9/float64(volSampleNs)), nil
+}
+
+// Loss is how far m is from the targets: the sum of squared log ratios,
+// so missing by a factor of two costs the same on every stat
+func (t Targets) Loss(m *Measured) float64 {
+	var loss float64
+	for _, k := range t.sorted() {
+		got := max(m.Get(k), 1e-6)
+		d := math.Log(got / t[k])
+		loss += d * d
+	}
+	return loss
+}
+
+func (t Targets) sorted() []string {
+	out := make([]string, 0, len(t))
+	for k := range t {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Params are the generator parameters searched
+type Params struct {
+	OrderIntervalNs  int64   `json:"order_interval_ns"`
+	CancelRate       float64 `json:"cancel_rate"`
+	MarketOrderRatio float64 `json:"market_order_ratio"`
+	DepthPerLevel    int64   `json:"depth_per_level"`
+}
+
+// paramsOf reads the searched parameters off cfg
+func paramsOf(cfg *scenario.Config) Params {
+	p := cfg.Scenario
+	return Params{OrderIntervalNs: p.OrderIntervalNs, CancelRate: p.CancelRate,
+		MarketOrderRatio: p.MarketOrderRatio, DepthPerLevel: p.DepthPerLevel}
+}
+
+// Apply sets the parameters on a copy of cfg
+func (p Params) Apply(cfg *scenario.Config) *scenario.Config {
+	c := *cfg
+	c.Scenario.OrderIntervalNs = p.OrderIntervalNs
+	c.Scenario.CancelRate = p.CancelRate
+	c.Scenario.MarketOrderRatio = p.MarketOrderRatio
+	c.Scenario.DepthPerLevel = p.DepthPerLevel
+	return &c
+}
+
+// Search bounds. Market and cancel shares leave room for limit orders
+const (
+	minIntervalNs = int64(100_000)
+	maxIntervalNs = int64(1_000_000_000)
+	maxCancelRate = 0.9
+	minMarket     = 0.01
+	maxFlowShare  = 0.95 // market + cancel
+	maxDepth      = 1_000
+)
+
+// dim is one searched parameter: how to step it and clamp it. Intervals
+// step by factors of two, rounded to the microsecond, and shares by tenths,
+// each scaled by the round's step size
+type dim struct {
+	name string
+	step func(p Params, dir int, size float64) Params
+}
+
+var dims = []dim{
+	{"order_interval", func(p Params, dir int, size float64) Params {
+		f := math.Pow(2, size*float64(dir))
+		us := int64(math.Round(float64(p.OrderIntervalNs) * f / 1_000))
+		p.OrderIntervalNs = min(max(us*1_000, minIntervalNs), maxIntervalNs)
+		return p
+	}},
+	{"cancel_rate", func(p Params, dir int, size float64) Params {
+		p.CancelRate = min(max(p.CancelRate+0.1*size*float64(dir), 0), maxCancelRate, maxFlowShare-p.MarketOrderRatio)
+		return p
+	}},
+	{"market_ratio", func(p Params, dir int, size float64) Params {
+		p.MarketOrderRatio = min(max(p.MarketOrderRatio+0.1*size*float64(dir), minMarket), maxFlowShare-p.CancelRate)
+		return p
+	}},
+	{"depth", func(p Params, dir int, size float64) Params {
+		f := math.Pow(2, size*float64(dir))
+		p.DepthPerLevel = min(max(int64(math.Round(float64(p.DepthPerLevel)*f)), 1), maxDepth)
+		return p
+	}},
+}
+
+// Trial is one evaluated candidate
+type Trial struct {
+	Params   Params    `json:"params"`
+	Measured *Measured `json:"measured"`
+	Loss     float64   `json:"loss"`
+}
+
+// Result is a finished calibration: the best candidate and the config it
+// makes, and every trial in the order run
+type Result struct {
+	Targets Targets          `json:"targets"`
+	Start   Trial            `json:"start"`
+	Best    Trial            `json:"best"`
+	Config  *scenario.Config `json:"-"`
+	Trials  []Trial          `json:"trials"`
+}
+
+// Calibrate searches from cfg's parameters for those whose run best
+// matches the targets, running at most budget candidates. It is a
+// compass search: each round steps every parameter up and down, keeps
+// any step that lowers the loss, and halves the step size after a round
+// that finds none. Runs are written under a temporary directory that is
+// removed afterwards
+func Calibrate(cfg *scenario.Config, targets Targets, budget int, progress func(n int, t Trial)) (*Result, error) {
+	if budget < 1 {
+		return nil, errors.New("budget must be at least 1")
+	}
+	if cfg.Background != nil {
+		return nil, errors.New("calibration tunes the scenario's single background trader; this config splits it into participants")
+	}
+	dir, err := os.MkdirTemp("", "fairsim-calibrate-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	res := &Result{Targets: targets}
+	tried := map[Params]*Trial{}
+	eval := func(p Params) (*Trial, error) {
+		if t, ok := tried[p]; ok {
+			return t, nil
+		}
+		c := p.Apply(cfg)
+		runner, err := sim.NewRunner(c, dir)
+		if err != nil {
+			return nil, err
+		}
+		run, err := runner.Run()
+		if err != nil {
+			return nil, err
+		}
+		m, err := Measure(run.LogPath, c.Duration)
+		if err != nil {
+			return nil, err
+		}
+		t := Trial{Params: p, Measured: m, Loss: targets.Loss(m)}
+		res.Trials = append(res.Trials, t)
+		tried[p] = &t
+		if progress != nil {
+			progress(len(res.Trials), t)
+		}
+		return &t, nil
+	}
+
+	best, err := eval(paramsOf(cfg))
+	if err != nil {
+		return nil, err
+	}
+	res.Start = *best
+	for size := 1.0; size >= 1.0/16 && len(res.Trials) < budget; {
+		improved := false
+		for _, d := range dims {
+			for _, sign := range []int{1, -1} {
+				if len(res.Trials) >= budget {
+					break
+				}
+				p := d.step(best.Params, sign, size)
+				if p == best.Params {
+					continue
+				}
+				t, err := eval(p)
+				if err != nil {
+					return nil, fmt.Errorf("%s %+d: %w", d.name, sign, err)
+				}
+				if t.Loss < best.Loss {
+					best, improved = t, true
+					break
+				}
+			}
+		}
+		if !improved {
+			size /= 2
+		}
+	}
+	res.Best = *best
+	res.Config = best.Params.Apply(cfg)
+	return res, nil
+}
+
+// Describe formats the parameters for a report line
+func (p Params) Describe() string {
+	return fmt.Sprintf("order interval %s, cancel rate %.3f, market ratio %.3f, depth per level %d",
+		latency.Format(p.OrderIntervalNs), p.CancelRate, p.MarketOrderRatio, p.DepthPerLevel)
+}
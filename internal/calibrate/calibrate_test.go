@@ -0,0 +1,46 @@
+package calibrate
+
+import (
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+func TestParseTargets(t *testing.T) {
+	got, err := ParseTargets("spread_bps=3, trades_per_sec=20")
+	if err != nil || len(got) != 2 || got["spread_bps"] != 3 || got["trades_per_sec"] != 20 {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	for _, bad := range []string{"", "spread_bps", "spread=3", "top_depth=0", "volatility_bps=-1", "trades_per_sec=x"} {
+		if _, err := ParseTargets(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+// TestCalibrateApproachesTargets asks calm for a wider spread and fewer
+// trades than it has: the search must get closer than the start, keep
+// every candidate's parameters in bounds, and stay within budget
+func TestCalibrateApproachesTargets(t *testing.T) {
+	cfg := scenario.DefaultCalm(42)
+	cfg.Duration = latency.MsToNs(2_000)
+	targets := Targets{"spread_bps": 4, "trades_per_sec": 20}
+	res, err := Calibrate(cfg, targets, 12, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Trials) > 12 || res.Best.Loss >= res.Start.Loss {
+		t.Fatalf("%d trials, loss %.4f from %.4f", len(res.Trials), res.Best.Loss, res.Start.Loss)
+	}
+	for _, tr := range res.Trials {
+		p := tr.Params
+		if p.OrderIntervalNs < minIntervalNs || p.CancelRate < 0 || p.MarketOrderRatio < minMarket ||
+			p.CancelRate+p.MarketOrderRatio > maxFlowShare+1e-9 || p.DepthPerLevel < 1 {
+			t.Fatalf("out of bounds: %+v", p)
+		}
+	}
+	if res.Config.Scenario.OrderIntervalNs != res.Best.Params.OrderIntervalNs || cfg.Scenario.OrderIntervalNs != latency.MsToNs(5) {
+		t.Fatal("calibrated config does not carry the best parameters, or the start config was changed")
+	}
+}
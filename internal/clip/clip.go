@@ -0,0 +1,214 @@
+// Package clip cuts a window out of an event log around one moment. The
+// clip opens with the book as it stood when the window starts, so it is
+// self-contained: a contested fill can be shared, plotted or tested
+// against without the full log
+package clip
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+)
+
+// DefaultWindowNs is the span of a clip when none is given
+const DefaultWindowNs = int64(200_000_000)
+
+// Summary describes a written clip
+type Summary struct {
+	FromNs  int64 // first timestamp covered
+	ToNs    int64 // last timestamp covered
+	Resting int   // orders in the opening snapshot
+	Events  int   // events copied from the log
+}
+
+// Book tracks the resting orders of an event log, fed in log order, the
+// way heatmap rebuilds depth: an accepted limit order rests with what it
+// has left after matching, a trade takes from the passive order, an
+// iceberg refresh sends its order to the back of the queue, and a cancel
+// ack or a session's close takes orders off
+type Book struct {
+	orders map[uint64]*entry
+	next   int
+}
+
+// entry is a resting order and when it joined the book, for FIFO order
+type entry struct {
+	order domain.Order
+	seq   int
+}
+
+// NewBook returns an empty book
+func NewBook() *Book {
+	return &Book{orders: make(map[uint64]*entry)}
+}
+
+// Process applies the next event in log order
+func (b *Book) Process(e *domain.Event) {
+	switch e.Type {
+	case domain.EventOrderAccepted:
+		if o := e.Order(); o.Type == domain.LimitOrder && o.RemainingQty > 0 && !o.Immediate() {
+			b.next++
+			b.orders[o.ID] = &entry{order: *o, seq: b.next}
+		}
+	case domain.EventTradeExecuted:
+		tr := e.Trade()
+		if r := b.orders[tr.PassiveOrderID]; r != nil {
+			if r.order.RemainingQty -= tr.Qty; r.order.RemainingQty <= 0 {
+				delete(b.orders, tr.PassiveOrderID)
+			}
+		}
+	case domain.EventIcebergRefresh:
+		if r := b.orders[e.Order().ID]; r != nil {
+			b.next++
+			r.seq = b.next
+		}
+	case domain.EventOrderCanceled:
+		c := e.Order()
+		if r := b.orders[c.CancelID]; r != nil && c.RemainingQty > 0 {
+			r.order.RemainingQty = c.RemainingQty
+		} else {
+			delete(b.orders, c.CancelID)
+		}
+	case domain.EventSession:
+		if phase, _ := e.Annotation("phase"); phase == domain.PhaseClosed {
+			clear(b.orders)
+		}
+	}
+}
+
+// Resting returns the resting orders: bids best first, then asks best
+// first, each level in time priority. QueuePos is the order's place in
+// its level and Qty its remaining qty, so rebuilding a book from these
+// orders gives the same book
+func (b *Book) Resting() []domain.Order {
+	entries := make([]*entry, 0, len(b.orders))
+	for _, r := range b.orders {
+		entries = append(entries, r)
+	}
+	slices.SortFunc(entries, func(x, y *entry) int {
+		if x.order.Side != y.order.Side {
+			if x.order.Side == domain.Buy {
+				return -1
+			}
+			return 1
+		}
+		if x.order.Price != y.order.Price {
+			if (x.order.Price > y.order.Price) == (x.order.Side == domain.Buy) {
+				return -1
+			}
+			return 1
+		}
+		return x.seq - y.seq
+	})
+	out := make([]domain.Order, len(entries))
+	for i, r := range entries {
+		o := r.order
+		o.Qty = o.RemainingQty
+		o.QueuePos = 1
+		if i > 0 && out[i-1].Side == o.Side && out[i-1].Price == o.Price {
+			o.QueuePos = out[i-1].QueuePos + 1
+		}
+		out[i] = o
+	}
+	return out
+}
+
+// Window returns the span of a clip of windowNs centred on aroundNs,
+// starting no earlier than 0
+func Window(aroundNs, windowNs int64) (from, to int64) {
+	return max(0, aroundNs-windowNs/2), aroundNs + windowNs/2
+}
+
+// Log writes to dst the events of the log at src timestamped within
+// windowNs centred on aroundNs. The clip's SIM_START carries the source's
+// annotations plus clip_around_ns and clip_window_ns. It is followed by
+// the resting book as accepted orders annotated snapshot, then the copied
+// events, then a SIM_END at the window's end unless the log's own falls
+// inside it. The book is rebuilt from accepted orders and cancel acks, so
+// the log must be at LevelOrders
+func Log(src, dst string, aroundNs, windowNs int64) (*Summary, error) {
+	if windowNs <= 0 {
+		return nil, errors.New("clip window must be positive")
+	}
+	r, err := eventlog.NewReader(src)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if err := r.Require(eventlog.LevelOrders, "clip"); err != nil {
+		return nil, err
+	}
+
+	from, to := Window(aroundNs, windowNs)
+	book := NewBook()
+	var start *domain.Event
+	var events []*domain.Event
+	var last int64
+	ended := false
+	for line := 1; ; line++ {
+		e, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("clip line %d: %w", line, err)
+		}
+		last = e.Timestamp
+		if e.Type == domain.EventSimStart {
+			start = e
+			continue
+		}
+		if e.Timestamp > to {
+			break
+		}
+		if e.Timestamp < from {
+			book.Process(e)
+			continue
+		}
+		if e.Type == domain.EventSimEnd {
+			ended = true
+		}
+		events = append(events, e)
+	}
+	if from > last {
+		return nil, fmt.Errorf("clip window starts at %d ns, after the log ends at %d ns", from, last)
+	}
+
+	if start == nil {
+		start = &domain.Event{Type: domain.EventSimStart}
+	}
+	start.Timestamp = from
+	start.Annotate("clip_around_ns", strconv.FormatInt(aroundNs, 10))
+	start.Annotate("clip_window_ns", strconv.FormatInt(windowNs, 10))
+	out := []*domain.Event{start}
+	resting := book.Resting()
+	for i := range resting {
+		e := &domain.Event{Timestamp: from, Type: domain.EventOrderAccepted, Payload: &resting[i]}
+		e.Annotate("snapshot", "true")
+		out = append(out, e)
+	}
+	out = append(out, events...)
+	if !ended {
+		out = append(out, &domain.Event{Timestamp: to, Type: domain.EventSimEnd})
+	}
+
+	w, err := eventlog.NewWriter(dst)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range out {
+		if err := w.Write(e); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return &Summary{FromNs: from, ToNs: to, Resting: len(resting), Events: len(events)}, nil
+}
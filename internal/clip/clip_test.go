@@ -0,0 +1,109 @@
+package clip
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+func readLog(t *testing.T, path string) []*domain.Event {
+	t.Helper()
+	r, err := eventlog.NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	events, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// TestClipIsSelfContained cuts a window out of a run and checks the clip
+// passes the schema, and that replaying it alone rebuilds the same book
+// as the full log, both at every depth snapshot and at the window's end
+func TestClipIsSelfContained(t *testing.T) {
+	cfg := scenario.DefaultCalm(42)
+	cfg.Duration = latency.MsToNs(1_000)
+	dir := t.TempDir()
+	r, err := sim.NewRunner(cfg, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "clip.jsonl")
+	sum, err := Log(result.LogPath, out, latency.MsToNs(500), latency.MsToNs(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.FromNs != latency.MsToNs(450) || sum.ToNs != latency.MsToNs(550) || sum.Resting == 0 || sum.Events == 0 {
+		t.Fatalf("summary = %+v", sum)
+	}
+
+	clipped := readLog(t, out)
+	v := eventlog.NewValidator()
+	for _, e := range clipped {
+		if err := v.Check(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if first, last := clipped[0], clipped[len(clipped)-1]; first.Type != domain.EventSimStart || last.Type != domain.EventSimEnd ||
+		first.Timestamp != sum.FromNs || last.Timestamp != sum.ToNs {
+		t.Fatalf("clip runs %s@%d to %s@%d", first.Type, first.Timestamp, last.Type, last.Timestamp)
+	}
+
+	book := NewBook()
+	depths := 0
+	for _, e := range clipped {
+		if d := e.Depth(); e.Type == domain.EventDepth && d != nil {
+			depths++
+			for _, levels := range [][]domain.DepthLevel{d.Bids, d.Asks} {
+				for _, l := range levels {
+					if got := levelQty(book, l.Price); got != l.Qty {
+						t.Errorf("t=%d: %d resting at %d, depth says %d", e.Timestamp, got, l.Price, l.Qty)
+					}
+				}
+			}
+		}
+		book.Process(e)
+	}
+	if depths == 0 {
+		t.Fatal("clip has no depth snapshots to check against")
+	}
+
+	full := NewBook()
+	for _, e := range readLog(t, result.LogPath) {
+		if e.Timestamp > sum.ToNs {
+			break
+		}
+		full.Process(e)
+	}
+	if !slices.Equal(book.Resting(), full.Resting()) {
+		t.Error("clip and full log end the window with different books")
+	}
+
+	if _, err := Log(result.LogPath, out, latency.MsToNs(5_000), latency.MsToNs(100)); err == nil {
+		t.Error("clip past the log's end accepted")
+	}
+}
+
+func levelQty(b *Book, price int64) int64 {
+	var qty int64
+	for _, o := range b.Resting() {
+		if o.Price == price {
+			qty += o.RemainingQty
+		}
+	}
+	return qty
+}
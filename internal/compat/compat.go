@@ -0,0 +1,256 @@
+// Package compat reads the artifacts of runs stored by earlier versions
+// of the simulator. Every change to config.json or metrics.json that an
+// older file would fail or mislead under is a Migration on the raw JSON,
+// applied before it is decoded, so report, baseline and the commands that
+// reread a run keep working across versions. Migrations recognize the
+// old schema by its fields, not by a version number, as runs from before
+// provenance stamps carry none. Upgrade rewrites a run's files in place
+package compat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+// The artifacts migrations apply to
+const (
+	ConfigFile  = "config.json"
+	MetricsFile = "metrics.json"
+)
+
+// Migration upgrades one artifact across one schema change
+type Migration struct {
+	File string // ConfigFile or MetricsFile
+	Name string // what changed, as upgrade reports it
+
+	// Apply rewrites an artifact's top-level object in place and reports
+	// whether it was in the old schema
+	Apply func(obj map[string]json.RawMessage) (bool, error)
+}
+
+// Migrations are every schema change since the first release, oldest
+// first. config.json has only ever gained fields, which an old file
+// reads as the defaults it ran with, so none are for it yet
+var Migrations = []Migration{
+	{
+		File:  MetricsFile,
+		Name:  "avg_time_to_fill_ns renamed avg_time_to_fill_ms; the values were always ms",
+		Apply: eachTrader(renameTimeToFill),
+	},
+	{
+		File:  MetricsFile,
+		Name:  "slippage_benchmark and markouts added; older runs used the decision mid and one 100ms markout",
+		Apply: eachTrader(addMarkouts),
+	},
+}
+
+// traderKeys are the traders' entries in metrics.json
+var traderKeys = []string{"fast", "slow"}
+
+// eachTrader applies a migration of one trader's metrics to each trader
+// in metrics.json
+func eachTrader(apply func(t map[string]json.RawMessage) (bool, error)) func(map[string]json.RawMessage) (bool, error) {
+	return func(obj map[string]json.RawMessage) (bool, error) {
+		changed := false
+		for _, key := range traderKeys {
+			raw, ok := obj[key]
+			if !ok || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+				continue
+			}
+			var t map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &t); err != nil {
+				return false, fmt.Errorf("%s: %w", key, err)
+			}
+			did, err := apply(t)
+			if err != nil {
+				return false, fmt.Errorf("%s: %w", key, err)
+			}
+			if !did {
+				continue
+			}
+			if obj[key], err = json.Marshal(t); err != nil {
+				return false, err
+			}
+			changed = true
+		}
+		return changed, nil
+	}
+}
+
+func renameTimeToFill(t map[string]json.RawMessage) (bool, error) {
+	v, ok := t["avg_time_to_fill_ns"]
+	if !ok {
+		return false, nil
+	}
+	if _, ok := t["avg_time_to_fill_ms"]; !ok {
+		t["avg_time_to_fill_ms"] = v
+	}
+	delete(t, "avg_time_to_fill_ns")
+	return true, nil
+}
+
+func addMarkouts(t map[string]json.RawMessage) (bool, error) {
+	changed := false
+	if _, ok := t["slippage_benchmark"]; !ok {
+		t["slippage_benchmark"], _ = json.Marshal(metrics.BenchDecisionMid)
+		changed = true
+	}
+	if _, ok := t["markouts"]; !ok {
+		var old struct {
+			Move float64 `json:"avg_price_move_after_fill"`
+			Bps  float64 `json:"adverse_selection_bps"`
+		}
+		if err := unmarshalFields(t, &old); err != nil {
+			return false, err
+		}
+		t["markouts"], _ = json.Marshal([]metrics.Markout{{HorizonNs: metrics.DefaultHorizonNs, AvgMove: old.Move, Bps: old.Bps}})
+		changed = true
+	}
+	return changed, nil
+}
+
+// unmarshalFields decodes an object already split into fields
+func unmarshalFields(t map[string]json.RawMessage, v any) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Migrate applies file's migrations to data, returning the upgraded JSON
+// and the names of those it needed. Data already current comes back as is
+func Migrate(file string, data []byte) ([]byte, []string, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", file, err)
+	}
+	var applied []string
+	for _, m := range Migrations {
+		if m.File != file {
+			continue
+		}
+		changed, err := m.Apply(obj)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s: %w", file, m.Name, err)
+		}
+		if changed {
+			applied = append(applied, m.Name)
+		}
+	}
+	if len(applied) == 0 {
+		return data, nil, nil
+	}
+	// Keys come out sorted; fields the old file lacks stay absent rather
+	// than reading as measured zeros
+	out, err := json.MarshalIndent(obj, "", "  ")
+	return out, applied, err
+}
+
+// ReadConfig reads the config.json in runDir, of any version
+func ReadConfig(runDir string) (*scenario.Config, error) {
+	data, err := os.ReadFile(filepath.Join(runDir, ConfigFile))
+	if err != nil {
+		return nil, err
+	}
+	if data, _, err = Migrate(ConfigFile, data); err != nil {
+		return nil, err
+	}
+	cfg := &scenario.Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+	return cfg, nil
+}
+
+// ReadMetrics reads the traders' metrics in runDir's metrics.json, of any
+// version, by their entry: "fast" and "slow"
+func ReadMetrics(runDir string) (map[string]*metrics.TraderMetrics, error) {
+	data, err := os.ReadFile(filepath.Join(runDir, MetricsFile))
+	if err != nil {
+		return nil, err
+	}
+	if data, _, err = Migrate(MetricsFile, data); err != nil {
+		return nil, err
+	}
+	var saved map[string]json.RawMessage
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("%s: %w", MetricsFile, err)
+	}
+	out := make(map[string]*metrics.TraderMetrics, len(traderKeys))
+	for _, key := range traderKeys {
+		raw, ok := saved[key]
+		if !ok {
+			continue
+		}
+		var m *metrics.TraderMetrics
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", MetricsFile, key, err)
+		}
+		if m != nil {
+			out[key] = m
+		}
+	}
+	return out, nil
+}
+
+// Upgrade rewrites the config.json and metrics.json in runDir that are in
+// an old schema, keeping each original beside it with a .bak suffix. It
+// returns the migrations applied, by file; files already current, or
+// missing, are left alone
+func Upgrade(runDir string, dryRun bool) (map[string][]string, error) {
+	done := make(map[string][]string)
+	for _, file := range []string{ConfigFile, MetricsFile} {
+		path := filepath.Join(runDir, file)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out, applied, err := Migrate(file, data)
+		if err != nil {
+			return nil, err
+		}
+		if len(applied) == 0 {
+			continue
+		}
+		done[file] = applied
+		if dryRun {
+			continue
+		}
+		if err := os.WriteFile(path+".bak", data, 0644); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, out, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return done, nil
+}
+
+// Runs lists the run directories under root, those holding a config.json,
+// by name
+func Runs(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, e := range entries {
+		dir := filepath.Join(root, e.Name())
+		if _, err := os.Stat(filepath.Join(dir, ConfigFile)); e.IsDir() && err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
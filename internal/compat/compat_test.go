@@ -0,0 +1,100 @@
+package compat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+// oldMetrics is a trader's metrics.json entry as the first release wrote
+// it
+const oldMetrics = `{
+  "trader_id": "%s", "orders_sent": 40, "total_fills": 12, "fill_rate": 0.3,
+  "avg_slippage": 0.01, "slippage_bps": 1.2,
+  "avg_time_to_fill_ns": 37.5, "time_to_fill_dist": [12.5, 50],
+  "avg_price_move_after_fill": 0.002, "adverse_selection_bps": 0.2
+}`
+
+func writeOldRun(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	cfg, _ := json.Marshal(scenario.DefaultCalm(7))
+	saved := fmt.Sprintf(`{"fast": %s, "slow": %s}`, fmt.Sprintf(oldMetrics, "fast"), fmt.Sprintf(oldMetrics, "slow"))
+	for file, data := range map[string]string{ConfigFile: string(cfg), MetricsFile: saved} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadOldMetrics(t *testing.T) {
+	dir := writeOldRun(t)
+	m, err := ReadMetrics(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast := m["fast"]
+	if fast == nil || m["slow"] == nil {
+		t.Fatalf("traders %v", m)
+	}
+	if fast.AvgTimeToFillMs != 37.5 || fast.FillRate != 0.3 {
+		t.Errorf("time-to-fill %g, fill rate %g", fast.AvgTimeToFillMs, fast.FillRate)
+	}
+	if fast.SlippageBenchmark != metrics.BenchDecisionMid || len(fast.Markouts) != 1 ||
+		fast.Markouts[0] != (metrics.Markout{HorizonNs: metrics.DefaultHorizonNs, AvgMove: 0.002, Bps: 0.2}) {
+		t.Errorf("benchmark %q, markouts %+v", fast.SlippageBenchmark, fast.Markouts)
+	}
+	cfg, err := ReadConfig(dir)
+	if err != nil || cfg.Name != "calm" || cfg.Seed != 7 {
+		t.Fatalf("config %+v: %v", cfg, err)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	dir := writeOldRun(t)
+	original, _ := os.ReadFile(filepath.Join(dir, MetricsFile))
+
+	done, err := Upgrade(dir, true)
+	if err != nil || len(done[MetricsFile]) != 2 || len(done[ConfigFile]) != 0 {
+		t.Fatalf("dry run %v: %v", done, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, MetricsFile)); !bytes.Equal(data, original) {
+		t.Fatal("dry run rewrote metrics.json")
+	}
+
+	if _, err := Upgrade(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if backup, _ := os.ReadFile(filepath.Join(dir, MetricsFile+".bak")); !bytes.Equal(backup, original) {
+		t.Error("original not kept")
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, MetricsFile))
+	if bytes.Contains(data, []byte("avg_time_to_fill_ns")) || !bytes.Contains(data, []byte(`"avg_time_to_fill_ms": 37.5`)) {
+		t.Errorf("upgraded metrics.json:\n%s", data)
+	}
+	// Fields the old run never measured stay absent
+	if bytes.Contains(data, []byte("requotes")) {
+		t.Error("upgrade added fields the run did not record")
+	}
+	if done, err := Upgrade(dir, false); err != nil || len(done) != 0 {
+		t.Errorf("second upgrade %v: %v", done, err)
+	}
+}
+
+// TestCurrentUntouched checks the migrations leave what this version
+// writes alone
+func TestCurrentUntouched(t *testing.T) {
+	m := &metrics.TraderMetrics{TraderID: "fast", SlippageBenchmark: metrics.BenchDecisionMid}
+	data, _ := json.MarshalIndent(map[string]any{"fast": m, "slow": m}, "", "  ")
+	out, applied, err := Migrate(MetricsFile, data)
+	if err != nil || len(applied) != 0 || !bytes.Equal(out, data) {
+		t.Errorf("applied %v: %v", applied, err)
+	}
+}
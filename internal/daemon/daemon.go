@@ -0,0 +1,277 @@
+// Package daemon serves simulation runs over a local socket, so a sweep
+// of many short runs pays process startup, pool allocation and GC heap
+// growth once rather than per run. The protocol is one JSON Request per
+// line in, one JSON Response per line out; a connection's requests run in
+// order, and separate connections run concurrently
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/report"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+// maxRequest bounds a request line; an inline config is a few KB
+const maxRequest = 1 << 20
+
+// Request is one run. Config, if set, is a config file's contents;
+// otherwise Scenario names a built-in one. Seed and Duration override
+// either. Out is the parent of the run directory, by default the
+// server's RunsDir; requests running at once must not share a run
+// directory
+type Request struct {
+	ID              string          `json:"id,omitempty"` // echoed in the response
+	Scenario        string          `json:"scenario,omitempty"`
+	Config          json.RawMessage `json:"config,omitempty"`
+	Seed            *int64          `json:"seed,omitempty"`
+	Duration        string          `json:"duration,omitempty"`
+	Out             string          `json:"out,omitempty"`
+	Report          bool            `json:"report,omitempty"` // also write report.md
+	CheckInvariants bool            `json:"check_invariants,omitempty"`
+}
+
+// Response is a run's outcome, or Error if it failed
+type Response struct {
+	ID              string                            `json:"id,omitempty"`
+	Error           string                            `json:"error,omitempty"`
+	RunID           string                            `json:"run_id,omitempty"`
+	RunDir          string                            `json:"run_dir,omitempty"`
+	LogHash         string                            `json:"log_hash,omitempty"`
+	EventCount      uint64                            `json:"event_count,omitempty"`
+	TradeCount      int                               `json:"trade_count,omitempty"`
+	AuditViolations int                               `json:"audit_violations,omitempty"`
+	WallNs          int64                             `json:"wall_ns,omitempty"`
+	Metrics         map[string]*metrics.TraderMetrics `json:"metrics,omitempty"`
+}
+
+// Server runs requests
+type Server struct {
+	RunsDir string
+	// Logf, if set, is told of each request served
+	Logf func(format string, args ...any)
+
+	mu    sync.Mutex
+	ln    net.Listener
+	conns map[net.Conn]bool
+	wg    sync.WaitGroup
+}
+
+// NewServer creates a server writing runs under runsDir by default
+func NewServer(runsDir string) *Server {
+	return &Server{RunsDir: runsDir, conns: make(map[net.Conn]bool)}
+}
+
+// Warm runs each built-in scenario briefly and discards the output, so
+// the first real request finds the pools filled and the heap grown
+func (s *Server) Warm() error {
+	dir, err := os.MkdirTemp("", "fairsim-warm-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"calm", "thin", "spike"} {
+		cfg := scenario.GetConfig(name, 1)
+		cfg.Duration = latency.MsToNs(500)
+		runner, err := sim.NewRunner(cfg, dir)
+		if err != nil {
+			return err
+		}
+		if _, err := runner.Run(); err != nil {
+			return fmt.Errorf("warm %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Serve accepts connections on ln until Close
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops accepting, lets in-flight runs finish and closes every
+// connection
+func (s *Server) Close() error {
+	s.mu.Lock()
+	var err error
+	if s.ln != nil {
+		err = s.ln.Close()
+	}
+	for conn := range s.conns {
+		// Unblock readers; a run in progress still writes its response
+		if cr, ok := conn.(interface{ CloseRead() error }); ok {
+			cr.CloseRead()
+		} else {
+			conn.Close()
+		}
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 64*1024), maxRequest)
+	enc := json.NewEncoder(conn)
+	for sc.Scan() {
+		var req Request
+		var resp Response
+		if err := json.Unmarshal(sc.Bytes(), &req); err != nil {
+			resp.Error = fmt.Sprintf("bad request: %v", err)
+		} else {
+			resp = s.Handle(&req)
+		}
+		if err := enc.Encode(&resp); err != nil {
+			return
+		}
+	}
+}
+
+// Handle runs one request
+func (s *Server) Handle(req *Request) Response {
+	resp := Response{ID: req.ID}
+	cfg, err := req.config()
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	out := req.Out
+	if out == "" {
+		out = s.RunsDir
+	}
+
+	runner, err := sim.NewRunner(cfg, out)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	runner.CheckInvariants = req.CheckInvariants
+	runner.Pipeline = true
+	// Requests run concurrently, so none of them is the last run
+	runner.NoLastRun = true
+	result, err := runner.Run()
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	resp.RunID, resp.RunDir, resp.LogHash = result.RunID, result.OutputDir, result.LogHash
+	resp.EventCount, resp.TradeCount = result.EventCount, result.TradeCount
+	resp.AuditViolations, resp.WallNs = result.AuditViolations, int64(result.Duration)
+
+	m, err := metrics.ComputeFromLog(result.LogPath, cfg.Scale(), cfg.Metrics)
+	if err != nil {
+		resp.Error = fmt.Sprintf("metrics: %v", err)
+		return resp
+	}
+	resp.Metrics = m
+	if req.Report {
+		if err := report.NewReport(cfg, m, result.OutputDir).Generate(); err != nil {
+			resp.Error = fmt.Sprintf("report: %v", err)
+		}
+	}
+	if s.Logf != nil {
+		s.Logf("%s %s in %s", result.RunID, result.LogHash[:16], result.Duration)
+	}
+	return resp
+}
+
+// config builds the request's scenario config
+func (req *Request) config() (*scenario.Config, error) {
+	var cfg *scenario.Config
+	switch {
+	case len(req.Config) > 0 && req.Scenario != "":
+		return nil, errors.New("give scenario or config, not both")
+	case len(req.Config) > 0:
+		var err error
+		if cfg, err = scenario.ParseConfig(req.Config); err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+	case req.Scenario != "":
+		if cfg = scenario.GetConfig(req.Scenario, 42); cfg == nil {
+			return nil, fmt.Errorf("unknown scenario %q", req.Scenario)
+		}
+	default:
+		return nil, errors.New("scenario or config is required")
+	}
+	if req.Seed != nil {
+		cfg.Seed = *req.Seed
+	}
+	if req.Duration != "" {
+		d, err := scenario.ParseDuration(req.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("duration: %w", err)
+		}
+		if d == 0 {
+			return nil, errors.New("duration must be positive")
+		}
+		cfg.Duration = d
+	}
+	return cfg, nil
+}
+
+// Client sends requests over one connection
+type Client struct {
+	conn net.Conn
+	dec  *json.Decoder
+	enc  *json.Encoder
+}
+
+// Dial connects to a server's socket
+func Dial(socket string) (*Client, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, dec: json.NewDecoder(conn), enc: json.NewEncoder(conn)}, nil
+}
+
+// Run sends req and waits for its response. A failed run is a Response
+// with Error set, not an error
+func (c *Client) Run(req *Request) (*Response, error) {
+	if err := c.enc.Encode(req); err != nil {
+		return nil, err
+	}
+	var resp Response
+	if err := c.dec.Decode(&resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
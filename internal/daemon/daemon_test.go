@@ -0,0 +1,154 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+// serve starts a server on a fresh socket; socket paths have a short
+// length limit, so it lives under a short temporary directory
+func serve(t *testing.T) (*Server, string) {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "fsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "d.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(t.TempDir())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	t.Cleanup(func() {
+		srv.Close()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+	return srv, socket
+}
+
+// TestServedRunMatchesDirectRun checks a run through the daemon writes
+// the same log as the same run made directly, that a connection carries
+// several requests, and that served runs leave last-run alone
+func TestServedRunMatchesDirectRun(t *testing.T) {
+	_, socket := serve(t)
+	c, err := Dial(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	cfg := scenario.GetConfig("thin", 3)
+	cfg.Duration = latency.MsToNs(500)
+	runner, err := sim.NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct, err := runner.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed := int64(3)
+	for _, req := range []*Request{
+		{ID: "a", Scenario: "thin", Seed: &seed, Duration: "500ms", Out: t.TempDir()},
+		{ID: "b", Config: []byte(`{"name": "thin", "seed": 3, "duration_ns": "500ms"}`), Out: t.TempDir(), Report: true},
+	} {
+		resp, err := c.Run(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error != "" || resp.ID != req.ID {
+			t.Fatalf("request %s: %+v", req.ID, resp)
+		}
+		if resp.LogHash != direct.LogHash {
+			t.Errorf("request %s: hash %s, direct run %s", req.ID, resp.LogHash, direct.LogHash)
+		}
+		if resp.Metrics["fast"] == nil || resp.Metrics["slow"] == nil {
+			t.Errorf("request %s: missing trader metrics", req.ID)
+		}
+		_, err = os.Stat(filepath.Join(resp.RunDir, "report.md"))
+		if req.Report != (err == nil) {
+			t.Errorf("request %s: report.md present = %v", req.ID, err == nil)
+		}
+		if _, err := os.Stat(filepath.Join(req.Out, "last-run")); err == nil {
+			t.Errorf("request %s: served run wrote last-run", req.ID)
+		}
+	}
+}
+
+func TestBadRequestsAnswered(t *testing.T) {
+	_, socket := serve(t)
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := &Client{conn: conn, dec: json.NewDecoder(conn), enc: json.NewEncoder(conn)}
+
+	if _, err := conn.Write([]byte("not json\n")); err != nil {
+		t.Fatal(err)
+	}
+	var resp Response
+	if err := c.dec.Decode(&resp); err != nil || resp.Error == "" {
+		t.Fatalf("malformed line: %+v, %v", resp, err)
+	}
+	for _, req := range []*Request{
+		{},
+		{Scenario: "volcano"},
+		{Scenario: "calm", Config: []byte(`{"name": "calm"}`)},
+		{Scenario: "calm", Duration: "soon"},
+		{Config: []byte(`{"name": "calm", "durration_ns": "1s"}`)},
+	} {
+		resp, err := c.Run(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error == "" {
+			t.Errorf("%+v: no error", req)
+		}
+	}
+}
+
+// TestConnectionsRunConcurrently checks separate connections are served
+// at once and each gets its own answers
+func TestConnectionsRunConcurrently(t *testing.T) {
+	_, socket := serve(t)
+	var wg sync.WaitGroup
+	hashes := make([]string, 4)
+	for i := range hashes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := Dial(socket)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer c.Close()
+			seed := int64(i % 2)
+			resp, err := c.Run(&Request{Scenario: "calm", Seed: &seed, Duration: "300ms", Out: t.TempDir()})
+			if err != nil || resp.Error != "" {
+				t.Errorf("connection %d: %v %+v", i, err, resp)
+				return
+			}
+			hashes[i] = resp.LogHash
+		}()
+	}
+	wg.Wait()
+	if hashes[0] != hashes[2] || hashes[1] != hashes[3] || hashes[0] == hashes[1] {
+		t.Errorf("hashes by seed: %v", hashes)
+	}
+}
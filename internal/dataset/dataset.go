@@ -0,0 +1,283 @@
+// Package dataset turns an event log into an order-level table for
+// modelling fills: one row per trader order, with features known when the
+// trader decided and labels for what happened to the order afterwards
+package dataset
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+)
+
+// DefaultHorizonNs is the fill label's horizon, matching the adverse
+// selection horizon
+const DefaultHorizonNs = int64(100_000_000)
+
+// Header is the CSV header of a dataset. Feature columns come first, then
+// the labels
+var Header = []string{
+	"order_id", "trader_id", "side", "order_type", "price", "qty", "decision_ns", "arrival_ns",
+	"latency_ns", "mid", "spread", "spread_bps", "bid_qty", "ask_qty", "imbalance", "queue_ahead",
+	"signal", "signal_age_ns",
+	"filled_within_horizon", "filled_qty_within_horizon", "filled_qty", "time_to_first_fill_ns",
+	"slippage_bps", "final_state",
+}
+
+// Row is one trader order. The book features are the top of book as of
+// the decision; Signal is the market signal's decayed strength then,
+// before any per-trader delay or noise. Slippage and the fill timings are
+// only meaningful when FilledQty > 0
+type Row struct {
+	OrderID    uint64
+	TraderID   string
+	Side       domain.Side
+	Type       domain.OrderType
+	Price      int64 // 0 for market orders
+	Qty        int64
+	DecisionNs int64
+	ArrivalNs  int64
+	LatencyNs  int64
+	Mid        int64
+	Spread     int64
+	BidQty     int64
+	AskQty     int64
+	Imbalance  float64 // (bid_qty - ask_qty) / (bid_qty + ask_qty), 0 on an empty book
+	QueueAhead int     // orders ahead when it rested; -1 if it never rested
+	Signal     float64
+	SignalAge  int64 // -1 before the first signal
+
+	FilledWithinHorizon bool
+	FilledQtyHorizon    int64
+	FilledQty           int64
+	FirstFillNs         int64 // time from decision to the first fill
+	SlippageBps         float64
+	FinalState          domain.OrderState
+
+	notional int64 // sum of price * qty over fills
+}
+
+type bboAt struct {
+	ts  int64
+	bbo domain.BBO
+}
+
+type signalAt struct {
+	ts  int64
+	sig domain.Signal
+}
+
+// Builder collects the rows of one event log, fed in log order
+type Builder struct {
+	scale   domain.Scale
+	horizon int64
+	bbos    []bboAt
+	signals []signalAt
+	rows    map[uint64]*Row
+}
+
+// NewBuilder creates an empty dataset; a fill counts towards the label if
+// it comes within horizonNs of the order's decision
+func NewBuilder(scale domain.Scale, horizonNs int64) *Builder {
+	return &Builder{scale: scale, horizon: horizonNs, rows: make(map[uint64]*Row)}
+}
+
+// Process adds the next event in log order
+func (b *Builder) Process(e *domain.Event) {
+	switch e.Type {
+	case domain.EventBBOUpdate:
+		if bbo := e.BBO(); bbo != nil {
+			b.bbos = append(b.bbos, bboAt{e.Timestamp, *bbo})
+		}
+	case domain.EventSignal:
+		if sig := e.Signal(); sig != nil {
+			b.signals = append(b.signals, signalAt{e.Timestamp, *sig})
+		}
+	case domain.EventOrderAccepted:
+		if o := e.Order(); o != nil && o.Type != domain.CancelOrder && o.TraderID != "" && !domain.IsBackground(o.TraderID) {
+			b.accept(o, e.Timestamp)
+		}
+	case domain.EventTradeExecuted:
+		if tr := e.Trade(); tr != nil {
+			b.fill(tr.BuyOrderID, tr)
+			b.fill(tr.SellOrderID, tr)
+		}
+	case domain.EventOrderState:
+		if c := e.StateChange(); c != nil {
+			if row, ok := b.rows[c.OrderID]; ok {
+				row.FinalState = c.To
+			}
+		}
+	}
+}
+
+func (b *Builder) accept(o *domain.Order, ts int64) {
+	arrival := o.ArrivalTime
+	if arrival == 0 {
+		arrival = ts
+	}
+	row := &Row{
+		OrderID: o.ID, TraderID: o.TraderID, Side: o.Side, Type: o.Type, Price: o.Price, Qty: o.Qty,
+		DecisionNs: o.DecisionTime, ArrivalNs: arrival, LatencyNs: arrival - o.DecisionTime,
+		QueueAhead: -1, SignalAge: -1, FinalState: domain.StateNew,
+	}
+	if o.QueuePos > 0 {
+		row.QueueAhead = o.QueuePos - 1
+	}
+	if bbo := b.bboAt(o.DecisionTime); bbo != nil {
+		row.Mid, row.BidQty, row.AskQty = bbo.MidPrice, bbo.BidQty, bbo.AskQty
+		if bbo.BidPrice > 0 && bbo.AskPrice > 0 {
+			row.Spread = bbo.AskPrice - bbo.BidPrice
+		}
+		row.Imbalance = bbo.Imbalance()
+	}
+	if s := b.signalAt(o.DecisionTime); s != nil {
+		row.SignalAge = o.DecisionTime - s.ts
+		row.Signal = s.sig.Strength(row.SignalAge)
+	}
+	b.rows[o.ID] = row
+}
+
+func (b *Builder) fill(id uint64, tr *domain.Trade) {
+	row, ok := b.rows[id]
+	if !ok {
+		return
+	}
+	if row.FilledQty == 0 {
+		row.FirstFillNs = tr.Timestamp - row.DecisionNs
+	}
+	row.FilledQty += tr.Qty
+	row.notional += tr.Price * tr.Qty
+	if tr.Timestamp-row.DecisionNs <= b.horizon {
+		row.FilledWithinHorizon = true
+		row.FilledQtyHorizon += tr.Qty
+	}
+	if row.Mid > 0 {
+		avg := float64(row.notional) / float64(row.FilledQty)
+		slip := avg - float64(row.Mid)
+		if row.Side == domain.Sell {
+			slip = -slip
+		}
+		row.SlippageBps = slip / float64(row.Mid) * 10_000
+	}
+}
+
+// bboAt returns the last BBO at or before t, or nil
+func (b *Builder) bboAt(t int64) *domain.BBO {
+	i := sort.Search(len(b.bbos), func(i int) bool { return b.bbos[i].ts > t })
+	if i == 0 {
+		return nil
+	}
+	return &b.bbos[i-1].bbo
+}
+
+// signalAt returns the last signal at or before t, or nil
+func (b *Builder) signalAt(t int64) *signalAt {
+	i := sort.Search(len(b.signals), func(i int) bool { return b.signals[i].ts > t })
+	if i == 0 {
+		return nil
+	}
+	return &b.signals[i-1]
+}
+
+// Rows returns the rows by order ID
+func (b *Builder) Rows() []Row {
+	var rows []Row
+	for _, r := range detorder.Values(b.rows) {
+		rows = append(rows, *r)
+	}
+	return rows
+}
+
+// WriteCSV writes the dataset with Header. Prices are decimal in the
+// run's scale; a value that does not apply is left blank
+func (b *Builder) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Header); err != nil {
+		return err
+	}
+	i64 := func(v int64) string { return strconv.FormatInt(v, 10) }
+	f := func(v float64, prec int) string { return strconv.FormatFloat(v, 'f', prec, 64) }
+	price := func(v int64) string {
+		if v == 0 {
+			return ""
+		}
+		return b.scale.Format(v)
+	}
+	for _, r := range b.Rows() {
+		spreadBps, queueAhead, signal, signalAge := "", "", "", ""
+		if r.Spread > 0 && r.Mid > 0 {
+			spreadBps = f(float64(r.Spread)/float64(r.Mid)*10_000, 2)
+		}
+		if r.QueueAhead >= 0 {
+			queueAhead = strconv.Itoa(r.QueueAhead)
+		}
+		if r.SignalAge >= 0 {
+			signal, signalAge = f(r.Signal, 4), i64(r.SignalAge)
+		}
+		filled := "0"
+		if r.FilledWithinHorizon {
+			filled = "1"
+		}
+		firstFill, slippage := "", ""
+		if r.FilledQty > 0 {
+			firstFill = i64(r.FirstFillNs)
+			if r.Mid > 0 {
+				slippage = f(r.SlippageBps, 2)
+			}
+		}
+		if err := cw.Write([]string{
+			strconv.FormatUint(r.OrderID, 10), r.TraderID, r.Side.String(), r.Type.String(), price(r.Price),
+			i64(r.Qty), i64(r.DecisionNs), i64(r.ArrivalNs), i64(r.LatencyNs), price(r.Mid), price(r.Spread),
+			spreadBps, i64(r.BidQty), i64(r.AskQty), f(r.Imbalance, 4), queueAhead, signal, signalAge,
+			filled, i64(r.FilledQtyHorizon), i64(r.FilledQty), firstFill, slippage, r.FinalState.String(),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteFile writes the dataset as CSV to path
+func (b *Builder) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := b.WriteCSV(f); err != nil {
+		f.Close()
+		return fmt.Errorf("write dataset: %w", err)
+	}
+	return f.Close()
+}
+
+// BuildLog builds the dataset of the event log at path
+func BuildLog(path string, scale domain.Scale, horizonNs int64) (*Builder, error) {
+	r, err := eventlog.NewReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if err := r.Require(eventlog.LevelFull, "dataset"); err != nil {
+		return nil, err
+	}
+
+	b := NewBuilder(scale, horizonNs)
+	line := 1
+	err = r.Each(func(e *domain.Event) error {
+		b.Process(e)
+		line++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dataset line %d: %w", line, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,99 @@
+package dataset
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// TestFeaturesAndLabels: fast's limit buy rests behind two orders and
+// fills 3 at 40 and 2 at 250; background orders get no row
+func TestFeaturesAndLabels(t *testing.T) {
+	ev := func(e domain.Event) *domain.Event { return &e }
+	buy := &domain.Order{ID: 1, TraderID: "fast", Side: domain.Buy, Type: domain.LimitOrder, Price: 990_000,
+		Qty: 5, RemainingQty: 5, DecisionTime: 15, ArrivalTime: 20, QueuePos: 3}
+	events := []*domain.Event{
+		ev(domain.NewBBOEvent(10, &domain.BBO{BidPrice: 990_000, BidQty: 30, AskPrice: 1_010_000, AskQty: 10, MidPrice: 1_000_000})),
+		ev(domain.NewSignalEvent(12, &domain.Signal{Value: 0.8, MidPrice: 1_000_000})),
+		ev(domain.NewOrderEvent(20, buy)),
+		ev(domain.NewOrderEvent(30, &domain.Order{ID: 2, TraderID: "background", Side: domain.Sell,
+			Type: domain.MarketOrder, Qty: 3, DecisionTime: 30})),
+		ev(domain.NewTradeEvent(40, &domain.Trade{ID: 1, BuyOrderID: 1, SellOrderID: 2, BuyTrader: "fast",
+			SellTrader: "background", Price: 990_000, Qty: 3, Timestamp: 40, PassiveOrderID: 1})),
+		ev(domain.NewTradeEvent(250, &domain.Trade{ID: 2, BuyOrderID: 1, SellOrderID: 3, BuyTrader: "fast",
+			SellTrader: "background", Price: 990_000, Qty: 2, Timestamp: 250, PassiveOrderID: 1})),
+		ev(domain.NewStateEvent(250, &domain.StateChange{OrderID: 1, TraderID: "fast",
+			From: domain.StatePartiallyFilled, To: domain.StateFilled, FilledQty: 5})),
+	}
+	b := NewBuilder(domain.DefaultScale, 100)
+	for _, e := range events {
+		b.Process(e)
+	}
+
+	rows := b.Rows()
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	r := rows[0]
+	if r.LatencyNs != 5 || r.Spread != 20_000 || r.QueueAhead != 2 || r.SignalAge != 3 || r.Signal != 0.8 {
+		t.Errorf("features: %+v", r)
+	}
+	if r.Imbalance != 0.5 {
+		t.Errorf("imbalance = %v, want 0.5", r.Imbalance)
+	}
+	if !r.FilledWithinHorizon || r.FilledQtyHorizon != 3 || r.FilledQty != 5 || r.FirstFillNs != 25 {
+		t.Errorf("labels: %+v", r)
+	}
+	if r.SlippageBps != -100 || r.FinalState != domain.StateFilled {
+		t.Errorf("slippage %v, state %v", r.SlippageBps, r.FinalState)
+	}
+
+	var buf bytes.Buffer
+	if err := b.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || len(records[1]) != len(Header) {
+		t.Fatalf("csv shape %d x %d", len(records), len(records[1]))
+	}
+	got := make(map[string]string)
+	for i, col := range Header {
+		got[col] = records[1][i]
+	}
+	for col, want := range map[string]string{
+		"price": "99.0000", "spread_bps": "200.00", "imbalance": "0.5000", "queue_ahead": "2",
+		"filled_within_horizon": "1", "slippage_bps": "-100.00", "final_state": "FILLED",
+	} {
+		if got[col] != want {
+			t.Errorf("%s = %q, want %q", col, got[col], want)
+		}
+	}
+}
+
+// TestUnfilledMarketOrder leaves the fill columns blank
+func TestUnfilledMarketOrder(t *testing.T) {
+	b := NewBuilder(domain.DefaultScale, DefaultHorizonNs)
+	e := domain.NewOrderEvent(5, &domain.Order{ID: 7, TraderID: "slow", Side: domain.Sell, Type: domain.MarketOrder,
+		Qty: 5, DecisionTime: 1, ArrivalTime: 5})
+	b.Process(&e)
+	var buf bytes.Buffer
+	if err := b.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	records, _ := csv.NewReader(&buf).ReadAll()
+	row := records[1]
+	for i, col := range Header {
+		switch col {
+		case "price", "mid", "spread", "spread_bps", "queue_ahead", "signal", "signal_age_ns",
+			"time_to_first_fill_ns", "slippage_bps":
+			if row[i] != "" {
+				t.Errorf("%s = %q, want blank", col, row[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Package detorder provides sorted iteration over maps. Go randomizes map
+// iteration order on every range, so any loop whose effects depend on
+// visit order (emitting events, dispatching to agents, summing floats)
+// must go through these helpers to keep runs reproducible
+package detorder
+
+import (
+	"cmp"
+	"iter"
+	"maps"
+	"slices"
+)
+
+// Keys returns the keys of m in ascending order
+func Keys[K cmp.Ordered, V any](m map[K]V) []K {
+	return slices.Sorted(maps.Keys(m))
+}
+
+// All iterates over m in ascending key order
+// Keys are snapshotted up front, so fn may modify m; entries deleted
+// during iteration are skipped and entries added are not visited
+func All[K cmp.Ordered, V any](m map[K]V) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, k := range Keys(m) {
+			v, ok := m[k]
+			if !ok {
+				continue
+			}
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns the values of m ordered by key
+func Values[K cmp.Ordered, V any](m map[K]V) []V {
+	keys := Keys(m)
+	values := make([]V, len(keys))
+	for i, k := range keys {
+		values[i] = m[k]
+	}
+	return values
+}
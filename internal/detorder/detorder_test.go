@@ -0,0 +1,45 @@
+package detorder
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestKeysSorted(t *testing.T) {
+	m := map[string]int{"slow": 2, "background": 0, "fast": 1}
+	for i := 0; i < 20; i++ {
+		if got := Keys(m); !slices.Equal(got, []string{"background", "fast", "slow"}) {
+			t.Fatalf("keys %v not sorted", got)
+		}
+	}
+	if got := Values(m); !slices.Equal(got, []int{0, 1, 2}) {
+		t.Errorf("values %v not in key order", got)
+	}
+}
+
+func TestAllToleratesMutation(t *testing.T) {
+	m := map[uint64]string{3: "c", 1: "a", 2: "b"}
+	var seen []uint64
+	for k := range All(m) {
+		seen = append(seen, k)
+		delete(m, 2)
+		m[10] = "new"
+	}
+	if !slices.Equal(seen, []uint64{1, 3}) {
+		t.Errorf("visited %v, want [1 3]", seen)
+	}
+}
+
+func TestAllStopsEarly(t *testing.T) {
+	m := map[int]bool{1: true, 2: true, 3: true}
+	n := 0
+	for range All(m) {
+		n++
+		if n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Errorf("visited %d entries after break", n)
+	}
+}
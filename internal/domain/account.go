@@ -0,0 +1,70 @@
+package domain
+
+// --- Accounts ---
+
+// Account is one trader's running ledger. Money fields are fixed-point
+// price units times quantity, so they share the run's price scale
+//
+// Cash + CostBasis == RealizedPnL - Fees holds after every fill
+type Account struct {
+	TraderID    string `json:"trader_id"`
+	Cash        int64  `json:"cash"`         // net of fees; starts at 0
+	Position    int64  `json:"position"`     // signed: long > 0, short < 0
+	CostBasis   int64  `json:"cost_basis"`   // signed cost of the open position
+	Fees        int64  `json:"fees"`         // paid; negative for net rebates
+	RealizedPnL int64  `json:"realized_pnl"` // on closed quantity, before fees
+	Volume      int64  `json:"volume"`       // shares traded either side
+	Fills       int    `json:"fills"`
+}
+
+// Fill books qty at price on side, charging fee. PnL is realized at
+// average cost on the part of qty that reduces the open position
+func (a *Account) Fill(side Side, price, qty, fee int64) {
+	signed := int64(side) * qty
+	a.Cash -= signed*price + fee
+	a.Fees += fee
+	a.Volume += qty
+	a.Fills++
+
+	if a.Position != 0 && (a.Position > 0) != (signed > 0) {
+		open := abs(a.Position)
+		closing := min(qty, open)
+		removed := a.CostBasis * closing / open
+		if closing == open {
+			removed = a.CostBasis
+		}
+		a.RealizedPnL += sign(a.Position)*closing*price - removed
+		a.CostBasis -= removed
+		a.Position += int64(side) * closing
+		qty -= closing
+	}
+	a.CostBasis += int64(side) * qty * price
+	a.Position += int64(side) * qty
+}
+
+// Unrealized marks the open position at mark
+func (a *Account) Unrealized(mark int64) int64 {
+	return a.Position*mark - a.CostBasis
+}
+
+// AvgPrice is the average entry price of the open position, or 0 when flat
+func (a *Account) AvgPrice() int64 {
+	if a.Position == 0 {
+		return 0
+	}
+	return a.CostBasis / a.Position
+}
+
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func sign(x int64) int64 {
+	if x < 0 {
+		return -1
+	}
+	return 1
+}
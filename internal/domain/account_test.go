@@ -0,0 +1,39 @@
+package domain
+
+import "testing"
+
+func TestAccountFill(t *testing.T) {
+	var a Account
+	a.Fill(Buy, 100, 10, 3)
+	a.Fill(Sell, 110, 4, 1) // closes 4 at +10
+	a.Fill(Sell, 90, 10, 2) // closes 6 at -10, opens 4 short at 90
+	a.Fill(Buy, 80, 4, 0)   // closes the short at +10
+	want := Account{Cash: 4*110 + 10*90 - 10*100 - 4*80 - 6, Position: 0, CostBasis: 0,
+		Fees: 6, RealizedPnL: 40 - 60 + 40, Volume: 28, Fills: 4}
+	if a != want {
+		t.Fatalf("account = %+v\nwant      %+v", a, want)
+	}
+}
+
+func TestAccountBalances(t *testing.T) {
+	var a Account
+	fills := []struct {
+		side       Side
+		price, qty int64
+	}{
+		{Buy, 1_000_100, 3}, {Buy, 999_900, 4}, {Sell, 1_000_300, 5},
+		{Sell, 1_000_000, 7}, {Buy, 999_700, 2}, {Buy, 1_000_200, 9},
+	}
+	for i, f := range fills {
+		a.Fill(f.side, f.price, f.qty, 7*f.qty)
+		if a.Cash+a.CostBasis != a.RealizedPnL-a.Fees {
+			t.Fatalf("fill %d: %+v does not balance", i, a)
+		}
+	}
+	if a.Position != 6 || a.AvgPrice() != 1_000_200 {
+		t.Errorf("position %d @ %d, want 6 @ 1000200", a.Position, a.AvgPrice())
+	}
+	if got := a.Unrealized(1_000_300); got != 600 {
+		t.Errorf("unrealized = %d, want 600", got)
+	}
+}
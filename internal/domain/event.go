@@ -0,0 +1,329 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// --- Event payloads ---
+
+// Payload is the body of an event: *Order, *Trade, *BBO, *Signal,
+// *StateChange, *Account or *Depth. The set is closed, so a type switch
+// over them is exhaustive
+type Payload interface {
+	payload()
+}
+
+func (*Order) payload()       {}
+func (*Trade) payload()       {}
+func (*BBO) payload()         {}
+func (*Signal) payload()      {}
+func (*StateChange) payload() {}
+func (*Account) payload()     {}
+func (*Depth) payload()       {}
+
+// NewOrderEvent returns an ORDER_ACCEPTED event for an order arriving at ts
+func NewOrderEvent(ts int64, o *Order) Event {
+	return Event{Timestamp: ts, Type: EventOrderAccepted, Payload: o}
+}
+
+// NewCancelEvent returns the ORDER_CANCELED ack for a CANCEL instruction
+// The payload is the instruction, not the order it canceled
+func NewCancelEvent(ts int64, cancel *Order) Event {
+	return Event{Timestamp: ts, Type: EventOrderCanceled, Payload: cancel}
+}
+
+// NewTradeEvent returns a TRADE_EXECUTED event
+func NewTradeEvent(ts int64, t *Trade) Event {
+	return Event{Timestamp: ts, Type: EventTradeExecuted, Payload: t}
+}
+
+// NewBBOEvent returns a BBO_UPDATE event
+func NewBBOEvent(ts int64, q *BBO) Event {
+	return Event{Timestamp: ts, Type: EventBBOUpdate, Payload: q}
+}
+
+// NewSignalEvent returns a SIGNAL event
+func NewSignalEvent(ts int64, s *Signal) Event {
+	return Event{Timestamp: ts, Type: EventSignal, Payload: s}
+}
+
+// NewStateEvent returns an ORDER_STATE event for a lifecycle transition
+func NewStateEvent(ts int64, c *StateChange) Event {
+	return Event{Timestamp: ts, Type: EventOrderState, Payload: c}
+}
+
+// NewAccountEvent returns an ACCOUNT_UPDATE event with a trader's
+// account after a fill
+func NewAccountEvent(ts int64, a *Account) Event {
+	return Event{Timestamp: ts, Type: EventAccountUpdate, TraderID: a.TraderID, Payload: a}
+}
+
+// NewDepthEvent returns a DEPTH event with a snapshot of the book
+func NewDepthEvent(ts int64, d *Depth) Event {
+	return Event{Timestamp: ts, Type: EventDepth, Payload: d}
+}
+
+// NewReQuoteEvent returns a REQUOTE event for one trader
+func NewReQuoteEvent(ts int64, traderID string) Event {
+	return Event{Timestamp: ts, Type: EventReQuote, TraderID: traderID}
+}
+
+// NewMarketDataEvent returns a MARKET_DATA event: a BBO update or trade
+// print reaching one trader over its market-data feed
+func NewMarketDataEvent(ts int64, traderID string, tick Payload) Event {
+	return Event{Timestamp: ts, Type: EventMarketData, TraderID: traderID, Payload: tick}
+}
+
+// NewRefreshEvent returns an ICEBERG_REFRESH event: a resting iceberg
+// order showing a new slice after a fill took the last one. The payload
+// is the order as it stands after the refresh, its QueuePos the place the
+// new slice took at the back of the queue
+func NewRefreshEvent(ts int64, o *Order) Event {
+	return Event{Timestamp: ts, Type: EventIcebergRefresh, Payload: o}
+}
+
+// NewAuctionEvent returns an AUCTION event: the close of a batch, whose
+// orders are logged after it. Its meta carries the auction's results
+func NewAuctionEvent(ts int64) Event {
+	return Event{Timestamp: ts, Type: EventAuction}
+}
+
+// Session phases, in the order a session goes through them. A SESSION
+// event's "phase" annotation is the phase that starts at its time
+const (
+	PhaseOpenAuction  = "open_auction"
+	PhaseContinuous   = "continuous"
+	PhaseCloseAuction = "close_auction"
+	PhaseClosed       = "closed"
+)
+
+// NewSessionEvent returns a SESSION event: a trading session boundary.
+// Its meta names the session, the phase that starts and the session's
+// reference price
+func NewSessionEvent(ts int64, session, phase, refPrice string) Event {
+	return Event{Timestamp: ts, Type: EventSession,
+		Meta: map[string]string{"session": session, "phase": phase, "ref_price": refPrice}}
+}
+
+// Annotate tags the event with key=value, replacing any earlier value
+func (e *Event) Annotate(key, value string) {
+	if e.Meta == nil {
+		e.Meta = make(map[string]string, 1)
+	}
+	e.Meta[key] = value
+}
+
+// Annotation returns the value tagged under key and whether it is set
+func (e *Event) Annotation(key string) (string, bool) {
+	v, ok := e.Meta[key]
+	return v, ok
+}
+
+// Order returns the order payload, or nil. On ORDER_CANCELED it is the
+// CANCEL instruction; prefer Cancel there
+func (e *Event) Order() *Order {
+	o, _ := e.Payload.(*Order)
+	return o
+}
+
+// Cancel returns the CANCEL instruction an ORDER_CANCELED or cancel
+// ORDER_ACCEPTED event carries, or nil. Its CancelID is the target order
+func (e *Event) Cancel() *Order {
+	if o, _ := e.Payload.(*Order); o != nil && o.Type == CancelOrder {
+		return o
+	}
+	return nil
+}
+
+// Trade returns the trade payload, or nil
+func (e *Event) Trade() *Trade {
+	t, _ := e.Payload.(*Trade)
+	return t
+}
+
+// BBO returns the BBO payload, or nil
+func (e *Event) BBO() *BBO {
+	q, _ := e.Payload.(*BBO)
+	return q
+}
+
+// Signal returns the signal payload, or nil
+func (e *Event) Signal() *Signal {
+	s, _ := e.Payload.(*Signal)
+	return s
+}
+
+// StateChange returns the lifecycle transition payload, or nil
+func (e *Event) StateChange() *StateChange {
+	c, _ := e.Payload.(*StateChange)
+	return c
+}
+
+// Account returns the account payload, or nil
+func (e *Event) Account() *Account {
+	a, _ := e.Payload.(*Account)
+	return a
+}
+
+// Depth returns the book snapshot payload, or nil
+func (e *Event) Depth() *Depth {
+	d, _ := e.Payload.(*Depth)
+	return d
+}
+
+// CheckPayload reports an event whose payload does not match its type
+func (e *Event) CheckPayload() error {
+	var ok bool
+	switch e.Type {
+	case EventOrderAccepted:
+		ok = e.Order() != nil
+	case EventIcebergRefresh:
+		ok = e.Order() != nil && e.Order().Iceberg()
+	case EventOrderCanceled:
+		ok = e.Cancel() != nil
+	case EventTradeExecuted:
+		ok = e.Trade() != nil
+	case EventBBOUpdate:
+		ok = e.BBO() != nil
+	case EventSignal:
+		ok = e.Signal() != nil
+	case EventOrderState:
+		ok = e.StateChange() != nil
+	case EventAccountUpdate:
+		ok = e.Account() != nil
+	case EventDepth:
+		ok = e.Depth() != nil
+	case EventMarketData:
+		ok = e.BBO() != nil || e.Trade() != nil
+	case EventReQuote, EventSimStart, EventSimEnd, EventAuction, EventSession:
+		ok = e.Payload == nil
+	default:
+		return fmt.Errorf("unknown event type %d", int(e.Type))
+	}
+	if !ok {
+		return fmt.Errorf("%s event with %s payload", e.Type, payloadName(e.Payload))
+	}
+	return nil
+}
+
+func payloadName(p Payload) string {
+	switch p := p.(type) {
+	case nil:
+		return "no"
+	case *Order:
+		if p == nil {
+			return "nil order"
+		}
+		return p.Type.String() + " order"
+	case *Trade:
+		if p == nil {
+			return "nil trade"
+		}
+		return "trade"
+	case *BBO:
+		if p == nil {
+			return "nil bbo"
+		}
+		return "bbo"
+	case *Signal:
+		if p == nil {
+			return "nil signal"
+		}
+		return "signal"
+	case *StateChange:
+		if p == nil {
+			return "nil state"
+		}
+		return "state"
+	case *Account:
+		if p == nil {
+			return "nil account"
+		}
+		return "account"
+	case *Depth:
+		if p == nil {
+			return "nil depth"
+		}
+		return "depth"
+	}
+	return "unknown"
+}
+
+// eventJSON is the wire form of Event; the payload goes under its own key
+type eventJSON struct {
+	SeqNo     uint64            `json:"seq_no"`
+	Timestamp int64             `json:"timestamp"`
+	Type      EventType         `json:"type"`
+	TraderID  string            `json:"trader_id,omitempty"`
+	Order     *Order            `json:"order,omitempty"`
+	Trade     *Trade            `json:"trade,omitempty"`
+	BBO       *BBO              `json:"bbo,omitempty"`
+	Signal    *Signal           `json:"signal,omitempty"`
+	State     *StateChange      `json:"state,omitempty"`
+	Account   *Account          `json:"account,omitempty"`
+	Depth     *Depth            `json:"depth,omitempty"`
+	Meta      map[string]string `json:"meta,omitempty"`
+}
+
+var errMultiplePayloads = errors.New("event has more than one payload")
+
+// MarshalJSON writes the payload under "order", "trade", "bbo", "signal",
+// "state", "account" or "depth", then any annotations under "meta"
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(eventJSON{
+		SeqNo:     e.SeqNo,
+		Timestamp: e.Timestamp,
+		Type:      e.Type,
+		TraderID:  e.TraderID,
+		Order:     e.Order(),
+		Trade:     e.Trade(),
+		BBO:       e.BBO(),
+		Signal:    e.Signal(),
+		State:     e.StateChange(),
+		Account:   e.Account(),
+		Depth:     e.Depth(),
+		Meta:      e.Meta,
+	})
+}
+
+// UnmarshalJSON reads the MarshalJSON form; at most one payload key may be set
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var w eventJSON
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	*e = Event{SeqNo: w.SeqNo, Timestamp: w.Timestamp, Type: w.Type, TraderID: w.TraderID, Meta: w.Meta}
+	for _, p := range []Payload{w.Order, w.Trade, w.BBO, w.Signal, w.State, w.Account, w.Depth} {
+		if isNil(p) {
+			continue
+		}
+		if e.Payload != nil {
+			return errMultiplePayloads
+		}
+		e.Payload = p
+	}
+	return nil
+}
+
+// isNil reports a nil payload, including a typed nil pointer
+func isNil(p Payload) bool {
+	switch p := p.(type) {
+	case *Order:
+		return p == nil
+	case *Trade:
+		return p == nil
+	case *BBO:
+		return p == nil
+	case *Signal:
+		return p == nil
+	case *StateChange:
+		return p == nil
+	case *Account:
+		return p == nil
+	case *Depth:
+		return p == nil
+	}
+	return p == nil
+}
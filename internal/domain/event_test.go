@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestCheckPayloadCoversEveryEventType fails when an EventType is added
+// without deciding which payload it carries
+func TestCheckPayloadCoversEveryEventType(t *testing.T) {
+	n := 0
+	for et := EventType(0); et.String() != "UNKNOWN"; et++ {
+		e := Event{Type: et}
+		if err := e.CheckPayload(); err != nil && strings.Contains(err.Error(), "unknown event type") {
+			t.Errorf("CheckPayload does not handle %s", et)
+		}
+		n++
+	}
+	if n != int(eventTypeCount) {
+		t.Errorf("walked %d event types, want %d", n, eventTypeCount)
+	}
+}
+
+func TestCheckPayload(t *testing.T) {
+	limit := &Order{ID: 1, Type: LimitOrder}
+	cancel := &Order{ID: 2, Type: CancelOrder, CancelID: 1}
+	tests := []struct {
+		name string
+		e    Event
+		ok   bool
+	}{
+		{"order", NewOrderEvent(0, limit), true},
+		{"cancel ack", NewCancelEvent(0, cancel), true},
+		{"trade", NewTradeEvent(0, &Trade{}), true},
+		{"bbo", NewBBOEvent(0, &BBO{}), true},
+		{"signal", NewSignalEvent(0, &Signal{}), true},
+		{"requote", NewReQuoteEvent(0, "fast"), true},
+		{"sim start", Event{Type: EventSimStart}, true},
+		{"auction", NewAuctionEvent(0), true},
+		{"auction with trade", Event{Type: EventAuction, Payload: &Trade{}}, false},
+		{"cancel ack carrying the canceled order", NewCancelEvent(0, limit), false},
+		{"signal without payload", Event{Type: EventSignal}, false},
+		{"typed nil signal", NewSignalEvent(0, nil), false},
+		{"trade with bbo payload", Event{Type: EventTradeExecuted, Payload: &BBO{}}, false},
+		{"requote with order", Event{Type: EventReQuote, Payload: limit}, false},
+		{"unknown type", Event{Type: EventType(99)}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.e.CheckPayload(); (err == nil) != tt.ok {
+			t.Errorf("%s: CheckPayload() = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestPayloadAccessors(t *testing.T) {
+	limit := &Order{ID: 1, Type: LimitOrder}
+	e := NewOrderEvent(5, limit)
+	if e.Order() != limit || e.Cancel() != nil || e.Trade() != nil || e.BBO() != nil || e.Signal() != nil {
+		t.Errorf("accessors on order event: %+v", e)
+	}
+	cancel := &Order{ID: 2, Type: CancelOrder, CancelID: 1}
+	if e := NewCancelEvent(5, cancel); e.Cancel() != cancel || e.Cancel().CancelID != 1 {
+		t.Error("Cancel() on cancel ack")
+	}
+}
+
+// TestEventJSONLayout pins the wire format to the pre-payload layout, so
+// existing logs and their hashes stay valid
+func TestEventJSONLayout(t *testing.T) {
+	tests := []struct {
+		e    Event
+		want string
+	}{
+		{
+			Event{SeqNo: 3, Timestamp: 7, Type: EventSignal, Payload: &Signal{Value: 0.5, MidPrice: 1_000_000}},
+			`{"seq_no":3,"timestamp":7,"type":"SIGNAL","signal":{"value":0.5,"mid_price":1000000}}`,
+		},
+		{
+			NewReQuoteEvent(9, "slow"),
+			`{"seq_no":0,"timestamp":9,"type":"REQUOTE","trader_id":"slow"}`,
+		},
+		{
+			Event{Timestamp: 9, Type: EventReQuote, TraderID: "slow", Meta: map[string]string{"speed_bump_applied": "1", "burst_id": "2"}},
+			`{"seq_no":0,"timestamp":9,"type":"REQUOTE","trader_id":"slow","meta":{"burst_id":"2","speed_bump_applied":"1"}}`,
+		},
+		{
+			NewBBOEvent(1, &BBO{BidPrice: 1, BidQty: 2, AskPrice: 3, AskQty: 4, MidPrice: 2}),
+			`{"seq_no":0,"timestamp":1,"type":"BBO_UPDATE","bbo":{"bid_price":1,"bid_qty":2,"ask_price":3,"ask_qty":4,"mid_price":2}}`,
+		},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(&tt.e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("Marshal = %s\nwant      %s", data, tt.want)
+		}
+		var back Event
+		if err := json.Unmarshal(data, &back); err != nil {
+			t.Fatal(err)
+		}
+		again, _ := json.Marshal(&back)
+		if string(again) != tt.want {
+			t.Errorf("round trip = %s", again)
+		}
+	}
+
+	var e Event
+	if err := json.Unmarshal([]byte(`{"type":"TRADE_EXECUTED","trade":{"id":1},"bbo":{}}`), &e); err == nil {
+		t.Error("accepted an event with two payloads")
+	}
+	if err := json.Unmarshal([]byte(`{"type":"SIM_END","order":null}`), &e); err != nil || e.Payload != nil {
+		t.Errorf("null payload: %v, %+v", err, e.Payload)
+	}
+}
@@ -0,0 +1,50 @@
+package domain
+
+import "sync"
+
+// --- Object pools ---
+// A run allocates millions of short-lived events and orders. Pooled objects
+// are always zeroed on release, so reuse never leaks state between uses and
+// cannot affect determinism
+
+var eventPool = sync.Pool{New: func() interface{} { return new(Event) }}
+
+var orderPool = sync.Pool{New: func() interface{} { return new(Order) }}
+
+// AcquireEvent returns a zeroed Event from the pool
+func AcquireEvent() *Event {
+	return eventPool.Get().(*Event)
+}
+
+// ReleaseEvent returns an event to the pool. The caller must hold no other
+// references to it; payload pointers are cleared but not released
+func ReleaseEvent(e *Event) {
+	*e = Event{}
+	eventPool.Put(e)
+}
+
+// AcquireOrder returns a zeroed Order from the pool
+func AcquireOrder() *Order {
+	return orderPool.Get().(*Order)
+}
+
+// ReleaseOrder returns an order to the pool. The caller must guarantee the
+// order is no longer resting on a book or tracked by any agent
+func ReleaseOrder(o *Order) {
+	*o = Order{}
+	orderPool.Put(o)
+}
+
+// PooledEvent copies e into an event taken from the pool
+func PooledEvent(e Event) *Event {
+	p := AcquireEvent()
+	*p = e
+	return p
+}
+
+// PooledOrder copies o into an order taken from the pool
+func PooledOrder(o Order) *Order {
+	p := AcquireOrder()
+	*p = o
+	return p
+}
@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of fixed-point units per 1.0 of price for an
+// instrument: 10_000 (4 decimals) for equities, 100_000 for FX pips,
+// 100_000_000 for crypto satoshis. It must be a power of ten
+type Scale int64
+
+// DefaultScale is the scale used when an instrument does not set one
+const DefaultScale Scale = PriceScale
+
+// maxScale keeps every price of a few billion representable
+const maxScale Scale = 1_000_000_000
+
+// Validate reports whether s is a supported power of ten
+func (s Scale) Validate() error {
+	if s < 1 || s > maxScale {
+		return fmt.Errorf("price scale %d out of range [1, %d]", s, maxScale)
+	}
+	for v := s; v > 1; v /= 10 {
+		if v%10 != 0 {
+			return fmt.Errorf("price scale %d is not a power of ten", s)
+		}
+	}
+	return nil
+}
+
+// Decimals returns the number of decimal places the scale represents
+func (s Scale) Decimals() int {
+	d := 0
+	for v := s; v > 1; v /= 10 {
+		d++
+	}
+	return d
+}
+
+// ToFloat converts a fixed-point price to float64 for display and metrics
+func (s Scale) ToFloat(p int64) float64 {
+	return float64(p) / float64(s)
+}
+
+// FromFloat converts a float64 to fixed-point, rounding half away from
+// zero on the shortest decimal form of f. Plain f*scale truncation turns
+// 0.29 into 2899 at scale 10_000 because 0.29 is stored as 0.28999...
+// NaN, infinities and values too large for an int64 at the scale are
+// errors
+func (s Scale) FromFloat(f float64) (int64, error) {
+	switch {
+	case math.IsNaN(f) || math.IsInf(f, 0):
+		return 0, fmt.Errorf("price %v is not a finite number", f)
+	case math.Abs(f)*float64(s) >= math.MaxInt64:
+		return 0, fmt.Errorf("price %g is out of range at scale %d", f, s)
+	}
+	return s.parse(strconv.FormatFloat(f, 'f', -1, 64), true)
+}
+
+// Parse parses a decimal string exactly. More decimal places than the
+// scale holds is an error unless the extra digits are zeros
+func (s Scale) Parse(str string) (int64, error) {
+	return s.parse(str, false)
+}
+
+// Format renders a price with exactly Decimals() places
+func (s Scale) Format(p int64) string {
+	sign := ""
+	u := uint64(p)
+	if p < 0 {
+		sign = "-"
+		u = uint64(-p)
+	}
+	whole := u / uint64(s)
+	if s == 1 {
+		return sign + strconv.FormatUint(whole, 10)
+	}
+	frac := strconv.FormatUint(u%uint64(s), 10)
+	pad := strings.Repeat("0", s.Decimals()-len(frac))
+	return sign + strconv.FormatUint(whole, 10) + "." + pad + frac
+}
+
+func (s Scale) parse(str string, round bool) (int64, error) {
+	str = strings.TrimSpace(str)
+	neg := false
+	switch {
+	case strings.HasPrefix(str, "-"):
+		neg = true
+		str = str[1:]
+	case strings.HasPrefix(str, "+"):
+		str = str[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(str, ".")
+	if intPart == "" && fracPart == "" || !digits(intPart) || !digits(fracPart) {
+		return 0, fmt.Errorf("invalid price %q", str)
+	}
+
+	decimals := s.Decimals()
+	roundUp := false
+	if len(fracPart) > decimals {
+		extra := fracPart[decimals:]
+		fracPart = fracPart[:decimals]
+		if !round && strings.Trim(extra, "0") != "" {
+			return 0, fmt.Errorf("price %q has more than %d decimal places", str, decimals)
+		}
+		roundUp = round && extra[0] >= '5'
+	}
+	fracPart += strings.Repeat("0", decimals-len(fracPart))
+
+	var v int64
+	for _, c := range intPart + fracPart {
+		if v > (math.MaxInt64-9)/10 {
+			return 0, fmt.Errorf("price %q overflows", str)
+		}
+		v = v*10 + int64(c-'0')
+	}
+	if roundUp {
+		v++
+	}
+	if neg {
+		v = -v
+	}
+	return v, nil
+}
+
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
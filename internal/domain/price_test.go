@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFloatToPriceRounds(t *testing.T) {
+	tests := []struct {
+		f    float64
+		want int64
+	}{
+		{100.0, 1_000_000},
+		{0.29, 2_900}, // 0.29 * 10_000 = 2899.9999999999995
+		{100.005, 1_000_050},
+		{1.00005, 10_001}, // half rounds away from zero
+		{1.00004, 10_000},
+		{-0.29, -2_900},
+		{-1.00005, -10_001},
+	}
+	for _, tt := range tests {
+		if got := FloatToPrice(tt.f); got != tt.want {
+			t.Errorf("FloatToPrice(%v) = %d, want %d", tt.f, got, tt.want)
+		}
+	}
+}
+
+func TestFromFloatRejectsUnrepresentable(t *testing.T) {
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), 1e15, -1e15, math.MaxFloat64} {
+		if p, err := DefaultScale.FromFloat(f); err == nil {
+			t.Errorf("FromFloat(%v) = %d, want an error", f, p)
+		}
+	}
+	if p, err := Scale(100_000_000).FromFloat(9e10); err != nil || p != 9_000_000_000_000_000_000 {
+		t.Errorf("FromFloat(9e10) at 1e8 = %d, %v", p, err)
+	}
+}
+
+func TestScaleParse(t *testing.T) {
+	tests := []struct {
+		scale Scale
+		s     string
+		want  int64
+	}{
+		{DefaultScale, "100.0050", 1_000_050},
+		{DefaultScale, "100.005", 1_000_050},
+		{DefaultScale, "100", 1_000_000},
+		{DefaultScale, ".5", 5_000},
+		{DefaultScale, "-0.01", -100},
+		{DefaultScale, " +7.25 ", 72_500},
+		{DefaultScale, "1.234500", 12_345}, // trailing zeros beyond the scale are exact
+		{100_000_000, "0.00000001", 1},
+		{100_000_000, "65000.12345678", 6_500_012_345_678},
+		{1, "42", 42},
+	}
+	for _, tt := range tests {
+		got, err := tt.scale.Parse(tt.s)
+		if err != nil || got != tt.want {
+			t.Errorf("Scale(%d).Parse(%q) = %d, %v; want %d", tt.scale, tt.s, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", ".", "1.2.3", "abc", "1e5", "1.00001", "99999999999999999999"} {
+		if _, err := DefaultScale.Parse(bad); err == nil {
+			t.Errorf("Parse(%q) accepted", bad)
+		}
+	}
+}
+
+func TestScaleFormatRoundTrip(t *testing.T) {
+	tests := []struct {
+		scale Scale
+		p     int64
+		want  string
+	}{
+		{DefaultScale, 999_750, "99.9750"},
+		{DefaultScale, -50, "-0.0050"},
+		{DefaultScale, 0, "0.0000"},
+		{100_000, 110_523, "1.10523"},
+		{100_000_000, 1, "0.00000001"},
+		{1, 42, "42"},
+	}
+	for _, tt := range tests {
+		got := tt.scale.Format(tt.p)
+		if got != tt.want {
+			t.Errorf("Scale(%d).Format(%d) = %q, want %q", tt.scale, tt.p, got, tt.want)
+		}
+		if back, err := tt.scale.Parse(got); err != nil || back != tt.p {
+			t.Errorf("Scale(%d).Parse(%q) = %d, %v; want %d", tt.scale, got, back, err, tt.p)
+		}
+	}
+}
+
+func TestScaleValidate(t *testing.T) {
+	for _, s := range []Scale{1, 10, 10_000, 100_000_000, 1_000_000_000} {
+		if err := s.Validate(); err != nil {
+			t.Errorf("Validate(%d): %v", s, err)
+		}
+	}
+	for _, s := range []Scale{0, -10, 250, 10_000_000_000} {
+		if err := s.Validate(); err == nil {
+			t.Errorf("Validate(%d) accepted", s)
+		}
+	}
+}
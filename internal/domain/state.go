@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- Order lifecycle ---
+
+// OrderState is where an order is in its lifecycle
+//
+//	New → Resting → PartiallyFilled → Filled
+//	  ↘ (any live state) → Canceled | Expired;  New → Rejected
+type OrderState int8
+
+const (
+	StateNew OrderState = iota
+	StateResting
+	StatePartiallyFilled
+	StateFilled
+	StateCanceled
+	StateExpired
+	StateRejected
+)
+
+func (s OrderState) String() string {
+	switch s {
+	case StateNew:
+		return "NEW"
+	case StateResting:
+		return "RESTING"
+	case StatePartiallyFilled:
+		return "PARTIALLY_FILLED"
+	case StateFilled:
+		return "FILLED"
+	case StateCanceled:
+		return "CANCELED"
+	case StateExpired:
+		return "EXPIRED"
+	case StateRejected:
+		return "REJECTED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Terminal reports whether no further transition is allowed
+func (s OrderState) Terminal() bool {
+	return s >= StateFilled
+}
+
+// CanTransition reports whether an order may move from s to next
+// Fills beyond the first keep a PartiallyFilled order where it is, which
+// is not a transition
+func (s OrderState) CanTransition(next OrderState) bool {
+	switch s {
+	case StateNew:
+		return next != StateNew && next != StateExpired
+	case StateResting:
+		return next == StatePartiallyFilled || next == StateFilled ||
+			next == StateCanceled || next == StateExpired
+	case StatePartiallyFilled:
+		return next == StateFilled || next == StateCanceled || next == StateExpired
+	default:
+		return false
+	}
+}
+
+// MarshalJSON serializes OrderState as a human-readable string
+func (s OrderState) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+// UnmarshalJSON deserializes OrderState from a string or integer
+func (s *OrderState) UnmarshalJSON(data []byte) error {
+	str := strings.Trim(string(data), `"`)
+	switch str {
+	case "NEW", "0":
+		*s = StateNew
+	case "RESTING", "1":
+		*s = StateResting
+	case "PARTIALLY_FILLED", "2":
+		*s = StatePartiallyFilled
+	case "FILLED", "3":
+		*s = StateFilled
+	case "CANCELED", "4":
+		*s = StateCanceled
+	case "EXPIRED", "5":
+		*s = StateExpired
+	case "REJECTED", "6":
+		*s = StateRejected
+	default:
+		return fmt.Errorf("unknown OrderState: %s", str)
+	}
+	return nil
+}
+
+// StateChange is one order lifecycle transition, logged as ORDER_STATE
+type StateChange struct {
+	OrderID      uint64     `json:"order_id"`
+	TraderID     string     `json:"trader_id"`
+	From         OrderState `json:"from"`
+	To           OrderState `json:"to"`
+	FilledQty    int64      `json:"filled_qty"`
+	RemainingQty int64      `json:"remaining_qty"`
+	Reason       string     `json:"reason,omitempty"`
+}
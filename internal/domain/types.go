@@ -4,28 +4,50 @@ package domain
 
 import (
 	"fmt"
+	"math"
 	"strings"
 )
 
 // --- Price representation ---
-// Prices are fixed-point int64 with 4 decimal places
-// e.g. $100.0050 is stored as 1_000_050
+// Prices are fixed-point int64 with 4 decimal places by default
+// e.g. $100.0050 is stored as 1_000_050. See Scale for other instruments
 
 const PriceScale = 10_000
 
 // PriceToFloat converts a fixed-point price to float64 for display
 func PriceToFloat(p int64) float64 {
-	return float64(p) / float64(PriceScale)
+	return DefaultScale.ToFloat(p)
 }
 
-// FloatToPrice converts a float64 to fixed-point price
+// FloatToPrice converts a float64 to fixed-point price, rounding to the
+// nearest unit. It is for constants and panics where FromFloat fails
 func FloatToPrice(f float64) int64 {
-	return int64(f * float64(PriceScale))
+	p, err := DefaultScale.FromFloat(f)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// ParsePrice parses a decimal string such as "100.0050" exactly
+func ParsePrice(s string) (int64, error) {
+	return DefaultScale.Parse(s)
 }
 
 // FormatPrice returns a human-readable price string
 func FormatPrice(p int64) string {
-	return fmt.Sprintf("%.4f", PriceToFloat(p))
+	return DefaultScale.Format(p)
+}
+
+// --- Background flow ---
+
+// BackgroundTrader is the trader ID of the generated background flow. A
+// configured background population trades as BackgroundTrader/<name>
+const BackgroundTrader = "background"
+
+// IsBackground reports whether a trader ID is part of the background flow
+func IsBackground(traderID string) bool {
+	return traderID == BackgroundTrader || strings.HasPrefix(traderID, BackgroundTrader+"/")
 }
 
 // --- Enums ---
@@ -88,6 +110,49 @@ func (t OrderType) String() string {
 	}
 }
 
+// TimeInForce is how long a limit order stays on the book
+type TimeInForce int8
+
+const (
+	Day TimeInForce = iota // rests until filled, canceled or the close
+	IOC                    // immediate or cancel: fills what it can on arrival, the rest is canceled
+	FOK                    // fill or kill: fills in full on arrival or not at all
+)
+
+func (t TimeInForce) String() string {
+	switch t {
+	case Day:
+		return "DAY"
+	case IOC:
+		return "IOC"
+	case FOK:
+		return "FOK"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// MarshalJSON serializes TimeInForce as a human-readable string
+func (t TimeInForce) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + t.String() + `"`), nil
+}
+
+// UnmarshalJSON deserializes TimeInForce from a string or integer
+func (t *TimeInForce) UnmarshalJSON(data []byte) error {
+	str := strings.Trim(string(data), `"`)
+	switch str {
+	case "DAY", "0":
+		*t = Day
+	case "IOC", "1":
+		*t = IOC
+	case "FOK", "2":
+		*t = FOK
+	default:
+		return fmt.Errorf("unknown TimeInForce: %s", str)
+	}
+	return nil
+}
+
 // MarshalJSON serializes OrderType as a human-readable string
 func (t OrderType) MarshalJSON() ([]byte, error) {
 	return []byte(`"` + t.String() + `"`), nil
@@ -120,6 +185,16 @@ const (
 	EventReQuote
 	EventSimStart
 	EventSimEnd
+	EventOrderState
+	EventAccountUpdate
+	EventAuction
+	EventDepth
+	EventSession
+	EventMarketData
+	EventIcebergRefresh
+
+	// eventTypeCount follows the last event type; add new ones above it
+	eventTypeCount
 )
 
 func (e EventType) String() string {
@@ -140,6 +215,20 @@ func (e EventType) String() string {
 		return "SIM_START"
 	case EventSimEnd:
 		return "SIM_END"
+	case EventOrderState:
+		return "ORDER_STATE"
+	case EventAccountUpdate:
+		return "ACCOUNT_UPDATE"
+	case EventAuction:
+		return "AUCTION"
+	case EventDepth:
+		return "DEPTH"
+	case EventSession:
+		return "SESSION"
+	case EventMarketData:
+		return "MARKET_DATA"
+	case EventIcebergRefresh:
+		return "ICEBERG_REFRESH"
 	default:
 		return "UNKNOWN"
 	}
@@ -170,6 +259,20 @@ func (e *EventType) UnmarshalJSON(data []byte) error {
 		*e = EventSimStart
 	case "SIM_END", "7":
 		*e = EventSimEnd
+	case "ORDER_STATE", "8":
+		*e = EventOrderState
+	case "ACCOUNT_UPDATE", "9":
+		*e = EventAccountUpdate
+	case "AUCTION", "10":
+		*e = EventAuction
+	case "DEPTH", "11":
+		*e = EventDepth
+	case "SESSION", "12":
+		*e = EventSession
+	case "MARKET_DATA", "13":
+		*e = EventMarketData
+	case "ICEBERG_REFRESH", "14":
+		*e = EventIcebergRefresh
 	default:
 		return fmt.Errorf("unknown EventType: %s", str)
 	}
@@ -184,14 +287,36 @@ type Order struct {
 	TraderID     string    `json:"trader_id"`
 	Side         Side      `json:"side"`
 	Type         OrderType `json:"type"`
-	Price        int64     `json:"price"` // 0 for market orders
-	Qty          int64     `json:"qty"`
-	RemainingQty int64     `json:"remaining_qty"`
+	Price        int64     `json:"price"`               // 0 for market orders
+	Qty          int64     `json:"qty"`                 // for CancelOrder: qty to take off the target, 0 for all of it
+	RemainingQty int64     `json:"remaining_qty"`       // for CancelOrder: what the target has left after it
 	DecisionTime int64     `json:"decision_time"`       // nanos: when trader decided
 	ArrivalTime  int64     `json:"arrival_time"`        // nanos: after latency
 	SeqNo        uint64    `json:"seq_no"`              // global FIFO tie-break
 	CancelID     uint64    `json:"cancel_id,omitempty"` // for CancelOrder: target order ID
 	QueuePos     int       `json:"queue_pos,omitempty"` // 1-based queue position at placement
+
+	// TimeInForce is how long a limit order may rest; the zero value rests
+	// until filled, canceled or the close
+	TimeInForce TimeInForce `json:"tif,omitempty"`
+
+	// DisplayQty is how much of a resting limit order shows on the book
+	// at a time; 0 shows all of it
+	DisplayQty int64 `json:"display_qty,omitempty"`
+}
+
+// Immediate reports whether the order is a limit order that must trade
+// on arrival or not at all: what it leaves unfilled is canceled rather
+// than resting
+func (o *Order) Immediate() bool {
+	return o.Type == LimitOrder && (o.TimeInForce == IOC || o.TimeInForce == FOK)
+}
+
+// Iceberg reports whether the order is a limit order that rests showing
+// only a slice of its quantity. Each slice filled is replaced from the
+// hidden rest, which joins the back of the queue
+func (o *Order) Iceberg() bool {
+	return o.Type == LimitOrder && !o.Immediate() && o.DisplayQty > 0 && o.DisplayQty < o.Qty
 }
 
 // IsFilled returns true if the order has been fully filled
@@ -199,6 +324,24 @@ func (o *Order) IsFilled() bool {
 	return o.RemainingQty <= 0
 }
 
+// CheckCancel returns why cancel may not remove target, or "" if it may.
+// Only the owning trader can cancel an order. A cancel that names a price
+// must also match the target's side and price; one with price 0 names
+// neither, since Side has no unset value on the wire
+func CheckCancel(cancel, target *Order) string {
+	if cancel.TraderID != target.TraderID {
+		return fmt.Sprintf("target %d owned by %s", target.ID, target.TraderID)
+	}
+	if cancel.Qty < 0 {
+		return fmt.Sprintf("negative cancel qty %d", cancel.Qty)
+	}
+	if cancel.Price != 0 && (cancel.Side != target.Side || cancel.Price != target.Price) {
+		return fmt.Sprintf("target %d is %s at %d, not %s at %d",
+			target.ID, target.Side, target.Price, cancel.Side, cancel.Price)
+	}
+	return ""
+}
+
 // Trade represents a matched execution
 type Trade struct {
 	ID          uint64 `json:"id"`
@@ -214,6 +357,12 @@ type Trade struct {
 	AggressorOrderID uint64 `json:"aggressor_order_id,omitempty"`
 	// Queue position of the resting (passive) order at fill time
 	RestingQueuePos int `json:"resting_queue_pos,omitempty"`
+	// What stood ahead of the passive order at its price: orders and qty
+	// when it went on the book, and qty still ahead at the fill. The
+	// orders still ahead are RestingQueuePos-1
+	PlacedAhead    int   `json:"placed_ahead,omitempty"`
+	PlacedAheadQty int64 `json:"placed_ahead_qty,omitempty"`
+	AheadQty       int64 `json:"ahead_qty,omitempty"`
 }
 
 // BBO represents best bid and offer snapshot
@@ -225,22 +374,64 @@ type BBO struct {
 	MidPrice int64 `json:"mid_price"` // (bid+ask)/2
 }
 
-// Signal represents a trading signal broadcast to all traders
+// Imbalance is (bid_qty - ask_qty) / (bid_qty + ask_qty) at the top of
+// the book: +1 is all bids, -1 all asks, 0 on an empty book
+func (b *BBO) Imbalance() float64 {
+	total := b.BidQty + b.AskQty
+	if total == 0 {
+		return 0
+	}
+	return float64(b.BidQty-b.AskQty) / float64(total)
+}
+
+// Depth is a snapshot of the top price levels on each side of the
+// book, best first
+type Depth struct {
+	Bids []DepthLevel `json:"bids,omitempty"`
+	Asks []DepthLevel `json:"asks,omitempty"`
+}
+
+// DepthLevel is one price level in a Depth snapshot
+type DepthLevel struct {
+	Price  int64 `json:"price"`
+	Qty    int64 `json:"qty"`
+	Orders int   `json:"orders"`
+}
+
+// Signal represents a trading signal broadcast to all traders. Its value
+// predicts the direction of background market orders until it decays.
+// The runner also attaches the book and trade flow as they stood when it
+// fired, so strategies can condition on the market as well as the value
 type Signal struct {
-	Value    float64 `json:"value"`     // signal strength / direction
-	MidPrice int64   `json:"mid_price"` // mid at signal time
+	Value      float64 `json:"value"`                  // signal strength / direction
+	MidPrice   int64   `json:"mid_price"`              // mid at signal time
+	HalfLifeNs int64   `json:"half_life_ns,omitempty"` // 0: no decay until the next signal
+
+	Imbalance   float64 `json:"imbalance,omitempty"`    // top-of-book BBO.Imbalance
+	TradeFlow   int64   `json:"trade_flow,omitempty"`   // buyer- minus seller-initiated volume over the flow window
+	TradeVolume int64   `json:"trade_volume,omitempty"` // all volume traded over the flow window
+}
+
+// Strength is the signal's value age nanoseconds after it fired
+func (s *Signal) Strength(age int64) float64 {
+	if s.HalfLifeNs <= 0 || age <= 0 {
+		return s.Value
+	}
+	return s.Value * math.Exp2(-float64(age)/float64(s.HalfLifeNs))
 }
 
 // Event is the core unit in the event loop and event log
+// Build events with the New*Event constructors; see event.go
 type Event struct {
-	SeqNo     uint64    `json:"seq_no"`
-	Timestamp int64     `json:"timestamp"`
-	Type      EventType `json:"type"`
-	TraderID  string    `json:"trader_id,omitempty"` // set for trader-specific events (e.g. re-quote)
-
-	// Exactly one of these is set depending on Type
-	Order  *Order  `json:"order,omitempty"`
-	Trade  *Trade  `json:"trade,omitempty"`
-	BBO    *BBO    `json:"bbo,omitempty"`
-	Signal *Signal `json:"signal,omitempty"`
+	SeqNo     uint64
+	Timestamp int64
+	Type      EventType
+	TraderID  string // set for trader-specific events (e.g. re-quote)
+
+	// Payload is the event body, fixed by Type; nil for events without one
+	Payload Payload
+
+	// Meta holds free-form annotations, e.g. "burst_id", so experiments
+	// can tag events without a new field; nil when there are none
+	Meta map[string]string
 }
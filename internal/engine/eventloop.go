@@ -41,9 +41,20 @@ type EventLoop struct {
 	seqNo   uint64
 	handler EventHandler
 
+	// Release, if set, is called with each event after its handler returns
+	// Use it to recycle pooled events; the loop never touches them again
+	Release func(event *domain.Event)
+
+	// Await, if set, is called before each event is taken off the queue,
+	// for events still being produced elsewhere. It may schedule events,
+	// and returns once nothing yet to come can go before the first queued
+	Await func()
+
 	// Stats
 	EventsProcessed uint64
 	CurrentTime     int64
+
+	stopped bool
 }
 
 // NewEventLoop creates a new event loop with the given handler
@@ -64,14 +75,47 @@ func (el *EventLoop) Schedule(event *domain.Event) {
 }
 
 // ScheduleWithSeqNo adds an event with a pre-assigned SeqNo
-// Use only when replaying from a log
+// Use only when replaying from a log, or for SeqNos kept clear with SetSeqNo
 func (el *EventLoop) ScheduleWithSeqNo(event *domain.Event) {
 	heap.Push(&el.queue, event)
 }
 
-// Run processes events until the queue is empty
+// SeqNo returns the last SeqNo Schedule assigned
+func (el *EventLoop) SeqNo() uint64 {
+	return el.seqNo
+}
+
+// SetSeqNo makes Schedule number on from n, leaving the SeqNos below it
+// to be assigned with ScheduleWithSeqNo
+func (el *EventLoop) SetSeqNo(n uint64) {
+	el.seqNo = n
+}
+
+// Peek returns the next event to be handled, or nil if the queue is empty
+func (el *EventLoop) Peek() *domain.Event {
+	if el.queue.Len() == 0 {
+		return nil
+	}
+	return el.queue[0]
+}
+
+// ready waits out Await and reports whether there is an event to handle
+func (el *EventLoop) ready() bool {
+	if el.Await != nil && !el.stopped {
+		el.Await()
+	}
+	return el.queue.Len() > 0 && !el.stopped
+}
+
+// Stop makes Run or RunUntil return once the current event is handled
+// Events still queued are dropped
+func (el *EventLoop) Stop() {
+	el.stopped = true
+}
+
+// Run processes events until the queue is empty or Stop is called
 func (el *EventLoop) Run() {
-	for el.queue.Len() > 0 {
+	for el.ready() {
 		event := heap.Pop(&el.queue).(*domain.Event)
 		el.CurrentTime = event.Timestamp
 		el.EventsProcessed++
@@ -80,13 +124,16 @@ func (el *EventLoop) Run() {
 		for _, e := range newEvents {
 			el.Schedule(e)
 		}
+		if el.Release != nil {
+			el.Release(event)
+		}
 	}
 }
 
 // RunUntil processes events until the given timestamp (inclusive)
 // Returns true if the queue still has events
 func (el *EventLoop) RunUntil(maxTime int64) bool {
-	for el.queue.Len() > 0 {
+	for el.ready() {
 		// Peek at the next event
 		next := el.queue[0]
 		if next.Timestamp > maxTime {
@@ -101,6 +148,9 @@ func (el *EventLoop) RunUntil(maxTime int64) bool {
 		for _, e := range newEvents {
 			el.Schedule(e)
 		}
+		if el.Release != nil {
+			el.Release(event)
+		}
 	}
 	return false
 }
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
@@ -42,8 +43,8 @@ func TestEventLoopSameTimestampFIFO(t *testing.T) {
 
 	handler := func(event *domain.Event) []*domain.Event {
 		// Use the order's ID to track which event was processed
-		if event.Order != nil {
-			processed = append(processed, event.Order.ID)
+		if event.Order() != nil {
+			processed = append(processed, event.Order().ID)
 		}
 		return nil
 	}
@@ -52,11 +53,11 @@ func TestEventLoopSameTimestampFIFO(t *testing.T) {
 
 	// Three events at the same timestamp — should be processed in SeqNo order
 	el.Schedule(&domain.Event{Timestamp: 100, Type: domain.EventOrderAccepted,
-		Order: &domain.Order{ID: 10}})
+		Payload: &domain.Order{ID: 10}})
 	el.Schedule(&domain.Event{Timestamp: 100, Type: domain.EventOrderAccepted,
-		Order: &domain.Order{ID: 20}})
+		Payload: &domain.Order{ID: 20}})
 	el.Schedule(&domain.Event{Timestamp: 100, Type: domain.EventOrderAccepted,
-		Order: &domain.Order{ID: 30}})
+		Payload: &domain.Order{ID: 30}})
 
 	el.Run()
 
@@ -117,3 +118,188 @@ func TestRunUntil(t *testing.T) {
 		t.Errorf("expected 1 pending, got %d", el.Pending())
 	}
 }
+
+func TestReleaseCalledAfterHandler(t *testing.T) {
+	var handled, released []uint64
+
+	el := NewEventLoop(func(event *domain.Event) []*domain.Event {
+		handled = append(handled, event.SeqNo)
+		return nil
+	})
+	el.Release = func(event *domain.Event) {
+		released = append(released, event.SeqNo)
+	}
+	el.Schedule(&domain.Event{Timestamp: 1, Type: domain.EventSignal})
+	el.Schedule(&domain.Event{Timestamp: 2, Type: domain.EventSignal})
+	el.Run()
+
+	if len(released) != 2 || released[0] != handled[0] || released[1] != handled[1] {
+		t.Errorf("expected release of %v, got %v", handled, released)
+	}
+}
+
+// TestAwaitFeedsLoop verifies events scheduled from Await are handled in
+// (timestamp, SeqNo) order with those already queued
+func TestAwaitFeedsLoop(t *testing.T) {
+	var handled []int64
+	el := NewEventLoop(func(event *domain.Event) []*domain.Event {
+		handled = append(handled, event.Timestamp)
+		return nil
+	})
+	el.Schedule(&domain.Event{Timestamp: 25, Type: domain.EventReQuote})
+	feed := []int64{10, 20, 30}
+	el.Await = func() {
+		// Hold back until the next fed event is past the head of the queue
+		for len(feed) > 0 && (el.Peek() == nil || el.Peek().Timestamp >= feed[0]) {
+			el.Schedule(&domain.Event{Timestamp: feed[0], Type: domain.EventSignal})
+			feed = feed[1:]
+		}
+	}
+	el.Run()
+	want := []int64{10, 20, 25, 30}
+	if fmt.Sprint(handled) != fmt.Sprint(want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+}
+
+func benchmarkScheduleRun(b *testing.B, pooled bool) {
+	const batch = 1024
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		el := NewEventLoop(func(event *domain.Event) []*domain.Event { return nil })
+		if pooled {
+			el.Release = domain.ReleaseEvent
+		}
+		for j := 0; j < batch; j++ {
+			var e *domain.Event
+			if pooled {
+				e = domain.AcquireEvent()
+			} else {
+				e = &domain.Event{}
+			}
+			e.Timestamp = int64(j % 97)
+			e.Type = domain.EventSignal
+			el.Schedule(e)
+		}
+		el.Run()
+	}
+}
+
+func BenchmarkScheduleRunAlloc(b *testing.B)  { benchmarkScheduleRun(b, false) }
+func BenchmarkScheduleRunPooled(b *testing.B) { benchmarkScheduleRun(b, true) }
+
+// BenchmarkEventLoopThroughput measures steady-state scheduling where each
+// handled event spawns a follow-up, keeping the queue at a fixed size
+func BenchmarkEventLoopThroughput(b *testing.B) {
+	const queued = 4096
+	remaining := b.N
+	el := NewEventLoop(func(event *domain.Event) []*domain.Event {
+		if remaining <= 0 {
+			return nil
+		}
+		remaining--
+		return []*domain.Event{{Timestamp: event.Timestamp + int64(remaining%13), Type: domain.EventSignal}}
+	})
+	for i := 0; i < queued; i++ {
+		el.Schedule(&domain.Event{Timestamp: int64(i), Type: domain.EventSignal})
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	el.Run()
+	b.ReportMetric(float64(el.EventsProcessed)/b.Elapsed().Seconds(), "events/s")
+}
+
+func TestStopDropsRemainingEvents(t *testing.T) {
+	var handled []int64
+	var el *EventLoop
+	el = NewEventLoop(func(e *domain.Event) []*domain.Event {
+		handled = append(handled, e.Timestamp)
+		if e.Timestamp == 20 {
+			el.Stop()
+		}
+		return nil
+	})
+	for _, ts := range []int64{10, 20, 30, 40} {
+		el.Schedule(&domain.Event{Timestamp: ts})
+	}
+	el.Run()
+
+	if len(handled) != 2 || handled[1] != 20 {
+		t.Fatalf("handled %v, want [10 20]", handled)
+	}
+	if el.Pending() != 2 {
+		t.Errorf("pending = %d, want 2", el.Pending())
+	}
+}
+
+// TestSourceRejectsTimeTravel checks a source delivers in (timestamp,
+// hint) order and cannot schedule behind the loop's clock
+func TestSourceRejectsTimeTravel(t *testing.T) {
+	var el *EventLoop
+	var late error
+	el = NewEventLoop(func(event *domain.Event) []*domain.Event {
+		if event.Timestamp == 200 {
+			// A fresh source, so only the clock can reject it
+			late = el.NewSource("agent").Inject(&domain.Event{Timestamp: 150, Type: domain.EventSignal}, 1)
+		}
+		return nil
+	})
+	src := el.NewSource("recorded")
+	for _, in := range []struct {
+		ts   int64
+		hint uint64
+		ok   bool
+	}{
+		{100, 1, true},
+		{100, 2, true},
+		{100, 2, false}, // hint repeats
+		{200, 1, true},
+		{150, 3, false}, // goes back in time
+	} {
+		err := src.Inject(&domain.Event{Timestamp: in.ts, Type: domain.EventSignal}, in.hint)
+		if (err == nil) != in.ok {
+			t.Errorf("inject at %d hint %d: err = %v", in.ts, in.hint, err)
+		}
+	}
+	el.Run()
+	if late == nil {
+		t.Error("event injected behind the clock accepted")
+	}
+	if el.EventsProcessed != 3 {
+		t.Errorf("processed %d events, want 3", el.EventsProcessed)
+	}
+}
+
+// TestTieAudit runs orders through the loop and checks the audit counts
+// each cross-trader tie once, with the side SeqNo order favored
+func TestTieAudit(t *testing.T) {
+	audit := NewTieAudit()
+	el := NewEventLoop(func(e *domain.Event) []*domain.Event {
+		audit.Observe(e)
+		return nil
+	})
+	order := func(ts int64, trader string, side domain.Side, price, decision int64) {
+		o := &domain.Order{TraderID: trader, Side: side, Type: domain.LimitOrder, Price: price, DecisionTime: decision}
+		e := domain.NewOrderEvent(ts, o)
+		el.Schedule(&e)
+	}
+	order(100, "slow", domain.Buy, 990, 50) // scheduled first, so wins both ties at 100
+	order(100, "fast", domain.Buy, 990, 99) // contested
+	order(100, "fast", domain.Sell, 1010, 99)
+	order(100, "slow", domain.Sell, 1010, 50) // same trader as the first: no tie with it
+	order(200, "fast", domain.Buy, 990, 150)
+	order(200, "slow", domain.Sell, 1010, 150)
+	order(300, "background", domain.Buy, 990, 0)
+	el.Run()
+
+	pairs := audit.Pairs()
+	if len(pairs) != 1 {
+		t.Fatalf("pairs = %+v, want fast/slow only", pairs)
+	}
+	// At 100: slow1 < fast1, slow1 < fast2, fast1 < slow2, fast2 < slow2
+	// (contested); at 200: fast < slow, decided at the same time
+	want := TiePair{A: "fast", B: "slow", Ties: 5, AFirst: 3, BFirst: 2, Contested: 2, ContestedAFirst: 1, SameDecision: 1}
+	if pairs[0] != want {
+		t.Errorf("got %+v, want %+v", pairs[0], want)
+	}
+}
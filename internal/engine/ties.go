@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// TiePair is how the loop ordered two traders' messages that arrived at
+// the same timestamp. With equal keys the loop goes by SeqNo, i.e. by
+// which message was scheduled first, so a tie is decided by scheduling
+// rather than by modeled latency
+type TiePair struct {
+	A string `json:"a"` // trader IDs, A < B
+	B string `json:"b"`
+
+	// Ties counts pairs of messages, one from each trader, handled at the
+	// same timestamp, and how many of them went A's or B's way
+	Ties   int `json:"ties"`
+	AFirst int `json:"a_first"`
+	BFirst int `json:"b_first"`
+
+	// Contested ties competed for the same thing: orders on the same side
+	// at the same price, or market orders on the same side. The first
+	// took the liquidity or the queue spot
+	Contested       int `json:"contested"`
+	ContestedAFirst int `json:"contested_a_first"`
+
+	// SameDecision ties were also decided at the same time, so nothing
+	// but the order the runner dispatched them in separated them
+	SameDecision int `json:"same_decision"`
+}
+
+// tieEntry is a message handled at the current timestamp
+type tieEntry struct {
+	trader   string
+	side     domain.Side
+	typ      domain.OrderType
+	price    int64
+	decision int64
+}
+
+// TieAudit records same-timestamp collisions between traders' messages
+// as the loop hands them out. Feed it every message in handling order
+type TieAudit struct {
+	ts    int64
+	group []tieEntry
+	pairs map[[2]string]*TiePair
+}
+
+// NewTieAudit returns an empty audit
+func NewTieAudit() *TieAudit {
+	return &TieAudit{ts: -1, pairs: make(map[[2]string]*TiePair)}
+}
+
+// Observe records a message being handled. Events without an order, and
+// derived events (SeqNo 0) released by a venue mechanism, are ignored
+func (a *TieAudit) Observe(e *domain.Event) {
+	o := e.Order()
+	if o == nil || e.SeqNo == 0 || e.Type != domain.EventOrderAccepted {
+		return
+	}
+	if e.Timestamp != a.ts {
+		a.ts, a.group = e.Timestamp, a.group[:0]
+	}
+	next := tieEntry{trader: o.TraderID, side: o.Side, typ: o.Type, price: o.Price, decision: o.DecisionTime}
+	for _, first := range a.group {
+		if first.trader != next.trader {
+			a.record(first, next)
+		}
+	}
+	a.group = append(a.group, next)
+}
+
+// record counts a tie that first won
+func (a *TieAudit) record(first, second tieEntry) {
+	key := [2]string{first.trader, second.trader}
+	aFirst := true
+	if key[0] > key[1] {
+		key[0], key[1], aFirst = key[1], key[0], false
+	}
+	p := a.pairs[key]
+	if p == nil {
+		p = &TiePair{A: key[0], B: key[1]}
+		a.pairs[key] = p
+	}
+	p.Ties++
+	if aFirst {
+		p.AFirst++
+	} else {
+		p.BFirst++
+	}
+	if first.typ != domain.CancelOrder && first.typ == second.typ && first.side == second.side && first.price == second.price {
+		p.Contested++
+		if aFirst {
+			p.ContestedAFirst++
+		}
+	}
+	if first.decision == second.decision {
+		p.SameDecision++
+	}
+}
+
+// Pairs returns the trader pairs that tied at least once, sorted
+func (a *TieAudit) Pairs() []TiePair {
+	out := make([]TiePair, 0, len(a.pairs))
+	for _, p := range a.pairs {
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].A != out[j].A {
+			return out[i].A < out[j].A
+		}
+		return out[i].B < out[j].B
+	})
+	return out
+}
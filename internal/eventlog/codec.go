@@ -0,0 +1,1061 @@
+package eventlog
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// --- Hand-rolled event codec ---
+// encoding/json reflection dominates run time when logging every event
+// These encoders produce output byte-identical to json.Marshal (same field
+// order, omitempty rules, HTML-safe string escaping, and float formatting),
+// so log hashes are the same with either codec. Fields added to the domain
+// types must be mirrored here; TestCodecMatchesEncodingJSON guards that
+
+// appendEvent appends the JSON encoding of e
+func appendEvent(b []byte, e *domain.Event) ([]byte, error) {
+	var err error
+	b = append(b, `{"seq_no":`...)
+	b = strconv.AppendUint(b, e.SeqNo, 10)
+	b = append(b, `,"timestamp":`...)
+	b = strconv.AppendInt(b, e.Timestamp, 10)
+	b = append(b, `,"type":`...)
+	b = appendEnum(b, e.Type.String())
+	if e.TraderID != "" {
+		b = append(b, `,"trader_id":`...)
+		b = appendString(b, e.TraderID)
+	}
+	switch p := e.Payload.(type) {
+	case *domain.Order:
+		if p != nil {
+			b = append(b, `,"order":`...)
+			b = appendOrder(b, p)
+		}
+	case *domain.Trade:
+		if p != nil {
+			b = append(b, `,"trade":`...)
+			b = appendTrade(b, p)
+		}
+	case *domain.BBO:
+		if p != nil {
+			b = append(b, `,"bbo":`...)
+			b = appendBBO(b, p)
+		}
+	case *domain.Signal:
+		if p != nil {
+			b = append(b, `,"signal":`...)
+			if b, err = appendSignal(b, p); err != nil {
+				return nil, err
+			}
+		}
+	case *domain.StateChange:
+		if p != nil {
+			b = append(b, `,"state":`...)
+			b = appendState(b, p)
+		}
+	case *domain.Account:
+		if p != nil {
+			b = append(b, `,"account":`...)
+			b = appendAccount(b, p)
+		}
+	case *domain.Depth:
+		if p != nil {
+			b = append(b, `,"depth":`...)
+			b = appendDepth(b, p)
+		}
+	}
+	if len(e.Meta) > 0 {
+		b = append(b, `,"meta":{`...)
+		for i, k := range detorder.Keys(e.Meta) {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendString(b, k)
+			b = append(b, ':')
+			b = appendString(b, e.Meta[k])
+		}
+		b = append(b, '}')
+	}
+	return append(b, '}'), nil
+}
+
+func appendOrder(b []byte, o *domain.Order) []byte {
+	b = append(b, `{"id":`...)
+	b = strconv.AppendUint(b, o.ID, 10)
+	b = append(b, `,"trader_id":`...)
+	b = appendString(b, o.TraderID)
+	b = append(b, `,"side":`...)
+	b = appendEnum(b, o.Side.String())
+	b = append(b, `,"type":`...)
+	b = appendEnum(b, o.Type.String())
+	b = append(b, `,"price":`...)
+	b = strconv.AppendInt(b, o.Price, 10)
+	b = append(b, `,"qty":`...)
+	b = strconv.AppendInt(b, o.Qty, 10)
+	b = append(b, `,"remaining_qty":`...)
+	b = strconv.AppendInt(b, o.RemainingQty, 10)
+	b = append(b, `,"decision_time":`...)
+	b = strconv.AppendInt(b, o.DecisionTime, 10)
+	b = append(b, `,"arrival_time":`...)
+	b = strconv.AppendInt(b, o.ArrivalTime, 10)
+	b = append(b, `,"seq_no":`...)
+	b = strconv.AppendUint(b, o.SeqNo, 10)
+	if o.CancelID != 0 {
+		b = append(b, `,"cancel_id":`...)
+		b = strconv.AppendUint(b, o.CancelID, 10)
+	}
+	if o.QueuePos != 0 {
+		b = append(b, `,"queue_pos":`...)
+		b = strconv.AppendInt(b, int64(o.QueuePos), 10)
+	}
+	if o.TimeInForce != domain.Day {
+		b = append(b, `,"tif":`...)
+		b = appendEnum(b, o.TimeInForce.String())
+	}
+	if o.DisplayQty != 0 {
+		b = append(b, `,"display_qty":`...)
+		b = strconv.AppendInt(b, o.DisplayQty, 10)
+	}
+	return append(b, '}')
+}
+
+func appendTrade(b []byte, t *domain.Trade) []byte {
+	b = append(b, `{"id":`...)
+	b = strconv.AppendUint(b, t.ID, 10)
+	b = append(b, `,"buy_order_id":`...)
+	b = strconv.AppendUint(b, t.BuyOrderID, 10)
+	b = append(b, `,"sell_order_id":`...)
+	b = strconv.AppendUint(b, t.SellOrderID, 10)
+	b = append(b, `,"buy_trader":`...)
+	b = appendString(b, t.BuyTrader)
+	b = append(b, `,"sell_trader":`...)
+	b = appendString(b, t.SellTrader)
+	b = append(b, `,"price":`...)
+	b = strconv.AppendInt(b, t.Price, 10)
+	b = append(b, `,"qty":`...)
+	b = strconv.AppendInt(b, t.Qty, 10)
+	b = append(b, `,"timestamp":`...)
+	b = strconv.AppendInt(b, t.Timestamp, 10)
+	if t.PassiveOrderID != 0 {
+		b = append(b, `,"passive_order_id":`...)
+		b = strconv.AppendUint(b, t.PassiveOrderID, 10)
+	}
+	if t.AggressorOrderID != 0 {
+		b = append(b, `,"aggressor_order_id":`...)
+		b = strconv.AppendUint(b, t.AggressorOrderID, 10)
+	}
+	if t.RestingQueuePos != 0 {
+		b = append(b, `,"resting_queue_pos":`...)
+		b = strconv.AppendInt(b, int64(t.RestingQueuePos), 10)
+	}
+	if t.PlacedAhead != 0 {
+		b = append(b, `,"placed_ahead":`...)
+		b = strconv.AppendInt(b, int64(t.PlacedAhead), 10)
+	}
+	if t.PlacedAheadQty != 0 {
+		b = append(b, `,"placed_ahead_qty":`...)
+		b = strconv.AppendInt(b, t.PlacedAheadQty, 10)
+	}
+	if t.AheadQty != 0 {
+		b = append(b, `,"ahead_qty":`...)
+		b = strconv.AppendInt(b, t.AheadQty, 10)
+	}
+	return append(b, '}')
+}
+
+func appendBBO(b []byte, q *domain.BBO) []byte {
+	b = append(b, `{"bid_price":`...)
+	b = strconv.AppendInt(b, q.BidPrice, 10)
+	b = append(b, `,"bid_qty":`...)
+	b = strconv.AppendInt(b, q.BidQty, 10)
+	b = append(b, `,"ask_price":`...)
+	b = strconv.AppendInt(b, q.AskPrice, 10)
+	b = append(b, `,"ask_qty":`...)
+	b = strconv.AppendInt(b, q.AskQty, 10)
+	b = append(b, `,"mid_price":`...)
+	b = strconv.AppendInt(b, q.MidPrice, 10)
+	return append(b, '}')
+}
+
+func appendSignal(b []byte, s *domain.Signal) ([]byte, error) {
+	var err error
+	b = append(b, `{"value":`...)
+	if b, err = appendFloat(b, s.Value); err != nil {
+		return nil, err
+	}
+	b = append(b, `,"mid_price":`...)
+	b = strconv.AppendInt(b, s.MidPrice, 10)
+	if s.HalfLifeNs != 0 {
+		b = append(b, `,"half_life_ns":`...)
+		b = strconv.AppendInt(b, s.HalfLifeNs, 10)
+	}
+	if s.Imbalance != 0 {
+		b = append(b, `,"imbalance":`...)
+		if b, err = appendFloat(b, s.Imbalance); err != nil {
+			return nil, err
+		}
+	}
+	if s.TradeFlow != 0 {
+		b = append(b, `,"trade_flow":`...)
+		b = strconv.AppendInt(b, s.TradeFlow, 10)
+	}
+	if s.TradeVolume != 0 {
+		b = append(b, `,"trade_volume":`...)
+		b = strconv.AppendInt(b, s.TradeVolume, 10)
+	}
+	return append(b, '}'), nil
+}
+
+func appendState(b []byte, c *domain.StateChange) []byte {
+	b = append(b, `{"order_id":`...)
+	b = strconv.AppendUint(b, c.OrderID, 10)
+	b = append(b, `,"trader_id":`...)
+	b = appendString(b, c.TraderID)
+	b = append(b, `,"from":`...)
+	b = appendEnum(b, c.From.String())
+	b = append(b, `,"to":`...)
+	b = appendEnum(b, c.To.String())
+	b = append(b, `,"filled_qty":`...)
+	b = strconv.AppendInt(b, c.FilledQty, 10)
+	b = append(b, `,"remaining_qty":`...)
+	b = strconv.AppendInt(b, c.RemainingQty, 10)
+	if c.Reason != "" {
+		b = append(b, `,"reason":`...)
+		b = appendString(b, c.Reason)
+	}
+	return append(b, '}')
+}
+
+func appendAccount(b []byte, a *domain.Account) []byte {
+	b = append(b, `{"trader_id":`...)
+	b = appendString(b, a.TraderID)
+	b = append(b, `,"cash":`...)
+	b = strconv.AppendInt(b, a.Cash, 10)
+	b = append(b, `,"position":`...)
+	b = strconv.AppendInt(b, a.Position, 10)
+	b = append(b, `,"cost_basis":`...)
+	b = strconv.AppendInt(b, a.CostBasis, 10)
+	b = append(b, `,"fees":`...)
+	b = strconv.AppendInt(b, a.Fees, 10)
+	b = append(b, `,"realized_pnl":`...)
+	b = strconv.AppendInt(b, a.RealizedPnL, 10)
+	b = append(b, `,"volume":`...)
+	b = strconv.AppendInt(b, a.Volume, 10)
+	b = append(b, `,"fills":`...)
+	b = strconv.AppendInt(b, int64(a.Fills), 10)
+	return append(b, '}')
+}
+
+func appendDepth(b []byte, d *domain.Depth) []byte {
+	b = append(b, '{')
+	sep := false
+	for _, side := range []struct {
+		key    string
+		levels []domain.DepthLevel
+	}{{`"bids":[`, d.Bids}, {`"asks":[`, d.Asks}} {
+		if len(side.levels) == 0 {
+			continue
+		}
+		if sep {
+			b = append(b, ',')
+		}
+		sep = true
+		b = append(b, side.key...)
+		for i, lv := range side.levels {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = append(b, `{"price":`...)
+			b = strconv.AppendInt(b, lv.Price, 10)
+			b = append(b, `,"qty":`...)
+			b = strconv.AppendInt(b, lv.Qty, 10)
+			b = append(b, `,"orders":`...)
+			b = strconv.AppendInt(b, int64(lv.Orders), 10)
+			b = append(b, '}')
+		}
+		b = append(b, ']')
+	}
+	return append(b, '}')
+}
+
+// appendEnum writes an enum name; names are plain ASCII and need no escaping
+func appendEnum(b []byte, name string) []byte {
+	b = append(b, '"')
+	b = append(b, name...)
+	return append(b, '"')
+}
+
+// appendFloat mirrors encoding/json's float64 formatting
+func appendFloat(b []byte, f float64) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("unsupported float value: %v", f)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendString mirrors encoding/json's HTML-safe string escaping
+func appendString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '\\', '"':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
+
+// --- Decoding ---
+
+var errSyntax = errors.New("eventlog: unsupported JSON")
+
+var errMultiplePayloads = errors.New("event has more than one payload")
+
+// decodeEvent parses a single event line. It handles everything the encoder
+// emits plus whitespace, unknown keys, and nulls; anything else returns an
+// error and the caller falls back to encoding/json
+func decodeEvent(data []byte, e *domain.Event) error {
+	d := decoder{data: data}
+	err := d.object(func(key []byte) error {
+		switch string(key) {
+		case "seq_no":
+			return d.uint(&e.SeqNo)
+		case "timestamp":
+			return d.int(&e.Timestamp)
+		case "type":
+			return d.enum(e.Type.UnmarshalJSON)
+		case "trader_id":
+			return d.str(&e.TraderID)
+		case "order":
+			if d.null() {
+				return nil
+			}
+			o := &domain.Order{}
+			if err := setPayload(e, o); err != nil {
+				return err
+			}
+			return d.order(o)
+		case "trade":
+			if d.null() {
+				return nil
+			}
+			t := &domain.Trade{}
+			if err := setPayload(e, t); err != nil {
+				return err
+			}
+			return d.trade(t)
+		case "bbo":
+			if d.null() {
+				return nil
+			}
+			q := &domain.BBO{}
+			if err := setPayload(e, q); err != nil {
+				return err
+			}
+			return d.bbo(q)
+		case "signal":
+			if d.null() {
+				return nil
+			}
+			sig := &domain.Signal{}
+			if err := setPayload(e, sig); err != nil {
+				return err
+			}
+			return d.signal(sig)
+		case "state":
+			if d.null() {
+				return nil
+			}
+			c := &domain.StateChange{}
+			if err := setPayload(e, c); err != nil {
+				return err
+			}
+			return d.state(c)
+		case "account":
+			if d.null() {
+				return nil
+			}
+			a := &domain.Account{}
+			if err := setPayload(e, a); err != nil {
+				return err
+			}
+			return d.account(a)
+		case "depth":
+			if d.null() {
+				return nil
+			}
+			dp := &domain.Depth{}
+			if err := setPayload(e, dp); err != nil {
+				return err
+			}
+			return d.depth(dp)
+		case "meta":
+			if d.null() {
+				return nil
+			}
+			return d.meta(e)
+		default:
+			return d.skip()
+		}
+	})
+	if err != nil {
+		return err
+	}
+	d.ws()
+	if d.pos != len(d.data) {
+		return errSyntax
+	}
+	return nil
+}
+
+// setPayload sets e's payload; a second payload key is an error, as
+// with encoding/json
+func setPayload(e *domain.Event, p domain.Payload) error {
+	if e.Payload != nil {
+		return errMultiplePayloads
+	}
+	e.Payload = p
+	return nil
+}
+
+// meta reads annotations, keeping any already read under an earlier
+// "meta" key as encoding/json does. Unlike payload fields, keys are
+// arbitrary strings and may be escaped
+func (d *decoder) meta(e *domain.Event) error {
+	if d.peek() != '{' {
+		return errSyntax
+	}
+	d.pos++
+	if e.Meta == nil {
+		e.Meta = make(map[string]string)
+	}
+	if d.peek() == '}' {
+		d.pos++
+		return nil
+	}
+	for {
+		var k, v string
+		if d.peek() != '"' {
+			return errSyntax
+		}
+		if err := d.str(&k); err != nil {
+			return err
+		}
+		if d.peek() != ':' {
+			return errSyntax
+		}
+		d.pos++
+		if err := d.str(&v); err != nil {
+			return err
+		}
+		e.Meta[k] = v
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case '}':
+			d.pos++
+			return nil
+		default:
+			return errSyntax
+		}
+	}
+}
+
+func (d *decoder) order(o *domain.Order) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "id":
+			return d.uint(&o.ID)
+		case "trader_id":
+			return d.str(&o.TraderID)
+		case "side":
+			return d.enum(o.Side.UnmarshalJSON)
+		case "type":
+			return d.enum(o.Type.UnmarshalJSON)
+		case "price":
+			return d.int(&o.Price)
+		case "qty":
+			return d.int(&o.Qty)
+		case "remaining_qty":
+			return d.int(&o.RemainingQty)
+		case "decision_time":
+			return d.int(&o.DecisionTime)
+		case "arrival_time":
+			return d.int(&o.ArrivalTime)
+		case "seq_no":
+			return d.uint(&o.SeqNo)
+		case "cancel_id":
+			return d.uint(&o.CancelID)
+		case "queue_pos":
+			return d.intn(&o.QueuePos)
+		case "tif":
+			return d.enum(o.TimeInForce.UnmarshalJSON)
+		case "display_qty":
+			return d.int(&o.DisplayQty)
+		default:
+			return d.skip()
+		}
+	})
+}
+
+func (d *decoder) trade(t *domain.Trade) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "id":
+			return d.uint(&t.ID)
+		case "buy_order_id":
+			return d.uint(&t.BuyOrderID)
+		case "sell_order_id":
+			return d.uint(&t.SellOrderID)
+		case "buy_trader":
+			return d.str(&t.BuyTrader)
+		case "sell_trader":
+			return d.str(&t.SellTrader)
+		case "price":
+			return d.int(&t.Price)
+		case "qty":
+			return d.int(&t.Qty)
+		case "timestamp":
+			return d.int(&t.Timestamp)
+		case "passive_order_id":
+			return d.uint(&t.PassiveOrderID)
+		case "aggressor_order_id":
+			return d.uint(&t.AggressorOrderID)
+		case "resting_queue_pos":
+			return d.intn(&t.RestingQueuePos)
+		case "placed_ahead":
+			return d.intn(&t.PlacedAhead)
+		case "placed_ahead_qty":
+			return d.int(&t.PlacedAheadQty)
+		case "ahead_qty":
+			return d.int(&t.AheadQty)
+		default:
+			return d.skip()
+		}
+	})
+}
+
+func (d *decoder) bbo(q *domain.BBO) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "bid_price":
+			return d.int(&q.BidPrice)
+		case "bid_qty":
+			return d.int(&q.BidQty)
+		case "ask_price":
+			return d.int(&q.AskPrice)
+		case "ask_qty":
+			return d.int(&q.AskQty)
+		case "mid_price":
+			return d.int(&q.MidPrice)
+		default:
+			return d.skip()
+		}
+	})
+}
+
+func (d *decoder) signal(s *domain.Signal) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "value":
+			return d.float(&s.Value)
+		case "mid_price":
+			return d.int(&s.MidPrice)
+		case "half_life_ns":
+			return d.int(&s.HalfLifeNs)
+		case "imbalance":
+			return d.float(&s.Imbalance)
+		case "trade_flow":
+			return d.int(&s.TradeFlow)
+		case "trade_volume":
+			return d.int(&s.TradeVolume)
+		default:
+			return d.skip()
+		}
+	})
+}
+
+func (d *decoder) state(c *domain.StateChange) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "order_id":
+			return d.uint(&c.OrderID)
+		case "trader_id":
+			return d.str(&c.TraderID)
+		case "from":
+			return d.enum(c.From.UnmarshalJSON)
+		case "to":
+			return d.enum(c.To.UnmarshalJSON)
+		case "filled_qty":
+			return d.int(&c.FilledQty)
+		case "remaining_qty":
+			return d.int(&c.RemainingQty)
+		case "reason":
+			return d.str(&c.Reason)
+		default:
+			return d.skip()
+		}
+	})
+}
+
+func (d *decoder) account(a *domain.Account) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "trader_id":
+			return d.str(&a.TraderID)
+		case "cash":
+			return d.int(&a.Cash)
+		case "position":
+			return d.int(&a.Position)
+		case "cost_basis":
+			return d.int(&a.CostBasis)
+		case "fees":
+			return d.int(&a.Fees)
+		case "realized_pnl":
+			return d.int(&a.RealizedPnL)
+		case "volume":
+			return d.int(&a.Volume)
+		case "fills":
+			return d.intn(&a.Fills)
+		default:
+			return d.skip()
+		}
+	})
+}
+
+func (d *decoder) depth(dp *domain.Depth) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "bids":
+			return d.levels(&dp.Bids)
+		case "asks":
+			return d.levels(&dp.Asks)
+		default:
+			return d.skip()
+		}
+	})
+}
+
+// levels reads an array of depth levels; null leaves it nil, as with
+// encoding/json, and [] makes it empty
+func (d *decoder) levels(out *[]domain.DepthLevel) error {
+	if d.null() {
+		*out = nil
+		return nil
+	}
+	if d.peek() != '[' {
+		return errSyntax
+	}
+	d.pos++
+	*out = []domain.DepthLevel{}
+	if d.peek() == ']' {
+		d.pos++
+		return nil
+	}
+	for {
+		var lv domain.DepthLevel
+		err := d.object(func(key []byte) error {
+			switch string(key) {
+			case "price":
+				return d.int(&lv.Price)
+			case "qty":
+				return d.int(&lv.Qty)
+			case "orders":
+				return d.intn(&lv.Orders)
+			default:
+				return d.skip()
+			}
+		})
+		if err != nil {
+			return err
+		}
+		*out = append(*out, lv)
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case ']':
+			d.pos++
+			return nil
+		default:
+			return errSyntax
+		}
+	}
+}
+
+// decoder is a minimal cursor over one JSON document
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) ws() {
+	for d.pos < len(d.data) {
+		switch d.data[d.pos] {
+		case ' ', '\t', '\n', '\r':
+			d.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (d *decoder) peek() byte {
+	d.ws()
+	if d.pos >= len(d.data) {
+		return 0
+	}
+	return d.data[d.pos]
+}
+
+func (d *decoder) null() bool {
+	if d.peek() == 'n' && len(d.data)-d.pos >= 4 && string(d.data[d.pos:d.pos+4]) == "null" {
+		d.pos += 4
+		return true
+	}
+	return false
+}
+
+// object walks the members of an object, calling field for each key with
+// the cursor positioned at its value
+func (d *decoder) object(field func(key []byte) error) error {
+	if d.peek() != '{' {
+		return errSyntax
+	}
+	d.pos++
+	if d.peek() == '}' {
+		d.pos++
+		return nil
+	}
+	for {
+		key, err := d.rawKey()
+		if err != nil {
+			return err
+		}
+		if d.peek() != ':' {
+			return errSyntax
+		}
+		d.pos++
+		if err := field(key); err != nil {
+			return err
+		}
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case '}':
+			d.pos++
+			return nil
+		default:
+			return errSyntax
+		}
+	}
+}
+
+// rawKey returns an unescaped key as a slice of the input
+func (d *decoder) rawKey() ([]byte, error) {
+	if d.peek() != '"' {
+		return nil, errSyntax
+	}
+	start := d.pos + 1
+	for i := start; i < len(d.data); i++ {
+		switch d.data[i] {
+		case '\\':
+			return nil, errSyntax
+		case '"':
+			d.pos = i + 1
+			return d.data[start:i], nil
+		}
+	}
+	return nil, errSyntax
+}
+
+func (d *decoder) number() ([]byte, error) {
+	d.ws()
+	start := d.pos
+	for d.pos < len(d.data) {
+		c := d.data[d.pos]
+		if (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E' {
+			d.pos++
+			continue
+		}
+		break
+	}
+	if d.pos == start {
+		return nil, errSyntax
+	}
+	return d.data[start:d.pos], nil
+}
+
+func (d *decoder) int(v *int64) error {
+	if d.null() {
+		return nil
+	}
+	n, err := d.number()
+	if err != nil {
+		return err
+	}
+	*v, err = strconv.ParseInt(string(n), 10, 64)
+	return err
+}
+
+func (d *decoder) intn(v *int) error {
+	var x int64
+	if err := d.int(&x); err != nil {
+		return err
+	}
+	*v = int(x)
+	return nil
+}
+
+func (d *decoder) uint(v *uint64) error {
+	if d.null() {
+		return nil
+	}
+	n, err := d.number()
+	if err != nil {
+		return err
+	}
+	*v, err = strconv.ParseUint(string(n), 10, 64)
+	return err
+}
+
+func (d *decoder) float(v *float64) error {
+	if d.null() {
+		return nil
+	}
+	n, err := d.number()
+	if err != nil {
+		return err
+	}
+	*v, err = strconv.ParseFloat(string(n), 64)
+	return err
+}
+
+// enum hands the raw value (quoted name or bare integer) to UnmarshalJSON
+func (d *decoder) enum(unmarshal func([]byte) error) error {
+	if d.null() {
+		return nil
+	}
+	start := d.pos
+	if err := d.skip(); err != nil {
+		return err
+	}
+	return unmarshal(d.data[start:d.pos])
+}
+
+func (d *decoder) str(v *string) error {
+	if d.null() {
+		return nil
+	}
+	if d.peek() != '"' {
+		return errSyntax
+	}
+	d.pos++
+	start := d.pos
+	for d.pos < len(d.data) {
+		c := d.data[d.pos]
+		if c == '"' {
+			*v = string(d.data[start:d.pos])
+			d.pos++
+			return nil
+		}
+		if c == '\\' || c < 0x20 || c >= utf8.RuneSelf {
+			d.pos = start
+			return d.slowStr(v)
+		}
+		d.pos++
+	}
+	return errSyntax
+}
+
+// slowStr decodes a string containing escapes or non-ASCII bytes
+func (d *decoder) slowStr(v *string) error {
+	var out []byte
+	for d.pos < len(d.data) {
+		c := d.data[d.pos]
+		switch {
+		case c == '"':
+			d.pos++
+			*v = string(out)
+			return nil
+		case c < 0x20:
+			return errSyntax
+		case c == '\\':
+			if d.pos+1 >= len(d.data) {
+				return errSyntax
+			}
+			d.pos++
+			switch e := d.data[d.pos]; e {
+			case '"', '\\', '/':
+				out = append(out, e)
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'u':
+				r, ok := d.hex4(d.pos + 1)
+				if !ok {
+					return errSyntax
+				}
+				d.pos += 4
+				if utf16.IsSurrogate(r) {
+					if r2, ok := d.hex4(d.pos + 3); ok && d.data[d.pos+1] == '\\' && d.data[d.pos+2] == 'u' {
+						if dec := utf16.DecodeRune(r, r2); dec != utf8.RuneError {
+							d.pos += 6
+							r = dec
+						} else {
+							r = utf8.RuneError
+						}
+					} else {
+						r = utf8.RuneError
+					}
+				}
+				out = utf8.AppendRune(out, r)
+			default:
+				return errSyntax
+			}
+			d.pos++
+		case c < utf8.RuneSelf:
+			out = append(out, c)
+			d.pos++
+		default:
+			r, size := utf8.DecodeRune(d.data[d.pos:])
+			if r == utf8.RuneError && size == 1 {
+				out = utf8.AppendRune(out, utf8.RuneError)
+			} else {
+				out = append(out, d.data[d.pos:d.pos+size]...)
+			}
+			d.pos += size
+		}
+	}
+	return errSyntax
+}
+
+func (d *decoder) hex4(at int) (rune, bool) {
+	if at+4 > len(d.data) {
+		return 0, false
+	}
+	var r rune
+	for _, c := range d.data[at : at+4] {
+		switch {
+		case c >= '0' && c <= '9':
+			r = r<<4 | rune(c-'0')
+		case c >= 'a' && c <= 'f':
+			r = r<<4 | rune(c-'a'+10)
+		case c >= 'A' && c <= 'F':
+			r = r<<4 | rune(c-'A'+10)
+		default:
+			return 0, false
+		}
+	}
+	return r, true
+}
+
+// skip advances past one value of any type
+func (d *decoder) skip() error {
+	switch c := d.peek(); {
+	case c == '"':
+		var s string
+		return d.str(&s)
+	case c == '{':
+		return d.object(func([]byte) error { return d.skip() })
+	case c == '[':
+		d.pos++
+		if d.peek() == ']' {
+			d.pos++
+			return nil
+		}
+		for {
+			if err := d.skip(); err != nil {
+				return err
+			}
+			switch d.peek() {
+			case ',':
+				d.pos++
+			case ']':
+				d.pos++
+				return nil
+			default:
+				return errSyntax
+			}
+		}
+	case c == 't' || c == 'f' || c == 'n':
+		for _, lit := range []string{"true", "false", "null"} {
+			if len(d.data)-d.pos >= len(lit) && string(d.data[d.pos:d.pos+len(lit)]) == lit {
+				d.pos += len(lit)
+				return nil
+			}
+		}
+		return errSyntax
+	default:
+		_, err := d.number()
+		return err
+	}
+}
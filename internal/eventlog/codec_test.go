@@ -0,0 +1,208 @@
+package eventlog
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+func codecSamples() []*domain.Event {
+	return []*domain.Event{
+		{SeqNo: 1, Timestamp: 0, Type: domain.EventSimStart},
+		{SeqNo: 2, Timestamp: 5, Type: domain.EventReQuote, TraderID: "fast"},
+		{
+			SeqNo: 3, Timestamp: 10, Type: domain.EventOrderAccepted,
+			Payload: &domain.Order{
+				ID: 1_000_001, TraderID: "slow", Side: domain.Buy, Type: domain.LimitOrder,
+				Price: 999_900, Qty: 5, RemainingQty: 5, DecisionTime: 1, ArrivalTime: 10,
+				SeqNo: 3, QueuePos: 4,
+			},
+		},
+		{
+			SeqNo: 4, Timestamp: 11, Type: domain.EventOrderCanceled,
+			Payload: &domain.Order{ID: 7, TraderID: "background", Type: domain.CancelOrder, CancelID: 3},
+		},
+		{
+			SeqNo: 4, Timestamp: 11, Type: domain.EventOrderAccepted,
+			Payload: &domain.Order{
+				ID: 8, TraderID: "fast", Side: domain.Sell, Type: domain.LimitOrder,
+				Price: 999_900, Qty: 5, RemainingQty: 2, TimeInForce: domain.IOC,
+			},
+		},
+		{
+			Timestamp: 11, Type: domain.EventIcebergRefresh,
+			Payload: &domain.Order{
+				ID: 3, TraderID: "slow", Side: domain.Buy, Type: domain.LimitOrder,
+				Price: 999_900, Qty: 20, RemainingQty: 15, DisplayQty: 5, QueuePos: 2,
+			},
+		},
+		{
+			SeqNo: 5, Timestamp: 12, Type: domain.EventTradeExecuted,
+			Payload: &domain.Trade{
+				ID: 9, BuyOrderID: 1, SellOrderID: 2, BuyTrader: "fast", SellTrader: "background",
+				Price: 1_000_100, Qty: 3, Timestamp: 12,
+				PassiveOrderID: 2, AggressorOrderID: 1, RestingQueuePos: 1,
+			},
+		},
+		{
+			SeqNo: 6, Timestamp: 13, Type: domain.EventTradeExecuted,
+			Payload: &domain.Trade{ID: 10, Price: -5, Qty: 0},
+		},
+		{
+			SeqNo: 7, Timestamp: 14, Type: domain.EventBBOUpdate,
+			Payload: &domain.BBO{BidPrice: 999_900, BidQty: 40, AskPrice: 1_000_100, AskQty: 12, MidPrice: 1_000_000},
+		},
+		{SeqNo: 8, Timestamp: 15, Type: domain.EventSignal, Payload: &domain.Signal{Value: -0.7312345678901234, MidPrice: 1}},
+		{SeqNo: 9, Timestamp: 16, Type: domain.EventSignal, Payload: &domain.Signal{Value: 1e-9, HalfLifeNs: 250_000_000}},
+		{SeqNo: 10, Timestamp: 17, Type: domain.EventSignal, Payload: &domain.Signal{Value: 3.5e21}},
+		{SeqNo: 11, Timestamp: 18, Type: domain.EventSignal, Payload: &domain.Signal{Value: math.Copysign(0, -1)}},
+		{SeqNo: 12, Timestamp: 18, Type: domain.EventSignal, Payload: &domain.Signal{Value: 0.2, MidPrice: 1, Imbalance: -0.25, TradeFlow: -7, TradeVolume: 19}},
+		{
+			Timestamp: 18, Type: domain.EventOrderState,
+			Payload: &domain.StateChange{OrderID: 7, TraderID: "slow", From: domain.StateNew, To: domain.StateRejected, Reason: "target 3 already filled"},
+		},
+		{
+			Timestamp: 18, Type: domain.EventOrderState,
+			Payload: &domain.StateChange{OrderID: 1_000_001, TraderID: "slow", From: domain.StateResting, To: domain.StatePartiallyFilled, FilledQty: 2, RemainingQty: 3},
+		},
+		{
+			Timestamp: 18, Type: domain.EventAccountUpdate, TraderID: "fast",
+			Payload: &domain.Account{TraderID: "fast", Cash: -3_000_309, Position: 3, CostBasis: 3_000_300, Fees: 9, Volume: 3, Fills: 1},
+		},
+		{
+			SeqNo: 12, Timestamp: 19, Type: domain.EventOrderAccepted,
+			Payload: &domain.Order{ID: 8, TraderID: "background", Side: domain.Sell, Type: domain.MarketOrder, Qty: 4},
+			Meta:    map[string]string{"burst_id": "3", "risk_check_latency_ns": "1500", "a<b>": "é\u2028"},
+		},
+		{SeqNo: 13, Timestamp: 19, Type: domain.EventReQuote, TraderID: "slow", Meta: map[string]string{}},
+		{Timestamp: 19, Type: domain.EventAuction, Meta: map[string]string{"auction_id": "4", "clearing_price": "100.0100"}},
+		{
+			Timestamp: 19, Type: domain.EventDepth,
+			Payload: &domain.Depth{
+				Bids: []domain.DepthLevel{{Price: 999_900, Qty: 40, Orders: 3}, {Price: 999_800, Qty: 5, Orders: 1}},
+				Asks: []domain.DepthLevel{{Price: 1_000_100, Qty: 12, Orders: 2}},
+			},
+		},
+		{Timestamp: 19, Type: domain.EventDepth, Payload: &domain.Depth{Asks: []domain.DepthLevel{{Price: 1_000_100, Qty: 1, Orders: 1}}}},
+		{Timestamp: 19, Type: domain.EventDepth, Payload: &domain.Depth{}},
+		{SeqNo: 14, Timestamp: 20, Type: domain.EventSession, Meta: map[string]string{"session": "day1", "phase": "closed", "ref_price": "100.0000"}},
+		{SeqNo: 15, Timestamp: 20, Type: domain.EventSimEnd, TraderID: "a<b>&\"q\"\\\n\t\x01é\u2028\xff"},
+	}
+}
+
+// TestCodecMatchesEncodingJSON verifies the hand-rolled encoder is
+// byte-identical to json.Marshal and the decoder agrees with json.Unmarshal
+func TestCodecMatchesEncodingJSON(t *testing.T) {
+	for _, e := range codecSamples() {
+		want, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := appendEvent(nil, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("encoding mismatch:\n  fast: %s\n  std:  %s", got, want)
+			continue
+		}
+
+		var fast, std domain.Event
+		if err := decodeEvent(want, &fast); err != nil {
+			t.Errorf("fast decode of %s: %v", want, err)
+			continue
+		}
+		if err := json.Unmarshal(want, &std); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(fast, std) {
+			t.Errorf("decode mismatch for %s:\n  fast: %+v\n  std:  %+v", want, fast, std)
+		}
+	}
+}
+
+func TestDecodeAcceptsIntegerEnumsAndUnknownKeys(t *testing.T) {
+	line := []byte(` {"seq_no": 1, "type": 2, "extra": [1, {"x": null}, "s"], "trade": {"id": 4, "buy_trader": "f\u0061st"}} `)
+	var fast, std domain.Event
+	if err := decodeEvent(line, &fast); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(line, &std); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fast, std) {
+		t.Errorf("decode mismatch:\n  fast: %+v\n  std:  %+v", fast, std)
+	}
+}
+
+func TestDecodeRejectsTwoPayloads(t *testing.T) {
+	line := []byte(`{"seq_no":1,"timestamp":0,"type":"ORDER_ACCEPTED","order":{"id":1},"trade":{"id":2}}`)
+	var e domain.Event
+	if err := decodeEvent(line, &e); err == nil {
+		t.Error("fast decoder accepted two payloads")
+	}
+	if _, err := DecodeLine(line); err == nil {
+		t.Error("DecodeLine accepted two payloads")
+	}
+}
+
+func TestEncodeRejectsNaN(t *testing.T) {
+	e := &domain.Event{Type: domain.EventSignal, Payload: &domain.Signal{Value: math.NaN()}}
+	if _, err := appendEvent(nil, e); err == nil {
+		t.Error("expected error encoding NaN")
+	}
+}
+
+func BenchmarkEncodeFast(b *testing.B) {
+	events := codecSamples()
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, e := range events {
+			buf, _ = appendEvent(buf[:0], e)
+		}
+	}
+}
+
+func BenchmarkEncodeStd(b *testing.B) {
+	events := codecSamples()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, e := range events {
+			json.Marshal(e)
+		}
+	}
+}
+
+func BenchmarkDecodeFast(b *testing.B) {
+	var lines [][]byte
+	for _, e := range codecSamples() {
+		line, _ := json.Marshal(e)
+		lines = append(lines, line)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			var e domain.Event
+			decodeEvent(line, &e)
+		}
+	}
+}
+
+func BenchmarkDecodeStd(b *testing.B) {
+	var lines [][]byte
+	for _, e := range codecSamples() {
+		line, _ := json.Marshal(e)
+		lines = append(lines, line)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			var e domain.Event
+			json.Unmarshal(line, &e)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package eventlog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// Level is how much of a run the event log records. Each level adds to
+// the one before it; SIM_START, SIM_END and SESSION boundaries are
+// always recorded
+type Level int8
+
+const (
+	LevelTrades Level = iota + 1 // trades only
+	LevelOrders                  // + accepted orders, cancel acks and iceberg refreshes
+	LevelBBO                     // + BBO updates
+	LevelDepth                   // + periodic depth snapshots
+	LevelFull                    // + signals, order states, accounts and auctions
+)
+
+var levelNames = []string{LevelTrades: "trades", LevelOrders: "orders", LevelBBO: "bbo", LevelDepth: "depth", LevelFull: "full"}
+
+func (l Level) String() string {
+	if l < LevelTrades || l > LevelFull {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses a level name; "" is LevelFull
+func ParseLevel(s string) (Level, error) {
+	if s == "" {
+		return LevelFull, nil
+	}
+	for l := LevelTrades; l <= LevelFull; l++ {
+		if levelNames[l] == s {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q (%s)", s, strings.Join(levelNames[LevelTrades:], ", "))
+}
+
+// LevelOf is the lowest level that records events of type t
+func LevelOf(t domain.EventType) Level {
+	switch t {
+	case domain.EventSimStart, domain.EventSimEnd, domain.EventSession, domain.EventTradeExecuted:
+		return LevelTrades
+	case domain.EventOrderAccepted, domain.EventOrderCanceled, domain.EventIcebergRefresh:
+		return LevelOrders
+	case domain.EventBBOUpdate:
+		return LevelBBO
+	case domain.EventDepth:
+		return LevelDepth
+	default:
+		return LevelFull
+	}
+}
+
+// Records reports whether a log at level l records events of type t
+func (l Level) Records(t domain.EventType) bool {
+	return LevelOf(t) <= l
+}
+
+// levelKey is the SIM_START annotation declaring a log's level. A log
+// without it is at LevelFull
+const levelKey = "log_level"
+
+// DeclareLevel annotates a log's SIM_START event with its level. Full
+// logs are left unannotated
+func DeclareLevel(start *domain.Event, l Level) {
+	if l != LevelFull {
+		start.Annotate(levelKey, l.String())
+	}
+}
+
+// DeclaredLevel is the level a log's first event declares
+func DeclaredLevel(first *domain.Event) (Level, error) {
+	if first == nil || first.Type != domain.EventSimStart {
+		return LevelFull, nil
+	}
+	name, ok := first.Annotation(levelKey)
+	if !ok {
+		return LevelFull, nil
+	}
+	return ParseLevel(name)
+}
+
+// LevelError reports a log recorded at too low a level for a consumer
+type LevelError struct {
+	Consumer string
+	Need     Level
+	Have     Level
+	Why      string // what needs the higher level, if not all of the consumer
+}
+
+func (e *LevelError) Error() string {
+	msg := fmt.Sprintf("%s needs log level %s or above, but the log is at %s", e.Consumer, e.Need, e.Have)
+	if e.Why != "" {
+		msg += " (" + e.Why + ")"
+	}
+	return msg
+}
@@ -0,0 +1,69 @@
+package eventlog
+
+import (
+	"context"
+	"io"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// DefaultStreamDepth is how many decoded events Stream buffers ahead of
+// its consumer
+const DefaultStreamDepth = 4096
+
+// Each calls fn on every remaining event in log order, holding one at a
+// time. It stops at the first error fn returns, and returns it
+func (r *Reader) Each(fn func(*domain.Event) error) error {
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+}
+
+// Stream decodes the remaining events on a background goroutine and
+// sends them in log order on the returned channel, which closes at the
+// end of the log, on a read error or when ctx is done. At most depth
+// events wait decoded, so a slow consumer holds the reader back instead
+// of memory growing with the log. Once the channel closes, Err reports
+// what ended it. Don't call Next or Close until then; to stop early,
+// cancel ctx and drain the channel
+func (r *Reader) Stream(ctx context.Context, depth int) <-chan *domain.Event {
+	if depth < 1 {
+		depth = 1
+	}
+	ch := make(chan *domain.Event, depth)
+	r.streamErr = nil
+	go func() {
+		defer close(ch)
+		for ctx.Err() == nil {
+			e, err := r.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				r.streamErr = err
+				return
+			}
+			select {
+			case ch <- e:
+			case <-ctx.Done():
+			}
+		}
+		r.streamErr = ctx.Err()
+	}()
+	return ch
+}
+
+// Err is the error that ended the last Stream: nil at the end of the
+// log. Call it once the stream's channel has closed
+func (r *Reader) Err() error {
+	return r.streamErr
+}
@@ -0,0 +1,93 @@
+package eventlog
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+func writeBBOs(t *testing.T, n int) (path, hash string) {
+	t.Helper()
+	path = filepath.Join(t.TempDir(), "events.jsonl")
+	w, err := NewWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := w.Write(&domain.Event{SeqNo: uint64(i), Timestamp: int64(i), Type: domain.EventBBOUpdate,
+			Payload: &domain.BBO{BidPrice: 999_900, AskPrice: 1_000_100}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path, w.Hash()
+}
+
+// TestStream reads a log through a stream shallower than the log, in
+// order and to the same hash, and stops early when canceled
+func TestStream(t *testing.T) {
+	path, hash := writeBBOs(t, 1000)
+
+	r, err := NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.Level(); err != nil {
+		t.Fatal(err)
+	}
+	ch := r.Stream(context.Background(), 8)
+	n := 0
+	for e := range ch {
+		if e.SeqNo != uint64(n) {
+			t.Fatalf("event %d has seq %d", n, e.SeqNo)
+		}
+		if len(ch) > cap(ch) || cap(ch) != 8 {
+			t.Fatalf("stream buffered %d of %d", len(ch), cap(ch))
+		}
+		n++
+	}
+	if err := r.Err(); err != nil || n != 1000 || r.Hash() != hash {
+		t.Errorf("read %d events (%v), hash %s want %s", n, err, r.Hash(), hash)
+	}
+
+	r2, err := NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch = r2.Stream(ctx, 1)
+	<-ch
+	cancel()
+	for range ch {
+	}
+	if !errors.Is(r2.Err(), context.Canceled) {
+		t.Errorf("canceled stream ended with %v", r2.Err())
+	}
+}
+
+func TestEachStopsOnError(t *testing.T) {
+	path, _ := writeBBOs(t, 10)
+	r, err := NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	stop := errors.New("stop")
+	n := 0
+	err = r.Each(func(e *domain.Event) error {
+		if n++; n == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 3 {
+		t.Errorf("Each returned %v after %d events", err, n)
+	}
+}
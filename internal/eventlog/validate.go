@@ -0,0 +1,264 @@
+package eventlog
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// SchemaError reports the first record in a log that breaks the schema
+type SchemaError struct {
+	Line   int // 1-based line number in the log
+	Reason string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("event log line %d: %s", e.Line, e.Reason)
+}
+
+// Validator checks events one at a time against the log schema:
+//   - each event type carries the payload and fields it requires
+//   - timestamps never decrease
+//   - non-zero seq numbers (events that went through the event loop) are
+//     unique and strictly increase among events sharing a timestamp;
+//     events derived while handling another (trades, BBOs, cancels) carry 0
+//   - SIM_START, if present, is the first event and nothing follows SIM_END
+//   - no record is of a type the log's declared level leaves out
+type Validator struct {
+	line    int
+	level   Level
+	lastTS  int64
+	lastSeq uint64 // highest non-zero seq seen at lastTS
+	seqs    map[uint64]bool
+	ended   bool
+}
+
+// NewValidator creates a validator positioned before the first line
+func NewValidator() *Validator {
+	return &Validator{level: LevelFull, seqs: make(map[uint64]bool)}
+}
+
+// Check validates the next event in log order
+func (v *Validator) Check(e *domain.Event) error {
+	v.line++
+	if reason := v.check(e); reason != "" {
+		return &SchemaError{Line: v.line, Reason: reason}
+	}
+	return nil
+}
+
+func (v *Validator) check(e *domain.Event) string {
+	if v.ended && !isExpiry(e) {
+		return fmt.Sprintf("%s after SIM_END", e.Type)
+	}
+	if reason := requiredFields(e); reason != "" {
+		return reason
+	}
+
+	if v.line > 1 && e.Timestamp < v.lastTS {
+		return fmt.Sprintf("timestamp %d before previous %d", e.Timestamp, v.lastTS)
+	}
+	if e.Timestamp != v.lastTS {
+		v.lastSeq = 0
+	}
+	v.lastTS = e.Timestamp
+	if !v.level.Records(e.Type) {
+		return fmt.Sprintf("%s in a log at level %s", e.Type, v.level)
+	}
+
+	if e.SeqNo != 0 {
+		if v.seqs[e.SeqNo] {
+			return fmt.Sprintf("duplicate seq_no %d", e.SeqNo)
+		}
+		if e.SeqNo < v.lastSeq {
+			return fmt.Sprintf("seq_no %d after %d at timestamp %d", e.SeqNo, v.lastSeq, e.Timestamp)
+		}
+		v.seqs[e.SeqNo] = true
+		v.lastSeq = e.SeqNo
+	}
+
+	switch e.Type {
+	case domain.EventSimStart:
+		if v.line != 1 {
+			return "SIM_START is not the first event"
+		}
+		level, err := DeclaredLevel(e)
+		if err != nil {
+			return err.Error()
+		}
+		v.level = level
+	case domain.EventSimEnd:
+		v.ended = true
+	}
+	return ""
+}
+
+// isExpiry reports an order expiring at SIM_END, the one record that
+// follows it
+func isExpiry(e *domain.Event) bool {
+	c := e.StateChange()
+	return c != nil && c.To == domain.StateExpired
+}
+
+// requiredFields checks the payload each event type must carry
+func requiredFields(e *domain.Event) string {
+	switch e.Type {
+	case domain.EventOrderAccepted:
+		o := e.Order()
+		if o == nil {
+			return "ORDER_ACCEPTED without order"
+		}
+		if o.ID == 0 {
+			return "order without id"
+		}
+		switch o.Type {
+		case domain.LimitOrder:
+			if o.Price <= 0 || o.Qty <= 0 {
+				return fmt.Sprintf("limit order %d needs positive price and qty", o.ID)
+			}
+		case domain.MarketOrder:
+			if o.Qty <= 0 {
+				return fmt.Sprintf("market order %d needs positive qty", o.ID)
+			}
+		case domain.CancelOrder:
+			if o.CancelID == 0 {
+				return fmt.Sprintf("cancel order %d without cancel_id", o.ID)
+			}
+			// A cancel's remaining qty is its target's, not its own
+			if o.Qty < 0 || o.RemainingQty < 0 {
+				return fmt.Sprintf("cancel order %d with negative qty", o.ID)
+			}
+			return ""
+		}
+		if o.RemainingQty < 0 || o.RemainingQty > o.Qty {
+			return fmt.Sprintf("order %d remaining_qty %d outside [0, %d]", o.ID, o.RemainingQty, o.Qty)
+		}
+	case domain.EventIcebergRefresh:
+		o := e.Order()
+		if o == nil || !o.Iceberg() {
+			return "ICEBERG_REFRESH without iceberg order"
+		}
+		if o.RemainingQty <= 0 || o.RemainingQty > o.Qty || o.QueuePos <= 0 {
+			return fmt.Sprintf("refresh of order %d needs remaining_qty in (0, %d] and a queue_pos", o.ID, o.Qty)
+		}
+	case domain.EventOrderCanceled:
+		if c := e.Cancel(); c == nil || c.CancelID == 0 {
+			return "ORDER_CANCELED without cancel order"
+		}
+	case domain.EventTradeExecuted:
+		t := e.Trade()
+		if t == nil {
+			return "TRADE_EXECUTED without trade"
+		}
+		if t.ID == 0 || t.BuyOrderID == 0 || t.SellOrderID == 0 {
+			return "trade missing trade or order ids"
+		}
+		if t.Price <= 0 || t.Qty <= 0 {
+			return fmt.Sprintf("trade %d needs positive price and qty", t.ID)
+		}
+		if t.Timestamp != e.Timestamp {
+			return fmt.Sprintf("trade %d timestamp %d != event timestamp %d", t.ID, t.Timestamp, e.Timestamp)
+		}
+	case domain.EventBBOUpdate:
+		if e.BBO() == nil {
+			return "BBO_UPDATE without bbo"
+		}
+	case domain.EventSignal:
+		if e.Signal() == nil {
+			return "SIGNAL without signal"
+		}
+	case domain.EventReQuote:
+		if e.TraderID == "" {
+			return "REQUOTE without trader_id"
+		}
+	case domain.EventMarketData:
+		if e.TraderID == "" {
+			return "MARKET_DATA without trader_id"
+		}
+		if e.BBO() == nil && e.Trade() == nil {
+			return "MARKET_DATA without bbo or trade"
+		}
+	case domain.EventSession:
+		if name, _ := e.Annotation("session"); name == "" {
+			return "SESSION without session name"
+		}
+		switch phase, _ := e.Annotation("phase"); phase {
+		case domain.PhaseOpenAuction, domain.PhaseContinuous, domain.PhaseCloseAuction, domain.PhaseClosed:
+		default:
+			return fmt.Sprintf("SESSION with unknown phase %q", phase)
+		}
+	case domain.EventOrderState:
+		c := e.StateChange()
+		if c == nil {
+			return "ORDER_STATE without state"
+		}
+		if c.OrderID == 0 {
+			return "state change without order_id"
+		}
+		if !c.From.CanTransition(c.To) {
+			return fmt.Sprintf("order %d: illegal transition %s -> %s", c.OrderID, c.From, c.To)
+		}
+	case domain.EventDepth:
+		d := e.Depth()
+		if d == nil {
+			return "DEPTH without depth"
+		}
+		// Best first: bids fall, asks rise
+		for _, side := range []struct {
+			levels []domain.DepthLevel
+			dir    int64
+		}{{d.Bids, -1}, {d.Asks, 1}} {
+			for i, lv := range side.levels {
+				if lv.Price <= 0 || lv.Qty <= 0 || lv.Orders <= 0 {
+					return fmt.Sprintf("depth level at %d needs positive price, qty and orders", lv.Price)
+				}
+				if i > 0 && (lv.Price-side.levels[i-1].Price)*side.dir <= 0 {
+					return fmt.Sprintf("depth level at %d out of order", lv.Price)
+				}
+			}
+		}
+	case domain.EventAccountUpdate:
+		a := e.Account()
+		if a == nil {
+			return "ACCOUNT_UPDATE without account"
+		}
+		if a.TraderID == "" || a.TraderID != e.TraderID {
+			return fmt.Sprintf("account trader_id %q != event trader_id %q", a.TraderID, e.TraderID)
+		}
+		if a.Cash+a.CostBasis != a.RealizedPnL-a.Fees {
+			return fmt.Sprintf("account %s does not balance: cash + cost_basis != realized_pnl - fees", a.TraderID)
+		}
+	}
+	if _, ok := e.Meta[""]; ok {
+		return "meta with empty key"
+	}
+	if err := e.CheckPayload(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// Validate reads the whole log at path and returns the first schema
+// violation, as a *SchemaError, or nil if the log conforms
+func Validate(path string) error {
+	r, err := NewReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	v := NewValidator()
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return &SchemaError{Line: v.line + 1, Reason: err.Error()}
+		}
+		if err := v.Check(e); err != nil {
+			return err
+		}
+	}
+}
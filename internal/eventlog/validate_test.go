@@ -0,0 +1,250 @@
+package eventlog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// validLog is a minimal conforming log: start, two resting orders, a
+// crossing order with its trade and BBO, a cancel, and end
+func validLog() []*domain.Event {
+	return []*domain.Event{
+		{Timestamp: 0, Type: domain.EventSimStart},
+		{SeqNo: 1, Timestamp: 0, Type: domain.EventOrderAccepted, Payload: &domain.Order{
+			ID: 1, Side: domain.Sell, Type: domain.LimitOrder, Price: 1_000_100, Qty: 5, RemainingQty: 5}},
+		{SeqNo: 2, Timestamp: 0, Type: domain.EventOrderAccepted, Payload: &domain.Order{
+			ID: 2, Side: domain.Sell, Type: domain.LimitOrder, Price: 1_000_200, Qty: 5, RemainingQty: 5}},
+		{SeqNo: 4, Timestamp: 10, Type: domain.EventOrderAccepted, Payload: &domain.Order{
+			ID: 3, Side: domain.Buy, Type: domain.MarketOrder, Qty: 2}},
+		{Timestamp: 10, Type: domain.EventTradeExecuted, Payload: &domain.Trade{
+			ID: 1, BuyOrderID: 3, SellOrderID: 1, Price: 1_000_100, Qty: 2, Timestamp: 10}},
+		{Timestamp: 10, Type: domain.EventBBOUpdate, Payload: &domain.BBO{AskPrice: 1_000_100, AskQty: 3}},
+		{SeqNo: 3, Timestamp: 20, Type: domain.EventSignal, Payload: &domain.Signal{Value: 0.4}},
+		{SeqNo: 5, Timestamp: 30, Type: domain.EventOrderAccepted, Payload: &domain.Order{
+			ID: 4, Side: domain.Sell, Type: domain.CancelOrder, CancelID: 2}},
+		{Timestamp: 30, Type: domain.EventOrderCanceled, Payload: &domain.Order{
+			ID: 4, Side: domain.Sell, Type: domain.CancelOrder, CancelID: 2}},
+		{SeqNo: 6, Timestamp: 40, Type: domain.EventSimEnd},
+	}
+}
+
+func writeLog(t *testing.T, events []*domain.Event) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	w, err := NewWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateAcceptsConformingLog(t *testing.T) {
+	if err := Validate(writeLog(t, validLog())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateRejectsViolations(t *testing.T) {
+	cases := []struct {
+		name   string
+		mutate func([]*domain.Event) []*domain.Event
+		line   int
+		reason string
+	}{
+		{"timestamp goes back", func(ev []*domain.Event) []*domain.Event {
+			ev[6].Timestamp = 5
+			return ev
+		}, 7, "before previous"},
+		{"duplicate seq", func(ev []*domain.Event) []*domain.Event {
+			ev[7].SeqNo = 4
+			return ev
+		}, 8, "duplicate seq_no"},
+		{"seq out of order within timestamp", func(ev []*domain.Event) []*domain.Event {
+			ev[1].SeqNo, ev[2].SeqNo = 2, 1
+			return ev
+		}, 3, "seq_no 1 after 2"},
+		{"missing order", func(ev []*domain.Event) []*domain.Event {
+			ev[3].Payload = nil
+			return ev
+		}, 4, "without order"},
+		{"cancel without target", func(ev []*domain.Event) []*domain.Event {
+			ev[7].Order().CancelID = 0
+			return ev
+		}, 8, "without cancel_id"},
+		{"zero qty trade", func(ev []*domain.Event) []*domain.Event {
+			ev[4].Trade().Qty = 0
+			return ev
+		}, 5, "positive price and qty"},
+		{"overfilled remaining", func(ev []*domain.Event) []*domain.Event {
+			ev[1].Order().RemainingQty = 6
+			return ev
+		}, 2, "remaining_qty"},
+		{"cancel ack for a limit order", func(ev []*domain.Event) []*domain.Event {
+			ev[8].Order().Type = domain.LimitOrder
+			return ev
+		}, 9, "without cancel order"},
+		{"payload on requote", func(ev []*domain.Event) []*domain.Event {
+			ev[9] = &domain.Event{SeqNo: 6, Timestamp: 40, Type: domain.EventReQuote, TraderID: "fast",
+				Payload: &domain.Signal{}}
+			return ev
+		}, 10, "REQUOTE event with signal payload"},
+		{"illegal state transition", func(ev []*domain.Event) []*domain.Event {
+			state := domain.NewStateEvent(30, &domain.StateChange{OrderID: 2, From: domain.StateFilled, To: domain.StateCanceled})
+			return append(ev[:9], append([]*domain.Event{&state}, ev[9:]...)...)
+		}, 10, "illegal transition FILLED -> CANCELED"},
+		{"unbalanced account", func(ev []*domain.Event) []*domain.Event {
+			update := domain.NewAccountEvent(10, &domain.Account{TraderID: "fast", Cash: -2_000_200, Position: 2, CostBasis: 2_000_000})
+			return append(ev[:6], append([]*domain.Event{&update}, ev[6:]...)...)
+		}, 7, "account fast does not balance"},
+		{"start not first", func(ev []*domain.Event) []*domain.Event {
+			return append(ev[1:2], append([]*domain.Event{ev[0]}, ev[2:]...)...)
+		}, 2, "SIM_START"},
+		{"signal in a bbo-level log", func(ev []*domain.Event) []*domain.Event {
+			DeclareLevel(ev[0], LevelBBO)
+			return ev
+		}, 7, "SIGNAL in a log at level bbo"},
+		{"unknown log level", func(ev []*domain.Event) []*domain.Event {
+			ev[0].Annotate("log_level", "everything")
+			return ev
+		}, 1, "unknown log level"},
+		{"depth levels out of order", func(ev []*domain.Event) []*domain.Event {
+			depth := domain.NewDepthEvent(10, &domain.Depth{Asks: []domain.DepthLevel{
+				{Price: 1_000_200, Qty: 5, Orders: 1}, {Price: 1_000_100, Qty: 3, Orders: 1}}})
+			return append(ev[:6], append([]*domain.Event{&depth}, ev[6:]...)...)
+		}, 7, "depth level at 1000100 out of order"},
+		{"session without phase", func(ev []*domain.Event) []*domain.Event {
+			session := domain.NewSessionEvent(10, "day1", "lunch", "100.0000")
+			session.SeqNo = 7
+			return append(ev[:6], append([]*domain.Event{&session}, ev[6:]...)...)
+		}, 7, `unknown phase "lunch"`},
+		{"event after end", func(ev []*domain.Event) []*domain.Event {
+			return append(ev, &domain.Event{Timestamp: 40, Type: domain.EventBBOUpdate, Payload: &domain.BBO{}})
+		}, 11, "after SIM_END"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate(writeLog(t, tc.mutate(validLog())))
+			var se *SchemaError
+			if !errors.As(err, &se) {
+				t.Fatalf("expected SchemaError, got %v", err)
+			}
+			if se.Line != tc.line || !strings.Contains(se.Reason, tc.reason) {
+				t.Errorf("got line %d %q, want line %d containing %q", se.Line, se.Reason, tc.line, tc.reason)
+			}
+		})
+	}
+}
+
+// TestReaderRequire verifies a consumer is refused a log below the level
+// it needs before reading past SIM_START, and that SIM_START still reaches
+// Next when the level suffices
+func TestReaderRequire(t *testing.T) {
+	events := validLog()
+	DeclareLevel(events[0], LevelOrders)
+	var kept []*domain.Event
+	for _, e := range events {
+		if LevelOrders.Records(e.Type) {
+			kept = append(kept, e)
+		}
+	}
+	path := writeLog(t, kept)
+	if err := Validate(path); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var le *LevelError
+	if err := r.Require(LevelBBO, "test"); !errors.As(err, &le) || le.Have != LevelOrders || le.Need != LevelBBO {
+		t.Fatalf("Require(bbo) on an orders log: %v", err)
+	}
+	r.Close()
+
+	r, err = NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := r.Require(LevelTrades, "test"); err != nil {
+		t.Fatal(err)
+	}
+	all, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(kept) || all[0].Type != domain.EventSimStart {
+		t.Fatalf("read %d events starting with %v, want %d from SIM_START", len(all), all[0].Type, len(kept))
+	}
+}
+
+func TestValidateAcceptsExpiryAfterEnd(t *testing.T) {
+	expiry := domain.NewStateEvent(40, &domain.StateChange{OrderID: 1, From: domain.StatePartiallyFilled, To: domain.StateExpired})
+	if err := Validate(writeLog(t, append(validLog(), &expiry))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateRejectsCorruptRecord(t *testing.T) {
+	path := writeLog(t, validLog())
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	lines[2] = lines[2][:len(lines[2])/2] + "\n"
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var se *SchemaError
+	if err := Validate(path); !errors.As(err, &se) || se.Line != 3 {
+		t.Fatalf("expected SchemaError on line 3, got %v", err)
+	}
+}
+
+// TestRoundTripPreservesEvents checks decode(encode(e)) == e for a log
+// covering every payload type
+func TestRoundTripPreservesEvents(t *testing.T) {
+	want := validLog()
+	r, err := NewReader(writeLog(t, want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	got, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("read %d events, wrote %d", len(got), len(want))
+	}
+	for i := range want {
+		w, g := want[i], got[i]
+		if w.SeqNo != g.SeqNo || w.Timestamp != g.Timestamp || w.Type != g.Type ||
+			!samePtr(w.Order(), g.Order()) || !samePtr(w.Trade(), g.Trade()) ||
+			!samePtr(w.BBO(), g.BBO()) || !samePtr(w.Signal(), g.Signal()) {
+			t.Errorf("event %d: got %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func samePtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
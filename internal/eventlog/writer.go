@@ -3,36 +3,306 @@ package eventlog
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
+	"maps"
 	"os"
+	"sync/atomic"
 
 	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
 )
 
 // Writer writes events as JSON lines to a file
+// Every byte written is also fed to a SHA-256 hash so the log digest is
+// available at Close without re-reading the file
 type Writer struct {
 	file   *os.File
 	writer *bufio.Writer
+	hash   hash.Hash
 	count  uint64
+	buf    []byte
+
+	// Set by NewMemoryWriter: the events kept instead of a file
+	memory bool
+	events []*domain.Event
+
+	// StdJSON encodes with encoding/json instead of the hand-rolled codec
+	// Output is byte-identical either way; this is a cross-check fallback
+	StdJSON bool
+
+	// Set by StartAsync: filled batches go to queue, drained ones come
+	// back on free, and done reports the first error when queue closes
+	queue chan []logRecord
+	free  chan []logRecord
+	batch []logRecord
+	done  chan error
+	err   atomic.Pointer[error]
+
+	// Set by HoldSeqNos: events with a SeqNo above above are logged as
+	// SeqNo-above+base. Until Renumber gives base, every event is held
+	held     [][]logRecord
+	holding  bool
+	renumber bool
+	above    uint64
+	base     uint64
 }
 
+// logRecord is a deep copy of an event taken at Write time, so the
+// caller may mutate or recycle the original while it waits to be encoded
+type logRecord struct {
+	event   domain.Event
+	order   domain.Order
+	trade   domain.Trade
+	bbo     domain.BBO
+	signal  domain.Signal
+	state   domain.StateChange
+	account domain.Account
+	depth   domain.Depth // shares the snapshot's levels, which are never mutated
+}
+
+func (rec *logRecord) set(e *domain.Event) {
+	rec.event = *e
+	if e.Meta != nil {
+		rec.event.Meta = maps.Clone(e.Meta)
+	}
+	switch p := e.Payload.(type) {
+	case *domain.Order:
+		if p != nil {
+			rec.order = *p
+			rec.event.Payload = &rec.order
+		}
+	case *domain.Trade:
+		if p != nil {
+			rec.trade = *p
+			rec.event.Payload = &rec.trade
+		}
+	case *domain.BBO:
+		if p != nil {
+			rec.bbo = *p
+			rec.event.Payload = &rec.bbo
+		}
+	case *domain.Signal:
+		if p != nil {
+			rec.signal = *p
+			rec.event.Payload = &rec.signal
+		}
+	case *domain.StateChange:
+		if p != nil {
+			rec.state = *p
+			rec.event.Payload = &rec.state
+		}
+	case *domain.Account:
+		if p != nil {
+			rec.account = *p
+			rec.event.Payload = &rec.account
+		}
+	case *domain.Depth:
+		if p != nil {
+			rec.depth = *p
+			rec.event.Payload = &rec.depth
+		}
+	}
+}
+
+// Clone deep-copies an event, its payload and annotations. A depth
+// snapshot's levels are shared, as they are never mutated
+func Clone(e *domain.Event) *domain.Event {
+	c := *e
+	if e.Meta != nil {
+		c.Meta = maps.Clone(e.Meta)
+	}
+	switch p := e.Payload.(type) {
+	case *domain.Order:
+		c.Payload = copyOf(p)
+	case *domain.Trade:
+		c.Payload = copyOf(p)
+	case *domain.BBO:
+		c.Payload = copyOf(p)
+	case *domain.Signal:
+		c.Payload = copyOf(p)
+	case *domain.StateChange:
+		c.Payload = copyOf(p)
+	case *domain.Account:
+		c.Payload = copyOf(p)
+	case *domain.Depth:
+		c.Payload = copyOf(p)
+	}
+	return &c
+}
+
+func copyOf[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	return &c
+}
+
+// asyncBatch is the number of events copied before a batch is handed off
+const asyncBatch = 512
+
 // NewWriter creates a new event log writer at the given path
 func NewWriter(path string) (*Writer, error) {
 	f, err := os.Create(path)
 	if err != nil {
 		return nil, fmt.Errorf("create event log: %w", err)
 	}
+	h := sha256.New()
 	return &Writer{
 		file:   f,
-		writer: bufio.NewWriterSize(f, 64*1024),
+		writer: bufio.NewWriterSize(io.MultiWriter(f, h), 64*1024),
+		hash:   h,
 	}, nil
 }
 
+// NewMemoryWriter creates a writer that keeps copies of the events it is
+// given instead of writing a file. They are still encoded and hashed, so
+// Hash is that of the file the same events would make
+func NewMemoryWriter() *Writer {
+	h := sha256.New()
+	return &Writer{
+		writer: bufio.NewWriterSize(h, 64*1024),
+		hash:   h,
+		memory: true,
+	}
+}
+
+// Events returns the events a memory writer kept, in write order
+// With StartAsync it is only complete after Close
+func (w *Writer) Events() []*domain.Event {
+	return w.events
+}
+
+// WrapOutput routes file output through wrap, e.g. to inject write
+// failures. The hash still sees every byte. Call it before the first Write
+func (w *Writer) WrapOutput(wrap func(io.Writer) io.Writer) {
+	w.writer = bufio.NewWriterSize(io.MultiWriter(wrap(w.file), w.hash), 64*1024)
+}
+
+// StartAsync moves encoding, hashing and file output onto a background
+// goroutine fed through a queue of at most depth batches. Write then only
+// copies the event, so the caller may reuse it as soon as Write returns
+// Call it before the first Write; errors surface from a later Write or Close
+func (w *Writer) StartAsync(depth int) {
+	if depth < 1 {
+		depth = 1
+	}
+	w.queue = make(chan []logRecord, depth)
+	w.free = make(chan []logRecord, depth+1)
+	w.done = make(chan error, 1)
+	for i := 0; i <= depth; i++ {
+		w.free <- make([]logRecord, 0, asyncBatch)
+	}
+	w.batch = <-w.free
+	go w.drain(w.queue)
+}
+
+// drain encodes queued batches in order until the queue is closed
+func (w *Writer) drain(queue <-chan []logRecord) {
+	var first error
+	for batch := range queue {
+		for i := range batch {
+			if first != nil {
+				break
+			}
+			if err := w.write(&batch[i].event); err != nil {
+				first = err
+				w.err.Store(&err)
+			}
+		}
+		w.free <- batch[:0]
+	}
+	w.done <- first
+}
+
+// HoldSeqNos holds every event back from the log until Renumber says
+// what the SeqNos above above stand for. A run uses it for SeqNos it
+// assigns before it knows how many events go ahead of them
+func (w *Writer) HoldSeqNos(above uint64) {
+	w.holding, w.above = true, above
+	if w.batch == nil {
+		w.batch = make([]logRecord, 0, asyncBatch)
+	}
+}
+
+// Renumber logs the events with a SeqNo above HoldSeqNos' mark as
+// numbered on from base, and writes the events held so far
+func (w *Writer) Renumber(base uint64) error {
+	if !w.holding {
+		return nil
+	}
+	w.holding, w.renumber, w.base = false, true, base
+	held := append(w.held, w.batch)
+	w.held = nil
+	if w.queue != nil {
+		w.batch = <-w.free
+	} else {
+		w.batch = nil
+	}
+	for _, batch := range held {
+		for i := range batch {
+			if err := w.Write(&batch[i].event); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// seqNo is the SeqNo an event is logged with
+func (w *Writer) seqNo(seq uint64) uint64 {
+	if w.renumber && seq > w.above {
+		return seq - w.above + w.base
+	}
+	return seq
+}
+
 // Write appends an event to the log
 func (w *Writer) Write(event *domain.Event) error {
-	data, err := json.Marshal(event)
+	if w.holding {
+		w.batch = w.batch[:len(w.batch)+1]
+		w.batch[len(w.batch)-1].set(event)
+		if len(w.batch) == cap(w.batch) {
+			w.held = append(w.held, w.batch)
+			w.batch = make([]logRecord, 0, asyncBatch)
+		}
+		return nil
+	}
+	if w.queue == nil {
+		if seq := w.seqNo(event.SeqNo); seq != event.SeqNo {
+			renumbered := *event
+			renumbered.SeqNo = seq
+			event = &renumbered
+		}
+		return w.write(event)
+	}
+	if err := w.err.Load(); err != nil {
+		return *err
+	}
+	w.batch = w.batch[:len(w.batch)+1]
+	rec := &w.batch[len(w.batch)-1]
+	rec.set(event)
+	rec.event.SeqNo = w.seqNo(event.SeqNo)
+	if len(w.batch) == cap(w.batch) {
+		w.queue <- w.batch
+		w.batch = <-w.free
+	}
+	return nil
+}
+
+func (w *Writer) write(event *domain.Event) error {
+	var data []byte
+	var err error
+	if w.StdJSON {
+		data, err = json.Marshal(event)
+	} else {
+		data, err = appendEvent(w.buf[:0], event)
+		w.buf = data
+	}
 	if err != nil {
 		return fmt.Errorf("marshal event: %w", err)
 	}
@@ -44,12 +314,36 @@ func (w *Writer) Write(event *domain.Event) error {
 	if err != nil {
 		return err
 	}
+	if w.memory {
+		w.events = append(w.events, Clone(event))
+	}
 	w.count++
 	return nil
 }
 
 // Close flushes and closes the log file
+// With StartAsync it first waits for every queued event to be written
+// Events still held are written with their SeqNos as they are
 func (w *Writer) Close() error {
+	// An async write error comes back from the drain below
+	if err := w.Renumber(w.above); err != nil && w.queue == nil {
+		w.file.Close()
+		return err
+	}
+	if w.queue != nil {
+		if len(w.batch) > 0 {
+			w.queue <- w.batch
+		}
+		close(w.queue)
+		w.queue = nil
+		if err := <-w.done; err != nil {
+			w.file.Close()
+			return err
+		}
+	}
+	if w.memory {
+		return w.writer.Flush()
+	}
 	if err := w.writer.Flush(); err != nil {
 		w.file.Close()
 		return err
@@ -58,14 +352,28 @@ func (w *Writer) Close() error {
 }
 
 // Count returns the number of events written
+// With StartAsync it is only complete after Close
 func (w *Writer) Count() uint64 {
 	return w.count
 }
 
+// Hash returns the hex SHA-256 of the log contents
+// Only bytes flushed to the file are included, so call it after Close
+func (w *Writer) Hash() string {
+	return hex.EncodeToString(w.hash.Sum(nil))
+}
+
 // Reader reads events from a JSON-lines event log
 type Reader struct {
 	file    *os.File
 	scanner *bufio.Scanner
+	hash    hash.Hash
+	peeked  *domain.Event // read by Level, returned by the next Next
+
+	streamErr error // what ended the last Stream
+
+	// StdJSON decodes with encoding/json instead of the hand-rolled codec
+	StdJSON bool
 }
 
 // NewReader opens an event log for reading
@@ -74,30 +382,89 @@ func NewReader(path string) (*Reader, error) {
 	if err != nil {
 		return nil, fmt.Errorf("open event log: %w", err)
 	}
-	scanner := bufio.NewScanner(f)
+	h := sha256.New()
+	scanner := bufio.NewScanner(io.TeeReader(f, h))
 	scanner.Buffer(make([]byte, 256*1024), 1024*1024)
 	return &Reader{
 		file:    f,
 		scanner: scanner,
+		hash:    h,
 	}, nil
 }
 
+// Hash returns the hex SHA-256 of the bytes read so far
+// Once Next has returned io.EOF this is the digest of the whole log
+func (r *Reader) Hash() string {
+	return hex.EncodeToString(r.hash.Sum(nil))
+}
+
 // Next reads the next event. Returns nil, io.EOF at end of log
 func (r *Reader) Next() (*domain.Event, error) {
+	if e := r.peeked; e != nil {
+		r.peeked = nil
+		return e, nil
+	}
 	if !r.scanner.Scan() {
 		if err := r.scanner.Err(); err != nil {
 			return nil, err
 		}
 		return nil, io.EOF
 	}
+	line := r.scanner.Bytes()
+	if r.StdJSON {
+		return decodeStd(line)
+	}
+	return DecodeLine(line)
+}
+
+// Level is the level the log declares in its SIM_START. Call it before
+// the first Next, which still returns that event
+func (r *Reader) Level() (Level, error) {
+	first, err := r.Next()
+	if err == io.EOF {
+		return LevelFull, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	r.peeked = first
+	return DeclaredLevel(first)
+}
+
+// Require fails fast, before any event is read, unless the log is at
+// level need or above. consumer names the caller in the *LevelError
+func (r *Reader) Require(need Level, consumer string) error {
+	have, err := r.Level()
+	if err != nil {
+		return err
+	}
+	if have < need {
+		return &LevelError{Consumer: consumer, Need: need, Have: have}
+	}
+	return nil
+}
+
+// DecodeLine parses a single log line
+func DecodeLine(line []byte) (*domain.Event, error) {
+	var event domain.Event
+	if decodeEvent(line, &event) == nil {
+		return &event, nil
+	}
+	// Anything the fast path does not handle goes through encoding/json,
+	// which also produces the canonical error for malformed input
+	return decodeStd(line)
+}
+
+func decodeStd(line []byte) (*domain.Event, error) {
 	var event domain.Event
-	if err := json.Unmarshal(r.scanner.Bytes(), &event); err != nil {
+	if err := json.Unmarshal(line, &event); err != nil {
 		return nil, fmt.Errorf("unmarshal event: %w", err)
 	}
 	return &event, nil
 }
 
-// ReadAll reads all events from the log
+// ReadAll reads all events from the log into memory. Each and Stream
+// visit them one at a time, for logs of any size
 func (r *Reader) ReadAll() ([]*domain.Event, error) {
 	var events []*domain.Event
 	for {
@@ -0,0 +1,210 @@
+package eventlog
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+func TestStreamingHashMatchesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	w, err := NewWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := w.Write(&domain.Event{
+			SeqNo:     uint64(i),
+			Timestamp: int64(i) * 1000,
+			Type:      domain.EventBBOUpdate,
+			Payload:   &domain.BBO{BidPrice: 999_900, AskPrice: 1_000_100},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("%x", sha256.Sum256(data))
+	if got := w.Hash(); got != want {
+		t.Errorf("writer hash %s != file hash %s", got, want)
+	}
+
+	r, err := NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	n := 0
+	for {
+		_, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 100 {
+		t.Errorf("expected 100 events, read %d", n)
+	}
+	if got := r.Hash(); got != want {
+		t.Errorf("reader hash %s != file hash %s", got, want)
+	}
+}
+
+func benchmarkEvents() []*domain.Event {
+	events := make([]*domain.Event, 0, 3)
+	events = append(events,
+		&domain.Event{Timestamp: 10, Type: domain.EventOrderAccepted, Payload: &domain.Order{
+			ID: 100_001, TraderID: "background", Side: domain.Buy, Type: domain.LimitOrder,
+			Price: 999_900, Qty: 5, RemainingQty: 5, QueuePos: 3}},
+		&domain.Event{Timestamp: 10, Type: domain.EventTradeExecuted, Payload: &domain.Trade{
+			ID: 1, BuyOrderID: 1, SellOrderID: 2, BuyTrader: "fast", SellTrader: "background",
+			Price: 1_000_100, Qty: 3, Timestamp: 10, PassiveOrderID: 2, AggressorOrderID: 1, RestingQueuePos: 1}},
+		&domain.Event{Timestamp: 10, Type: domain.EventBBOUpdate, Payload: &domain.BBO{
+			BidPrice: 999_900, BidQty: 40, AskPrice: 1_000_100, AskQty: 12, MidPrice: 1_000_000}},
+	)
+	return events
+}
+
+func BenchmarkWriterWrite(b *testing.B) {
+	w, err := NewWriter(filepath.Join(b.TempDir(), "events.jsonl"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer w.Close()
+	events := benchmarkEvents()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := w.Write(events[i%len(events)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "events/s")
+}
+
+func BenchmarkReaderNext(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "events.jsonl")
+	w, err := NewWriter(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	events := benchmarkEvents()
+	for i := 0; i < b.N; i++ {
+		w.Write(events[i%len(events)])
+	}
+	if err := w.Close(); err != nil {
+		b.Fatal(err)
+	}
+	r, err := NewReader(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer r.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Next(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "events/s")
+}
+
+// TestAsyncWriterMatchesSync reuses one event for every Write to check the
+// async writer copies it rather than encoding whatever it holds later
+func TestAsyncWriterMatchesSync(t *testing.T) {
+	dir := t.TempDir()
+	var hashes []string
+	for _, async := range []bool{false, true} {
+		w, err := NewWriter(filepath.Join(dir, fmt.Sprintf("events_%v.jsonl", async)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if async {
+			w.StartAsync(2)
+		}
+		event := &domain.Event{Type: domain.EventOrderAccepted, Payload: &domain.Order{}}
+		for i := 0; i < 3*asyncBatch+7; i++ {
+			event.SeqNo = uint64(i)
+			event.Timestamp = int64(i) * 1000
+			event.Order().ID = uint64(i)
+			event.Order().Qty = int64(i % 13)
+			if err := w.Write(event); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if w.Count() != 3*asyncBatch+7 {
+			t.Errorf("async=%v: count %d", async, w.Count())
+		}
+		hashes = append(hashes, w.Hash())
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("async log hash %s differs from sync %s", hashes[1], hashes[0])
+	}
+}
+
+// TestHeldSeqNosRenumbered checks a writer that starts holding SeqNos
+// partway writes the same log, once renumbered, as one given the final
+// SeqNos from the start
+func TestHeldSeqNosRenumbered(t *testing.T) {
+	const above, base = 1 << 40, 100
+	dir := t.TempDir()
+	write := func(name string, async, held bool) string {
+		w, err := NewWriter(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if async {
+			w.StartAsync(2)
+		}
+		event := &domain.Event{Type: domain.EventOrderAccepted, Payload: &domain.Order{}}
+		for i := 0; i < 3*asyncBatch+7; i++ {
+			if held && i == 5 {
+				w.HoldSeqNos(above)
+			}
+			if held && i == 2*asyncBatch {
+				if err := w.Renumber(base); err != nil {
+					t.Fatal(err)
+				}
+			}
+			event.SeqNo = uint64(i)
+			if i%2 == 1 && i > 5 {
+				event.SeqNo = base + uint64(i)
+				if held {
+					event.SeqNo = above + uint64(i)
+				}
+			}
+			event.Order().ID = uint64(i)
+			if err := w.Write(event); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return w.Hash()
+	}
+	want := write("plain.jsonl", false, false)
+	for _, async := range []bool{false, true} {
+		if got := write(fmt.Sprintf("held_%v.jsonl", async), async, true); got != want {
+			t.Errorf("async=%v: held log hash %s, want %s", async, got, want)
+		}
+	}
+}
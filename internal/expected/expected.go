@@ -0,0 +1,168 @@
+// Package expected checks a run's results against the metric ranges a
+// scenario ships in an expected.json, so each scenario doubles as a
+// regression test of the whole pipeline: generation, matching, logging
+// and metrics
+package expected
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+//go:embed scenarios/*.json
+var builtin embed.FS
+
+// Range bounds one metric, inclusive at both ends
+type Range struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// File is an expected.json: the ranges a scenario's metrics fall in when
+// run with the given seed and duration. Keys are "fast.<metric>",
+// "slow.<metric>" or "gap.<metric>" (fast minus slow), where <metric> is
+// a numeric field of metrics.json, or trade_count
+type File struct {
+	Scenario   string           `json:"scenario"`
+	Seed       int64            `json:"seed"`
+	DurationNs int64            `json:"duration_ns"`
+	Ranges     map[string]Range `json:"ranges"`
+}
+
+// ErrNone is returned by Builtin for a scenario that ships no ranges
+var ErrNone = errors.New("no expected results")
+
+// Builtin returns the ranges shipped for a built-in scenario
+func Builtin(name string) (*File, error) {
+	data, err := builtin.ReadFile("scenarios/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", name, ErrNone)
+	}
+	return parse(data, name)
+}
+
+// Load reads an expected.json
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parse(data, path)
+}
+
+func parse(data []byte, source string) (*File, error) {
+	var f File
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("expected results %s: %w", source, err)
+	}
+	if len(f.Ranges) == 0 {
+		return nil, fmt.Errorf("expected results %s: no ranges", source)
+	}
+	for key, r := range f.Ranges {
+		if r.Min > r.Max {
+			return nil, fmt.Errorf("expected results %s: %s: min %g above max %g", source, key, r.Min, r.Max)
+		}
+	}
+	return &f, nil
+}
+
+// Applies reports whether the ranges hold for cfg: they were taken at one
+// seed and duration, and mean nothing at others
+func (f *File) Applies(cfg *scenario.Config) error {
+	if cfg.Seed != f.Seed || cfg.Duration != f.DurationNs {
+		return fmt.Errorf("expected results are for seed %d over %s; this run is seed %d over %s",
+			f.Seed, latency.Format(f.DurationNs), cfg.Seed, latency.Format(cfg.Duration))
+	}
+	return nil
+}
+
+// Result is one metric checked against its range
+type Result struct {
+	Key   string  `json:"key"`
+	Value float64 `json:"value"`
+	Range Range   `json:"range"`
+	OK    bool    `json:"ok"`
+}
+
+// Check reads each ranged metric off the fast and slow traders' metrics
+// and reports it, in key order. An unknown key is an error
+func (f *File) Check(fast, slow *metrics.TraderMetrics, tradeCount int) ([]Result, error) {
+	fastFields, err := fields(fast)
+	if err != nil {
+		return nil, err
+	}
+	slowFields, err := fields(slow)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	for key, r := range detorder.All(f.Ranges) {
+		var v float64
+		var ok bool
+		who, name, _ := strings.Cut(key, ".")
+		switch {
+		case key == "trade_count":
+			v, ok = float64(tradeCount), true
+		case who == "fast":
+			v, ok = fastFields[name]
+		case who == "slow":
+			v, ok = slowFields[name]
+		case who == "gap":
+			var fv, sv float64
+			fv, ok = fastFields[name]
+			sv, _ = slowFields[name]
+			v = fv - sv
+		}
+		if !ok {
+			return nil, fmt.Errorf("expected results: unknown metric %q", key)
+		}
+		results = append(results, Result{Key: key, Value: v, Range: r, OK: v >= r.Min && v <= r.Max && !math.IsNaN(v)})
+	}
+	return results, nil
+}
+
+// Failed counts the results outside their range
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if !r.OK {
+			n++
+		}
+	}
+	return n
+}
+
+// fields maps metrics.json's numeric fields by name
+func fields(m *metrics.TraderMetrics) (map[string]float64, error) {
+	if m == nil {
+		return nil, errors.New("expected results: trader has no metrics")
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	out := make(map[string]float64, len(raw))
+	for k, v := range raw {
+		if n, ok := v.(float64); ok {
+			out[k] = n
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,96 @@
+package expected
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+// TestBuiltinScenarios runs each built-in scenario at its expected seed
+// and checks every shipped range holds
+func TestBuiltinScenarios(t *testing.T) {
+	if testing.Short() {
+		t.Skip("full-length scenario runs")
+	}
+	for _, name := range []string{"calm", "thin", "spike"} {
+		want, err := Builtin(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := scenario.GetConfig(name, want.Seed)
+		if err := want.Applies(cfg); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		r, err := sim.NewRunner(cfg, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := r.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := metrics.ComputeFromLog(result.LogPath, cfg.Scale(), cfg.Metrics)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := want.Check(m["fast"], m["slow"], result.TradeCount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, res := range results {
+			if !res.OK {
+				t.Errorf("%s: %s = %g outside [%g, %g]", name, res.Key, res.Value, res.Range.Min, res.Range.Max)
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	f := &File{Ranges: map[string]Range{
+		"fast.fill_rate": {Min: 0.5, Max: 1},
+		"gap.fill_rate":  {Min: 0, Max: 0.1},
+		"trade_count":    {Min: 10, Max: 20},
+	}}
+	fast := &metrics.TraderMetrics{FillRate: 0.6}
+	slow := &metrics.TraderMetrics{FillRate: 0.4}
+	results, err := f.Check(fast, slow, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Key != "fast.fill_rate" || Failed(results) != 1 {
+		t.Fatalf("results = %+v", results)
+	}
+	if gap := results[1]; gap.OK || gap.Value < 0.19 || gap.Value > 0.21 {
+		t.Errorf("gap = %+v", gap)
+	}
+
+	f.Ranges["fast.no_such_metric"] = Range{}
+	if _, err := f.Check(fast, slow, 15); err == nil || !strings.Contains(err.Error(), "no_such_metric") {
+		t.Errorf("unknown metric: %v", err)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"inverted": `{"seed": 1, "ranges": {"trade_count": {"min": 5, "max": 1}}}`,
+		"unknown":  `{"seed": 1, "tolerance": 2, "ranges": {"trade_count": {"min": 1, "max": 5}}}`,
+		"empty":    `{"seed": 1}`,
+	} {
+		path := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+	if _, err := Builtin("nope"); err == nil {
+		t.Error("unknown scenario has expected results")
+	}
+}
@@ -0,0 +1,280 @@
+// Package golden pins simulator behavior to committed snapshots: small
+// fixture runs whose log hash, counts and metrics are checked in and
+// compared on every test run, so cross-version drift shows up in CI. The
+// hashes of the fixture's report.md and metrics.json are pinned too, and
+// as the snapshots are the same for every platform, a report that formats
+// differently on one of them shows up as drift
+package golden
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/provenance"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/report"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+// Version is the behavior version recorded in every snapshot. It is
+// provenance.Version, which is bumped with any change to simulation output
+const Version = provenance.Version
+
+// DefaultDir is where snapshots live, relative to the repository root
+const DefaultDir = "test/testdata/golden"
+
+// Fixture is a small, fast scenario run with pinned output
+type Fixture struct {
+	Name       string `json:"name"`
+	Scenario   string `json:"scenario"`
+	Seed       int64  `json:"seed"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Fixtures are short enough to run on every go test. Spike runs long
+// enough to include one burst window
+var Fixtures = []Fixture{
+	{Name: "calm_seed7", Scenario: "calm", Seed: 7, DurationMs: 2_000},
+	{Name: "thin_seed7", Scenario: "thin", Seed: 7, DurationMs: 2_000},
+	{Name: "spike_seed7", Scenario: "spike", Seed: 7, DurationMs: 3_000},
+}
+
+// Snapshot is the pinned output of one fixture
+type Snapshot struct {
+	Version     int                               `json:"version"`
+	Fixture     Fixture                           `json:"fixture"`
+	EventCount  uint64                            `json:"event_count"`
+	TradeCount  int                               `json:"trade_count"`
+	LogHash     string                            `json:"log_hash"`
+	ReportHash  string                            `json:"report_hash"`
+	MetricsHash string                            `json:"metrics_hash"`
+	Metrics     map[string]*metrics.TraderMetrics `json:"metrics"`
+}
+
+// Run executes a fixture in a scratch directory and snapshots the result
+func Run(f Fixture) (*Snapshot, error) {
+	cfg := scenario.GetConfig(f.Scenario, f.Seed)
+	if cfg == nil {
+		return nil, fmt.Errorf("fixture %s: unknown scenario '%s'", f.Name, f.Scenario)
+	}
+	cfg.Duration = latency.MsToNs(f.DurationMs)
+
+	tmpDir, err := os.MkdirTemp("", "fairsim-golden-*")
+	if err != nil {
+		return nil, fmt.Errorf("create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	runner, err := sim.NewRunner(cfg, tmpDir)
+	if err != nil {
+		return nil, fmt.Errorf("fixture %s: %w", f.Name, err)
+	}
+	result, err := runner.Run()
+	if err != nil {
+		return nil, fmt.Errorf("fixture %s: %w", f.Name, err)
+	}
+	m, err := metrics.ComputeFromLog(result.LogPath, cfg.Scale(), cfg.Metrics)
+	if err != nil {
+		return nil, fmt.Errorf("fixture %s: compute metrics: %w", f.Name, err)
+	}
+	if err := report.ForRun(cfg, m, result).Generate(); err != nil {
+		return nil, fmt.Errorf("fixture %s: %w", f.Name, err)
+	}
+	reportHash, err := hashFile(filepath.Join(result.OutputDir, "report.md"))
+	if err != nil {
+		return nil, err
+	}
+	metricsHash, err := hashFile(filepath.Join(result.OutputDir, "metrics.json"))
+	if err != nil {
+		return nil, err
+	}
+	// Raw per-fill series are covered by the log hash; keep files small
+	for _, tm := range m {
+		tm.TimeToFillDist, tm.TimeToFillSketch = nil, nil
+		tm.SlippageValues = nil
+	}
+
+	return &Snapshot{
+		Version:     Version,
+		Fixture:     f,
+		EventCount:  result.EventCount,
+		TradeCount:  result.TradeCount,
+		LogHash:     result.LogHash,
+		ReportHash:  reportHash,
+		MetricsHash: metricsHash,
+		Metrics:     m,
+	}, nil
+}
+
+// hashFile returns the hex SHA-256 of a file's contents
+func hashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func path(dir string, f Fixture) string {
+	return filepath.Join(dir, f.Name+".json")
+}
+
+// Load reads the committed snapshot for a fixture
+func Load(dir string, f Fixture) (*Snapshot, error) {
+	data, err := os.ReadFile(path(dir, f))
+	if err != nil {
+		return nil, fmt.Errorf("read golden %s: %w", f.Name, err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse golden %s: %w", f.Name, err)
+	}
+	return &s, nil
+}
+
+// Save writes a snapshot for its fixture
+func Save(dir string, s *Snapshot) error {
+	data, err := numfmt.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal golden %s: %w", s.Fixture.Name, err)
+	}
+	return os.WriteFile(path(dir, s.Fixture), append(data, '\n'), 0644)
+}
+
+// Diff lists the differences between a committed snapshot and a fresh
+// one, or nil if they match
+func Diff(want, got *Snapshot) []string {
+	var diffs []string
+	if want.Version != got.Version {
+		diffs = append(diffs, fmt.Sprintf("version: %d -> %d", want.Version, got.Version))
+	}
+	if want.Fixture != got.Fixture {
+		diffs = append(diffs, fmt.Sprintf("fixture: %+v -> %+v", want.Fixture, got.Fixture))
+	}
+	if want.EventCount != got.EventCount {
+		diffs = append(diffs, fmt.Sprintf("event_count: %d -> %d", want.EventCount, got.EventCount))
+	}
+	if want.TradeCount != got.TradeCount {
+		diffs = append(diffs, fmt.Sprintf("trade_count: %d -> %d", want.TradeCount, got.TradeCount))
+	}
+	if want.LogHash != got.LogHash {
+		diffs = append(diffs, fmt.Sprintf("log_hash: %s -> %s", want.LogHash, got.LogHash))
+	}
+	if want.ReportHash != got.ReportHash {
+		diffs = append(diffs, fmt.Sprintf("report_hash: %s -> %s", want.ReportHash, got.ReportHash))
+	}
+	if want.MetricsHash != got.MetricsHash {
+		diffs = append(diffs, fmt.Sprintf("metrics_hash: %s -> %s", want.MetricsHash, got.MetricsHash))
+	}
+	return append(diffs, diffMetrics(want.Metrics, got.Metrics)...)
+}
+
+// diffMetrics compares per-trader metrics field by field via their JSON
+// form, so new metric fields are covered without touching this code.
+// Values are compared to numfmt.Significant digits, as they are saved
+func diffMetrics(want, got map[string]*metrics.TraderMetrics) []string {
+	flatten := func(m map[string]*metrics.TraderMetrics) map[string]string {
+		out := make(map[string]string)
+		for id, tm := range m {
+			data, _ := json.Marshal(tm)
+			data = numfmt.CanonJSON(data)
+			var fields map[string]json.RawMessage
+			json.Unmarshal(data, &fields)
+			for k, v := range fields {
+				out[id+"."+k] = string(v)
+			}
+		}
+		return out
+	}
+	w, g := flatten(want), flatten(got)
+
+	union := make(map[string]bool, len(w)+len(g))
+	for k := range w {
+		union[k] = true
+	}
+	for k := range g {
+		union[k] = true
+	}
+
+	var diffs []string
+	for _, k := range detorder.Keys(union) {
+		if w[k] != g[k] {
+			diffs = append(diffs, fmt.Sprintf("metrics.%s: %s -> %s", k, orMissing(w[k]), orMissing(g[k])))
+		}
+	}
+	return diffs
+}
+
+func orMissing(v string) string {
+	if v == "" {
+		return "(missing)"
+	}
+	return v
+}
+
+// Check runs every fixture and compares it to its committed snapshot
+// It returns the differences keyed by fixture name; an empty map means
+// the simulator still reproduces every snapshot exactly
+func Check(dir string) (map[string][]string, error) {
+	drift := make(map[string][]string)
+	for _, f := range Fixtures {
+		want, err := Load(dir, f)
+		if err != nil {
+			return nil, err
+		}
+		got, err := Run(f)
+		if err != nil {
+			return nil, err
+		}
+		if diffs := Diff(want, got); len(diffs) > 0 {
+			drift[f.Name] = diffs
+		}
+	}
+	return drift, nil
+}
+
+// Update regenerates every snapshot. Unless force is set it refuses when
+// output changed but Version did not, so drift is always a deliberate,
+// versioned decision. It returns the names of fixtures whose output changed
+func Update(dir string, force bool) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create golden dir: %w", err)
+	}
+	var changed []string
+	var fresh []*Snapshot
+	for _, f := range Fixtures {
+		got, err := Run(f)
+		if err != nil {
+			return nil, err
+		}
+		fresh = append(fresh, got)
+		want, err := Load(dir, f)
+		if err != nil {
+			// New fixture: nothing to drift from
+			changed = append(changed, f.Name)
+			continue
+		}
+		if len(Diff(want, got)) == 0 {
+			continue
+		}
+		changed = append(changed, f.Name)
+		if want.Version == Version && !force {
+			return nil, fmt.Errorf("fixture %s changed but provenance.Version is still %d: bump it or pass --force", f.Name, Version)
+		}
+	}
+	for _, s := range fresh {
+		if err := Save(dir, s); err != nil {
+			return nil, err
+		}
+	}
+	return changed, nil
+}
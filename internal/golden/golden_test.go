@@ -0,0 +1,56 @@
+package golden
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpdateRefusesUnversionedDrift(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Update(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := Update(dir, false); err != nil || len(changed) != 0 {
+		t.Fatalf("rerun changed %v, err %v", changed, err)
+	}
+
+	// Simulate a behavior change recorded under the current version
+	f := Fixtures[0]
+	s, err := Load(dir, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.TradeCount++
+	if err := Save(dir, s); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Update(dir, false); err == nil {
+		t.Fatal("expected Update to refuse drift without a version bump")
+	}
+	changed, err := Update(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 1 || changed[0] != f.Name {
+		t.Errorf("forced update changed %v, want [%s]", changed, f.Name)
+	}
+}
+
+func TestDiffReportsMetricFields(t *testing.T) {
+	want, err := Run(Fixtures[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Run(Fixtures[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffs := Diff(want, got); len(diffs) != 0 {
+		t.Fatalf("identical runs differ: %v", diffs)
+	}
+	got.Metrics["slow"].FillRate += 0.5
+	diffs := Diff(want, got)
+	if len(diffs) != 1 || !strings.HasPrefix(diffs[0], "metrics.slow.fill_rate:") {
+		t.Errorf("unexpected diffs: %v", diffs)
+	}
+}
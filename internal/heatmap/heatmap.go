@@ -0,0 +1,326 @@
+// Package heatmap samples the book's resting depth on a time grid into a
+// time x price matrix for heatmap plots. A grid is taken from the live
+// book during a run or rebuilt from an event log, and the two agree
+package heatmap
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+)
+
+// DefaultIntervalNs is the sampling interval when none is given
+const DefaultIntervalNs = int64(10_000_000)
+
+// Sampler takes a depth sample at each multiple of its interval. A sample
+// is the book as it stood before the first event at or after its time
+type Sampler struct {
+	interval int64
+	next     int64
+	times    []int64
+	rows     []map[int64]int64 // price -> resting qty, bids positive, asks negative
+}
+
+// NewSampler samples every intervalNs from t=intervalNs
+func NewSampler(intervalNs int64) *Sampler {
+	return &Sampler{interval: intervalNs, next: intervalNs}
+}
+
+// Advance takes the samples due at or before ts. depth returns the whole
+// book and is only called when a sample is due
+func (s *Sampler) Advance(ts int64, depth func() *domain.Depth) {
+	if s.next > ts {
+		return
+	}
+	d := depth()
+	row := make(map[int64]int64, len(d.Bids)+len(d.Asks))
+	for _, l := range d.Bids {
+		row[l.Price] += l.Qty
+	}
+	for _, l := range d.Asks {
+		row[l.Price] -= l.Qty
+	}
+	// The book does not change between grid times with no event
+	for ; s.next <= ts; s.next += s.interval {
+		s.times = append(s.times, s.next)
+		s.rows = append(s.rows, row)
+	}
+}
+
+// Grid lays the samples out as a matrix
+func (s *Sampler) Grid() *Grid {
+	g := &Grid{IntervalNs: s.interval, Times: s.times}
+	var step int64
+	lo, hi := int64(math.MaxInt64), int64(math.MinInt64)
+	for _, row := range s.rows {
+		for p := range row {
+			lo, hi = min(lo, p), max(hi, p)
+		}
+	}
+	for _, row := range s.rows {
+		for p := range row {
+			step = gcd(step, p-lo)
+		}
+	}
+	if lo > hi {
+		g.Qty = make([][]int64, len(s.rows))
+		for i := range g.Qty {
+			g.Qty[i] = []int64{}
+		}
+		return g
+	}
+	if step == 0 {
+		step = 1
+	}
+	for p := lo; p <= hi; p += step {
+		g.Prices = append(g.Prices, p)
+	}
+	g.Qty = make([][]int64, len(s.rows))
+	for i, row := range s.rows {
+		g.Qty[i] = make([]int64, len(g.Prices))
+		for p, q := range row {
+			g.Qty[i][(p-lo)/step] = q
+		}
+	}
+	return g
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// Grid is resting depth on a time x price grid. Prices run from the lowest
+// to the highest level seen in any sample, stepping by the finest spacing
+// between them, so empty levels inside the range get columns too. Bid
+// depth is positive and ask depth negative
+type Grid struct {
+	IntervalNs int64
+	Times      []int64   // sample times, ns
+	Prices     []int64   // column prices, ascending
+	Qty        [][]int64 // [time][price]
+}
+
+// WriteCSV writes one row per sample: time_ns, then a column per price,
+// headed by the decimal price
+func (g *Grid) WriteCSV(w io.Writer, scale domain.Scale) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, 0, len(g.Prices)+1)
+	header = append(header, "time_ns")
+	for _, p := range g.Prices {
+		header = append(header, scale.Format(p))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	rec := make([]string, len(header))
+	for i, t := range g.Times {
+		rec[0] = strconv.FormatInt(t, 10)
+		for j, q := range g.Qty[i] {
+			rec[j+1] = strconv.FormatInt(q, 10)
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteNPY writes the matrix as a 2-D int64 array in NumPy's .npy format
+func (g *Grid) WriteNPY(w io.Writer) error {
+	flat := make([]int64, 0, len(g.Times)*len(g.Prices))
+	for _, row := range g.Qty {
+		flat = append(flat, row...)
+	}
+	return writeNPY(w, "<i8", fmt.Sprintf("(%d, %d)", len(g.Times), len(g.Prices)), flat)
+}
+
+// WriteFile writes the grid to path as CSV, or as .npy when path ends in
+// .npy. An .npy matrix gets the times (int64 ns) and prices (float64) in
+// sibling files ending _times.npy and _prices.npy
+func (g *Grid) WriteFile(path string, scale domain.Scale) error {
+	if !strings.HasSuffix(path, ".npy") {
+		return writeFile(path, func(w io.Writer) error { return g.WriteCSV(w, scale) })
+	}
+	base := strings.TrimSuffix(path, ".npy")
+	if err := writeFile(path, g.WriteNPY); err != nil {
+		return err
+	}
+	if err := writeFile(base+"_times.npy", func(w io.Writer) error {
+		return writeNPY(w, "<i8", fmt.Sprintf("(%d,)", len(g.Times)), g.Times)
+	}); err != nil {
+		return err
+	}
+	prices := make([]float64, len(g.Prices))
+	for i, p := range g.Prices {
+		prices[i] = scale.ToFloat(p)
+	}
+	return writeFile(base+"_prices.npy", func(w io.Writer) error {
+		return writeNPY(w, "<f8", fmt.Sprintf("(%d,)", len(prices)), prices)
+	})
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("write heatmap: %w", err)
+	}
+	return f.Close()
+}
+
+// writeNPY writes a version 1.0 .npy file, padding the header so the data
+// starts on a 64-byte boundary
+func writeNPY[T int64 | float64](w io.Writer, descr, shape string, data []T) error {
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': %s, }", descr, shape)
+	const prefix = 10 // magic, version and header length
+	pad := 63 - (prefix+len(header))%64
+	header += strings.Repeat(" ", pad) + "\n"
+	buf := make([]byte, 0, prefix+len(header)+8*len(data))
+	buf = append(buf, "\x93NUMPY\x01\x00"...)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(header)))
+	buf = append(buf, header...)
+	for _, v := range data {
+		switch v := any(v).(type) {
+		case int64:
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(v))
+		case float64:
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+		}
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// resting is an order on the book as the log tells it
+type resting struct {
+	side  domain.Side
+	price int64
+	qty   int64 // on display
+	left  int64 // remaining, an iceberg's hidden qty included
+}
+
+// Rebuilder tracks the resting orders of an event log, fed in log order,
+// and samples them like a run would
+type Rebuilder struct {
+	sampler *Sampler
+	orders  map[uint64]*resting
+	done    bool
+}
+
+// NewRebuilder samples the log's book every intervalNs
+func NewRebuilder(intervalNs int64) *Rebuilder {
+	return &Rebuilder{sampler: NewSampler(intervalNs), orders: make(map[uint64]*resting)}
+}
+
+// Process applies the next event in log order. An accepted limit order
+// rests with what it has left after matching, a trade takes from the
+// passive order, and a cancel ack or a session's close takes orders off.
+// A partial cancel's ack leaves its target with the ack's remaining qty.
+// Depth counts an iceberg's displayed slice, renewed by each refresh
+func (b *Rebuilder) Process(e *domain.Event) {
+	if b.done {
+		return
+	}
+	b.sampler.Advance(e.Timestamp, b.depth)
+	switch e.Type {
+	case domain.EventOrderAccepted:
+		if o := e.Order(); o.Type == domain.LimitOrder && o.RemainingQty > 0 && !o.Immediate() {
+			b.orders[o.ID] = &resting{side: o.Side, price: o.Price, qty: displayed(o), left: o.RemainingQty}
+		}
+	case domain.EventTradeExecuted:
+		tr := e.Trade()
+		if r := b.orders[tr.PassiveOrderID]; r != nil {
+			r.qty -= tr.Qty
+			if r.left -= tr.Qty; r.left <= 0 {
+				delete(b.orders, tr.PassiveOrderID)
+			}
+		}
+	case domain.EventIcebergRefresh:
+		if o := e.Order(); b.orders[o.ID] != nil {
+			b.orders[o.ID].qty, b.orders[o.ID].left = displayed(o), o.RemainingQty
+		}
+	case domain.EventOrderCanceled:
+		c := e.Order()
+		if r := b.orders[c.CancelID]; r != nil && c.RemainingQty > 0 {
+			// An iceberg gives up hidden qty first
+			r.qty, r.left = min(r.qty, c.RemainingQty), c.RemainingQty
+		} else {
+			delete(b.orders, c.CancelID)
+		}
+	case domain.EventSession:
+		if phase, _ := e.Annotation("phase"); phase == domain.PhaseClosed {
+			clear(b.orders)
+		}
+	case domain.EventSimEnd:
+		b.done = true
+	}
+}
+
+// displayed is the qty a resting order shows
+func displayed(o *domain.Order) int64 {
+	if o.Iceberg() {
+		return min(o.DisplayQty, o.RemainingQty)
+	}
+	return o.RemainingQty
+}
+
+// depth aggregates the resting orders by price
+func (b *Rebuilder) depth() *domain.Depth {
+	levels := map[domain.Side]map[int64]int64{domain.Buy: {}, domain.Sell: {}}
+	for _, r := range b.orders {
+		levels[r.side][r.price] += r.qty
+	}
+	d := &domain.Depth{}
+	for p, q := range levels[domain.Buy] {
+		d.Bids = append(d.Bids, domain.DepthLevel{Price: p, Qty: q})
+	}
+	for p, q := range levels[domain.Sell] {
+		d.Asks = append(d.Asks, domain.DepthLevel{Price: p, Qty: q})
+	}
+	return d
+}
+
+// Grid returns the samples taken so far
+func (b *Rebuilder) Grid() *Grid {
+	return b.sampler.Grid()
+}
+
+// BuildLog rebuilds the depth grid of the event log at path. It needs the
+// accepted orders and cancel acks, so the log must be at LevelOrders
+func BuildLog(path string, intervalNs int64) (*Grid, error) {
+	r, err := eventlog.NewReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if err := r.Require(eventlog.LevelOrders, "heatmap"); err != nil {
+		return nil, err
+	}
+
+	b := NewRebuilder(intervalNs)
+	line := 1
+	err = r.Each(func(e *domain.Event) error {
+		b.Process(e)
+		line++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("heatmap line %d: %w", line, err)
+	}
+	return b.Grid(), nil
+}
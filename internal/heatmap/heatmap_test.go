@@ -0,0 +1,92 @@
+package heatmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+func accept(seq uint64, ts int64, id uint64, side domain.Side, price, qty int64) *domain.Event {
+	return &domain.Event{SeqNo: seq, Timestamp: ts, Type: domain.EventOrderAccepted, Payload: &domain.Order{
+		ID: id, Side: side, Type: domain.LimitOrder, Price: price, Qty: qty, RemainingQty: qty}}
+}
+
+// TestRebuilder feeds a small log and checks each sample sees the book as
+// it stood before the first event at or after the sample's time
+func TestRebuilder(t *testing.T) {
+	b := NewRebuilder(10)
+	for _, e := range []*domain.Event{
+		{Timestamp: 0, Type: domain.EventSimStart},
+		accept(1, 0, 1, domain.Buy, 990, 5),
+		accept(2, 0, 2, domain.Sell, 1020, 4),
+		accept(3, 5, 3, domain.Buy, 980, 2),
+		// A fill takes 3 of order 2 at t=10, after the first sample
+		{Timestamp: 10, Type: domain.EventTradeExecuted, Payload: &domain.Trade{
+			ID: 1, BuyOrderID: 9, SellOrderID: 2, PassiveOrderID: 2, Price: 1020, Qty: 3, Timestamp: 10}},
+		// A partial cancel leaves order 3 with 1
+		{Timestamp: 15, Type: domain.EventOrderCanceled, Payload: &domain.Order{
+			ID: 6, Side: domain.Buy, Type: domain.CancelOrder, CancelID: 3, Qty: 1, RemainingQty: 1}},
+		{Timestamp: 25, Type: domain.EventOrderCanceled, Payload: &domain.Order{
+			ID: 4, Side: domain.Buy, Type: domain.CancelOrder, CancelID: 1}},
+		{SeqNo: 4, Timestamp: 30, Type: domain.EventSimEnd},
+		accept(5, 40, 5, domain.Buy, 1000, 1),
+	} {
+		b.Process(e)
+	}
+
+	g := b.Grid()
+	if want := []int64{10, 20, 30}; !slices.Equal(g.Times, want) {
+		t.Fatalf("times = %v, want %v", g.Times, want)
+	}
+	// 980 to 1020 in steps of 10, the finest spacing seen
+	if want := []int64{980, 990, 1000, 1010, 1020}; !slices.Equal(g.Prices, want) {
+		t.Fatalf("prices = %v, want %v", g.Prices, want)
+	}
+	for i, want := range [][]int64{
+		{2, 5, 0, 0, -4},
+		{1, 5, 0, 0, -1},
+		{1, 0, 0, 0, -1},
+	} {
+		if !slices.Equal(g.Qty[i], want) {
+			t.Errorf("t=%d: %v, want %v", g.Times[i], g.Qty[i], want)
+		}
+	}
+
+	var csv strings.Builder
+	if err := g.WriteCSV(&csv, 100); err != nil {
+		t.Fatal(err)
+	}
+	if want := "time_ns,9.80,9.90,10.00,10.10,10.20\n10,2,5,0,0,-4\n"; !strings.HasPrefix(csv.String(), want) {
+		t.Errorf("csv starts %q, want %q", csv.String(), want)
+	}
+}
+
+// TestWriteNPY checks the .npy header and that the data starts on a 64-byte
+// boundary in row-major little-endian order
+func TestWriteNPY(t *testing.T) {
+	g := &Grid{Times: []int64{10, 20}, Prices: []int64{1, 2, 3}, Qty: [][]int64{{1, 2, 3}, {-4, -5, -6}}}
+	var buf bytes.Buffer
+	if err := g.WriteNPY(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if string(data[:8]) != "\x93NUMPY\x01\x00" {
+		t.Fatalf("magic = %q", data[:8])
+	}
+	start := 10 + int(binary.LittleEndian.Uint16(data[8:10]))
+	header := string(data[10:start])
+	if start%64 != 0 || !strings.HasSuffix(header, "\n") ||
+		!strings.HasPrefix(header, "{'descr': '<i8', 'fortran_order': False, 'shape': (2, 3), }") {
+		t.Fatalf("header %q ends at %d", header, start)
+	}
+	if len(data)-start != 6*8 {
+		t.Fatalf("%d data bytes, want 48", len(data)-start)
+	}
+	if v := int64(binary.LittleEndian.Uint64(data[start+3*8:])); v != -4 {
+		t.Errorf("element [1][0] = %d, want -4", v)
+	}
+}
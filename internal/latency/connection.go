@@ -0,0 +1,29 @@
+package latency
+
+// Connection is a trader's session with the exchange. Its first message
+// pays HandshakeNs to set the session up, and a message sent after IdleNs
+// or more of quiet pays WarmupNs, for the caches and TCP window that went
+// cold in between. Messages sent close together pay nothing extra
+type Connection struct {
+	IdleNs      int64
+	WarmupNs    int64
+	HandshakeNs int64
+
+	last int64 // when the previous message was sent
+	open bool
+}
+
+// Send sends a message at t and returns the delay the connection adds
+// before its network latency
+func (c *Connection) Send(t int64) int64 {
+	extra := int64(0)
+	switch {
+	case !c.open:
+		extra = c.HandshakeNs
+		c.open = true
+	case c.IdleNs > 0 && t-c.last >= c.IdleNs:
+		extra = c.WarmupNs
+	}
+	c.last = t
+	return extra
+}
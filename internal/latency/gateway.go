@@ -0,0 +1,18 @@
+package latency
+
+// Gateway is a trader's order entry gateway. It sends one message at a
+// time, each taking ServiceNs, so a burst queues: a message waits for the
+// ones ahead of it before its own network latency starts
+type Gateway struct {
+	ServiceNs int64
+
+	free int64 // when the gateway finishes its current backlog
+}
+
+// Send hands the gateway a message at t and returns when it is sent, and
+// how long it waited behind earlier messages before its own send began
+func (g *Gateway) Send(t int64) (sent, waitNs int64) {
+	start := max(t, g.free)
+	g.free = start + g.ServiceNs
+	return g.free, start - t
+}
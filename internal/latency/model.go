@@ -2,25 +2,62 @@
 package latency
 
 import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
 	"math/rand"
+	"strconv"
 )
 
+// Jitter modes. Stream draws each message's jitter from the trader's RNG
+// in turn, so one extra message shifts every later draw. Hashed derives
+// it from the message itself, leaving unrelated messages' delays alone
+const (
+	JitterStream = "stream"
+	JitterHashed = "hashed"
+)
+
+// ValidateJitterMode accepts "" (stream), stream or hashed
+func ValidateJitterMode(mode string) error {
+	switch mode {
+	case "", JitterStream, JitterHashed:
+		return nil
+	}
+	return fmt.Errorf("unknown jitter mode %q (%s, %s)", mode, JitterStream, JitterHashed)
+}
+
 // Model applies deterministic latency + jitter to messages
 type Model struct {
-	BaseNs int64 // base latency in nanoseconds
+	BaseNs   int64 // base latency in nanoseconds
 	JitterNs int64 // max jitter in nanoseconds (uniform [0, JitterNs))
-	rng    *rand.Rand
+	rng      *rand.Rand
+
+	// hashed models key the jitter on the message rather than the stream
+	hashed bool
+	seed   int64
 }
 
+// Seed returns the seed the model was created with
+func (m *Model) Seed() int64 { return m.seed }
+
 // NewModel creates a latency model with the given parameters and seed
 func NewModel(baseNs, jitterNs int64, seed int64) *Model {
 	return &Model{
 		BaseNs:   baseNs,
 		JitterNs: jitterNs,
 		rng:      rand.New(rand.NewSource(seed)),
+		seed:     seed,
 	}
 }
 
+// NewHashedModel creates a latency model whose jitter is a hash of the
+// seed and the message; see ApplyMessage
+func NewHashedModel(baseNs, jitterNs int64, seed int64) *Model {
+	m := NewModel(baseNs, jitterNs, seed)
+	m.hashed = true
+	return m
+}
+
 // Apply returns the arrival time given a decision time
 func (m *Model) Apply(decisionTime int64) int64 {
 	jitter := int64(0)
@@ -30,7 +67,69 @@ func (m *Model) Apply(decisionTime int64) int64 {
 	return decisionTime + m.BaseNs + jitter
 }
 
+// ApplyMessage returns the arrival time of one trader's message. A hashed
+// model derives the jitter from (trader, trigger, index, decision time)
+// alone, where trigger is the SeqNo of the event the trader decided on and
+// index the message's place among that decision's orders. Order IDs are
+// left out: one extra order renumbers every later one. Otherwise it draws
+// from the stream like Apply
+func (m *Model) ApplyMessage(traderID string, trigger uint64, index int, decisionTime int64) int64 {
+	if !m.hashed {
+		return m.Apply(decisionTime)
+	}
+	jitter := int64(0)
+	if m.JitterNs > 0 {
+		jitter = int64(messageHash(m.seed, traderID, trigger, index, decisionTime) % uint64(m.JitterNs))
+	}
+	return decisionTime + m.BaseNs + jitter
+}
+
+// messageHash is FNV-1a over the fields, finished with a splitmix64 mix
+// so nearby triggers and times land far apart
+func messageHash(seed int64, traderID string, trigger uint64, index int, decisionTime int64) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(seed))
+	h.Write(buf[:])
+	h.Write([]byte(traderID))
+	binary.LittleEndian.PutUint64(buf[:], trigger)
+	h.Write(buf[:])
+	binary.LittleEndian.PutUint64(buf[:], uint64(index))
+	h.Write(buf[:])
+	binary.LittleEndian.PutUint64(buf[:], uint64(decisionTime))
+	h.Write(buf[:])
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
 // MsToNs converts milliseconds to nanoseconds
 func MsToNs(ms int64) int64 {
 	return ms * 1_000_000
 }
+
+// UsToNs converts microseconds to nanoseconds
+func UsToNs(us int64) int64 {
+	return us * 1_000
+}
+
+// Format renders a latency in the largest unit that keeps it readable:
+// "50 ms", "1.25 ms", "150 µs", "800 ns"
+func Format(ns int64) string {
+	abs := ns
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs >= 1_000_000 || abs == 0:
+		return strconv.FormatFloat(float64(ns)/1e6, 'f', -1, 64) + " ms"
+	case abs >= 1_000:
+		return strconv.FormatFloat(float64(ns)/1e3, 'f', -1, 64) + " µs"
+	default:
+		return strconv.FormatInt(ns, 10) + " ns"
+	}
+}
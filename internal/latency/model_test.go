@@ -1,6 +1,7 @@
 package latency
 
 import (
+	"strconv"
 	"testing"
 )
 
@@ -50,6 +51,97 @@ func TestModelJitterBounds(t *testing.T) {
 	}
 }
 
+// TestHashedModel checks a hashed model's jitter depends only on the
+// message: other messages in between, or none, leave it unchanged
+func TestHashedModel(t *testing.T) {
+	base, jitter := MsToNs(5), MsToNs(3)
+	alone := NewHashedModel(base, jitter, 42)
+	busy := NewHashedModel(base, jitter, 42)
+
+	distinct := make(map[int64]bool)
+	for i := uint64(0); i < 1000; i++ {
+		decision := int64(i) * MsToNs(10)
+		busy.ApplyMessage("other", i, 0, decision)
+		a1 := alone.ApplyMessage("fast", i, int(i%3), decision)
+		a2 := busy.ApplyMessage("fast", i, int(i%3), decision)
+		if a1 != a2 {
+			t.Fatalf("order %d: %d alone, %d among other messages", i, a1, a2)
+		}
+		delay := a1 - decision
+		if delay < base || delay >= base+jitter {
+			t.Fatalf("delay %d outside [%d, %d)", delay, base, base+jitter)
+		}
+		distinct[delay] = true
+	}
+	if len(distinct) < 900 {
+		t.Errorf("only %d distinct delays in 1000 messages", len(distinct))
+	}
+	if NewHashedModel(base, jitter, 43).ApplyMessage("fast", 1, 0, 0) == alone.ApplyMessage("fast", 1, 0, 0) &&
+		NewHashedModel(base, jitter, 43).ApplyMessage("fast", 2, 0, 0) == alone.ApplyMessage("fast", 2, 0, 0) {
+		t.Error("seed does not change the jitter")
+	}
+	if err := ValidateJitterMode("sorted"); err == nil {
+		t.Error("unknown jitter mode accepted")
+	}
+}
+
+// TestGatewayQueues sends a burst through a gateway: each message waits
+// for the ones ahead, and an idle gateway sends at once
+func TestGatewayQueues(t *testing.T) {
+	g := &Gateway{ServiceNs: 100}
+	for i, want := range []struct{ sent, wait int64 }{{100, 0}, {200, 100}, {300, 200}} {
+		if sent, wait := g.Send(0); sent != want.sent || wait != want.wait {
+			t.Errorf("message %d: sent %d after %d, want %d after %d", i, sent, wait, want.sent, want.wait)
+		}
+	}
+	if sent, wait := g.Send(1_000); sent != 1_100 || wait != 0 {
+		t.Errorf("idle gateway: sent %d after %d", sent, wait)
+	}
+}
+
+// TestConnectionWarmup charges the handshake once, then warm-up only to
+// messages after an idle gap
+func TestConnectionWarmup(t *testing.T) {
+	c := &Connection{IdleNs: 1_000, WarmupNs: 50, HandshakeNs: 300}
+	for i, m := range []struct{ t, extra int64 }{{0, 300}, {10, 0}, {999, 0}, {1_999, 50}, {2_500, 0}, {10_000, 50}} {
+		if extra := c.Send(m.t); extra != m.extra {
+			t.Errorf("message %d at %d: extra %d, want %d", i, m.t, extra, m.extra)
+		}
+	}
+}
+
+// TestPathStacksStages sends messages down a path of every stage: each
+// stage's hold is annotated and adds up, with the network draw last and
+// keyed on the decision time as a bare model's would be
+func TestPathStacksStages(t *testing.T) {
+	path := Path{RiskCheck{Ns: 30}, &Gateway{ServiceNs: 100}, &Connection{HandshakeNs: 500},
+		Network{Model: NewHashedModel(1_000, 50, 9)}}
+	bare := NewHashedModel(1_000, 50, 9)
+	for i, want := range []map[string]string{
+		{"risk_check_ns": "30", "gateway_ns": "100", "warmup_ns": "500"},
+		{"risk_check_ns": "30", "gateway_ns": "200", "gateway_wait_ns": "100"},
+	} {
+		m := Message{TraderID: "slow", DecisionTime: 10, Trigger: 5, Index: i}
+		arrival := path.Send(&m)
+		if len(m.Meta) != len(want) {
+			t.Errorf("message %d: annotations %v, want %v", i, m.Meta, want)
+		}
+		held := int64(0)
+		for k, v := range want {
+			if m.Meta[k] != v {
+				t.Errorf("message %d: %s = %q, want %q", i, k, m.Meta[k], v)
+			}
+			if k != "gateway_wait_ns" {
+				n, _ := strconv.ParseInt(v, 10, 64)
+				held += n
+			}
+		}
+		if network := bare.ApplyMessage("slow", 5, i, 10); arrival != network+held {
+			t.Errorf("message %d: arrival %d, want %d", i, arrival, network+held)
+		}
+	}
+}
+
 func TestMsToNs(t *testing.T) {
 	if MsToNs(1) != 1_000_000 {
 		t.Errorf("MsToNs(1) = %d, want 1000000", MsToNs(1))
@@ -58,3 +150,39 @@ func TestMsToNs(t *testing.T) {
 		t.Errorf("MsToNs(50) = %d, want 50000000", MsToNs(50))
 	}
 }
+
+func TestUsToNs(t *testing.T) {
+	if UsToNs(150) != 150_000 {
+		t.Errorf("UsToNs(150) = %d, want 150000", UsToNs(150))
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		ns   int64
+		want string
+	}{
+		{0, "0 ms"},
+		{MsToNs(50), "50 ms"},
+		{MsToNs(1) + UsToNs(250), "1.25 ms"},
+		{UsToNs(150), "150 µs"},
+		{UsToNs(20) + 500, "20.5 µs"},
+		{800, "800 ns"},
+		{-MsToNs(49), "-49 ms"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.ns); got != tt.want {
+			t.Errorf("Format(%d) = %q, want %q", tt.ns, got, tt.want)
+		}
+	}
+}
+
+func TestModelSubMillisecond(t *testing.T) {
+	m := NewModel(UsToNs(150), UsToNs(20), 7)
+	for i := 0; i < 1000; i++ {
+		delay := m.Apply(0)
+		if delay < UsToNs(150) || delay >= UsToNs(170) {
+			t.Fatalf("delay %d outside [150µs, 170µs)", delay)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package latency
+
+import "strconv"
+
+// Message is one trader message on its way to the venue, collecting the
+// annotations the stages it passes add
+type Message struct {
+	TraderID     string
+	DecisionTime int64
+	Meta         map[string]string
+
+	// Trigger and Index identify the message to a hashed model: the SeqNo
+	// of the event the trader decided on and the message's place among
+	// that decision's orders
+	Trigger uint64
+	Index   int
+}
+
+// Annotate records a stage's hold under key
+func (m *Message) Annotate(key string, ns int64) {
+	if m.Meta == nil {
+		m.Meta = make(map[string]string, 1)
+	}
+	m.Meta[key] = strconv.FormatInt(ns, 10)
+}
+
+// Stage is one step of a trader's path to the venue. Pass takes a message
+// reaching the stage at t and returns how long the stage holds it,
+// annotating the message with the hold so metrics can tell the stages
+// apart
+type Stage interface {
+	Pass(m *Message, t int64) int64
+}
+
+// Path is a trader's stages in the order its messages pass them. Adding a
+// delay mechanism is adding a stage; the runner only calls Send
+type Path []Stage
+
+// Send passes m through every stage and returns when it reaches the venue
+func (p Path) Send(m *Message) int64 {
+	t := m.DecisionTime
+	for _, s := range p {
+		t += s.Pass(m, t)
+	}
+	return t
+}
+
+// Network is the trader's network latency. Its draw is keyed on the
+// decision time, not when the message reaches it, so adding stages ahead
+// of it leaves the draws unchanged. It is the remainder of arrival minus
+// decision once the annotated stages are taken out, and is not annotated
+type Network struct {
+	Model *Model
+}
+
+func (n Network) Pass(m *Message, t int64) int64 {
+	return n.Model.ApplyMessage(m.TraderID, m.Trigger, m.Index, m.DecisionTime) - m.DecisionTime
+}
+
+// Pass queues m in the gateway, annotating gateway_ns with its wait plus
+// send time, and gateway_wait_ns with the wait when it had one
+func (g *Gateway) Pass(m *Message, t int64) int64 {
+	sent, waitNs := g.Send(t)
+	m.Annotate("gateway_ns", sent-t)
+	if waitNs > 0 {
+		m.Annotate("gateway_wait_ns", waitNs)
+	}
+	return sent - t
+}
+
+// Pass sends m on the connection, annotating warmup_ns when it found the
+// connection cold
+func (c *Connection) Pass(m *Message, t int64) int64 {
+	extra := c.Send(t)
+	if extra > 0 {
+		m.Annotate("warmup_ns", extra)
+	}
+	return extra
+}
+
+// RiskCheck is a pre-trade risk check that holds every message for Ns,
+// annotated risk_check_ns
+type RiskCheck struct {
+	Ns int64
+}
+
+func (r RiskCheck) Pass(m *Message, t int64) int64 {
+	m.Annotate("risk_check_ns", r.Ns)
+	return r.Ns
+}
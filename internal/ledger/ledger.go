@@ -0,0 +1,81 @@
+// Package ledger keeps a domain.Account per trader, booked from executed
+// trades. The runner logs each updated account as an ACCOUNT_UPDATE event,
+// and anything that needs positions or PnL reads them here instead of
+// summing trades itself
+package ledger
+
+import (
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// Ledger holds every trader's account and the fee schedule
+type Ledger struct {
+	makerFee int64 // per share, charged to the passive side
+	takerFee int64 // per share, charged to the aggressor
+	accounts map[string]*domain.Account
+}
+
+// New returns an empty ledger with per-share maker and taker fees in
+// price units; negative fees are rebates
+func New(makerFee, takerFee int64) *Ledger {
+	return &Ledger{
+		makerFee: makerFee,
+		takerFee: takerFee,
+		accounts: make(map[string]*domain.Account),
+	}
+}
+
+// Apply books a trade against both sides and returns their accounts. The
+// accounts are live: copy them before the next Apply if they must not change
+func (l *Ledger) Apply(t *domain.Trade) (buyer, seller *domain.Account) {
+	buyer = l.account(t.BuyTrader)
+	buyer.Fill(domain.Buy, t.Price, t.Qty, l.fee(t, t.BuyOrderID))
+	seller = l.account(t.SellTrader)
+	seller.Fill(domain.Sell, t.Price, t.Qty, l.fee(t, t.SellOrderID))
+	return buyer, seller
+}
+
+// fee is the charge for the side of t that orderID was on. Trades that do
+// not name their passive order are charged as taker on both sides
+func (l *Ledger) fee(t *domain.Trade, orderID uint64) int64 {
+	if t.PassiveOrderID != 0 && t.PassiveOrderID == orderID {
+		return l.makerFee * t.Qty
+	}
+	return l.takerFee * t.Qty
+}
+
+func (l *Ledger) account(traderID string) *domain.Account {
+	a, ok := l.accounts[traderID]
+	if !ok {
+		a = &domain.Account{TraderID: traderID}
+		l.accounts[traderID] = a
+	}
+	return a
+}
+
+// Account returns a copy of a trader's account; a trader who has not
+// traded has an empty one
+func (l *Ledger) Account(traderID string) domain.Account {
+	if a, ok := l.accounts[traderID]; ok {
+		return *a
+	}
+	return domain.Account{TraderID: traderID}
+}
+
+// Position returns a trader's signed net position
+func (l *Ledger) Position(traderID string) int64 {
+	if a, ok := l.accounts[traderID]; ok {
+		return a.Position
+	}
+	return 0
+}
+
+// Accounts returns copies of every account, ordered by trader ID
+func (l *Ledger) Accounts() []domain.Account {
+	out := make([]domain.Account, 0, len(l.accounts))
+	for _, a := range detorder.Values(l.accounts) {
+		out = append(out, *a)
+	}
+	return out
+}
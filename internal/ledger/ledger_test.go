@@ -0,0 +1,83 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+func TestApplyChargesMakerAndTaker(t *testing.T) {
+	l := New(-2, 3)
+	l.Apply(&domain.Trade{BuyOrderID: 1, SellOrderID: 2, BuyTrader: "fast", SellTrader: "slow",
+		Price: 1_000_000, Qty: 10, PassiveOrderID: 2, AggressorOrderID: 1})
+
+	fast, slow := l.Account("fast"), l.Account("slow")
+	if fast.Fees != 30 || slow.Fees != -20 {
+		t.Errorf("fees fast %d slow %d, want 30 and -20", fast.Fees, slow.Fees)
+	}
+	if fast.Cash != -10_000_030 || slow.Cash != 10_000_020 {
+		t.Errorf("cash fast %d slow %d", fast.Cash, slow.Cash)
+	}
+	if l.Position("fast") != 10 || l.Position("slow") != -10 || l.Position("nobody") != 0 {
+		t.Errorf("positions %d %d %d", l.Position("fast"), l.Position("slow"), l.Position("nobody"))
+	}
+}
+
+func TestPositionsNetToZero(t *testing.T) {
+	l := New(0, 0)
+	trades := []domain.Trade{
+		{BuyTrader: "fast", SellTrader: "background", Price: 1_000_100, Qty: 5},
+		{BuyTrader: "background", SellTrader: "slow", Price: 999_900, Qty: 7},
+		{BuyTrader: "slow", SellTrader: "fast", Price: 1_000_000, Qty: 3},
+		{BuyTrader: "fast", SellTrader: "fast", Price: 1_000_000, Qty: 2},
+	}
+	for i := range trades {
+		l.Apply(&trades[i])
+	}
+	var net, cash int64
+	var ids []string
+	for _, a := range l.Accounts() {
+		net += a.Position
+		cash += a.Cash
+		ids = append(ids, a.TraderID)
+	}
+	if net != 0 || cash != 0 {
+		t.Errorf("net position %d, net cash %d; want 0 and 0", net, cash)
+	}
+	if len(ids) != 3 || ids[0] != "background" || ids[2] != "slow" {
+		t.Errorf("accounts = %v, want sorted by trader", ids)
+	}
+	if a := l.Account("fast"); a.Position != 2 || a.Fills != 4 {
+		t.Errorf("fast = %+v, want position 2 over 4 fills", a)
+	}
+}
+
+func TestLimitAllows(t *testing.T) {
+	// $10,000 at $100 on 2:1 margin: 200 shares of exposure
+	lim := Limit{Capital: 100_000_000, MarginBps: 5_000}
+	mark := int64(1_000_000)
+	var a domain.Account
+
+	if ok, req := lim.Allows(a, domain.Buy, 200, 0, 0, mark); !ok || req != 100_000_000 {
+		t.Errorf("200 shares: allowed %v, required %d", ok, req)
+	}
+	if ok, _ := lim.Allows(a, domain.Buy, 150, 60, 0, mark); ok {
+		t.Error("150 more behind 60 open allowed")
+	}
+	// Open orders on the other side do not add up with these
+	if ok, _ := lim.Allows(a, domain.Sell, 150, 60, 0, mark); !ok {
+		t.Error("sell against open buys refused")
+	}
+
+	// Long 300 after a loss: over the limit, but selling still reduces it
+	a.Fill(domain.Buy, mark, 300, 0)
+	if ok, _ := lim.Allows(a, domain.Buy, 1, 0, 0, 900_000); ok {
+		t.Error("adding to a position over the limit allowed")
+	}
+	if ok, _ := lim.Allows(a, domain.Sell, 100, 0, 0, 900_000); !ok {
+		t.Error("reducing a position over the limit refused")
+	}
+	if ok, _ := lim.Allows(a, domain.Sell, 700, 0, 0, 900_000); ok {
+		t.Error("flipping to a larger short allowed")
+	}
+}
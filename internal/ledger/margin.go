@@ -0,0 +1,45 @@
+package ledger
+
+import "github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+
+// Limit is a trader's buying power. Capital is in price units times
+// quantity, like Account's money fields; MarginBps is the share of the
+// trader's exposure held against it, in basis points: 10_000 for a cash
+// account, 2_500 for 4:1 leverage
+type Limit struct {
+	Capital   int64
+	MarginBps int64
+}
+
+// Exposure is the largest position a trader could reach, long or short,
+// if every open order on one side filled
+func Exposure(position, openBuy, openSell int64) int64 {
+	return max(position+openBuy, openSell-position, 0)
+}
+
+// Required is the capital held against exposure at mark
+func (l Limit) Required(exposure, mark int64) int64 {
+	return exposure * mark * l.MarginBps / 10_000
+}
+
+// Equity is the trader's capital plus its account's cash and open
+// position marked at mark
+func (l Limit) Equity(a domain.Account, mark int64) int64 {
+	return l.Capital + a.Cash + a.Position*mark
+}
+
+// Allows reports whether the trader may send an order of qty on side,
+// given its open orders, and the capital it would then require. An order
+// that does not add to the trader's exposure is always allowed, so a
+// trader over its limit can still reduce its position or quotes
+func (l Limit) Allows(a domain.Account, side domain.Side, qty, openBuy, openSell, mark int64) (bool, int64) {
+	before := Exposure(a.Position, openBuy, openSell)
+	if side == domain.Buy {
+		openBuy += qty
+	} else {
+		openSell += qty
+	}
+	after := Exposure(a.Position, openBuy, openSell)
+	required := l.Required(after, mark)
+	return after <= before || required <= l.Equity(a, mark), required
+}
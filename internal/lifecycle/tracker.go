@@ -0,0 +1,292 @@
+// Package lifecycle follows every order through its state machine
+// (domain.OrderState) as the book processes it. The runner logs each
+// transition as an ORDER_STATE event, and the same tracker replays a log,
+// so metrics read lifecycle from one place instead of inferring it
+package lifecycle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// Violation is a transition the state machine does not allow, e.g. a
+// fill for an order that was already canceled
+type Violation struct {
+	Timestamp int64
+	OrderID   uint64
+	From      domain.OrderState
+	To        domain.OrderState
+	Detail    string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("order %d: illegal transition %s -> %s at t=%d: %s",
+		v.OrderID, v.From, v.To, v.Timestamp, v.Detail)
+}
+
+type order struct {
+	traderID string
+	side     domain.Side
+	price    int64
+	state    domain.OrderState
+	qty      int64
+	filled   int64
+	tif      domain.TimeInForce
+}
+
+// Tracker holds the state of every order seen so far
+type Tracker struct {
+	live map[uint64]*order            // orders that can still change state
+	done map[uint64]domain.OrderState // final state of terminal orders
+
+	changes []domain.StateChange
+}
+
+// New returns an empty tracker
+func New() *Tracker {
+	return &Tracker{
+		live: make(map[uint64]*order),
+		done: make(map[uint64]domain.OrderState),
+	}
+}
+
+// State returns an order's current state and whether it has been seen
+func (t *Tracker) State(id uint64) (domain.OrderState, bool) {
+	if o, ok := t.live[id]; ok {
+		return o.state, true
+	}
+	s, ok := t.done[id]
+	return s, ok
+}
+
+// Remaining returns a live order's unfilled, uncanceled quantity
+func (t *Tracker) Remaining(id uint64) (int64, bool) {
+	o, ok := t.live[id]
+	if !ok {
+		return 0, false
+	}
+	return o.qty - o.filled, true
+}
+
+// Live returns the number of orders not yet in a terminal state
+func (t *Tracker) Live() int {
+	return len(t.live)
+}
+
+// Apply advances the state machine with an event, in log order, and
+// returns the transitions it caused. The slice is reused by the next call
+// that returns transitions. Events with no lifecycle effect, including
+// ORDER_STATE itself, return nil. An illegal transition is returned as a
+// *Violation; the tracker still records the new state
+func (t *Tracker) Apply(e *domain.Event) ([]domain.StateChange, error) {
+	switch e.Type {
+	case domain.EventOrderAccepted:
+		if c := e.Cancel(); c != nil {
+			t.changes = t.changes[:0]
+			t.request(c)
+			return t.changes, nil
+		}
+		if o := e.Order(); o != nil {
+			t.changes = t.changes[:0]
+			return t.changes, t.accept(o, e.Timestamp)
+		}
+	case domain.EventTradeExecuted:
+		if tr := e.Trade(); tr != nil && tr.PassiveOrderID != 0 {
+			t.changes = t.changes[:0]
+			return t.changes, t.fill(tr)
+		}
+	case domain.EventOrderCanceled:
+		if c := e.Cancel(); c != nil {
+			t.changes = t.changes[:0]
+			return t.changes, t.cancel(c, e.Timestamp)
+		}
+	case domain.EventSimEnd:
+		t.changes = t.changes[:0]
+		t.expire()
+		return t.changes, nil
+	case domain.EventSession:
+		// Day orders expire at each session's close
+		if phase, _ := e.Annotation("phase"); phase == domain.PhaseClosed {
+			t.changes = t.changes[:0]
+			t.expire()
+			return t.changes, nil
+		}
+	}
+	return nil, nil
+}
+
+// accept records an order's first transition. The book has already
+// matched it, so its logged remaining quantity says where it ended up.
+// An IOC or FOK order with a remainder stays new until the venue's cancel
+// of the remainder, which may follow fills as the passive side in an
+// auction
+func (t *Tracker) accept(o *domain.Order, ts int64) error {
+	if prev, seen := t.State(o.ID); seen {
+		return &Violation{Timestamp: ts, OrderID: o.ID, From: prev, To: domain.StateNew,
+			Detail: "order accepted twice"}
+	}
+	filled := o.Qty - o.RemainingQty
+	var to domain.OrderState
+	var reason string
+	switch {
+	case o.RemainingQty == 0:
+		to = domain.StateFilled
+	case o.Type == domain.MarketOrder && filled > 0:
+		to, reason = domain.StateCanceled, "unfilled market remainder"
+	case o.Type == domain.MarketOrder:
+		to, reason = domain.StateRejected, "no liquidity"
+	case filled > 0:
+		to = domain.StatePartiallyFilled
+	default:
+		to = domain.StateResting
+	}
+	st := &order{traderID: o.TraderID, side: o.Side, price: o.Price, state: to, qty: o.Qty, filled: filled, tif: o.TimeInForce}
+	if o.Immediate() && o.RemainingQty > 0 {
+		st.state = domain.StateNew
+		t.live[o.ID] = st
+		return nil
+	}
+	t.record(o.ID, st, domain.StateNew, reason)
+	return nil
+}
+
+// Reject records an order refused before it reached the book, which is
+// therefore never logged as accepted, and returns its one transition for
+// the caller to log
+func (t *Tracker) Reject(o *domain.Order, reason string) domain.StateChange {
+	t.done[o.ID] = domain.StateRejected
+	return domain.StateChange{
+		OrderID:  o.ID,
+		TraderID: o.TraderID,
+		From:     domain.StateNew,
+		To:       domain.StateRejected,
+		Reason:   reason,
+	}
+}
+
+// fill applies a trade to its passive order; the aggressor's fills are
+// already reflected in its accept transition
+func (t *Tracker) fill(tr *domain.Trade) error {
+	id := tr.PassiveOrderID
+	o, ok := t.live[id]
+	if !ok {
+		from, seen := t.done[id]
+		detail := "fill for an order never accepted"
+		if seen {
+			detail = "fill for an order already " + strings.ToLower(from.String())
+		}
+		return &Violation{Timestamp: tr.Timestamp, OrderID: id, From: from, To: domain.StateFilled, Detail: detail}
+	}
+	o.filled += tr.Qty
+	to := domain.StatePartiallyFilled
+	if o.filled >= o.qty {
+		to = domain.StateFilled
+	}
+	from := o.state
+	if to == from {
+		return nil
+	}
+	o.state = to
+	t.record(id, o, from, "")
+	if o.filled > o.qty {
+		return &Violation{Timestamp: tr.Timestamp, OrderID: id, From: from, To: to,
+			Detail: fmt.Sprintf("filled %d of %d", o.filled, o.qty)}
+	}
+	return nil
+}
+
+// request checks a cancel instruction against its target the way the
+// book does. One the book refuses, because the target has already left
+// it (typically a fill won the race) or fails domain.CheckCancel, is
+// itself rejected; one it accepts waits for its ORDER_CANCELED ack
+func (t *Tracker) request(c *domain.Order) {
+	var reason string
+	if o, ok := t.live[c.CancelID]; ok {
+		target := domain.Order{ID: c.CancelID, TraderID: o.traderID, Side: o.side, Price: o.price}
+		if reason = domain.CheckCancel(c, &target); reason == "" {
+			return
+		}
+	} else if s, seen := t.done[c.CancelID]; seen {
+		reason = fmt.Sprintf("target %d already %s", c.CancelID, strings.ToLower(s.String()))
+	} else {
+		reason = fmt.Sprintf("target %d unknown", c.CancelID)
+	}
+	t.changes = append(t.changes, domain.StateChange{
+		OrderID:  c.ID,
+		TraderID: c.TraderID,
+		From:     domain.StateNew,
+		To:       domain.StateRejected,
+		Reason:   reason,
+	})
+	t.done[c.ID] = domain.StateRejected
+}
+
+// cancel applies a cancel ack to its target. A partial cancel that
+// leaves the target some quantity shrinks it without a transition
+func (t *Tracker) cancel(c *domain.Order, ts int64) error {
+	o, ok := t.live[c.CancelID]
+	if !ok {
+		from, seen := t.done[c.CancelID]
+		detail := "cancel ack for an order never accepted"
+		if seen {
+			detail = "cancel ack for an order already " + strings.ToLower(from.String())
+		}
+		return &Violation{Timestamp: ts, OrderID: c.CancelID, From: from, To: domain.StateCanceled, Detail: detail}
+	}
+	if s, rejected := t.done[c.ID]; rejected && s == domain.StateRejected {
+		return &Violation{Timestamp: ts, OrderID: c.CancelID, From: o.state, To: domain.StateCanceled,
+			Detail: fmt.Sprintf("cancel %d acked after it was rejected", c.ID)}
+	}
+	if c.RemainingQty > 0 {
+		if left := o.qty - o.filled; c.RemainingQty >= left {
+			return &Violation{Timestamp: ts, OrderID: c.CancelID, From: o.state, To: o.state,
+				Detail: fmt.Sprintf("cancel %d leaves %d of %d remaining", c.ID, c.RemainingQty, left)}
+		}
+		o.qty = o.filled + c.RemainingQty
+		return nil
+	}
+	from := o.state
+	o.state = domain.StateCanceled
+	var reason string
+	if c.ID == c.CancelID {
+		reason = "unfilled " + o.tif.String() + " remainder"
+	}
+	t.record(c.CancelID, o, from, reason)
+	return nil
+}
+
+// expire ends every order still live at the close, the run's or a
+// session's, in order ID order
+func (t *Tracker) expire() {
+	for id, o := range detorder.All(t.live) {
+		from := o.state
+		o.state = domain.StateExpired
+		t.record(id, o, from, "")
+	}
+}
+
+// record appends a transition and moves terminal orders out of live
+func (t *Tracker) record(id uint64, o *order, from domain.OrderState, reason string) {
+	remaining := o.qty - o.filled
+	if o.state == domain.StateCanceled || o.state == domain.StateExpired || o.state == domain.StateRejected {
+		remaining = 0
+	}
+	t.changes = append(t.changes, domain.StateChange{
+		OrderID:      id,
+		TraderID:     o.traderID,
+		From:         from,
+		To:           o.state,
+		FilledQty:    o.filled,
+		RemainingQty: remaining,
+		Reason:       reason,
+	})
+	if o.state.Terminal() {
+		delete(t.live, id)
+		t.done[id] = o.state
+	} else {
+		t.live[id] = o
+	}
+}
@@ -0,0 +1,220 @@
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+func accepted(id uint64, typ domain.OrderType, qty, remaining int64) *domain.Event {
+	e := domain.NewOrderEvent(0, &domain.Order{ID: id, TraderID: "fast", Type: typ, Qty: qty, RemainingQty: remaining})
+	return &e
+}
+
+func trade(passive, aggressor uint64, qty int64) *domain.Event {
+	e := domain.NewTradeEvent(0, &domain.Trade{PassiveOrderID: passive, AggressorOrderID: aggressor, Qty: qty})
+	return &e
+}
+
+func requested(id, target uint64) *domain.Event {
+	e := domain.NewOrderEvent(0, &domain.Order{ID: id, TraderID: "fast", Type: domain.CancelOrder, CancelID: target})
+	return &e
+}
+
+func canceled(id, target uint64) *domain.Event {
+	e := domain.NewCancelEvent(0, &domain.Order{ID: id, TraderID: "fast", Type: domain.CancelOrder, CancelID: target})
+	return &e
+}
+
+type step struct {
+	e    *domain.Event
+	want []string // "id:FROM->TO"
+}
+
+func run(t *testing.T, tr *Tracker, steps []step) {
+	t.Helper()
+	for i, s := range steps {
+		changes, err := tr.Apply(s.e)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if len(changes) != len(s.want) {
+			t.Fatalf("step %d: got %v, want %v", i, changes, s.want)
+		}
+		for j, c := range changes {
+			if got := format(c); got != s.want[j] {
+				t.Errorf("step %d: got %s, want %s", i, got, s.want[j])
+			}
+		}
+	}
+}
+
+func format(c domain.StateChange) string {
+	return fmt.Sprintf("%d:%s->%s", c.OrderID, c.From, c.To)
+}
+
+func TestLimitOrderLifecycle(t *testing.T) {
+	tr := New()
+	run(t, tr, []step{
+		{accepted(1, domain.LimitOrder, 10, 10), []string{"1:NEW->RESTING"}},
+		{trade(1, 9, 4), []string{"1:RESTING->PARTIALLY_FILLED"}},
+		{trade(1, 9, 3), nil}, // still partially filled
+		{trade(1, 9, 3), []string{"1:PARTIALLY_FILLED->FILLED"}},
+		{accepted(2, domain.LimitOrder, 5, 2), []string{"2:NEW->PARTIALLY_FILLED"}},
+		{accepted(3, domain.LimitOrder, 5, 0), []string{"3:NEW->FILLED"}},
+	})
+	if tr.Live() != 1 {
+		t.Errorf("live = %d, want 1", tr.Live())
+	}
+}
+
+func TestMarketOrderOutcomes(t *testing.T) {
+	run(t, New(), []step{
+		{accepted(1, domain.MarketOrder, 5, 0), []string{"1:NEW->FILLED"}},
+		{accepted(2, domain.MarketOrder, 5, 3), []string{"2:NEW->CANCELED"}},
+		{accepted(3, domain.MarketOrder, 5, 5), []string{"3:NEW->REJECTED"}},
+	})
+}
+
+// TestCancelRacingFill: a cancel that arrives after the fill is rejected,
+// and the filled order stays filled
+func TestCancelRacingFill(t *testing.T) {
+	tr := New()
+	run(t, tr, []step{
+		{accepted(1, domain.LimitOrder, 5, 5), []string{"1:NEW->RESTING"}},
+		{accepted(2, domain.LimitOrder, 5, 5), []string{"2:NEW->RESTING"}},
+		{trade(1, 9, 5), []string{"1:RESTING->FILLED"}},
+		{requested(3, 1), []string{"3:NEW->REJECTED"}},
+		{requested(4, 2), nil}, // waits for its ack
+		{canceled(4, 2), []string{"2:RESTING->CANCELED"}},
+		{requested(5, 2), []string{"5:NEW->REJECTED"}},
+		{requested(6, 8), []string{"6:NEW->REJECTED"}},
+	})
+	changes, _ := tr.Apply(requested(7, 1))
+	if changes[0].Reason != "target 1 already filled" {
+		t.Errorf("reason = %q", changes[0].Reason)
+	}
+	if s, _ := tr.State(1); s != domain.StateFilled {
+		t.Errorf("order 1 is %s after late cancel", s)
+	}
+}
+
+// TestPartialCancel: a cancel that leaves its target some quantity keeps
+// it live without a transition, and fills then complete the smaller order
+func TestPartialCancel(t *testing.T) {
+	tr := New()
+	reduced := canceled(3, 1)
+	reduced.Order().Qty, reduced.Order().RemainingQty = 4, 3
+	run(t, tr, []step{
+		{accepted(1, domain.LimitOrder, 10, 10), []string{"1:NEW->RESTING"}},
+		{trade(1, 9, 3), []string{"1:RESTING->PARTIALLY_FILLED"}},
+		{requested(3, 1), nil},
+		{reduced, nil},
+		{trade(1, 9, 3), []string{"1:PARTIALLY_FILLED->FILLED"}},
+	})
+	if left, live := tr.Remaining(1); live {
+		t.Errorf("order 1 still live with %d", left)
+	}
+
+	tr.Apply(accepted(4, domain.LimitOrder, 5, 5))
+	tooMuch := canceled(5, 4)
+	tooMuch.Order().Qty, tooMuch.Order().RemainingQty = 1, 5
+	if _, err := tr.Apply(tooMuch); err == nil {
+		t.Error("partial cancel leaving the whole order accepted")
+	}
+}
+
+// TestForeignCancelRejected: a cancel from another trader, or one naming
+// the wrong side or price, is rejected and leaves its target resting
+func TestForeignCancelRejected(t *testing.T) {
+	tr := New()
+	order := domain.NewOrderEvent(0, &domain.Order{ID: 1, TraderID: "fast", Side: domain.Buy,
+		Type: domain.LimitOrder, Price: 100, Qty: 5, RemainingQty: 5})
+	foreign := domain.NewOrderEvent(0, &domain.Order{ID: 2, TraderID: "slow", Type: domain.CancelOrder, CancelID: 1})
+	wrongPrice := domain.NewOrderEvent(0, &domain.Order{ID: 3, TraderID: "fast", Side: domain.Buy,
+		Type: domain.CancelOrder, Price: 101, CancelID: 1})
+	matching := domain.NewOrderEvent(0, &domain.Order{ID: 4, TraderID: "fast", Side: domain.Buy,
+		Type: domain.CancelOrder, Price: 100, CancelID: 1})
+	run(t, tr, []step{
+		{&order, []string{"1:NEW->RESTING"}},
+		{&wrongPrice, []string{"3:NEW->REJECTED"}},
+		{&matching, nil},
+	})
+	changes, _ := tr.Apply(&foreign)
+	if want := "target 1 owned by fast"; len(changes) != 1 || changes[0].Reason != want {
+		t.Errorf("foreign cancel: got %v, want rejection %q", changes, want)
+	}
+	if s, _ := tr.State(1); s != domain.StateResting {
+		t.Errorf("order 1 is %s after refused cancels", s)
+	}
+	if _, err := tr.Apply(canceled(2, 1)); err == nil {
+		t.Error("ack of a rejected cancel accepted")
+	}
+}
+
+func TestExpireAtClose(t *testing.T) {
+	tr := New()
+	end := &domain.Event{Type: domain.EventSimEnd}
+	run(t, tr, []step{
+		{accepted(3, domain.LimitOrder, 5, 5), []string{"3:NEW->RESTING"}},
+		{accepted(1, domain.LimitOrder, 5, 5), []string{"1:NEW->RESTING"}},
+		{trade(3, 9, 2), []string{"3:RESTING->PARTIALLY_FILLED"}},
+		{end, []string{"1:RESTING->EXPIRED", "3:PARTIALLY_FILLED->EXPIRED"}},
+	})
+	if tr.Live() != 0 {
+		t.Errorf("live = %d after close", tr.Live())
+	}
+}
+
+func TestImmediateRemainderKilled(t *testing.T) {
+	tr := New()
+	ioc := accepted(4, domain.LimitOrder, 5, 3)
+	ioc.Order().TimeInForce = domain.IOC
+	run(t, tr, []step{{ioc, nil}})
+	changes, err := tr.Apply(canceled(4, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || format(changes[0]) != "4:NEW->CANCELED" {
+		t.Fatalf("got %v, want the remainder canceled", changes)
+	}
+	if got := changes[0].Reason; got != "unfilled IOC remainder" {
+		t.Errorf("reason %q", got)
+	}
+}
+
+func TestIllegalTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup []*domain.Event
+		bad   *domain.Event
+	}{
+		{"accepted twice", []*domain.Event{accepted(1, domain.LimitOrder, 5, 5)}, accepted(1, domain.LimitOrder, 5, 5)},
+		{"fill after cancel", []*domain.Event{accepted(1, domain.LimitOrder, 5, 5), canceled(2, 1)}, trade(1, 9, 1)},
+		{"cancel ack after fill", []*domain.Event{accepted(1, domain.LimitOrder, 5, 0)}, canceled(2, 1)},
+		{"fill for unknown order", nil, trade(1, 9, 1)},
+		{"overfill", []*domain.Event{accepted(1, domain.LimitOrder, 5, 5)}, trade(1, 9, 6)},
+	}
+	for _, tt := range tests {
+		tr := New()
+		for _, e := range tt.setup {
+			if _, err := tr.Apply(e); err != nil {
+				t.Fatalf("%s: setup: %v", tt.name, err)
+			}
+		}
+		_, err := tr.Apply(tt.bad)
+		var v *Violation
+		if !errors.As(err, &v) {
+			t.Errorf("%s: got %v, want a Violation", tt.name, err)
+		}
+	}
+}
+
+func TestStateEventsIgnored(t *testing.T) {
+	e := domain.NewStateEvent(0, &domain.StateChange{OrderID: 1, To: domain.StateResting})
+	if changes, err := New().Apply(&e); changes != nil || err != nil {
+		t.Errorf("Apply(ORDER_STATE) = %v, %v", changes, err)
+	}
+}
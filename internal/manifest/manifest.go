@@ -0,0 +1,96 @@
+// Package manifest keeps the runs directory's manifest.json, which names
+// the run marked as baseline. Day-to-day runs are compared against it, so
+// the manifest holds a copy of the baseline's metrics: the baseline's own
+// directory is overwritten when the same scenario and seed run again
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/compat"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/provenance"
+)
+
+// File is the manifest's name within the runs directory
+const File = "manifest.json"
+
+// Manifest is the runs directory's record
+type Manifest struct {
+	Baseline *Baseline `json:"baseline,omitempty"`
+}
+
+// Baseline is the reference run and its metrics as they were when marked
+type Baseline struct {
+	RunDir     string                            `json:"run_dir"`
+	Scenario   string                            `json:"scenario"`
+	Seed       int64                             `json:"seed"`
+	ConfigHash string                            `json:"config_hash,omitempty"`
+	LogHash    string                            `json:"log_hash,omitempty"`
+	Metrics    map[string]*metrics.TraderMetrics `json:"metrics"` // "fast" and "slow"
+}
+
+// Fast returns the baseline's fast-trader metrics
+func (b *Baseline) Fast() *metrics.TraderMetrics { return b.Metrics["fast"] }
+
+// Slow returns the baseline's slow-trader metrics
+func (b *Baseline) Slow() *metrics.TraderMetrics { return b.Metrics["slow"] }
+
+// Load reads dir's manifest. A missing manifest is an empty one
+func Load(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, File))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Manifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", File, err)
+	}
+	return &m, nil
+}
+
+// Save writes the manifest into dir
+func (m *Manifest) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(m, "", "  ")
+	return os.WriteFile(filepath.Join(dir, File), data, 0644)
+}
+
+// Mark reads the finished run in runDir as a baseline: its metrics.json,
+// written with its report, and the scenario and seed of its config.json.
+// Runs stored by earlier versions are read through compat
+func Mark(runDir string) (*Baseline, error) {
+	saved, err := compat.ReadMetrics(runDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("baseline needs a run with metrics: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if saved["fast"] == nil || saved["slow"] == nil {
+		return nil, fmt.Errorf("%s: metrics.json lacks the fast or slow trader", runDir)
+	}
+	cfg, err := compat.ReadConfig(runDir)
+	if err != nil {
+		return nil, err
+	}
+	b := &Baseline{
+		RunDir: runDir, Scenario: cfg.Name, Seed: cfg.Seed,
+		Metrics: saved,
+	}
+	// Runs from before provenance have no stamp
+	if stamp, err := provenance.Read(runDir); err == nil {
+		b.ConfigHash, b.LogHash = stamp.ConfigHash, stamp.LogHash
+	}
+	return b, nil
+}
@@ -0,0 +1,44 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMarkAndReload marks a run as baseline and reads it back from the
+// manifest, which keeps its metrics after the run directory is gone
+func TestMarkAndReload(t *testing.T) {
+	runs := t.TempDir()
+	if m, err := Load(runs); err != nil || m.Baseline != nil {
+		t.Fatalf("missing manifest: %+v, %v", m, err)
+	}
+
+	runDir := filepath.Join(runs, "calm_seed42")
+	os.MkdirAll(runDir, 0755)
+	os.WriteFile(filepath.Join(runDir, "metrics.json"), []byte(`{"fast": {"trader_id": "fast", "fill_rate": 0.5}, "slow": {"trader_id": "slow", "fill_rate": 0.25}}`), 0644)
+	os.WriteFile(filepath.Join(runDir, "config.json"), []byte(`{"name": "calm", "seed": 42}`), 0644)
+	b, err := Mark(runDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Manifest{Baseline: b}).Save(runs); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(runDir)
+
+	m, err := Load(runs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := m.Baseline
+	if got == nil || got.Scenario != "calm" || got.Seed != 42 || got.RunDir != runDir {
+		t.Fatalf("baseline = %+v", got)
+	}
+	if got.Fast().FillRate != 0.5 || got.Slow().FillRate != 0.25 {
+		t.Errorf("fill rates %v, %v", got.Fast().FillRate, got.Slow().FillRate)
+	}
+	if _, err := Mark(runDir); err == nil {
+		t.Error("marked a run with no metrics")
+	}
+}
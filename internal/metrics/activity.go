@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+)
+
+// Activity is a trader's traffic over the run in fixed buckets of event
+// time: orders and cancels counted when the venue accepted them, fills
+// when they traded. Counting at the venue rather than at the decision
+// shows where a slow trader's latency pushes its activity, e.g. past a
+// burst into the recovery after it. Every trader's counts in a run cover
+// the same buckets, up to its last event, so they line up
+
+// ActivityBucketNs is the width of an activity bucket
+const ActivityBucketNs = int64(100_000_000)
+
+// Activity is a trader's orders, cancels and fills per bucket, and the
+// run's bursts to line them up with
+type Activity struct {
+	BucketNs int64 `json:"bucket_ns"`
+	Orders   []int `json:"orders"`  // limit and market orders
+	Cancels  []int `json:"cancels"` // full and partial
+	Fills    []int `json:"fills"`
+
+	// Bursts are the run's burst spans, by start; EndNs is exclusive
+	Bursts []Window `json:"bursts,omitempty"`
+}
+
+// InBurst reports whether bucket i overlaps a burst
+func (a *Activity) InBurst(i int) bool {
+	lo, hi := int64(i)*a.BucketNs, int64(i+1)*a.BucketNs
+	for _, b := range a.Bursts {
+		if b.StartNs < hi && b.EndNs > lo {
+			return true
+		}
+	}
+	return false
+}
+
+// addActivity buckets a trader's accepted messages and fills over the
+// run's span
+func (m *TraderMetrics) addActivity(c *Collector, a *traderAccum) {
+	n := int(max((c.lastEvent+ActivityBucketNs-1)/ActivityBucketNs, 1))
+	act := &Activity{BucketNs: ActivityBucketNs, Orders: make([]int, n), Cancels: make([]int, n), Fills: make([]int, n)}
+	bucket := func(ns int64) int {
+		return min(int(ns/ActivityBucketNs), n-1)
+	}
+	for _, ns := range a.acceptedOrders {
+		act.Orders[bucket(ns)]++
+	}
+	for _, ns := range a.acceptedCancels {
+		act.Cancels[bucket(ns)]++
+	}
+	for _, f := range a.fills {
+		act.Fills[bucket(f.fillTime)]++
+	}
+	for id, s := range detorder.All(c.bursts) {
+		act.Bursts = append(act.Bursts, Window{Name: id, StartNs: s.start, EndNs: s.end + 1})
+	}
+	slices.SortStableFunc(act.Bursts, func(x, y Window) int {
+		return cmp.Compare(x.StartNs, y.StartNs)
+	})
+	m.Activity = act
+}
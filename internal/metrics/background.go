@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+)
+
+// BackgroundMetrics is one background trader's activity. With a
+// configured population each of its traders gets one; otherwise there is
+// a single "background"
+type BackgroundMetrics struct {
+	TraderID      string `json:"trader_id"`
+	LimitOrders   int    `json:"limit_orders"`
+	MarketOrders  int    `json:"market_orders"`
+	Cancels       int    `json:"cancels"`
+	Fills         int    `json:"fills"`
+	PassiveQty    int64  `json:"passive_qty"`
+	AggressiveQty int64  `json:"aggressive_qty"`
+	Position      int64  `json:"position"` // bought minus sold
+
+	// QtyVs is the qty filled against each non-background trader
+	QtyVs map[string]int64 `json:"qty_vs,omitempty"`
+}
+
+// Counterparty is a trader's fills against one other trader, with the
+// adverse selection of those fills alone at the first markout horizon
+type Counterparty struct {
+	TraderID            string  `json:"trader_id"`
+	Fills               int     `json:"fills"`
+	Qty                 int64   `json:"qty"`
+	AdverseSelectionBps float64 `json:"adverse_selection_bps"`
+}
+
+func (c *Collector) backgroundAccum(traderID string) *BackgroundMetrics {
+	b := c.background[traderID]
+	if b == nil {
+		b = &BackgroundMetrics{TraderID: traderID}
+		c.background[traderID] = b
+	}
+	return b
+}
+
+func (c *Collector) backgroundOrder(order *domain.Order) {
+	b := c.backgroundAccum(order.TraderID)
+	switch order.Type {
+	case domain.LimitOrder:
+		b.LimitOrders++
+	case domain.MarketOrder:
+		b.MarketOrders++
+	case domain.CancelOrder:
+		b.Cancels++
+	}
+}
+
+// backgroundTrade books a trade's background side or sides
+func (c *Collector) backgroundTrade(trade *domain.Trade) {
+	for _, side := range [2]struct {
+		trader, other string
+		order         uint64
+		sign          int64
+	}{
+		{trade.BuyTrader, trade.SellTrader, trade.BuyOrderID, 1},
+		{trade.SellTrader, trade.BuyTrader, trade.SellOrderID, -1},
+	} {
+		if !domain.IsBackground(side.trader) {
+			continue
+		}
+		b := c.backgroundAccum(side.trader)
+		b.Fills++
+		b.Position += side.sign * trade.Qty
+		if trade.PassiveOrderID > 0 && side.order == trade.PassiveOrderID {
+			b.PassiveQty += trade.Qty
+		} else {
+			b.AggressiveQty += trade.Qty
+		}
+		if !domain.IsBackground(side.other) {
+			if b.QtyVs == nil {
+				b.QtyVs = make(map[string]int64)
+			}
+			b.QtyVs[side.other] += trade.Qty
+		}
+	}
+}
+
+// Background returns the background traders' activity, by trader ID
+func (c *Collector) Background() []BackgroundMetrics {
+	out := make([]BackgroundMetrics, 0, len(c.background))
+	for _, b := range detorder.All(c.background) {
+		out = append(out, *b)
+	}
+	return out
+}
+
+// addCounterparties splits a trader's fills by counterparty. Adverse
+// selection is measured as for the trader's headline figure: the mean
+// move against each fill horizonNs later, in bps of the opening mid
+func (m *TraderMetrics) addCounterparties(c *Collector, fills []fillInfo, horizonNs int64, scale domain.Scale) {
+	byID := make(map[string]*Counterparty)
+	moves := make(map[string]float64)
+	for _, f := range fills {
+		cp := byID[f.counterparty]
+		if cp == nil {
+			cp = &Counterparty{TraderID: f.counterparty}
+			byID[f.counterparty] = cp
+		}
+		cp.Fills++
+		cp.Qty += f.fillQty
+		if after := c.priceAfterDuration(f.fillTime, horizonNs); after > 0 && f.tradePrice > 0 {
+			move := scale.ToFloat(after) - scale.ToFloat(f.tradePrice)
+			if f.side == domain.Sell {
+				move = -move
+			}
+			moves[f.counterparty] += move
+		}
+	}
+	open := scale.ToFloat(c.midAtTime(0))
+	for id, cp := range detorder.All(byID) {
+		if open > 0 {
+			cp.AdverseSelectionBps = moves[id] / float64(cp.Fills) / open * 10_000
+		}
+		m.Counterparties = append(m.Counterparties, *cp)
+	}
+}
+
+// BackgroundFromLog reads an event log and returns the background
+// traders' activity
+func BackgroundFromLog(logPath string) ([]BackgroundMetrics, error) {
+	reader, err := eventlog.NewReader(logPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	if err := reader.Require(eventlog.LevelOrders, "background metrics"); err != nil {
+		return nil, err
+	}
+
+	c := NewCollector()
+	err = reader.Each(func(event *domain.Event) error {
+		switch event.Type {
+		case domain.EventOrderAccepted:
+			if o := event.Order(); o != nil && domain.IsBackground(o.TraderID) {
+				c.backgroundOrder(o)
+			}
+		case domain.EventTradeExecuted:
+			if tr := event.Trade(); tr != nil {
+				c.backgroundTrade(tr)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.Background(), nil
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import "github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+
+// Built-in plugins, which double as examples of the Metric interface:
+// max_position is the largest position each trader held, long or short,
+// in shares, and max_drawdown the deepest fall of its realized PnL net
+// of fees from an earlier peak, in the instrument's currency. Both read
+// ACCOUNT_UPDATE records only
+func init() {
+	Register("max_position", func(domain.Scale) Metric { return make(maxPosition) })
+	Register("max_drawdown", func(scale domain.Scale) Metric {
+		return &maxDrawdown{scale: scale, peak: make(map[string]int64), worst: make(map[string]int64)}
+	})
+}
+
+// maxPosition is the max_position plugin
+type maxPosition map[string]int64
+
+func (m maxPosition) ProcessEvent(e *domain.Event) {
+	if e.Type != domain.EventAccountUpdate {
+		return
+	}
+	if acct := e.Account(); acct != nil && !domain.IsBackground(acct.TraderID) {
+		m[acct.TraderID] = max(m[acct.TraderID], acct.Position, -acct.Position)
+	}
+}
+
+func (m maxPosition) Finalize() map[string]float64 {
+	out := make(map[string]float64, len(m))
+	for id, pos := range m {
+		out[id] = float64(pos)
+	}
+	return out
+}
+
+// maxDrawdown is the max_drawdown plugin
+type maxDrawdown struct {
+	scale domain.Scale
+	peak  map[string]int64 // best realized PnL net of fees so far
+	worst map[string]int64 // deepest drop from peak so far
+}
+
+func (m *maxDrawdown) ProcessEvent(e *domain.Event) {
+	if e.Type != domain.EventAccountUpdate {
+		return
+	}
+	acct := e.Account()
+	if acct == nil || domain.IsBackground(acct.TraderID) {
+		return
+	}
+	id, net := acct.TraderID, acct.RealizedPnL-acct.Fees
+	m.peak[id] = max(m.peak[id], net)
+	m.worst[id] = max(m.worst[id], m.peak[id]-net)
+}
+
+func (m *maxDrawdown) Finalize() map[string]float64 {
+	out := make(map[string]float64, len(m.worst))
+	for id, dd := range m.worst {
+		out[id] = m.scale.ToFloat(dd)
+	}
+	return out
+}
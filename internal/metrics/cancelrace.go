@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"sort"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// A cancel races the contra flow heading for its target. Between the
+// decision and the cancel landing, the target can still be hit: a stale
+// quote picked off. A cancel is in a race when contra liquidity reached
+// its target's price within the race window of the decision, and the
+// outcome is one of:
+//   - won: the cancel landed with nothing filled since the decision, and
+//     contra flow traded at or through the target's price in the window
+//   - lost: the target filled in full before the cancel landed
+//   - partial: part of the target filled first, and the cancel took the rest
+//
+// Fills after the window and cancels of a target already done when the
+// trader decided are not races. Partial cancels (reductions) don't count
+
+// DefaultCancelRaceWindowNs is the race window when none is configured
+const DefaultCancelRaceWindowNs = int64(100_000_000)
+
+// Cancel race outcomes
+const (
+	cancelRaceNone = iota
+	cancelRaceWon
+	cancelRaceLost
+	cancelRacePartial
+)
+
+// raceTarget is a trader limit order that a cancel may race for
+type raceTarget struct {
+	side     domain.Side
+	price    int64
+	qty      int64
+	canceled int // log position of its cancellation, 0 while live
+	fills    []targetFill
+}
+
+type targetFill struct {
+	ns  int64
+	qty int64
+	seq int // log position
+}
+
+// pendingCancel is a full cancel as it reached the venue
+type pendingCancel struct {
+	trader   string
+	target   uint64
+	decision int64
+	seq      int // log position of its arrival
+}
+
+// cancelRaceTracker follows trader limit orders, their fills and the
+// cancels sent against them, and settles the races once the trades
+// around each cancel are known
+type cancelRaceTracker struct {
+	seq     int
+	targets map[uint64]*raceTarget
+	cancels []pendingCancel
+}
+
+func newCancelRaceTracker() *cancelRaceTracker {
+	return &cancelRaceTracker{targets: make(map[uint64]*raceTarget)}
+}
+
+func (t *cancelRaceTracker) process(e *domain.Event) {
+	t.seq++
+	switch e.Type {
+	case domain.EventOrderAccepted:
+		o := e.Order()
+		if o == nil || domain.IsBackground(o.TraderID) {
+			return
+		}
+		switch {
+		case o.Type == domain.LimitOrder:
+			t.targets[o.ID] = &raceTarget{side: o.Side, price: o.Price, qty: o.Qty}
+		case o.Type == domain.CancelOrder && o.Qty == 0:
+			t.cancels = append(t.cancels, pendingCancel{o.TraderID, o.CancelID, o.DecisionTime, t.seq})
+		}
+	case domain.EventOrderCanceled:
+		if c := e.Cancel(); c != nil && c.RemainingQty == 0 {
+			if target := t.targets[c.CancelID]; target != nil && target.canceled == 0 {
+				target.canceled = t.seq
+			}
+		}
+	case domain.EventTradeExecuted:
+		if tr := e.Trade(); tr != nil && tr.PassiveOrderID > 0 {
+			if target := t.targets[tr.PassiveOrderID]; target != nil {
+				target.fills = append(target.fills, targetFill{tr.Timestamp, tr.Qty, t.seq})
+			}
+		}
+	}
+}
+
+// outcome settles one cancel's race against the run's trades, which are
+// in time order
+func (t *cancelRaceTracker) outcome(c pendingCancel, window int64, trades []tradeRecord) (int, int64) {
+	target := t.targets[c.target]
+	if target == nil || target.canceled > 0 && target.canceled < c.seq {
+		return cancelRaceNone, 0
+	}
+	var filled, raced int64
+	for _, f := range target.fills {
+		if f.seq > c.seq {
+			break
+		}
+		filled += f.qty
+		if f.ns >= c.decision && f.ns <= c.decision+window {
+			raced += f.qty
+		}
+	}
+	switch {
+	case raced > 0 && filled >= target.qty:
+		return cancelRaceLost, raced
+	case raced > 0:
+		return cancelRacePartial, raced
+	case filled >= target.qty:
+		return cancelRaceNone, 0 // done before the trader decided
+	}
+	// Nothing filled: did contra flow reach the price in the window?
+	i := sort.Search(len(trades), func(i int) bool { return trades[i].timestamp >= c.decision })
+	for ; i < len(trades) && trades[i].timestamp <= c.decision+window; i++ {
+		tr := trades[i]
+		if target.side == domain.Buy && tr.sign < 0 && tr.price <= target.price ||
+			target.side == domain.Sell && tr.sign > 0 && tr.price >= target.price {
+			return cancelRaceWon, 0
+		}
+	}
+	return cancelRaceNone, 0
+}
+
+// addCancelRaces settles the trader's cancel races, leaving out those
+// decided when skip says so
+func (m *TraderMetrics) addCancelRaces(t *cancelRaceTracker, window int64, trades []tradeRecord, skip func(int64) bool) {
+	for _, c := range t.cancels {
+		if c.trader != m.TraderID || skip(c.decision) {
+			continue
+		}
+		outcome, qty := t.outcome(c, window, trades)
+		switch outcome {
+		case cancelRaceNone:
+			continue
+		case cancelRaceWon:
+			m.CancelRacesWon++
+		case cancelRaceLost:
+			m.CancelRacesLost++
+		case cancelRacePartial:
+			m.CancelRacesPartial++
+		}
+		m.CancelRaces++
+		m.PickedOffQty += qty
+	}
+	if m.CancelRaces > 0 {
+		m.CancelRaceWinRate = float64(m.CancelRacesWon) / float64(m.CancelRaces)
+	}
+}
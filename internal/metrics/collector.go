@@ -3,11 +3,15 @@
 package metrics
 
 import (
-	"io"
+	"math"
 	"sort"
+	"strconv"
 
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/lifecycle"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/quantile"
 )
 
 // TraderMetrics holds computed metrics for a single trader
@@ -20,40 +24,281 @@ type TraderMetrics struct {
 	MarketOrders int `json:"market_orders"`
 	CancelsSent  int `json:"cancels_sent"`
 
+	// Messaging efficiency (see messaging.go): messages sent, OrdersSent
+	// counting cancels and reductions, per fill and per filled share; and
+	// in the run's bursts, if it has any, messages and fills, and their
+	// ratio
+	MessagesPerTrade      float64 `json:"messages_per_trade"`
+	MessagesPerShare      float64 `json:"messages_per_share"`
+	BurstMessages         int     `json:"burst_messages,omitempty"`
+	BurstFills            int     `json:"burst_fills,omitempty"`
+	BurstMessagesPerTrade float64 `json:"burst_messages_per_trade,omitempty"`
+
 	// Fill metrics
 	TotalFills     int     `json:"total_fills"`
 	TotalQtyFilled int64   `json:"total_qty_filled"`
-	FillRate       float64 `json:"fill_rate"` // filled executable orders / executable orders
+	FillRate       float64 `json:"fill_rate"` // filled executable orders / executable orders, less any CancelAtClose cuts off
 
-	// Missed fill tracking
+	// Lifecycle outcomes, from the order state machine
 	CanceledBeforeFill int `json:"canceled_before_fill"` // orders canceled without any fill
+	LateCancels        int `json:"late_cancels"`         // cancels rejected because a fill got there first
+	DuplicateCancels   int `json:"duplicate_cancels"`    // cancels of an order already canceled
+	RefusedCancels     int `json:"refused_cancels"`      // cancels of another trader's order, or naming the wrong side or price
+	ExpiredOrders      int `json:"expired_orders"`       // orders still resting at the close
+
+	// IOC and FOK limit orders, and those the venue canceled the unfilled
+	// remainder of on arrival, with the qty it canceled
+	ImmediateOrders int   `json:"immediate_orders,omitempty"`
+	KilledOrders    int   `json:"killed_orders,omitempty"`
+	KilledQty       int64 `json:"killed_qty,omitempty"`
+
+	// Iceberg orders, the new slices their fills brought out, and the
+	// mean queue position a new slice took at the back of its level
+	IcebergOrders    int     `json:"iceberg_orders,omitempty"`
+	IcebergRefreshes int     `json:"iceberg_refreshes,omitempty"`
+	RefreshQueuePos  float64 `json:"refresh_queue_pos,omitempty"`
+
+	// Orders still resting at the close (see resting.go): the qty they
+	// left open, how many had no fill, how long they had rested, and how
+	// far they sat behind the mid on average, in price units and bps
+	RestingQty          int64         `json:"resting_qty"`
+	RestingUnfilled     int           `json:"resting_unfilled"`
+	RestingAge          *LatencyStats `json:"resting_age,omitempty"`
+	RestingBehindMid    float64       `json:"resting_behind_mid"`
+	RestingBehindMidBps float64       `json:"resting_behind_mid_bps"`
+
+	// Fill-versus-cancel races (see cancelrace.go): full cancels whose
+	// target met contra flow within the race window of the decision, by
+	// who got there first, and the qty filled while cancels were in flight
+	CancelRaces        int     `json:"cancel_races"`
+	CancelRacesWon     int     `json:"cancel_races_won"`
+	CancelRacesLost    int     `json:"cancel_races_lost"`
+	CancelRacesPartial int     `json:"cancel_races_partial"`
+	CancelRaceWinRate  float64 `json:"cancel_race_win_rate"`
+	PickedOffQty       int64   `json:"picked_off_qty"`
+
+	// Partial cancels that left their target resting, and the qty they
+	// took off it
+	PartialCancels int   `json:"partial_cancels,omitempty"`
+	ReducedQty     int64 `json:"reduced_qty,omitempty"`
+
+	// Priority inversions, counted per pair of orders (see priority.go).
+	// Always zero under price-time priority
+	PriorityInversions int            `json:"priority_inversions,omitempty"`    // times this trader's orders were jumped
+	QueueJumps         int            `json:"queue_jumps,omitempty"`            // times this trader's orders jumped another's
+	JumpedBy           map[string]int `json:"priority_inversions_by,omitempty"` // inversions by the jumping trader
+
+	// Account at the close, from ACCOUNT_UPDATE records; price units
+	FinalPosition int64   `json:"final_position"`
+	RealizedPnL   float64 `json:"realized_pnl"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"` // open position marked at the last mid
+	Fees          float64 `json:"fees"`
+	NetPnL        float64 `json:"net_pnl"` // realized + unrealized - fees
 
 	// Price metrics
 	AvgExecPrice float64 `json:"avg_exec_price"`
-	AvgSlippage  float64 `json:"avg_slippage"` // vs mid at decision time
+	AvgSlippage  float64 `json:"avg_slippage"` // vs the benchmark price
 	SlippageBps  float64 `json:"slippage_bps"` // in basis points
 
+	// SlippageBenchmark is the price slippage was measured against
+	SlippageBenchmark string `json:"slippage_benchmark"`
+
+	// Liquidity mix: fills that rested on the book (passive, maker) vs
+	// fills that took liquidity (aggressive, taker); see spread.go
+	PassiveFills    int     `json:"passive_fills"`
+	AggressiveFills int     `json:"aggressive_fills"`
+	PassiveQty      int64   `json:"passive_qty"`
+	AggressiveQty   int64   `json:"aggressive_qty"`
+	PassiveShare    float64 `json:"passive_share"` // passive qty / filled qty
+
+	// Spread cost against the mid just before each trade, in price units
+	// times qty: paid by aggressive fills, captured by passive ones
+	SpreadPaid     float64 `json:"spread_paid"`
+	SpreadCaptured float64 `json:"spread_captured"`
+	NetSpread      float64 `json:"net_spread"`     // captured - paid
+	NetSpreadBps   float64 `json:"net_spread_bps"` // per filled share, bps of the mid
+
+	// Flow toxicity at this trader's fills (see toxicity.go): the
+	// qty-weighted VPIN of the intervals they fell in, the share of
+	// filled qty in the run's most toxic third of volume, and that share
+	// over the market's; above 1 the trader was filled disproportionately
+	// when flow was toxic
+	AvgFillToxicity float64 `json:"avg_fill_toxicity"`
+	ToxicFillShare  float64 `json:"toxic_fill_share"`
+	ToxicFillRatio  float64 `json:"toxic_fill_ratio"`
+
 	// Time metrics
-	AvgTimeToFillNs float64   `json:"avg_time_to_fill_ns"` // average time-to-fill in ms (legacy field name)
+	AvgTimeToFillMs float64   `json:"avg_time_to_fill_ms"` // average time-to-fill in ms
 	TimeToFillDist  []float64 `json:"time_to_fill_dist"`   // all time-to-fill values in ms
 
+	// TimeToFillSketch stands in for TimeToFillDist when the percentile
+	// engine summarized time-to-fill approximately (see Options); use
+	// TimeToFill rather than either
+	TimeToFillSketch *quantile.Sketch `json:"time_to_fill_sketch,omitempty"`
+
+	// Time-to-fill split at the order's arrival, per fill, in ns: decision
+	// to arrival is the trader's latency, arrival to fill is time at the
+	// venue, mostly spent queuing
+	TTFToArrival *LatencyStats `json:"ttf_decision_to_arrival,omitempty"`
+	TTFAtVenue   *LatencyStats `json:"ttf_arrival_to_fill,omitempty"`
+
 	// Queue position metrics
 	AvgQueuePosPlace float64 `json:"avg_queue_pos_place"` // at placement
 	AvgQueuePosFill  float64 `json:"avg_queue_pos_fill"`  // at fill
 
-	// Adverse selection
+	// Queue position percentiles at placement, over limit orders that
+	// rested, and at passive fills (see queue.go)
+	QueuePosPlace *QueueDist `json:"queue_pos_place,omitempty"`
+	QueuePosFill  *QueueDist `json:"queue_pos_fill,omitempty"`
+
+	// Realized queue progression of passive fills, from the trades: qty
+	// ahead when the order went on the book and when it filled, and the
+	// share of the former that cleared in between (see queue.go)
+	AvgAheadQtyPlace float64 `json:"avg_ahead_qty_place"`
+	AvgAheadQtyFill  float64 `json:"avg_ahead_qty_fill"`
+	QueueProgress    float64 `json:"queue_progress"`
+
+	// Adverse selection at the first markout horizon
 	AvgPriceMoveAfterFill float64 `json:"avg_price_move_after_fill"` // in price units
 	AdverseSelectionBps   float64 `json:"adverse_selection_bps"`
 
+	// Markouts are adverse selection at every configured horizon
+	Markouts []Markout `json:"markouts"`
+
+	// Counterparties split the fills by the trader on the other side,
+	// e.g. a background population's makers and takers (see background.go)
+	Counterparties []Counterparty `json:"counterparties,omitempty"`
+
+	// RealizedLatency is arrival minus decision time by message type:
+	// limit, market and cancel (see latency.go). Time in the order entry
+	// gateway and warming the connection is left out; Gateway and
+	// ColdMessages cover them
+	RealizedLatency map[string]*LatencyStats `json:"realized_latency,omitempty"`
+
+	// Gateway is the time messages spent in the trader's order entry
+	// gateway, waiting plus sending, when one is configured; GatewayQueued
+	// counts the messages that waited behind an earlier one
+	Gateway       *LatencyStats `json:"gateway,omitempty"`
+	GatewayQueued int           `json:"gateway_queued,omitempty"`
+
+	// ColdMessages counts the messages that paid a connection handshake or
+	// idle warm-up, and WarmupNs is the total time it added
+	ColdMessages int   `json:"cold_messages,omitempty"`
+	WarmupNs     int64 `json:"warmup_ns,omitempty"`
+
+	// Signal races (see races.go). Won and lost count contested races
+	// only; the cost gap averages lost races where both sides filled
+	RacesEntered     int         `json:"races_entered"`
+	RacesWon         int         `json:"races_won"`
+	RacesLost        int         `json:"races_lost"`
+	RaceWinRate      float64     `json:"race_win_rate"`
+	RacesMissed      int         `json:"races_missed"`       // lost and filled nothing while the winner filled
+	RacesQueueBehind int         `json:"races_queue_behind"` // lost and rested behind the winner
+	RaceCostGapBps   float64     `json:"race_cost_gap_bps"`
+	Races            []RaceEntry `json:"races,omitempty"`
+
 	// Raw data for plotting
 	SlippageValues []float64 `json:"slippage_values,omitempty"`
+
+	// Activity is the trader's orders, cancels and fills per bucket of
+	// the run (see activity.go)
+	Activity *Activity `json:"activity,omitempty"`
+
+	// Re-quoting (see requote.go): cancel-replace links and the chains
+	// they form, the round trip from each cancel's decision to its
+	// replacement's arrival, and the share of the run spent with a
+	// resting order at the best bid or ask
+	Requotes            int           `json:"requotes"`
+	RequoteChains       int           `json:"requote_chains"`
+	LongestRequoteChain int           `json:"longest_requote_chain"`
+	RequoteRTT          *LatencyStats `json:"requote_rtt,omitempty"`
+	TimeAtInside        float64       `json:"time_at_inside"`
+
+	// Custom are the values of the custom metric plugins the options
+	// turned on (see plugin.go), by plugin name
+	Custom map[string]float64 `json:"custom,omitempty"`
+}
+
+// TimeToFill is the trader's time-to-fill distribution in ms, exact or
+// sketched as it was computed
+func (m *TraderMetrics) TimeToFill() quantile.Estimator {
+	if m.TimeToFillSketch != nil {
+		return m.TimeToFillSketch
+	}
+	return quantile.Sorted(m.TimeToFillDist)
+}
+
+// addRaces records a trader's race entries and sums them up
+func (m *TraderMetrics) addRaces(entries []RaceEntry) {
+	m.Races = entries
+	m.RacesEntered = len(entries)
+	var gap float64
+	var gaps int
+	for _, e := range entries {
+		switch {
+		case e.Won():
+			m.RacesWon++
+		case e.Rank > 1:
+			m.RacesLost++
+			if e.Missed {
+				m.RacesMissed++
+			}
+			if e.QueueBehind {
+				m.RacesQueueBehind++
+			}
+			if e.CostGapBps != nil {
+				gap += *e.CostGapBps
+				gaps++
+			}
+		}
+	}
+	if m.RacesWon+m.RacesLost > 0 {
+		m.RaceWinRate = float64(m.RacesWon) / float64(m.RacesWon+m.RacesLost)
+	}
+	if gaps > 0 {
+		m.RaceCostGapBps = gap / float64(gaps)
+	}
+}
+
+// Markout is adverse selection at one horizon: how far the mid moved
+// against the trader's fills that long after them
+type Markout struct {
+	HorizonNs int64   `json:"horizon_ns"`
+	AvgMove   float64 `json:"avg_move"` // in price units
+	Bps       float64 `json:"bps"`
 }
 
 // Collector accumulates metrics from events
 type Collector struct {
 	traderMetrics map[string]*traderAccum
+	lifecycle     *lifecycle.Tracker
 	bboHistory    []bboSnapshot
 	tradeHistory  []tradeRecord
+	annotations   map[string]map[string]int   // key -> value -> events
+	watchers      map[string][]AnnotationFunc // by key
+	priority      *priorityTracker
+	races         *raceTracker
+	cancelRaces   *cancelRaceTracker
+	quotes        *quoteTracker
+	background    map[string]*BackgroundMetrics
+	bursts        map[string]*span // by burst_id
+	lastEvent     int64            // the latest event time seen
+
+	// Custom metric plugins (see plugin.go), started on the first event
+	plugins        []runningPlugin
+	pluginsStarted bool
+
+	// Scale converts prices to floats; zero means domain.DefaultScale
+	Scale domain.Scale
+
+	// Options choose the slippage benchmark and markout horizons; set
+	// them before the first event
+	Options Options
+
+	// Exclusion windows (see windows.go): the trader orders left out, and
+	// whether the collector measures only the windows instead
+	masked       map[uint64]bool
+	onlyExcluded bool
 }
 
 type traderAccum struct {
@@ -62,6 +307,11 @@ type traderAccum struct {
 	limitOrders  int
 	marketOrders int
 	cancelsSent  int
+	sent         []int64 // decision times of every message
+
+	// When the venue accepted each order and cancel, for activity
+	acceptedOrders  []int64
+	acceptedCancels []int64
 
 	// Track orders for time-to-fill
 	orderTimes map[uint64]orderInfo // orderID -> info
@@ -69,10 +319,34 @@ type traderAccum struct {
 	// Track which orders have received fills
 	filledOrders map[uint64]bool // orderID -> filled
 
-	// Track cancel targets
-	cancelTargets []uint64 // orderIDs that were canceled
+	// Lifecycle outcomes
+	canceledBeforeFill int
+	lateCancels        int
+	duplicateCancels   int
+	refusedCancels     int
+	expiredOrders      int
+	partialCancels     int
+	reducedQty         int64
+	immediateOrders    int
+	killedOrders       int
+	killedQty          int64
+	icebergOrders      int
+	refreshes          int
+	refreshPosSum      int64
+
+	// Latest logged account
+	account domain.Account
 
-	fills []fillInfo
+	// Realized latency samples by message type, gateway times and
+	// connection warm-ups
+	latencies     map[domain.OrderType]quantile.Estimator
+	gateway       quantile.Estimator
+	gatewayQueued int
+	coldMessages  int
+	warmupNs      int64
+
+	fills   []fillInfo
+	resting []restingOrder // orders the close expired
 }
 
 type orderInfo struct {
@@ -80,18 +354,28 @@ type orderInfo struct {
 	arrivalTime   int64
 	side          domain.Side
 	price         int64
-	midAtDecision int64
-	queuePosPlace int // queue position at placement
+	qty           int64 // less any partial cancels
+	benchmark     int64 // slippage reference; 0 for VWAP, known only at the end
+	queuePosPlace int   // queue position at placement
 }
 
 type fillInfo struct {
-	tradePrice    int64
-	fillQty       int64
-	decisionTime  int64
-	fillTime      int64
-	midAtDecision int64
-	queuePosFill  int
-	side          domain.Side
+	tradePrice   int64
+	fillQty      int64
+	decisionTime int64
+	arrivalTime  int64
+	fillTime     int64
+	benchmark    int64
+	queuePosFill int
+	side         domain.Side
+	passive      bool
+	trade        int    // index in tradeHistory
+	midBefore    int64  // last two-sided mid before the trade; 0 if none yet
+	counterparty string // the trader on the other side
+
+	// Passive fills only: qty ahead at placement and at the fill
+	placedAheadQty int64
+	aheadQty       int64
 }
 
 type bboSnapshot struct {
@@ -102,13 +386,23 @@ type bboSnapshot struct {
 type tradeRecord struct {
 	timestamp int64
 	price     int64
+	qty       int64
+	sign      int8 // +1 buyer-initiated, -1 seller-initiated, 0 unknown
 }
 
 // NewCollector creates a new metrics collector
 func NewCollector() *Collector {
-	return &Collector{
+	c := &Collector{
 		traderMetrics: make(map[string]*traderAccum),
+		lifecycle:     lifecycle.New(),
+		priority:      newPriorityTracker(),
+		races:         newRaceTracker(),
+		cancelRaces:   newCancelRaceTracker(),
+		masked:        make(map[uint64]bool),
+		background:    make(map[string]*BackgroundMetrics),
 	}
+	c.quotes = newQuoteTracker(func() quantile.Estimator { return c.Options.estimator() })
+	return c
 }
 
 func (c *Collector) getAccum(traderID string) *traderAccum {
@@ -124,85 +418,283 @@ func (c *Collector) getAccum(traderID string) *traderAccum {
 	return a
 }
 
+// AnnotationFunc receives each event tagged with a watched key
+type AnnotationFunc func(e *domain.Event, value string)
+
+// Watch calls fn for every later event annotated with key, so an
+// experiment can compute its own metric from tags without a new field.
+// fn must not keep e, which may be recycled
+func (c *Collector) Watch(key string, fn AnnotationFunc) {
+	if c.watchers == nil {
+		c.watchers = make(map[string][]AnnotationFunc)
+	}
+	c.watchers[key] = append(c.watchers[key], fn)
+}
+
+// Annotations counts the events seen under each annotation key and value
+func (c *Collector) Annotations() map[string]map[string]int {
+	return c.annotations
+}
+
+// Annotated returns the number of events seen with key set to value
+func (c *Collector) Annotated(key, value string) int {
+	return c.annotations[key][value]
+}
+
+func (c *Collector) processMeta(event *domain.Event) {
+	if c.annotations == nil {
+		c.annotations = make(map[string]map[string]int)
+	}
+	for k, v := range detorder.All(event.Meta) {
+		if c.annotations[k] == nil {
+			c.annotations[k] = make(map[string]int)
+		}
+		c.annotations[k][v]++
+		for _, fn := range c.watchers[k] {
+			fn(event, v)
+		}
+	}
+}
+
 // ProcessEvent ingests a single event
 func (c *Collector) ProcessEvent(event *domain.Event) {
+	if !c.pluginsStarted {
+		c.startPlugins(c.Scale)
+	}
+	for _, p := range c.plugins {
+		p.metric.ProcessEvent(event)
+	}
+	c.lastEvent = max(c.lastEvent, event.Timestamp)
+	if len(event.Meta) > 0 {
+		c.processMeta(event)
+		c.processBurst(event)
+	}
+	if c.Options.Computes(GroupPriority) {
+		c.priority.process(event)
+	}
+	if c.Options.Computes(GroupRaces) {
+		c.races.process(event)
+	}
+	if c.Options.Computes(GroupCancelRaces) {
+		c.cancelRaces.process(event)
+	}
 	switch event.Type {
 	case domain.EventOrderAccepted:
-		if event.Order != nil {
+		if event.Order() != nil {
 			c.processOrder(event)
 		}
+	case domain.EventOrderCanceled:
+		if cancel := event.Cancel(); cancel != nil && cancel.RemainingQty > 0 {
+			c.processReduce(cancel)
+		}
 	case domain.EventTradeExecuted:
-		if event.Trade != nil {
+		if event.Trade() != nil {
 			c.processTrade(event)
 		}
-	case domain.EventOrderCanceled:
-		if event.Order != nil {
-			c.processCancel(event)
+	case domain.EventIcebergRefresh:
+		if o := event.Order(); o != nil && !domain.IsBackground(o.TraderID) && !c.masked[o.ID] {
+			a := c.getAccum(o.TraderID)
+			a.refreshes++
+			a.refreshPosSum += int64(o.QueuePos)
 		}
 	case domain.EventBBOUpdate:
-		if event.BBO != nil {
+		if bbo := event.BBO(); bbo != nil {
 			c.bboHistory = append(c.bboHistory, bboSnapshot{
 				timestamp: event.Timestamp,
-				bbo:       *event.BBO,
+				bbo:       *bbo,
 			})
 		}
+	case domain.EventAccountUpdate:
+		if acct := event.Account(); acct != nil && !domain.IsBackground(acct.TraderID) {
+			c.getAccum(acct.TraderID).account = *acct
+		}
+	}
+
+	// Lifecycle comes from replaying the tracker rather than the logged
+	// ORDER_STATE records, so logs written before those still work
+	changes, _ := c.lifecycle.Apply(event)
+	for i := range changes {
+		c.processState(&changes[i], event)
+	}
+	if c.Options.Computes(GroupRequotes) {
+		c.quotes.process(event, changes)
 	}
 }
 
 func (c *Collector) processOrder(event *domain.Event) {
-	order := event.Order
-	if order.TraderID == "background" {
-		return // skip background orders
+	order := event.Order()
+	if domain.IsBackground(order.TraderID) {
+		c.backgroundOrder(order)
+		return
+	}
+	if c.skip(order.DecisionTime) {
+		c.masked[order.ID] = true
+		return
 	}
 
 	a := c.getAccum(order.TraderID)
 	a.ordersSent++
+	a.sent = append(a.sent, order.DecisionTime)
+	if order.ArrivalTime > 0 {
+		if a.latencies == nil {
+			a.latencies = make(map[domain.OrderType]quantile.Estimator)
+		}
+		gatewayNs := int64(0)
+		if v, ok := event.Annotation("gateway_ns"); ok {
+			gatewayNs, _ = strconv.ParseInt(v, 10, 64)
+			if a.gateway == nil {
+				a.gateway = c.Options.estimator()
+			}
+			a.gateway.Add(float64(gatewayNs))
+			if _, waited := event.Annotation("gateway_wait_ns"); waited {
+				a.gatewayQueued++
+			}
+		}
+		warmupNs := int64(0)
+		if v, ok := event.Annotation("warmup_ns"); ok {
+			warmupNs, _ = strconv.ParseInt(v, 10, 64)
+			a.coldMessages++
+			a.warmupNs += warmupNs
+		}
+		riskNs := int64(0)
+		if v, ok := event.Annotation("risk_check_ns"); ok {
+			riskNs, _ = strconv.ParseInt(v, 10, 64)
+		}
+		if a.latencies[order.Type] == nil {
+			a.latencies[order.Type] = c.Options.estimator()
+		}
+		a.latencies[order.Type].Add(float64(order.ArrivalTime - order.DecisionTime - gatewayNs - warmupNs - riskNs))
+	}
 
 	switch order.Type {
 	case domain.LimitOrder:
 		a.limitOrders++
-		midAtDecision := c.midAtTime(order.DecisionTime)
+		if order.Immediate() {
+			a.immediateOrders++
+		}
+		if order.Iceberg() {
+			a.icebergOrders++
+		}
 		a.orderTimes[order.ID] = orderInfo{
 			decisionTime:  order.DecisionTime,
 			arrivalTime:   order.ArrivalTime,
 			side:          order.Side,
 			price:         order.Price,
-			midAtDecision: midAtDecision,
+			qty:           order.Qty,
+			benchmark:     c.benchmark(order, event.Timestamp),
 			queuePosPlace: order.QueuePos,
 		}
 	case domain.MarketOrder:
 		a.marketOrders++
-		midAtDecision := c.midAtTime(order.DecisionTime)
 		a.orderTimes[order.ID] = orderInfo{
-			decisionTime:  order.DecisionTime,
-			arrivalTime:   order.ArrivalTime,
-			side:          order.Side,
-			midAtDecision: midAtDecision,
+			decisionTime: order.DecisionTime,
+			arrivalTime:  order.ArrivalTime,
+			side:         order.Side,
+			qty:          order.Qty,
+			benchmark:    c.benchmark(order, event.Timestamp),
 		}
 	case domain.CancelOrder:
 		a.cancelsSent++
 	}
+	if order.Type == domain.CancelOrder {
+		a.acceptedCancels = append(a.acceptedCancels, event.Timestamp)
+	} else {
+		a.acceptedOrders = append(a.acceptedOrders, event.Timestamp)
+	}
 }
 
-func (c *Collector) processCancel(event *domain.Event) {
-	order := event.Order
-	if order.TraderID == "background" {
+// benchmark is the price an order's slippage is measured against. The
+// book history holds every BBO up to the order's acceptance, which
+// covers its decision and arrival times
+func (c *Collector) benchmark(order *domain.Order, ts int64) int64 {
+	switch c.Options.BenchmarkName() {
+	case BenchArrivalMid:
+		arrival := order.ArrivalTime
+		if arrival == 0 {
+			arrival = ts
+		}
+		return c.midAtTime(arrival)
+	case BenchTouch:
+		bbo := c.bboAtTime(order.DecisionTime)
+		if order.Side == domain.Buy {
+			return bbo.AskPrice
+		}
+		return bbo.BidPrice
+	case BenchVWAP:
+		return 0
+	}
+	return c.midAtTime(order.DecisionTime)
+}
+
+// processReduce counts a partial cancel's ack. The tracker has not seen it
+// yet, so it still holds what the target had before
+func (c *Collector) processReduce(cancel *domain.Order) {
+	if domain.IsBackground(cancel.TraderID) || c.masked[cancel.ID] {
 		return
 	}
+	a := c.getAccum(cancel.TraderID)
+	a.partialCancels++
+	if before, ok := c.lifecycle.Remaining(cancel.CancelID); ok && before > cancel.RemainingQty {
+		a.reducedQty += before - cancel.RemainingQty
+		if info, ok := a.orderTimes[cancel.CancelID]; ok {
+			info.qty -= before - cancel.RemainingQty
+			a.orderTimes[cancel.CancelID] = info
+		}
+	}
+}
 
-	a := c.getAccum(order.TraderID)
-	if order.CancelID > 0 {
-		a.cancelTargets = append(a.cancelTargets, order.CancelID)
+func (c *Collector) processState(change *domain.StateChange, event *domain.Event) {
+	if domain.IsBackground(change.TraderID) || c.masked[change.OrderID] {
+		return
+	}
+
+	a := c.getAccum(change.TraderID)
+	switch change.To {
+	case domain.StateCanceled:
+		if cancel := event.Cancel(); cancel != nil && cancel.ID == change.OrderID {
+			// The venue's own cancel of an IOC or FOK remainder
+			a.killedOrders++
+			a.killedQty += cancel.Qty
+		} else if change.FilledQty == 0 {
+			a.canceledBeforeFill++
+		}
+	case domain.StateRejected:
+		// A rejected cancel: what happened to its target first
+		if cancel := event.Cancel(); cancel != nil && cancel.ID == change.OrderID {
+			switch target, _ := c.lifecycle.State(cancel.CancelID); target {
+			case domain.StateFilled:
+				a.lateCancels++
+			case domain.StateCanceled:
+				a.duplicateCancels++
+			case domain.StateResting, domain.StatePartiallyFilled:
+				a.refusedCancels++
+			}
+		}
+	case domain.StateExpired:
+		a.expiredOrders++
+		c.processResting(a, change, event.Timestamp)
 	}
 }
 
 func (c *Collector) processTrade(event *domain.Event) {
-	trade := event.Trade
+	trade := event.Trade()
+	var sign int8
+	switch trade.AggressorOrderID {
+	case 0:
+	case trade.BuyOrderID:
+		sign = 1
+	case trade.SellOrderID:
+		sign = -1
+	}
 	c.tradeHistory = append(c.tradeHistory, tradeRecord{
 		timestamp: trade.Timestamp,
 		price:     trade.Price,
+		qty:       trade.Qty,
+		sign:      sign,
 	})
 
+	c.backgroundTrade(trade)
+
 	// Record fill for the buyer
 	c.recordFill(trade.BuyTrader, trade.BuyOrderID, trade, event.Timestamp, domain.Buy)
 	// Record fill for the seller
@@ -210,49 +702,92 @@ func (c *Collector) processTrade(event *domain.Event) {
 }
 
 func (c *Collector) recordFill(traderID string, orderID uint64, trade *domain.Trade, fillTime int64, side domain.Side) {
-	if traderID == "background" {
+	if domain.IsBackground(traderID) || c.masked[orderID] {
 		return
 	}
 
 	a := c.getAccum(traderID)
 	a.filledOrders[orderID] = true
 	info, exists := a.orderTimes[orderID]
-	var midAtDecision int64
-	var decisionTime int64
+	var benchmark int64
+	var decisionTime, arrivalTime int64
 	var queuePosFill int
 	if exists {
-		midAtDecision = info.midAtDecision
-		decisionTime = info.decisionTime
+		benchmark = info.benchmark
+		decisionTime, arrivalTime = info.decisionTime, info.arrivalTime
 	}
 	// The resting queue position only applies to the passive order
-	if trade.PassiveOrderID > 0 && orderID == trade.PassiveOrderID {
+	passive := trade.PassiveOrderID > 0 && orderID == trade.PassiveOrderID
+	if passive {
 		queuePosFill = trade.RestingQueuePos
 	}
 
-	a.fills = append(a.fills, fillInfo{
-		tradePrice:    trade.Price,
-		fillQty:       trade.Qty,
-		decisionTime:  decisionTime,
-		fillTime:      fillTime,
-		midAtDecision: midAtDecision,
-		queuePosFill:  queuePosFill,
-		side:          side,
-	})
+	counterparty := trade.SellTrader
+	if side == domain.Sell {
+		counterparty = trade.BuyTrader
+	}
+	fill := fillInfo{
+		tradePrice:   trade.Price,
+		fillQty:      trade.Qty,
+		decisionTime: decisionTime,
+		arrivalTime:  arrivalTime,
+		fillTime:     fillTime,
+		benchmark:    benchmark,
+		queuePosFill: queuePosFill,
+		side:         side,
+		passive:      passive,
+		trade:        len(c.tradeHistory) - 1,
+		midBefore:    c.lastMid(),
+		counterparty: counterparty,
+	}
+	if passive {
+		fill.placedAheadQty, fill.aheadQty = trade.PlacedAheadQty, trade.AheadQty
+	}
+	a.fills = append(a.fills, fill)
 }
 
 // midAtTime returns the mid price at a given time by searching BBO history
 func (c *Collector) midAtTime(t int64) int64 {
+	return c.bboAtTime(t).MidPrice
+}
+
+// bboAtTime returns the latest BBO at or before t, or the first one if t
+// precedes them all
+func (c *Collector) bboAtTime(t int64) domain.BBO {
 	if len(c.bboHistory) == 0 {
-		return 0
+		return domain.BBO{}
 	}
-	// Binary search for the latest BBO before or at time t
 	idx := sort.Search(len(c.bboHistory), func(i int) bool {
 		return c.bboHistory[i].timestamp > t
 	})
 	if idx == 0 {
-		return c.bboHistory[0].bbo.MidPrice
+		return c.bboHistory[0].bbo
+	}
+	return c.bboHistory[idx-1].bbo
+}
+
+// vwap is the volume-weighted price of every trade so far, or 0
+func (c *Collector) vwap() int64 {
+	var notional float64
+	var qty int64
+	for _, t := range c.tradeHistory {
+		notional += float64(t.price) * float64(t.qty)
+		qty += t.qty
+	}
+	if qty == 0 {
+		return 0
 	}
-	return c.bboHistory[idx-1].bbo.MidPrice
+	return int64(math.Round(notional / float64(qty)))
+}
+
+// lastMid is the final two-sided mid, or 0 if there never was one
+func (c *Collector) lastMid() int64 {
+	for i := len(c.bboHistory) - 1; i >= 0; i-- {
+		if mid := c.bboHistory[i].bbo.MidPrice; mid > 0 {
+			return mid
+		}
+	}
+	return 0
 }
 
 // priceAfterDuration returns the mid price durationNs after fillTime
@@ -263,9 +798,29 @@ func (c *Collector) priceAfterDuration(fillTime int64, durationNs int64) int64 {
 
 // Compute calculates final metrics for all tracked traders
 func (c *Collector) Compute() map[string]*TraderMetrics {
+	scale := c.Scale
+	if scale == 0 {
+		scale = domain.DefaultScale
+	}
 	result := make(map[string]*TraderMetrics)
+	c.priority.flush()
+	horizons := c.Options.Horizons()
+	races := c.races.entries()
+	var vwap int64
+	if c.Options.BenchmarkName() == BenchVWAP {
+		vwap = c.vwap()
+	}
+	var toxicity *flowToxicity
+	if c.Options.Computes(GroupToxicity) {
+		buckets, window := c.Options.toxicity()
+		toxicity = newFlowToxicity(c.tradeHistory, buckets, window)
+	}
+	custom := make(map[string]map[string]float64, len(c.plugins))
+	for _, p := range c.plugins {
+		custom[p.name] = p.metric.Finalize()
+	}
 
-	for traderID, a := range c.traderMetrics {
+	for traderID, a := range detorder.All(c.traderMetrics) {
 		m := &TraderMetrics{
 			TraderID:     traderID,
 			OrdersSent:   a.ordersSent,
@@ -273,13 +828,60 @@ func (c *Collector) Compute() map[string]*TraderMetrics {
 			MarketOrders: a.marketOrders,
 			CancelsSent:  a.cancelsSent,
 			TotalFills:   len(a.fills),
+
+			CanceledBeforeFill: a.canceledBeforeFill,
+			LateCancels:        a.lateCancels,
+			DuplicateCancels:   a.duplicateCancels,
+			RefusedCancels:     a.refusedCancels,
+			ExpiredOrders:      a.expiredOrders,
+			PartialCancels:     a.partialCancels,
+			ReducedQty:         a.reducedQty,
+			ImmediateOrders:    a.immediateOrders,
+			KilledOrders:       a.killedOrders,
+			KilledQty:          a.killedQty,
+			IcebergOrders:      a.icebergOrders,
+			IcebergRefreshes:   a.refreshes,
+			GatewayQueued:      a.gatewayQueued,
+			ColdMessages:       a.coldMessages,
+			WarmupNs:           a.warmupNs,
+
+			QueueJumps: c.priority.jumps(traderID),
+
+			SlippageBenchmark: c.Options.BenchmarkName(),
+			Markouts:          make([]Markout, len(horizons)),
+		}
+		for by, n := range detorder.All(c.priority.jumped[traderID]) {
+			if m.JumpedBy == nil {
+				m.JumpedBy = make(map[string]int)
+			}
+			m.JumpedBy[by] = n
+			m.PriorityInversions += n
+		}
+		if a.refreshes > 0 {
+			m.RefreshQueuePos = float64(a.refreshPosSum) / float64(a.refreshes)
+		}
+		if c.Options.Computes(GroupRaces) {
+			m.addRaces(c.keptRaces(races[traderID]))
+		}
+		if c.Options.Computes(GroupCancelRaces) {
+			m.addCancelRaces(c.cancelRaces, c.Options.CancelRaceWindow(), c.tradeHistory, c.skip)
 		}
 
+		acct := a.account
+		m.FinalPosition = acct.Position
+		m.RealizedPnL = scale.ToFloat(acct.RealizedPnL)
+		m.Fees = scale.ToFloat(acct.Fees)
+		if mark := c.lastMid(); mark > 0 {
+			m.UnrealizedPnL = scale.ToFloat(acct.Unrealized(mark))
+		}
+		m.NetPnL = m.RealizedPnL + m.UnrealizedPnL - m.Fees
+
 		// Fill rate is order-level: executable orders with >=1 fill / executable orders
-		totalExecutableOrders := len(a.orderTimes)
+		cutOff := c.cutOff(a)
+		totalExecutableOrders := len(a.orderTimes) - len(cutOff)
 		if totalExecutableOrders > 0 {
 			filledExecutableOrders := 0
-			for orderID := range a.orderTimes {
+			for orderID := range detorder.All(a.orderTimes) {
 				if a.filledOrders[orderID] {
 					filledExecutableOrders++
 				}
@@ -295,29 +897,37 @@ func (c *Collector) Compute() map[string]*TraderMetrics {
 		var queuePosPlaceCount int
 		var totalQueuePosFill float64
 		var queuePosFillCount int
+		placePositions, fillPositions := c.Options.estimator(), c.Options.estimator()
+		toArrival, atVenue := c.Options.estimator(), c.Options.estimator()
+		ttfs := c.Options.estimator()
 
 		// Compute average queue position at placement from order records
-		for _, info := range a.orderTimes {
+		for _, info := range detorder.All(a.orderTimes) {
 			if info.queuePosPlace > 0 {
 				totalQueuePosPlace += float64(info.queuePosPlace)
 				queuePosPlaceCount++
+				placePositions.Add(float64(info.queuePosPlace))
 			}
 		}
 
 		for _, fill := range a.fills {
 			qty := fill.fillQty
 			totalQty += qty
-			totalPrice += domain.PriceToFloat(fill.tradePrice) * float64(qty)
+			totalPrice += scale.ToFloat(fill.tradePrice) * float64(qty)
 
-			// Slippage: signed difference from mid at decision time
-			if fill.midAtDecision > 0 {
+			// Slippage: signed difference from the benchmark price
+			bench := fill.benchmark
+			if vwap > 0 {
+				bench = vwap
+			}
+			if bench > 0 {
 				var slippage float64
 				if fill.side == domain.Buy {
-					// Buying: slippage = exec_price - mid (positive = worse for buyer)
-					slippage = domain.PriceToFloat(fill.tradePrice) - domain.PriceToFloat(fill.midAtDecision)
+					// Buying: slippage = exec_price - benchmark (positive = worse for buyer)
+					slippage = scale.ToFloat(fill.tradePrice) - scale.ToFloat(bench)
 				} else {
-					// Selling: slippage = mid - exec_price (positive = worse for seller)
-					slippage = domain.PriceToFloat(fill.midAtDecision) - domain.PriceToFloat(fill.tradePrice)
+					// Selling: slippage = benchmark - exec_price (positive = worse for seller)
+					slippage = scale.ToFloat(bench) - scale.ToFloat(fill.tradePrice)
 				}
 				totalSlippage += slippage * float64(qty)
 				m.SlippageValues = append(m.SlippageValues, slippage)
@@ -327,50 +937,69 @@ func (c *Collector) Compute() map[string]*TraderMetrics {
 			if fill.decisionTime > 0 {
 				ttf := float64(fill.fillTime-fill.decisionTime) / 1e6 // to ms
 				totalTimeToFill += ttf
-				m.TimeToFillDist = append(m.TimeToFillDist, ttf)
+				ttfs.Add(ttf)
+				if fill.arrivalTime > 0 {
+					toArrival.Add(float64(fill.arrivalTime - fill.decisionTime))
+					atVenue.Add(float64(fill.fillTime - fill.arrivalTime))
+				}
 			}
 
-			// Adverse selection: price move 100ms after fill
-			priceAfter := c.priceAfterDuration(fill.fillTime, 100_000_000) // 100ms
-			if priceAfter > 0 && fill.tradePrice > 0 {
-				var move float64
-				if fill.side == domain.Buy {
-					// For buyer: adverse if price went down after buy
-					move = domain.PriceToFloat(priceAfter) - domain.PriceToFloat(fill.tradePrice)
-				} else {
-					// For seller: adverse if price went up after sell
-					move = domain.PriceToFloat(fill.tradePrice) - domain.PriceToFloat(priceAfter)
+			// Adverse selection: price move each horizon after the fill
+			for i, h := range horizons {
+				priceAfter := c.priceAfterDuration(fill.fillTime, h)
+				if priceAfter > 0 && fill.tradePrice > 0 {
+					var move float64
+					if fill.side == domain.Buy {
+						// For buyer: adverse if price went down after buy
+						move = scale.ToFloat(priceAfter) - scale.ToFloat(fill.tradePrice)
+					} else {
+						// For seller: adverse if price went up after sell
+						move = scale.ToFloat(fill.tradePrice) - scale.ToFloat(priceAfter)
+					}
+					m.Markouts[i].AvgMove += move
 				}
-				m.AvgPriceMoveAfterFill += move
 			}
 
 			// Queue position at fill
 			if fill.queuePosFill > 0 {
 				totalQueuePosFill += float64(fill.queuePosFill)
 				queuePosFillCount++
+				fillPositions.Add(float64(fill.queuePosFill))
 			}
 		}
 
 		m.TotalQtyFilled = totalQty
+		m.addSpread(a.fills, scale)
+		if toxicity != nil {
+			m.addToxicity(a.fills, toxicity)
+		}
+		m.addCounterparties(c, a.fills, horizons[0], scale)
+		m.addQueueProgress(a.fills)
 
 		if totalQty > 0 {
 			m.AvgExecPrice = totalPrice / float64(totalQty)
 			m.AvgSlippage = totalSlippage / float64(totalQty)
-			midPrice := domain.PriceToFloat(c.midAtTime(0))
+			midPrice := scale.ToFloat(c.midAtTime(0))
 			if midPrice > 0 {
 				m.SlippageBps = (m.AvgSlippage / midPrice) * 10000
 			}
 		}
 
-		if len(a.fills) > 0 {
-			m.AvgTimeToFillNs = totalTimeToFill / float64(len(a.fills))
-			m.AvgPriceMoveAfterFill /= float64(len(a.fills))
-
-			midPrice := domain.PriceToFloat(c.midAtTime(0))
-			if midPrice > 0 {
-				m.AdverseSelectionBps = (m.AvgPriceMoveAfterFill / midPrice) * 10000
+		midPrice := scale.ToFloat(c.midAtTime(0))
+		for i, h := range horizons {
+			mo := &m.Markouts[i]
+			mo.HorizonNs = h
+			if len(a.fills) > 0 {
+				mo.AvgMove /= float64(len(a.fills))
+				if midPrice > 0 {
+					mo.Bps = (mo.AvgMove / midPrice) * 10000
+				}
 			}
 		}
+		m.AvgPriceMoveAfterFill, m.AdverseSelectionBps = m.Markouts[0].AvgMove, m.Markouts[0].Bps
+		if len(a.fills) > 0 {
+			m.AvgTimeToFillMs = totalTimeToFill / float64(len(a.fills))
+		}
 
 		// Queue position averages
 		if queuePosPlaceCount > 0 {
@@ -379,16 +1008,36 @@ func (c *Collector) Compute() map[string]*TraderMetrics {
 		if queuePosFillCount > 0 {
 			m.AvgQueuePosFill = totalQueuePosFill / float64(queuePosFillCount)
 		}
+		m.QueuePosPlace = queueDist(placePositions)
+		m.QueuePosFill = queueDist(fillPositions)
 
-		// Canceled-before-fill: count cancel targets that were never filled
-		for _, canceledID := range a.cancelTargets {
-			if !a.filledOrders[canceledID] {
-				m.CanceledBeforeFill++
+		for typ, samples := range detorder.All(a.latencies) {
+			if m.RealizedLatency == nil {
+				m.RealizedLatency = make(map[string]*LatencyStats)
 			}
+			m.RealizedLatency[latencyKey(typ)] = latencyStats(samples)
 		}
+		m.Gateway = latencyStats(a.gateway)
 
-		// Sort time-to-fill for CDF plotting
-		sort.Float64s(m.TimeToFillDist)
+		// Exact time-to-fill is kept sorted for CDF plotting
+		switch e := quantile.Unwrap(ttfs).(type) {
+		case *quantile.Exact:
+			if e.Count() > 0 {
+				m.TimeToFillDist = e.Samples()
+			}
+		case *quantile.Sketch:
+			m.TimeToFillSketch = e
+		}
+		m.TTFToArrival, m.TTFAtVenue = latencyStats(toArrival), latencyStats(atVenue)
+		m.addMessaging(c, a)
+		m.addResting(c, a, scale)
+		if c.Options.Computes(GroupActivity) {
+			m.addActivity(c, a)
+		}
+		if c.Options.Computes(GroupRequotes) {
+			m.addRequotes(c.quotes, c.lastEvent)
+		}
+		m.addCustom(custom)
 
 		result[traderID] = m
 	}
@@ -396,30 +1045,44 @@ func (c *Collector) Compute() map[string]*TraderMetrics {
 	return result
 }
 
-// ComputeFromLog reads an event log and computes metrics
-func ComputeFromLog(logPath string) (map[string]*TraderMetrics, error) {
+// ComputeFromLog reads an event log and computes metrics, converting
+// prices with scale (zero means domain.DefaultScale) and measuring them
+// as opts choose
+func ComputeFromLog(logPath string, scale domain.Scale, opts Options) (map[string]*TraderMetrics, error) {
+	return computeFromLog(logPath, scale, opts, false)
+}
+
+func computeFromLog(logPath string, scale domain.Scale, opts Options, onlyExcluded bool) (map[string]*TraderMetrics, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
 	reader, err := eventlog.NewReader(logPath)
 	if err != nil {
 		return nil, err
 	}
 	defer reader.Close()
+	have, err := reader.Level()
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckLevel(have); err != nil {
+		return nil, err
+	}
 
 	c := NewCollector()
-	for {
-		event, err := reader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
+	c.Scale, c.Options, c.onlyExcluded = scale, opts, onlyExcluded
+	err = reader.Each(func(event *domain.Event) error {
 		c.ProcessEvent(event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
 	return c.Compute(), nil
 }
 
-// ComputeFromEvents computes metrics directly from an in-memory event stream
+// ComputeFromEvents computes metrics directly from an in-memory event
+// stream at the default price scale
 func ComputeFromEvents(events []*domain.Event) map[string]*TraderMetrics {
 	c := NewCollector()
 	for _, event := range events {
@@ -430,3 +1093,29 @@ func ComputeFromEvents(events []*domain.Event) map[string]*TraderMetrics {
 	}
 	return c.Compute()
 }
+
+// ComputeEvents computes metrics from an in-memory event log, such as
+// an in-memory run keeps, as ComputeFromLog would from the same log on
+// disk
+func ComputeEvents(events []*domain.Event, scale domain.Scale, opts Options) (map[string]*TraderMetrics, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	var first *domain.Event
+	if len(events) > 0 {
+		first = events[0]
+	}
+	have, err := eventlog.DeclaredLevel(first)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckLevel(have); err != nil {
+		return nil, err
+	}
+	c := NewCollector()
+	c.Scale, c.Options = scale, opts
+	for _, event := range events {
+		c.ProcessEvent(event)
+	}
+	return c.Compute(), nil
+}
@@ -1,12 +1,16 @@
 package metrics
 
 import (
+	"encoding/json"
+	"math"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/quantile"
 )
 
 func TestFillRateCountsFilledOrderOnceWithPartialFills(t *testing.T) {
@@ -14,7 +18,7 @@ func TestFillRateCountsFilledOrderOnceWithPartialFills(t *testing.T) {
 		{
 			Timestamp: 100,
 			Type:      domain.EventOrderAccepted,
-			Order: &domain.Order{
+			Payload: &domain.Order{
 				ID:           1,
 				TraderID:     "fast",
 				Side:         domain.Buy,
@@ -29,7 +33,7 @@ func TestFillRateCountsFilledOrderOnceWithPartialFills(t *testing.T) {
 		{
 			Timestamp: 110,
 			Type:      domain.EventTradeExecuted,
-			Trade: &domain.Trade{
+			Payload: &domain.Trade{
 				ID:          1,
 				BuyOrderID:  1,
 				SellOrderID: 5001,
@@ -43,7 +47,7 @@ func TestFillRateCountsFilledOrderOnceWithPartialFills(t *testing.T) {
 		{
 			Timestamp: 120,
 			Type:      domain.EventTradeExecuted,
-			Trade: &domain.Trade{
+			Payload: &domain.Trade{
 				ID:          2,
 				BuyOrderID:  1,
 				SellOrderID: 5002,
@@ -75,7 +79,7 @@ func TestFillRateNeverExceedsOne(t *testing.T) {
 		{
 			Timestamp: 100,
 			Type:      domain.EventOrderAccepted,
-			Order: &domain.Order{
+			Payload: &domain.Order{
 				ID:           1,
 				TraderID:     "fast",
 				Side:         domain.Buy,
@@ -90,7 +94,7 @@ func TestFillRateNeverExceedsOne(t *testing.T) {
 		{
 			Timestamp: 101,
 			Type:      domain.EventOrderAccepted,
-			Order: &domain.Order{
+			Payload: &domain.Order{
 				ID:           2,
 				TraderID:     "fast",
 				Side:         domain.Sell,
@@ -105,7 +109,7 @@ func TestFillRateNeverExceedsOne(t *testing.T) {
 		{
 			Timestamp: 110,
 			Type:      domain.EventTradeExecuted,
-			Trade: &domain.Trade{
+			Payload: &domain.Trade{
 				ID:          1,
 				BuyOrderID:  1,
 				SellOrderID: 5001,
@@ -119,7 +123,7 @@ func TestFillRateNeverExceedsOne(t *testing.T) {
 		{
 			Timestamp: 120,
 			Type:      domain.EventTradeExecuted,
-			Trade: &domain.Trade{
+			Payload: &domain.Trade{
 				ID:          2,
 				BuyOrderID:  1,
 				SellOrderID: 5002,
@@ -151,7 +155,7 @@ func TestComputeFromEventsMatchesComputeFromLog(t *testing.T) {
 		{
 			Timestamp: 50,
 			Type:      domain.EventBBOUpdate,
-			BBO: &domain.BBO{
+			Payload: &domain.BBO{
 				BidPrice: domain.FloatToPrice(99.99),
 				BidQty:   20,
 				AskPrice: domain.FloatToPrice(100.01),
@@ -162,7 +166,7 @@ func TestComputeFromEventsMatchesComputeFromLog(t *testing.T) {
 		{
 			Timestamp: 100,
 			Type:      domain.EventOrderAccepted,
-			Order: &domain.Order{
+			Payload: &domain.Order{
 				ID:           10,
 				TraderID:     "fast",
 				Side:         domain.Buy,
@@ -178,7 +182,7 @@ func TestComputeFromEventsMatchesComputeFromLog(t *testing.T) {
 		{
 			Timestamp: 101,
 			Type:      domain.EventOrderAccepted,
-			Order: &domain.Order{
+			Payload: &domain.Order{
 				ID:           20,
 				TraderID:     "slow",
 				Side:         domain.Sell,
@@ -194,7 +198,7 @@ func TestComputeFromEventsMatchesComputeFromLog(t *testing.T) {
 		{
 			Timestamp: 120,
 			Type:      domain.EventTradeExecuted,
-			Trade: &domain.Trade{
+			Payload: &domain.Trade{
 				ID:               1,
 				BuyOrderID:       10,
 				SellOrderID:      7001,
@@ -211,7 +215,7 @@ func TestComputeFromEventsMatchesComputeFromLog(t *testing.T) {
 		{
 			Timestamp: 130,
 			Type:      domain.EventTradeExecuted,
-			Trade: &domain.Trade{
+			Payload: &domain.Trade{
 				ID:               2,
 				BuyOrderID:       7002,
 				SellOrderID:      20,
@@ -242,7 +246,7 @@ func TestComputeFromEventsMatchesComputeFromLog(t *testing.T) {
 	}
 
 	fromEvents := ComputeFromEvents(events)
-	fromLog, err := ComputeFromLog(logPath)
+	fromLog, err := ComputeFromLog(logPath, domain.DefaultScale, Options{})
 	if err != nil {
 		t.Fatalf("compute from log: %v", err)
 	}
@@ -257,7 +261,7 @@ func TestQueuePositionAtFillOnlyForPassiveOrder(t *testing.T) {
 		{
 			Timestamp: 50,
 			Type:      domain.EventBBOUpdate,
-			BBO: &domain.BBO{
+			Payload: &domain.BBO{
 				BidPrice: domain.FloatToPrice(99.99),
 				BidQty:   20,
 				AskPrice: domain.FloatToPrice(100.01),
@@ -268,7 +272,7 @@ func TestQueuePositionAtFillOnlyForPassiveOrder(t *testing.T) {
 		{
 			Timestamp: 100,
 			Type:      domain.EventOrderAccepted,
-			Order: &domain.Order{
+			Payload: &domain.Order{
 				ID:           1,
 				TraderID:     "fast",
 				Side:         domain.Buy,
@@ -284,7 +288,7 @@ func TestQueuePositionAtFillOnlyForPassiveOrder(t *testing.T) {
 		{
 			Timestamp: 110,
 			Type:      domain.EventOrderAccepted,
-			Order: &domain.Order{
+			Payload: &domain.Order{
 				ID:           2,
 				TraderID:     "slow",
 				Side:         domain.Sell,
@@ -298,7 +302,7 @@ func TestQueuePositionAtFillOnlyForPassiveOrder(t *testing.T) {
 		{
 			Timestamp: 111,
 			Type:      domain.EventTradeExecuted,
-			Trade: &domain.Trade{
+			Payload: &domain.Trade{
 				ID:               1,
 				BuyOrderID:       1,
 				SellOrderID:      2,
@@ -328,3 +332,887 @@ func TestQueuePositionAtFillOnlyForPassiveOrder(t *testing.T) {
 		t.Fatalf("expected aggressor queue-pos-fill 0, got %.2f", slow.AvgQueuePosFill)
 	}
 }
+
+// TestCancelOutcomesFromLifecycle checks a cancel that loses the race to a
+// fill and a repeated cancel are not counted as orders canceled unfilled
+func TestCancelOutcomesFromLifecycle(t *testing.T) {
+	limit := func(id uint64) *domain.Event {
+		return &domain.Event{Timestamp: 100, Type: domain.EventOrderAccepted, Payload: &domain.Order{
+			ID: id, TraderID: "slow", Side: domain.Buy, Type: domain.LimitOrder,
+			Price: domain.FloatToPrice(100.00), Qty: 5, RemainingQty: 5, DecisionTime: 90, ArrivalTime: 100,
+		}}
+	}
+	// Only a cancel the book accepts is acked
+	cancel := func(id, target uint64, acked bool) []*domain.Event {
+		o := &domain.Order{ID: id, TraderID: "slow", Type: domain.CancelOrder, CancelID: target}
+		events := []*domain.Event{{Timestamp: 200, Type: domain.EventOrderAccepted, Payload: o}}
+		if acked {
+			events = append(events, &domain.Event{Timestamp: 200, Type: domain.EventOrderCanceled, Payload: o})
+		}
+		return events
+	}
+	events := []*domain.Event{
+		limit(1),
+		limit(2),
+		limit(3),
+		{Timestamp: 150, Type: domain.EventTradeExecuted, Payload: &domain.Trade{
+			ID: 1, BuyOrderID: 1, SellOrderID: 9001, BuyTrader: "slow", SellTrader: "background",
+			Price: domain.FloatToPrice(100.00), Qty: 5, Timestamp: 150, PassiveOrderID: 1, AggressorOrderID: 9001,
+		}},
+	}
+	events = append(events, cancel(10, 1, false)...) // too late: order 1 filled
+	events = append(events, cancel(11, 2, true)...)
+	events = append(events, cancel(12, 2, false)...) // order 2 already canceled
+	events = append(events, &domain.Event{Timestamp: 250, Type: domain.EventOrderAccepted, Payload: &domain.Order{
+		ID: 13, TraderID: "fast", Type: domain.CancelOrder, CancelID: 3, // not fast's order
+	}})
+	events = append(events, &domain.Event{Timestamp: 300, Type: domain.EventSimEnd})
+
+	slow := ComputeFromEvents(events)["slow"]
+	if slow.CancelsSent != 3 {
+		t.Errorf("CancelsSent = %d, want 3", slow.CancelsSent)
+	}
+	if slow.CanceledBeforeFill != 1 || slow.LateCancels != 1 || slow.DuplicateCancels != 1 {
+		t.Errorf("canceled before fill %d, late %d, duplicate %d; want 1, 1, 1",
+			slow.CanceledBeforeFill, slow.LateCancels, slow.DuplicateCancels)
+	}
+	if slow.ExpiredOrders != 1 {
+		t.Errorf("ExpiredOrders = %d, want 1 (order 3)", slow.ExpiredOrders)
+	}
+	if fast := ComputeFromEvents(events)["fast"]; fast == nil || fast.RefusedCancels != 1 {
+		t.Errorf("fast trader's cancel of a slow order not counted as refused: %+v", fast)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	tagged := func(ts int64, burst string) *domain.Event {
+		e := &domain.Event{Timestamp: ts, Type: domain.EventReQuote, TraderID: "fast"}
+		e.Annotate("burst_id", burst)
+		return e
+	}
+	c := NewCollector()
+	var seen []int64
+	c.Watch("burst_id", func(e *domain.Event, value string) {
+		if value == "2" {
+			seen = append(seen, e.Timestamp)
+		}
+	})
+	for _, e := range []*domain.Event{tagged(1, "1"), tagged(2, "2"), {Timestamp: 3, Type: domain.EventSimEnd}, tagged(4, "2")} {
+		c.ProcessEvent(e)
+	}
+	if c.Annotated("burst_id", "1") != 1 || c.Annotated("burst_id", "2") != 2 || c.Annotated("speed_bump", "1") != 0 {
+		t.Errorf("annotation counts = %v", c.Annotations())
+	}
+	if len(seen) != 2 || seen[0] != 2 || seen[1] != 4 {
+		t.Errorf("watcher saw burst 2 at %v, want [2 4]", seen)
+	}
+}
+
+func TestPnLFromAccountUpdates(t *testing.T) {
+	acct := &domain.Account{TraderID: "fast", Cash: -5_000_010, Position: 5, CostBasis: 5_000_000,
+		Fees: 10, Volume: 5, Fills: 1}
+	update := domain.NewAccountEvent(150, acct)
+	events := []*domain.Event{
+		{Timestamp: 100, Type: domain.EventOrderAccepted, Payload: &domain.Order{
+			ID: 1, TraderID: "fast", Side: domain.Buy, Type: domain.MarketOrder, Qty: 5, DecisionTime: 90, ArrivalTime: 100,
+		}},
+		&update,
+		{Timestamp: 200, Type: domain.EventBBOUpdate, Payload: &domain.BBO{
+			BidPrice: 1_000_100, AskPrice: 1_000_300, MidPrice: 1_000_200,
+		}},
+		{Timestamp: 300, Type: domain.EventBBOUpdate, Payload: &domain.BBO{BidPrice: 1_000_100}},
+	}
+
+	fast := ComputeFromEvents(events)["fast"]
+	if fast.FinalPosition != 5 || fast.Fees != 0.001 {
+		t.Errorf("position %d fees %v, want 5 and 0.001", fast.FinalPosition, fast.Fees)
+	}
+	if fast.UnrealizedPnL != 0.1 {
+		t.Errorf("unrealized = %v, want 0.1 marked at the last two-sided mid", fast.UnrealizedPnL)
+	}
+	if fast.NetPnL != fast.RealizedPnL+fast.UnrealizedPnL-fast.Fees {
+		t.Errorf("net = %v, want realized + unrealized - fees", fast.NetPnL)
+	}
+}
+
+// TestPriorityInversions checks a later arrival filled ahead of an earlier
+// order at its price counts once per pair, that a fill in arrival order
+// does not count, and that an auction fill logged before the earlier
+// order's accept at the same timestamp still counts. An iceberg that
+// refreshed has given up its place
+func TestPriorityInversions(t *testing.T) {
+	px := domain.FloatToPrice(100.00)
+	rest := func(ts int64, id uint64, trader string, arrival int64) *domain.Event {
+		e := domain.NewOrderEvent(ts, &domain.Order{
+			ID: id, TraderID: trader, Side: domain.Buy, Type: domain.LimitOrder, Price: px,
+			Qty: 5, RemainingQty: 5, DecisionTime: arrival - 10, ArrivalTime: arrival,
+		})
+		return &e
+	}
+	hit := func(ts int64, passive uint64, trader string, qty int64) *domain.Event {
+		e := domain.NewTradeEvent(ts, &domain.Trade{
+			ID: passive*100 + uint64(ts), BuyOrderID: passive, SellOrderID: 99, BuyTrader: trader, SellTrader: "background",
+			Price: px, Qty: qty, Timestamp: ts, PassiveOrderID: passive, AggressorOrderID: 99,
+		})
+		return &e
+	}
+
+	t.Run("fifo", func(t *testing.T) {
+		m := ComputeFromEvents([]*domain.Event{
+			rest(100, 1, "fast", 100), rest(200, 2, "slow", 200),
+			hit(300, 1, "fast", 5), hit(300, 2, "slow", 2),
+		})
+		if m["fast"].PriorityInversions != 0 || m["slow"].PriorityInversions != 0 || m["slow"].QueueJumps != 0 {
+			t.Fatalf("fills in arrival order counted as inversions: %+v %+v", m["fast"], m["slow"])
+		}
+	})
+
+	t.Run("pro rata", func(t *testing.T) {
+		m := ComputeFromEvents([]*domain.Event{
+			rest(100, 1, "fast", 100), rest(200, 2, "slow", 200),
+			hit(300, 1, "fast", 2), hit(300, 2, "slow", 2),
+			hit(400, 2, "slow", 1), // the same pair again
+		})
+		fast, slow := m["fast"], m["slow"]
+		if fast.PriorityInversions != 1 || fast.JumpedBy["slow"] != 1 || slow.QueueJumps != 1 {
+			t.Fatalf("expected slow to jump fast once, got inversions %d by %v, jumps %d",
+				fast.PriorityInversions, fast.JumpedBy, slow.QueueJumps)
+		}
+		if slow.PriorityInversions != 0 || fast.QueueJumps != 0 {
+			t.Fatalf("fast arrived first and cannot jump slow: %+v %+v", fast, slow)
+		}
+	})
+
+	t.Run("auction", func(t *testing.T) {
+		// Slow arrived later but was crossed first; fast, logged after,
+		// was left with quantity
+		m := ComputeFromEvents([]*domain.Event{
+			rest(500, 2, "slow", 450), hit(500, 2, "slow", 5),
+			rest(500, 1, "fast", 420),
+		})
+		if m["fast"].JumpedBy["slow"] != 1 {
+			t.Fatalf("expected one inversion of fast by slow, got %v", m["fast"].JumpedBy)
+		}
+	})
+
+	t.Run("iceberg refresh", func(t *testing.T) {
+		// Fast's iceberg shows 5 of 10; its refresh puts it behind slow
+		ice := rest(100, 1, "fast", 100)
+		ice.Order().Qty, ice.Order().RemainingQty, ice.Order().DisplayQty = 10, 10, 5
+		refreshed := *ice.Order()
+		refreshed.RemainingQty, refreshed.QueuePos = 5, 2
+		refresh := domain.NewRefreshEvent(300, &refreshed)
+		m := ComputeFromEvents([]*domain.Event{
+			ice, rest(200, 2, "slow", 200),
+			hit(300, 1, "fast", 5), &refresh, hit(300, 2, "slow", 2),
+		})
+		fast, slow := m["fast"], m["slow"]
+		if fast.PriorityInversions != 0 || slow.QueueJumps != 0 {
+			t.Fatalf("fill behind a refreshed iceberg counted as an inversion: %v", fast.JumpedBy)
+		}
+		if fast.IcebergOrders != 1 || fast.IcebergRefreshes != 1 || fast.RefreshQueuePos != 2 {
+			t.Errorf("iceberg orders %d, refreshes %d at %v; want 1, 1 at 2",
+				fast.IcebergOrders, fast.IcebergRefreshes, fast.RefreshQueuePos)
+		}
+	})
+}
+
+// TestSlippageBenchmarksAndMarkouts measures one buy against each
+// benchmark and at two markout horizons
+func TestSlippageBenchmarksAndMarkouts(t *testing.T) {
+	p := domain.FloatToPrice
+	bbo := func(ts int64, bid, ask float64) *domain.Event {
+		e := domain.NewBBOEvent(ts, &domain.BBO{BidPrice: p(bid), AskPrice: p(ask), MidPrice: (p(bid) + p(ask)) / 2})
+		return &e
+	}
+	trade := func(id uint64, ts int64, buyer string, buyOrder uint64, price float64) *domain.Event {
+		return &domain.Event{Timestamp: ts, Type: domain.EventTradeExecuted, Payload: &domain.Trade{
+			ID: id, BuyOrderID: buyOrder, SellOrderID: 9000 + id, BuyTrader: buyer, SellTrader: "background",
+			Price: p(price), Qty: 10, Timestamp: ts,
+		}}
+	}
+	events := []*domain.Event{
+		bbo(0, 99.97, 100.03), // mid 100.00, ask 100.03 at decision
+		bbo(50, 100.00, 100.04),
+		{Timestamp: 60, Type: domain.EventOrderAccepted, Payload: &domain.Order{
+			ID: 1, TraderID: "fast", Side: domain.Buy, Type: domain.MarketOrder, Qty: 10, RemainingQty: 10,
+			DecisionTime: 10, ArrivalTime: 60,
+		}},
+		trade(1, 60, "fast", 1, 100.04),
+		trade(2, 70, "background", 5000, 99.98), // VWAP 100.01
+		bbo(100_000_000, 100.08, 100.12),        // mid 100.10
+		bbo(150_000_000, 99.88, 99.92),          // mid 99.90
+	}
+
+	for _, tc := range []struct {
+		bench string
+		want  float64
+	}{
+		{"", 0.04},
+		{BenchArrivalMid, 0.02},
+		{BenchTouch, 0.01},
+		{BenchVWAP, 0.03},
+	} {
+		c := NewCollector()
+		c.Options = Options{Benchmark: tc.bench, HorizonsNs: []int64{100_000_000, 200_000_000}}
+		for _, e := range events {
+			c.ProcessEvent(e)
+		}
+		fast := c.Compute()["fast"]
+		if math.Abs(fast.AvgSlippage-tc.want) > 1e-9 {
+			t.Errorf("%s: slippage %.4f, want %.4f", fast.SlippageBenchmark, fast.AvgSlippage, tc.want)
+		}
+		if len(fast.Markouts) != 2 {
+			t.Fatalf("%d markouts, want 2", len(fast.Markouts))
+		}
+		if math.Abs(fast.Markouts[0].AvgMove-0.06) > 1e-9 || math.Abs(fast.Markouts[1].AvgMove+0.14) > 1e-9 {
+			t.Errorf("markouts %+v, want +0.06 then -0.14", fast.Markouts)
+		}
+		if fast.AdverseSelectionBps != fast.Markouts[0].Bps {
+			t.Errorf("headline %.4f is not the first markout %.4f", fast.AdverseSelectionBps, fast.Markouts[0].Bps)
+		}
+	}
+}
+
+func TestOptionsJSON(t *testing.T) {
+	var o Options
+	if err := json.Unmarshal([]byte(`{"benchmark": "touch", "horizons_ns": ["50ms", 1000000000], "cancel_race_window_ns": "20ms",
+		"exclude": [{"name": "shock", "start_ns": "2s", "end_ns": 2500000000}], "percentiles": "approx", "cancel_at_close": true}`), &o); err != nil {
+		t.Fatal(err)
+	}
+	want := Options{Benchmark: BenchTouch, HorizonsNs: []int64{50_000_000, 1_000_000_000}, CancelRaceWindowNs: 20_000_000,
+		Exclude: []Window{{Name: "shock", StartNs: 2_000_000_000, EndNs: 2_500_000_000}}, Percentiles: "approx", CancelAtClose: true}
+	if !reflect.DeepEqual(o, want) {
+		t.Errorf("got %+v, want %+v", o, want)
+	}
+	for _, bad := range []string{`{"benchmark": "twap"}`, `{"horizons_ns": ["0s"]}`, `{"horizons_ns": ["1s", "1s"]}`,
+		`{"exclude": [{"start_ns": "2s", "end_ns": "1s"}]}`, `{"exclude": [{"start_ns": "2s"}]}`, `{"percentiles": "median"}`} {
+		var o Options
+		if err := json.Unmarshal([]byte(bad), &o); err == nil {
+			err = o.Validate()
+			if err == nil {
+				t.Errorf("%s accepted", bad)
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"horizon": "1s"}`), &o); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
+
+// TestRealizedLatency checks arrival − decision times are kept per message
+// type and that Check flags a message that skipped the latency model
+func TestRealizedLatency(t *testing.T) {
+	accept := func(id uint64, typ domain.OrderType, decision, arrival int64) *domain.Event {
+		return &domain.Event{Timestamp: arrival, Type: domain.EventOrderAccepted, Payload: &domain.Order{
+			ID: id, TraderID: "slow", Side: domain.Buy, Type: typ, Price: domain.FloatToPrice(100.00),
+			Qty: 1, RemainingQty: 1, DecisionTime: decision, ArrivalTime: arrival,
+		}}
+	}
+	events := []*domain.Event{
+		accept(1, domain.LimitOrder, 100, 1100),
+		accept(2, domain.LimitOrder, 200, 1400),
+		accept(3, domain.LimitOrder, 300, 1200),
+		accept(4, domain.CancelOrder, 400, 400),
+	}
+	slow := ComputeFromEvents(events)["slow"]
+	limit := slow.RealizedLatency["limit"]
+	if limit == nil || limit.Count != 3 || limit.MinNs != 900 || limit.P50Ns != 1000 || limit.MaxNs != 1200 {
+		t.Fatalf("limit latency = %+v", limit)
+	}
+	if _, ok := slow.RealizedLatency["market"]; ok {
+		t.Error("market latency without market orders")
+	}
+	if issues := limit.Check(900, 500); len(issues) != 0 {
+		t.Errorf("in-range limit latency flagged: %v", issues)
+	}
+	if issues := limit.Check(1000, 500); len(issues) != 1 {
+		t.Errorf("limit below base: issues = %v", issues)
+	}
+	if issues := slow.RealizedLatency["cancel"].Check(900, 500); len(issues) != 1 {
+		t.Errorf("zero-latency cancel: issues = %v", issues)
+	}
+	var none *LatencyStats
+	if none.Check(900, 500) != nil {
+		t.Error("nil stats flagged")
+	}
+}
+
+// TestSignalRaces checks entrants are ranked by first arrival, a delayed
+// delivery joins the signal it copies, and the loser is scored against
+// the winner
+func TestSignalRaces(t *testing.T) {
+	mid := domain.FloatToPrice(100.00)
+	signal := func(ts int64, trader string) *domain.Event {
+		e := domain.NewSignalEvent(ts, &domain.Signal{Value: 0.5, MidPrice: mid})
+		e.TraderID = trader
+		return &e
+	}
+	accept := func(id uint64, trader string, typ domain.OrderType, decision, arrival int64, queue int) *domain.Event {
+		return &domain.Event{Timestamp: arrival, Type: domain.EventOrderAccepted, Payload: &domain.Order{
+			ID: id, TraderID: trader, Side: domain.Buy, Type: typ, Price: domain.FloatToPrice(99.99),
+			Qty: 5, RemainingQty: 5, DecisionTime: decision, ArrivalTime: arrival, QueuePos: queue,
+		}}
+	}
+	trade := func(buyID uint64, buyer string, ts int64, price float64) *domain.Event {
+		return &domain.Event{Timestamp: ts, Type: domain.EventTradeExecuted, Payload: &domain.Trade{
+			BuyOrderID: buyID, SellOrderID: 9000 + buyID, BuyTrader: buyer, SellTrader: "background",
+			Price: domain.FloatToPrice(price), Qty: 5, Timestamp: ts,
+		}}
+	}
+	events := []*domain.Event{
+		signal(1000, ""),
+		accept(1, "fast", domain.LimitOrder, 1000, 1100, 2),
+		accept(2, "fast", domain.CancelOrder, 1000, 1050, 0), // cancels don't enter
+		signal(1500, "slow"),
+		accept(3, "slow", domain.LimitOrder, 1500, 2000, 4),
+		trade(1, "fast", 2500, 99.99),
+		accept(4, "fast", domain.MarketOrder, 3000, 3100, 0), // no signal at 3000
+		trade(4, "fast", 3100, 100.01),
+	}
+	m := ComputeFromEvents(events)
+	fast, slow := m["fast"], m["slow"]
+	if fast.RacesEntered != 1 || fast.RacesWon != 1 || fast.RaceWinRate != 1 {
+		t.Fatalf("fast races: entered %d won %d rate %v", fast.RacesEntered, fast.RacesWon, fast.RaceWinRate)
+	}
+	w := fast.Races[0]
+	if w.Rank != 1 || w.Entrants != 2 || w.ArrivalNs != 1100 || w.LeadNs != 900 || w.Orders != 1 || w.FilledQty != 5 {
+		t.Errorf("winner entry = %+v", w)
+	}
+	if math.Abs(w.CostBps-(-1)) > 1e-9 {
+		t.Errorf("winner cost = %v bps, want -1", w.CostBps)
+	}
+	if slow.RacesLost != 1 || slow.RacesMissed != 1 || slow.RacesQueueBehind != 1 {
+		t.Fatalf("slow races: lost %d missed %d behind %d", slow.RacesLost, slow.RacesMissed, slow.RacesQueueBehind)
+	}
+	l := slow.Races[0]
+	if l.SignalNs != 1000 || l.Rank != 2 || l.LeadNs != -900 || l.CostGapBps != nil {
+		t.Errorf("loser entry = %+v", l)
+	}
+}
+
+// TestSpreadMix checks a passive buy below the mid captures the half
+// spread, an aggressive buy above it pays, and a fill before any
+// two-sided book counts towards the mix but not the spread
+func TestSpreadMix(t *testing.T) {
+	bbo := func(ts int64) *domain.Event {
+		return &domain.Event{Timestamp: ts, Type: domain.EventBBOUpdate, Payload: &domain.BBO{
+			BidPrice: domain.FloatToPrice(99.98), BidQty: 10, AskPrice: domain.FloatToPrice(100.02), AskQty: 10,
+			MidPrice: domain.FloatToPrice(100.00)}}
+	}
+	trade := func(ts int64, id, fastOrder uint64, price float64, passive bool, qty int64) *domain.Event {
+		tr := &domain.Trade{ID: id, BuyOrderID: fastOrder, SellOrderID: 9000 + id, BuyTrader: "fast", SellTrader: "background",
+			Price: domain.FloatToPrice(price), Qty: qty, Timestamp: ts}
+		if passive {
+			tr.PassiveOrderID, tr.AggressorOrderID = fastOrder, tr.SellOrderID
+		} else {
+			tr.PassiveOrderID, tr.AggressorOrderID = tr.SellOrderID, fastOrder
+		}
+		return &domain.Event{Timestamp: ts, Type: domain.EventTradeExecuted, Payload: tr}
+	}
+	m := ComputeFromEvents([]*domain.Event{
+		trade(5, 1, 1, 100.00, true, 1),
+		bbo(10),
+		trade(20, 2, 2, 99.98, true, 4),
+		trade(30, 3, 3, 100.02, false, 2),
+	})["fast"]
+
+	if m.PassiveFills != 2 || m.AggressiveFills != 1 || m.PassiveQty != 5 || m.AggressiveQty != 2 {
+		t.Fatalf("mix = %d/%d fills, %d/%d qty", m.PassiveFills, m.AggressiveFills, m.PassiveQty, m.AggressiveQty)
+	}
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+	if !near(m.PassiveShare, 5.0/7) || !near(m.SpreadCaptured, 0.08) || !near(m.SpreadPaid, 0.04) || !near(m.NetSpread, 0.04) {
+		t.Errorf("share %g, captured %g, paid %g, net %g", m.PassiveShare, m.SpreadCaptured, m.SpreadPaid, m.NetSpread)
+	}
+	// 0.04 over 6 shares at a mid of 100
+	if !near(m.NetSpreadBps, 0.04/600*10_000) {
+		t.Errorf("net spread = %g bps", m.NetSpreadBps)
+	}
+}
+
+// TestMarketQuality weighs each BBO by how long it stood, leaving out
+// the time the book was one-sided
+func TestMarketQuality(t *testing.T) {
+	bbo := func(ts int64, bid, ask float64, qty int64) *domain.Event {
+		b := &domain.BBO{BidPrice: domain.FloatToPrice(bid), BidQty: qty, AskPrice: domain.FloatToPrice(ask), AskQty: qty}
+		if bid > 0 && ask > 0 {
+			b.MidPrice = (b.BidPrice + b.AskPrice) / 2
+		}
+		return &domain.Event{Timestamp: ts, Type: domain.EventBBOUpdate, Payload: b}
+	}
+	var c qualityCollector
+	for _, e := range []*domain.Event{
+		{Type: domain.EventSimStart},
+		bbo(0, 99.98, 0, 10),
+		bbo(10, 99.98, 100.02, 10), // 4 bps, 20 deep, for 20 ns
+		bbo(30, 99.99, 100.01, 15), // 2 bps, 30 deep, for 10 ns
+		{Timestamp: 35, Type: domain.EventTradeExecuted, Payload: &domain.Trade{Qty: 5}},
+		{Timestamp: 40, Type: domain.EventSimEnd},
+	} {
+		c.process(e)
+	}
+	q := c.result()
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+	if !near(q.AvgSpreadBps, 100.0/30) || !near(q.AvgTopDepth, 700.0/30) || !near(q.TwoSidedPct, 75) || q.Trades != 1 || q.Volume != 5 {
+		t.Errorf("quality = %+v", q)
+	}
+}
+
+// TestFlowToxicity cuts 40 shares into four buckets: two one-sided, one
+// balanced, one half and half, with a trade straddling two buckets. With
+// a window of one bucket a trade's toxicity is its buckets' imbalance
+func TestFlowToxicity(t *testing.T) {
+	trades := []tradeRecord{
+		{qty: 10, sign: 1},  // bucket 0: all buys
+		{qty: 5, sign: -1},  // bucket 1: balanced
+		{qty: 10, sign: 1},  // 5 in bucket 1, 5 in bucket 2
+		{qty: 5, sign: -1},  // bucket 2
+		{qty: 10, sign: -1}, // bucket 3: all sells
+	}
+	ft := newFlowToxicity(trades, 4, 1)
+	want := []float64{1, 0, 0, 0, 1}
+	for i, w := range want {
+		if math.Abs(ft.perTrade[i]-w) > 1e-9 {
+			t.Errorf("trade %d toxicity = %g, want %g", i, ft.perTrade[i], w)
+		}
+	}
+	// The two one-sided buckets hold half the volume, more than a third
+	if ft.threshold != 1 || ft.toxicQty != 20 || ft.volume != 40 {
+		t.Errorf("threshold %g, toxic %d of %d", ft.threshold, ft.toxicQty, ft.volume)
+	}
+
+	// A trader filled only in the last bucket is twice as exposed as
+	// the market
+	var m TraderMetrics
+	m.addToxicity([]fillInfo{{trade: 4, fillQty: 4}, {trade: 1, fillQty: 0}}, ft)
+	if m.AvgFillToxicity != 1 || m.ToxicFillShare != 1 || m.ToxicFillRatio != 2 {
+		t.Errorf("avg %g, share %g, ratio %g", m.AvgFillToxicity, m.ToxicFillShare, m.ToxicFillRatio)
+	}
+
+	// A two-bucket window smooths the balanced bucket with the one before
+	if got := newFlowToxicity(trades, 4, 2).perTrade[1]; got != 0.5 {
+		t.Errorf("windowed toxicity = %g, want 0.5", got)
+	}
+}
+
+func TestBackgroundPopulation(t *testing.T) {
+	bbo := func(ts int64, bid, ask float64) *domain.Event {
+		return &domain.Event{Timestamp: ts, Type: domain.EventBBOUpdate, Payload: &domain.BBO{
+			BidPrice: domain.FloatToPrice(bid), AskPrice: domain.FloatToPrice(ask),
+			MidPrice: domain.FloatToPrice((bid + ask) / 2),
+		}}
+	}
+	order := func(ts int64, id uint64, trader string, typ domain.OrderType) *domain.Event {
+		return &domain.Event{Timestamp: ts, Type: domain.EventOrderAccepted, Payload: &domain.Order{
+			ID: id, TraderID: trader, Type: typ, Side: domain.Sell, Price: domain.FloatToPrice(100.01), Qty: 5, RemainingQty: 5,
+		}}
+	}
+	trade := func(ts int64, id, passive uint64, seller string, qty int64) *domain.Event {
+		return &domain.Event{Timestamp: ts, Type: domain.EventTradeExecuted, Payload: &domain.Trade{
+			ID: id, BuyOrderID: 1, SellOrderID: passive, BuyTrader: "fast", SellTrader: seller,
+			Price: domain.FloatToPrice(100.01), Qty: qty, Timestamp: ts, PassiveOrderID: passive, AggressorOrderID: 1,
+		}}
+	}
+	events := []*domain.Event{
+		bbo(0, 100.00, 100.02),
+		order(10, 9001, "background/maker", domain.LimitOrder),
+		order(10, 9002, "background/taker", domain.LimitOrder),
+		order(20, 9003, "background/maker", domain.CancelOrder),
+		{Timestamp: 50, Type: domain.EventOrderAccepted, Payload: &domain.Order{
+			ID: 1, TraderID: "fast", Type: domain.MarketOrder, Side: domain.Buy, Qty: 5, DecisionTime: 40, ArrivalTime: 50,
+		}},
+		trade(50, 1, 9001, "background/maker", 3),
+		trade(50, 2, 9002, "background/taker", 2),
+		// The mid drops a cent, against both of fast's fills
+		bbo(60, 99.99, 100.01),
+		{Timestamp: 500, Type: domain.EventSimEnd},
+	}
+
+	c := NewCollector()
+	for _, e := range events {
+		c.ProcessEvent(e)
+	}
+	bg := c.Background()
+	if len(bg) != 2 || bg[0].TraderID != "background/maker" || bg[1].TraderID != "background/taker" {
+		t.Fatalf("background = %+v", bg)
+	}
+	if m := bg[0]; m.LimitOrders != 1 || m.Cancels != 1 || m.Fills != 1 || m.PassiveQty != 3 || m.Position != -3 || m.QtyVs["fast"] != 3 {
+		t.Errorf("maker = %+v", m)
+	}
+
+	fast := c.Compute()["fast"]
+	if len(fast.Counterparties) != 2 {
+		t.Fatalf("counterparties = %+v", fast.Counterparties)
+	}
+	for _, cp := range fast.Counterparties {
+		// Bought at 100.01 with the mid at 100.00 100ms later, as overall
+		if cp.AdverseSelectionBps >= 0 || cp.AdverseSelectionBps != fast.AdverseSelectionBps {
+			t.Errorf("%s: adverse selection %g bps, want %g", cp.TraderID, cp.AdverseSelectionBps, fast.AdverseSelectionBps)
+		}
+	}
+	if cp := fast.Counterparties[1]; cp.TraderID != "background/taker" || cp.Fills != 1 || cp.Qty != 2 {
+		t.Errorf("taker counterparty = %+v", cp)
+	}
+}
+
+func TestQueueProgress(t *testing.T) {
+	var m TraderMetrics
+	m.addQueueProgress([]fillInfo{
+		{passive: true, placedAheadQty: 30, aheadQty: 10},
+		{passive: true, placedAheadQty: 10},
+		{passive: true},                      // front of the queue from the start
+		{passive: false, placedAheadQty: 99}, // aggressive fills have no queue
+	})
+	if m.AvgAheadQtyPlace != 40.0/3 || m.AvgAheadQtyFill != 10.0/3 || m.QueueProgress != 0.75 {
+		t.Errorf("place %g, fill %g, progress %g", m.AvgAheadQtyPlace, m.AvgAheadQtyFill, m.QueueProgress)
+	}
+}
+
+// TestQueueDist shows the median and front share a deep join can't drag
+// the way it drags the mean
+func TestQueueDist(t *testing.T) {
+	for _, mode := range []quantile.Mode{quantile.ModeExact, quantile.ModeApprox} {
+		e := quantile.New(mode, 0)
+		for _, pos := range []int{1, 40, 1, 2, 1, 1, 3, 1, 2, 1} {
+			e.Add(float64(pos))
+		}
+		d := queueDist(e)
+		if d.Count != 10 || d.P25 != 1 || d.P50 != 1 || d.P90 != 3 || d.FrontPct != 60 {
+			t.Errorf("%s: dist = %+v", mode, d)
+		}
+		if queueDist(quantile.New(mode, 0)) != nil {
+			t.Errorf("%s: empty positions gave a distribution", mode)
+		}
+	}
+}
+
+// TestCancelRaces settles one cancel of each outcome: its target filled
+// while it was in flight, filled in part, or was spared a trade through
+// its price; a cancel no contra flow came near is not a race
+func TestCancelRaces(t *testing.T) {
+	limit := func(ts int64, id uint64, side domain.Side, price float64) *domain.Event {
+		e := domain.NewOrderEvent(ts, &domain.Order{ID: id, TraderID: "slow", Side: side, Type: domain.LimitOrder,
+			Price: domain.FloatToPrice(price), Qty: 5, RemainingQty: 5, DecisionTime: ts, ArrivalTime: ts})
+		return &e
+	}
+	cancel := func(decision, arrival int64, id, target uint64) []*domain.Event {
+		o := &domain.Order{ID: id, TraderID: "slow", Type: domain.CancelOrder, CancelID: target, DecisionTime: decision, ArrivalTime: arrival}
+		accepted, acked := domain.NewOrderEvent(arrival, o), domain.NewCancelEvent(arrival, o)
+		return []*domain.Event{&accepted, &acked}
+	}
+	trade := func(ts int64, id, passive uint64, aggressorSide domain.Side, price float64, qty int64) *domain.Event {
+		tr := &domain.Trade{ID: id, BuyOrderID: passive, SellOrderID: 9000 + id, BuyTrader: "slow", SellTrader: "background",
+			Price: domain.FloatToPrice(price), Qty: qty, Timestamp: ts, PassiveOrderID: passive, AggressorOrderID: 9000 + id}
+		if aggressorSide == domain.Buy {
+			tr.BuyOrderID, tr.SellOrderID, tr.BuyTrader, tr.SellTrader = 9000+id, passive, "background", "slow"
+		}
+		if passive == 0 { // between background traders
+			tr.PassiveOrderID, tr.SellOrderID, tr.SellTrader = 8000+id, 8000+id, "background"
+		}
+		e := domain.NewTradeEvent(ts, tr)
+		return &e
+	}
+	events := []*domain.Event{
+		limit(0, 1, domain.Buy, 100), limit(0, 2, domain.Buy, 100), limit(0, 3, domain.Sell, 101), limit(0, 4, domain.Sell, 105),
+		trade(150, 1, 1, domain.Sell, 100, 5), // picked off in full
+	}
+	events = append(events, cancel(100, 200, 11, 1)...)
+	events = append(events, trade(350, 2, 2, domain.Sell, 100, 2)) // 2 of 5 picked off
+	events = append(events, cancel(300, 400, 12, 2)...)
+	events = append(events, cancel(500, 510, 13, 3)...)
+	events = append(events, trade(520, 3, 0, domain.Buy, 101, 1)) // would have hit order 3
+	events = append(events, cancel(600, 610, 14, 4)...)
+	m := ComputeFromEvents(events)["slow"]
+
+	if m.CancelRaces != 3 || m.CancelRacesWon != 1 || m.CancelRacesLost != 1 || m.CancelRacesPartial != 1 || m.PickedOffQty != 7 {
+		t.Errorf("races %d: won %d, lost %d, partial %d, picked off %d",
+			m.CancelRaces, m.CancelRacesWon, m.CancelRacesLost, m.CancelRacesPartial, m.PickedOffQty)
+	}
+	if m.CancelRaceWinRate != 1.0/3 {
+		t.Errorf("win rate = %g", m.CancelRaceWinRate)
+	}
+}
+
+// TestTTFDecomposition splits each fill's time-to-fill at its order's
+// arrival
+func TestTTFDecomposition(t *testing.T) {
+	order := func(id uint64, decision, arrival int64) *domain.Event {
+		e := domain.NewOrderEvent(arrival, &domain.Order{ID: id, TraderID: "fast", Side: domain.Buy, Type: domain.LimitOrder,
+			Price: domain.FloatToPrice(100), Qty: 1, RemainingQty: 1, DecisionTime: decision, ArrivalTime: arrival})
+		return &e
+	}
+	fill := func(ts int64, id uint64) *domain.Event {
+		e := domain.NewTradeEvent(ts, &domain.Trade{ID: id, BuyOrderID: id, SellOrderID: 9000 + id, BuyTrader: "fast",
+			SellTrader: "background", Price: domain.FloatToPrice(100), Qty: 1, Timestamp: ts,
+			PassiveOrderID: id, AggressorOrderID: 9000 + id})
+		return &e
+	}
+	m := ComputeFromEvents([]*domain.Event{
+		order(1, 100, 110), order(2, 100, 130), fill(150, 1), fill(1_130, 2),
+	})["fast"]
+	if a := m.TTFToArrival; a == nil || a.Count != 2 || a.MinNs != 10 || a.MaxNs != 30 {
+		t.Errorf("decision to arrival = %+v", a)
+	}
+	if v := m.TTFAtVenue; v == nil || v.MinNs != 40 || v.MaxNs != 1_000 || v.MeanNs != 520 {
+		t.Errorf("arrival to fill = %+v", v)
+	}
+}
+
+// TestExclusionWindows leaves an order decided in a window, and its fill,
+// out of the metrics, and measures it alone when asked for the windows
+func TestExclusionWindows(t *testing.T) {
+	order := func(id uint64, decision int64) *domain.Event {
+		e := domain.NewOrderEvent(decision+10, &domain.Order{ID: id, TraderID: "fast", Side: domain.Buy, Type: domain.LimitOrder,
+			Price: domain.FloatToPrice(100), Qty: 1, RemainingQty: 1, DecisionTime: decision, ArrivalTime: decision + 10})
+		return &e
+	}
+	fill := func(ts int64, id uint64) *domain.Event {
+		e := domain.NewTradeEvent(ts, &domain.Trade{ID: id, BuyOrderID: id, SellOrderID: 9000 + id, BuyTrader: "fast",
+			SellTrader: "background", Price: domain.FloatToPrice(100), Qty: 1, Timestamp: ts,
+			PassiveOrderID: id, AggressorOrderID: 9000 + id})
+		return &e
+	}
+	events := []*domain.Event{order(1, 100), order(2, 300), order(3, 320), fill(400, 1), fill(500, 2)}
+	opts := Options{Exclude: []Window{{StartNs: 50, EndNs: 150}}}
+
+	for _, only := range []bool{false, true} {
+		c := NewCollector()
+		c.Options, c.onlyExcluded = opts, only
+		for _, e := range events {
+			c.ProcessEvent(e)
+		}
+		m := c.Compute()["fast"]
+		sent, fills, rate := 2, 1, 0.5
+		if only {
+			sent, fills, rate = 1, 1, 1
+		}
+		if m.OrdersSent != sent || m.TotalFills != fills || m.FillRate != rate {
+			t.Errorf("only excluded %v: %d sent, %d fills, fill rate %g", only, m.OrdersSent, m.TotalFills, m.FillRate)
+		}
+	}
+}
+
+// TestMessaging counts messages per fill and per share, and in the spans
+// of burst-tagged background flow
+func TestMessaging(t *testing.T) {
+	order := func(id uint64, ts int64, typ domain.OrderType) *domain.Event {
+		o := &domain.Order{ID: id, TraderID: "fast", Side: domain.Buy, Type: typ, Qty: 2, RemainingQty: 2,
+			Price: domain.FloatToPrice(100), DecisionTime: ts, ArrivalTime: ts}
+		if typ == domain.CancelOrder {
+			o.CancelID, o.Qty, o.RemainingQty, o.Price = 1, 0, 0, 0
+		}
+		e := domain.NewOrderEvent(ts, o)
+		return &e
+	}
+	fill := func(ts int64, id uint64, qty int64) *domain.Event {
+		e := domain.NewTradeEvent(ts, &domain.Trade{ID: id, BuyOrderID: id, SellOrderID: 9000 + id, BuyTrader: "fast",
+			SellTrader: "background", Price: domain.FloatToPrice(100), Qty: qty, Timestamp: ts,
+			PassiveOrderID: id, AggressorOrderID: 9000 + id})
+		return &e
+	}
+	burst := func(ts int64, id uint64) *domain.Event {
+		e := domain.NewOrderEvent(ts, &domain.Order{ID: id, TraderID: "background", Side: domain.Sell, Type: domain.LimitOrder,
+			Price: domain.FloatToPrice(101), Qty: 1, RemainingQty: 1, DecisionTime: ts, ArrivalTime: ts})
+		e.Annotate("burst_id", "1")
+		return &e
+	}
+	events := []*domain.Event{
+		order(1, 100, domain.LimitOrder), order(2, 200, domain.LimitOrder), order(3, 300, domain.CancelOrder),
+		burst(1_000, 50), order(4, 1_100, domain.LimitOrder), order(5, 1_200, domain.LimitOrder),
+		fill(1_300, 2, 2), burst(1_500, 51), fill(2_000, 4, 1),
+	}
+	m := ComputeFromEvents(events)["fast"]
+	if m.MessagesPerTrade != 2.5 || m.MessagesPerShare != 5.0/3 {
+		t.Errorf("%g messages per trade, %g per share", m.MessagesPerTrade, m.MessagesPerShare)
+	}
+	if m.BurstMessages != 2 || m.BurstFills != 1 || m.BurstMessagesPerTrade != 2 {
+		t.Errorf("in bursts: %d messages, %d fills, %g per trade", m.BurstMessages, m.BurstFills, m.BurstMessagesPerTrade)
+	}
+}
+
+func TestRestingAtClose(t *testing.T) {
+	limit := func(id uint64, ts int64, price float64) *domain.Event {
+		e := domain.NewOrderEvent(ts, &domain.Order{ID: id, TraderID: "slow", Side: domain.Buy, Type: domain.LimitOrder,
+			Price: domain.FloatToPrice(price), Qty: 5, RemainingQty: 5, DecisionTime: ts - 10, ArrivalTime: ts})
+		return &e
+	}
+	fill := func(ts int64, id uint64, qty int64) *domain.Event {
+		e := domain.NewTradeEvent(ts, &domain.Trade{ID: id, BuyOrderID: id, SellOrderID: 9000 + id, BuyTrader: "slow",
+			SellTrader: "background", Price: domain.FloatToPrice(99.95), Qty: qty, Timestamp: ts,
+			PassiveOrderID: id, AggressorOrderID: 9000 + id})
+		return &e
+	}
+	// Order 2 is cut to 3 open by a partial cancel
+	reduce := &domain.Order{ID: 10, TraderID: "slow", Type: domain.CancelOrder, CancelID: 2, Qty: 2, RemainingQty: 3}
+	accepted, acked := domain.NewOrderEvent(250, reduce), domain.NewCancelEvent(250, reduce)
+	bbo := domain.NewBBOEvent(50, &domain.BBO{BidPrice: domain.FloatToPrice(100.00), AskPrice: domain.FloatToPrice(100.10),
+		MidPrice: domain.FloatToPrice(100.05)})
+	events := []*domain.Event{
+		&bbo, limit(1, 100, 99.95), limit(2, 200, 100.00), limit(3, 300, 99.95),
+		&accepted, &acked, fill(400, 1, 2), fill(500, 3, 5),
+		{Timestamp: 1_000, Type: domain.EventSimEnd},
+	}
+
+	for _, cancelAtClose := range []bool{false, true} {
+		c := NewCollector()
+		c.Options.CancelAtClose = cancelAtClose
+		for _, e := range events {
+			c.ProcessEvent(e)
+		}
+		m := c.Compute()["slow"]
+		if m.ExpiredOrders != 2 || m.RestingQty != 6 || m.RestingUnfilled != 1 {
+			t.Errorf("%d resting, %d open qty, %d unfilled; want 2, 6, 1", m.ExpiredOrders, m.RestingQty, m.RestingUnfilled)
+		}
+		if a := m.RestingAge; a == nil || a.MinNs != 800 || a.MaxNs != 900 {
+			t.Errorf("resting age %+v, want 800 to 900 ns", a)
+		}
+		if math.Abs(m.RestingBehindMid-0.075) > 1e-9 {
+			t.Errorf("behind the mid by %g, want 0.075", m.RestingBehindMid)
+		}
+		// Order 2, cut off unfilled, counts against the fill rate unless
+		// it is taken as canceled at the close
+		want := 2.0 / 3
+		if cancelAtClose {
+			want = 1
+		}
+		if m.FillRate != want {
+			t.Errorf("cancel at close %v: fill rate %g, want %g", cancelAtClose, m.FillRate, want)
+		}
+	}
+}
+
+// TestActivityBuckets counts a trader's orders and cancels when the venue
+// accepted them, not when decided, and fills when they traded, over
+// buckets up to the run's end
+func TestActivityBuckets(t *testing.T) {
+	ms := func(n int64) int64 { return n * 1_000_000 }
+	background := &domain.Event{Timestamp: ms(120), Type: domain.EventOrderAccepted, Payload: &domain.Order{
+		ID: 9, TraderID: "background", Side: domain.Sell, Type: domain.LimitOrder, Price: 1_000_000, Qty: 5,
+	}}
+	background.Annotate("burst_id", "1")
+	burstEnd := &domain.Event{Timestamp: ms(180), Type: domain.EventOrderAccepted, Payload: &domain.Order{
+		ID: 10, TraderID: "background", Side: domain.Sell, Type: domain.MarketOrder, Qty: 1,
+	}}
+	burstEnd.Annotate("burst_id", "1")
+	events := []*domain.Event{
+		background,
+		// Decided in the first bucket, accepted in the second
+		{Timestamp: ms(150), Type: domain.EventOrderAccepted, Payload: &domain.Order{
+			ID: 1, TraderID: "slow", Side: domain.Buy, Type: domain.LimitOrder, Price: 1_000_000, Qty: 5,
+			DecisionTime: ms(90), ArrivalTime: ms(150),
+		}},
+		burstEnd,
+		{Timestamp: ms(180), Type: domain.EventTradeExecuted, Payload: &domain.Trade{
+			ID: 1, BuyOrderID: 1, SellOrderID: 10, BuyTrader: "slow", SellTrader: "background",
+			Price: 1_000_000, Qty: 1, Timestamp: ms(180), PassiveOrderID: 1, AggressorOrderID: 10,
+		}},
+		{Timestamp: ms(250), Type: domain.EventOrderAccepted, Payload: &domain.Order{
+			ID: 2, TraderID: "slow", Side: domain.Buy, Type: domain.CancelOrder, Price: 1_000_000, CancelID: 1,
+			DecisionTime: ms(240), ArrivalTime: ms(250),
+		}},
+		{Timestamp: ms(300), Type: domain.EventSimEnd},
+	}
+	a := ComputeFromEvents(events)["slow"].Activity
+	if a == nil || a.BucketNs != ActivityBucketNs {
+		t.Fatalf("activity = %+v", a)
+	}
+	if !slices.Equal(a.Orders, []int{0, 1, 0}) || !slices.Equal(a.Cancels, []int{0, 0, 1}) || !slices.Equal(a.Fills, []int{0, 1, 0}) {
+		t.Errorf("orders %v, cancels %v, fills %v", a.Orders, a.Cancels, a.Fills)
+	}
+	if len(a.Bursts) != 1 || a.Bursts[0].StartNs != ms(120) || a.Bursts[0].EndNs != ms(180)+1 ||
+		a.InBurst(0) || !a.InBurst(1) || a.InBurst(2) {
+		t.Errorf("bursts %+v", a.Bursts)
+	}
+}
+
+// TestRequoteChains links cancels to the orders that replace them and
+// times the trader at the inside
+func TestRequoteChains(t *testing.T) {
+	ms := func(n int64) int64 { return n * 1_000_000 }
+	limit := func(decision, arrival int64, id uint64, side domain.Side, price float64) *domain.Event {
+		e := domain.NewOrderEvent(ms(arrival), &domain.Order{ID: id, TraderID: "fast", Side: side, Type: domain.LimitOrder,
+			Price: domain.FloatToPrice(price), Qty: 5, RemainingQty: 5, DecisionTime: ms(decision), ArrivalTime: ms(arrival)})
+		return &e
+	}
+	cancel := func(decision, arrival int64, id, target uint64) []*domain.Event {
+		o := &domain.Order{ID: id, TraderID: "fast", Type: domain.CancelOrder, CancelID: target,
+			DecisionTime: ms(decision), ArrivalTime: ms(arrival)}
+		accepted, acked := domain.NewOrderEvent(ms(arrival), o), domain.NewCancelEvent(ms(arrival), o)
+		return []*domain.Event{&accepted, &acked}
+	}
+	bbo := domain.NewBBOEvent(0, &domain.BBO{BidPrice: domain.FloatToPrice(100), AskPrice: domain.FloatToPrice(101),
+		MidPrice: domain.FloatToPrice(100.5)})
+	end := domain.Event{Timestamp: ms(3000), Type: domain.EventSimEnd}
+
+	events := []*domain.Event{&bbo, limit(0, 0, 1, domain.Buy, 100), limit(0, 0, 2, domain.Sell, 102)}
+	events = append(events, cancel(100, 110, 3, 1)...)
+	events = append(events, limit(150, 160, 4, domain.Buy, 100)) // replaces 1
+	events = append(events, cancel(200, 210, 5, 4)...)
+	events = append(events, limit(220, 230, 6, domain.Buy, 99)) // replaces 4, behind the bid
+	events = append(events, cancel(300, 310, 7, 2)...)
+	events = append(events, limit(1400, 1410, 8, domain.Sell, 102)) // too late to replace 2
+	events = append(events, &end)
+	m := ComputeFromEvents(events)["fast"]
+
+	if m.Requotes != 2 || m.RequoteChains != 1 || m.LongestRequoteChain != 2 {
+		t.Errorf("requotes %d in %d chains, longest %d", m.Requotes, m.RequoteChains, m.LongestRequoteChain)
+	}
+	if rtt := m.RequoteRTT; rtt == nil || rtt.Count != 2 || rtt.MinNs != ms(30) || rtt.MaxNs != ms(60) {
+		t.Errorf("round trips %+v", rtt)
+	}
+	// At the bid from 0 to the first cancel and from the replacement to
+	// the second
+	if want := float64(ms(110)+ms(50)) / float64(ms(3000)); m.TimeAtInside != want {
+		t.Errorf("time at inside = %g, want %g", m.TimeAtInside, want)
+	}
+}
+
+// countingMetric counts each trader's account updates
+type countingMetric map[string]float64
+
+func (m countingMetric) ProcessEvent(e *domain.Event) {
+	if acct := e.Account(); e.Type == domain.EventAccountUpdate && acct != nil {
+		m[acct.TraderID]++
+	}
+}
+
+func (m countingMetric) Finalize() map[string]float64 { return m }
+
+// TestCustomMetrics checks a registered plugin and a built-in one land in
+// Custom, that groups left out of the selection stay zero, and that
+// unknown groups and plugins are rejected
+func TestCustomMetrics(t *testing.T) {
+	Register("test_account_updates", func(domain.Scale) Metric { return make(countingMetric) })
+	var events []*domain.Event
+	for i, pnl := range []int64{300, 100, 400} {
+		update := domain.NewAccountEvent(int64(i+1)*100, &domain.Account{TraderID: "fast", RealizedPnL: pnl, Position: int64(-3 * i)})
+		events = append(events, &update)
+	}
+	opts := Options{Groups: []string{GroupRaces}, Custom: []string{"test_account_updates", "max_drawdown", "max_position"}}
+	m, err := ComputeEvents(events, 0, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast := m["fast"]
+	if got := fast.Custom["test_account_updates"]; got != 3 {
+		t.Errorf("test plugin = %v, want 3 account updates", got)
+	}
+	if got := fast.Custom["max_drawdown"]; got != 0.02 {
+		t.Errorf("max_drawdown = %v, want 0.02 (300 down to 100)", got)
+	}
+	if got := fast.Custom["max_position"]; got != 6 {
+		t.Errorf("max_position = %v, want 6 (short)", got)
+	}
+	if fast.Activity != nil {
+		t.Error("activity computed though the options left its group out")
+	}
+	if all, _ := ComputeEvents(events, 0, Options{}); all["fast"].Activity == nil || all["fast"].Custom != nil {
+		t.Error("default options should compute activity and no custom metrics")
+	}
+
+	for _, bad := range []Options{{Groups: []string{"latency"}}, {Custom: []string{"nope"}}, {Custom: []string{"max_position", "max_position"}}} {
+		if bad.Validate() == nil {
+			t.Errorf("%+v validated", bad)
+		}
+	}
+}
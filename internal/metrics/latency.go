@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/quantile"
+)
+
+// Realized latency is arrival_time − decision_time for each message a
+// trader sent, kept by message type. Every message goes through the
+// trader's latency model, so each should land in the configured
+// [base, base+jitter); one that does not means latency was applied wrongly
+// somewhere between the strategy and the venue
+
+// LatencyStats summarizes one trader's realized latency for one message
+// type, in nanoseconds
+type LatencyStats struct {
+	Count  int     `json:"count"`
+	MinNs  int64   `json:"min_ns"`
+	P50Ns  int64   `json:"p50_ns"`
+	P99Ns  int64   `json:"p99_ns"`
+	MaxNs  int64   `json:"max_ns"`
+	MeanNs float64 `json:"mean_ns"`
+}
+
+// latencyKey names a message type in TraderMetrics.RealizedLatency
+func latencyKey(t domain.OrderType) string {
+	switch t {
+	case domain.LimitOrder:
+		return "limit"
+	case domain.MarketOrder:
+		return "market"
+	case domain.CancelOrder:
+		return "cancel"
+	}
+	return "unknown"
+}
+
+// latencyStats summarizes the samples in e, or returns nil if there are
+// none
+func latencyStats(e quantile.Estimator) *LatencyStats {
+	if e == nil || e.Count() == 0 {
+		return nil
+	}
+	// A sketch's estimates fall between nanoseconds
+	at := func(p float64) int64 { return int64(math.Round(e.Lower(p))) }
+	return &LatencyStats{
+		Count:  e.Count(),
+		MinNs:  int64(e.Min()),
+		P50Ns:  at(0.50),
+		P99Ns:  at(0.99),
+		MaxNs:  int64(e.Max()),
+		MeanNs: e.Mean(),
+	}
+}
+
+// minJitterSamples is how many samples it takes before a spread under
+// half the jitter bound is suspicious rather than chance
+const minJitterSamples = 20
+
+// Check compares realized latency with a configured base and jitter bound
+// and describes each discrepancy, or returns nil if there is none
+func (s *LatencyStats) Check(baseNs, jitterNs int64) []string {
+	if s == nil || s.Count == 0 {
+		return nil
+	}
+	var issues []string
+	if s.MinNs < baseNs {
+		issues = append(issues, fmt.Sprintf("min %d ns below the %d ns base", s.MinNs, baseNs))
+	}
+	hi := baseNs + jitterNs // exclusive when there is jitter
+	if jitterNs == 0 && s.MaxNs > hi || jitterNs > 0 && s.MaxNs >= hi {
+		issues = append(issues, fmt.Sprintf("max %d ns beyond the %d ns bound", s.MaxNs, hi))
+	}
+	if jitterNs > 0 && s.Count >= minJitterSamples && s.MaxNs-s.MinNs < jitterNs/2 {
+		issues = append(issues, fmt.Sprintf("spread %d ns under half the %d ns jitter", s.MaxNs-s.MinNs, jitterNs))
+	}
+	return issues
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+)
+
+// Requirement is the event log level one family of metrics reads
+type Requirement struct {
+	Metrics string
+	Level   eventlog.Level
+}
+
+// Requirements declares what each family of metrics needs from the log.
+// ComputeFromLog refuses a log below the highest of them
+var Requirements = []Requirement{
+	{"fills and volume", eventlog.LevelTrades},
+	{"fill rate, latency, queue position and priority", eventlog.LevelOrders},
+	{"slippage, spread cost and adverse selection", eventlog.LevelBBO},
+	{"signal races, fees and PnL", eventlog.LevelFull},
+}
+
+// RequiredLevel is the lowest log level every metric can be computed from
+func RequiredLevel() eventlog.Level {
+	var need eventlog.Level
+	for _, r := range Requirements {
+		need = max(need, r.Level)
+	}
+	return need
+}
+
+// CheckLevel returns a *eventlog.LevelError naming the metrics a log at
+// level have can't support, or nil
+func CheckLevel(have eventlog.Level) error {
+	var missing []string
+	for _, r := range Requirements {
+		if r.Level > have {
+			missing = append(missing, fmt.Sprintf("%s need %s", r.Metrics, r.Level))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return &eventlog.LevelError{Consumer: "metrics", Need: RequiredLevel(), Have: have, Why: strings.Join(missing, "; ")}
+}
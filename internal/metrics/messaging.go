@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// Messaging efficiency is how much traffic a trader puts on the venue for
+// what it gets done: every order, cancel and reduction it sends, per
+// fill and per filled share, the order-to-trade ratio a venue's throttles
+// and message fees are set against. Bursts are read off the log as the
+// span from the first to the last background order tagged with each
+// burst_id, so a log replays to the same counts without its config
+
+// span is a burst's extent in the log, inclusive
+type span struct{ start, end int64 }
+
+// processBurst widens the span of the burst an event is tagged with
+func (c *Collector) processBurst(event *domain.Event) {
+	id, ok := event.Annotation("burst_id")
+	if !ok {
+		return
+	}
+	if c.bursts == nil {
+		c.bursts = make(map[string]*span)
+	}
+	if s := c.bursts[id]; s != nil {
+		s.start, s.end = min(s.start, event.Timestamp), max(s.end, event.Timestamp)
+		return
+	}
+	c.bursts[id] = &span{event.Timestamp, event.Timestamp}
+}
+
+// inBurst reports whether ns falls in any burst
+func (c *Collector) inBurst(ns int64) bool {
+	for _, s := range detorder.All(c.bursts) {
+		if ns >= s.start && ns <= s.end {
+			return true
+		}
+	}
+	return false
+}
+
+// addMessaging sets the order-to-trade ratios, counting messages by when
+// the trader sent them and fills by when they traded
+func (m *TraderMetrics) addMessaging(c *Collector, a *traderAccum) {
+	if m.TotalFills > 0 {
+		m.MessagesPerTrade = float64(m.OrdersSent) / float64(m.TotalFills)
+	}
+	if m.TotalQtyFilled > 0 {
+		m.MessagesPerShare = float64(m.OrdersSent) / float64(m.TotalQtyFilled)
+	}
+	if len(c.bursts) == 0 {
+		return
+	}
+	for _, ns := range a.sent {
+		if c.inBurst(ns) {
+			m.BurstMessages++
+		}
+	}
+	for _, f := range a.fills {
+		if c.inBurst(f.fillTime) {
+			m.BurstFills++
+		}
+	}
+	if m.BurstFills > 0 {
+		m.BurstMessagesPerTrade = float64(m.BurstMessages) / float64(m.BurstFills)
+	}
+}
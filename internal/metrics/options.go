@@ -0,0 +1,206 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/quantile"
+)
+
+// Benchmarks slippage can be measured against
+const (
+	BenchDecisionMid = "decision_mid" // mid when the trader decided (default)
+	BenchArrivalMid  = "arrival_mid"  // mid when the order reached the venue
+	BenchTouch       = "touch"        // far touch when the trader decided: the ask for a buy, the bid for a sell
+	BenchVWAP        = "vwap"         // volume-weighted price of every trade in the run
+)
+
+// DefaultHorizonNs is the markout horizon when none is configured
+const DefaultHorizonNs = int64(100_000_000)
+
+// Options choose how execution quality is measured. The zero value is
+// slippage against the decision-time mid and a single 100 ms markout
+type Options struct {
+	// Benchmark is the reference price for slippage, one of the Bench*
+	// constants; empty means BenchDecisionMid
+	Benchmark string `json:"benchmark,omitempty"`
+
+	// HorizonsNs are the markout horizons for adverse selection. The
+	// first is the headline AdverseSelectionBps; empty means 100 ms
+	HorizonsNs []int64 `json:"horizons_ns,omitempty"`
+
+	// ToxicityBuckets splits the run's traded volume into this many
+	// equal buckets, and ToxicityWindow is how many of them flow
+	// toxicity averages over (see toxicity.go); zero means the defaults
+	ToxicityBuckets int `json:"toxicity_buckets,omitempty"`
+	ToxicityWindow  int `json:"toxicity_window,omitempty"`
+
+	// CancelRaceWindowNs is how soon after a cancel decision contra flow
+	// must reach the target for the cancel to be in a race (see
+	// cancelrace.go); zero means 100 ms
+	CancelRaceWindowNs int64 `json:"cancel_race_window_ns,omitempty"`
+
+	// Exclude are windows whose orders the metrics leave out (see
+	// windows.go); ComputeExcludedFromLog measures them on their own
+	Exclude []Window `json:"exclude,omitempty"`
+
+	// Percentiles chooses how distributions are summarized: exact keeps
+	// and sorts every sample, approx streams them into a sketch, and auto
+	// (the default) is exact until a distribution outgrows
+	// quantile.DefaultExactLimit samples
+	Percentiles string `json:"percentiles,omitempty"`
+
+	// CancelAtClose treats orders still resting at the close as canceled
+	// there, so the ones left unfilled drop out of the fill rate rather
+	// than counting against it (see resting.go)
+	CancelAtClose bool `json:"cancel_at_close,omitempty"`
+
+	// Groups limits the optional metric groups computed to those listed
+	// (see plugin.go); empty computes them all
+	Groups []string `json:"groups,omitempty"`
+
+	// Custom are the registered metric plugins to run, by name (see
+	// plugin.go); their values land in TraderMetrics.Custom
+	Custom []string `json:"custom,omitempty"`
+}
+
+// Validate rejects an unknown benchmark, a non-positive or repeated
+// horizon, an unknown metric group and an unregistered plugin
+func (o Options) Validate() error {
+	switch o.Benchmark {
+	case "", BenchDecisionMid, BenchArrivalMid, BenchTouch, BenchVWAP:
+	default:
+		return fmt.Errorf("unknown slippage benchmark %q (%s, %s, %s, %s)",
+			o.Benchmark, BenchDecisionMid, BenchArrivalMid, BenchTouch, BenchVWAP)
+	}
+	seen := make(map[int64]bool)
+	for _, h := range o.HorizonsNs {
+		if h <= 0 {
+			return fmt.Errorf("markout horizons must be positive")
+		}
+		if seen[h] {
+			return fmt.Errorf("markout horizon %s given twice", time.Duration(h))
+		}
+		seen[h] = true
+	}
+	if o.ToxicityBuckets < 0 || o.ToxicityWindow < 0 {
+		return fmt.Errorf("toxicity buckets and window must not be negative")
+	}
+	if buckets, window := o.toxicity(); window > buckets {
+		return fmt.Errorf("toxicity window of %d buckets is longer than the run's %d", window, buckets)
+	}
+	if o.CancelRaceWindowNs < 0 {
+		return fmt.Errorf("cancel race window must not be negative")
+	}
+	if _, err := quantile.ParseMode(o.Percentiles); err != nil {
+		return err
+	}
+	for _, w := range o.Exclude {
+		if w.StartNs < 0 || w.EndNs <= w.StartNs {
+			return fmt.Errorf("exclusion window %s to %s must start at 0 or later and end after it",
+				time.Duration(w.StartNs), time.Duration(w.EndNs))
+		}
+	}
+	return o.validateSelection()
+}
+
+// BenchmarkName is the benchmark in effect
+func (o Options) BenchmarkName() string {
+	if o.Benchmark == "" {
+		return BenchDecisionMid
+	}
+	return o.Benchmark
+}
+
+// Horizons are the markout horizons in effect, headline first
+func (o Options) Horizons() []int64 {
+	if len(o.HorizonsNs) == 0 {
+		return []int64{DefaultHorizonNs}
+	}
+	return o.HorizonsNs
+}
+
+// CancelRaceWindow is the cancel race window in effect
+func (o Options) CancelRaceWindow() int64 {
+	if o.CancelRaceWindowNs > 0 {
+		return o.CancelRaceWindowNs
+	}
+	return DefaultCancelRaceWindowNs
+}
+
+// estimator returns an empty estimator in the percentile mode in effect
+func (o Options) estimator() quantile.Estimator {
+	mode, _ := quantile.ParseMode(o.Percentiles)
+	return quantile.New(mode, 0)
+}
+
+// toxicity is the bucket count and window in effect
+func (o Options) toxicity() (buckets, window int) {
+	buckets, window = DefaultToxicityBuckets, DefaultToxicityWindow
+	if o.ToxicityBuckets > 0 {
+		buckets = o.ToxicityBuckets
+	}
+	if o.ToxicityWindow > 0 {
+		window = o.ToxicityWindow
+	}
+	return buckets, window
+}
+
+// UnmarshalJSON accepts horizons, the cancel race window and exclusion
+// window bounds as nanosecond counts or duration strings such as "100ms"
+func (o *Options) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Benchmark          string            `json:"benchmark"`
+		HorizonsNs         []json.RawMessage `json:"horizons_ns"`
+		ToxicityBuckets    int               `json:"toxicity_buckets"`
+		ToxicityWindow     int               `json:"toxicity_window"`
+		CancelRaceWindowNs json.RawMessage   `json:"cancel_race_window_ns"`
+		Exclude            []Window          `json:"exclude"`
+		Percentiles        string            `json:"percentiles"`
+		CancelAtClose      bool              `json:"cancel_at_close"`
+		Groups             []string          `json:"groups"`
+		Custom             []string          `json:"custom"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&aux); err != nil {
+		return err
+	}
+	*o = Options{Benchmark: aux.Benchmark, ToxicityBuckets: aux.ToxicityBuckets, ToxicityWindow: aux.ToxicityWindow,
+		Exclude: aux.Exclude, Percentiles: aux.Percentiles, CancelAtClose: aux.CancelAtClose,
+		Groups: aux.Groups, Custom: aux.Custom}
+	for _, raw := range aux.HorizonsNs {
+		ns, err := parseNanos(raw, "horizon")
+		if err != nil {
+			return err
+		}
+		o.HorizonsNs = append(o.HorizonsNs, ns)
+	}
+	if aux.CancelRaceWindowNs != nil {
+		ns, err := parseNanos(aux.CancelRaceWindowNs, "cancel race window")
+		if err != nil {
+			return err
+		}
+		o.CancelRaceWindowNs = ns
+	}
+	return nil
+}
+
+// parseNanos reads a nanosecond count or a duration string
+func parseNanos(raw json.RawMessage, what string) (int64, error) {
+	var ns int64
+	if err := json.Unmarshal(raw, &ns); err == nil {
+		return ns, nil
+	}
+	var s string
+	if json.Unmarshal(raw, &s) != nil {
+		return 0, fmt.Errorf("%s %s: want nanoseconds or a duration string", what, raw)
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", what, err)
+	}
+	return int64(d), nil
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// Metric is a custom metric plugin. It is shown every event of the log,
+// in order, as the built-in metrics are, and reports one value per
+// trader at the end. A study that needs a bespoke metric registers one
+// under a name, from an init function, and turns it on by listing that
+// name in its config's metrics.custom, instead of forking the Collector
+type Metric interface {
+	// ProcessEvent ingests one event. It must not keep e, which may be
+	// recycled
+	ProcessEvent(e *domain.Event)
+	// Finalize returns the metric's value for each trader, by ID. Values
+	// for traders the collector does not track, such as background
+	// ones, are dropped
+	Finalize() map[string]float64
+}
+
+// Factory makes a fresh instance of a plugin for one log, whose prices
+// are at scale
+type Factory func(scale domain.Scale) Metric
+
+var plugins = make(map[string]Factory)
+
+// Register makes a plugin available under name. Like database/sql's
+// Register, it panics if the name is empty or already taken
+func Register(name string, f Factory) {
+	if name == "" || f == nil {
+		panic("metrics: Register needs a name and a factory")
+	}
+	if _, dup := plugins[name]; dup {
+		panic("metrics: plugin " + name + " registered twice")
+	}
+	plugins[name] = f
+}
+
+// Plugins lists the registered plugins' names, sorted
+func Plugins() []string {
+	return detorder.Keys(plugins)
+}
+
+// Optional metric groups. The core metrics (orders, fills, fill rate,
+// slippage, markouts, time-to-fill, queue position, PnL) are always
+// computed; a config's metrics.groups limits the rest to the groups it
+// lists, leaving the others' fields zero and their report sections out
+const (
+	GroupRaces       = "races"        // signal races (races.go)
+	GroupCancelRaces = "cancel_races" // fill-versus-cancel races (cancelrace.go)
+	GroupPriority    = "priority"     // priority inversions (priority.go)
+	GroupToxicity    = "toxicity"     // flow toxicity (toxicity.go)
+	GroupRequotes    = "requotes"     // cancel-replace chains and time at the inside (requote.go)
+	GroupActivity    = "activity"     // activity per bucket (activity.go)
+)
+
+// Groups are the optional metric groups, in the order they are listed
+var Groups = []string{GroupRaces, GroupCancelRaces, GroupPriority, GroupToxicity, GroupRequotes, GroupActivity}
+
+// Computes reports whether the options compute an optional group: every
+// group does unless Groups is set
+func (o Options) Computes(group string) bool {
+	return len(o.Groups) == 0 || slices.Contains(o.Groups, group)
+}
+
+// validateSelection rejects unknown groups and unregistered plugins
+func (o Options) validateSelection() error {
+	for _, g := range o.Groups {
+		if !slices.Contains(Groups, g) {
+			return fmt.Errorf("unknown metric group %q (%s)", g, strings.Join(Groups, ", "))
+		}
+	}
+	seen := make(map[string]bool)
+	for _, name := range o.Custom {
+		if plugins[name] == nil {
+			return fmt.Errorf("unknown custom metric %q (registered: %s)", name, strings.Join(Plugins(), ", "))
+		}
+		if seen[name] {
+			return fmt.Errorf("custom metric %q listed twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// runningPlugin is one custom metric at work in a Collector
+type runningPlugin struct {
+	name   string
+	metric Metric
+}
+
+// startPlugins instantiates the configured plugins, once, before the
+// first event
+func (c *Collector) startPlugins(scale domain.Scale) {
+	c.pluginsStarted = true
+	if scale == 0 {
+		scale = domain.DefaultScale
+	}
+	for _, name := range c.Options.Custom {
+		if f := plugins[name]; f != nil {
+			c.plugins = append(c.plugins, runningPlugin{name: name, metric: f(scale)})
+		}
+	}
+}
+
+// addCustom records each plugin's value for the trader
+func (m *TraderMetrics) addCustom(values map[string]map[string]float64) {
+	for name, byTrader := range detorder.All(values) {
+		v, ok := byTrader[m.TraderID]
+		if !ok {
+			continue
+		}
+		if m.Custom == nil {
+			m.Custom = make(map[string]float64)
+		}
+		m.Custom[name] = v
+	}
+}
@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// A priority inversion is a pair of resting orders at the same side and
+// price where the one that arrived later got a passive fill while the
+// earlier one still had quantity left. Price-time priority never does
+// this; pro-rata matching and batch auctions do, and a speed bump can
+// when a held order rests behind ones that came in meanwhile. Arrival is
+// the order's arrival_time at the venue, so an order a mechanism held
+// back keeps its place in the comparison. An iceberg's refresh gives its
+// place up, so from then on it compares as arriving at the refresh. Each
+// pair counts once
+
+// priorityOrder is a resting order as the inversion tracker sees it
+type priorityOrder struct {
+	id        uint64
+	trader    string
+	arrival   int64
+	seq       int // log order, to break arrival ties
+	remaining int64
+}
+
+// passiveFill is an order's first passive fill at a timestamp, with its
+// place in arrival order as it stood then
+type passiveFill struct {
+	id      uint64
+	arrival int64
+	seq     int
+}
+
+type priorityLevel struct {
+	side  domain.Side
+	price int64
+}
+
+// priorityTracker finds inversions. Fills are judged once every event at
+// their timestamp is in, since a batch auction logs the orders it crosses
+// one at a time and an earlier arrival may be logged after a later one
+type priorityTracker struct {
+	orders map[uint64]*priorityOrder
+	level  map[uint64]priorityLevel
+	levels map[priorityLevel][]*priorityOrder
+	seq    int
+	ts     int64
+	filled []passiveFill // passive orders filled at ts, first fill order
+	pairs  map[[2]uint64]bool
+	jumped map[string]map[string]int // jumped trader -> jumper trader -> pairs
+}
+
+func newPriorityTracker() *priorityTracker {
+	return &priorityTracker{
+		orders: make(map[uint64]*priorityOrder),
+		level:  make(map[uint64]priorityLevel),
+		levels: make(map[priorityLevel][]*priorityOrder),
+		pairs:  make(map[[2]uint64]bool),
+		jumped: make(map[string]map[string]int),
+	}
+}
+
+func (p *priorityTracker) process(event *domain.Event) {
+	if event.Timestamp != p.ts {
+		p.flush()
+		p.ts = event.Timestamp
+	}
+	switch event.Type {
+	case domain.EventOrderAccepted:
+		o := event.Order()
+		// IOC and FOK orders never wait in the queue
+		if o == nil || o.Type != domain.LimitOrder || o.RemainingQty <= 0 || o.Immediate() {
+			return
+		}
+		arrival := o.ArrivalTime
+		if arrival == 0 {
+			arrival = event.Timestamp
+		}
+		p.seq++
+		po := &priorityOrder{id: o.ID, trader: o.TraderID, arrival: arrival, seq: p.seq, remaining: o.RemainingQty}
+		lv := priorityLevel{o.Side, o.Price}
+		p.orders[o.ID] = po
+		p.level[o.ID] = lv
+		p.levels[lv] = append(p.levels[lv], po)
+	case domain.EventTradeExecuted:
+		tr := event.Trade()
+		if tr == nil {
+			return
+		}
+		if po, ok := p.orders[tr.PassiveOrderID]; ok {
+			if po.remaining > 0 && !p.filledAt(po.id) {
+				p.filled = append(p.filled, passiveFill{po.id, po.arrival, po.seq})
+			}
+			po.remaining -= tr.Qty
+		}
+	case domain.EventOrderCanceled:
+		if c := event.Cancel(); c != nil {
+			if po, ok := p.orders[c.CancelID]; ok {
+				po.remaining = c.RemainingQty
+			}
+		}
+	case domain.EventIcebergRefresh:
+		if po, ok := p.orders[event.Order().ID]; ok {
+			p.seq++
+			po.arrival, po.seq = event.Timestamp, p.seq
+		}
+	}
+}
+
+func (p *priorityTracker) filledAt(id uint64) bool {
+	for _, f := range p.filled {
+		if f.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// flush judges the fills at the current timestamp, then drops orders
+// that are done
+func (p *priorityTracker) flush() {
+	for _, f := range p.filled {
+		b := p.orders[f.id]
+		lv := p.level[f.id]
+		for _, a := range p.levels[lv] {
+			if a == b || a.remaining <= 0 || !(a.arrival < f.arrival || a.arrival == f.arrival && a.seq < f.seq) {
+				continue
+			}
+			key := [2]uint64{a.id, b.id}
+			if p.pairs[key] {
+				continue
+			}
+			p.pairs[key] = true
+			if p.jumped[a.trader] == nil {
+				p.jumped[a.trader] = make(map[string]int)
+			}
+			p.jumped[a.trader][b.trader]++
+		}
+	}
+	if len(p.filled) == 0 {
+		return
+	}
+	touched := make(map[priorityLevel]bool)
+	for _, f := range p.filled {
+		touched[p.level[f.id]] = true
+	}
+	p.filled = p.filled[:0]
+	for lv := range touched {
+		p.compact(lv)
+	}
+}
+
+// compact drops a level's finished orders, including ones canceled since
+// the level was last compacted
+func (p *priorityTracker) compact(lv priorityLevel) {
+	live := p.levels[lv][:0]
+	for _, o := range p.levels[lv] {
+		if o.remaining > 0 {
+			live = append(live, o)
+			continue
+		}
+		delete(p.orders, o.id)
+		delete(p.level, o.id)
+	}
+	if len(live) == 0 {
+		delete(p.levels, lv)
+		return
+	}
+	p.levels[lv] = live
+}
+
+// jumps returns how many pairs trader's orders jumped, over all victims
+func (p *priorityTracker) jumps(trader string) int {
+	n := 0
+	for _, by := range p.jumped {
+		n += by[trader]
+	}
+	return n
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+)
+
+// MarketQuality measures the market as a whole rather than any trader:
+// what it cost to trade and how much could be traded. Spread and depth
+// are time-weighted over the time the book was two-sided
+type MarketQuality struct {
+	AvgSpreadBps float64 `json:"avg_spread_bps"` // quoted spread, bps of the mid
+	AvgTopDepth  float64 `json:"avg_top_depth"`  // bid qty + ask qty at the top
+	TwoSidedPct  float64 `json:"two_sided_pct"`  // share of the run the book was two-sided
+	Trades       int     `json:"trades"`
+	Volume       int64   `json:"volume"`
+}
+
+// qualityCollector accumulates a MarketQuality over a log in order
+type qualityCollector struct {
+	q        MarketQuality
+	bbo      domain.BBO
+	since    int64 // when bbo took effect
+	end      int64
+	twoSided int64 // ns the book was two-sided
+	spread   float64
+	depth    float64
+}
+
+func (c *qualityCollector) process(e *domain.Event) {
+	switch e.Type {
+	case domain.EventBBOUpdate:
+		c.hold(e.Timestamp)
+		if b := e.BBO(); b != nil {
+			c.bbo = *b
+		}
+	case domain.EventTradeExecuted:
+		if tr := e.Trade(); tr != nil {
+			c.q.Trades++
+			c.q.Volume += tr.Qty
+		}
+	case domain.EventSimEnd:
+		c.hold(e.Timestamp)
+	}
+	c.end = e.Timestamp
+}
+
+// hold credits the BBO in force until now
+func (c *qualityCollector) hold(now int64) {
+	if dt := now - c.since; dt > 0 && c.bbo.BidPrice > 0 && c.bbo.AskPrice > 0 && c.bbo.MidPrice > 0 {
+		c.twoSided += dt
+		c.spread += float64(c.bbo.AskPrice-c.bbo.BidPrice) / float64(c.bbo.MidPrice) * 10_000 * float64(dt)
+		c.depth += float64(c.bbo.BidQty+c.bbo.AskQty) * float64(dt)
+	}
+	c.since = now
+}
+
+func (c *qualityCollector) result() *MarketQuality {
+	q := c.q
+	if c.twoSided > 0 {
+		q.AvgSpreadBps = c.spread / float64(c.twoSided)
+		q.AvgTopDepth = c.depth / float64(c.twoSided)
+	}
+	if c.end > 0 {
+		q.TwoSidedPct = float64(c.twoSided) / float64(c.end) * 100
+	}
+	return &q
+}
+
+// ComputeMarketQuality measures the market of the log at logPath, which
+// must record BBO updates
+func ComputeMarketQuality(logPath string) (*MarketQuality, error) {
+	reader, err := eventlog.NewReader(logPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	if err := reader.Require(eventlog.LevelBBO, "market quality"); err != nil {
+		return nil, err
+	}
+	var c qualityCollector
+	err = reader.Each(func(event *domain.Event) error {
+		c.process(event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.result(), nil
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"math"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/quantile"
+)
+
+// QueueDist is the spread of a trader's queue positions, where 1 is the
+// front. Averages are dragged up by a few deep joins; the quartiles and
+// the share at the front show how often the trader was first in line
+type QueueDist struct {
+	Count    int     `json:"count"`
+	P25      int     `json:"p25"`
+	P50      int     `json:"p50"`
+	P90      int     `json:"p90"`
+	FrontPct float64 `json:"front_pct"` // share at position 1
+}
+
+// QueueFrontPct is the share of the trader's resting limit orders that
+// joined at the front of the queue, 0 if none rested
+func (m *TraderMetrics) QueueFrontPct() float64 {
+	if m.QueuePosPlace == nil {
+		return 0
+	}
+	return m.QueuePosPlace.FrontPct
+}
+
+// queueDist summarizes the positions in e, or returns nil if there are
+// none
+func queueDist(e quantile.Estimator) *QueueDist {
+	if e.Count() == 0 {
+		return nil
+	}
+	// A sketch's estimates fall between positions
+	at := func(p float64) int { return int(math.Round(e.Lower(p))) }
+	return &QueueDist{
+		Count:    e.Count(),
+		P25:      at(0.25),
+		P50:      at(0.50),
+		P90:      at(0.90),
+		FrontPct: 100 * float64(e.Below(2)) / float64(e.Count()),
+	}
+}
+
+// addQueueProgress averages what stood ahead of a trader's passive fills
+// when their orders were placed and when they filled. Progress is the
+// share of the qty ahead at placement that traded or was canceled before
+// the fill, summed over the fills that had any ahead
+func (m *TraderMetrics) addQueueProgress(fills []fillInfo) {
+	var n int
+	var placed, left, progressed, ahead int64
+	for _, f := range fills {
+		if !f.passive {
+			continue
+		}
+		n++
+		placed += f.placedAheadQty
+		left += f.aheadQty
+		if f.placedAheadQty > 0 {
+			ahead += f.placedAheadQty
+			progressed += f.placedAheadQty - f.aheadQty
+		}
+	}
+	if n == 0 {
+		return
+	}
+	m.AvgAheadQtyPlace = float64(placed) / float64(n)
+	m.AvgAheadQtyFill = float64(left) / float64(n)
+	if ahead > 0 {
+		m.QueueProgress = float64(progressed) / float64(ahead)
+	}
+}
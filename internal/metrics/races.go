@@ -0,0 +1,266 @@
+package metrics
+
+import (
+	"sort"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// Every signal starts a race. A trader enters it with the orders it
+// decides at the instant it receives the signal: the signal's own
+// timestamp, or a delayed SIGNAL addressed to it. Cancels don't count.
+// Entrants are ranked by their first order's arrival at the venue, and
+// each loser is checked against the winner: did it fill less, did its
+// limit orders queue behind the winner's at the same price, and what did
+// its fills cost against the signal-time mid compared with the winner's
+
+// RaceEntry is one trader's part in one signal's race
+type RaceEntry struct {
+	SignalNs  int64   `json:"signal_ns"` // when the signal fired
+	Value     float64 `json:"value"`
+	Rank      int     `json:"rank"` // 1 is the first to arrive
+	Entrants  int     `json:"entrants"`
+	ArrivalNs int64   `json:"arrival_ns"` // first order's arrival
+	// LeadNs is the winner's lead over the runner-up, or for any other
+	// entrant how far it trailed the winner, as a negative number
+	LeadNs    int64 `json:"lead_ns"`
+	Orders    int   `json:"orders"`
+	FilledQty int64 `json:"filled_qty"`
+	QueuePos  int   `json:"queue_pos,omitempty"` // best placement queue position of its limit orders
+	// CostBps is the fills' average price against the signal-time mid,
+	// positive is worse; zero without fills
+	CostBps float64 `json:"cost_bps"`
+
+	// Outcome for a trader that lost: it filled nothing while the winner
+	// filled, a limit order rested behind the winner's at the same price,
+	// and its cost minus the winner's, nil unless both filled
+	Missed      bool     `json:"missed,omitempty"`
+	QueueBehind bool     `json:"queue_behind,omitempty"`
+	CostGapBps  *float64 `json:"cost_gap_bps,omitempty"`
+}
+
+// Won reports whether the trader arrived first in a contested race
+func (e RaceEntry) Won() bool { return e.Rank == 1 && e.Entrants > 1 }
+
+type race struct {
+	signalNs int64
+	value    float64
+	mid      int64
+}
+
+// raceOrder is a trader order that may belong to a race
+type raceOrder struct {
+	trader   string
+	decision int64
+	arrival  int64
+	seq      int // log order
+	side     domain.Side
+	level    priorityLevel
+	queuePos int // limit orders only
+	filled   int64
+	paid     float64 // Σ qty × price
+}
+
+// raceAccum is one entrant's orders and fills in one race
+type raceAccum struct {
+	race     *race
+	orders   int
+	arrival  int64
+	seq      int // log order of the first arrival, to break ties
+	filled   int64
+	notional float64 // Σ qty × signed distance from the mid, price units
+	limits   map[priorityLevel]int
+}
+
+// raceTracker collects signals and trader orders, and groups them into
+// races once the log is in: only then is it known which traders had
+// their signals delayed, and so which of their orders were decided at a
+// broadcast only by coincidence, e.g. a re-quote
+type raceTracker struct {
+	races     []*race
+	broadcast map[int64]*race
+	delivered map[string]map[int64]*race // trader -> delayed delivery time
+	orders    map[uint64]*raceOrder
+	logged    []*raceOrder
+}
+
+func newRaceTracker() *raceTracker {
+	return &raceTracker{
+		broadcast: make(map[int64]*race),
+		delivered: make(map[string]map[int64]*race),
+		orders:    make(map[uint64]*raceOrder),
+	}
+}
+
+func (t *raceTracker) process(event *domain.Event) {
+	switch event.Type {
+	case domain.EventSignal:
+		s := event.Signal()
+		if s == nil {
+			return
+		}
+		if event.TraderID == "" {
+			r := &race{signalNs: event.Timestamp, value: s.Value, mid: s.MidPrice}
+			t.races = append(t.races, r)
+			t.broadcast[event.Timestamp] = r
+			return
+		}
+		// A delayed copy belongs to the latest signal it copies
+		for i := len(t.races) - 1; i >= 0; i-- {
+			if r := t.races[i]; r.value == s.Value && r.mid == s.MidPrice {
+				if t.delivered[event.TraderID] == nil {
+					t.delivered[event.TraderID] = make(map[int64]*race)
+				}
+				t.delivered[event.TraderID][event.Timestamp] = r
+				break
+			}
+		}
+	case domain.EventOrderAccepted:
+		o := event.Order()
+		if o == nil || domain.IsBackground(o.TraderID) || o.Type == domain.CancelOrder {
+			return
+		}
+		ro := &raceOrder{
+			trader:   o.TraderID,
+			decision: o.DecisionTime,
+			arrival:  o.ArrivalTime,
+			seq:      len(t.logged),
+			side:     o.Side,
+		}
+		if o.Type == domain.LimitOrder {
+			ro.level, ro.queuePos = priorityLevel{o.Side, o.Price}, o.QueuePos
+		}
+		t.orders[o.ID] = ro
+		t.logged = append(t.logged, ro)
+	case domain.EventTradeExecuted:
+		tr := event.Trade()
+		if tr == nil {
+			return
+		}
+		for _, id := range []uint64{tr.BuyOrderID, tr.SellOrderID} {
+			if ro, ok := t.orders[id]; ok {
+				ro.filled += tr.Qty
+				ro.paid += float64(tr.Qty) * float64(tr.Price)
+			}
+		}
+	}
+}
+
+// race returns the race an order was sent in, or nil
+func (t *raceTracker) race(o *raceOrder) *race {
+	if delayed, ok := t.delivered[o.trader]; ok {
+		return delayed[o.decision]
+	}
+	return t.broadcast[o.decision]
+}
+
+// costBps is the entrant's fills against the signal-time mid
+func (a *raceAccum) costBps() float64 {
+	if a.filled == 0 || a.race.mid == 0 {
+		return 0
+	}
+	return a.notional / float64(a.filled) / float64(a.race.mid) * 10000
+}
+
+// queuedBehind reports whether any of a's limit orders rested behind
+// winner's at the same side and price
+func (a *raceAccum) queuedBehind(winner *raceAccum) bool {
+	for lv, q := range a.limits {
+		if w, ok := winner.limits[lv]; ok && q > w {
+			return true
+		}
+	}
+	return false
+}
+
+// entries ranks each race's entrants and returns every trader's entries
+// in signal order
+func (t *raceTracker) entries() map[string][]RaceEntry {
+	out := make(map[string][]RaceEntry)
+	entrants := make(map[*race]map[string]*raceAccum)
+	for _, o := range t.logged {
+		r := t.race(o)
+		if r == nil {
+			continue
+		}
+		if entrants[r] == nil {
+			entrants[r] = make(map[string]*raceAccum)
+		}
+		acc := entrants[r][o.trader]
+		if acc == nil {
+			acc = &raceAccum{race: r, arrival: o.arrival, seq: o.seq, limits: make(map[priorityLevel]int)}
+			entrants[r][o.trader] = acc
+		}
+		acc.orders++
+		if o.arrival < acc.arrival {
+			acc.arrival, acc.seq = o.arrival, o.seq
+		}
+		if o.queuePos > 0 {
+			if q, ok := acc.limits[o.level]; !ok || o.queuePos < q {
+				acc.limits[o.level] = o.queuePos
+			}
+		}
+		if o.filled > 0 {
+			move := o.paid - float64(o.filled)*float64(r.mid)
+			if o.side == domain.Sell {
+				move = -move
+			}
+			acc.filled += o.filled
+			acc.notional += move
+		}
+	}
+
+	for _, r := range t.races {
+		type entrant struct {
+			id  string
+			acc *raceAccum
+		}
+		var ranked []entrant
+		for id, acc := range entrants[r] {
+			ranked = append(ranked, entrant{id, acc})
+		}
+		sort.Slice(ranked, func(i, j int) bool {
+			a, b := ranked[i].acc, ranked[j].acc
+			if a.arrival != b.arrival {
+				return a.arrival < b.arrival
+			}
+			return a.seq < b.seq
+		})
+		if len(ranked) == 0 {
+			continue
+		}
+		winner := ranked[0].acc
+		for i, en := range ranked {
+			acc := en.acc
+			e := RaceEntry{
+				SignalNs:  r.signalNs,
+				Value:     r.value,
+				Rank:      i + 1,
+				Entrants:  len(ranked),
+				ArrivalNs: acc.arrival,
+				Orders:    acc.orders,
+				FilledQty: acc.filled,
+				CostBps:   acc.costBps(),
+			}
+			for _, q := range acc.limits {
+				if e.QueuePos == 0 || q < e.QueuePos {
+					e.QueuePos = q
+				}
+			}
+			switch {
+			case i > 0:
+				e.LeadNs = winner.arrival - acc.arrival
+				e.Missed = acc.filled == 0 && winner.filled > 0
+				e.QueueBehind = acc.queuedBehind(winner)
+				if acc.filled > 0 && winner.filled > 0 {
+					gap := e.CostBps - winner.costBps()
+					e.CostGapBps = &gap
+				}
+			case len(ranked) > 1:
+				e.LeadNs = ranked[1].acc.arrival - acc.arrival
+			}
+			out[en.id] = append(out[en.id], e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/quantile"
+)
+
+// Re-quoting: how well a trader keeps a quote at the touch. A full cancel
+// of a resting limit order followed by the trader's next limit order on
+// the same side, decided within RequoteWindowNs of the cancel, is one
+// re-quote link; a replacement that is itself canceled and replaced
+// extends the chain. The round trip runs from the cancel's decision to
+// the replacement's arrival at the venue, the time the trader meant to
+// be quoting and was not. Time at the inside is the share of the run the
+// trader had a resting order at the best bid or ask. Both cover the whole
+// run, exclusion windows included, like position and PnL
+
+// RequoteWindowNs is how soon after a cancel a new order on the same side
+// must be decided to count as its replacement
+const RequoteWindowNs = int64(1_000_000_000)
+
+// quoteKey is one side of one trader's quoting
+type quoteKey struct {
+	trader string
+	side   domain.Side
+}
+
+// pendingRequote is a canceled quote awaiting its replacement
+type pendingRequote struct {
+	decision int64 // the cancel's decision time
+	links    int   // links in the chain that led to the canceled quote
+}
+
+// requoteAccum is one trader's re-quoting
+type requoteAccum struct {
+	links    int
+	chains   int
+	longest  int
+	rtt      quantile.Estimator
+	insideNs int64
+	inside   bool
+}
+
+type quoteTracker struct {
+	live    map[uint64]domain.Order // resting trader orders
+	pending map[quoteKey]pendingRequote
+	links   map[uint64]int // live replacements -> links in their chain
+	traders map[string]*requoteAccum
+	bbo     domain.BBO
+	last    int64
+	newEst  func() quantile.Estimator
+}
+
+// newQuoteTracker takes the estimator constructor late, as a collector's
+// options are set after it is made
+func newQuoteTracker(newEst func() quantile.Estimator) *quoteTracker {
+	return &quoteTracker{
+		live:    make(map[uint64]domain.Order),
+		pending: make(map[quoteKey]pendingRequote),
+		links:   make(map[uint64]int),
+		traders: make(map[string]*requoteAccum),
+		newEst:  newEst,
+	}
+}
+
+func (q *quoteTracker) trader(id string) *requoteAccum {
+	a, ok := q.traders[id]
+	if !ok {
+		a = &requoteAccum{rtt: q.newEst()}
+		q.traders[id] = a
+	}
+	return a
+}
+
+// process applies an event and the lifecycle changes it caused
+func (q *quoteTracker) process(e *domain.Event, changes []domain.StateChange) {
+	if e.Timestamp > q.last {
+		for _, a := range q.traders {
+			if a.inside {
+				a.insideNs += e.Timestamp - q.last
+			}
+		}
+		q.last = e.Timestamp
+	}
+	moved := false
+	switch e.Type {
+	case domain.EventBBOUpdate:
+		if bbo := e.BBO(); bbo != nil {
+			q.bbo, moved = *bbo, true
+		}
+	case domain.EventOrderAccepted:
+		if o := e.Order(); o != nil && o.Type == domain.LimitOrder && !o.Immediate() && !domain.IsBackground(o.TraderID) {
+			q.placed(o, e.Timestamp)
+			if o.RemainingQty > 0 {
+				q.live[o.ID], moved = *o, true
+			}
+		}
+	}
+	for _, ch := range changes {
+		if !ch.To.Terminal() {
+			continue
+		}
+		o, ok := q.live[ch.OrderID]
+		if !ok {
+			continue
+		}
+		delete(q.live, ch.OrderID)
+		moved = true
+		if c := e.Cancel(); ch.To == domain.StateCanceled && c != nil && c.CancelID == ch.OrderID {
+			q.pending[quoteKey{o.TraderID, o.Side}] = pendingRequote{decision: c.DecisionTime, links: q.links[o.ID]}
+		}
+		delete(q.links, o.ID)
+	}
+	if moved {
+		q.refresh()
+	}
+}
+
+// placed links a new limit order to the canceled quote it replaces, if
+// any; any new order on the side ends the wait for a replacement
+func (q *quoteTracker) placed(o *domain.Order, ts int64) {
+	key := quoteKey{o.TraderID, o.Side}
+	p, ok := q.pending[key]
+	if !ok {
+		return
+	}
+	delete(q.pending, key)
+	if o.DecisionTime < p.decision || o.DecisionTime-p.decision > RequoteWindowNs {
+		return
+	}
+	arrival := o.ArrivalTime
+	if arrival == 0 {
+		arrival = ts
+	}
+	a := q.trader(o.TraderID)
+	a.links++
+	if p.links == 0 {
+		a.chains++
+	}
+	a.longest = max(a.longest, p.links+1)
+	a.rtt.Add(float64(arrival - p.decision))
+	q.links[o.ID] = p.links + 1
+}
+
+// refresh recomputes which traders are at the inside
+func (q *quoteTracker) refresh() {
+	for _, a := range q.traders {
+		a.inside = false
+	}
+	for _, o := range q.live {
+		if o.Side == domain.Buy && q.bbo.BidPrice > 0 && o.Price == q.bbo.BidPrice ||
+			o.Side == domain.Sell && q.bbo.AskPrice > 0 && o.Price == q.bbo.AskPrice {
+			q.trader(o.TraderID).inside = true
+		}
+	}
+}
+
+// addRequotes fills in a trader's re-quoting over a run ending at end
+func (m *TraderMetrics) addRequotes(q *quoteTracker, end int64) {
+	a, ok := q.traders[m.TraderID]
+	if !ok {
+		return
+	}
+	m.Requotes, m.RequoteChains, m.LongestRequoteChain = a.links, a.chains, a.longest
+	m.RequoteRTT = latencyStats(a.rtt)
+	if end > 0 {
+		m.TimeAtInside = float64(a.insideNs) / float64(end)
+	}
+}
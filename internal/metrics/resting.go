@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// Orders still resting when the run ends, or a session closes, were cut
+// off by the clock rather than by the market or the trader. A slow
+// trader's orders reach the book later and have less time to fill, so
+// counting the ones left unfilled as failures biases fill rates against
+// it. Each is measured at the close: its open qty, how long it had rested
+// since arrival and how far it sat behind the mid. Under
+// Options.CancelAtClose they are treated as canceled there instead, and
+// the unfilled ones leave the fill rate
+
+// restingOrder is an order the close expired
+type restingOrder struct {
+	id     uint64
+	open   int64 // qty left on the book
+	filled bool  // whether any of it had filled
+	ageNs  int64 // close minus arrival
+	behind int64 // price units behind the mid at the close; negative if through it
+	mid    int64 // the mid at the close; 0 if there was none
+}
+
+// processResting records an order the close expired. A resting buy sits
+// behind the mid by how far below it it is, a sell by how far above
+func (c *Collector) processResting(a *traderAccum, change *domain.StateChange, ts int64) {
+	info, ok := a.orderTimes[change.OrderID]
+	if !ok {
+		return
+	}
+	r := restingOrder{id: change.OrderID, open: info.qty - change.FilledQty, filled: change.FilledQty > 0}
+	placed := info.arrivalTime
+	if placed == 0 {
+		placed = info.decisionTime
+	}
+	r.ageNs = ts - placed
+	if r.mid = c.lastMid(); r.mid > 0 && info.price > 0 {
+		r.behind = r.mid - info.price
+		if info.side == domain.Sell {
+			r.behind = -r.behind
+		}
+	}
+	a.resting = append(a.resting, r)
+}
+
+// cutOff is the set of orders the close expired without a fill, which
+// CancelAtClose leaves out of the fill rate; nil otherwise
+func (c *Collector) cutOff(a *traderAccum) map[uint64]bool {
+	if !c.Options.CancelAtClose {
+		return nil
+	}
+	var out map[uint64]bool
+	for _, r := range a.resting {
+		if !r.filled {
+			if out == nil {
+				out = make(map[uint64]bool)
+			}
+			out[r.id] = true
+		}
+	}
+	return out
+}
+
+// addResting sets the resting-at-close metrics
+func (m *TraderMetrics) addResting(c *Collector, a *traderAccum, scale domain.Scale) {
+	if len(a.resting) == 0 {
+		return
+	}
+	ages := c.Options.estimator()
+	var behind, bps float64
+	var measured int
+	for _, r := range a.resting {
+		m.RestingQty += r.open
+		if !r.filled {
+			m.RestingUnfilled++
+		}
+		ages.Add(float64(r.ageNs))
+		if r.mid > 0 {
+			behind += scale.ToFloat(r.behind)
+			bps += float64(r.behind) / float64(r.mid) * 10000
+			measured++
+		}
+	}
+	m.RestingAge = latencyStats(ages)
+	if measured > 0 {
+		m.RestingBehindMid = behind / float64(measured)
+		m.RestingBehindMidBps = bps / float64(measured)
+	}
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+)
+
+// SessionMetrics are the traders' metrics over one trading session
+type SessionMetrics struct {
+	Name    string                    `json:"name"`
+	StartNs int64                     `json:"start_ns"`
+	EndNs   int64                     `json:"end_ns"`
+	Traders map[string]*TraderMetrics `json:"traders"`
+}
+
+// ComputeSessionsFromLog computes each session's metrics from a
+// multi-session log, as ComputeFromLog does for the whole run. A session
+// sees its events from its first SESSION record to its close, so fills,
+// slippage, markouts and races are its own. Accounts carry over: position
+// and PnL are the running totals at the session's close. A log without
+// sessions has none
+func ComputeSessionsFromLog(logPath string, scale domain.Scale, opts Options) ([]SessionMetrics, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	reader, err := eventlog.NewReader(logPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	have, err := reader.Level()
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckLevel(have); err != nil {
+		return nil, err
+	}
+
+	var sessions []SessionMetrics
+	var c *Collector // the open session's, nil between sessions
+	accounts := make(map[string]domain.Account)
+	err = reader.Each(func(event *domain.Event) error {
+		if event.Type != domain.EventSession {
+			if c != nil {
+				c.ProcessEvent(event)
+			}
+			return nil
+		}
+
+		if c == nil {
+			name, _ := event.Annotation("session")
+			sessions = append(sessions, SessionMetrics{Name: name, StartNs: event.Timestamp})
+			c = NewCollector()
+			c.Scale, c.Options = scale, opts
+			for id, acct := range detorder.All(accounts) {
+				c.getAccum(id).account = acct
+			}
+		}
+		c.ProcessEvent(event)
+		if phase, _ := event.Annotation("phase"); phase == domain.PhaseClosed {
+			s := &sessions[len(sessions)-1]
+			s.EndNs, s.Traders = event.Timestamp, c.Compute()
+			for id, a := range c.traderMetrics {
+				accounts[id] = a.account
+			}
+			c = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import "github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+
+// addSpread splits a trader's fills into passive and aggressive and sums
+// the spread each side of the split earned or paid. A fill's spread is
+// its price's distance from the last two-sided mid before the trade,
+// signed so that a passive buyer below the mid captures and an aggressive
+// buyer above it pays. Fills before any two-sided book count towards the
+// mix but not the spread
+func (m *TraderMetrics) addSpread(fills []fillInfo, scale domain.Scale) {
+	// Summed in price units, exact, and converted once
+	var captured, paid, notionalMid int64
+	for _, f := range fills {
+		if f.passive {
+			m.PassiveFills++
+			m.PassiveQty += f.fillQty
+		} else {
+			m.AggressiveFills++
+			m.AggressiveQty += f.fillQty
+		}
+		if f.midBefore == 0 {
+			continue
+		}
+		// Positive when the fill was better than the mid
+		edge := f.midBefore - f.tradePrice
+		if f.side == domain.Sell {
+			edge = -edge
+		}
+		if f.passive {
+			captured += edge * f.fillQty
+		} else {
+			paid -= edge * f.fillQty
+		}
+		notionalMid += f.midBefore * f.fillQty
+	}
+	m.SpreadCaptured, m.SpreadPaid = scale.ToFloat(captured), scale.ToFloat(paid)
+	if filled := m.PassiveQty + m.AggressiveQty; filled > 0 {
+		m.PassiveShare = float64(m.PassiveQty) / float64(filled)
+	}
+	m.NetSpread = scale.ToFloat(captured - paid)
+	if notionalMid > 0 {
+		m.NetSpreadBps = float64(captured-paid) / float64(notionalMid) * 10_000
+	}
+}
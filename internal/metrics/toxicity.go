@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+)
+
+// Flow toxicity defaults: the run's volume in 50 buckets, each averaged
+// with the 9 before it
+const (
+	DefaultToxicityBuckets = 50
+	DefaultToxicityWindow  = 10
+)
+
+// flowToxicity is a VPIN-like measure of how one-sided the trade stream
+// was. The run's volume is cut into equal-volume buckets; a bucket's
+// imbalance is |buy - sell| / volume by aggressor side, and its toxicity
+// is the mean imbalance of the window of buckets ending with it. A trade
+// takes the toxicity of the buckets its volume fell in, so it reflects
+// the interval around the trade, itself included
+type flowToxicity struct {
+	perTrade  []float64 // by trade index
+	threshold float64   // toxic from here up: the run's most toxic third of volume
+	toxicQty  int64
+	volume    int64
+}
+
+func newFlowToxicity(trades []tradeRecord, buckets, window int) *flowToxicity {
+	ft := &flowToxicity{perTrade: make([]float64, len(trades))}
+	for _, t := range trades {
+		ft.volume += t.qty
+	}
+	if ft.volume == 0 {
+		return ft
+	}
+	size := max((ft.volume+int64(buckets)-1)/int64(buckets), 1)
+
+	// Each trade's volume is split across the buckets it spans
+	var vol, net []int64
+	bucketOf := make([][2]int, len(trades)) // first and last bucket
+	cur := 0
+	for i, t := range trades {
+		bucketOf[i][0] = cur
+		for left := t.qty; left > 0; {
+			if cur == len(vol) {
+				vol, net = append(vol, 0), append(net, 0)
+			}
+			part := min(left, size-vol[cur])
+			vol[cur] += part
+			net[cur] += int64(t.sign) * part
+			left -= part
+			bucketOf[i][1] = cur
+			if vol[cur] == size {
+				cur++
+			}
+		}
+	}
+
+	toxicity := make([]float64, len(vol))
+	var sum float64
+	for b := range vol {
+		sum += math.Abs(float64(net[b])) / float64(vol[b])
+		if b >= window {
+			sum -= math.Abs(float64(net[b-window])) / float64(vol[b-window])
+		}
+		toxicity[b] = sum / float64(min(b+1, window))
+	}
+	for i := range trades {
+		// A trade spanning buckets takes their mean
+		first, last := bucketOf[i][0], bucketOf[i][1]
+		var w float64
+		for b := first; b <= last; b++ {
+			w += toxicity[b]
+		}
+		ft.perTrade[i] = w / float64(last-first+1)
+	}
+
+	// The threshold is where the most toxic trades reach a third of volume
+	order := make([]int, len(trades))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return ft.perTrade[order[a]] > ft.perTrade[order[b]] })
+	var seen int64
+	for _, i := range order {
+		seen += trades[i].qty
+		if 3*seen >= ft.volume {
+			ft.threshold = ft.perTrade[i]
+			break
+		}
+	}
+	for i, t := range trades {
+		if ft.toxic(i) {
+			ft.toxicQty += t.qty
+		}
+	}
+	return ft
+}
+
+// toxic reports whether trade i fell in a toxic interval
+func (ft *flowToxicity) toxic(i int) bool {
+	return ft.volume > 0 && ft.perTrade[i] >= ft.threshold
+}
+
+// addToxicity sums up the toxicity of the intervals a trader's fills fell
+// in, against the market's
+func (m *TraderMetrics) addToxicity(fills []fillInfo, ft *flowToxicity) {
+	var qty, toxicQty int64
+	var weighted float64
+	for _, f := range fills {
+		qty += f.fillQty
+		weighted += ft.perTrade[f.trade] * float64(f.fillQty)
+		if ft.toxic(f.trade) {
+			toxicQty += f.fillQty
+		}
+	}
+	if qty == 0 {
+		return
+	}
+	m.AvgFillToxicity = weighted / float64(qty)
+	m.ToxicFillShare = float64(toxicQty) / float64(qty)
+	if ft.toxicQty > 0 {
+		m.ToxicFillRatio = m.ToxicFillShare / (float64(ft.toxicQty) / float64(ft.volume))
+	}
+}
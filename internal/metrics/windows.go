@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// Window is a span of the run that metrics leave out, e.g. around a
+// scheduled shock or halt, so the transient it causes doesn't swamp the
+// comparison. A trader order is in a window if it was decided there, and
+// its fills, cancellation and expiry go with it; so do signal races that
+// started there and cancel races decided there. Market-wide context such
+// as mids for markouts and flow toxicity still sees every event, and
+// position and PnL are still the whole run's
+type Window struct {
+	Name    string `json:"name,omitempty"`
+	StartNs int64  `json:"start_ns"`
+	EndNs   int64  `json:"end_ns"` // exclusive
+}
+
+// Contains reports whether ns falls in the window
+func (w Window) Contains(ns int64) bool {
+	return ns >= w.StartNs && ns < w.EndNs
+}
+
+// UnmarshalJSON accepts the bounds as nanosecond counts or duration
+// strings such as "2s"
+func (w *Window) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Name    string          `json:"name"`
+		StartNs json.RawMessage `json:"start_ns"`
+		EndNs   json.RawMessage `json:"end_ns"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.StartNs == nil || aux.EndNs == nil {
+		return fmt.Errorf("exclusion window needs start_ns and end_ns")
+	}
+	start, err := parseNanos(aux.StartNs, "window start")
+	if err != nil {
+		return err
+	}
+	end, err := parseNanos(aux.EndNs, "window end")
+	if err != nil {
+		return err
+	}
+	*w = Window{Name: aux.Name, StartNs: start, EndNs: end}
+	return nil
+}
+
+// excluded reports whether ns falls in any exclusion window
+func (o Options) excluded(ns int64) bool {
+	for _, w := range o.Exclude {
+		if w.Contains(ns) {
+			return true
+		}
+	}
+	return false
+}
+
+// skip reports whether the collector leaves out what was decided at ns:
+// whatever is in an exclusion window, or when it is measuring the windows
+// themselves, whatever is not
+func (c *Collector) skip(ns int64) bool {
+	return len(c.Options.Exclude) > 0 && c.Options.excluded(ns) != c.onlyExcluded
+}
+
+// ComputeExcludedFromLog computes metrics over just what opts' exclusion
+// windows leave out of ComputeFromLog, so the windows can be reported
+// separately. Without windows it returns nil
+func ComputeExcludedFromLog(logPath string, scale domain.Scale, opts Options) (map[string]*TraderMetrics, error) {
+	if len(opts.Exclude) == 0 {
+		return nil, nil
+	}
+	return computeFromLog(logPath, scale, opts, true)
+}
+
+// keptRaces drops the races that started where skip applies
+func (c *Collector) keptRaces(entries []RaceEntry) []RaceEntry {
+	if len(c.Options.Exclude) == 0 {
+		return entries
+	}
+	var kept []RaceEntry
+	for _, e := range entries {
+		if !c.skip(e.SignalNs) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,188 @@
+// Package numfmt formats floats for reports and metrics files the same
+// way on every platform. A float result can differ in its last bit from
+// one architecture to another (fused multiply-add on arm64, for one),
+// and fmt rounds exact ties to even, so a fill rate of 12.5% on one
+// machine and 12.500000000000002% on another prints as 12 on the first
+// and 13 on the second. Here every value is first cut to Significant
+// digits, which absorbs that noise, then rounded half away from zero to
+// the places shown. Negative zero, or a negative value that rounds to
+// zero, prints as zero. strconv is locale-independent, so the output
+// never depends on the environment either
+package numfmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Significant is how many significant digits of a value are kept
+const Significant = 12
+
+// Canon cuts v to Significant digits. NaN and the infinities are kept
+func Canon(v float64) float64 {
+	if v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return v + 0 // clears negative zero
+	}
+	c, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'e', Significant-1, 64), 64)
+	return c
+}
+
+// Fixed formats v with places decimals, rounding half away from zero
+func Fixed(v float64, places int) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'f', places, 64)
+	}
+	c := Canon(v)
+	digits := strconv.FormatFloat(math.Abs(c), 'f', -1, 64)
+	whole, frac, _ := strings.Cut(digits, ".")
+	if len(frac) <= places {
+		frac += strings.Repeat("0", places-len(frac))
+	} else {
+		up := frac[places] >= '5'
+		frac = frac[:places]
+		if up {
+			// a carry can lengthen the whole part, never the fraction
+			sum := increment(whole + frac)
+			whole, frac = sum[:len(sum)-places], sum[len(sum)-places:]
+		}
+	}
+	out := whole
+	if places > 0 {
+		out += "." + frac
+	}
+	if c < 0 && strings.Trim(out, "0.") != "" {
+		out = "-" + out
+	}
+	return out
+}
+
+// Signed is Fixed with a leading + on values that are not negative, as
+// the %+f verb gives
+func Signed(v float64, places int) string {
+	s := Fixed(v, places)
+	if !strings.HasPrefix(s, "-") {
+		s = "+" + s
+	}
+	return s
+}
+
+// increment adds one to a string of decimal digits
+func increment(digits string) string {
+	b := []byte(digits)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < '9' {
+			b[i]++
+			return string(b)
+		}
+		b[i] = '0'
+	}
+	return "1" + string(b)
+}
+
+// Float formats through Fixed under the f and F verbs, honouring the +
+// and - flags, width and precision (6 by default, as in fmt). Other
+// verbs format Canon of the value as fmt would
+type Float float64
+
+// Format implements fmt.Formatter
+func (x Float) Format(f fmt.State, verb rune) {
+	v := float64(x)
+	if verb != 'f' && verb != 'F' {
+		fmt.Fprintf(f, fmt.FormatString(f, verb), Canon(v))
+		return
+	}
+	places, ok := f.Precision()
+	if !ok {
+		places = 6
+	}
+	s := Fixed(v, places)
+	if f.Flag('+') && !strings.HasPrefix(s, "-") {
+		s = "+" + s
+	}
+	if width, ok := f.Width(); ok && len(s) < width {
+		pad := strings.Repeat(" ", width-len(s))
+		if f.Flag('-') {
+			s += pad
+		} else {
+			s = pad + s
+		}
+	}
+	f.Write([]byte(s))
+}
+
+// Sprintf is fmt.Sprintf with every float64 argument formatted as a Float
+func Sprintf(format string, args ...any) string {
+	wrapped := make([]any, len(args))
+	for i, a := range args {
+		if v, ok := a.(float64); ok {
+			a = Float(v)
+		}
+		wrapped[i] = a
+	}
+	return fmt.Sprintf(format, wrapped...)
+}
+
+// MarshalIndent is json.MarshalIndent with every fractional number cut
+// to Significant digits, so last-bit noise never reaches the file
+func MarshalIndent(v any, prefix, indent string) ([]byte, error) {
+	data, err := json.MarshalIndent(v, prefix, indent)
+	if err != nil {
+		return nil, err
+	}
+	return CanonJSON(data), nil
+}
+
+// CanonJSON rewrites the fractional numbers in JSON data as Canon of
+// their value, in encoding/json's notation. Integers, strings and layout
+// are left as they are
+func CanonJSON(data []byte) []byte {
+	var out bytes.Buffer
+	out.Grow(len(data))
+	inString, escaped := false, false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			out.WriteByte(c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		if c == '"' {
+			inString = true
+			out.WriteByte(c)
+			continue
+		}
+		if c != '-' && (c < '0' || c > '9') {
+			out.WriteByte(c)
+			continue
+		}
+		j := i
+		for j < len(data) && strings.IndexByte("+-0123456789.eE", data[j]) >= 0 {
+			j++
+		}
+		num := data[i:j]
+		i = j - 1
+		if bytes.IndexAny(num, ".eE") < 0 {
+			out.Write(num)
+			continue
+		}
+		v, err := strconv.ParseFloat(string(num), 64)
+		if err != nil {
+			out.Write(num)
+			continue
+		}
+		canon, _ := json.Marshal(Canon(v))
+		out.Write(canon)
+	}
+	return out.Bytes()
+}
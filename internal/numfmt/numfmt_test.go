@@ -0,0 +1,71 @@
+package numfmt
+
+import (
+	"math"
+	"testing"
+)
+
+// TestFixedGolden pins the text of values that fmt prints differently
+// depending on their last bit. Each is checked as is and one ulp either
+// side, as another platform might compute it, and must print the same
+func TestFixedGolden(t *testing.T) {
+	tests := []struct {
+		v      float64
+		places int
+		want   string
+	}{
+		{12.5, 0, "13"}, // fmt: 12
+		{0.125, 2, "0.13"},
+		{88.28125, 4, "88.2813"},
+		{2.675, 2, "2.68"}, // stored as 2.67499999999999982236431605997495353221893310546875
+		{0.1 + 0.2, 1, "0.3"},
+		{-4.53125, 4, "-4.5313"},
+		{-0.00001, 4, "0.0000"},
+		{9.9995, 3, "10.000"},
+		{99.5, 0, "100"},
+		{1234567.891, 2, "1234567.89"},
+		{1e-9, 2, "0.00"},
+		{3, 2, "3.00"},
+	}
+	for _, tt := range tests {
+		for _, v := range []float64{tt.v, math.Nextafter(tt.v, math.Inf(1)), math.Nextafter(tt.v, math.Inf(-1))} {
+			if got := Fixed(v, tt.places); got != tt.want {
+				t.Errorf("Fixed(%v, %d) = %q, want %q", v, tt.places, got, tt.want)
+			}
+		}
+	}
+	if got := Fixed(math.Copysign(0, -1), 2); got != "0.00" {
+		t.Errorf("Fixed(-0, 2) = %q", got)
+	}
+	if got := Signed(-0.00004, 4); got != "+0.0000" {
+		t.Errorf("Signed(-0.00004, 4) = %q", got)
+	}
+}
+
+func TestSprintf(t *testing.T) {
+	tests := []struct {
+		format string
+		args   []any
+		want   string
+	}{
+		{"%.1f%%", []any{12.25}, "12.3%"},
+		{"%+.4f", []any{-0.00001}, "+0.0000"},
+		{"%+.2f", []any{1.005}, "+1.01"},
+		{"|%8.2f|%-8.2f|", []any{2.5, -2.5}, "|    2.50|-2.50   |"},
+		{"%s %d %.0f", []any{"n", 3, 0.5}, "n 3 1"},
+		{"%g", []any{0.1 + 0.2}, "0.3"},
+	}
+	for _, tt := range tests {
+		if got := Sprintf(tt.format, tt.args...); got != tt.want {
+			t.Errorf("Sprintf(%q, %v) = %q, want %q", tt.format, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestCanonJSON(t *testing.T) {
+	in := `{"fill_rate": 0.30000000000000004, "orders": 12, "id": "1.00000000000000001", "ns": -1.2345678901234567e-7, "big": 1e21}`
+	want := `{"fill_rate": 0.3, "orders": 12, "id": "1.00000000000000001", "ns": -1.23456789012e-7, "big": 1e+21}`
+	if got := string(CanonJSON([]byte(in))); got != want {
+		t.Errorf("CanonJSON:\n got %s\nwant %s", got, want)
+	}
+}
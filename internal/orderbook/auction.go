@@ -0,0 +1,405 @@
+package orderbook
+
+import (
+	"slices"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// A batch auction crosses a batch of new orders against the book, and
+// each other, at one uniform price. BeginAuction picks the price and
+// decides every fill up front; the batch's orders then go through
+// ProcessOrder one at a time in the order it returns, each executing the
+// fills it aggresses, so every order is still logged with its own trades.
+// New limit orders are held off the book until EndAuction, which keeps
+// the book uncrossed while the batch is half processed
+
+// auction is a batch being crossed
+type auction struct {
+	time     int64
+	price    int64
+	fills    map[uint64][]auctionFill // aggressor ID -> its fills, in priority order
+	pending  []*domain.Order          // new limit orders, held off the book
+	killed   []*domain.Order          // new IOC and FOK orders, which never rest
+	queuePos map[uint64]int           // queue position each pending remainder will take
+}
+
+// auctionFill is qty an aggressor takes from one passive order
+type auctionFill struct {
+	passive *domain.Order
+	qty     int64
+}
+
+// bidder is one order's interest on one side of the auction
+type bidder struct {
+	o     *domain.Order
+	qty   int64 // displayed for a resting order, full qty for a new one
+	old   bool  // resting before the batch
+	alloc int64 // qty it executes
+	open  int64 // alloc not yet paired with a counterparty
+}
+
+// slot is a price level on one side
+type slot struct {
+	side  domain.Side
+	price int64
+}
+
+// BeginAuction prepares to cross batch, new limit and market orders, at
+// timestamp, and returns the order to process them in: limits, then
+// market orders, each in batch order. The clearing price maximizes executed volume, then
+// minimizes the imbalance left over, then stays nearest the current mid.
+// Orders at better prices fill first; at the marginal price resting
+// orders keep time priority over the batch, or share pro-rata. Market
+// orders never trade with each other. IOC orders take what the auction
+// gives them and FOK orders fill in full or not at all; neither joins
+// the book after. A resting iceberg offers only its displayed slice. If
+// nothing crosses there is no auction and the orders simply go through
+// the continuous book
+func (b *Book) BeginAuction(batch []*domain.Order, timestamp int64) []*domain.Order {
+	var seq, markets []*domain.Order
+	for _, o := range batch {
+		if o.Type == domain.MarketOrder {
+			markets = append(markets, o)
+		} else {
+			seq = append(seq, o)
+		}
+	}
+	seq = append(seq, markets...)
+
+	price, volume, buys, sells := b.clear(seq)
+	if volume == 0 {
+		return seq
+	}
+
+	a := &auction{
+		time:     timestamp,
+		price:    price,
+		fills:    make(map[uint64][]auctionFill),
+		queuePos: make(map[uint64]int),
+	}
+	rank := make(map[uint64]int, len(seq))
+	for i, o := range seq {
+		rank[o.ID] = i
+	}
+	buyMarket, buyLimit := split(buys)
+	sellMarket, sellLimit := split(sells)
+	a.pair(buyMarket, sellLimit, rank)
+	a.pair(sellMarket, buyLimit, rank)
+	a.pair(buyLimit, sellLimit, rank)
+
+	// Remainders join the back of their level, after the resting orders
+	// the auction leaves there, in batch order. A resting iceberg whose
+	// slice fills stays, its next slice ahead of the remainders
+	leaving := make(map[slot]int)
+	allocs := make(map[uint64]int64)
+	for _, side := range [][][]*bidder{buys, sells} {
+		for _, group := range side {
+			for _, bd := range group {
+				allocs[bd.o.ID] = bd.alloc
+				if bd.old && bd.alloc == bd.qty && b.orderIndex[bd.o.ID].hidden == 0 {
+					leaving[slot{bd.o.Side, bd.o.Price}]++
+				}
+			}
+		}
+	}
+	joined := make(map[slot]int)
+	for _, o := range seq {
+		if o.Type != domain.LimitOrder || o.Immediate() || allocs[o.ID] == o.Qty {
+			continue
+		}
+		s := slot{o.Side, o.Price}
+		joined[s]++
+		resting := 0
+		if level := b.side(o.Side).get(o.Price); level != nil {
+			resting = level.count
+		}
+		a.queuePos[o.ID] = resting - leaving[s] + joined[s]
+	}
+
+	b.auction = a
+	return seq
+}
+
+// EndAuction puts the batch's limit remainders on the book and returns
+// the BBO after the auction, with the IOC and FOK orders left with a
+// remainder for the caller to cancel. It is a no-op if BeginAuction found
+// nothing to cross
+func (b *Book) EndAuction() (*domain.BBO, []*domain.Order) {
+	var unfilled []*domain.Order
+	if a := b.auction; a != nil {
+		b.auction = nil
+		for _, o := range a.pending {
+			if o.RemainingQty > 0 {
+				b.insert(o, a.time)
+			}
+		}
+		for _, o := range a.killed {
+			if o.RemainingQty > 0 {
+				unfilled = append(unfilled, o)
+			}
+		}
+	}
+	return b.BBO(), unfilled
+}
+
+// AuctionPrice returns the clearing price of the batch being crossed, or 0
+func (b *Book) AuctionPrice() int64 {
+	if b.auction == nil {
+		return 0
+	}
+	return b.auction.price
+}
+
+// cross executes the fills incoming aggresses at the clearing price
+func (b *Book) cross(incoming *domain.Order, timestamp int64) []domain.Trade {
+	a := b.auction
+	var trades []domain.Trade
+	for _, f := range a.fills[incoming.ID] {
+		p := f.passive
+		pos := a.queuePos[p.ID]
+		var node *orderNode
+		var aheadQty int64
+		if n, resting := b.orderIndex[p.ID]; resting {
+			pos = b.QueuePosition(p.ID)
+			node, aheadQty = n, n.aheadQty()
+			n.level.qty -= f.qty
+		}
+		p.RemainingQty -= f.qty
+		incoming.RemainingQty -= f.qty
+		trade := b.trade(incoming, p, a.price, f.qty, timestamp, pos)
+		// A batch order never rested, so has no placement to report
+		if node != nil {
+			node.stamp(&trade, aheadQty)
+			switch {
+			case p.RemainingQty <= 0:
+				b.removeNode(node)
+			case node.shown() == 0:
+				b.refresh(node, trade.ID)
+			}
+		}
+		trades = append(trades, trade)
+	}
+	delete(a.fills, incoming.ID)
+	switch {
+	case incoming.Type != domain.LimitOrder || incoming.RemainingQty == 0:
+	case incoming.Immediate():
+		// Its remainder may still fill as the passive side of a later
+		// order in the batch, so it is only canceled at the end
+		a.killed = append(a.killed, incoming)
+	default:
+		a.pending = append(a.pending, incoming)
+	}
+	return trades
+}
+
+// clear picks the clearing price for seq and allocates the volume it
+// executes. A fill-or-kill order the allocation leaves short sits the
+// auction out, and the price is picked again without it, until every FOK
+// order still in fills in full
+func (b *Book) clear(seq []*domain.Order) (price, volume int64, buys, sells [][]*bidder) {
+	for {
+		buys, sells = b.bidders(domain.Buy, seq), b.bidders(domain.Sell, seq)
+		price, volume = b.clearingPrice(buys, sells)
+		if volume == 0 {
+			return price, volume, buys, sells
+		}
+		buys = eligible(buys, func(p int64) bool { return p >= price })
+		sells = eligible(sells, func(p int64) bool { return p <= price })
+		b.allocate(buys, volume)
+		b.allocate(sells, volume)
+		short := shortFOK(buys, sells)
+		if short == nil {
+			return price, volume, buys, sells
+		}
+		seq = slices.DeleteFunc(slices.Clone(seq), func(o *domain.Order) bool { return o == short })
+	}
+}
+
+// shortFOK returns the first fill-or-kill order allocated less than its
+// qty, or nil
+func shortFOK(sides ...[][]*bidder) *domain.Order {
+	for _, side := range sides {
+		for _, group := range side {
+			for _, bd := range group {
+				if bd.o.TimeInForce == domain.FOK && bd.alloc < bd.qty {
+					return bd.o
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// bidders lists one side's interest in priority order: market orders in
+// batch order, then one group per price, best first, each holding the
+// resting orders in FIFO order and then the batch's
+func (b *Book) bidders(side domain.Side, seq []*domain.Order) [][]*bidder {
+	var market []*bidder
+	var prices []int64
+	byPrice := make(map[int64][]*bidder)
+	b.side(side).each(func(pl *PriceLevel) bool {
+		prices = append(prices, pl.Price)
+		for n := pl.head; n != nil; n = n.next {
+			byPrice[pl.Price] = append(byPrice[pl.Price], &bidder{o: n.order, qty: n.shown(), old: true})
+		}
+		return true
+	})
+	for _, o := range seq {
+		if o.Side != side {
+			continue
+		}
+		bd := &bidder{o: o, qty: o.Qty}
+		if o.Type == domain.MarketOrder {
+			market = append(market, bd)
+			continue
+		}
+		if _, seen := byPrice[o.Price]; !seen {
+			prices = append(prices, o.Price)
+		}
+		byPrice[o.Price] = append(byPrice[o.Price], bd)
+	}
+	slices.Sort(prices)
+	if side == domain.Buy {
+		slices.Reverse(prices)
+	}
+	groups := [][]*bidder{market}
+	for _, p := range prices {
+		groups = append(groups, byPrice[p])
+	}
+	return groups
+}
+
+// eligible keeps the market orders and the price groups ok accepts
+func eligible(groups [][]*bidder, ok func(price int64) bool) [][]*bidder {
+	out := groups[:1:1]
+	for _, g := range groups[1:] {
+		if ok(g[0].o.Price) {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// interest sums the quantity of the market orders and the groups ok accepts
+func interest(groups [][]*bidder, ok func(price int64) bool) int64 {
+	var total int64
+	for i, g := range groups {
+		if i > 0 && !ok(g[0].o.Price) {
+			continue
+		}
+		for _, bd := range g {
+			total += bd.qty
+		}
+	}
+	return total
+}
+
+// clearingPrice tries every limit price on either side and returns the
+// best, with the volume it executes; volume 0 means nothing crosses
+func (b *Book) clearingPrice(buys, sells [][]*bidder) (price, volume int64) {
+	mid := b.BBO().MidPrice
+	dist := func(p int64) int64 { return abs64(p - mid) }
+	var imbalance int64
+	for _, side := range [2][][]*bidder{buys, sells} {
+		for _, g := range side[1:] {
+			p := g[0].o.Price
+			demand := interest(buys, func(q int64) bool { return q >= p })
+			supply := interest(sells, func(q int64) bool { return q <= p })
+			v, imb := min64(demand, supply), abs64(demand-supply)
+			if v == 0 {
+				continue
+			}
+			better := v > volume ||
+				v == volume && (imb < imbalance ||
+					imb == imbalance && (dist(p) < dist(price) || dist(p) == dist(price) && p < price))
+			if better {
+				price, volume, imbalance = p, v, imb
+			}
+		}
+	}
+	return price, volume
+}
+
+// allocate hands volume to one side's eligible groups in priority order;
+// the marginal group shares it in FIFO order, or pro-rata if the book is
+func (b *Book) allocate(groups [][]*bidder, volume int64) {
+	left := volume
+	for i, g := range groups {
+		if left == 0 {
+			return
+		}
+		var total int64
+		qtys := make([]int64, len(g))
+		for j, bd := range g {
+			qtys[j] = bd.qty
+			total += bd.qty
+		}
+		var alloc []int64
+		switch {
+		case total <= left:
+			alloc = qtys
+		case b.ProRata && i > 0:
+			alloc = proRata(qtys, left)
+		default:
+			alloc = make([]int64, len(g))
+			rest := left
+			for j, q := range qtys {
+				alloc[j] = min64(q, rest)
+				rest -= alloc[j]
+			}
+		}
+		for j, bd := range g {
+			bd.alloc, bd.open = alloc[j], alloc[j]
+			left -= alloc[j]
+		}
+	}
+}
+
+// split returns one side's allocated market and limit orders
+func split(groups [][]*bidder) (market, limit []*bidder) {
+	for _, g := range groups {
+		for _, bd := range g {
+			switch {
+			case bd.alloc == 0:
+			case bd.o.Type == domain.MarketOrder:
+				market = append(market, bd)
+			default:
+				limit = append(limit, bd)
+			}
+		}
+	}
+	return market, limit
+}
+
+// pair matches buyers' and sellers' open allocations in priority order.
+// The passive side of each fill is the resting order, or else the one
+// processed first
+func (a *auction) pair(xs, ys []*bidder, rank map[uint64]int) {
+	for i, j := 0, 0; i < len(xs) && j < len(ys); {
+		x, y := xs[i], ys[j]
+		if x.open == 0 {
+			i++
+			continue
+		}
+		if y.open == 0 {
+			j++
+			continue
+		}
+		q := min64(x.open, y.open)
+		x.open -= q
+		y.open -= q
+		aggressor, passive := x, y
+		if x.old || !y.old && rank[y.o.ID] > rank[x.o.ID] {
+			aggressor, passive = y, x
+		}
+		a.fills[aggressor.o.ID] = append(a.fills[aggressor.o.ID], auctionFill{passive: passive.o, qty: q})
+	}
+}
+
+func abs64(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
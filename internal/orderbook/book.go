@@ -4,59 +4,179 @@ package orderbook
 
 import (
 	"fmt"
-	"sort"
 
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
 )
 
 // PriceLevel holds all resting orders at a single price, in FIFO order
+// Orders form an intrusive doubly-linked list so removal from anywhere in
+// the queue is O(1); order count and total quantity are kept incrementally
+// and queue positions come from a ticket index (see queue.go). qty is the
+// displayed quantity; iceberg orders keep the rest of theirs in hidden
 type PriceLevel struct {
-	Price  int64
-	Orders []*domain.Order
+	Price int64
+
+	head, tail *orderNode
+	count      int
+	qty        int64
+	hidden     int64
+	tickets    fenwick
+}
+
+// orderNode links a resting order into its price level's FIFO queue
+type orderNode struct {
+	order      *domain.Order
+	level      *PriceLevel
+	prev, next *orderNode
+	ticket     int
+	since      int64 // when the order went on the book
+	hidden     int64 // an iceberg's qty behind its displayed slice
+
+	// The orders and qty ahead of it at its price when it went on the book
+	placedAhead    int
+	placedAheadQty int64
+}
+
+// stamp records on a trade against this node's order what stood ahead of
+// it when placed, and the qty still ahead now
+func (n *orderNode) stamp(t *domain.Trade, aheadQty int64) {
+	t.PlacedAhead, t.PlacedAheadQty, t.AheadQty = n.placedAhead, n.placedAheadQty, aheadQty
+}
+
+// shown is the qty of n's order on display
+func (n *orderNode) shown() int64 {
+	return n.order.RemainingQty - n.hidden
 }
 
-// TotalQty returns the sum of remaining quantities at this level
+// TotalQty returns the sum of displayed remaining quantities at this level
 func (pl *PriceLevel) TotalQty() int64 {
-	var total int64
-	for _, o := range pl.Orders {
-		total += o.RemainingQty
+	return pl.qty
+}
+
+// HiddenQty returns the qty iceberg orders at this level hold back from
+// display
+func (pl *PriceLevel) HiddenQty() int64 {
+	return pl.hidden
+}
+
+// Len returns the number of resting orders at this level
+func (pl *PriceLevel) Len() int {
+	return pl.count
+}
+
+// Orders returns the resting orders at this level in FIFO order
+func (pl *PriceLevel) Orders() []*domain.Order {
+	orders := make([]*domain.Order, 0, pl.count)
+	for n := pl.head; n != nil; n = n.next {
+		orders = append(orders, n.order)
 	}
-	return total
+	return orders
 }
 
-func (pl *PriceLevel) removeFilledOrders() {
-	n := 0
-	for _, o := range pl.Orders {
-		if o.RemainingQty > 0 {
-			pl.Orders[n] = o
-			n++
-		}
+// push appends a node to the back of the queue
+func (pl *PriceLevel) push(n *orderNode) {
+	n.level = pl
+	n.prev = pl.tail
+	n.next = nil
+	if pl.tail != nil {
+		pl.tail.next = n
+	} else {
+		pl.head = n
 	}
-	pl.Orders = pl.Orders[:n]
+	pl.tail = n
+	pl.count++
+	pl.qty += n.shown()
+	pl.hidden += n.hidden
+	pl.enqueue(n)
+}
+
+// unlink removes a node from the queue, releasing its remaining quantity
+func (pl *PriceLevel) unlink(n *orderNode) {
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else {
+		pl.head = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else {
+		pl.tail = n.prev
+	}
+	n.prev, n.next, n.level = nil, nil, nil
+	pl.count--
+	pl.qty -= n.shown()
+	pl.hidden -= n.hidden
+	pl.dequeue(n)
 }
 
 // Book is a single-instrument limit order book
 type Book struct {
-	Bids []*PriceLevel // sorted descending by price (best bid first)
-	Asks []*PriceLevel // sorted ascending by price (best ask first)
+	bids levelTree // best bid = highest price
+	asks levelTree // best ask = lowest price
 
-	// orderIndex maps order ID to the order pointer for fast cancel lookup
-	orderIndex map[uint64]*domain.Order
+	// orderIndex maps order ID to its queue node for O(1) cancel lookup
+	orderIndex map[uint64]*orderNode
 
 	nextTradeID uint64
 
 	lastBBO domain.BBO
+
+	// ProRata splits a fill smaller than a price level across its orders
+	// by size instead of filling them in time order
+	ProRata bool
+
+	// auction is the batch being crossed, between BeginAuction and EndAuction
+	auction *auction
+
+	// refreshes are the iceberg slices shown since the last Refreshes call
+	refreshes []Refresh
+}
+
+// Refresh is a resting iceberg order showing its next slice after a trade
+// took the last one
+type Refresh struct {
+	TradeID uint64 // the trade that took the last slice
+
+	// Order is a copy of the order as it stands after the refresh, with
+	// QueuePos the place the new slice took at the back of the queue
+	Order domain.Order
 }
 
 // New creates an empty order book
 func New() *Book {
 	return &Book{
-		orderIndex: make(map[uint64]*domain.Order),
+		bids:       levelTree{desc: true},
+		asks:       levelTree{desc: false},
+		orderIndex: make(map[uint64]*orderNode),
+	}
+}
+
+func (b *Book) side(s domain.Side) *levelTree {
+	if s == domain.Buy {
+		return &b.bids
 	}
+	return &b.asks
+}
+
+// Levels returns the price levels on one side, best price first
+func (b *Book) Levels(s domain.Side) []*PriceLevel {
+	tree := b.side(s)
+	levels := make([]*PriceLevel, 0, tree.size)
+	tree.each(func(pl *PriceLevel) bool {
+		levels = append(levels, pl)
+		return true
+	})
+	return levels
 }
 
 // ProcessOrder handles a limit, market, or cancel order
-// Returns any trades generated and the updated BBO
+// Returns any trades generated and the updated BBO. During a batch
+// auction orders execute their share of the batch and the BBO is nil
+// until EndAuction. An IOC or FOK limit order never rests: what it leaves
+// unfilled stays in its RemainingQty for the caller to cancel. An iceberg
+// order rests showing DisplayQty at a time; Refreshes returns the slices
+// its fills brought out
 func (b *Book) ProcessOrder(order *domain.Order, timestamp int64) ([]domain.Trade, *domain.BBO) {
 	switch order.Type {
 	case domain.LimitOrder:
@@ -64,7 +184,8 @@ func (b *Book) ProcessOrder(order *domain.Order, timestamp int64) ([]domain.Trad
 	case domain.MarketOrder:
 		return b.processMarket(order, timestamp)
 	case domain.CancelOrder:
-		return b.processCancel(order)
+		bbo, _ := b.Cancel(order)
+		return nil, bbo
 	default:
 		panic(fmt.Sprintf("unknown order type: %d", order.Type))
 	}
@@ -73,53 +194,118 @@ func (b *Book) ProcessOrder(order *domain.Order, timestamp int64) ([]domain.Trad
 // processLimit inserts a limit order, matching aggressively first
 func (b *Book) processLimit(order *domain.Order, timestamp int64) ([]domain.Trade, *domain.BBO) {
 	order.RemainingQty = order.Qty
+	if b.auction != nil {
+		return b.cross(order, timestamp), nil
+	}
+	// A fill-or-kill order the book cannot fill in full does not trade
+	if order.TimeInForce == domain.FOK && !b.fillable(order) {
+		return nil, b.BBO()
+	}
 	trades := b.match(order, timestamp)
 
-	// If not fully filled, rest on the book
-	if order.RemainingQty > 0 {
-		b.insert(order)
+	// If not fully filled, rest on the book, unless it must not
+	if order.RemainingQty > 0 && !order.Immediate() {
+		b.insert(order, timestamp)
 	}
 
 	bbo := b.BBO()
 	return trades, bbo
 }
 
+// fillable reports whether the opposite side holds enough qty at prices
+// a limit order accepts to fill it in full
+func (b *Book) fillable(order *domain.Order) bool {
+	var qty int64
+	b.side(order.Side.Opposite()).each(func(pl *PriceLevel) bool {
+		if order.Side == domain.Buy && pl.Price > order.Price || order.Side == domain.Sell && pl.Price < order.Price {
+			return false
+		}
+		qty += pl.qty + pl.hidden
+		return qty < order.Qty
+	})
+	return qty >= order.Qty
+}
+
 // processMarket sweeps the book. No resting
 func (b *Book) processMarket(order *domain.Order, timestamp int64) ([]domain.Trade, *domain.BBO) {
 	order.RemainingQty = order.Qty
+	if b.auction != nil {
+		return b.cross(order, timestamp), nil
+	}
 	trades := b.match(order, timestamp)
 	bbo := b.BBO()
 	return trades, bbo
 }
 
-// processCancel removes remaining quantity of the target order
-func (b *Book) processCancel(cancel *domain.Order) ([]domain.Trade, *domain.BBO) {
-	target, exists := b.orderIndex[cancel.CancelID]
-	if !exists || target.RemainingQty <= 0 {
-		// Already filled or unknown — no-op
-		return nil, b.BBO()
-	}
+// CancelError is why the book refused a cancel; the target, if any, is
+// left untouched
+type CancelError struct {
+	CancelID uint64 // the cancel instruction
+	TargetID uint64
+	Reason   string
+}
 
-	target.RemainingQty = 0
-	b.removeOrder(target)
-	delete(b.orderIndex, target.ID)
+func (e *CancelError) Error() string {
+	return fmt.Sprintf("cancel %d refused: %s", e.CancelID, e.Reason)
+}
 
-	return nil, b.BBO()
+// Cancel removes the remaining quantity of cancel's target, or, for a
+// cancel with a qty less than that, takes only its qty off and leaves the
+// target where it is in the queue. cancel's remaining qty is set to what
+// the target has left. A partial cancel takes an iceberg's hidden qty
+// before its displayed slice. It returns a *CancelError if the target is
+// not resting or fails domain.CheckCancel
+func (b *Book) Cancel(cancel *domain.Order) (*domain.BBO, error) {
+	cancel.RemainingQty = 0
+	node, exists := b.orderIndex[cancel.CancelID]
+	if !exists || node.order.RemainingQty <= 0 {
+		// Already filled, canceled or unknown
+		return b.BBO(), &CancelError{CancelID: cancel.ID, TargetID: cancel.CancelID,
+			Reason: fmt.Sprintf("target %d not resting", cancel.CancelID)}
+	}
+	if reason := domain.CheckCancel(cancel, node.order); reason != "" {
+		return b.BBO(), &CancelError{CancelID: cancel.ID, TargetID: cancel.CancelID, Reason: reason}
+	}
+
+	if cancel.Qty > 0 && cancel.Qty < node.order.RemainingQty {
+		hidden := min64(cancel.Qty, node.hidden)
+		node.hidden -= hidden
+		node.level.hidden -= hidden
+		node.order.RemainingQty -= cancel.Qty
+		node.level.qty -= cancel.Qty - hidden
+		cancel.RemainingQty = node.order.RemainingQty
+		return b.BBO(), nil
+	}
+	b.removeNode(node)
+	node.order.RemainingQty = 0
+
+	return b.BBO(), nil
+}
+
+// Clear takes every resting order off the book, as at a session close,
+// and returns them in ID order. Their remaining quantity is left as it
+// stood, so the caller can tell what expired
+func (b *Book) Clear() []*domain.Order {
+	orders := make([]*domain.Order, 0, len(b.orderIndex))
+	for _, id := range detorder.Keys(b.orderIndex) {
+		orders = append(orders, b.orderIndex[id].order)
+	}
+	b.bids = levelTree{desc: true}
+	b.asks = levelTree{desc: false}
+	b.orderIndex = make(map[uint64]*orderNode)
+	return orders
 }
 
 // match attempts to fill the incoming order against the opposite side
 func (b *Book) match(incoming *domain.Order, timestamp int64) []domain.Trade {
 	var trades []domain.Trade
-	var oppositeSide *[]*PriceLevel
-
-	if incoming.Side == domain.Buy {
-		oppositeSide = &b.Asks
-	} else {
-		oppositeSide = &b.Bids
-	}
+	opposite := b.side(incoming.Side.Opposite())
 
-	for incoming.RemainingQty > 0 && len(*oppositeSide) > 0 {
-		level := (*oppositeSide)[0]
+	for incoming.RemainingQty > 0 {
+		level := opposite.best()
+		if level == nil {
+			break
+		}
 
 		// Price check for limit orders
 		if incoming.Type == domain.LimitOrder {
@@ -131,116 +317,167 @@ func (b *Book) match(incoming *domain.Order, timestamp int64) []domain.Trade {
 			}
 		}
 
-		// Walk orders at this level in FIFO order
-		for i := 0; i < len(level.Orders) && incoming.RemainingQty > 0; {
-			resting := level.Orders[i]
-			fillQty := min64(incoming.RemainingQty, resting.RemainingQty)
+		// Under pro-rata a fill smaller than the level is split by size
+		var alloc []int64
+		if b.ProRata && incoming.RemainingQty < level.qty {
+			sizes := make([]int64, 0, level.count)
+			for n := level.head; n != nil; n = n.next {
+				sizes = append(sizes, n.shown())
+			}
+			alloc = proRata(sizes, incoming.RemainingQty)
+		}
+
+		// Walk orders at this level in FIFO order. Filled nodes are unlinked
+		// as we go, so counting the ones left in place gives each resting
+		// order's queue position without an index lookup. A refreshed
+		// iceberg goes to the back, where the walk reaches it again
+		ahead := 0         // orders left in place ahead of the current node
+		var aheadQty int64 // and their remaining qty
+		for i, n := 0, level.head; n != nil && incoming.RemainingQty > 0; i++ {
+			resting := n.order
+			fillQty := min64(incoming.RemainingQty, n.shown())
+			if alloc != nil {
+				fillQty = alloc[i]
+			}
+			next := n.next
+			if fillQty == 0 {
+				ahead++
+				aheadQty += n.shown()
+				n = next
+				continue
+			}
 
 			incoming.RemainingQty -= fillQty
 			resting.RemainingQty -= fillQty
+			level.qty -= fillQty
 
-			b.nextTradeID++
-			trade := domain.Trade{
-				ID:               b.nextTradeID,
-				Price:            resting.Price, // trade at resting order's price
-				Qty:              fillQty,
-				Timestamp:        timestamp,
-				PassiveOrderID:   resting.ID,
-				AggressorOrderID: incoming.ID,
-				RestingQueuePos:  i + 1, // 1-based position in FIFO queue
-			}
-			if incoming.Side == domain.Buy {
-				trade.BuyOrderID = incoming.ID
-				trade.SellOrderID = resting.ID
-				trade.BuyTrader = incoming.TraderID
-				trade.SellTrader = resting.TraderID
-			} else {
-				trade.SellOrderID = incoming.ID
-				trade.BuyOrderID = resting.ID
-				trade.SellTrader = incoming.TraderID
-				trade.BuyTrader = resting.TraderID
-			}
+			// trade at resting order's price
+			trade := b.trade(incoming, resting, resting.Price, fillQty, timestamp, ahead+1)
+			n.stamp(&trade, aheadQty)
 			trades = append(trades, trade)
 
-			if resting.RemainingQty <= 0 {
+			switch {
+			case resting.RemainingQty <= 0:
 				delete(b.orderIndex, resting.ID)
-				// Remove from slice by advancing
-				level.Orders = append(level.Orders[:i], level.Orders[i+1:]...)
-			} else {
-				i++
+				level.unlink(n)
+			case n.shown() == 0:
+				b.refresh(n, trade.ID)
+			default:
+				ahead++
+				aheadQty += n.shown()
 			}
+			n = next
 		}
 
 		// Remove empty levels
-		if len(level.Orders) == 0 {
-			*oppositeSide = (*oppositeSide)[1:]
+		if level.count == 0 {
+			opposite.remove(level.Price)
 		}
 	}
 
 	return trades
 }
 
-// insert places a resting order into the book at the appropriate level
-func (b *Book) insert(order *domain.Order) {
-	b.orderIndex[order.ID] = order
-
-	if order.Side == domain.Buy {
-		b.Bids = insertIntoLevels(b.Bids, order, true)
+// trade records a fill of qty between an incoming order and a resting one
+// at queuePos (1-based) in its level
+func (b *Book) trade(incoming, resting *domain.Order, price, qty, timestamp int64, queuePos int) domain.Trade {
+	b.nextTradeID++
+	trade := domain.Trade{
+		ID:               b.nextTradeID,
+		Price:            price,
+		Qty:              qty,
+		Timestamp:        timestamp,
+		PassiveOrderID:   resting.ID,
+		AggressorOrderID: incoming.ID,
+		RestingQueuePos:  queuePos,
+	}
+	if incoming.Side == domain.Buy {
+		trade.BuyOrderID = incoming.ID
+		trade.SellOrderID = resting.ID
+		trade.BuyTrader = incoming.TraderID
+		trade.SellTrader = resting.TraderID
 	} else {
-		b.Asks = insertIntoLevels(b.Asks, order, false)
+		trade.SellOrderID = incoming.ID
+		trade.BuyOrderID = resting.ID
+		trade.SellTrader = incoming.TraderID
+		trade.BuyTrader = resting.TraderID
 	}
+	return trade
 }
 
-// insertIntoLevels inserts an order into a sorted price level slice
-// descending=true for bids, false for asks
-func insertIntoLevels(levels []*PriceLevel, order *domain.Order, descending bool) []*PriceLevel {
-	// Find the level for this price
-	idx := sort.Search(len(levels), func(i int) bool {
-		if descending {
-			return levels[i].Price <= order.Price
+// proRata splits qty across orders of the given sizes, in FIFO order, in
+// proportion to size, rounding down, then hands out what rounding left in
+// FIFO order. qty must not exceed the sizes' total
+func proRata(sizes []int64, qty int64) []int64 {
+	var total int64
+	for _, s := range sizes {
+		total += s
+	}
+	alloc := make([]int64, len(sizes))
+	left := qty
+	for i, s := range sizes {
+		alloc[i] = qty * s / total
+		left -= alloc[i]
+	}
+	for i, s := range sizes {
+		if left == 0 {
+			break
 		}
-		return levels[i].Price >= order.Price
-	})
-
-	if idx < len(levels) && levels[idx].Price == order.Price {
-		// Append to existing level (FIFO)
-		levels[idx].Orders = append(levels[idx].Orders, order)
-		return levels
+		extra := min64(left, s-alloc[i])
+		alloc[i] += extra
+		left -= extra
 	}
+	return alloc
+}
 
-	// Insert new level
-	newLevel := &PriceLevel{
-		Price:  order.Price,
-		Orders: []*domain.Order{order},
-	}
-	levels = append(levels, nil)
-	copy(levels[idx+1:], levels[idx:])
-	levels[idx] = newLevel
-	return levels
+// refresh shows an iceberg's next slice once a trade has taken the last.
+// The slice joins the back of the queue: a refresh costs the order its
+// time priority, and what is ahead of the slice counts as placed ahead
+func (b *Book) refresh(n *orderNode, tradeID uint64) {
+	level := n.level
+	level.unlink(n)
+	n.hidden = n.order.RemainingQty - min64(n.order.DisplayQty, n.order.RemainingQty)
+	n.placedAhead, n.placedAheadQty = level.count, level.qty
+	level.push(n)
+
+	r := Refresh{TradeID: tradeID, Order: *n.order}
+	r.Order.QueuePos = level.count
+	b.refreshes = append(b.refreshes, r)
 }
 
-// removeOrder removes an order from its price level
-func (b *Book) removeOrder(order *domain.Order) {
-	var levels *[]*PriceLevel
-	if order.Side == domain.Buy {
-		levels = &b.Bids
-	} else {
-		levels = &b.Asks
+// Refreshes returns the iceberg refreshes since the last call, in the
+// order their trades were made, and forgets them
+func (b *Book) Refreshes() []Refresh {
+	r := b.refreshes
+	b.refreshes = nil
+	return r
+}
+
+// insert places a resting order at the back of its price level's queue.
+// An iceberg shows DisplayQty of what it has left
+func (b *Book) insert(order *domain.Order, timestamp int64) {
+	tree := b.side(order.Side)
+	level := tree.get(order.Price)
+	if level == nil {
+		level = &PriceLevel{Price: order.Price}
+		tree.insert(level)
 	}
 
-	for i, level := range *levels {
-		if level.Price != order.Price {
-			continue
-		}
-		for j, o := range level.Orders {
-			if o.ID == order.ID {
-				level.Orders = append(level.Orders[:j], level.Orders[j+1:]...)
-				if len(level.Orders) == 0 {
-					*levels = append((*levels)[:i], (*levels)[i+1:]...)
-				}
-				return
-			}
-		}
+	n := &orderNode{order: order, since: timestamp, placedAhead: level.count, placedAheadQty: level.qty}
+	if order.Iceberg() {
+		n.hidden = order.RemainingQty - min64(order.DisplayQty, order.RemainingQty)
+	}
+	level.push(n)
+	b.orderIndex[order.ID] = n
+}
+
+// removeNode unlinks a resting order and drops its level if now empty
+func (b *Book) removeNode(n *orderNode) {
+	level := n.level
+	level.unlink(n)
+	delete(b.orderIndex, n.order.ID)
+	if level.count == 0 {
+		b.side(n.order.Side).remove(level.Price)
 	}
 }
 
@@ -248,13 +485,13 @@ func (b *Book) removeOrder(order *domain.Order) {
 func (b *Book) BBO() *domain.BBO {
 	bbo := &domain.BBO{}
 
-	if len(b.Bids) > 0 {
-		bbo.BidPrice = b.Bids[0].Price
-		bbo.BidQty = b.Bids[0].TotalQty()
+	if best := b.bids.best(); best != nil {
+		bbo.BidPrice = best.Price
+		bbo.BidQty = best.TotalQty()
 	}
-	if len(b.Asks) > 0 {
-		bbo.AskPrice = b.Asks[0].Price
-		bbo.AskQty = b.Asks[0].TotalQty()
+	if best := b.asks.best(); best != nil {
+		bbo.AskPrice = best.Price
+		bbo.AskQty = best.TotalQty()
 	}
 	if bbo.BidPrice > 0 && bbo.AskPrice > 0 {
 		bbo.MidPrice = (bbo.BidPrice + bbo.AskPrice) / 2
@@ -264,119 +501,176 @@ func (b *Book) BBO() *domain.BBO {
 }
 
 // QueuePosition returns the position (1-based) of an order at its price level
-// Returns 0 if the order is not found on the book
+// Returns 0 if the order is not found on the book. O(log n) in the level size
+// During a batch auction a new order's is where its remainder will rest
 func (b *Book) QueuePosition(orderID uint64) int {
-	order, exists := b.orderIndex[orderID]
+	if b.auction != nil {
+		if pos, pending := b.auction.queuePos[orderID]; pending {
+			return pos
+		}
+	}
+	node, exists := b.orderIndex[orderID]
 	if !exists {
 		return 0
 	}
-
-	var levels []*PriceLevel
-	if order.Side == domain.Buy {
-		levels = b.Bids
-	} else {
-		levels = b.Asks
+	if node == node.level.tail {
+		return node.level.count
 	}
+	return node.level.position(node)
+}
 
-	for _, level := range levels {
-		if level.Price != order.Price {
-			continue
-		}
-		for i, o := range level.Orders {
-			if o.ID == orderID {
-				return i + 1
-			}
-		}
+// RestingSince returns when a resting order went on the book, and
+// whether it is resting
+func (b *Book) RestingSince(orderID uint64) (int64, bool) {
+	node, exists := b.orderIndex[orderID]
+	if !exists {
+		return 0, false
 	}
-	return 0
+	return node.since, true
 }
 
 // Depth returns the number of price levels on each side
 func (b *Book) Depth() (bidLevels, askLevels int) {
-	return len(b.Bids), len(b.Asks)
+	return b.bids.size, b.asks.size
+}
+
+// DepthSnapshot returns the best n price levels on each side
+func (b *Book) DepthSnapshot(n int) *domain.Depth {
+	d := &domain.Depth{}
+	for _, side := range []struct {
+		tree *levelTree
+		out  *[]domain.DepthLevel
+	}{{&b.bids, &d.Bids}, {&b.asks, &d.Asks}} {
+		side.tree.each(func(pl *PriceLevel) bool {
+			*side.out = append(*side.out, domain.DepthLevel{Price: pl.Price, Qty: pl.TotalQty(), Orders: pl.Len()})
+			return len(*side.out) < n
+		})
+	}
+	return d
 }
 
-// TotalVolume returns total resting volume on each side
+// TotalVolume returns total displayed resting volume on each side
 func (b *Book) TotalVolume() (bidVol, askVol int64) {
-	for _, level := range b.Bids {
-		bidVol += level.TotalQty()
+	b.bids.each(func(pl *PriceLevel) bool {
+		bidVol += pl.TotalQty()
+		return true
+	})
+	b.asks.each(func(pl *PriceLevel) bool {
+		askVol += pl.TotalQty()
+		return true
+	})
+	return
+}
+
+// AssertTopOfBook performs O(1) sanity checks on the best levels only
+// Cheap enough for every order; AssertInvariants does the full O(book) walk
+func (b *Book) AssertTopOfBook() {
+	bestBid, bestAsk := b.bids.best(), b.asks.best()
+	if bestBid != nil && bestBid.count == 0 {
+		panic(fmt.Sprintf("empty best bid level at price %d", bestBid.Price))
 	}
-	for _, level := range b.Asks {
-		askVol += level.TotalQty()
+	if bestAsk != nil && bestAsk.count == 0 {
+		panic(fmt.Sprintf("empty best ask level at price %d", bestAsk.Price))
+	}
+	if bestBid != nil && bestAsk != nil {
+		if bestBid.Price >= bestAsk.Price {
+			panic(fmt.Sprintf("crossed book: best bid %d >= best ask %d",
+				bestBid.Price, bestAsk.Price))
+		}
 	}
-	return
 }
 
 // AssertInvariants checks all book invariants. Panics on violation
 func (b *Book) AssertInvariants() {
+	bids := b.Levels(domain.Buy)
+	asks := b.Levels(domain.Sell)
+
 	// 1. Bids sorted descending
-	for i := 1; i < len(b.Bids); i++ {
-		if b.Bids[i].Price >= b.Bids[i-1].Price {
+	for i := 1; i < len(bids); i++ {
+		if bids[i].Price >= bids[i-1].Price {
 			panic(fmt.Sprintf("bid levels not sorted descending: %d >= %d at index %d",
-				b.Bids[i].Price, b.Bids[i-1].Price, i))
+				bids[i].Price, bids[i-1].Price, i))
 		}
 	}
 
 	// 2. Asks sorted ascending
-	for i := 1; i < len(b.Asks); i++ {
-		if b.Asks[i].Price <= b.Asks[i-1].Price {
+	for i := 1; i < len(asks); i++ {
+		if asks[i].Price <= asks[i-1].Price {
 			panic(fmt.Sprintf("ask levels not sorted ascending: %d <= %d at index %d",
-				b.Asks[i].Price, b.Asks[i-1].Price, i))
+				asks[i].Price, asks[i-1].Price, i))
 		}
 	}
 
 	// 3. No crossed book
-	if len(b.Bids) > 0 && len(b.Asks) > 0 {
-		if b.Bids[0].Price >= b.Asks[0].Price {
+	if len(bids) > 0 && len(asks) > 0 {
+		if bids[0].Price >= asks[0].Price {
 			panic(fmt.Sprintf("crossed book: best bid %d >= best ask %d",
-				b.Bids[0].Price, b.Asks[0].Price))
+				bids[0].Price, asks[0].Price))
 		}
 	}
 
-	// 4. No empty levels
-	for _, level := range b.Bids {
-		if len(level.Orders) == 0 {
-			panic(fmt.Sprintf("empty bid level at price %d", level.Price))
+	// 4-6. Per-level checks: non-empty, consistent links and counters,
+	// positive remaining quantities, and orderIndex agreement
+	count := 0
+	for _, levels := range [][]*PriceLevel{bids, asks} {
+		for _, level := range levels {
+			b.assertLevel(level)
+			count += level.count
 		}
 	}
-	for _, level := range b.Asks {
-		if len(level.Orders) == 0 {
-			panic(fmt.Sprintf("empty ask level at price %d", level.Price))
-		}
+	if len(bids) != b.bids.size || len(asks) != b.asks.size {
+		panic(fmt.Sprintf("level tree size mismatch: bids %d/%d asks %d/%d",
+			len(bids), b.bids.size, len(asks), b.asks.size))
 	}
-
-	// 5. No negative remaining quantities
-	for _, level := range b.Bids {
-		for _, o := range level.Orders {
-			if o.RemainingQty < 0 {
-				panic(fmt.Sprintf("negative remaining qty on bid order %d: %d", o.ID, o.RemainingQty))
-			}
-			if o.RemainingQty == 0 {
-				panic(fmt.Sprintf("zero remaining qty order %d still on book", o.ID))
-			}
-		}
+	if count != len(b.orderIndex) {
+		panic(fmt.Sprintf("orderIndex size %d != book order count %d", len(b.orderIndex), count))
 	}
-	for _, level := range b.Asks {
-		for _, o := range level.Orders {
-			if o.RemainingQty < 0 {
-				panic(fmt.Sprintf("negative remaining qty on ask order %d: %d", o.ID, o.RemainingQty))
-			}
-			if o.RemainingQty == 0 {
-				panic(fmt.Sprintf("zero remaining qty order %d still on book", o.ID))
-			}
-		}
+}
+
+func (b *Book) assertLevel(level *PriceLevel) {
+	if level.count == 0 || level.head == nil {
+		panic(fmt.Sprintf("empty level at price %d", level.Price))
 	}
 
-	// 6. orderIndex consistency
-	count := 0
-	for _, level := range b.Bids {
-		count += len(level.Orders)
+	var n, qty, hidden int64
+	var prev *orderNode
+	for node := level.head; node != nil; node = node.next {
+		o := node.order
+		if node.prev != prev || node.level != level {
+			panic(fmt.Sprintf("broken queue links for order %d at price %d", o.ID, level.Price))
+		}
+		if o.Price != level.Price {
+			panic(fmt.Sprintf("order %d price %d on level %d", o.ID, o.Price, level.Price))
+		}
+		if o.RemainingQty < 0 {
+			panic(fmt.Sprintf("negative remaining qty on order %d: %d", o.ID, o.RemainingQty))
+		}
+		if o.RemainingQty == 0 {
+			panic(fmt.Sprintf("zero remaining qty order %d still on book", o.ID))
+		}
+		if node.hidden < 0 || node.hidden > 0 && !o.Iceberg() {
+			panic(fmt.Sprintf("order %d hides %d", o.ID, node.hidden))
+		}
+		if node.shown() <= 0 || o.Iceberg() && node.shown() > o.DisplayQty {
+			panic(fmt.Sprintf("order %d shows %d of %d remaining", o.ID, node.shown(), o.RemainingQty))
+		}
+		if b.orderIndex[o.ID] != node {
+			panic(fmt.Sprintf("order %d missing from orderIndex", o.ID))
+		}
+		n++
+		if pos := level.position(node); int64(pos) != n {
+			panic(fmt.Sprintf("order %d queue position %d != actual %d", o.ID, pos, n))
+		}
+		qty += node.shown()
+		hidden += node.hidden
+		prev = node
 	}
-	for _, level := range b.Asks {
-		count += len(level.Orders)
+	if level.tail != prev {
+		panic(fmt.Sprintf("level %d tail does not match last order", level.Price))
 	}
-	if count != len(b.orderIndex) {
-		panic(fmt.Sprintf("orderIndex size %d != book order count %d", len(b.orderIndex), count))
+	if int64(level.count) != n || level.qty != qty || level.hidden != hidden {
+		panic(fmt.Sprintf("level %d counters (%d orders, qty %d, hidden %d) != actual (%d, %d, %d)",
+			level.Price, level.count, level.qty, level.hidden, n, qty, hidden))
 	}
 }
 
@@ -1,6 +1,8 @@
 package orderbook
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
@@ -140,6 +142,54 @@ func TestCancelRemovesRemainingOnly(t *testing.T) {
 	}
 }
 
+// TestPartialCancelKeepsPriority verifies that a cancel with a qty takes
+// only that much off its target, which keeps its place in the queue, and
+// that one for the whole remainder or more removes it
+func TestPartialCancelKeepsPriority(t *testing.T) {
+	book := New()
+	book.ProcessOrder(makeLimit(1, domain.Sell, 100, 10), 0)
+	book.ProcessOrder(makeLimit(2, domain.Sell, 100, 10), 0)
+
+	reduce := makeCancel(3, 1)
+	reduce.Qty = 6
+	bbo, err := book.Cancel(reduce)
+	if err != nil {
+		t.Fatalf("partial cancel refused: %v", err)
+	}
+	book.AssertInvariants()
+	if reduce.RemainingQty != 4 || bbo.AskQty != 14 {
+		t.Fatalf("after reducing by 6: target has %d left, ask qty %d; want 4 and 14", reduce.RemainingQty, bbo.AskQty)
+	}
+	if pos := book.QueuePosition(1); pos != 1 {
+		t.Fatalf("reduced order at queue position %d, want 1", pos)
+	}
+
+	trades, _ := book.ProcessOrder(makeMarket(4, domain.Buy, 5), 1)
+	if len(trades) != 2 || trades[0].PassiveOrderID != 1 || trades[0].Qty != 4 || trades[1].Qty != 1 {
+		t.Fatalf("expected 4 from order 1 then 1 from order 2, got %+v", trades)
+	}
+
+	all := makeCancel(5, 2)
+	all.Qty = 20
+	if _, err := book.Cancel(all); err != nil {
+		t.Fatalf("oversized partial cancel refused: %v", err)
+	}
+	book.AssertInvariants()
+	if all.RemainingQty != 0 {
+		t.Errorf("oversized partial cancel left %d", all.RemainingQty)
+	}
+	if _, askLevels := book.Depth(); askLevels != 0 {
+		t.Errorf("expected empty book, got %d ask levels", askLevels)
+	}
+
+	book.ProcessOrder(makeLimit(6, domain.Sell, 100, 10), 2)
+	negative := makeCancel(7, 6)
+	negative.Qty = -1
+	if _, err := book.Cancel(negative); err == nil {
+		t.Error("cancel with negative qty accepted")
+	}
+}
+
 // TestCancelUnknownOrderIsNoop verifies that canceling a non-existent order
 // doesn't panic or corrupt the book
 func TestCancelUnknownOrderIsNoop(t *testing.T) {
@@ -157,6 +207,44 @@ func TestCancelUnknownOrderIsNoop(t *testing.T) {
 	}
 }
 
+// TestCancelOwnershipAndConsistency verifies that the book refuses a
+// cancel from another trader or one naming the wrong side or price, and
+// leaves the target resting
+func TestCancelOwnershipAndConsistency(t *testing.T) {
+	book := New()
+	book.ProcessOrder(makeLimit(1, domain.Sell, 100, 10), 0)
+
+	foreign := makeCancel(2, 1)
+	foreign.TraderID = "rival"
+	wrongSide := makeCancel(3, 1)
+	wrongSide.Side, wrongSide.Price = domain.Buy, 100
+	wrongPrice := makeCancel(4, 1)
+	wrongPrice.Side, wrongPrice.Price = domain.Sell, 101
+	for _, c := range []*domain.Order{foreign, wrongSide, wrongPrice} {
+		_, err := book.Cancel(c)
+		var refused *CancelError
+		if !errors.As(err, &refused) || refused.TargetID != 1 {
+			t.Errorf("cancel %d: got %v, want a CancelError for order 1", c.ID, err)
+		}
+		book.AssertInvariants()
+	}
+	if pos := book.QueuePosition(1); pos != 1 {
+		t.Fatalf("order 1 queue position %d after refused cancels, want 1", pos)
+	}
+
+	matching := makeCancel(5, 1)
+	matching.Side, matching.Price = domain.Sell, 100
+	if _, err := book.Cancel(matching); err != nil {
+		t.Fatalf("matching cancel refused: %v", err)
+	}
+	if _, err := book.Cancel(makeCancel(6, 1)); err == nil {
+		t.Error("second cancel of order 1 accepted")
+	}
+	if _, askLevels := book.Depth(); askLevels != 0 {
+		t.Errorf("expected empty book, got %d ask levels", askLevels)
+	}
+}
+
 // TestCrossedLimitOrderMatchesImmediately verifies that a crossing limit
 // order is matched immediately (no crossed book)
 func TestCrossedLimitOrderMatchesImmediately(t *testing.T) {
@@ -271,6 +359,30 @@ func TestMultipleBidLevels(t *testing.T) {
 	}
 }
 
+// TestDepthSnapshot verifies snapshots list the best levels first and
+// stop at the requested depth
+func TestDepthSnapshot(t *testing.T) {
+	book := New()
+	for i, o := range []struct {
+		side       domain.Side
+		price, qty int64
+	}{{domain.Buy, 98, 10}, {domain.Buy, 100, 5}, {domain.Buy, 99, 8}, {domain.Buy, 100, 2}, {domain.Sell, 102, 4}} {
+		book.ProcessOrder(makeLimit(uint64(i+1), o.side, o.price, o.qty), 0)
+	}
+
+	d := book.DepthSnapshot(2)
+	want := []domain.DepthLevel{{Price: 100, Qty: 7, Orders: 2}, {Price: 99, Qty: 8, Orders: 1}}
+	if fmt.Sprint(d.Bids) != fmt.Sprint(want) {
+		t.Errorf("bids %v, want %v", d.Bids, want)
+	}
+	if len(d.Asks) != 1 || d.Asks[0] != (domain.DepthLevel{Price: 102, Qty: 4, Orders: 1}) {
+		t.Errorf("asks %v", d.Asks)
+	}
+	if d := New().DepthSnapshot(2); d.Bids != nil || d.Asks != nil {
+		t.Errorf("empty book snapshot %+v", d)
+	}
+}
+
 // TestQueuePosition verifies queue position tracking
 func TestQueuePosition(t *testing.T) {
 	book := New()
@@ -295,3 +407,534 @@ func TestQueuePosition(t *testing.T) {
 		t.Errorf("non-existent order: expected 0, got %d", pos)
 	}
 }
+
+// TestAssertTopOfBookDetectsCrossedBook verifies the cheap per-order check
+// catches a crossed top of book
+func TestAssertTopOfBookDetectsCrossedBook(t *testing.T) {
+	book := New()
+	book.ProcessOrder(makeLimit(1, domain.Buy, 99, 10), 0)
+	book.ProcessOrder(makeLimit(2, domain.Sell, 101, 10), 0)
+	book.AssertTopOfBook()
+
+	// Corrupt the book directly to simulate a matching bug
+	book.bids.best().Price = 102
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on crossed book")
+		}
+	}()
+	book.AssertTopOfBook()
+}
+
+// TestCancelMiddleOfQueue verifies cancelling from the middle of a level
+// keeps FIFO order and queue positions of the remaining orders
+func TestCancelMiddleOfQueue(t *testing.T) {
+	book := New()
+	book.ProcessOrder(makeLimit(1, domain.Buy, 100, 10), 0)
+	book.ProcessOrder(makeLimit(2, domain.Buy, 100, 5), 0)
+	book.ProcessOrder(makeLimit(3, domain.Buy, 100, 8), 0)
+
+	book.ProcessOrder(makeCancel(4, 2), 1)
+	book.AssertInvariants()
+
+	if pos := book.QueuePosition(3); pos != 2 {
+		t.Errorf("order 3 position after cancel: expected 2, got %d", pos)
+	}
+	bbo := book.BBO()
+	if bbo.BidQty != 18 {
+		t.Errorf("expected bid qty 18, got %d", bbo.BidQty)
+	}
+
+	trades, _ := book.ProcessOrder(makeMarket(5, domain.Sell, 12), 2)
+	book.AssertInvariants()
+	if len(trades) != 2 || trades[0].BuyOrderID != 1 || trades[1].BuyOrderID != 3 {
+		t.Fatalf("expected fills against orders 1 then 3, got %+v", trades)
+	}
+}
+
+// TestLevelsOrderedAfterChurn inserts and removes many levels and checks
+// both sides stay sorted best-first
+func TestLevelsOrderedAfterChurn(t *testing.T) {
+	book := New()
+	id := uint64(0)
+	for i := int64(0); i < 200; i++ {
+		id++
+		book.ProcessOrder(makeLimit(id, domain.Buy, 1000-(i*37)%500, 1), 0)
+		id++
+		book.ProcessOrder(makeLimit(id, domain.Sell, 1001+(i*53)%500, 1), 0)
+	}
+	book.AssertInvariants()
+
+	// Cancel every third order to punch holes in both sides
+	for target := uint64(1); target <= id; target += 3 {
+		book.ProcessOrder(makeCancel(id+target, target), 1)
+	}
+	book.AssertInvariants()
+
+	bids := book.Levels(domain.Buy)
+	for i := 1; i < len(bids); i++ {
+		if bids[i].Price >= bids[i-1].Price {
+			t.Fatalf("bids not descending at %d", i)
+		}
+	}
+	asks := book.Levels(domain.Sell)
+	for i := 1; i < len(asks); i++ {
+		if asks[i].Price <= asks[i-1].Price {
+			t.Fatalf("asks not ascending at %d", i)
+		}
+	}
+	bidLevels, askLevels := book.Depth()
+	if bidLevels != len(bids) || askLevels != len(asks) {
+		t.Errorf("Depth (%d, %d) != Levels (%d, %d)", bidLevels, askLevels, len(bids), len(asks))
+	}
+}
+
+// BenchmarkProcessOrder measures a limit insert + cancel round trip and a
+// small market sweep against books of increasing depth
+func BenchmarkProcessOrder(b *testing.B) {
+	for _, levels := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("levels=%d", levels), func(b *testing.B) {
+			book := New()
+			id := uint64(0)
+			for l := 0; l < levels; l++ {
+				for k := 0; k < 5; k++ {
+					id++
+					book.ProcessOrder(makeLimit(id, domain.Buy, int64(10_000-l), 10), 0)
+					id++
+					book.ProcessOrder(makeLimit(id, domain.Sell, int64(10_001+l), 10), 0)
+				}
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				id++
+				price := int64(10_000 - i%levels)
+				book.ProcessOrder(makeLimit(id, domain.Buy, price, 3), int64(i))
+				book.ProcessOrder(makeCancel(id+1, id), int64(i))
+				id++
+
+				// Sweep a little and replenish so depth stays constant
+				id++
+				book.ProcessOrder(makeMarket(id, domain.Sell, 2), int64(i))
+				id++
+				book.ProcessOrder(makeLimit(id, domain.Buy, book.BBO().BidPrice, 2), int64(i))
+			}
+		})
+	}
+}
+
+// TestQueuePositionAfterChurn checks indexed positions against a walk of
+// the queue while orders are filled from the front and canceled from the
+// middle, enough to force several ticket compactions
+func TestQueuePositionAfterChurn(t *testing.T) {
+	book := New()
+	id := uint64(0)
+	for i := 0; i < 500; i++ {
+		id++
+		book.ProcessOrder(makeLimit(id, domain.Buy, 100, 1+int64(i%3)), 0)
+	}
+	next := id + 1_000
+	for round := 0; round < 300; round++ {
+		next++
+		book.ProcessOrder(makeCancel(next, uint64(1+(round*7)%500)), 1)
+		next++
+		book.ProcessOrder(makeMarket(next, domain.Sell, 2), 1)
+		id++
+		book.ProcessOrder(makeLimit(id, domain.Buy, 100, 2), 1)
+
+		level := book.Levels(domain.Buy)[0]
+		for pos, o := range level.Orders() {
+			if got := book.QueuePosition(o.ID); got != pos+1 {
+				t.Fatalf("round %d: order %d at position %d, QueuePosition says %d", round, o.ID, pos+1, got)
+			}
+		}
+	}
+	book.AssertInvariants()
+}
+
+// BenchmarkQueuePosition looks up orders spread through one deep level
+func BenchmarkQueuePosition(b *testing.B) {
+	for _, depth := range []int{10, 1000, 100_000} {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			book := New()
+			for i := 1; i <= depth; i++ {
+				book.ProcessOrder(makeLimit(uint64(i), domain.Buy, 100, 1), 0)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				book.QueuePosition(uint64(1 + (i*7919)%depth))
+			}
+		})
+	}
+}
+
+// TestProRataSplitsBySize verifies that a pro-rata book shares a partial
+// level fill by resting size and hands the rounding remainder out FIFO
+func TestProRataSplitsBySize(t *testing.T) {
+	book := New()
+	book.ProRata = true
+	book.ProcessOrder(makeLimit(1, domain.Sell, 1000, 10), 0)
+	book.ProcessOrder(makeLimit(2, domain.Sell, 1000, 30), 0)
+	book.ProcessOrder(makeLimit(3, domain.Sell, 1000, 60), 0)
+
+	trades, _ := book.ProcessOrder(makeMarket(100, domain.Buy, 11), 1)
+	book.AssertInvariants()
+
+	// 11 of 100: shares 1.1, 3.3, 6.6 round down to 1, 3, 6; the spare
+	// share goes to the first order in time
+	want := map[uint64]int64{1: 2, 2: 3, 3: 6}
+	if len(trades) != len(want) {
+		t.Fatalf("expected %d trades, got %d", len(want), len(trades))
+	}
+	for _, tr := range trades {
+		if tr.Qty != want[tr.SellOrderID] {
+			t.Errorf("order %d filled %d, want %d", tr.SellOrderID, tr.Qty, want[tr.SellOrderID])
+		}
+	}
+
+	// An order that clears the level fills everyone in full, as FIFO would
+	trades, _ = book.ProcessOrder(makeMarket(101, domain.Buy, 89), 2)
+	book.AssertInvariants()
+	if len(trades) != 3 || book.BBO().AskPrice != 0 {
+		t.Fatalf("expected the level cleared in 3 trades, got %d trades", len(trades))
+	}
+}
+
+// TestTradeQueueContext verifies that a trade carries what stood ahead of
+// the passive order when it was placed and what is still ahead at the fill
+func TestTradeQueueContext(t *testing.T) {
+	book := New()
+	book.ProRata = true
+	book.ProcessOrder(makeLimit(1, domain.Sell, 1000, 10), 0)
+	book.ProcessOrder(makeLimit(2, domain.Sell, 1000, 30), 0)
+	book.ProcessOrder(makeLimit(3, domain.Sell, 1000, 60), 0)
+
+	// Allocations 2, 3, 6: each order's neighbours ahead keep the rest
+	trades, _ := book.ProcessOrder(makeMarket(100, domain.Buy, 11), 1)
+	want := []struct {
+		placed    int
+		placedQty int64
+		aheadQty  int64
+	}{{0, 0, 0}, {1, 10, 8}, {2, 40, 35}}
+	for i, tr := range trades {
+		w := want[i]
+		if tr.PlacedAhead != w.placed || tr.PlacedAheadQty != w.placedQty || tr.AheadQty != w.aheadQty {
+			t.Errorf("order %d: placed ahead %d/%d, ahead at fill %d; want %d/%d, %d",
+				tr.SellOrderID, tr.PlacedAhead, tr.PlacedAheadQty, tr.AheadQty, w.placed, w.placedQty, w.aheadQty)
+		}
+	}
+
+	// Under FIFO the order at the front has nothing ahead when it fills
+	book.ProRata = false
+	trades, _ = book.ProcessOrder(makeMarket(101, domain.Buy, 70), 2)
+	last := trades[len(trades)-1]
+	if last.SellOrderID != 3 || last.PlacedAheadQty != 40 || last.AheadQty != 0 {
+		t.Errorf("last trade = %+v", last)
+	}
+}
+
+// TestBatchAuctionUniformPrice verifies that a batch crosses at one price,
+// leaves the book uncrossed and honors time priority at the margin
+func TestBatchAuctionUniformPrice(t *testing.T) {
+	book := New()
+	book.ProcessOrder(makeLimit(1, domain.Sell, 1010, 5), 0)
+	book.ProcessOrder(makeLimit(2, domain.Buy, 990, 5), 0)
+
+	batch := []*domain.Order{
+		makeLimit(10, domain.Sell, 1000, 5),
+		makeLimit(11, domain.Buy, 1010, 8),
+		makeMarket(12, domain.Buy, 4),
+	}
+	seq := book.BeginAuction(batch, 5)
+	if book.AuctionPrice() != 1010 {
+		t.Fatalf("clearing price %d, want 1010", book.AuctionPrice())
+	}
+	if seq[len(seq)-1].ID != 12 {
+		t.Fatalf("market orders should be processed last")
+	}
+
+	var trades []domain.Trade
+	for _, o := range seq {
+		o.RemainingQty = o.Qty
+		ts, err := book.ProcessOrder(o, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trades = append(trades, ts...)
+	}
+	bbo, _ := book.EndAuction()
+	book.AssertInvariants()
+
+	// Demand 12 against supply 10: the market order fills in full and the
+	// limit buy gets the rest, the resting seller passive to all of it
+	var volume int64
+	for _, tr := range trades {
+		if tr.Price != 1010 {
+			t.Errorf("trade at %d, want the clearing price", tr.Price)
+		}
+		volume += tr.Qty
+	}
+	if volume != 10 {
+		t.Errorf("volume %d, want 10", volume)
+	}
+	if batch[2].RemainingQty != 0 {
+		t.Errorf("market order left %d unfilled", batch[2].RemainingQty)
+	}
+	if bbo.BidPrice != 1010 || bbo.BidQty != 2 || bbo.AskPrice != 0 {
+		t.Errorf("after auction BBO = %+v, want 2 bid at 1010 and no asks", bbo)
+	}
+}
+
+// TestBatchAuctionNothingCrosses verifies that a batch that cannot trade
+// goes through the continuous book unchanged
+func TestBatchAuctionNothingCrosses(t *testing.T) {
+	book := New()
+	book.ProcessOrder(makeLimit(1, domain.Sell, 1010, 5), 0)
+	seq := book.BeginAuction([]*domain.Order{makeLimit(2, domain.Buy, 1000, 5)}, 1)
+	if book.AuctionPrice() != 0 || len(seq) != 1 {
+		t.Fatalf("expected no auction")
+	}
+	seq[0].RemainingQty = seq[0].Qty
+	book.ProcessOrder(seq[0], 1)
+	if bbo, _ := book.EndAuction(); bbo.BidPrice != 1000 || bbo.AskPrice != 1010 {
+		t.Errorf("BBO = %+v", bbo)
+	}
+}
+
+// TestRestingSince verifies the time an order joined the book is kept
+// until it leaves
+func TestRestingSince(t *testing.T) {
+	book := New()
+	book.ProcessOrder(makeLimit(1, domain.Sell, 1000, 5), 42)
+	if since, ok := book.RestingSince(1); !ok || since != 42 {
+		t.Fatalf("RestingSince = %d, %v; want 42, true", since, ok)
+	}
+	book.ProcessOrder(makeCancel(2, 1), 50)
+	if _, ok := book.RestingSince(1); ok {
+		t.Fatal("canceled order still resting")
+	}
+}
+
+func TestClearEmptiesBook(t *testing.T) {
+	book := New()
+	book.ProcessOrder(makeLimit(3, domain.Sell, 1010, 5), 0)
+	book.ProcessOrder(makeLimit(1, domain.Buy, 990, 4), 0)
+	book.ProcessOrder(makeLimit(2, domain.Buy, 1000, 2), 0)
+	book.ProcessOrder(makeMarket(4, domain.Sell, 1), 1)
+
+	cleared := book.Clear()
+	if len(cleared) != 3 || cleared[0].ID != 1 || cleared[1].ID != 2 || cleared[2].ID != 3 {
+		t.Fatalf("cleared %v, want orders 1, 2, 3", cleared)
+	}
+	if cleared[1].RemainingQty != 1 {
+		t.Errorf("order 2 remaining %d, want 1", cleared[1].RemainingQty)
+	}
+	if bbo := book.BBO(); bbo.BidQty != 0 || bbo.AskQty != 0 {
+		t.Errorf("BBO after clear %+v, want empty", bbo)
+	}
+	book.AssertInvariants()
+
+	// The book takes orders again, and a cleared order cannot be canceled
+	book.ProcessOrder(makeLimit(5, domain.Buy, 995, 3), 2)
+	if _, err := book.Cancel(makeCancel(6, 1)); err == nil {
+		t.Error("cancel of a cleared order accepted")
+	}
+	if bbo := book.BBO(); bbo.BidPrice != 995 || bbo.BidQty != 3 {
+		t.Errorf("BBO %+v, want bid 995 x 3", bbo)
+	}
+}
+
+func makeTIF(id uint64, side domain.Side, price, qty int64, tif domain.TimeInForce) *domain.Order {
+	o := makeLimit(id, side, price, qty)
+	o.TimeInForce = tif
+	o.RemainingQty = qty
+	return o
+}
+
+// TestIOCNeverRests verifies that an IOC order fills what it can and
+// leaves its remainder off the book
+func TestIOCNeverRests(t *testing.T) {
+	book := New()
+	book.ProcessOrder(makeLimit(1, domain.Sell, 1000, 5), 0)
+	book.ProcessOrder(makeLimit(2, domain.Sell, 1010, 5), 0)
+
+	ioc := makeTIF(10, domain.Buy, 1000, 8, domain.IOC)
+	trades, bbo := book.ProcessOrder(ioc, 1)
+	book.AssertInvariants()
+	if len(trades) != 1 || trades[0].Qty != 5 {
+		t.Fatalf("trades = %+v, want 5 at 1000", trades)
+	}
+	if ioc.RemainingQty != 3 {
+		t.Errorf("remaining %d, want 3", ioc.RemainingQty)
+	}
+	if bbo.BidPrice != 0 || bbo.AskPrice != 1010 {
+		t.Errorf("BBO = %+v, want no bids", bbo)
+	}
+	if _, ok := book.RestingSince(10); ok {
+		t.Errorf("IOC remainder rests on the book")
+	}
+}
+
+// TestFOKAllOrNothing verifies that a FOK order trades only when it can
+// fill in full within its limit
+func TestFOKAllOrNothing(t *testing.T) {
+	book := New()
+	book.ProcessOrder(makeLimit(1, domain.Sell, 1000, 5), 0)
+	book.ProcessOrder(makeLimit(2, domain.Sell, 1010, 5), 0)
+
+	short := makeTIF(10, domain.Buy, 1000, 8, domain.FOK)
+	if trades, _ := book.ProcessOrder(short, 1); len(trades) != 0 {
+		t.Fatalf("FOK short of its quantity traded %+v", trades)
+	}
+	if short.RemainingQty != 8 {
+		t.Errorf("killed FOK remaining %d, want 8", short.RemainingQty)
+	}
+
+	full := makeTIF(11, domain.Buy, 1010, 8, domain.FOK)
+	trades, bbo := book.ProcessOrder(full, 2)
+	book.AssertInvariants()
+	var filled int64
+	for _, tr := range trades {
+		filled += tr.Qty
+	}
+	if filled != 8 || full.RemainingQty != 0 {
+		t.Errorf("filled %d, remaining %d; want a full fill", filled, full.RemainingQty)
+	}
+	if bbo.AskPrice != 1010 || bbo.AskQty != 2 || bbo.BidPrice != 0 {
+		t.Errorf("BBO = %+v", bbo)
+	}
+}
+
+// TestAuctionImmediateOrders verifies that a FOK the clearing volume
+// cannot fill sits out the auction and that unfilled immediate orders
+// come back from EndAuction instead of resting
+func TestAuctionImmediateOrders(t *testing.T) {
+	book := New()
+	book.ProcessOrder(makeLimit(1, domain.Sell, 1000, 5), 0)
+
+	batch := []*domain.Order{
+		makeTIF(10, domain.Buy, 1000, 8, domain.FOK),
+		makeTIF(11, domain.Buy, 1000, 3, domain.IOC),
+		makeTIF(12, domain.Buy, 990, 4, domain.IOC),
+	}
+	seq := book.BeginAuction(batch, 5)
+	if book.AuctionPrice() != 1000 {
+		t.Fatalf("clearing price %d, want 1000", book.AuctionPrice())
+	}
+	for _, o := range seq {
+		o.RemainingQty = o.Qty
+		if _, err := book.ProcessOrder(o, 5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bbo, killed := book.EndAuction()
+	book.AssertInvariants()
+
+	if batch[0].RemainingQty != 8 {
+		t.Errorf("FOK traded %d in an auction it could not fill", 8-batch[0].RemainingQty)
+	}
+	if batch[1].RemainingQty != 0 {
+		t.Errorf("IOC left %d unfilled", batch[1].RemainingQty)
+	}
+	ids := map[uint64]bool{}
+	for _, o := range killed {
+		ids[o.ID] = true
+	}
+	if len(killed) != 2 || !ids[10] || !ids[12] {
+		t.Errorf("killed = %v, want orders 10 and 12", ids)
+	}
+	if bbo.BidPrice != 0 || bbo.AskPrice != 1000 || bbo.AskQty != 2 {
+		t.Errorf("BBO = %+v, want 2 left at 1000 and no bids", bbo)
+	}
+}
+
+func makeIceberg(id uint64, side domain.Side, price, qty, display int64) *domain.Order {
+	o := makeLimit(id, side, price, qty)
+	o.DisplayQty = display
+	return o
+}
+
+// TestIcebergShowsSlice verifies that only an iceberg's displayed slice
+// shows in the BBO while its hidden reserve still trades
+func TestIcebergShowsSlice(t *testing.T) {
+	book := New()
+	_, bbo := book.ProcessOrder(makeIceberg(1, domain.Sell, 1000, 20, 5), 0)
+	book.AssertInvariants()
+	if bbo.AskPrice != 1000 || bbo.AskQty != 5 {
+		t.Fatalf("BBO = %+v, want 5 shown at 1000", bbo)
+	}
+	if _, ask := book.TotalVolume(); ask != 5 {
+		t.Errorf("ask volume %d, want only the displayed 5", ask)
+	}
+
+	trades, bbo := book.ProcessOrder(makeMarket(2, domain.Buy, 12), 1)
+	book.AssertInvariants()
+	var filled int64
+	for _, tr := range trades {
+		filled += tr.Qty
+	}
+	if filled != 12 || len(trades) != 3 {
+		t.Fatalf("market buy of 12 got %d in %d trades, want 12 across three slices", filled, len(trades))
+	}
+	if bbo.AskQty != 3 {
+		t.Errorf("ask qty %d after 12 of 20, want the last 3 shown", bbo.AskQty)
+	}
+}
+
+// TestIcebergRefreshLosesPriority verifies that a refreshed slice joins
+// the back of its level, behind orders that arrived after the iceberg
+func TestIcebergRefreshLosesPriority(t *testing.T) {
+	book := New()
+	book.ProcessOrder(makeIceberg(1, domain.Sell, 1000, 10, 4), 0)
+	book.ProcessOrder(makeLimit(2, domain.Sell, 1000, 3), 1)
+
+	book.ProcessOrder(makeMarket(3, domain.Buy, 4), 2)
+	book.AssertInvariants()
+	refreshes := book.Refreshes()
+	if len(refreshes) != 1 {
+		t.Fatalf("got %d refreshes, want 1", len(refreshes))
+	}
+	if r := refreshes[0].Order; r.ID != 1 || r.RemainingQty != 6 || r.QueuePos != 2 {
+		t.Errorf("refresh = %+v, want order 1 with 6 left at queue position 2", r)
+	}
+	if len(book.Refreshes()) != 0 {
+		t.Error("Refreshes did not drain")
+	}
+	if pos := book.QueuePosition(2); pos != 1 {
+		t.Errorf("later order at queue position %d, want 1", pos)
+	}
+
+	trades, _ := book.ProcessOrder(makeMarket(4, domain.Buy, 5), 3)
+	if len(trades) != 2 || trades[0].PassiveOrderID != 2 || trades[0].Qty != 3 || trades[1].PassiveOrderID != 1 {
+		t.Fatalf("expected order 2 to fill ahead of the refreshed slice, got %+v", trades)
+	}
+}
+
+// TestIcebergPartialCancelTakesHidden verifies that reducing an iceberg
+// comes out of its hidden reserve before its displayed slice, and that a
+// FOK counts the reserve as fillable
+func TestIcebergPartialCancelTakesHidden(t *testing.T) {
+	book := New()
+	book.ProcessOrder(makeIceberg(1, domain.Sell, 1000, 10, 4), 0)
+	reduce := makeCancel(2, 1)
+	reduce.Qty = 5
+	bbo, err := book.Cancel(reduce)
+	if err != nil {
+		t.Fatalf("partial cancel refused: %v", err)
+	}
+	book.AssertInvariants()
+	if reduce.RemainingQty != 5 || bbo.AskQty != 4 {
+		t.Fatalf("after reducing by 5: %d left, ask qty %d; want 5 and the slice still 4", reduce.RemainingQty, bbo.AskQty)
+	}
+
+	fok := makeTIF(3, domain.Buy, 1000, 5, domain.FOK)
+	trades, bbo := book.ProcessOrder(fok, 1)
+	book.AssertInvariants()
+	if fok.RemainingQty != 0 || len(trades) != 2 {
+		t.Fatalf("FOK for the shown and hidden 5 left %d in %d trades", fok.RemainingQty, len(trades))
+	}
+	if bbo.AskPrice != 0 {
+		t.Errorf("BBO = %+v, want the asks empty", bbo)
+	}
+}
@@ -0,0 +1,131 @@
+package fuzz
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/orderbook"
+)
+
+// CheckLog replays the order flow of a simulation event log through a
+// fresh order book and the reference matcher side by side. Every accepted
+// order must produce the same trades in both, and those trades must equal
+// the ones the run logged after it, and the same goes for the iceberg
+// refreshes its trades set off. Remaining quantity, queue position at
+// placement and the BBO are compared as well. Returns the first mismatch
+func CheckLog(path string) error {
+	reader, err := eventlog.NewReader(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	book := orderbook.New()
+	ref := NewReference()
+
+	// Trades the last order produced, matched off against the log as its
+	// TRADE_EXECUTED records follow
+	var expected []domain.Trade
+	var refreshes []orderbook.Refresh
+	var source uint64
+	var bbo *domain.BBO
+
+	for line := 1; ; line++ {
+		e, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		switch p := e.Payload.(type) {
+		case *domain.Trade:
+			if len(expected) == 0 {
+				return fmt.Errorf("line %d: logged trade %d that neither matcher produced", line, p.ID)
+			}
+			if *p != expected[0] {
+				return fmt.Errorf("line %d: logged trade %+v, matchers %+v", line, *p, expected[0])
+			}
+			expected = expected[1:]
+			continue
+		case *domain.BBO:
+			if bbo == nil || *p != *bbo {
+				return fmt.Errorf("line %d: logged BBO %+v, matchers %+v", line, *p, bbo)
+			}
+			continue
+		case *domain.Order:
+			if e.Type != domain.EventIcebergRefresh {
+				break
+			}
+			if len(refreshes) == 0 {
+				return fmt.Errorf("line %d: logged refresh of order %d the book did not make", line, p.ID)
+			}
+			if got := refreshes[0].Order; p.ID != got.ID || p.RemainingQty != got.RemainingQty || p.QueuePos != got.QueuePos {
+				return fmt.Errorf("line %d: logged refresh %+v, book %+v", line, *p, got)
+			}
+			refreshes = refreshes[1:]
+			continue
+		}
+		if e.SeqNo == 0 {
+			continue
+		}
+
+		// A new loop event: the previous order's trades must all be logged
+		if len(expected) > 0 {
+			return fmt.Errorf("line %d: order %d trade %+v missing from log", line, source, expected[0])
+		}
+		if len(refreshes) > 0 {
+			return fmt.Errorf("line %d: order %d refresh of %d missing from log", line, source, refreshes[0].Order.ID)
+		}
+		logged := e.Order()
+		if e.Type != domain.EventOrderAccepted || logged == nil {
+			continue
+		}
+
+		bookOrder, refOrder := *logged, *logged
+		bookOrder.RemainingQty = bookOrder.Qty
+		bookOrder.QueuePos = 0
+		refOrder.QueuePos = 0
+
+		got, gotBBO := book.ProcessOrder(&bookOrder, e.Timestamp)
+		want := ref.Process(&refOrder, e.Timestamp)
+
+		if len(got) != len(want) {
+			return fmt.Errorf("line %d: order %d produced %d trades, reference %d", line, logged.ID, len(got), len(want))
+		}
+		for k := range got {
+			if got[k] != want[k] {
+				return fmt.Errorf("line %d: order %d trade %d = %+v, reference %+v", line, logged.ID, k, got[k], want[k])
+			}
+		}
+		if bookOrder.RemainingQty != refOrder.RemainingQty {
+			return fmt.Errorf("line %d: order %d remaining %d, reference %d",
+				line, logged.ID, bookOrder.RemainingQty, refOrder.RemainingQty)
+		}
+		if bookOrder.RemainingQty != logged.RemainingQty {
+			return fmt.Errorf("line %d: order %d remaining %d, logged %d",
+				line, logged.ID, bookOrder.RemainingQty, logged.RemainingQty)
+		}
+		refBBO := ref.BBO()
+		if *gotBBO != *refBBO {
+			return fmt.Errorf("line %d: BBO %+v, reference %+v", line, *gotBBO, *refBBO)
+		}
+		if logged.Type == domain.LimitOrder && logged.RemainingQty > 0 {
+			pos, refPos := book.QueuePosition(logged.ID), ref.QueuePosition(logged.ID)
+			if pos != refPos || pos != logged.QueuePos {
+				return fmt.Errorf("line %d: order %d queue position %d, reference %d, logged %d",
+					line, logged.ID, pos, refPos, logged.QueuePos)
+			}
+		}
+
+		expected, refreshes, source, bbo = got, book.Refreshes(), logged.ID, gotBBO
+	}
+
+	if len(expected) > 0 {
+		return fmt.Errorf("order %d trade %+v missing from log", source, expected[0])
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package fuzz
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+func runScenario(t *testing.T, name string, seed int64) string {
+	t.Helper()
+	cfg := scenario.GetConfig(name, seed)
+	r, err := sim.NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.LogPath
+}
+
+// TestScenarioFlowsMatchReference runs full scenario order flow, background
+// and both traders, through the book and the brute-force reference
+func TestScenarioFlowsMatchReference(t *testing.T) {
+	seeds := []int64{1, 42, 1234}
+	if testing.Short() {
+		seeds = seeds[:1]
+	}
+	for _, name := range []string{"calm", "thin", "spike"} {
+		for _, seed := range seeds {
+			logPath := runScenario(t, name, seed)
+			if err := CheckLog(logPath); err != nil {
+				t.Errorf("%s seed %d: %v", name, seed, err)
+			}
+		}
+	}
+}
+
+func TestCheckLogDetectsTamperedTrade(t *testing.T) {
+	logPath := runScenario(t, "thin", 42)
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if strings.Contains(line, `"type":"TRADE_EXECUTED"`) {
+			lines[i] = strings.Replace(line, `"resting_queue_pos":1`, `"resting_queue_pos":2`, 1)
+			if lines[i] != line {
+				break
+			}
+		}
+	}
+	tampered := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := os.WriteFile(tampered, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckLog(tampered); err == nil || !strings.Contains(err.Error(), "logged trade") {
+		t.Fatalf("err = %v, want logged trade mismatch", err)
+	}
+}
@@ -0,0 +1,69 @@
+package fuzz
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// TestRandomSequences runs seeded random sequences through Check so the
+// properties are exercised on every go test, not only under -fuzz
+func TestRandomSequences(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	n := 300
+	if testing.Short() {
+		n = 30
+	}
+	for i := 0; i < n; i++ {
+		data := make([]byte, opByteSize*(1+rng.Intn(400)))
+		rng.Read(data)
+		if err := Check(Decode(data)); err != nil {
+			t.Fatalf("sequence %d: %v", i, err)
+		}
+	}
+}
+
+// TestReferencePriceTimePriority pins down the reference matcher itself:
+// better price first, then arrival order within a price
+func TestReferencePriceTimePriority(t *testing.T) {
+	ref := NewReference()
+	ref.Process(&domain.Order{ID: 1, Side: domain.Sell, Type: domain.LimitOrder, Price: 101, Qty: 5}, 0)
+	ref.Process(&domain.Order{ID: 2, Side: domain.Sell, Type: domain.LimitOrder, Price: 100, Qty: 5}, 0)
+	ref.Process(&domain.Order{ID: 3, Side: domain.Sell, Type: domain.LimitOrder, Price: 100, Qty: 5}, 0)
+
+	trades := ref.Process(&domain.Order{ID: 4, Side: domain.Buy, Type: domain.MarketOrder, Qty: 12}, 1)
+	want := []struct {
+		passive uint64
+		qty     int64
+		pos     int
+	}{{2, 5, 1}, {3, 5, 1}, {1, 2, 1}}
+	if len(trades) != len(want) {
+		t.Fatalf("expected %d trades, got %d", len(want), len(trades))
+	}
+	for i, w := range want {
+		if trades[i].PassiveOrderID != w.passive || trades[i].Qty != w.qty || trades[i].RestingQueuePos != w.pos {
+			t.Errorf("trade %d = %+v, want passive %d qty %d pos %d", i, trades[i], w.passive, w.qty, w.pos)
+		}
+	}
+}
+
+func TestDecodeIgnoresTrailingBytes(t *testing.T) {
+	if ops := Decode([]byte{0, 1, 2, 3, 4, 5}); len(ops) != 1 {
+		t.Fatalf("expected 1 op, got %d", len(ops))
+	}
+}
+
+func FuzzBook(f *testing.F) {
+	// A few hand-picked seeds: resting then crossing, cancel of a resting
+	// order, modify of a partially filled one, and a reduction then a sweep
+	f.Add([]byte{0, 0, 4, 0, 0, 1, 4, 0, 1, 0, 9, 0})
+	f.Add([]byte{0, 10, 5, 0, 0, 10, 5, 0, 2, 0, 0, 0, 1, 1, 3, 0})
+	f.Add([]byte{0, 3, 9, 0, 0, 4, 2, 0, 1, 0, 1, 0, 3, 5, 7, 0, 1, 1, 9, 1})
+	f.Add([]byte{0, 0, 9, 0, 0, 0, 9, 0, 4, 0, 3, 0, 1, 1, 9, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := Check(Decode(data)); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
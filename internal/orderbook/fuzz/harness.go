@@ -0,0 +1,212 @@
+package fuzz
+
+import (
+	"fmt"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/orderbook"
+)
+
+// OpKind is the kind of a generated book operation
+type OpKind uint8
+
+const (
+	OpLimit OpKind = iota
+	OpMarket
+	OpCancel
+	OpModify  // cancel-replace: cancel Target, then a new limit on its side
+	OpReduce  // partial cancel: take Qty off Target
+	OpIceberg // limit showing 1 + Target%Qty at a time
+)
+
+// Op is one generated operation. Target picks among previously placed
+// limit orders (modulo their count) for cancels, modifies and reductions
+type Op struct {
+	Kind   OpKind
+	Side   domain.Side
+	Price  int64
+	Qty    int64
+	Target int
+}
+
+// Prices are drawn from a narrow band around basePrice so that orders
+// cross, queue behind each other and empty levels often
+const (
+	basePrice  = 100
+	priceBand  = 11
+	maxQty     = 10
+	opByteSize = 4
+)
+
+// Decode turns arbitrary bytes into operations, four bytes per op
+// Every input decodes to something valid so the fuzzer never wastes work
+func Decode(data []byte) []Op {
+	ops := make([]Op, 0, len(data)/opByteSize)
+	for len(data) >= opByteSize {
+		b := data[:opByteSize]
+		data = data[opByteSize:]
+		side := domain.Buy
+		if b[1]&1 == 1 {
+			side = domain.Sell
+		}
+		ops = append(ops, Op{
+			Kind:   OpKind(b[0] % 6),
+			Side:   side,
+			Price:  basePrice - priceBand/2 + int64(b[1]>>1)%priceBand,
+			Qty:    1 + int64(b[2])%maxQty,
+			Target: int(b[3]),
+		})
+	}
+	return ops
+}
+
+// Check runs ops through both the order book and the reference matcher
+// and returns the first property violation, or nil. Properties checked:
+//   - book invariants hold after every order (AssertInvariants)
+//   - trades, remaining quantities, BBO and queue positions match the
+//     reference exactly, which pins down price-time priority
+//   - no order ever has negative remaining quantity
+//   - limit aggressors never trade through their price
+//   - every order's fills, remaining and canceled quantity add up to its
+//     quantity, and buy and sell fills balance
+//   - a cancel's remaining quantity is what its target has left
+//   - resting volume per side matches the reference
+func Check(ops []Op) (err error) {
+	book := orderbook.New()
+	ref := NewReference()
+
+	step := 0
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("op %d: panic: %v", step, p)
+		}
+	}()
+
+	var placed []uint64
+	orders := make(map[uint64]*domain.Order)
+	canceled := make(map[uint64]int64) // qty taken off by cancels
+	filled := make(map[uint64]int64)
+	var buyFilled, sellFilled int64
+	nextID := uint64(0)
+
+	newOrder := func(kind domain.OrderType, side domain.Side, price, qty int64) *domain.Order {
+		nextID++
+		return &domain.Order{ID: nextID, TraderID: "fuzz", Side: side, Type: kind, Price: price, Qty: qty}
+	}
+
+	for i, op := range ops {
+		step = i
+		var batch []*domain.Order
+		switch op.Kind {
+		case OpLimit:
+			batch = append(batch, newOrder(domain.LimitOrder, op.Side, op.Price, op.Qty))
+		case OpIceberg:
+			o := newOrder(domain.LimitOrder, op.Side, op.Price, op.Qty)
+			o.DisplayQty = 1 + int64(op.Target)%op.Qty
+			batch = append(batch, o)
+		case OpMarket:
+			batch = append(batch, newOrder(domain.MarketOrder, op.Side, 0, op.Qty))
+		case OpCancel, OpModify, OpReduce:
+			if len(placed) == 0 {
+				continue
+			}
+			target := orders[placed[op.Target%len(placed)]]
+			cancel := newOrder(domain.CancelOrder, target.Side, 0, 0)
+			cancel.CancelID = target.ID
+			if op.Kind == OpReduce {
+				cancel.Qty = op.Qty
+			}
+			batch = append(batch, cancel)
+			if op.Kind == OpModify {
+				batch = append(batch, newOrder(domain.LimitOrder, target.Side, op.Price, op.Qty))
+			}
+		}
+
+		for _, o := range batch {
+			bookOrder, refOrder := *o, *o
+			ts := int64(i)
+			var before int64
+			if o.Type == domain.CancelOrder {
+				before = orders[o.CancelID].RemainingQty
+			}
+			got, bbo := book.ProcessOrder(&bookOrder, ts)
+			want := ref.Process(&refOrder, ts)
+			book.AssertInvariants()
+
+			if len(got) != len(want) {
+				return fmt.Errorf("op %d: order %d produced %d trades, reference %d", i, o.ID, len(got), len(want))
+			}
+			for k := range got {
+				if got[k] != want[k] {
+					return fmt.Errorf("op %d: trade %d = %+v, reference %+v", i, k, got[k], want[k])
+				}
+			}
+			if bookOrder.RemainingQty != refOrder.RemainingQty {
+				return fmt.Errorf("op %d: order %d remaining %d, reference %d",
+					i, o.ID, bookOrder.RemainingQty, refOrder.RemainingQty)
+			}
+			if bookOrder.RemainingQty < 0 {
+				return fmt.Errorf("op %d: order %d negative remaining %d", i, o.ID, bookOrder.RemainingQty)
+			}
+			if want := ref.BBO(); *bbo != *want {
+				return fmt.Errorf("op %d: BBO %+v, reference %+v", i, *bbo, *want)
+			}
+
+			for _, t := range got {
+				if bookOrder.Type == domain.LimitOrder {
+					if (bookOrder.Side == domain.Buy && t.Price > bookOrder.Price) ||
+						(bookOrder.Side == domain.Sell && t.Price < bookOrder.Price) {
+						return fmt.Errorf("op %d: order %d at %d traded through at %d", i, o.ID, bookOrder.Price, t.Price)
+					}
+				}
+				filled[t.BuyOrderID] += t.Qty
+				filled[t.SellOrderID] += t.Qty
+				buyFilled += t.Qty
+				sellFilled += t.Qty
+			}
+
+			switch o.Type {
+			case domain.LimitOrder:
+				// The book keeps a pointer to bookOrder and updates it on fills
+				orders[o.ID] = &bookOrder
+				placed = append(placed, o.ID)
+			case domain.MarketOrder:
+				orders[o.ID] = &bookOrder
+			case domain.CancelOrder:
+				target := orders[o.CancelID]
+				canceled[o.CancelID] += before - target.RemainingQty
+				if bookOrder.RemainingQty != target.RemainingQty {
+					return fmt.Errorf("op %d: cancel %d says target has %d left, book %d",
+						i, o.ID, bookOrder.RemainingQty, target.RemainingQty)
+				}
+			}
+		}
+
+		for _, id := range placed {
+			if got, want := book.QueuePosition(id), ref.QueuePosition(id); got != want {
+				return fmt.Errorf("op %d: order %d queue position %d, reference %d", i, id, got, want)
+			}
+		}
+	}
+
+	for id, o := range orders {
+		switch {
+		case o.RemainingQty < 0:
+			return fmt.Errorf("order %d negative remaining %d", id, o.RemainingQty)
+		case filled[id] > o.Qty:
+			return fmt.Errorf("order %d overfilled: %d of %d", id, filled[id], o.Qty)
+		case filled[id]+o.RemainingQty+canceled[id] != o.Qty:
+			return fmt.Errorf("order %d filled %d + remaining %d + canceled %d != qty %d",
+				id, filled[id], o.RemainingQty, canceled[id], o.Qty)
+		}
+	}
+	if buyFilled != sellFilled {
+		return fmt.Errorf("buy fills %d != sell fills %d", buyFilled, sellFilled)
+	}
+	bookBid, bookAsk := book.TotalVolume()
+	refBid, refAsk := ref.TotalVolume()
+	if bookBid != refBid || bookAsk != refAsk {
+		return fmt.Errorf("resting volume (%d, %d), reference (%d, %d)", bookBid, bookAsk, refBid, refAsk)
+	}
+	return nil
+}
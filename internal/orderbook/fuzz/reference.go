@@ -0,0 +1,217 @@
+// Package fuzz drives the order book with random operation sequences and
+// checks it against invariants, conservation properties and a deliberately
+// naive reference matcher
+package fuzz
+
+import (
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// Reference is a price-time priority matcher written for obviousness
+// rather than speed: resting orders live in one slice in arrival order and
+// every match scans the whole slice for the best counterparty. A refreshed
+// iceberg moves to the end of the slice
+type Reference struct {
+	resting     []*domain.Order
+	nextTradeID uint64
+
+	// placed is what stood ahead of each resting order when it rested
+	placed map[uint64]ahead
+
+	// hidden is each resting iceberg's qty behind its displayed slice
+	hidden map[uint64]int64
+}
+
+type ahead struct {
+	orders int
+	qty    int64
+}
+
+// NewReference creates an empty reference book
+func NewReference() *Reference {
+	return &Reference{placed: make(map[uint64]ahead), hidden: make(map[uint64]int64)}
+}
+
+// Process handles a limit, market, or cancel order and returns its trades.
+// A partial cancel shrinks its target in place, hidden qty first
+func (r *Reference) Process(order *domain.Order, timestamp int64) []domain.Trade {
+	if order.Type == domain.CancelOrder {
+		order.RemainingQty = 0
+		for i, o := range r.resting {
+			if o.ID == order.CancelID {
+				if domain.CheckCancel(order, o) != "" {
+					break
+				}
+				if order.Qty > 0 && order.Qty < o.RemainingQty {
+					r.hidden[o.ID] -= min(order.Qty, r.hidden[o.ID])
+					o.RemainingQty -= order.Qty
+					order.RemainingQty = o.RemainingQty
+					break
+				}
+				o.RemainingQty = 0
+				delete(r.hidden, o.ID)
+				r.resting = append(r.resting[:i], r.resting[i+1:]...)
+				break
+			}
+		}
+		return nil
+	}
+
+	order.RemainingQty = order.Qty
+	var trades []domain.Trade
+	for order.RemainingQty > 0 {
+		best := r.best(order.Side.Opposite())
+		if best < 0 {
+			break
+		}
+		resting := r.resting[best]
+		if order.Type == domain.LimitOrder {
+			if order.Side == domain.Buy && order.Price < resting.Price {
+				break
+			}
+			if order.Side == domain.Sell && order.Price > resting.Price {
+				break
+			}
+		}
+
+		qty := min(order.RemainingQty, r.shown(resting))
+		order.RemainingQty -= qty
+		resting.RemainingQty -= qty
+
+		r.nextTradeID++
+		trade := domain.Trade{
+			ID:               r.nextTradeID,
+			Price:            resting.Price,
+			Qty:              qty,
+			Timestamp:        timestamp,
+			PassiveOrderID:   resting.ID,
+			AggressorOrderID: order.ID,
+			RestingQueuePos:  r.position(best),
+			PlacedAhead:      r.placed[resting.ID].orders,
+			PlacedAheadQty:   r.placed[resting.ID].qty,
+			AheadQty:         r.ahead(best).qty,
+		}
+		buy, sell := order, resting
+		if order.Side == domain.Sell {
+			buy, sell = resting, order
+		}
+		trade.BuyOrderID, trade.BuyTrader = buy.ID, buy.TraderID
+		trade.SellOrderID, trade.SellTrader = sell.ID, sell.TraderID
+		trades = append(trades, trade)
+
+		switch {
+		case resting.RemainingQty == 0:
+			r.resting = append(r.resting[:best], r.resting[best+1:]...)
+			delete(r.hidden, resting.ID)
+		case r.shown(resting) == 0:
+			r.resting = append(r.resting[:best], r.resting[best+1:]...)
+			r.rest(resting)
+		}
+	}
+
+	if order.Type == domain.LimitOrder && order.RemainingQty > 0 {
+		r.rest(order)
+	}
+	return trades
+}
+
+// rest appends an order to the slice, an iceberg showing DisplayQty of
+// what it has left
+func (r *Reference) rest(o *domain.Order) {
+	if o.Iceberg() {
+		r.hidden[o.ID] = o.RemainingQty - min(o.DisplayQty, o.RemainingQty)
+	}
+	r.resting = append(r.resting, o)
+	r.placed[o.ID] = r.ahead(len(r.resting) - 1)
+}
+
+// shown is the displayed qty of a resting order
+func (r *Reference) shown(o *domain.Order) int64 {
+	return o.RemainingQty - r.hidden[o.ID]
+}
+
+// best returns the index of the highest-priority resting order on a side,
+// or -1 if there is none. Earlier arrival wins ties because the slice is
+// in arrival order and only a strictly better price replaces the pick
+func (r *Reference) best(side domain.Side) int {
+	best := -1
+	for i, o := range r.resting {
+		if o.Side != side {
+			continue
+		}
+		if best < 0 ||
+			(side == domain.Buy && o.Price > r.resting[best].Price) ||
+			(side == domain.Sell && o.Price < r.resting[best].Price) {
+			best = i
+		}
+	}
+	return best
+}
+
+// position returns the 1-based queue position of resting[i] at its price
+func (r *Reference) position(i int) int {
+	return r.ahead(i).orders + 1
+}
+
+// ahead counts the orders, and sums the qty, ahead of resting[i] at its
+// price
+func (r *Reference) ahead(i int) ahead {
+	var a ahead
+	for _, o := range r.resting[:i] {
+		if o.Side == r.resting[i].Side && o.Price == r.resting[i].Price {
+			a.orders++
+			a.qty += r.shown(o)
+		}
+	}
+	return a
+}
+
+// QueuePosition returns an order's 1-based queue position, or 0 if it is
+// not resting
+func (r *Reference) QueuePosition(orderID uint64) int {
+	for i, o := range r.resting {
+		if o.ID == orderID {
+			return r.position(i)
+		}
+	}
+	return 0
+}
+
+// BBO returns the best bid and offer in the same form as the book
+func (r *Reference) BBO() *domain.BBO {
+	bbo := &domain.BBO{}
+	if i := r.best(domain.Buy); i >= 0 {
+		bbo.BidPrice = r.resting[i].Price
+		bbo.BidQty = r.levelQty(domain.Buy, bbo.BidPrice)
+	}
+	if i := r.best(domain.Sell); i >= 0 {
+		bbo.AskPrice = r.resting[i].Price
+		bbo.AskQty = r.levelQty(domain.Sell, bbo.AskPrice)
+	}
+	if bbo.BidPrice > 0 && bbo.AskPrice > 0 {
+		bbo.MidPrice = (bbo.BidPrice + bbo.AskPrice) / 2
+	}
+	return bbo
+}
+
+func (r *Reference) levelQty(side domain.Side, price int64) int64 {
+	var qty int64
+	for _, o := range r.resting {
+		if o.Side == side && o.Price == price {
+			qty += r.shown(o)
+		}
+	}
+	return qty
+}
+
+// TotalVolume returns total displayed resting volume on each side
+func (r *Reference) TotalVolume() (bidVol, askVol int64) {
+	for _, o := range r.resting {
+		if o.Side == domain.Buy {
+			bidVol += r.shown(o)
+		} else {
+			askVol += r.shown(o)
+		}
+	}
+	return
+}
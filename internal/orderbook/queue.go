@@ -0,0 +1,78 @@
+package orderbook
+
+// Queue positions are answered from a Fenwick tree over each level's push
+// tickets: every push takes the next ticket and marks it live, unlink clears
+// it, and a node's position is the number of live tickets up to its own
+// Dead tickets are compacted away once they outnumber live ones, so the
+// tree stays proportional to the queue and every operation is O(log n)
+
+// fenwick is a binary indexed tree of per-ticket counts, 0-based externally
+type fenwick []int32
+
+// add adds delta at index i
+func (f fenwick) add(i int, delta int32) {
+	for i++; i <= len(f); i += i & -i {
+		f[i-1] += delta
+	}
+}
+
+// prefix returns the sum over indexes [0, i)
+func (f fenwick) prefix(i int) int {
+	s := 0
+	for ; i > 0; i -= i & -i {
+		s += int(f[i-1])
+	}
+	return s
+}
+
+// push appends index len(f) holding v
+func (f *fenwick) push(v int32) {
+	n := len(*f) + 1
+	v += int32(f.prefix(n-1) - f.prefix(n-(n&-n)))
+	*f = append(*f, v)
+}
+
+// compactSlack is how many dead tickets a level tolerates beyond its live
+// count before renumbering
+const compactSlack = 32
+
+// enqueue assigns n the next ticket
+func (pl *PriceLevel) enqueue(n *orderNode) {
+	n.ticket = len(pl.tickets)
+	pl.tickets.push(1)
+}
+
+// dequeue retires n's ticket, compacting if dead tickets dominate
+func (pl *PriceLevel) dequeue(n *orderNode) {
+	pl.tickets.add(n.ticket, -1)
+	if len(pl.tickets) > 2*pl.count+compactSlack {
+		pl.compact()
+	}
+}
+
+// compact renumbers live nodes 0..count-1 and rebuilds the tree in O(n)
+func (pl *PriceLevel) compact() {
+	i := 0
+	for n := pl.head; n != nil; n = n.next {
+		n.ticket = i
+		i++
+	}
+	pl.tickets = pl.tickets[:i]
+	for j := 1; j <= i; j++ {
+		pl.tickets[j-1] = int32(j & -j)
+	}
+}
+
+// position returns n's 1-based place in the queue
+func (pl *PriceLevel) position(n *orderNode) int {
+	return pl.tickets.prefix(n.ticket + 1)
+}
+
+// aheadQty sums the displayed qty of the orders ahead of n in its level
+func (n *orderNode) aheadQty() int64 {
+	var qty int64
+	for m := n.prev; m != nil; m = m.prev {
+		qty += m.shown()
+	}
+	return qty
+}
@@ -0,0 +1,164 @@
+package orderbook
+
+// levelTree is an ordered set of price levels for one side of the book,
+// implemented as a treap keyed by price. Node priorities are derived from
+// the price itself, so the tree shape is a pure function of its contents
+// and iteration order never depends on insertion history or randomness
+type levelTree struct {
+	root *levelNode
+	size int
+	desc bool // true for bids: best level is the highest price
+}
+
+type levelNode struct {
+	level       *PriceLevel
+	prio        uint64
+	left, right *levelNode
+}
+
+// get returns the level at price, or nil if none exists
+func (t *levelTree) get(price int64) *PriceLevel {
+	n := t.root
+	for n != nil {
+		switch {
+		case price < n.level.Price:
+			n = n.left
+		case price > n.level.Price:
+			n = n.right
+		default:
+			return n.level
+		}
+	}
+	return nil
+}
+
+// insert adds a new level. The caller guarantees no level exists at its price
+func (t *levelTree) insert(level *PriceLevel) {
+	t.root = treapInsert(t.root, &levelNode{level: level, prio: mixPrice(level.Price)})
+	t.size++
+}
+
+// remove deletes the level at price if present
+func (t *levelTree) remove(price int64) {
+	var removed bool
+	t.root = treapRemove(t.root, price, &removed)
+	if removed {
+		t.size--
+	}
+}
+
+// best returns the best-priced level, or nil if the side is empty
+func (t *levelTree) best() *PriceLevel {
+	n := t.root
+	if n == nil {
+		return nil
+	}
+	if t.desc {
+		for n.right != nil {
+			n = n.right
+		}
+	} else {
+		for n.left != nil {
+			n = n.left
+		}
+	}
+	return n.level
+}
+
+// each visits levels best-first until fn returns false
+func (t *levelTree) each(fn func(*PriceLevel) bool) {
+	var stack []*levelNode
+	n := t.root
+	for n != nil || len(stack) > 0 {
+		for n != nil {
+			stack = append(stack, n)
+			if t.desc {
+				n = n.right
+			} else {
+				n = n.left
+			}
+		}
+		n = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !fn(n.level) {
+			return
+		}
+		if t.desc {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+}
+
+func treapInsert(n, x *levelNode) *levelNode {
+	if n == nil {
+		return x
+	}
+	if x.level.Price < n.level.Price {
+		n.left = treapInsert(n.left, x)
+		if n.left.prio > n.prio {
+			n = rotateRight(n)
+		}
+	} else {
+		n.right = treapInsert(n.right, x)
+		if n.right.prio > n.prio {
+			n = rotateLeft(n)
+		}
+	}
+	return n
+}
+
+func treapRemove(n *levelNode, price int64, removed *bool) *levelNode {
+	if n == nil {
+		return nil
+	}
+	switch {
+	case price < n.level.Price:
+		n.left = treapRemove(n.left, price, removed)
+	case price > n.level.Price:
+		n.right = treapRemove(n.right, price, removed)
+	default:
+		*removed = true
+		return treapMerge(n.left, n.right)
+	}
+	return n
+}
+
+// treapMerge joins two treaps where every key in a is below every key in b
+func treapMerge(a, b *levelNode) *levelNode {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.prio > b.prio {
+		a.right = treapMerge(a.right, b)
+		return a
+	}
+	b.left = treapMerge(a, b.left)
+	return b
+}
+
+func rotateRight(n *levelNode) *levelNode {
+	l := n.left
+	n.left = l.right
+	l.right = n
+	return l
+}
+
+func rotateLeft(n *levelNode) *levelNode {
+	r := n.right
+	n.right = r.left
+	r.left = n
+	return r
+}
+
+// mixPrice is the splitmix64 finalizer, used as a deterministic treap priority
+func mixPrice(price int64) uint64 {
+	z := uint64(price) + 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
@@ -0,0 +1,142 @@
+// Package provenance records what produced a run: the simulator version,
+// the build and the machine. Only the version goes into the event log,
+// whose hash must not depend on where it was made; the rest is stamped
+// on provenance.json, metrics.json and the report
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+)
+
+// Version is the simulator's behavior version, logged in every SIM_START
+// and recorded in every golden snapshot. Bump it in the same change that
+// intentionally alters simulation output, then run `fairsim golden
+// update`; the update refuses to record drift otherwise
+const Version = 25
+
+// File is the name of a run's provenance record
+const File = "provenance.json"
+
+// versionKey is the SIM_START annotation carrying Version
+const versionKey = "simulator_version"
+
+// Stamp says what produced a run
+type Stamp struct {
+	SimulatorVersion int    `json:"simulator_version"`
+	GitCommit        string `json:"git_commit,omitempty"`   // empty when built outside a checkout
+	GitModified      bool   `json:"git_modified,omitempty"` // built with uncommitted changes
+	GoVersion        string `json:"go_version"`
+	ConfigHash       string `json:"config_hash"` // SHA-256 of config.json
+	Hostname         string `json:"hostname,omitempty"`
+	LogHash          string `json:"log_hash,omitempty"`
+}
+
+// New stamps a run of the config saved as configJSON, by this binary on
+// this machine
+func New(configJSON []byte) *Stamp {
+	sum := sha256.Sum256(configJSON)
+	s := &Stamp{
+		SimulatorVersion: Version,
+		GoVersion:        runtime.Version(),
+		ConfigHash:       hex.EncodeToString(sum[:]),
+	}
+	s.Hostname, _ = os.Hostname()
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, kv := range info.Settings {
+			switch kv.Key {
+			case "vcs.revision":
+				s.GitCommit = kv.Value
+			case "vcs.modified":
+				s.GitModified = kv.Value == "true"
+			}
+		}
+	}
+	return s
+}
+
+// String is a one-line summary, e.g. "simulator v16, commit 1a2b3c4d5e6f
+// (modified), go1.24.1, config 9f8e7d6c5b4a, host build-01"
+func (s *Stamp) String() string {
+	parts := []string{"simulator v" + strconv.Itoa(s.SimulatorVersion)}
+	if s.GitCommit != "" {
+		commit := short(s.GitCommit)
+		if s.GitModified {
+			commit += " (modified)"
+		}
+		parts = append(parts, "commit "+commit)
+	}
+	parts = append(parts, s.GoVersion, "config "+short(s.ConfigHash))
+	if s.Hostname != "" {
+		parts = append(parts, "host "+s.Hostname)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func short(hash string) string {
+	return hash[:min(12, len(hash))]
+}
+
+// Write writes the stamp to dir's provenance.json
+func (s *Stamp) Write(dir string) error {
+	data, _ := json.MarshalIndent(s, "", "  ")
+	return os.WriteFile(filepath.Join(dir, File), data, 0644)
+}
+
+// Read reads dir's provenance.json
+func Read(dir string) (*Stamp, error) {
+	data, err := os.ReadFile(filepath.Join(dir, File))
+	if err != nil {
+		return nil, err
+	}
+	var s Stamp
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", File, err)
+	}
+	return &s, nil
+}
+
+// Declare annotates a log's SIM_START with the simulator version
+func Declare(start *domain.Event) {
+	start.Annotate(versionKey, strconv.Itoa(Version))
+}
+
+// Declared is the simulator version a log's first event declares, or 0
+// for a log from before versions were logged
+func Declared(first *domain.Event) int {
+	if first == nil || first.Type != domain.EventSimStart {
+		return 0
+	}
+	v, _ := first.Annotation(versionKey)
+	n, _ := strconv.Atoi(v)
+	return n
+}
+
+// LogVersion is the simulator version the log at path declares, or 0
+func LogVersion(path string) (int, error) {
+	r, err := eventlog.NewReader(path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	first, err := r.Next()
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return Declared(first), nil
+}
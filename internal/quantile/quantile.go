@@ -0,0 +1,205 @@
+// Package quantile computes percentiles of a stream of samples. Exact
+// keeps every sample and sorts them when asked. Sketch keeps a
+// log-bucketed histogram in the manner of DDSketch: its quantiles are
+// within a fixed relative error of the exact ones, in memory that grows
+// with the log of the samples' range rather than their count. Auto starts
+// exact and moves to a sketch once the samples outnumber a limit, so
+// ordinary runs keep their exact figures and only very large ones trade
+// them for speed
+package quantile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Estimator accumulates samples and answers percentile queries. Queries
+// on no samples return 0
+type Estimator interface {
+	Add(v float64)
+	Count() int
+	Min() float64
+	Max() float64
+	Mean() float64
+	// Quantile interpolates linearly between the samples at the closest
+	// ranks to q*(n-1), for q in [0, 1]
+	Quantile(q float64) float64
+	// Lower is the sample at rank floor(q*(n-1)): a value that occurred,
+	// for integer-valued samples such as nanoseconds or queue positions
+	Lower(q float64) float64
+	// Below counts the samples less than x
+	Below(x float64) int
+}
+
+// Mode chooses an Estimator
+type Mode string
+
+const (
+	ModeAuto   Mode = "auto"   // exact up to a limit, then a sketch
+	ModeExact  Mode = "exact"  // keep and sort every sample
+	ModeApprox Mode = "approx" // a sketch from the first sample
+)
+
+// DefaultExactLimit is how many samples Auto keeps exactly before moving
+// to a sketch
+const DefaultExactLimit = 1 << 20
+
+// DefaultAccuracy is a sketch's relative error
+const DefaultAccuracy = 0.005
+
+// ParseMode reads a mode; empty means ModeAuto
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "":
+		return ModeAuto, nil
+	case ModeAuto, ModeExact, ModeApprox:
+		return Mode(s), nil
+	}
+	return "", fmt.Errorf("unknown percentile mode %q (%s, %s, %s)", s, ModeAuto, ModeExact, ModeApprox)
+}
+
+// New returns an empty estimator for mode. Under ModeAuto, limit is the
+// sample count beyond which it moves to a sketch; 0 means
+// DefaultExactLimit. An unknown mode is taken as ModeAuto
+func New(mode Mode, limit int) Estimator {
+	switch mode {
+	case ModeExact:
+		return NewExact()
+	case ModeApprox:
+		return NewSketch(DefaultAccuracy)
+	}
+	if limit <= 0 {
+		limit = DefaultExactLimit
+	}
+	return &auto{limit: limit, exact: NewExact()}
+}
+
+// Unwrap returns the estimator doing the work: an *Exact or a *Sketch
+func Unwrap(e Estimator) Estimator {
+	if a, ok := e.(*auto); ok {
+		if a.sketch != nil {
+			return a.sketch
+		}
+		return a.exact
+	}
+	return e
+}
+
+// Exact keeps every sample
+type Exact struct {
+	samples []float64
+	sorted  bool
+}
+
+// NewExact returns an empty exact estimator
+func NewExact() *Exact { return &Exact{sorted: true} }
+
+// Sorted wraps samples already in ascending order, without copying them
+func Sorted(samples []float64) *Exact { return &Exact{samples: samples, sorted: true} }
+
+func (e *Exact) Add(v float64) {
+	e.samples = append(e.samples, v)
+	e.sorted = false
+}
+
+func (e *Exact) sort() {
+	if !e.sorted {
+		sort.Float64s(e.samples)
+		e.sorted = true
+	}
+}
+
+// Samples returns the samples in ascending order
+func (e *Exact) Samples() []float64 {
+	e.sort()
+	return e.samples
+}
+
+func (e *Exact) Count() int { return len(e.samples) }
+
+func (e *Exact) Min() float64 {
+	if len(e.samples) == 0 {
+		return 0
+	}
+	e.sort()
+	return e.samples[0]
+}
+
+func (e *Exact) Max() float64 {
+	if len(e.samples) == 0 {
+		return 0
+	}
+	e.sort()
+	return e.samples[len(e.samples)-1]
+}
+
+// Mean sums in ascending order, so it does not depend on arrival order
+func (e *Exact) Mean() float64 {
+	if len(e.samples) == 0 {
+		return 0
+	}
+	e.sort()
+	var sum float64
+	for _, v := range e.samples {
+		sum += v
+	}
+	return sum / float64(len(e.samples))
+}
+
+func (e *Exact) Quantile(q float64) float64 {
+	if len(e.samples) == 0 {
+		return 0
+	}
+	e.sort()
+	idx := q * float64(len(e.samples)-1)
+	lo, hi := int(math.Floor(idx)), int(math.Ceil(idx))
+	if lo == hi || hi >= len(e.samples) {
+		return e.samples[lo]
+	}
+	frac := idx - float64(lo)
+	return e.samples[lo]*(1-frac) + e.samples[hi]*frac
+}
+
+func (e *Exact) Lower(q float64) float64 {
+	if len(e.samples) == 0 {
+		return 0
+	}
+	e.sort()
+	return e.samples[int(q*float64(len(e.samples)-1))]
+}
+
+func (e *Exact) Below(x float64) int {
+	e.sort()
+	return sort.SearchFloat64s(e.samples, x)
+}
+
+// auto is exact until it holds more than limit samples, then a sketch
+type auto struct {
+	limit  int
+	exact  *Exact
+	sketch *Sketch
+}
+
+func (a *auto) Add(v float64) {
+	if a.sketch != nil {
+		a.sketch.Add(v)
+		return
+	}
+	a.exact.Add(v)
+	if a.exact.Count() > a.limit {
+		a.sketch = NewSketch(DefaultAccuracy)
+		for _, s := range a.exact.samples {
+			a.sketch.Add(s)
+		}
+		a.exact = nil
+	}
+}
+
+func (a *auto) Count() int                 { return Unwrap(a).Count() }
+func (a *auto) Min() float64               { return Unwrap(a).Min() }
+func (a *auto) Max() float64               { return Unwrap(a).Max() }
+func (a *auto) Mean() float64              { return Unwrap(a).Mean() }
+func (a *auto) Quantile(q float64) float64 { return Unwrap(a).Quantile(q) }
+func (a *auto) Lower(q float64) float64    { return Unwrap(a).Lower(q) }
+func (a *auto) Below(x float64) int        { return Unwrap(a).Below(x) }
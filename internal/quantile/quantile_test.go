@@ -0,0 +1,114 @@
+package quantile
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestExact(t *testing.T) {
+	e := NewExact()
+	for _, v := range []float64{5, 1, 4, 2, 3} {
+		e.Add(v)
+	}
+	if e.Min() != 1 || e.Max() != 5 || e.Mean() != 3 || e.Count() != 5 {
+		t.Errorf("min %v, max %v, mean %v, count %d", e.Min(), e.Max(), e.Mean(), e.Count())
+	}
+	if q := e.Quantile(0.5); q != 3 {
+		t.Errorf("P50 = %v", q)
+	}
+	if q := e.Quantile(0.9); math.Abs(q-4.6) > 1e-12 {
+		t.Errorf("P90 = %v, want 4.6 interpolated", q)
+	}
+	if l := e.Lower(0.9); l != 4 {
+		t.Errorf("lower P90 = %v", l)
+	}
+	if b := e.Below(3); b != 2 {
+		t.Errorf("below 3 = %d", b)
+	}
+	if NewExact().Quantile(0.5) != 0 {
+		t.Error("empty estimator gave a quantile")
+	}
+}
+
+// TestSketchAccuracy checks every sketched quantile of a skewed sample,
+// with negatives and zeros, is within the sketch's relative error
+func TestSketchAccuracy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	exact, sketch := NewExact(), NewSketch(DefaultAccuracy)
+	for i := 0; i < 50_000; i++ {
+		v := math.Exp(rng.NormFloat64() * 2)
+		switch i % 10 {
+		case 0:
+			v = -v
+		case 1:
+			v = 0
+		}
+		exact.Add(v)
+		sketch.Add(v)
+	}
+	for _, q := range []float64{0, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 0.999, 1} {
+		want, got := exact.Lower(q), sketch.Lower(q)
+		if math.Abs(got-want) > DefaultAccuracy*math.Abs(want)+1e-9 {
+			t.Errorf("P%g: sketch %v, exact %v", q*100, got, want)
+		}
+	}
+	if sketch.Min() != exact.Min() || sketch.Max() != exact.Max() || sketch.Count() != exact.Count() {
+		t.Error("sketch min, max or count is not exact")
+	}
+
+	data, err := json.Marshal(sketch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Sketch
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Quantile(0.9) != sketch.Quantile(0.9) || back.Count() != sketch.Count() {
+		t.Error("sketch changed in a JSON round trip")
+	}
+}
+
+// TestAutoMovesToSketch checks auto stays exact up to its limit
+func TestAutoMovesToSketch(t *testing.T) {
+	e := New(ModeAuto, 100)
+	for i := 1; i <= 100; i++ {
+		e.Add(float64(i))
+	}
+	if _, ok := Unwrap(e).(*Exact); !ok {
+		t.Fatal("auto left exact mode at its limit")
+	}
+	e.Add(101)
+	if _, ok := Unwrap(e).(*Sketch); !ok {
+		t.Fatal("auto stayed exact beyond its limit")
+	}
+	if e.Count() != 101 || e.Max() != 101 || math.Abs(e.Lower(0.5)-51) > 51*DefaultAccuracy {
+		t.Errorf("after the move: count %d, max %v, P50 %v", e.Count(), e.Max(), e.Lower(0.5))
+	}
+	if _, err := ParseMode("median"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func benchmarkMode(b *testing.B, mode Mode) {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]float64, 1_000_000)
+	for i := range samples {
+		samples[i] = rng.ExpFloat64() * 1e6
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e := New(mode, 0)
+		for _, v := range samples {
+			e.Add(v)
+		}
+		for _, q := range []float64{0.5, 0.9, 0.99} {
+			e.Quantile(q)
+		}
+	}
+}
+
+func BenchmarkExact(b *testing.B)  { benchmarkMode(b, ModeExact) }
+func BenchmarkSketch(b *testing.B) { benchmarkMode(b, ModeApprox) }
@@ -0,0 +1,199 @@
+package quantile
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+)
+
+// zeroBand is the magnitude below which a sample counts as zero
+const zeroBand = 1e-9
+
+// Sketch buckets samples by the log of their magnitude, each bucket
+// gamma = (1+a)/(1-a) times as wide as the one below, so every sample in
+// a bucket is within relative error a of the bucket's representative
+// value. Count, min, max and mean are exact
+type Sketch struct {
+	accuracy float64
+	lnGamma  float64
+	pos, neg map[int]int64 // bucket index -> count, by sign
+	zero     int64
+	n        int64
+	min, max float64
+	sum      float64
+
+	order []bucketRef // buckets in ascending value order, nil once stale
+}
+
+type bucketRef struct {
+	sign  int // -1, 0 or 1
+	index int
+	count int64
+}
+
+// NewSketch returns an empty sketch with the given relative accuracy
+func NewSketch(accuracy float64) *Sketch {
+	s := &Sketch{accuracy: accuracy, pos: make(map[int]int64), neg: make(map[int]int64)}
+	s.lnGamma = math.Log((1 + accuracy) / (1 - accuracy))
+	return s
+}
+
+// Accuracy is the sketch's relative error
+func (s *Sketch) Accuracy() float64 { return s.accuracy }
+
+func (s *Sketch) Add(v float64) {
+	if s.n == 0 || v < s.min {
+		s.min = v
+	}
+	if s.n == 0 || v > s.max {
+		s.max = v
+	}
+	s.n++
+	s.sum += v
+	s.order = nil
+	switch {
+	case math.Abs(v) < zeroBand:
+		s.zero++
+	case v > 0:
+		s.pos[s.index(v)]++
+	default:
+		s.neg[s.index(-v)]++
+	}
+}
+
+// index is the bucket holding magnitude m: gamma^(i-1) < m <= gamma^i
+func (s *Sketch) index(m float64) int {
+	return int(math.Ceil(math.Log(m) / s.lnGamma))
+}
+
+// value is bucket i's representative magnitude, the point within
+// relative error a of both its edges
+func (s *Sketch) value(i int) float64 {
+	g := math.Exp(s.lnGamma)
+	return 2 * math.Pow(g, float64(i)) / (g + 1)
+}
+
+func (s *Sketch) buckets() []bucketRef {
+	if s.order != nil {
+		return s.order
+	}
+	order := make([]bucketRef, 0, len(s.neg)+len(s.pos)+1)
+	for i, c := range s.neg {
+		order = append(order, bucketRef{-1, i, c})
+	}
+	// Larger magnitudes are more negative
+	sort.Slice(order, func(a, b int) bool { return order[a].index > order[b].index })
+	if s.zero > 0 {
+		order = append(order, bucketRef{0, 0, s.zero})
+	}
+	start := len(order)
+	for i, c := range s.pos {
+		order = append(order, bucketRef{1, i, c})
+	}
+	pos := order[start:]
+	sort.Slice(pos, func(a, b int) bool { return pos[a].index < pos[b].index })
+	s.order = order
+	return order
+}
+
+func (s *Sketch) bucketValue(b bucketRef) float64 {
+	return float64(b.sign) * s.value(b.index)
+}
+
+// at is the estimate of the sample at rank k, clamped to the exact range
+func (s *Sketch) at(k int64) float64 {
+	var seen int64
+	for _, b := range s.buckets() {
+		seen += b.count
+		if k < seen {
+			return math.Min(math.Max(s.bucketValue(b), s.min), s.max)
+		}
+	}
+	return s.max
+}
+
+func (s *Sketch) Count() int   { return int(s.n) }
+func (s *Sketch) Min() float64 { return s.min }
+func (s *Sketch) Max() float64 { return s.max }
+
+func (s *Sketch) Mean() float64 {
+	if s.n == 0 {
+		return 0
+	}
+	return s.sum / float64(s.n)
+}
+
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.n == 0 {
+		return 0
+	}
+	idx := q * float64(s.n-1)
+	lo, hi := int64(math.Floor(idx)), int64(math.Ceil(idx))
+	if lo == hi || hi >= s.n {
+		return s.at(lo)
+	}
+	frac := idx - float64(lo)
+	return s.at(lo)*(1-frac) + s.at(hi)*frac
+}
+
+func (s *Sketch) Lower(q float64) float64 {
+	if s.n == 0 {
+		return 0
+	}
+	return s.at(int64(q * float64(s.n-1)))
+}
+
+// Below counts the samples in buckets whose representative is under x
+func (s *Sketch) Below(x float64) int {
+	var n int64
+	for _, b := range s.buckets() {
+		if s.bucketValue(b) >= x {
+			break
+		}
+		n += b.count
+	}
+	return int(n)
+}
+
+// sketchJSON is a sketch's saved form: buckets as index/count pairs in
+// ascending index order, so the encoding is stable
+type sketchJSON struct {
+	Accuracy float64    `json:"accuracy"`
+	Count    int64      `json:"count"`
+	Min      float64    `json:"min"`
+	Max      float64    `json:"max"`
+	Sum      float64    `json:"sum"`
+	Zero     int64      `json:"zero,omitempty"`
+	Positive [][2]int64 `json:"positive,omitempty"`
+	Negative [][2]int64 `json:"negative,omitempty"`
+}
+
+func (s *Sketch) MarshalJSON() ([]byte, error) {
+	out := sketchJSON{Accuracy: s.accuracy, Count: s.n, Min: s.min, Max: s.max, Sum: s.sum, Zero: s.zero}
+	pairs := func(m map[int]int64) [][2]int64 {
+		var p [][2]int64
+		for i, c := range m {
+			p = append(p, [2]int64{int64(i), c})
+		}
+		sort.Slice(p, func(a, b int) bool { return p[a][0] < p[b][0] })
+		return p
+	}
+	out.Positive, out.Negative = pairs(s.pos), pairs(s.neg)
+	return json.Marshal(out)
+}
+
+func (s *Sketch) UnmarshalJSON(data []byte) error {
+	var in sketchJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*s = *NewSketch(in.Accuracy)
+	s.n, s.min, s.max, s.sum, s.zero = in.Count, in.Min, in.Max, in.Sum, in.Zero
+	for _, p := range in.Positive {
+		s.pos[int(p[0])] = p[1]
+	}
+	for _, p := range in.Negative {
+		s.neg[int(p[0])] = p[1]
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package report
+
+import (
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+)
+
+// activityWidth is how many buckets one block of the activity chart shows
+const activityWidth = 100
+
+// activityLevels shade a bucket's count against the row kind's peak
+var activityLevels = []rune(" ▁▂▃▄▅▆▇█")
+
+// renderActivity draws both traders' orders, cancels and fills per
+// bucket, one column a bucket, over a row marking the bursts. Each kind
+// is shaded against its peak over both traders, so rows of the same kind
+// compare directly. Empty when either trader has no activity recorded,
+// as in metrics saved before it was
+func (r *Report) renderActivity() string {
+	if r.fast == nil || r.slow == nil || r.fast.Activity == nil || r.slow.Activity == nil {
+		return ""
+	}
+	fast, slow := r.fast.Activity, r.slow.Activity
+	n := min(len(fast.Orders), len(slow.Orders))
+	if n == 0 {
+		return ""
+	}
+	rows := []struct {
+		label      string
+		fast, slow []int
+	}{
+		{"orders", fast.Orders, slow.Orders},
+		{"cancels", fast.Cancels, slow.Cancels},
+		{"fills", fast.Fills, slow.Fills},
+	}
+
+	var sb strings.Builder
+	sb.WriteString(numfmt.Sprintf("=== Activity per %s (by venue time) ===\n\n", latency.Format(fast.BucketNs)))
+	sb.WriteString("One column per bucket, shaded against the busiest bucket of its kind:\n")
+	for _, row := range rows {
+		sb.WriteString(numfmt.Sprintf("  %-8s peak %d\n", row.label, max(peak(row.fast[:n]), peak(row.slow[:n]))))
+	}
+	if len(fast.Bursts) > 0 {
+		sb.WriteString("Bursts are marked ^.\n")
+	}
+	sb.WriteString("\n")
+
+	for lo := 0; lo < n; lo += activityWidth {
+		hi := min(lo+activityWidth, n)
+		sb.WriteString(numfmt.Sprintf("t=%s\n", latency.Format(int64(lo)*fast.BucketNs)))
+		for _, trader := range []string{"fast", "slow"} {
+			for _, row := range rows {
+				counts, top := row.fast, max(peak(row.fast[:n]), peak(row.slow[:n]))
+				if trader == "slow" {
+					counts = row.slow
+				}
+				sb.WriteString(numfmt.Sprintf("  %s %-8s |%s|\n", trader, row.label, shade(counts[lo:hi], top)))
+			}
+		}
+		if len(fast.Bursts) > 0 {
+			marks := make([]byte, hi-lo)
+			for i := range marks {
+				marks[i] = ' '
+				if fast.InBurst(lo + i) {
+					marks[i] = '^'
+				}
+			}
+			sb.WriteString(numfmt.Sprintf("  %-13s |%s|\n", "bursts", marks))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func peak(counts []int) int {
+	top := 0
+	for _, c := range counts {
+		top = max(top, c)
+	}
+	return top
+}
+
+// shade renders counts as one level character each, blank for none
+func shade(counts []int, top int) string {
+	out := make([]rune, len(counts))
+	for i, c := range counts {
+		level := 0
+		if c > 0 {
+			level = (c*(len(activityLevels)-1) + top - 1) / top
+		}
+		out[i] = activityLevels[level]
+	}
+	return string(out)
+}
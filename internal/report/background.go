@@ -0,0 +1,68 @@
+package report
+
+import (
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+)
+
+// renderBackground tabulates what each background trader did, then splits
+// the fast and slow traders' fills by which background trader they were
+// against
+func (r *Report) renderBackground() string {
+	var sb strings.Builder
+	f, s := r.fast, r.slow
+	sb.WriteString("## Background Population\n\n")
+	sb.WriteString(numfmt.Sprintf("| Trader | Limit | Market | Cancel | Fills | Passive Qty | Aggressive Qty | Position | vs %s | vs %s |\n", f.TraderID, s.TraderID))
+	sb.WriteString("|--------|-------|--------|--------|-------|-------------|----------------|----------|------|------|\n")
+	for _, b := range r.Background {
+		sb.WriteString(numfmt.Sprintf("| %s | %d | %d | %d | %d | %d | %d | %d | %d | %d |\n",
+			b.TraderID, b.LimitOrders, b.MarketOrders, b.Cancels, b.Fills, b.PassiveQty, b.AggressiveQty, b.Position,
+			b.QtyVs[f.TraderID], b.QtyVs[s.TraderID]))
+	}
+
+	sb.WriteString("\nFills by counterparty, with adverse selection of those fills alone at the first markout horizon:\n\n")
+	sb.WriteString(numfmt.Sprintf("| Counterparty | %s Qty | %s AS (bps) | %s Qty | %s AS (bps) |\n", f.TraderID, f.TraderID, s.TraderID, s.TraderID))
+	sb.WriteString("|--------------|------|------|------|------|\n")
+	fc, sc := counterparties(f), counterparties(s)
+	for _, b := range r.Background {
+		sb.WriteString(numfmt.Sprintf("| %s | %s | %s |\n", b.TraderID, fc.cells(b.TraderID), sc.cells(b.TraderID)))
+	}
+	// Trades between the traders themselves
+	others := make(map[string]bool)
+	for id := range fc {
+		others[id] = true
+	}
+	for id := range sc {
+		others[id] = true
+	}
+	for _, id := range detorder.Keys(others) {
+		if !domain.IsBackground(id) {
+			sb.WriteString(numfmt.Sprintf("| %s | %s | %s |\n", id, fc.cells(id), sc.cells(id)))
+		}
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+type counterpartyMap map[string]metrics.Counterparty
+
+func counterparties(m *metrics.TraderMetrics) counterpartyMap {
+	out := make(counterpartyMap, len(m.Counterparties))
+	for _, cp := range m.Counterparties {
+		out[cp.TraderID] = cp
+	}
+	return out
+}
+
+// cells formats the qty and adverse selection against id, or dashes
+func (c counterpartyMap) cells(id string) string {
+	cp, ok := c[id]
+	if !ok {
+		return "- | -"
+	}
+	return numfmt.Sprintf("%d | %.2f", cp.Qty, cp.AdverseSelectionBps)
+}
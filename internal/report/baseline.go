@@ -0,0 +1,33 @@
+// Package report — comparison against the manifest's baseline run
+package report
+
+import (
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sensitivity"
+)
+
+// renderBaseline sets this run's headline metrics beside the baseline's:
+// each trader's change, and how the fast-minus-slow gap moved
+func (r *Report) renderBaseline() string {
+	b := r.Baseline
+	var sb strings.Builder
+	sb.WriteString("## vs Baseline\n\n")
+	sb.WriteString(numfmt.Sprintf("Compared against the baseline `%s` (%s, seed %d), as it was when marked. ",
+		b.RunDir, b.Scenario, b.Seed))
+	sb.WriteString("Each Δ is this run minus the baseline.\n\n")
+	if b.ConfigHash != "" && r.Provenance != nil && b.ConfigHash == r.Provenance.ConfigHash {
+		sb.WriteString("This run's config is identical to the baseline's.\n\n")
+	}
+	sb.WriteString("| Metric | Fast | Δ Fast | Slow | Δ Slow | Gap | Δ Gap |\n")
+	sb.WriteString("|--------|------|--------|------|--------|-----|-------|\n")
+	bf, bs := b.Fast(), b.Slow()
+	for _, mt := range sensitivity.Metrics {
+		f, s := mt.Get(r.fast), mt.Get(r.slow)
+		pf, ps := mt.Get(bf), mt.Get(bs)
+		sb.WriteString(numfmt.Sprintf("| %s | %.2f | %+.2f | %.2f | %+.2f | %+.2f | %+.2f |\n",
+			mt.Name, f, f-pf, s, s-ps, f-s, (f-s)-(pf-ps)))
+	}
+	return sb.String()
+}
@@ -0,0 +1,31 @@
+// Package report — the traders' use of their capital limits
+package report
+
+import (
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+)
+
+// renderCapital tabulates each limited trader's capital, the most of it
+// the trader had in use, and the orders rejected for want of it
+func (r *Report) renderCapital() string {
+	var sb strings.Builder
+	sb.WriteString("## Capital\n\n")
+	sb.WriteString("Orders are checked against the trader's capital as they reach the venue: resting orders and position, valued at the mid and held at margin, must stay within equity. ")
+	sb.WriteString("Rejected orders never reached the book, so they are in none of the metrics above.\n\n")
+	sb.WriteString("| Trader | Capital | Margin | Peak Used | Peak Used (%) | Rejected Orders | Rejected Qty |\n")
+	sb.WriteString("|--------|---------|--------|-----------|---------------|-----------------|--------------|\n")
+	for _, u := range r.Capital {
+		sb.WriteString(numfmt.Sprintf("| %s | %.2f | %.2f | %.2f | %.1f | %d | %d |\n",
+			u.TraderID, u.Capital, u.Margin, u.PeakUsed, u.PeakUsed/u.Capital*100, u.Rejected, u.RejectedQty))
+	}
+	sb.WriteString("\n")
+	for _, u := range r.Capital {
+		if u.Rejected > 0 {
+			sb.WriteString("**Note:** a trader that ran out of capital sent fewer orders to the book, so its fill rate and fill counts compare a smaller book presence, not only its latency.\n\n")
+			break
+		}
+	}
+	return sb.String()
+}
@@ -2,13 +2,13 @@
 package report
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
 )
 
@@ -44,7 +44,7 @@ func (cr *CrossReport) Generate() error {
 
 	// Also save structured data
 	dataPath := filepath.Join(cr.outDir, "cross-scenario-metrics.json")
-	data, _ := json.MarshalIndent(cr.buildSummary(), "", "  ")
+	data, _ := numfmt.MarshalIndent(cr.buildSummary(), "", "  ")
 	return os.WriteFile(dataPath, data, 0644)
 }
 
@@ -76,7 +76,7 @@ func (cr *CrossReport) renderMarkdown() string {
 	sb.WriteString("## Summary Table\n\n")
 	sb.WriteString("| Metric | ")
 	for _, r := range cr.results {
-		sb.WriteString(fmt.Sprintf("%s (F) | %s (S) | ", r.Config.Name, r.Config.Name))
+		sb.WriteString(numfmt.Sprintf("%s (F) | %s (S) | ", r.Config.Name, r.Config.Name))
 	}
 	sb.WriteString("\n|--------|")
 	for range cr.results {
@@ -93,21 +93,26 @@ func (cr *CrossReport) renderMarkdown() string {
 	rows := []rowDef{
 		{"Fill Rate (%)", func(m *metrics.TraderMetrics) float64 { return m.FillRate * 100 }, "%.1f"},
 		{"Slippage (bps)", func(m *metrics.TraderMetrics) float64 { return m.SlippageBps }, "%.2f"},
-		{"Avg TTF (ms)", func(m *metrics.TraderMetrics) float64 { return m.AvgTimeToFillNs }, "%.1f"},
+		{"Avg TTF (ms)", func(m *metrics.TraderMetrics) float64 { return m.AvgTimeToFillMs }, "%.1f"},
 		{"Queue Pos Place", func(m *metrics.TraderMetrics) float64 { return m.AvgQueuePosPlace }, "%.1f"},
 		{"Queue Pos Fill", func(m *metrics.TraderMetrics) float64 { return m.AvgQueuePosFill }, "%.1f"},
+		{"Queue Front (%)", func(m *metrics.TraderMetrics) float64 { return m.QueueFrontPct() }, "%.1f"},
 		{"Adv Select (bps)", func(m *metrics.TraderMetrics) float64 { return m.AdverseSelectionBps }, "%.2f"},
 		{"Total Fills", func(m *metrics.TraderMetrics) float64 { return float64(m.TotalFills) }, "%.0f"},
 		{"Total Qty", func(m *metrics.TraderMetrics) float64 { return float64(m.TotalQtyFilled) }, "%.0f"},
+		{"Msgs per Trade", func(m *metrics.TraderMetrics) float64 { return m.MessagesPerTrade }, "%.2f"},
+		{"Passive Share (%)", func(m *metrics.TraderMetrics) float64 { return m.PassiveShare * 100 }, "%.1f"},
+		{"Net Spread (bps)", func(m *metrics.TraderMetrics) float64 { return m.NetSpreadBps }, "%.2f"},
+		{"Toxic Fill Ratio", func(m *metrics.TraderMetrics) float64 { return m.ToxicFillRatio }, "%.2f"},
 	}
 
 	for _, row := range rows {
-		sb.WriteString(fmt.Sprintf("| %s | ", row.label))
+		sb.WriteString(numfmt.Sprintf("| %s | ", row.label))
 		for _, r := range cr.results {
 			fast := r.Metrics[r.Config.FastTrader.ID]
 			slow := r.Metrics[r.Config.SlowTrader.ID]
 			if fast != nil && slow != nil {
-				sb.WriteString(fmt.Sprintf(row.fmt+" | "+row.fmt+" | ", row.get(fast), row.get(slow)))
+				sb.WriteString(numfmt.Sprintf(row.fmt+" | "+row.fmt+" | ", row.get(fast), row.get(slow)))
 			} else {
 				sb.WriteString("N/A | N/A | ")
 			}
@@ -120,7 +125,7 @@ func (cr *CrossReport) renderMarkdown() string {
 	sb.WriteString("## Latency Impact (Fast − Slow)\n\n")
 	sb.WriteString("| Metric |")
 	for _, r := range cr.results {
-		sb.WriteString(fmt.Sprintf(" %s |", r.Config.Name))
+		sb.WriteString(numfmt.Sprintf(" %s |", r.Config.Name))
 	}
 	sb.WriteString("\n|--------|")
 	for range cr.results {
@@ -129,13 +134,13 @@ func (cr *CrossReport) renderMarkdown() string {
 	sb.WriteString("\n")
 
 	for _, row := range rows {
-		sb.WriteString(fmt.Sprintf("| %s |", row.label))
+		sb.WriteString(numfmt.Sprintf("| %s |", row.label))
 		for _, r := range cr.results {
 			fast := r.Metrics[r.Config.FastTrader.ID]
 			slow := r.Metrics[r.Config.SlowTrader.ID]
 			if fast != nil && slow != nil {
 				delta := row.get(fast) - row.get(slow)
-				sb.WriteString(fmt.Sprintf(" %+.2f |", delta))
+				sb.WriteString(numfmt.Sprintf(" %+.2f |", delta))
 			} else {
 				sb.WriteString(" N/A |")
 			}
@@ -175,7 +180,7 @@ func (cr *CrossReport) generateCrossAnalysis() string {
 			name:       r.Config.Name,
 			fillDelta:  (fast.FillRate - slow.FillRate) * 100,
 			slipDelta:  fast.SlippageBps - slow.SlippageBps,
-			ttfDelta:   fast.AvgTimeToFillNs - slow.AvgTimeToFillNs,
+			ttfDelta:   fast.AvgTimeToFillMs - slow.AvgTimeToFillMs,
 			queueDelta: fast.AvgQueuePosPlace - slow.AvgQueuePosPlace,
 		})
 	}
@@ -193,7 +198,7 @@ func (cr *CrossReport) generateCrossAnalysis() string {
 		}
 	}
 
-	sb.WriteString(fmt.Sprintf("- **Fill Rate**: The largest gap appears in **%s** (%+.1f pp), ",
+	sb.WriteString(numfmt.Sprintf("- **Fill Rate**: The largest gap appears in **%s** (%+.1f pp), ",
 		maxFillScenario.name, maxFillScenario.fillDelta))
 	sb.WriteString("indicating this market regime amplifies the latency advantage most for execution likelihood.\n")
 
@@ -205,7 +210,7 @@ func (cr *CrossReport) generateCrossAnalysis() string {
 		}
 	}
 
-	sb.WriteString(fmt.Sprintf("- **Slippage**: The **%s** scenario shows the widest slippage gap (%+.2f bps), ",
+	sb.WriteString(numfmt.Sprintf("- **Slippage**: The **%s** scenario shows the widest slippage gap (%+.2f bps), ",
 		maxSlipScenario.name, maxSlipScenario.slipDelta))
 	sb.WriteString("suggesting execution price quality diverges most under these conditions.\n")
 
@@ -241,7 +246,7 @@ func PrintCrossSummary(results []ScenarioResult) {
 	rows := []rowFn{
 		{"Fill Rate (%)", func(m *metrics.TraderMetrics) float64 { return m.FillRate * 100 }, "%12.1f"},
 		{"Slippage (bps)", func(m *metrics.TraderMetrics) float64 { return m.SlippageBps }, "%12.2f"},
-		{"Avg TTF (ms)", func(m *metrics.TraderMetrics) float64 { return m.AvgTimeToFillNs }, "%12.1f"},
+		{"Avg TTF (ms)", func(m *metrics.TraderMetrics) float64 { return m.AvgTimeToFillMs }, "%12.1f"},
 		{"Queue Pos Place", func(m *metrics.TraderMetrics) float64 { return m.AvgQueuePosPlace }, "%12.1f"},
 		{"Adv Select (bps)", func(m *metrics.TraderMetrics) float64 { return m.AdverseSelectionBps }, "%12.2f"},
 	}
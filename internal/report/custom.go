@@ -0,0 +1,19 @@
+// Package report — values of the custom metric plugins a config turned on
+package report
+
+import "strings"
+
+// renderCustom tabulates each custom metric for both traders, in the
+// order the config lists them
+func (r *Report) renderCustom() string {
+	var sb strings.Builder
+	sb.WriteString("## Custom Metrics\n\n")
+	sb.WriteString("Computed by the metric plugins listed under metrics.custom in the config.\n\n")
+	sb.WriteString("| Metric | Fast | Slow | Delta |\n")
+	sb.WriteString("|--------|------|------|-------|\n")
+	for _, name := range r.config.Metrics.Custom {
+		r.addRow(&sb, name, r.fast.Custom[name], r.slow.Custom[name], true)
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
@@ -0,0 +1,211 @@
+// Package report — the queue position vs latency experiment
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sensitivity"
+)
+
+// QueueExperimentReport charts both traders' queue position at placement
+// and fill probability against the slow trader's latency. Unlike the
+// sensitivity report it plots each trader's own values, not their gap
+type QueueExperimentReport struct {
+	result *sensitivity.Result
+	outDir string
+}
+
+// NewQueueExperimentReport creates a report over a sweep of
+// sensitivity.QueueMetrics
+func NewQueueExperimentReport(result *sensitivity.Result, outDir string) *QueueExperimentReport {
+	return &QueueExperimentReport{result: result, outDir: outDir}
+}
+
+// Generate writes queue_latency.md, queue_latency.json, queue_latency.csv
+// and a line chart per metric, queue_latency_<metric>.svg
+func (qr *QueueExperimentReport) Generate() error {
+	if err := os.MkdirAll(qr.outDir, 0755); err != nil {
+		return fmt.Errorf("create output dir: %w", err)
+	}
+	for i, c := range qr.result.Curves {
+		path := filepath.Join(qr.outDir, "queue_latency_"+sensitivity.QueueMetrics[i].Key+".svg")
+		if err := os.WriteFile(path, []byte(lineChartSVG(c, qr.result.FastNs)), 0644); err != nil {
+			return fmt.Errorf("write queue chart: %w", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(qr.outDir, "queue_latency.md"), []byte(qr.renderMarkdown()), 0644); err != nil {
+		return fmt.Errorf("write queue experiment report: %w", err)
+	}
+	data, _ := numfmt.MarshalIndent(qr.result, "", "  ")
+	if err := os.WriteFile(filepath.Join(qr.outDir, "queue_latency.json"), data, 0644); err != nil {
+		return fmt.Errorf("write queue experiment data: %w", err)
+	}
+	return qr.writeCSV(filepath.Join(qr.outDir, "queue_latency.csv"))
+}
+
+// writeCSV writes one row per latency, a fast and a slow column per metric
+func (qr *QueueExperimentReport) writeCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	header := []string{"slow_latency_ns"}
+	for _, mt := range sensitivity.QueueMetrics {
+		header = append(header, "fast_"+mt.Key, "slow_"+mt.Key)
+	}
+	cw.Write(header)
+	num := func(v float64) string { return numfmt.Fixed(v, 4) }
+	for i, lat := range qr.result.Latencies {
+		row := []string{strconv.FormatInt(lat, 10)}
+		for _, c := range qr.result.Curves {
+			row = append(row, num(c.Points[i].Fast), num(c.Points[i].Slow))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("write queue experiment csv: %w", err)
+	}
+	return f.Close()
+}
+
+func (qr *QueueExperimentReport) renderMarkdown() string {
+	res := qr.result
+	var sb strings.Builder
+
+	sb.WriteString("# Queue Position vs Latency\n\n")
+	sb.WriteString("Where does latency put a trader in the queue, and what does that do to its fills? ")
+	sb.WriteString("The same market is replayed with only the slow trader's latency changed: background flow, ")
+	sb.WriteString("signals and jitter draws are identical at every point. The fast trader's latency stays fixed, ")
+	sb.WriteString("so its curve moves only as the slow trader competes with it for the same queues.\n\n")
+	sb.WriteString(numfmt.Sprintf("**Scenario:** %s | **Seed:** %d | **Fast trader:** %s\n\n",
+		res.Scenario, res.Seed, latency.Format(res.FastNs)))
+	sb.WriteString("Queue position counts orders, 1 at the front, over the limit orders that rested. ")
+	sb.WriteString("Fill probability is the share of executable orders that filled at all.\n\n")
+
+	sb.WriteString("| Slow Latency |")
+	for _, c := range res.Curves {
+		sb.WriteString(numfmt.Sprintf(" %s, fast / slow |", c.Metric))
+	}
+	sb.WriteString("\n|--------------|")
+	sb.WriteString(strings.Repeat("--------|", len(res.Curves)))
+	sb.WriteString("\n")
+	for i, lat := range res.Latencies {
+		sb.WriteString(numfmt.Sprintf("| %s |", latency.Format(lat)))
+		for _, c := range res.Curves {
+			sb.WriteString(numfmt.Sprintf(" %.2f / %.2f |", c.Points[i].Fast, c.Points[i].Slow))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("## Curves\n\n")
+	for i, c := range res.Curves {
+		sb.WriteString(numfmt.Sprintf("### %s\n\n", c.Metric))
+		sb.WriteString(numfmt.Sprintf("![%s](queue_latency_%s.svg)\n\n", c.Metric, sensitivity.QueueMetrics[i].Key))
+		sb.WriteString(numfmt.Sprintf("```\n%s```\n\n", asciiValues(c)))
+	}
+
+	sb.WriteString("## Runs\n\n")
+	for i, dir := range res.RunDirs {
+		sb.WriteString(numfmt.Sprintf("- slow at %s: `%s`\n", latency.Format(res.Latencies[i]), dir))
+	}
+	return sb.String()
+}
+
+// asciiValues draws one bar per latency, its length the slow trader's
+// value, with the fast trader's beside it for reference
+func asciiValues(c sensitivity.Curve) string {
+	const width = 40
+	var peak float64
+	for _, p := range c.Points {
+		peak = math.Max(peak, math.Abs(p.Slow))
+	}
+	var sb strings.Builder
+	for _, p := range c.Points {
+		n := 0
+		if peak > 0 {
+			n = int(math.Round(math.Abs(p.Slow) / peak * width))
+		}
+		sb.WriteString(numfmt.Sprintf("%10s | %-*s %.2f (fast %.2f)\n", latency.Format(p.LatencyNs), width, strings.Repeat("█", n), p.Slow, p.Fast))
+	}
+	return sb.String()
+}
+
+// SVG line chart layout, in pixels
+const (
+	chartW      = 520
+	chartH      = 220
+	chartLeft   = 64
+	chartTop    = 40
+	chartBottom = 48
+	chartRight  = 24
+)
+
+// lineChartSVG draws a curve's fast and slow values against the slow
+// trader's latency, on a log axis, with the fast trader's own latency
+// marked
+func lineChartSVG(c sensitivity.Curve, fastNs int64) string {
+	width, height := chartLeft+chartW+chartRight, chartTop+chartH+chartBottom
+	lo, hi := 0.0, 0.0
+	for _, p := range c.Points {
+		lo, hi = math.Min(lo, math.Min(p.Fast, p.Slow)), math.Max(hi, math.Max(p.Fast, p.Slow))
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+	first, last := c.Points[0].LatencyNs, c.Points[len(c.Points)-1].LatencyNs
+	x := func(ns int64) float64 {
+		if last == first {
+			return chartLeft + chartW/2
+		}
+		return chartLeft + chartW*math.Log(float64(ns)/float64(first))/math.Log(float64(last)/float64(first))
+	}
+	y := func(v float64) float64 { return chartTop + chartH*(hi-v)/(hi-lo) }
+
+	var sb strings.Builder
+	sb.WriteString(numfmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="11">`+"\n", width, height))
+	sb.WriteString(numfmt.Sprintf(`<text x="%d" y="20" font-size="13" font-weight="bold">%s</text>`+"\n", chartLeft, svgEscape(c.Metric)))
+	sb.WriteString(numfmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="#999999"/>`+"\n", chartLeft, chartTop, chartW, chartH))
+	for _, v := range []float64{lo, (lo + hi) / 2, hi} {
+		sb.WriteString(numfmt.Sprintf(`<text x="%d" y="%.1f" text-anchor="end">%.2f</text>`+"\n", chartLeft-6, y(v)+4, v))
+	}
+	for _, p := range c.Points {
+		sb.WriteString(numfmt.Sprintf(`<text x="%.1f" y="%d" text-anchor="middle">%s</text>`+"\n", x(p.LatencyNs), chartTop+chartH+16, svgEscape(latency.Format(p.LatencyNs))))
+	}
+	if fastNs >= first && fastNs <= last {
+		sb.WriteString(numfmt.Sprintf(`<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#999999" stroke-dasharray="4 3"/>`+"\n",
+			x(fastNs), chartTop, x(fastNs), chartTop+chartH))
+	}
+	for _, line := range []struct {
+		name, color string
+		value       func(sensitivity.Point) float64
+	}{
+		{"fast", "#d62728", func(p sensitivity.Point) float64 { return p.Fast }},
+		{"slow", "#1f77b4", func(p sensitivity.Point) float64 { return p.Slow }},
+	} {
+		points := make([]string, len(c.Points))
+		for i, p := range c.Points {
+			points[i] = numfmt.Sprintf("%.1f,%.1f", x(p.LatencyNs), y(line.value(p)))
+		}
+		sb.WriteString(numfmt.Sprintf(`<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`+"\n", strings.Join(points, " "), line.color))
+		for _, p := range c.Points {
+			sb.WriteString(numfmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="3" fill="%s"/>`+"\n", x(p.LatencyNs), y(line.value(p)), line.color))
+		}
+	}
+	sb.WriteString(numfmt.Sprintf(`<text x="%d" y="20" fill="#d62728">fast</text>`+"\n", chartLeft+chartW-60))
+	sb.WriteString(numfmt.Sprintf(`<text x="%d" y="20" fill="#1f77b4">slow</text>`+"\n", chartLeft+chartW-24))
+	sb.WriteString(numfmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle">slow-trader latency (log scale; dashed: fast trader's)</text>`+"\n", chartLeft+chartW/2, height-12))
+	sb.WriteString("</svg>\n")
+	return sb.String()
+}
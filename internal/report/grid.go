@@ -0,0 +1,286 @@
+// Package report — two-parameter sensitivity grids
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/robustness"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scoring"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sensitivity"
+)
+
+// GridReport maps each fairness gap over the plane of slow-trader latency
+// and one market parameter
+type GridReport struct {
+	result *robustness.GridResult
+	outDir string
+
+	// Scores ranks the grid's cells by composite score; nil omits them
+	Scores *scoring.Ranking
+}
+
+// NewGridReport creates a report over a completed grid
+func NewGridReport(result *robustness.GridResult, outDir string) *GridReport {
+	return &GridReport{result: result, outDir: outDir}
+}
+
+// Generate writes grid.md, grid.json, and per metric a CSV matrix and an
+// SVG heatmap, plus scores.json and scores.csv when scored
+func (gr *GridReport) Generate() error {
+	if err := os.MkdirAll(gr.outDir, 0755); err != nil {
+		return fmt.Errorf("create output dir: %w", err)
+	}
+	for _, mt := range sensitivity.Metrics {
+		if err := gr.writeCSV(mt); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(gr.outDir, "grid_"+mt.Key+".svg"), []byte(gr.renderSVG(mt)), 0644); err != nil {
+			return fmt.Errorf("write grid heatmap: %w", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(gr.outDir, "grid.md"), []byte(gr.renderMarkdown()), 0644); err != nil {
+		return fmt.Errorf("write grid report: %w", err)
+	}
+	data, _ := numfmt.MarshalIndent(gr.result, "", "  ")
+	if err := os.WriteFile(filepath.Join(gr.outDir, "grid.json"), data, 0644); err != nil {
+		return fmt.Errorf("write grid data: %w", err)
+	}
+	if gr.Scores != nil {
+		return writeScores(gr.outDir, gr.Scores)
+	}
+	return nil
+}
+
+// writeCSV writes one metric's gaps as a matrix: a row per parameter
+// value, a column per latency in nanoseconds. Aborted cells are empty
+func (gr *GridReport) writeCSV(mt sensitivity.Metric) error {
+	res := gr.result
+	f, err := os.Create(filepath.Join(gr.outDir, "grid_"+mt.Key+".csv"))
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	header := []string{res.Param + `\slow_latency_ns`}
+	for _, lat := range res.Latencies {
+		header = append(header, strconv.FormatInt(lat, 10))
+	}
+	cw.Write(header)
+	for i, v := range res.Values {
+		row := []string{strconv.FormatFloat(v, 'f', -1, 64)}
+		for _, cell := range res.Cells[i] {
+			if cell.Gaps == nil {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, numfmt.Fixed(cell.Gaps[mt.Key], 4))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("write grid csv: %w", err)
+	}
+	return f.Close()
+}
+
+func (gr *GridReport) renderMarkdown() string {
+	res := gr.result
+	p, _ := robustness.LookupParam(res.Param)
+	var sb strings.Builder
+
+	sb.WriteString("# Latency Sensitivity Grid\n\n")
+	sb.WriteString(numfmt.Sprintf("The same market is replayed at every pair of slow-trader latency and %s, ", res.Param))
+	sb.WriteString("with the seed fixed, so neighbouring cells differ along one axis only. ")
+	sb.WriteString("Each cell is the fast trader's value minus the slow trader's.\n\n")
+	sb.WriteString(numfmt.Sprintf("**Scenario:** %s | **Seed:** %d | **Fast trader:** %s | **Cells:** %d × %d\n\n",
+		res.Scenario, res.Seed, latency.Format(res.FastNs), len(res.Values), len(res.Latencies)))
+
+	var aborted int
+	for _, row := range res.Cells {
+		for _, cell := range row {
+			if cell.Gaps == nil {
+				aborted++
+			}
+		}
+	}
+	if aborted > 0 {
+		sb.WriteString(numfmt.Sprintf("**Aborted:** %d cells were stopped by a scenario guard and are left blank.\n\n", aborted))
+	}
+
+	sb.WriteString("In each heatmap, red cells favour the fast trader and blue cells the slow one, shaded by size; ")
+	sb.WriteString("a black line marks where the gap changes sign.\n\n")
+	for _, mt := range sensitivity.Metrics {
+		sb.WriteString(numfmt.Sprintf("## %s\n\n", mt.Name))
+		sb.WriteString(numfmt.Sprintf("![%s gap](grid_%s.svg)\n\n", mt.Name, mt.Key))
+		if i, j, ok := gr.peak(mt); ok {
+			sb.WriteString(numfmt.Sprintf("Largest gap: %+.2f, with %s at %s and the slow trader at %s.\n\n",
+				res.Cells[i][j].Gaps[mt.Key], res.Param, p.Format(res.Values[i]), latency.Format(res.Latencies[j])))
+		}
+		sb.WriteString(numfmt.Sprintf("| %s \\ Slow Latency |", res.Param))
+		for _, lat := range res.Latencies {
+			sb.WriteString(numfmt.Sprintf(" %s |", latency.Format(lat)))
+		}
+		sb.WriteString("\n|------|")
+		sb.WriteString(strings.Repeat("------|", len(res.Latencies)))
+		sb.WriteString("\n")
+		for i, v := range res.Values {
+			sb.WriteString(numfmt.Sprintf("| %s |", p.Format(v)))
+			for _, cell := range res.Cells[i] {
+				if cell.Gaps == nil {
+					sb.WriteString(" - |")
+					continue
+				}
+				sb.WriteString(numfmt.Sprintf(" %+.2f |", cell.Gaps[mt.Key]))
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	if gr.Scores != nil {
+		sb.WriteString(renderScores(gr.Scores))
+	}
+
+	sb.WriteString("## Runs\n\n")
+	for i, v := range res.Values {
+		for _, cell := range res.Cells[i] {
+			note := ""
+			if cell.Guard != "" {
+				note = " (aborted: " + cell.Guard + ")"
+			}
+			sb.WriteString(numfmt.Sprintf("- %s %s, slow at %s: `%s`%s\n",
+				res.Param, p.Format(v), latency.Format(cell.LatencyNs), cell.RunDir, note))
+		}
+	}
+	return sb.String()
+}
+
+// peak finds the cell with the metric's largest absolute gap
+func (gr *GridReport) peak(mt sensitivity.Metric) (int, int, bool) {
+	bi, bj, best := 0, 0, -1.0
+	for i, row := range gr.result.Cells {
+		for j, cell := range row {
+			if cell.Gaps != nil && math.Abs(cell.Gaps[mt.Key]) > best {
+				bi, bj, best = i, j, math.Abs(cell.Gaps[mt.Key])
+			}
+		}
+	}
+	return bi, bj, best > 0
+}
+
+// SVG heatmap layout, in pixels
+const (
+	gridCellW  = 72
+	gridCellH  = 32
+	gridLeft   = 96
+	gridTop    = 40
+	gridBottom = 56
+)
+
+// renderSVG draws one metric's gaps as a heatmap, the parameter rising up
+// the y axis and latency along the x axis, with the zero contour traced
+// along the cell edges where the gap changes sign
+func (gr *GridReport) renderSVG(mt sensitivity.Metric) string {
+	res := gr.result
+	p, _ := robustness.LookupParam(res.Param)
+	rows, cols := len(res.Values), len(res.Latencies)
+	width, height := gridLeft+cols*gridCellW+16, gridTop+rows*gridCellH+gridBottom
+
+	var peak float64
+	for _, row := range res.Cells {
+		for _, cell := range row {
+			if cell.Gaps != nil {
+				peak = math.Max(peak, math.Abs(cell.Gaps[mt.Key]))
+			}
+		}
+	}
+	// Highest parameter value on top
+	x := func(j int) int { return gridLeft + j*gridCellW }
+	y := func(i int) int { return gridTop + (rows-1-i)*gridCellH }
+
+	var sb strings.Builder
+	sb.WriteString(numfmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="11">`+"\n", width, height))
+	sb.WriteString(numfmt.Sprintf(`<text x="%d" y="20" font-size="13" font-weight="bold">%s gap (fast − slow)</text>`+"\n", gridLeft, svgEscape(mt.Name)))
+	for i := range res.Values {
+		for j, cell := range res.Cells[i] {
+			fill, label := "#dddddd", "-"
+			if cell.Gaps != nil {
+				g := cell.Gaps[mt.Key]
+				fill, label = gapColor(g, peak), numfmt.Sprintf("%+.2f", g)
+			}
+			sb.WriteString(numfmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s" stroke="#ffffff"/>`+"\n",
+				x(j), y(i), gridCellW, gridCellH, fill))
+			sb.WriteString(numfmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle">%s</text>`+"\n",
+				x(j)+gridCellW/2, y(i)+gridCellH/2+4, label))
+		}
+	}
+
+	// Zero contour: an edge between two completed cells of opposite sign
+	sign := func(i, j int) (float64, bool) {
+		cell := res.Cells[i][j]
+		if cell.Gaps == nil {
+			return 0, false
+		}
+		return math.Copysign(1, cell.Gaps[mt.Key]), cell.Gaps[mt.Key] != 0
+	}
+	edge := func(x1, y1, x2, y2 int) {
+		sb.WriteString(numfmt.Sprintf(`<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#000000" stroke-width="2"/>`+"\n", x1, y1, x2, y2))
+	}
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			s, ok := sign(i, j)
+			if !ok {
+				continue
+			}
+			if j+1 < cols {
+				if t, ok := sign(i, j+1); ok && t != s {
+					edge(x(j+1), y(i), x(j+1), y(i)+gridCellH)
+				}
+			}
+			if i+1 < rows {
+				if t, ok := sign(i+1, j); ok && t != s {
+					edge(x(j), y(i), x(j)+gridCellW, y(i))
+				}
+			}
+		}
+	}
+
+	for i, v := range res.Values {
+		sb.WriteString(numfmt.Sprintf(`<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", gridLeft-6, y(i)+gridCellH/2+4, svgEscape(p.Format(v))))
+	}
+	for j, lat := range res.Latencies {
+		sb.WriteString(numfmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle">%s</text>`+"\n", x(j)+gridCellW/2, gridTop+rows*gridCellH+16, svgEscape(latency.Format(lat))))
+	}
+	sb.WriteString(numfmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle">slow-trader latency</text>`+"\n", gridLeft+cols*gridCellW/2, height-12))
+	sb.WriteString(numfmt.Sprintf(`<text x="12" y="%d" text-anchor="middle" transform="rotate(-90 12 %d)">%s</text>`+"\n",
+		gridTop+rows*gridCellH/2, gridTop+rows*gridCellH/2, svgEscape(res.Param)))
+	sb.WriteString("</svg>\n")
+	return sb.String()
+}
+
+// gapColor shades a gap on a diverging scale: white at zero, red at the
+// largest gap in the fast trader's favour, blue at the largest against
+func gapColor(g, peak float64) string {
+	if peak == 0 {
+		return "#ffffff"
+	}
+	t := math.Min(math.Abs(g)/peak, 1)
+	fade := int(math.Round(255 * (1 - 0.8*t)))
+	if g > 0 {
+		return numfmt.Sprintf("#ff%02x%02x", fade, fade)
+	}
+	return numfmt.Sprintf("#%02x%02xff", fade, fade)
+}
+
+var svgReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func svgEscape(s string) string { return svgReplacer.Replace(s) }
@@ -0,0 +1,46 @@
+// Package report — hedging on the future leg
+package report
+
+import (
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+)
+
+// renderHedge tabulates each trader's hedges of its fills on the future,
+// their slippage split into the half spread and the drift of fair value
+// in flight, and its PnL with the hedge leg counted
+func (r *Report) renderHedge() string {
+	h := r.config.Hedge
+	var sb strings.Builder
+	sb.WriteString("## Hedging\n\n")
+	sb.WriteString(numfmt.Sprintf("Each fill is hedged on a delta-one future at a ratio of %g, sent at the fill and arriving after the trader's latency. ", h.HedgeRatio()))
+	sb.WriteString(numfmt.Sprintf("The future's fair value is the mid %+g bps, and a dealer quotes it ±%g bps. ", h.BasisBps, h.HalfSpreadBps))
+	sb.WriteString("Slippage is against fair value at the fill: the half spread, plus how far fair value moved while the hedge was in flight, which is what latency costs. ")
+	sb.WriteString("Hedges still in flight at the close were never executed.\n\n")
+	sb.WriteString("| Trader | Hedges | Qty | Avg Delay | Slippage (bps) | Latency (bps) | Spread (bps) | Cost | Latency Cost | In Flight | Hedge PnL | Net PnL Hedged |\n")
+	sb.WriteString("|--------|--------|-----|-----------|----------------|---------------|--------------|------|--------------|-----------|-----------|----------------|\n")
+	drift := make(map[string]float64)
+	for _, leg := range r.Hedge {
+		m := r.fast
+		if leg.TraderID == r.config.SlowTrader.ID {
+			m = r.slow
+		}
+		hedged := "—"
+		if m != nil {
+			hedged = numfmt.Sprintf("%.4f", m.NetPnL+leg.PnL)
+		}
+		sb.WriteString(numfmt.Sprintf("| %s | %d | %d | %s | %.4f | %.4f | %.4f | %.4f | %.4f | %d | %.4f | %s |\n",
+			leg.TraderID, leg.Hedges, leg.Qty, latency.Format(int64(leg.AvgDelayNs)), leg.SlippageBps, leg.LatencyBps,
+			leg.SpreadBps, leg.Cost, leg.LatencyCost, leg.InFlight, leg.PnL, hedged))
+		drift[leg.TraderID] = leg.LatencyBps
+	}
+	sb.WriteString("\n")
+	fast, okFast := drift[r.config.FastTrader.ID]
+	slow, okSlow := drift[r.config.SlowTrader.ID]
+	if okFast && okSlow {
+		sb.WriteString(numfmt.Sprintf("Hedge slippage attributable to latency, slow − fast: **%+.4f bps**. Positive means the slower hedges lost more to fair value moving in flight.\n\n", slow-fast))
+	}
+	return sb.String()
+}
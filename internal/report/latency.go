@@ -0,0 +1,232 @@
+// Package report — realized latency against the configured model
+package report
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+// latencyMessages orders message types in the realized latency section
+var latencyMessages = []string{"limit", "market", "cancel"}
+
+// latencyChartWidth is the width of a range bar in characters
+const latencyChartWidth = 40
+
+// latencyTrader pairs a trader's configured model with its realized latency
+type latencyTrader struct {
+	name     string
+	baseNs   int64
+	jitterNs int64
+	realized map[string]*metrics.LatencyStats
+}
+
+func (r *Report) latencyTraders() []latencyTrader {
+	return []latencyTrader{
+		{"fast", r.config.FastTrader.BaseLatencyNs(), r.config.FastTrader.JitterNs(), r.fast.RealizedLatency},
+		{"slow", r.config.SlowTrader.BaseLatencyNs(), r.config.SlowTrader.JitterNs(), r.slow.RealizedLatency},
+	}
+}
+
+// renderRealizedLatency compares each trader's arrival − decision times
+// with its configured base and jitter, and charts the two ranges
+func (r *Report) renderRealizedLatency() string {
+	var sb strings.Builder
+	sb.WriteString("## Realized Latency\n\n")
+	sb.WriteString("Arrival minus decision time for every message each trader sent")
+	var held []string
+	if r.config.FastTrader.RiskCheckNs > 0 || r.config.SlowTrader.RiskCheckNs > 0 {
+		held = append(held, "in its risk check")
+	}
+	if r.config.FastTrader.GatewayNs > 0 || r.config.SlowTrader.GatewayNs > 0 {
+		held = append(held, "in its gateway")
+	}
+	if r.config.FastTrader.HasConnection() || r.config.SlowTrader.HasConnection() {
+		held = append(held, "warming its connection")
+	}
+	if n := len(held); n > 0 {
+		sb.WriteString(", less any time ")
+		if n > 1 {
+			sb.WriteString(strings.Join(held[:n-1], ", ") + " or ")
+		}
+		sb.WriteString(held[n-1])
+	}
+	sb.WriteString(". ")
+	sb.WriteString("Each should fall within the configured base to base + jitter.\n\n")
+	sb.WriteString("| Trader | Message | Count | Min | P50 | P99 | Max | Configured | Check |\n")
+	sb.WriteString("|--------|---------|-------|-----|-----|-----|-----|------------|-------|\n")
+
+	var flagged []string
+	lo, hi := int64(-1), int64(0)
+	for _, t := range r.latencyTraders() {
+		configured := numfmt.Sprintf("%s – %s", latency.Format(t.baseNs), latency.Format(t.baseNs+t.jitterNs))
+		lo, hi = widen(lo, hi, t.baseNs, t.baseNs+t.jitterNs)
+		for _, msg := range latencyMessages {
+			s := t.realized[msg]
+			if s == nil {
+				continue
+			}
+			lo, hi = widen(lo, hi, s.MinNs, s.MaxNs)
+			check := "ok"
+			if issues := s.Check(t.baseNs, t.jitterNs); len(issues) > 0 {
+				check = "**" + strings.Join(issues, "; ") + "**"
+				flagged = append(flagged, numfmt.Sprintf("%s %s", t.name, msg))
+			}
+			sb.WriteString(numfmt.Sprintf("| %s | %s | %d | %s | %s | %s | %s | %s | %s |\n",
+				t.name, msg, s.Count, latency.Format(s.MinNs), latency.Format(s.P50Ns),
+				latency.Format(s.P99Ns), latency.Format(s.MaxNs), configured, check))
+		}
+	}
+	sb.WriteString("\n")
+
+	if hi > lo {
+		sb.WriteString("```\n")
+		loLabel, hiLabel := latency.Format(lo), latency.Format(hi)
+		gap := max(1, latencyChartWidth-utf8.RuneCountInString(loLabel)-utf8.RuneCountInString(hiLabel))
+		sb.WriteString(numfmt.Sprintf("%-13s %s%s%s\n", "", loLabel, strings.Repeat(" ", gap), hiLabel))
+		for _, t := range r.latencyTraders() {
+			sb.WriteString(numfmt.Sprintf("%-13s %s\n", t.name+" config", rangeBar(lo, hi, t.baseNs, t.baseNs+t.jitterNs, -1)))
+			for _, msg := range latencyMessages {
+				if s := t.realized[msg]; s != nil {
+					sb.WriteString(numfmt.Sprintf("%-13s %s\n", "  "+msg, rangeBar(lo, hi, s.MinNs, s.MaxNs, s.P50Ns)))
+				}
+			}
+		}
+		sb.WriteString("```\n\n")
+		sb.WriteString("Bars run from min to max; `|` marks the realized median.\n\n")
+	}
+
+	if len(flagged) > 0 {
+		sb.WriteString(numfmt.Sprintf("**Warning:** realized latency disagrees with the config for %s. ", strings.Join(flagged, ", ")))
+		sb.WriteString("Some messages are not going through the latency model as configured.\n\n")
+	}
+	sb.WriteString(r.renderGateway())
+	sb.WriteString(r.renderWarmup())
+	return sb.String()
+}
+
+// renderGateway tabulates the time messages spent in each trader's order
+// entry gateway, when either trader has one
+func (r *Report) renderGateway() string {
+	if r.fast.Gateway == nil && r.slow.Gateway == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("### Gateway Queuing\n\n")
+	sb.WriteString("Each gateway sends one message at a time, so a burst waits behind its first message. ")
+	sb.WriteString("Times run from decision until the message leaves the gateway, before network latency.\n\n")
+	sb.WriteString("| Trader | Send Time | Messages | Queued | P50 | P99 | Max |\n")
+	sb.WriteString("|--------|-----------|----------|--------|-----|-----|-----|\n")
+	for _, t := range []struct {
+		name string
+		tc   scenario.TraderConfig
+		m    *metrics.TraderMetrics
+	}{{"fast", r.config.FastTrader, r.fast}, {"slow", r.config.SlowTrader, r.slow}} {
+		s := t.m.Gateway
+		if s == nil {
+			sb.WriteString(numfmt.Sprintf("| %s | none | - | - | - | - | - |\n", t.name))
+			continue
+		}
+		sb.WriteString(numfmt.Sprintf("| %s | %s | %d | %d (%.1f%%) | %s | %s | %s |\n",
+			t.name, latency.Format(t.tc.GatewayNs), s.Count, t.m.GatewayQueued,
+			100*float64(t.m.GatewayQueued)/float64(s.Count),
+			latency.Format(s.P50Ns), latency.Format(s.P99Ns), latency.Format(s.MaxNs)))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// renderWarmup tabulates the handshake and idle warm-up delays each
+// trader's messages paid, when either trader's connection has them
+func (r *Report) renderWarmup() string {
+	if !r.config.FastTrader.HasConnection() && !r.config.SlowTrader.HasConnection() {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("### Connection Warm-up\n\n")
+	sb.WriteString("A trader's first message pays its handshake, and a message after the idle time pays the warm-up, ")
+	sb.WriteString("so a strategy that goes quiet between bursts pays more than a steady one.\n\n")
+	sb.WriteString("| Trader | Handshake | Idle After | Warm-up | Messages | Cold | Added |\n")
+	sb.WriteString("|--------|-----------|------------|---------|----------|------|-------|\n")
+	dur := func(ns int64) string {
+		if ns == 0 {
+			return "none"
+		}
+		return latency.Format(ns)
+	}
+	for _, t := range []struct {
+		name string
+		tc   scenario.TraderConfig
+		m    *metrics.TraderMetrics
+	}{{"fast", r.config.FastTrader, r.fast}, {"slow", r.config.SlowTrader, r.slow}} {
+		if !t.tc.HasConnection() {
+			sb.WriteString(numfmt.Sprintf("| %s | none | - | - | - | - | - |\n", t.name))
+			continue
+		}
+		sent := 0
+		for _, s := range t.m.RealizedLatency {
+			sent += s.Count
+		}
+		pct := 0.0
+		if sent > 0 {
+			pct = 100 * float64(t.m.ColdMessages) / float64(sent)
+		}
+		sb.WriteString(numfmt.Sprintf("| %s | %s | %s | %s | %d | %d (%.1f%%) | %s |\n",
+			t.name, dur(t.tc.HandshakeNs), dur(t.tc.IdleNs), dur(t.tc.WarmupNs), sent,
+			t.m.ColdMessages, pct, latency.Format(t.m.WarmupNs)))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// widen extends [lo, hi] to cover [a, b]; lo < 0 means empty
+func widen(lo, hi, a, b int64) (int64, int64) {
+	if lo < 0 || a < lo {
+		lo = a
+	}
+	if b > hi {
+		hi = b
+	}
+	return lo, hi
+}
+
+// rangeBar draws [from, to] on a scale from lo to hi, with a mark at mark
+// unless it is negative
+func rangeBar(lo, hi, from, to, mark int64) string {
+	col := func(ns int64) int {
+		c := int(float64(ns-lo) / float64(hi-lo) * float64(latencyChartWidth-1))
+		return max(0, min(latencyChartWidth-1, c))
+	}
+	bar := []rune(strings.Repeat("·", latencyChartWidth))
+	for i := col(from); i <= col(to); i++ {
+		bar[i] = '='
+	}
+	if mark >= 0 {
+		bar[col(mark)] = '|'
+	}
+	return string(bar)
+}
+
+// latencyWarnings lists each trader and message type whose realized
+// latency disagrees with the config
+func latencyWarnings(cfg *scenario.Config, m map[string]*metrics.TraderMetrics) []string {
+	var out []string
+	for _, t := range []struct {
+		tc scenario.TraderConfig
+		m  *metrics.TraderMetrics
+	}{{cfg.FastTrader, m[cfg.FastTrader.ID]}, {cfg.SlowTrader, m[cfg.SlowTrader.ID]}} {
+		if t.m == nil {
+			continue
+		}
+		for _, msg := range latencyMessages {
+			for _, issue := range t.m.RealizedLatency[msg].Check(t.tc.BaseLatencyNs(), t.tc.JitterNs()) {
+				out = append(out, numfmt.Sprintf("%s %s: %s", t.tc.ID, msg, issue))
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,234 @@
+// Package report — venue mechanism comparison
+package report
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scoring"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/venue"
+)
+
+// MechanismResult is one venue mechanism's run
+type MechanismResult struct {
+	Name string
+	ScenarioResult
+}
+
+// fairnessMetric is a metric the mechanism matrix compares; its gap is
+// fast minus slow, and the smaller the gap the fairer the venue
+type fairnessMetric struct {
+	key   string
+	label string
+	get   func(m *metrics.TraderMetrics) float64
+	fmt   string
+}
+
+var fairnessMetrics = []fairnessMetric{
+	{"fill_rate_pct", "Fill Rate (%)", func(m *metrics.TraderMetrics) float64 { return m.FillRate * 100 }, "%+.1f"},
+	{"slippage_bps", "Slippage (bps)", func(m *metrics.TraderMetrics) float64 { return m.SlippageBps }, "%+.2f"},
+	{"time_to_fill_ms", "Avg TTF (ms)", func(m *metrics.TraderMetrics) float64 { return m.AvgTimeToFillMs }, "%+.2f"},
+	{"adverse_selection_bps", "Adv Select (bps)", func(m *metrics.TraderMetrics) float64 { return m.AdverseSelectionBps }, "%+.2f"},
+	{"net_pnl", "Net PnL", func(m *metrics.TraderMetrics) float64 { return m.NetPnL }, "%+.2f"},
+}
+
+// MechanismReport compares how fair the same market is under different
+// venue mechanisms
+type MechanismReport struct {
+	results []MechanismResult
+	outDir  string
+
+	// Scores ranks the mechanisms by composite score; nil omits them
+	Scores *scoring.Ranking
+}
+
+// NewMechanismReport creates a mechanism comparison over results, which
+// share a scenario and seed
+func NewMechanismReport(results []MechanismResult, outDir string) *MechanismReport {
+	return &MechanismReport{results: results, outDir: outDir}
+}
+
+// mechanismSummary is one mechanism's row of the matrix
+type mechanismSummary struct {
+	Mechanism   string             `json:"mechanism"`
+	Venue       string             `json:"venue"`
+	Gaps        map[string]float64 `json:"gaps"`
+	Ranks       map[string]int     `json:"ranks"`
+	AvgRank     float64            `json:"avg_rank"`
+	OverallRank int                `json:"overall_rank"`
+	Inversions  inversionSummary   `json:"priority_inversions"`
+	RunDir      string             `json:"run_dir"`
+}
+
+// inversionSummary counts priority inversions suffered by each trader,
+// and those between the two
+type inversionSummary struct {
+	Fast       int `json:"fast"`
+	Slow       int `json:"slow"`
+	FastBySlow int `json:"fast_by_slow"`
+	SlowByFast int `json:"slow_by_fast"`
+}
+
+// Generate writes mechanisms.md and mechanisms.json, and scores.json and
+// scores.csv when scored
+func (mr *MechanismReport) Generate() error {
+	if err := os.MkdirAll(mr.outDir, 0755); err != nil {
+		return fmt.Errorf("create output dir: %w", err)
+	}
+	summaries := mr.buildSummary()
+
+	reportPath := filepath.Join(mr.outDir, "mechanisms.md")
+	if err := os.WriteFile(reportPath, []byte(mr.renderMarkdown(summaries)), 0644); err != nil {
+		return fmt.Errorf("write mechanism report: %w", err)
+	}
+
+	dataPath := filepath.Join(mr.outDir, "mechanisms.json")
+	data, _ := numfmt.MarshalIndent(summaries, "", "  ")
+	if err := os.WriteFile(dataPath, data, 0644); err != nil {
+		return err
+	}
+	if mr.Scores != nil {
+		return writeScores(mr.outDir, mr.Scores)
+	}
+	return nil
+}
+
+// buildSummary computes each mechanism's gaps and ranks, in run order.
+// Mechanisms missing a trader's metrics are left out of the ranking
+func (mr *MechanismReport) buildSummary() []mechanismSummary {
+	var summaries []mechanismSummary
+	for _, r := range mr.results {
+		fast := r.Metrics[r.Config.FastTrader.ID]
+		slow := r.Metrics[r.Config.SlowTrader.ID]
+		if fast == nil || slow == nil {
+			continue
+		}
+		s := mechanismSummary{
+			Mechanism: r.Name,
+			Venue:     venue.Describe(r.Config),
+			Gaps:      make(map[string]float64),
+			Ranks:     make(map[string]int),
+			RunDir:    r.RunDir,
+			Inversions: inversionSummary{
+				Fast:       fast.PriorityInversions,
+				Slow:       slow.PriorityInversions,
+				FastBySlow: fast.JumpedBy[slow.TraderID],
+				SlowByFast: slow.JumpedBy[fast.TraderID],
+			},
+		}
+		for _, fm := range fairnessMetrics {
+			s.Gaps[fm.key] = fm.get(fast) - fm.get(slow)
+		}
+		summaries = append(summaries, s)
+	}
+
+	for _, fm := range fairnessMetrics {
+		for i := range summaries {
+			rank := 1
+			for j := range summaries {
+				if math.Abs(summaries[j].Gaps[fm.key]) < math.Abs(summaries[i].Gaps[fm.key]) {
+					rank++
+				}
+			}
+			summaries[i].Ranks[fm.key] = rank
+			summaries[i].AvgRank += float64(rank) / float64(len(fairnessMetrics))
+		}
+	}
+
+	order := make([]int, len(summaries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return summaries[order[a]].AvgRank < summaries[order[b]].AvgRank })
+	for rank, i := range order {
+		summaries[i].OverallRank = rank + 1
+	}
+	return summaries
+}
+
+func (mr *MechanismReport) renderMarkdown(summaries []mechanismSummary) string {
+	var sb strings.Builder
+
+	sb.WriteString("# Venue Mechanism Comparison\n\n")
+	sb.WriteString("The same market, with the same seed, traders and order flow, run under each venue mechanism. ")
+	sb.WriteString("Each cell is the fast trader's value minus the slow trader's; the closer to zero, the less ")
+	sb.WriteString("latency decides the outcome.\n\n")
+	if len(mr.results) > 0 {
+		cfg := mr.results[0].Config
+		sb.WriteString(numfmt.Sprintf("**Scenario:** %s | **Seed:** %d\n\n", cfg.Name, cfg.Seed))
+	}
+	if len(summaries) == 0 {
+		sb.WriteString("No mechanism produced metrics for both traders.\n")
+		return sb.String()
+	}
+
+	sb.WriteString("## Latency Gap (Fast − Slow)\n\n")
+	sb.WriteString("| Mechanism | Venue |")
+	for _, fm := range fairnessMetrics {
+		sb.WriteString(numfmt.Sprintf(" %s |", fm.label))
+	}
+	sb.WriteString("\n|-----------|-------|")
+	sb.WriteString(strings.Repeat("--------|", len(fairnessMetrics)))
+	sb.WriteString("\n")
+	for _, s := range summaries {
+		sb.WriteString(numfmt.Sprintf("| %s | %s |", s.Mechanism, s.Venue))
+		for _, fm := range fairnessMetrics {
+			sb.WriteString(numfmt.Sprintf(" "+fm.fmt+" |", s.Gaps[fm.key]))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("## Rankings\n\n")
+	sb.WriteString("Per metric, 1 is the smallest absolute gap; mechanisms with equal gaps share a rank. ")
+	sb.WriteString("The overall rank orders mechanisms by their average rank.\n\n")
+	sb.WriteString("| Overall | Mechanism |")
+	for _, fm := range fairnessMetrics {
+		sb.WriteString(numfmt.Sprintf(" %s |", fm.label))
+	}
+	sb.WriteString(" Avg Rank |\n|---------|-----------|")
+	sb.WriteString(strings.Repeat("--------|", len(fairnessMetrics)))
+	sb.WriteString("----------|\n")
+	byRank := make([]mechanismSummary, len(summaries))
+	for _, s := range summaries {
+		byRank[s.OverallRank-1] = s
+	}
+	for _, s := range byRank {
+		sb.WriteString(numfmt.Sprintf("| %d | %s |", s.OverallRank, s.Mechanism))
+		for _, fm := range fairnessMetrics {
+			sb.WriteString(numfmt.Sprintf(" %d |", s.Ranks[fm.key]))
+		}
+		sb.WriteString(numfmt.Sprintf(" %.1f |\n", s.AvgRank))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString(numfmt.Sprintf("**Fairest here:** %s (%s). ", byRank[0].Mechanism, byRank[0].Venue))
+	sb.WriteString("One seed is one sample; rerun with other seeds before reading much into close ranks.\n\n")
+
+	sb.WriteString("## Priority Inversions\n\n")
+	sb.WriteString("Times each trader's resting order was passed over for a later arrival at the same price. ")
+	sb.WriteString("Price-time priority never does this; these count what a mechanism trades away for it.\n\n")
+	sb.WriteString("| Mechanism | Fast Jumped | Slow Jumped | Fast by Slow | Slow by Fast |\n")
+	sb.WriteString("|-----------|-------------|-------------|--------------|--------------|\n")
+	for _, s := range summaries {
+		inv := s.Inversions
+		sb.WriteString(numfmt.Sprintf("| %s | %d | %d | %d | %d |\n", s.Mechanism, inv.Fast, inv.Slow, inv.FastBySlow, inv.SlowByFast))
+	}
+	sb.WriteString("\n")
+
+	if mr.Scores != nil {
+		sb.WriteString(renderScores(mr.Scores))
+	}
+
+	sb.WriteString("## Runs\n\n")
+	for _, s := range summaries {
+		sb.WriteString(numfmt.Sprintf("- %s: `%s`\n", s.Mechanism, s.RunDir))
+	}
+	return sb.String()
+}
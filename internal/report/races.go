@@ -0,0 +1,149 @@
+// Package report — race-by-race attribution of signal races
+package report
+
+import (
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+)
+
+// maxRaceRows caps the race-by-race table; metrics.json has them all
+const maxRaceRows = 40
+
+// raceRow is one signal's race as both traders ran it; nil for a trader
+// that sent nothing in response
+type raceRow struct {
+	signalNs   int64
+	value      float64
+	fast, slow *metrics.RaceEntry
+}
+
+// raceRows merges the traders' entries by signal
+func (r *Report) raceRows() []raceRow {
+	var rows []raceRow
+	f, s := r.fast.Races, r.slow.Races
+	for len(f) > 0 || len(s) > 0 {
+		switch {
+		case len(s) == 0 || len(f) > 0 && f[0].SignalNs < s[0].SignalNs:
+			rows = append(rows, raceRow{f[0].SignalNs, f[0].Value, &f[0], nil})
+			f = f[1:]
+		case len(f) == 0 || s[0].SignalNs < f[0].SignalNs:
+			rows = append(rows, raceRow{s[0].SignalNs, s[0].Value, nil, &s[0]})
+			s = s[1:]
+		default:
+			rows = append(rows, raceRow{f[0].SignalNs, f[0].Value, &f[0], &s[0]})
+			f, s = f[1:], s[1:]
+		}
+	}
+	return rows
+}
+
+// renderRaces summarizes who won each signal's race and what losing cost,
+// then lists the races one by one
+func (r *Report) renderRaces() string {
+	var sb strings.Builder
+	sb.WriteString("## Signal Races\n\n")
+	sb.WriteString("Each signal starts a race: the orders a trader decides the moment it hears the signal, ranked by when the first of them reaches the venue. ")
+	sb.WriteString("A race is contested when both traders respond. Cost is the fills' price against the mid when the signal fired; positive is worse.\n\n")
+
+	sb.WriteString("| Metric | Fast | Slow |\n")
+	sb.WriteString("|--------|------|------|\n")
+	sb.WriteString(numfmt.Sprintf("| Races entered | %d | %d |\n", r.fast.RacesEntered, r.slow.RacesEntered))
+	sb.WriteString(numfmt.Sprintf("| Contested races won | %d | %d |\n", r.fast.RacesWon, r.slow.RacesWon))
+	sb.WriteString(numfmt.Sprintf("| Win rate | %.1f%% | %.1f%% |\n", r.fast.RaceWinRate*100, r.slow.RaceWinRate*100))
+	sb.WriteString(numfmt.Sprintf("| Lost, missed liquidity | %d | %d |\n", r.fast.RacesMissed, r.slow.RacesMissed))
+	sb.WriteString(numfmt.Sprintf("| Lost, queued behind winner | %d | %d |\n", r.fast.RacesQueueBehind, r.slow.RacesQueueBehind))
+	sb.WriteString(numfmt.Sprintf("| Avg cost vs winner when lost (bps) | %.4f | %.4f |\n\n", r.fast.RaceCostGapBps, r.slow.RaceCostGapBps))
+
+	rows := r.raceRows()
+	if len(rows) == 0 {
+		sb.WriteString("No trader responded to a signal.\n\n")
+		return sb.String()
+	}
+	sb.WriteString("| Signal | Value | Fast arrives | Slow arrives | Winner | Lead | Fast filled | Slow filled | Loser | Cost gap (bps) |\n")
+	sb.WriteString("|--------|-------|--------------|--------------|--------|------|-------------|-------------|-------|----------------|\n")
+	for i, row := range rows {
+		if i == maxRaceRows {
+			sb.WriteString(numfmt.Sprintf("\n%d more races are listed under `races` in metrics.json.\n", len(rows)-maxRaceRows))
+			break
+		}
+		winner, lead, loser, gap := "", "", "", ""
+		entrants := map[string]*metrics.RaceEntry{"fast": row.fast, "slow": row.slow}
+		for _, name := range []string{"fast", "slow"} {
+			e := entrants[name]
+			switch {
+			case e == nil:
+			case e.Rank == 1 && e.Entrants == 1:
+				winner = name + " (alone)"
+			case e.Rank == 1:
+				winner, lead = name, latency.Format(e.LeadNs)
+			default:
+				loser = raceOutcome(e)
+				if e.CostGapBps != nil {
+					gap = numfmt.Sprintf("%+.2f", *e.CostGapBps)
+				}
+			}
+		}
+		sb.WriteString(numfmt.Sprintf("| %s | %+.3f | %s | %s | %s | %s | %s | %s | %s | %s |\n",
+			latency.Format(row.signalNs), row.value, raceArrival(row.fast), raceArrival(row.slow),
+			winner, lead, raceFilled(row.fast), raceFilled(row.slow), loser, gap))
+	}
+	sb.WriteString("\nArrivals are measured from the signal. The loser column is the trailing trader's outcome against the winner.\n\n")
+	return sb.String()
+}
+
+func raceArrival(e *metrics.RaceEntry) string {
+	if e == nil {
+		return "—"
+	}
+	return "+" + latency.Format(e.ArrivalNs-e.SignalNs)
+}
+
+func raceFilled(e *metrics.RaceEntry) string {
+	if e == nil {
+		return "—"
+	}
+	return numfmt.Sprintf("%d", e.FilledQty)
+}
+
+// raceOutcome describes how a losing entrant fared against the winner
+func raceOutcome(e *metrics.RaceEntry) string {
+	var out []string
+	if e.Missed {
+		out = append(out, "missed liquidity")
+	}
+	if e.QueueBehind {
+		out = append(out, "queued behind")
+	}
+	if len(out) == 0 && e.FilledQty > 0 {
+		return "filled"
+	}
+	if len(out) == 0 {
+		return "no fill"
+	}
+	return strings.Join(out, ", ")
+}
+
+// renderCancelRaces compares how often each trader's cancels beat the
+// contra flow heading for their targets
+func (r *Report) renderCancelRaces() string {
+	if r.fast.CancelRaces+r.slow.CancelRaces == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("### Fill vs Cancel Races\n\n")
+	sb.WriteString(numfmt.Sprintf("A cancel is in a race when contra flow reached its target's price within %s of the decision. ",
+		latency.Format(r.config.Metrics.CancelRaceWindow())))
+	sb.WriteString("It wins if it lands first; a stale order that fills while the cancel is in flight is picked off.\n\n")
+	sb.WriteString("| Metric | Fast | Slow |\n")
+	sb.WriteString("|--------|------|------|\n")
+	sb.WriteString(numfmt.Sprintf("| Cancel races | %d | %d |\n", r.fast.CancelRaces, r.slow.CancelRaces))
+	sb.WriteString(numfmt.Sprintf("| Cancel won | %d | %d |\n", r.fast.CancelRacesWon, r.slow.CancelRacesWon))
+	sb.WriteString(numfmt.Sprintf("| Fill won | %d | %d |\n", r.fast.CancelRacesLost, r.slow.CancelRacesLost))
+	sb.WriteString(numfmt.Sprintf("| Partial (part filled, rest canceled) | %d | %d |\n", r.fast.CancelRacesPartial, r.slow.CancelRacesPartial))
+	sb.WriteString(numfmt.Sprintf("| Win rate | %.1f%% | %.1f%% |\n", r.fast.CancelRaceWinRate*100, r.slow.CancelRaceWinRate*100))
+	sb.WriteString(numfmt.Sprintf("| Qty picked off | %d | %d |\n\n", r.fast.PickedOffQty, r.slow.PickedOffQty))
+	return sb.String()
+}
@@ -2,16 +2,25 @@
 package report
 
 import (
-	"encoding/json"
 	"fmt"
 	"math"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/akshitanchan/execution-fairness-simulator/internal/attribution"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/batching"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/engine"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/manifest"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/provenance"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/quantile"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/venue"
 )
 
 // Report generates and writes the fairness report
@@ -20,6 +29,52 @@ type Report struct {
 	fast   *metrics.TraderMetrics
 	slow   *metrics.TraderMetrics
 	outDir string
+
+	// Attribution splits the gaps into latency and information when the
+	// run configures both; nil omits the section
+	Attribution []attribution.Gap
+
+	// Sessions are the metrics of each trading session of a multi-session
+	// run; nil omits the section
+	Sessions []metrics.SessionMetrics
+
+	// Excluded are the metrics over the run's exclusion windows alone,
+	// which the main metrics leave out; nil omits the section
+	Excluded map[string]*metrics.TraderMetrics
+
+	// Background is each background trader's activity when the run
+	// configures a population; nil omits the section
+	Background []metrics.BackgroundMetrics
+
+	// Ties are same-timestamp collisions between traders' messages; the
+	// section appears when the fast or slow trader was in one
+	Ties []engine.TiePair
+
+	// Capital is the use of their capital by the traders with a limit;
+	// nil omits the section
+	Capital []sim.CapitalUsage
+
+	// Hedge is the traders' hedging on the future in runs with a hedge
+	// leg; nil omits the section
+	Hedge []sim.HedgeLeg
+
+	// Batching sets the batching slow trader against a reactive re-run
+	// of it; nil omits the section
+	Batching *batching.Comparison
+
+	// Truncated is set when a time budget ended the run early; the
+	// report then says the metrics cover only the completed portion
+	Truncated *sim.Truncation
+
+	// Provenance is what produced the run; the baseline section uses its
+	// config hash. It is not written out: the host, Go version and commit
+	// stay in provenance.json, so metrics.json and report.md come out the
+	// same on every machine
+	Provenance *provenance.Stamp
+
+	// Baseline is the manifest's baseline run to compare against; nil
+	// omits the section
+	Baseline *manifest.Baseline
 }
 
 // NewReport creates a report generator
@@ -32,17 +87,49 @@ func NewReport(cfg *scenario.Config, metricsMap map[string]*metrics.TraderMetric
 	}
 }
 
+// ForRun creates the report run writes for a finished run, with its ties,
+// capital, hedge legs, truncation and provenance. The golden snapshots
+// hash what it writes
+func ForRun(cfg *scenario.Config, metricsMap map[string]*metrics.TraderMetrics, result *sim.RunResult) *Report {
+	r := NewReport(cfg, metricsMap, result.OutputDir)
+	r.Ties = result.Ties
+	r.Capital = result.Capital
+	r.Hedge = result.Hedge
+	r.Truncated = result.Truncated
+	r.Provenance = result.Provenance
+	return r
+}
+
 // Generate produces the full report
 func (r *Report) Generate() error {
 	// Save metrics as JSON
 	metricsPath := filepath.Join(r.outDir, "metrics.json")
-	metricsData, _ := json.MarshalIndent(map[string]*metrics.TraderMetrics{
+	saved := map[string]any{
 		"fast": r.fast,
 		"slow": r.slow,
-	}, "", "  ")
+	}
+	metricsData, _ := numfmt.MarshalIndent(saved, "", "  ")
 	if err := os.WriteFile(metricsPath, metricsData, 0644); err != nil {
 		return fmt.Errorf("write metrics: %w", err)
 	}
+	if r.Background != nil {
+		data, _ := numfmt.MarshalIndent(r.Background, "", "  ")
+		if err := os.WriteFile(filepath.Join(r.outDir, "background.json"), data, 0644); err != nil {
+			return fmt.Errorf("write background metrics: %w", err)
+		}
+	}
+	if r.Sessions != nil {
+		data, _ := numfmt.MarshalIndent(r.Sessions, "", "  ")
+		if err := os.WriteFile(filepath.Join(r.outDir, "sessions.json"), data, 0644); err != nil {
+			return fmt.Errorf("write session metrics: %w", err)
+		}
+	}
+	if r.Excluded != nil {
+		data, _ := numfmt.MarshalIndent(map[string]any{"windows": r.config.Metrics.Exclude, "metrics": r.Excluded}, "", "  ")
+		if err := os.WriteFile(filepath.Join(r.outDir, "excluded.json"), data, 0644); err != nil {
+			return fmt.Errorf("write excluded metrics: %w", err)
+		}
+	}
 
 	// Generate text/markdown report
 	reportPath := filepath.Join(r.outDir, "report.md")
@@ -61,23 +148,61 @@ func (r *Report) Generate() error {
 	return nil
 }
 
+// addMarkoutRows adds adverse selection at each markout horizon. The
+// default single 100 ms horizon keeps its plain label
+func (r *Report) addMarkoutRows(sb *strings.Builder) {
+	if len(r.config.Metrics.HorizonsNs) == 0 {
+		r.addRow(sb, "Adverse Selection (bps)", r.fast.AdverseSelectionBps, r.slow.AdverseSelectionBps, true)
+		return
+	}
+	for i, h := range r.config.Metrics.HorizonsNs {
+		if i >= len(r.fast.Markouts) || i >= len(r.slow.Markouts) {
+			break
+		}
+		label := numfmt.Sprintf("Adverse Selection %s (bps)", latency.Format(h))
+		r.addRow(sb, label, r.fast.Markouts[i].Bps, r.slow.Markouts[i].Bps, true)
+	}
+}
+
 func (r *Report) renderMarkdown() string {
 	var sb strings.Builder
 
 	sb.WriteString("# Execution Fairness Report\n\n")
-	sb.WriteString(fmt.Sprintf("**Scenario:** %s | **Seed:** %d\n\n", r.config.Name, r.config.Seed))
+	sb.WriteString(numfmt.Sprintf("**Scenario:** %s | **Seed:** %d", r.config.Name, r.config.Seed))
+	if !r.config.Venue.IsZero() {
+		sb.WriteString(numfmt.Sprintf(" | **Venue:** %s", venue.Describe(r.config)))
+	}
+	sb.WriteString("\n\n")
+	if t := r.Truncated; t != nil {
+		sb.WriteString(numfmt.Sprintf("> **Truncated:** the run stopped at t=%s of %s on its %s budget: %s. "+
+			"Every figure below covers only the completed portion.\n\n",
+			latency.Format(t.Timestamp), latency.Format(r.config.Duration), t.Limit, t.Detail))
+	}
 
 	// Latency config table
 	sb.WriteString("## Latency Configuration\n\n")
-	sb.WriteString("| Trader | Base Latency (ms) | Jitter (ms) |\n")
-	sb.WriteString("|--------|-------------------|-------------|\n")
-	sb.WriteString(fmt.Sprintf("| fast   | %d                | %d          |\n",
-		r.config.FastTrader.BaseLatencyMs, r.config.FastTrader.JitterMs))
-	sb.WriteString(fmt.Sprintf("| slow   | %d               | %d         |\n\n",
-		r.config.SlowTrader.BaseLatencyMs, r.config.SlowTrader.JitterMs))
+	sb.WriteString("| Trader | Base Latency | Jitter | Signal |\n")
+	sb.WriteString("|--------|--------------|--------|--------|\n")
+	sb.WriteString(numfmt.Sprintf("| fast   | %s | %s | %s |\n",
+		latency.Format(r.config.FastTrader.BaseLatencyNs()), latency.Format(r.config.FastTrader.JitterNs()),
+		r.config.FastTrader.SignalAccess()))
+	sb.WriteString(numfmt.Sprintf("| slow   | %s | %s | %s |\n\n",
+		latency.Format(r.config.SlowTrader.BaseLatencyNs()), latency.Format(r.config.SlowTrader.JitterNs()),
+		r.config.SlowTrader.SignalAccess()))
+
+	if r.fast != nil && r.slow != nil {
+		sb.WriteString(r.renderRealizedLatency())
+	}
+
+	if r.config.StrategyAsymmetric() {
+		sb.WriteString(r.renderAB())
+	}
 
 	// Side-by-side metrics
 	sb.WriteString("## Execution Metrics\n\n")
+	if len(r.config.Metrics.Exclude) > 0 {
+		sb.WriteString("Orders decided in the exclusion windows are left out; see [Exclusion Windows](#exclusion-windows).\n\n")
+	}
 	sb.WriteString("| Metric | Fast | Slow | Delta |\n")
 	sb.WriteString("|--------|------|------|-------|\n")
 
@@ -85,39 +210,237 @@ func (r *Report) renderMarkdown() string {
 		r.addRow(&sb, "Orders Sent", float64(r.fast.OrdersSent), float64(r.slow.OrdersSent), false)
 		r.addRow(&sb, "Limit Orders", float64(r.fast.LimitOrders), float64(r.slow.LimitOrders), false)
 		r.addRow(&sb, "Market Orders", float64(r.fast.MarketOrders), float64(r.slow.MarketOrders), false)
+		if r.fast.ImmediateOrders+r.slow.ImmediateOrders > 0 {
+			r.addRow(&sb, "IOC/FOK Orders", float64(r.fast.ImmediateOrders), float64(r.slow.ImmediateOrders), false)
+			r.addRow(&sb, "Killed IOC/FOK Orders", float64(r.fast.KilledOrders), float64(r.slow.KilledOrders), false)
+			r.addRow(&sb, "Killed Qty", float64(r.fast.KilledQty), float64(r.slow.KilledQty), false)
+		}
+		if r.fast.IcebergOrders+r.slow.IcebergOrders > 0 {
+			r.addRow(&sb, "Iceberg Orders", float64(r.fast.IcebergOrders), float64(r.slow.IcebergOrders), false)
+			r.addRow(&sb, "Iceberg Refreshes", float64(r.fast.IcebergRefreshes), float64(r.slow.IcebergRefreshes), false)
+			r.addRow(&sb, "Refresh Queue Pos", r.fast.RefreshQueuePos, r.slow.RefreshQueuePos, true)
+		}
 		r.addRow(&sb, "Total Fills", float64(r.fast.TotalFills), float64(r.slow.TotalFills), false)
 		r.addRow(&sb, "Total Qty Filled", float64(r.fast.TotalQtyFilled), float64(r.slow.TotalQtyFilled), false)
+		r.addRow(&sb, "Messages per Trade", r.fast.MessagesPerTrade, r.slow.MessagesPerTrade, true)
+		r.addRow(&sb, "Messages per Filled Share", r.fast.MessagesPerShare, r.slow.MessagesPerShare, true)
+		if r.fast.BurstMessages+r.slow.BurstMessages > 0 {
+			r.addRow(&sb, "Burst Messages", float64(r.fast.BurstMessages), float64(r.slow.BurstMessages), false)
+			r.addRow(&sb, "Burst Messages per Trade", r.fast.BurstMessagesPerTrade, r.slow.BurstMessagesPerTrade, true)
+		}
 		r.addRow(&sb, "Fill Rate", r.fast.FillRate*100, r.slow.FillRate*100, true)
 		r.addRow(&sb, "Avg Exec Price", r.fast.AvgExecPrice, r.slow.AvgExecPrice, true)
-		r.addRow(&sb, "Avg Slippage", r.fast.AvgSlippage, r.slow.AvgSlippage, true)
-		r.addRow(&sb, "Slippage (bps)", r.fast.SlippageBps, r.slow.SlippageBps, true)
-		r.addRow(&sb, "Avg Time-to-Fill (ms)", r.fast.AvgTimeToFillNs, r.slow.AvgTimeToFillNs, true)
+		slip := "Slippage"
+		if bench := r.config.Metrics.BenchmarkName(); bench != metrics.BenchDecisionMid {
+			slip += " vs " + strings.ReplaceAll(bench, "_", " ")
+		}
+		r.addRow(&sb, "Avg "+slip, r.fast.AvgSlippage, r.slow.AvgSlippage, true)
+		r.addRow(&sb, slip+" (bps)", r.fast.SlippageBps, r.slow.SlippageBps, true)
+		r.addRow(&sb, "Avg Time-to-Fill (ms)", r.fast.AvgTimeToFillMs, r.slow.AvgTimeToFillMs, true)
 		r.addRow(&sb, "Avg Queue Pos (place)", r.fast.AvgQueuePosPlace, r.slow.AvgQueuePosPlace, true)
 		r.addRow(&sb, "Avg Queue Pos (fill)", r.fast.AvgQueuePosFill, r.slow.AvgQueuePosFill, true)
-		r.addRow(&sb, "Adverse Selection (bps)", r.fast.AdverseSelectionBps, r.slow.AdverseSelectionBps, true)
+		r.addRow(&sb, "Joined Queue Front (%)", r.fast.QueueFrontPct(), r.slow.QueueFrontPct(), true)
+		r.addRow(&sb, "Avg Qty Ahead (place)", r.fast.AvgAheadQtyPlace, r.slow.AvgAheadQtyPlace, true)
+		r.addRow(&sb, "Avg Qty Ahead (fill)", r.fast.AvgAheadQtyFill, r.slow.AvgAheadQtyFill, true)
+		r.addMarkoutRows(&sb)
+		if r.config.Metrics.Computes(metrics.GroupRequotes) {
+			r.addRow(&sb, "Re-quotes", float64(r.fast.Requotes), float64(r.slow.Requotes), false)
+			r.addRow(&sb, "Longest Re-quote Chain", float64(r.fast.LongestRequoteChain), float64(r.slow.LongestRequoteChain), false)
+			r.addRow(&sb, "Re-quote RTT P50 (ms)", requoteRTTMs(r.fast), requoteRTTMs(r.slow), true)
+			r.addRow(&sb, "Time at Inside (%)", r.fast.TimeAtInside*100, r.slow.TimeAtInside*100, true)
+		}
+		r.addRow(&sb, "Final Position", float64(r.fast.FinalPosition), float64(r.slow.FinalPosition), false)
+		r.addRow(&sb, "Realized PnL", r.fast.RealizedPnL, r.slow.RealizedPnL, true)
+		r.addRow(&sb, "Fees", r.fast.Fees, r.slow.Fees, true)
+		r.addRow(&sb, "Net PnL (marked)", r.fast.NetPnL, r.slow.NetPnL, true)
 	}
 	sb.WriteString("\n")
 
 	// Time-to-fill distribution summary
 	sb.WriteString("## Time-to-Fill Distribution (ms)\n\n")
+	if r.fast != nil && r.fast.TimeToFillSketch != nil || r.slow != nil && r.slow.TimeToFillSketch != nil {
+		sb.WriteString(numfmt.Sprintf("Percentiles are estimated from a streaming sketch, within %.1f%% of the exact values.\n\n",
+			quantile.DefaultAccuracy*100))
+	}
 	sb.WriteString("| Percentile | Fast | Slow |\n")
 	sb.WriteString("|------------|------|------|\n")
 	if r.fast != nil && r.slow != nil {
+		fast, slow := r.fast.TimeToFill(), r.slow.TimeToFill()
 		for _, p := range []float64{0.25, 0.50, 0.75, 0.90, 0.99} {
-			fv := percentile(r.fast.TimeToFillDist, p)
-			sv := percentile(r.slow.TimeToFillDist, p)
-			sb.WriteString(fmt.Sprintf("| P%.0f | %.2f | %.2f |\n", p*100, fv, sv))
+			fv, sv := fast.Quantile(p), slow.Quantile(p)
+			sb.WriteString(numfmt.Sprintf("| P%.0f | %.2f | %.2f |\n", p*100, fv, sv))
 		}
 	}
 	sb.WriteString("\n")
+	if r.fast != nil && r.slow != nil {
+		sb.WriteString(r.renderTTFDecomposition())
+	}
+
+	if r.fast != nil && r.slow != nil && r.fast.ExpiredOrders+r.slow.ExpiredOrders > 0 {
+		sb.WriteString(r.renderResting())
+	}
+
+	if r.fast != nil && r.slow != nil {
+		sb.WriteString(r.renderLiquidity())
+		if r.config.Metrics.Computes(metrics.GroupToxicity) {
+			sb.WriteString(r.renderToxicity())
+		}
+	}
+
+	if len(r.Background) > 0 && r.fast != nil && r.slow != nil {
+		sb.WriteString(r.renderBackground())
+	}
+
+	if len(r.Sessions) > 0 {
+		sb.WriteString(r.renderSessions())
+	}
+
+	if len(r.config.Metrics.Exclude) > 0 {
+		sb.WriteString(r.renderExcluded())
+	}
+
+	// Inversions only happen away from price-time priority
+	if !r.config.Venue.IsZero() && r.config.Metrics.Computes(metrics.GroupPriority) && r.fast != nil && r.slow != nil {
+		sb.WriteString(r.renderPriority())
+	}
+
+	if r.config.Metrics.Computes(metrics.GroupRaces) && r.fast != nil && r.slow != nil {
+		sb.WriteString(r.renderRaces())
+	}
+
+	if len(r.config.Metrics.Custom) > 0 && r.fast != nil && r.slow != nil {
+		sb.WriteString(r.renderCustom())
+	}
+
+	if r.tiesMatter() {
+		sb.WriteString(r.renderTies())
+	}
+
+	if len(r.Capital) > 0 {
+		sb.WriteString(r.renderCapital())
+	}
+
+	if len(r.Hedge) > 0 {
+		sb.WriteString(r.renderHedge())
+	}
+
+	if r.Batching != nil {
+		sb.WriteString(r.renderBatching())
+	}
 
 	// Explanation section
 	sb.WriteString("## Fairness Analysis\n\n")
 	sb.WriteString(r.generateExplanation())
 
+	if r.Baseline != nil && r.fast != nil && r.slow != nil {
+		sb.WriteString("\n")
+		sb.WriteString(r.renderBaseline())
+	}
+
 	return sb.String()
 }
 
+// renderPriority tabulates priority inversions by trader pair: each cell
+// counts the times the row trader's resting order was jumped by a later
+// arrival from the column trader
+func (r *Report) renderPriority() string {
+	var sb strings.Builder
+	sb.WriteString("## Priority Inversions\n\n")
+	sb.WriteString("Times a resting order was passed over: an order at the same price that arrived later got filled while it still waited.\n\n")
+	sb.WriteString(numfmt.Sprintf("| Jumped \\ Jumper | %s | %s | background | Total |\n", r.fast.TraderID, r.slow.TraderID))
+	sb.WriteString("|-----------------|------|------|------------|-------|\n")
+	for _, m := range []*metrics.TraderMetrics{r.fast, r.slow} {
+		sb.WriteString(numfmt.Sprintf("| %s | %d | %d | %d | %d |\n", m.TraderID,
+			m.JumpedBy[r.fast.TraderID], m.JumpedBy[r.slow.TraderID], backgroundJumps(m.JumpedBy), m.PriorityInversions))
+	}
+	sb.WriteString(numfmt.Sprintf("\n**Queue jumps** (times each trader's order jumped another's, background included): %s %d, %s %d\n\n",
+		r.fast.TraderID, r.fast.QueueJumps, r.slow.TraderID, r.slow.QueueJumps))
+	return sb.String()
+}
+
+// backgroundJumps totals the inversions by background traders
+func backgroundJumps(by map[string]int) int {
+	var n int
+	for id, jumps := range by {
+		if domain.IsBackground(id) {
+			n += jumps
+		}
+	}
+	return n
+}
+
+// renderExcluded lists the exclusion windows and what the traders did
+// in them, fast / slow
+func (r *Report) renderExcluded() string {
+	var sb strings.Builder
+	sb.WriteString("## Exclusion Windows\n\n")
+	sb.WriteString("Orders decided in these windows, with their fills and cancels, are left out of every other section. ")
+	sb.WriteString("Mids, flow toxicity, position and PnL still cover the whole run.\n\n")
+	sb.WriteString("| Window | Start | End |\n")
+	sb.WriteString("|--------|-------|-----|\n")
+	for i, w := range r.config.Metrics.Exclude {
+		name := w.Name
+		if name == "" {
+			name = numfmt.Sprintf("window %d", i+1)
+		}
+		sb.WriteString(numfmt.Sprintf("| %s | %s | %s |\n", name, latency.Format(w.StartNs), latency.Format(w.EndNs)))
+	}
+	sb.WriteString("\n")
+	if r.Excluded == nil {
+		return sb.String()
+	}
+	fast, slow := r.Excluded[r.config.FastTrader.ID], r.Excluded[r.config.SlowTrader.ID]
+	if fast == nil {
+		fast = &metrics.TraderMetrics{}
+	}
+	if slow == nil {
+		slow = &metrics.TraderMetrics{}
+	}
+	sb.WriteString("Inside the windows, fast / slow:\n\n")
+	sb.WriteString("| Orders | Fills | Fill Rate (%) | Slippage (bps) | Avg TTF (ms) | Adv Select (bps) | Races Won |\n")
+	sb.WriteString("|--------|-------|---------------|----------------|--------------|------------------|-----------|\n")
+	sb.WriteString(numfmt.Sprintf("| %d / %d | %d / %d | %.2f / %.2f | %.2f / %.2f | %.2f / %.2f | %.2f / %.2f | %d / %d |\n\n",
+		fast.OrdersSent, slow.OrdersSent, fast.TotalFills, slow.TotalFills, fast.FillRate*100, slow.FillRate*100,
+		fast.SlippageBps, slow.SlippageBps, fast.AvgTimeToFillMs, slow.AvgTimeToFillMs,
+		fast.AdverseSelectionBps, slow.AdverseSelectionBps, fast.RacesWon, slow.RacesWon))
+	return sb.String()
+}
+
+// renderSessions tabulates each session's metrics, fast / slow
+func (r *Report) renderSessions() string {
+	var sb strings.Builder
+	sb.WriteString("## Sessions\n\n")
+	sb.WriteString("Each session's executions, fast / slow. Orders still resting at a close expire; Net PnL is the running total at the session's close.\n\n")
+	sb.WriteString("| Session | Start | End | Fills | Fill Rate (%) | Slippage (bps) | Avg TTF (ms) | Adv Select (bps) | Races Won | Expired | Net PnL |\n")
+	sb.WriteString("|---------|-------|-----|-------|---------------|----------------|--------------|------------------|-----------|---------|---------|\n")
+	for _, s := range r.Sessions {
+		fast, slow := s.Traders[r.config.FastTrader.ID], s.Traders[r.config.SlowTrader.ID]
+		if fast == nil {
+			fast = &metrics.TraderMetrics{}
+		}
+		if slow == nil {
+			slow = &metrics.TraderMetrics{}
+		}
+		sb.WriteString(numfmt.Sprintf("| %s | %s | %s | %d / %d | %.2f / %.2f | %.2f / %.2f | %.2f / %.2f | %.2f / %.2f | %d / %d | %d / %d | %.4f / %.4f |\n",
+			s.Name, latency.Format(s.StartNs), latency.Format(s.EndNs),
+			fast.TotalFills, slow.TotalFills, fast.FillRate*100, slow.FillRate*100,
+			fast.SlippageBps, slow.SlippageBps, fast.AvgTimeToFillMs, slow.AvgTimeToFillMs,
+			fast.AdverseSelectionBps, slow.AdverseSelectionBps, fast.RacesWon, slow.RacesWon,
+			fast.ExpiredOrders, slow.ExpiredOrders, fast.NetPnL, slow.NetPnL))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// requoteRTTMs is a trader's median re-quote round trip in milliseconds,
+// or 0 if it never re-quoted
+func requoteRTTMs(m *metrics.TraderMetrics) float64 {
+	if m.RequoteRTT == nil {
+		return 0
+	}
+	return float64(m.RequoteRTT.P50Ns) / 1e6
+}
+
 func (r *Report) addRow(sb *strings.Builder, label string, fast, slow float64, isFloat bool) {
 	delta := fast - slow
 	var fmtStr string
@@ -126,7 +449,77 @@ func (r *Report) addRow(sb *strings.Builder, label string, fast, slow float64, i
 	} else {
 		fmtStr = "| %s | %.0f | %.0f | %+.0f |\n"
 	}
-	sb.WriteString(fmt.Sprintf(fmtStr, label, fast, slow, delta))
+	sb.WriteString(numfmt.Sprintf(fmtStr, label, fast, slow, delta))
+}
+
+// renderTTFDecomposition splits each trader's time-to-fill at arrival,
+// so configured latency and queuing show up separately
+func (r *Report) renderTTFDecomposition() string {
+	if r.fast.TTFToArrival == nil && r.slow.TTFToArrival == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("### Time-to-Fill Decomposition\n\n")
+	sb.WriteString("Each fill's time-to-fill split at its order's arrival: decision to arrival is the trader's latency, ")
+	sb.WriteString("arrival to fill is time at the venue, mostly queuing.\n\n")
+	sb.WriteString("| Trader | Component | Fills | Mean | P50 | P99 | Max | Share |\n")
+	sb.WriteString("|--------|-----------|-------|------|-----|-----|-----|-------|\n")
+	for _, t := range []struct {
+		name string
+		m    *metrics.TraderMetrics
+	}{{"fast", r.fast}, {"slow", r.slow}} {
+		if t.m.TTFToArrival == nil {
+			continue
+		}
+		total := t.m.TTFToArrival.MeanNs + t.m.TTFAtVenue.MeanNs
+		for _, c := range []struct {
+			name string
+			s    *metrics.LatencyStats
+		}{{"decision → arrival", t.m.TTFToArrival}, {"arrival → fill", t.m.TTFAtVenue}} {
+			share := 0.0
+			if total > 0 {
+				share = 100 * c.s.MeanNs / total
+			}
+			sb.WriteString(numfmt.Sprintf("| %s | %s | %d | %s | %s | %s | %s | %.1f%% |\n",
+				t.name, c.name, c.s.Count, latency.Format(int64(c.s.MeanNs)), latency.Format(c.s.P50Ns),
+				latency.Format(c.s.P99Ns), latency.Format(c.s.MaxNs), share))
+		}
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// renderQueueDist tabulates queue position percentiles at placement and
+// at fill, which averages blur: a few deep joins can hide that one trader
+// is nearly always first in line
+func (r *Report) renderQueueDist() string {
+	if r.fast.QueuePosPlace == nil && r.slow.QueuePosPlace == nil && r.fast.QueuePosFill == nil && r.slow.QueuePosFill == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("**Queue position distribution** (1 is the front of the queue):\n\n")
+	sb.WriteString("| Trader | When | Count | P25 | P50 | P90 | At Front |\n")
+	sb.WriteString("|--------|------|-------|-----|-----|-----|----------|\n")
+	for _, t := range []struct {
+		name string
+		m    *metrics.TraderMetrics
+	}{{"fast", r.fast}, {"slow", r.slow}} {
+		for _, w := range []struct {
+			when string
+			d    *metrics.QueueDist
+		}{{"placement", t.m.QueuePosPlace}, {"fill", t.m.QueuePosFill}} {
+			if w.d == nil {
+				continue
+			}
+			sb.WriteString(numfmt.Sprintf("| %s | %s | %d | %d | %d | %d | %.1f%% |\n",
+				t.name, w.when, w.d.Count, w.d.P25, w.d.P50, w.d.P90, w.d.FrontPct))
+		}
+	}
+	sb.WriteString("\n")
+	if f, s := r.fast.QueueFrontPct(), r.slow.QueueFrontPct(); f != s {
+		sb.WriteString(numfmt.Sprintf("The fast trader joined at the front %.1f%% of the time, the slow trader %.1f%%.\n\n", f, s))
+	}
+	return sb.String()
 }
 
 func (r *Report) generateExplanation() string {
@@ -139,15 +532,15 @@ func (r *Report) generateExplanation() string {
 
 	// 1. Arrival order differences
 	sb.WriteString("### Message Arrival Ordering\n\n")
-	latencyDiff := r.config.SlowTrader.BaseLatencyMs - r.config.FastTrader.BaseLatencyMs
-	sb.WriteString(fmt.Sprintf("The fast trader's messages arrive **%d ms** earlier than the slow trader's. ",
+	latencyDiff := latency.Format(r.config.SlowTrader.BaseLatencyNs() - r.config.FastTrader.BaseLatencyNs())
+	sb.WriteString(numfmt.Sprintf("The fast trader's messages arrive **%s** earlier than the slow trader's. ",
 		latencyDiff))
 	sb.WriteString("This means when both traders react to the same signal, the fast trader's order is ")
 	sb.WriteString("processed first—securing better queue position at the intended price level.\n\n")
 
 	// Queue position data
 	if r.fast.AvgQueuePosPlace > 0 || r.slow.AvgQueuePosPlace > 0 {
-		sb.WriteString(fmt.Sprintf("**Queue position at placement**: fast = %.1f, slow = %.1f. ",
+		sb.WriteString(numfmt.Sprintf("**Queue position at placement**: fast = %.1f, slow = %.1f. ",
 			r.fast.AvgQueuePosPlace, r.slow.AvgQueuePosPlace))
 		if r.fast.AvgQueuePosPlace < r.slow.AvgQueuePosPlace {
 			sb.WriteString("The fast trader consistently joins the queue closer to the front, ")
@@ -157,54 +550,73 @@ func (r *Report) generateExplanation() string {
 		}
 	}
 	if r.fast.AvgQueuePosFill > 0 || r.slow.AvgQueuePosFill > 0 {
-		sb.WriteString(fmt.Sprintf("**Queue position at fill**: fast = %.1f, slow = %.1f. ",
+		sb.WriteString(numfmt.Sprintf("**Queue position at fill**: fast = %.1f, slow = %.1f. ",
 			r.fast.AvgQueuePosFill, r.slow.AvgQueuePosFill))
 		sb.WriteString("A lower fill queue position means the order was nearer the front when it executed.\n\n")
 	}
+	sb.WriteString(r.renderQueueDist())
+	if r.fast.AvgAheadQtyPlace > 0 || r.slow.AvgAheadQtyPlace > 0 {
+		sb.WriteString(numfmt.Sprintf("**Queue progression**: passive fills had %.1f (fast) and %.1f (slow) shares ahead when placed, and %.1f and %.1f still ahead when they filled; %.0f%% and %.0f%% of the queue ahead cleared first.\n\n",
+			r.fast.AvgAheadQtyPlace, r.slow.AvgAheadQtyPlace, r.fast.AvgAheadQtyFill, r.slow.AvgAheadQtyFill,
+			r.fast.QueueProgress*100, r.slow.QueueProgress*100))
+	}
 
 	// 2. Fill rate analysis
 	sb.WriteString("### Fill Rate Impact\n\n")
 	fillDelta := (r.fast.FillRate - r.slow.FillRate) * 100
 	if math.Abs(fillDelta) > 1.0 {
 		if fillDelta > 0 {
-			sb.WriteString(fmt.Sprintf("The fast trader achieved a fill rate **%.1f pp higher** than the slow trader. ", fillDelta))
+			sb.WriteString(numfmt.Sprintf("The fast trader achieved a fill rate **%.1f pp higher** than the slow trader. ", fillDelta))
 			sb.WriteString("This gap arises because:\n")
 			sb.WriteString("- The fast trader joins the queue earlier, gaining priority over the slow trader at the same price level.\n")
 			sb.WriteString("- By the time the slow trader's order arrives, available liquidity may already be consumed.\n")
 			sb.WriteString("- Cancel-and-replace operations take effect sooner for the fast trader, reducing stale-order exposure.\n\n")
 		} else {
-			sb.WriteString(fmt.Sprintf("The fast trader's fill rate is **%.1f pp lower** than the slow trader in this run. ", math.Abs(fillDelta)))
+			sb.WriteString(numfmt.Sprintf("The fast trader's fill rate is **%.1f pp lower** than the slow trader in this run. ", math.Abs(fillDelta)))
 			sb.WriteString("Despite faster arrival on average, fill-rate inversions can occur when:\n")
 			sb.WriteString("- The slow trader gets fewer but more selectively timed fills.\n")
 			sb.WriteString("- Cancel/replace timing changes which resting orders remain eligible during liquidity bursts.\n")
 			sb.WriteString("- Queue position advantages show up more strongly in time-to-fill or size-filled metrics than in filled-order ratio.\n\n")
 		}
 	} else {
-		sb.WriteString(fmt.Sprintf("Fill rates are similar (delta: %.1f pp), suggesting the scenario's depth ", fillDelta))
+		sb.WriteString(numfmt.Sprintf("Fill rates are similar (delta: %.1f pp), suggesting the scenario's depth ", fillDelta))
 		sb.WriteString("was sufficient to absorb both traders' orders most of the time.\n\n")
 	}
 
 	// Missed fills analysis
 	sb.WriteString("### Missed Fills\n\n")
-	sb.WriteString(fmt.Sprintf("Orders canceled without any fill — fast: **%d**, slow: **%d**.\n",
+	sb.WriteString(numfmt.Sprintf("Orders canceled without any fill — fast: **%d**, slow: **%d**.\n",
 		r.fast.CanceledBeforeFill, r.slow.CanceledBeforeFill))
 	if r.slow.CanceledBeforeFill > r.fast.CanceledBeforeFill {
 		diff := r.slow.CanceledBeforeFill - r.fast.CanceledBeforeFill
-		sb.WriteString(fmt.Sprintf("The slow trader missed **%d more fills** due to orders going stale ",
+		sb.WriteString(numfmt.Sprintf("The slow trader missed **%d more fills** due to orders going stale ",
 			diff))
-		sb.WriteString(fmt.Sprintf("before any contra-side liquidity arrived. The %d ms additional latency means cancels ",
+		sb.WriteString(numfmt.Sprintf("before any contra-side liquidity arrived. The %s additional latency means cancels ",
 			latencyDiff))
 		sb.WriteString("take longer to process, leaving stale orders exposed. ")
-		sb.WriteString(fmt.Sprintf("Out of %d cancels sent by the slow trader, %d targeted orders that never received a fill.\n\n",
+		sb.WriteString(numfmt.Sprintf("Out of %d cancels sent by the slow trader, %d targeted orders that never received a fill.\n\n",
 			r.slow.CancelsSent, r.slow.CanceledBeforeFill))
 	} else {
 		sb.WriteString("Both traders show similar missed-fill counts in this scenario.\n\n")
 	}
+	if r.fast.LateCancels+r.slow.LateCancels > 0 {
+		sb.WriteString(numfmt.Sprintf("Cancels that arrived after a fill had already taken the order — fast: **%d**, slow: **%d**.\n\n",
+			r.fast.LateCancels, r.slow.LateCancels))
+	}
+	if r.fast.PartialCancels+r.slow.PartialCancels > 0 {
+		sb.WriteString(numfmt.Sprintf("Quotes shrunk by partial cancels, keeping their place in the queue — fast: **%d** (%d qty), slow: **%d** (%d qty).\n\n",
+			r.fast.PartialCancels, r.fast.ReducedQty, r.slow.PartialCancels, r.slow.ReducedQty))
+	}
+	if r.fast.RefusedCancels+r.slow.RefusedCancels > 0 {
+		sb.WriteString(numfmt.Sprintf("Cancels refused for targeting another trader's order or the wrong side or price — fast: **%d**, slow: **%d**.\n\n",
+			r.fast.RefusedCancels, r.slow.RefusedCancels))
+	}
+	sb.WriteString(r.renderCancelRaces())
 
 	// 3. Slippage analysis
 	sb.WriteString("### Slippage Analysis\n\n")
 	slipDelta := r.fast.SlippageBps - r.slow.SlippageBps
-	sb.WriteString(fmt.Sprintf("Fast trader slippage: **%.2f bps** | Slow trader slippage: **%.2f bps** (delta: %+.2f bps)\n\n",
+	sb.WriteString(numfmt.Sprintf("Fast trader slippage: **%.2f bps** | Slow trader slippage: **%.2f bps** (delta: %+.2f bps)\n\n",
 		r.fast.SlippageBps, r.slow.SlippageBps, slipDelta))
 	if math.Abs(slipDelta) > 0.5 {
 		sb.WriteString("The slippage difference reflects the impact of queue position on execution price. ")
@@ -215,7 +627,7 @@ func (r *Report) generateExplanation() string {
 
 	// 4. Adverse selection
 	sb.WriteString("### Adverse Selection\n\n")
-	sb.WriteString(fmt.Sprintf("Fast trader: **%.2f bps** | Slow trader: **%.2f bps**\n\n",
+	sb.WriteString(numfmt.Sprintf("Fast trader: **%.2f bps** | Slow trader: **%.2f bps**\n\n",
 		r.fast.AdverseSelectionBps, r.slow.AdverseSelectionBps))
 	sb.WriteString("Adverse selection measures price movement against the trader's position after a fill. ")
 	if r.slow.AdverseSelectionBps < r.fast.AdverseSelectionBps {
@@ -229,15 +641,43 @@ func (r *Report) generateExplanation() string {
 
 	// 5. Time-to-fill
 	sb.WriteString("### Time-to-Fill\n\n")
-	if r.fast.AvgTimeToFillNs > 0 && r.slow.AvgTimeToFillNs > 0 {
-		ttfRatio := r.slow.AvgTimeToFillNs / r.fast.AvgTimeToFillNs
-		sb.WriteString(fmt.Sprintf("The slow trader's average time-to-fill is **%.1fx** that of the fast trader. ",
+	if r.fast.AvgTimeToFillMs > 0 && r.slow.AvgTimeToFillMs > 0 {
+		ttfRatio := r.slow.AvgTimeToFillMs / r.fast.AvgTimeToFillMs
+		sb.WriteString(numfmt.Sprintf("The slow trader's average time-to-fill is **%.1fx** that of the fast trader. ",
 			ttfRatio))
 		sb.WriteString("This reflects both the latency gap itself and the cascading effect: ")
 		sb.WriteString("later arrival → worse queue position → longer wait for fills.\n\n")
 	}
+	if f, s := r.fast, r.slow; f.TTFToArrival != nil && s.TTFToArrival != nil {
+		latencyGap := s.TTFToArrival.MeanNs - f.TTFToArrival.MeanNs
+		venueGap := s.TTFAtVenue.MeanNs - f.TTFAtVenue.MeanNs
+		sb.WriteString(numfmt.Sprintf("Of the slow − fast gap in average time-to-fill, %s is decision to arrival and %s arrival to fill. ",
+			latency.Format(int64(latencyGap)), latency.Format(int64(venueGap))))
+		if math.Abs(venueGap) > math.Abs(latencyGap) {
+			sb.WriteString("Time at the venue drives it more than latency does.\n\n")
+		} else {
+			sb.WriteString("Latency drives it more than time at the venue does.\n\n")
+		}
+	}
 
-	// 6. Scenario-specific notes
+	// 6. Information vs latency
+	if len(r.Attribution) > 0 {
+		sb.WriteString("### Information vs Latency\n\n")
+		sb.WriteString(numfmt.Sprintf("The traders differ in both latency and signal access (fast: %s, slow: %s). ",
+			r.config.FastTrader.SignalAccess(), r.config.SlowTrader.SignalAccess()))
+		sb.WriteString("Each fast − slow gap is split by re-running the scenario with the slow trader given ")
+		sb.WriteString("the fast trader's latency, its signal access, and both, averaging over the order ")
+		sb.WriteString("the two are removed in. The residual is the gap left with neither.\n\n")
+		sb.WriteString("| Metric | Gap | Latency | Information | Residual |\n")
+		sb.WriteString("|--------|-----|---------|-------------|----------|\n")
+		for _, g := range r.Attribution {
+			sb.WriteString(numfmt.Sprintf("| %s | %+.4f | %+.4f | %+.4f | %+.4f |\n",
+				g.Metric, g.Total, g.Latency, g.Information, g.Residual))
+		}
+		sb.WriteString("\n")
+	}
+
+	// 7. Scenario-specific notes
 	sb.WriteString("### Scenario Context: " + r.config.Name + "\n\n")
 	switch r.config.Name {
 	case "calm":
@@ -276,17 +716,19 @@ func (r *Report) renderPlots() string {
 
 	sb.WriteString("=== Time-to-Fill CDF (ASCII) ===\n\n")
 
-	if r.fast != nil && len(r.fast.TimeToFillDist) > 0 {
+	if r.fast != nil && r.fast.TimeToFill().Count() > 0 {
 		sb.WriteString("Fast Trader:\n")
-		sb.WriteString(asciiCDF(r.fast.TimeToFillDist))
+		sb.WriteString(asciiCDF(r.fast.TimeToFill()))
 		sb.WriteString("\n")
 	}
-	if r.slow != nil && len(r.slow.TimeToFillDist) > 0 {
+	if r.slow != nil && r.slow.TimeToFill().Count() > 0 {
 		sb.WriteString("Slow Trader:\n")
-		sb.WriteString(asciiCDF(r.slow.TimeToFillDist))
+		sb.WriteString(asciiCDF(r.slow.TimeToFill()))
 		sb.WriteString("\n")
 	}
 
+	sb.WriteString(r.renderActivity())
+
 	return sb.String()
 }
 
@@ -307,7 +749,7 @@ func asciiHistogram(values []float64, bins int) string {
 	}
 
 	if minV == maxV {
-		return fmt.Sprintf("  all values = %.4f\n", minV)
+		return numfmt.Sprintf("  all values = %.4f\n", minV)
 	}
 
 	binWidth := (maxV - minV) / float64(bins)
@@ -335,14 +777,14 @@ func asciiHistogram(values []float64, bins int) string {
 			barLen = c * barMax / maxCount
 		}
 		bar := strings.Repeat("█", barLen)
-		sb.WriteString(fmt.Sprintf("  %+8.4f to %+8.4f | %s (%d)\n", lo, hi, bar, c))
+		sb.WriteString(numfmt.Sprintf("  %+8.4f to %+8.4f | %s (%d)\n", lo, hi, bar, c))
 	}
 	return sb.String()
 }
 
 // asciiCDF draws a simple text CDF
-func asciiCDF(sorted []float64) string {
-	if len(sorted) == 0 {
+func asciiCDF(dist quantile.Estimator) string {
+	if dist.Count() == 0 {
 		return "  (no data)\n"
 	}
 
@@ -350,28 +792,14 @@ func asciiCDF(sorted []float64) string {
 	steps := 10
 	for i := 1; i <= steps; i++ {
 		p := float64(i) / float64(steps)
-		val := percentile(sorted, p)
+		val := dist.Quantile(p)
 		barLen := int(p * 40)
 		bar := strings.Repeat("▓", barLen)
-		sb.WriteString(fmt.Sprintf("  P%3.0f: %8.2f ms | %s\n", p*100, val, bar))
+		sb.WriteString(numfmt.Sprintf("  P%3.0f: %8.2f ms | %s\n", p*100, val, bar))
 	}
 	return sb.String()
 }
 
-func percentile(sorted []float64, p float64) float64 {
-	if len(sorted) == 0 {
-		return 0
-	}
-	idx := p * float64(len(sorted)-1)
-	lower := int(math.Floor(idx))
-	upper := int(math.Ceil(idx))
-	if lower == upper || upper >= len(sorted) {
-		return sorted[lower]
-	}
-	frac := idx - float64(lower)
-	return sorted[lower]*(1-frac) + sorted[upper]*frac
-}
-
 // PrintSummary writes a brief summary to stdout
 func PrintSummary(cfg *scenario.Config, m map[string]*metrics.TraderMetrics) {
 	fast := m[cfg.FastTrader.ID]
@@ -393,13 +821,25 @@ func PrintSummary(cfg *scenario.Config, m map[string]*metrics.TraderMetrics) {
 	printRow("Fill Rate (%)", fast.FillRate*100, slow.FillRate*100, "%12.2f")
 	printRow("Avg Exec Price", fast.AvgExecPrice, slow.AvgExecPrice, "%12.4f")
 	printRow("Slippage (bps)", fast.SlippageBps, slow.SlippageBps, "%12.2f")
-	printRow("Avg TTF (ms)", fast.AvgTimeToFillNs, slow.AvgTimeToFillNs, "%12.2f")
+	printRow("Avg TTF (ms)", fast.AvgTimeToFillMs, slow.AvgTimeToFillMs, "%12.2f")
 	printRow("Queue Pos Place", fast.AvgQueuePosPlace, slow.AvgQueuePosPlace, "%12.2f")
 	printRow("Queue Pos Fill", fast.AvgQueuePosFill, slow.AvgQueuePosFill, "%12.2f")
+	printRow("Queue Front (%)", fast.QueueFrontPct(), slow.QueueFrontPct(), "%12.2f")
 	printRow("Adv Select (bps)", fast.AdverseSelectionBps, slow.AdverseSelectionBps, "%12.2f")
 	printRow("Total Fills", float64(fast.TotalFills), float64(slow.TotalFills), "%12.0f")
 	printRow("Total Qty", float64(fast.TotalQtyFilled), float64(slow.TotalQtyFilled), "%12.0f")
+	printRow("Msgs per Trade", fast.MessagesPerTrade, slow.MessagesPerTrade, "%12.2f")
+	printRow("Passive Share (%)", fast.PassiveShare*100, slow.PassiveShare*100, "%12.2f")
+	printRow("Net Spread (bps)", fast.NetSpreadBps, slow.NetSpreadBps, "%12.2f")
+	if cfg.Metrics.Computes(metrics.GroupToxicity) {
+		printRow("Toxic Fill Ratio", fast.ToxicFillRatio, slow.ToxicFillRatio, "%12.2f")
+	}
+	printRow("Net PnL (marked)", fast.NetPnL, slow.NetPnL, "%12.4f")
+
+	for _, w := range latencyWarnings(cfg, m) {
+		fmt.Printf("  Warning: realized latency, %s\n", w)
+	}
 
-	mid := domain.PriceToFloat(cfg.Scenario.InitialMidPrice)
+	mid := cfg.Scale().ToFloat(cfg.Scenario.InitialMidPrice)
 	_ = mid
 }
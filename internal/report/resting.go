@@ -0,0 +1,48 @@
+package report
+
+import (
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+)
+
+// renderResting describes the orders the close cut off, which otherwise
+// only show up as unfilled orders in the fill rate
+func (r *Report) renderResting() string {
+	f, s := r.fast, r.slow
+	var sb strings.Builder
+	sb.WriteString("## Resting at Close\n\n")
+	sb.WriteString("Orders still on the book at the close, measured there: age is time since arrival, and distance is how far each sat behind the mid.\n\n")
+	sb.WriteString("| Metric | Fast | Slow | Delta |\n")
+	sb.WriteString("|--------|------|------|-------|\n")
+	r.addRow(&sb, "Orders Resting", float64(f.ExpiredOrders), float64(s.ExpiredOrders), false)
+	r.addRow(&sb, "Never Filled", float64(f.RestingUnfilled), float64(s.RestingUnfilled), false)
+	r.addRow(&sb, "Open Qty", float64(f.RestingQty), float64(s.RestingQty), false)
+	if f.RestingAge != nil || s.RestingAge != nil {
+		r.addRow(&sb, "Median Age (ms)", restingAgeMs(f.RestingAge, false), restingAgeMs(s.RestingAge, false), true)
+		r.addRow(&sb, "Max Age (ms)", restingAgeMs(f.RestingAge, true), restingAgeMs(s.RestingAge, true), true)
+	}
+	r.addRow(&sb, "Avg Behind Mid", f.RestingBehindMid, s.RestingBehindMid, true)
+	r.addRow(&sb, "Avg Behind Mid (bps)", f.RestingBehindMidBps, s.RestingBehindMidBps, true)
+	sb.WriteString("\n")
+	if r.config.Metrics.CancelAtClose {
+		sb.WriteString(numfmt.Sprintf("They count as canceled at the close: fill rates leave out the %d (fast) and %d (slow) that never filled.\n\n",
+			f.RestingUnfilled, s.RestingUnfilled))
+	} else {
+		sb.WriteString(numfmt.Sprintf("Fill rates count the %d (fast) and %d (slow) that never filled as orders that failed to; `--cancel-at-close` leaves them out.\n\n",
+			f.RestingUnfilled, s.RestingUnfilled))
+	}
+	return sb.String()
+}
+
+// restingAgeMs is the median or, if oldest, the greatest resting age in ms
+func restingAgeMs(a *metrics.LatencyStats, oldest bool) float64 {
+	if a == nil {
+		return 0
+	}
+	if oldest {
+		return float64(a.MaxNs) / 1e6
+	}
+	return float64(a.P50Ns) / 1e6
+}
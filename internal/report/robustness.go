@@ -0,0 +1,183 @@
+// Package report — scenario randomization studies
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/robustness"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scoring"
+)
+
+// RobustnessReport shows how far the fairness gaps move when the market
+// around them does, and which parameters move them most
+type RobustnessReport struct {
+	result *robustness.Result
+	outDir string
+
+	// Scores ranks the study's runs by composite score; nil omits them
+	Scores *scoring.Ranking
+}
+
+// NewRobustnessReport creates a report over a completed study
+func NewRobustnessReport(result *robustness.Result, outDir string) *RobustnessReport {
+	return &RobustnessReport{result: result, outDir: outDir}
+}
+
+// Generate writes robustness.md, robustness.json and robustness.csv, and
+// scores.json and scores.csv when scored
+func (rr *RobustnessReport) Generate() error {
+	if err := os.MkdirAll(rr.outDir, 0755); err != nil {
+		return fmt.Errorf("create output dir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(rr.outDir, "robustness.md"), []byte(rr.renderMarkdown()), 0644); err != nil {
+		return fmt.Errorf("write robustness report: %w", err)
+	}
+	data, _ := numfmt.MarshalIndent(rr.result, "", "  ")
+	if err := os.WriteFile(filepath.Join(rr.outDir, "robustness.json"), data, 0644); err != nil {
+		return fmt.Errorf("write robustness data: %w", err)
+	}
+	if rr.Scores != nil {
+		if err := writeScores(rr.outDir, rr.Scores); err != nil {
+			return err
+		}
+	}
+	return rr.writeCSV(filepath.Join(rr.outDir, "robustness.csv"))
+}
+
+// writeCSV writes one row per run: its seed, drawn parameters and gaps
+func (rr *RobustnessReport) writeCSV(path string) error {
+	res := rr.result
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	header := []string{"run", "seed"}
+	for _, b := range res.Bounds {
+		header = append(header, b.Param)
+	}
+	for _, d := range res.Distributions {
+		header = append(header, d.Key+"_gap")
+	}
+	cw.Write(append(header, "run_dir"))
+	for _, run := range res.Runs {
+		row := []string{strconv.Itoa(run.Index), strconv.FormatInt(run.Seed, 10)}
+		for _, b := range res.Bounds {
+			row = append(row, strconv.FormatFloat(run.Params[b.Param], 'f', -1, 64))
+		}
+		for _, d := range res.Distributions {
+			row = append(row, numfmt.Fixed(run.Gaps[d.Key], 4))
+		}
+		cw.Write(append(row, run.RunDir))
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("write robustness csv: %w", err)
+	}
+	return f.Close()
+}
+
+func (rr *RobustnessReport) renderMarkdown() string {
+	res := rr.result
+	var sb strings.Builder
+
+	sb.WriteString("# Scenario Robustness\n\n")
+	sb.WriteString("A conclusion drawn from one hand-picked market may not survive a slightly different one. ")
+	sb.WriteString("Here the scenario is rerun with its market parameters drawn at random within bounds, ")
+	sb.WriteString("and each gap is the fast trader's value minus the slow trader's.\n\n")
+	total := len(res.Runs) + len(res.Aborted)
+	seeds := numfmt.Sprintf("%d to %d", res.Seed, res.Seed+int64(total)-1)
+	if res.FixedSeed {
+		seeds = numfmt.Sprintf("%d on every run", res.Seed)
+	}
+	runs := strconv.Itoa(len(res.Runs))
+	if len(res.Aborted) > 0 {
+		runs = numfmt.Sprintf("%d of %d (%d aborted)", len(res.Runs), total, len(res.Aborted))
+	}
+	sb.WriteString(numfmt.Sprintf("**Scenario:** %s | **Runs:** %s | **Seeds:** %s\n\n", res.Scenario, runs, seeds))
+
+	sb.WriteString("## Varied Parameters\n\n")
+	sb.WriteString("| Parameter | Low | High | Meaning |\n")
+	sb.WriteString("|-----------|-----|------|---------|\n")
+	for _, b := range res.Bounds {
+		p, _ := robustness.LookupParam(b.Param)
+		sb.WriteString(numfmt.Sprintf("| %s | %s | %s | %s |\n", b.Param, p.Format(b.Lo), p.Format(b.Hi), p.Doc))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("## Gap Distribution (Fast − Slow)\n\n")
+	sb.WriteString("| Metric | Mean | Std | Min | P5 | Median | P95 | Max | Gap > 0 |\n")
+	sb.WriteString("|--------|------|-----|-----|----|--------|-----|-----|---------|\n")
+	for _, d := range res.Distributions {
+		sb.WriteString(numfmt.Sprintf("| %s | %+.2f | %.2f | %+.2f | %+.2f | %+.2f | %+.2f | %+.2f | %.0f%% |\n",
+			d.Metric, d.Mean, d.Std, d.Min, d.P5, d.P50, d.P95, d.Max, d.Positive*100))
+	}
+	sb.WriteString("\nGap > 0 is the share of runs where the fast trader's value is the higher. ")
+	sb.WriteString("A conclusion that holds in nearly every run is robust to these bounds; one near 50% is not.\n\n")
+
+	if len(res.Aborted) > 0 {
+		sb.WriteString("## Aborted Runs\n\n")
+		sb.WriteString("These draws left the market degenerate and were stopped by a scenario guard; ")
+		sb.WriteString("they are not in the distributions above.\n\n")
+		sb.WriteString("| Run | Seed |")
+		for _, b := range res.Bounds {
+			sb.WriteString(numfmt.Sprintf(" %s |", b.Param))
+		}
+		sb.WriteString(" Guard | Detail |\n|-----|------|")
+		sb.WriteString(strings.Repeat("--------|", len(res.Bounds)))
+		sb.WriteString("-------|--------|\n")
+		for _, a := range res.Aborted {
+			sb.WriteString(numfmt.Sprintf("| %d | %d |", a.Run, a.Seed))
+			for _, b := range res.Bounds {
+				p, _ := robustness.LookupParam(b.Param)
+				sb.WriteString(numfmt.Sprintf(" %s |", p.Format(a.Params[b.Param])))
+			}
+			sb.WriteString(numfmt.Sprintf(" %s | %s |\n", a.Guard, a.Detail))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("## What the Gaps Hinge On\n\n")
+	sb.WriteString(numfmt.Sprintf("Spearman rank correlation between each parameter and each gap. With %d runs, ", len(res.Runs)))
+	sb.WriteString(numfmt.Sprintf("|ρ| below %.2f cannot be told from chance; stronger correlations are marked *.\n\n", res.Noise()))
+	sb.WriteString("| Parameter |")
+	for _, d := range res.Distributions {
+		sb.WriteString(numfmt.Sprintf(" %s |", d.Metric))
+	}
+	sb.WriteString(" Mean \\|ρ\\| |\n|-----------|")
+	sb.WriteString(strings.Repeat("--------|", len(res.Distributions)))
+	sb.WriteString("----------|\n")
+	for _, in := range res.Influence {
+		sb.WriteString(numfmt.Sprintf("| %s |", in.Param))
+		for _, d := range res.Distributions {
+			mark := ""
+			if math.Abs(in.Rho[d.Key]) > res.Noise() {
+				mark = "*"
+			}
+			sb.WriteString(numfmt.Sprintf(" %+.2f%s |", in.Rho[d.Key], mark))
+		}
+		sb.WriteString(numfmt.Sprintf(" %.2f |\n", in.MeanAbs))
+	}
+	sb.WriteString("\n")
+
+	for _, d := range res.Distributions {
+		if p := res.MostSensitive(d.Key); p != "" {
+			sb.WriteString(numfmt.Sprintf("- **%s** is most sensitive to %s.\n", d.Metric, p))
+		} else {
+			sb.WriteString(numfmt.Sprintf("- **%s** shows no parameter beyond chance.\n", d.Metric))
+		}
+	}
+	sb.WriteString("\nEach run's parameters, gaps and directory are in `robustness.csv`.\n")
+	if rr.Scores != nil {
+		sb.WriteString("\n" + renderScores(rr.Scores))
+	}
+	return sb.String()
+}
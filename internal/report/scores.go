@@ -0,0 +1,122 @@
+// Package report — composite scores over a sweep
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scoring"
+)
+
+// maxScoreRows caps the ranking table; scores.csv has every run
+const maxScoreRows = 20
+
+// writeScores writes scores.json and scores.csv into dir
+func writeScores(dir string, r *scoring.Ranking) error {
+	data, _ := numfmt.MarshalIndent(r, "", "  ")
+	if err := os.WriteFile(filepath.Join(dir, "scores.json"), data, 0644); err != nil {
+		return fmt.Errorf("write scores: %w", err)
+	}
+	f, err := os.Create(filepath.Join(dir, "scores.csv"))
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	header := []string{"rank", "label", "score", "fairness", "quality", "pareto"}
+	for _, t := range scoring.Terms {
+		header = append(header, t.Key)
+	}
+	cw.Write(append(header, "run_dir"))
+	num := func(v float64) string { return numfmt.Fixed(v, 4) }
+	for _, s := range r.Scores {
+		row := []string{strconv.Itoa(s.Rank), s.Label, num(s.Score), num(s.Fairness), num(s.Quality), strconv.FormatBool(s.Pareto)}
+		for _, t := range scoring.Terms {
+			row = append(row, num(s.Raw[t.Key]))
+		}
+		cw.Write(append(row, s.RunDir))
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("write scores csv: %w", err)
+	}
+	return f.Close()
+}
+
+// renderScores ranks the sweep by composite score and charts its Pareto
+// front of fairness against market quality
+func renderScores(r *scoring.Ranking) string {
+	var sb strings.Builder
+	sb.WriteString("## Composite Score\n\n")
+	sb.WriteString("Each term is scaled across the sweep from 0 at its worst run to 1 at its best; fairness terms ")
+	sb.WriteString("are absolute fast-minus-slow gaps, where smaller is better. The score is the terms' weighted mean. Weights: ")
+	var weights []string
+	for _, t := range scoring.Terms {
+		if w := r.Weights[t.Key]; w > 0 {
+			weights = append(weights, numfmt.Sprintf("%s %g", t.Key, w))
+		}
+	}
+	sb.WriteString(strings.Join(weights, ", ") + ".\n\n")
+
+	sb.WriteString("| Rank | Run | Score | Fairness | Quality | Spread (bps) | Top Depth | Volume | Pareto |\n")
+	sb.WriteString("|------|-----|-------|----------|---------|--------------|-----------|--------|--------|\n")
+	for _, s := range r.Scores[:min(len(r.Scores), maxScoreRows)] {
+		front := ""
+		if s.Pareto {
+			front = "★"
+		}
+		sb.WriteString(numfmt.Sprintf("| %d | %s | %.3f | %.3f | %.3f | %.2f | %.1f | %d | %s |\n",
+			s.Rank, s.Label, s.Score, s.Fairness, s.Quality, s.Market.AvgSpreadBps, s.Market.AvgTopDepth, s.Market.Volume, front))
+	}
+	if len(r.Scores) > maxScoreRows {
+		sb.WriteString(numfmt.Sprintf("\n%d more runs are ranked in `scores.csv`.\n", len(r.Scores)-maxScoreRows))
+	}
+	sb.WriteString("\n")
+
+	front := r.Front()
+	sb.WriteString("## Pareto Front (Fairness vs Market Quality)\n\n")
+	sb.WriteString("No other run is both fairer and a better market than these; choosing among them is choosing ")
+	sb.WriteString("how much market quality to give up for fairness. Fairest first:\n\n")
+	for _, s := range front {
+		sb.WriteString(numfmt.Sprintf("- **%s**: fairness %.3f, quality %.3f\n", s.Label, s.Fairness, s.Quality))
+	}
+	sb.WriteString(numfmt.Sprintf("\n```\n%s```\n\n", asciiFront(r.Scores)))
+	return sb.String()
+}
+
+// asciiFront scatters the runs with fairness across and quality up, the
+// front marked ★
+func asciiFront(scores []scoring.Score) string {
+	const width, height = 40, 10
+	grid := make([][]rune, height+1)
+	for y := range grid {
+		grid[y] = []rune(strings.Repeat(" ", width+1))
+	}
+	for _, s := range scores {
+		x, y := int(s.Fairness*width+0.5), height-int(s.Quality*height+0.5)
+		if s.Pareto {
+			grid[y][x] = '★'
+		} else if grid[y][x] == ' ' {
+			grid[y][x] = '·'
+		}
+	}
+	var sb strings.Builder
+	for y, row := range grid {
+		label := "       "
+		switch y {
+		case 0:
+			label = "quality"
+		case height:
+			label = "      0"
+		}
+		sb.WriteString(label + " |" + strings.TrimRight(string(row), " ") + "\n")
+	}
+	sb.WriteString("        +" + strings.Repeat("-", width+1) + "\n")
+	sb.WriteString(numfmt.Sprintf("        0%*s\n", width+1, "fairness 1"))
+	return sb.String()
+}
@@ -0,0 +1,173 @@
+// Package report — latency sensitivity curves
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scoring"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sensitivity"
+)
+
+// SensitivityReport charts each fairness gap against the slow trader's
+// latency
+type SensitivityReport struct {
+	result *sensitivity.Result
+	outDir string
+
+	// Scores ranks the sweep's latencies by composite score; nil omits them
+	Scores *scoring.Ranking
+}
+
+// NewSensitivityReport creates a report over a completed sweep
+func NewSensitivityReport(result *sensitivity.Result, outDir string) *SensitivityReport {
+	return &SensitivityReport{result: result, outDir: outDir}
+}
+
+// Generate writes sensitivity.md, sensitivity.json and sensitivity.csv,
+// and scores.json and scores.csv when scored
+func (sr *SensitivityReport) Generate() error {
+	if err := os.MkdirAll(sr.outDir, 0755); err != nil {
+		return fmt.Errorf("create output dir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(sr.outDir, "sensitivity.md"), []byte(sr.renderMarkdown()), 0644); err != nil {
+		return fmt.Errorf("write sensitivity report: %w", err)
+	}
+	data, _ := numfmt.MarshalIndent(sr.result, "", "  ")
+	if err := os.WriteFile(filepath.Join(sr.outDir, "sensitivity.json"), data, 0644); err != nil {
+		return fmt.Errorf("write sensitivity data: %w", err)
+	}
+	if sr.Scores != nil {
+		if err := writeScores(sr.outDir, sr.Scores); err != nil {
+			return err
+		}
+	}
+	return sr.writeCSV(filepath.Join(sr.outDir, "sensitivity.csv"))
+}
+
+// writeCSV writes the curves in long form, one row per metric and latency
+func (sr *SensitivityReport) writeCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	cw.Write([]string{"metric", "slow_latency_ns", "fast", "slow", "gap", "knee"})
+	num := func(v float64) string { return numfmt.Fixed(v, 4) }
+	for _, c := range sr.result.Curves {
+		for _, p := range c.Points {
+			knee := "0"
+			if p.LatencyNs == c.KneeNs {
+				knee = "1"
+			}
+			cw.Write([]string{c.Metric, strconv.FormatInt(p.LatencyNs, 10), num(p.Fast), num(p.Slow), num(p.Gap), knee})
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("write sensitivity csv: %w", err)
+	}
+	return f.Close()
+}
+
+func (sr *SensitivityReport) renderMarkdown() string {
+	res := sr.result
+	var sb strings.Builder
+
+	sb.WriteString("# Latency Sensitivity\n\n")
+	sb.WriteString("How slow is too slow? The same market is replayed with only the slow trader's latency ")
+	sb.WriteString("changed: background flow, signals and jitter draws are identical at every point. ")
+	sb.WriteString("Each curve is the fast trader's value minus the slow trader's.\n\n")
+	sb.WriteString(numfmt.Sprintf("**Scenario:** %s | **Seed:** %d | **Fast trader:** %s\n\n",
+		res.Scenario, res.Seed, latency.Format(res.FastNs)))
+
+	if first := res.FirstKneeNs(); first > 0 {
+		sb.WriteString(numfmt.Sprintf("**First knee:** %s. Below it, extra latency costs the slow trader little; ", latency.Format(first)))
+		sb.WriteString("beyond it, at least one metric bends sharply.\n\n")
+	} else {
+		sb.WriteString("**No knee found:** every curve is flat or too short to bend.\n\n")
+	}
+
+	sb.WriteString("## Knees\n\n")
+	sb.WriteString("The knee is the point of maximum curvature (Kneedle), taking latency on a log scale.\n\n")
+	sb.WriteString("| Metric | Knee | Gap at Knee |\n")
+	sb.WriteString("|--------|------|-------------|\n")
+	for _, c := range res.Curves {
+		if c.KneeNs == 0 {
+			sb.WriteString(numfmt.Sprintf("| %s | none | - |\n", c.Metric))
+			continue
+		}
+		for _, p := range c.Points {
+			if p.LatencyNs == c.KneeNs {
+				sb.WriteString(numfmt.Sprintf("| %s | %s | %+.2f |\n", c.Metric, latency.Format(c.KneeNs), p.Gap))
+			}
+		}
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("## Gap by Slow-Trader Latency (Fast − Slow)\n\n")
+	sb.WriteString("| Slow Latency |")
+	for _, c := range res.Curves {
+		sb.WriteString(numfmt.Sprintf(" %s |", c.Metric))
+	}
+	sb.WriteString("\n|--------------|")
+	sb.WriteString(strings.Repeat("--------|", len(res.Curves)))
+	sb.WriteString("\n")
+	for i, lat := range res.Latencies {
+		sb.WriteString(numfmt.Sprintf("| %s |", latency.Format(lat)))
+		for _, c := range res.Curves {
+			mark := ""
+			if c.KneeNs == lat {
+				mark = " ◆"
+			}
+			sb.WriteString(numfmt.Sprintf(" %+.2f%s |", c.Points[i].Gap, mark))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n◆ marks each metric's knee.\n\n")
+
+	sb.WriteString("## Curves\n\n")
+	for _, c := range res.Curves {
+		sb.WriteString(numfmt.Sprintf("### %s\n\n```\n%s```\n\n", c.Metric, asciiCurve(c)))
+	}
+
+	if sr.Scores != nil {
+		sb.WriteString(renderScores(sr.Scores))
+	}
+
+	sb.WriteString("## Runs\n\n")
+	for i, dir := range res.RunDirs {
+		sb.WriteString(numfmt.Sprintf("- slow at %s: `%s`\n", latency.Format(res.Latencies[i]), dir))
+	}
+	return sb.String()
+}
+
+// asciiCurve draws one bar per latency, its length the gap's size
+func asciiCurve(c sensitivity.Curve) string {
+	const width = 40
+	var peak float64
+	for _, p := range c.Points {
+		peak = math.Max(peak, math.Abs(p.Gap))
+	}
+	var sb strings.Builder
+	for _, p := range c.Points {
+		n := 0
+		if peak > 0 {
+			n = int(math.Round(math.Abs(p.Gap) / peak * width))
+		}
+		mark := ""
+		if p.LatencyNs == c.KneeNs {
+			mark = "  ◆ knee"
+		}
+		sb.WriteString(numfmt.Sprintf("%10s | %-*s %+.2f%s\n", latency.Format(p.LatencyNs), width, strings.Repeat("█", n), p.Gap, mark))
+	}
+	return sb.String()
+}
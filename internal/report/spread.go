@@ -0,0 +1,43 @@
+package report
+
+import (
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+)
+
+// renderLiquidity compares how much of each trader's volume rested on the
+// book and what crossing or capturing the spread cost or earned it
+func (r *Report) renderLiquidity() string {
+	var sb strings.Builder
+	sb.WriteString("## Liquidity Mix and Spread Cost\n\n")
+	sb.WriteString("Passive fills rested on the book; aggressive fills took liquidity. Spread is each fill's distance from the mid just before the trade, times its qty: aggressive fills pay it, passive fills capture it.\n\n")
+	sb.WriteString("| Metric | Fast | Slow | Delta |\n")
+	sb.WriteString("|--------|------|------|-------|\n")
+	f, s := r.fast, r.slow
+	r.addRow(&sb, "Passive Fills", float64(f.PassiveFills), float64(s.PassiveFills), false)
+	r.addRow(&sb, "Aggressive Fills", float64(f.AggressiveFills), float64(s.AggressiveFills), false)
+	r.addRow(&sb, "Passive Qty", float64(f.PassiveQty), float64(s.PassiveQty), false)
+	r.addRow(&sb, "Aggressive Qty", float64(f.AggressiveQty), float64(s.AggressiveQty), false)
+	r.addRow(&sb, "Passive Share (%)", f.PassiveShare*100, s.PassiveShare*100, true)
+	r.addRow(&sb, "Spread Paid", f.SpreadPaid, s.SpreadPaid, true)
+	r.addRow(&sb, "Spread Captured", f.SpreadCaptured, s.SpreadCaptured, true)
+	r.addRow(&sb, "Net Spread", f.NetSpread, s.NetSpread, true)
+	r.addRow(&sb, "Net Spread (bps)", f.NetSpreadBps, s.NetSpreadBps, true)
+	sb.WriteString("\n")
+
+	if f.PassiveQty+f.AggressiveQty > 0 && s.PassiveQty+s.AggressiveQty > 0 {
+		more, less := f, s
+		if s.PassiveShare > f.PassiveShare {
+			more, less = s, f
+		}
+		if more.PassiveShare == less.PassiveShare {
+			sb.WriteString(numfmt.Sprintf("Both stayed passive on %.1f%% of their volume; **%s** netted %+.2f bps of spread and **%s** %+.2f bps.\n\n",
+				f.PassiveShare*100, f.TraderID, f.NetSpreadBps, s.TraderID, s.NetSpreadBps))
+			return sb.String()
+		}
+		sb.WriteString(numfmt.Sprintf("**%s** stayed passive on %.1f%% of its volume against %.1f%% for **%s**, and netted %+.2f bps of spread against %+.2f bps.\n\n",
+			more.TraderID, more.PassiveShare*100, less.PassiveShare*100, less.TraderID, more.NetSpreadBps, less.NetSpreadBps))
+	}
+	return sb.String()
+}
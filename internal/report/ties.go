@@ -0,0 +1,54 @@
+// Package report — same-timestamp ties the event loop broke by SeqNo
+package report
+
+import (
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/engine"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+)
+
+// tiesMatter reports whether a fast or slow trader's message ever tied
+// with another trader's
+func (r *Report) tiesMatter() bool {
+	for _, p := range r.Ties {
+		for _, id := range []string{p.A, p.B} {
+			if id == r.config.FastTrader.ID || id == r.config.SlowTrader.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renderTies tabulates, per trader pair, the messages that reached the
+// venue at the same timestamp and which way the loop's SeqNo order broke
+// them
+func (r *Report) renderTies() string {
+	var sb strings.Builder
+	sb.WriteString("## Same-Timestamp Ties\n\n")
+	sb.WriteString("Messages from two traders that reached the venue at the same nanosecond. The event loop breaks the tie by sequence number, i.e. by which message was scheduled first, not by modeled latency. ")
+	sb.WriteString("Contested ties competed for the same liquidity or queue spot: same side, same price. Same-decision ties were also decided at the same instant, so only dispatch order separated them.\n\n")
+	sb.WriteString("| Pair | Ties | First | Contested | Contested first | Same decision |\n")
+	sb.WriteString("|------|------|-------|-----------|-----------------|---------------|\n")
+	for _, p := range r.Ties {
+		sb.WriteString(numfmt.Sprintf("| %s / %s | %d | %d / %d | %d | %d / %d | %d |\n",
+			p.A, p.B, p.Ties, p.AFirst, p.BFirst, p.Contested, p.ContestedAFirst, p.Contested-p.ContestedAFirst, p.SameDecision))
+	}
+	sb.WriteString("\n")
+	if p := r.pairTies(r.config.FastTrader.ID, r.config.SlowTrader.ID); p != nil && p.Contested > 0 {
+		sb.WriteString(numfmt.Sprintf("**Warning:** %d contested %s / %s ties were decided by event order rather than latency. Latency gaps this small are below the simulator's resolution; add jitter or widen the gap before reading the race results.\n\n",
+			p.Contested, p.A, p.B))
+	}
+	return sb.String()
+}
+
+// pairTies returns the ties between traders a and b, or nil
+func (r *Report) pairTies(a, b string) *engine.TiePair {
+	for i, p := range r.Ties {
+		if (p.A == a && p.B == b) || (p.A == b && p.B == a) {
+			return &r.Ties[i]
+		}
+	}
+	return nil
+}
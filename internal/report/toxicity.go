@@ -0,0 +1,36 @@
+package report
+
+import (
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/numfmt"
+)
+
+// renderToxicity compares how toxic the order flow was when each trader
+// was filled, and whether the slow trader's fills bunched up in the run's
+// most toxic intervals
+func (r *Report) renderToxicity() string {
+	var sb strings.Builder
+	sb.WriteString("## Flow Toxicity\n\n")
+	sb.WriteString("Trades are cut into equal-volume buckets; a bucket's toxicity is the rolling mean of |buy - sell| / volume over a window of recent buckets, so one-sided flow scores near 1. Toxic intervals are the run's most toxic third of volume.\n\n")
+	sb.WriteString("| Metric | Fast | Slow | Delta |\n")
+	sb.WriteString("|--------|------|------|-------|\n")
+	f, s := r.fast, r.slow
+	r.addRow(&sb, "Avg Fill Toxicity", f.AvgFillToxicity, s.AvgFillToxicity, true)
+	r.addRow(&sb, "Toxic Fill Share (%)", f.ToxicFillShare*100, s.ToxicFillShare*100, true)
+	r.addRow(&sb, "Toxic Fill Ratio", f.ToxicFillRatio, s.ToxicFillRatio, true)
+	sb.WriteString("\n")
+
+	if s.ToxicFillRatio > 0 {
+		verdict := "about as often as the market"
+		switch {
+		case s.ToxicFillRatio > 1.1:
+			verdict = "disproportionately often"
+		case s.ToxicFillRatio < 0.9:
+			verdict = "less often than the market"
+		}
+		sb.WriteString(numfmt.Sprintf("**%s** took %.1f%% of its volume in toxic intervals against %.1f%% for the market (%.2fx), so it was filled %s when flow was toxic.\n\n",
+			s.TraderID, s.ToxicFillShare*100, s.ToxicFillShare/s.ToxicFillRatio*100, s.ToxicFillRatio, verdict))
+	}
+	return sb.String()
+}
@@ -0,0 +1,196 @@
+// Package repro packages a run into a self-contained bundle and re-runs it
+// on another machine to check that the simulation reproduces bit for bit
+package repro
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/compat"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+// BundleVersion is the bundle file format version
+const BundleVersion = 1
+
+// Platform identifies where a bundle was produced or verified
+type Platform struct {
+	GOOS      string `json:"goos"`
+	GOARCH    string `json:"goarch"`
+	GoVersion string `json:"go_version"`
+}
+
+// CurrentPlatform describes the running binary
+func CurrentPlatform() Platform {
+	return Platform{GOOS: runtime.GOOS, GOARCH: runtime.GOARCH, GoVersion: runtime.Version()}
+}
+
+func (p Platform) String() string {
+	return fmt.Sprintf("%s/%s %s", p.GOOS, p.GOARCH, p.GoVersion)
+}
+
+// Bundle is everything needed to re-run a simulation and judge the result
+// Log holds the gzip-compressed event log so a mismatch can be located
+// down to the first divergent event; it is optional
+type Bundle struct {
+	Version    int              `json:"version"`
+	Platform   Platform         `json:"platform"`
+	Config     *scenario.Config `json:"config"`
+	LogHash    string           `json:"log_hash"`
+	LogRecords int              `json:"log_records"`
+	TradeCount int              `json:"trade_count"`
+	Log        []byte           `json:"log,omitempty"`
+}
+
+// FromRunDir builds a bundle from a completed run's config.json and
+// events.jsonl
+func FromRunDir(runDir string, includeLog bool) (*Bundle, error) {
+	cfg, err := compat.ReadConfig(runDir)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	logData, err := os.ReadFile(filepath.Join(runDir, "events.jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("read event log: %w", err)
+	}
+	b := &Bundle{
+		Version:  BundleVersion,
+		Platform: CurrentPlatform(),
+		Config:   cfg,
+		LogHash:  hashBytes(logData),
+	}
+	b.LogRecords, b.TradeCount = countLog(logData)
+	if includeLog {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(logData)
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("compress event log: %w", err)
+		}
+		b.Log = buf.Bytes()
+	}
+	return b, nil
+}
+
+// countLog returns the number of records in a log and how many of them
+// are trades
+func countLog(logData []byte) (records, trades int) {
+	for _, line := range bytes.Split(logData, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		records++
+		if bytes.Contains(line, []byte(`"type":"TRADE_EXECUTED"`)) {
+			trades++
+		}
+	}
+	return
+}
+
+// Read loads a bundle file
+func Read(path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read bundle: %w", err)
+	}
+	b := &Bundle{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("decode bundle: %w", err)
+	}
+	if b.Version != BundleVersion {
+		return nil, fmt.Errorf("bundle version %d not supported (want %d)", b.Version, BundleVersion)
+	}
+	if b.Config == nil {
+		return nil, fmt.Errorf("bundle has no config")
+	}
+	return b, nil
+}
+
+// Write saves a bundle file
+func (b *Bundle) Write(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal bundle: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// openLog returns a reader over the bundled event log
+func (b *Bundle) openLog() (io.ReadCloser, error) {
+	if len(b.Log) == 0 {
+		return nil, fmt.Errorf("bundle has no event log")
+	}
+	return gzip.NewReader(bytes.NewReader(b.Log))
+}
+
+// Result is the outcome of re-running a bundle
+type Result struct {
+	Match       bool
+	Bundled     Platform
+	Current     Platform
+	WantHash    string
+	GotHash     string
+	WantRecords int
+	GotRecords  int
+	WantTrades  int
+	GotTrades   int
+
+	// Diagnosis locates the first differing event, when the bundle has a
+	// log. It stays nil on a hash mismatch if the logs are identical, which
+	// means the bundle's recorded hash is wrong rather than the run
+	Diagnosis *Diagnosis
+}
+
+// Verify re-runs the bundled config under workDir and compares the result
+func Verify(b *Bundle, workDir string) (*Result, error) {
+	runner, err := sim.NewRunner(b.Config, workDir)
+	if err != nil {
+		return nil, fmt.Errorf("initialize runner: %w", err)
+	}
+	run, err := runner.Run()
+	if err != nil {
+		return nil, fmt.Errorf("run bundled config: %w", err)
+	}
+
+	logData, err := os.ReadFile(run.LogPath)
+	if err != nil {
+		return nil, fmt.Errorf("read replayed log: %w", err)
+	}
+	res := &Result{
+		Match:       run.LogHash == b.LogHash,
+		Bundled:     b.Platform,
+		Current:     CurrentPlatform(),
+		WantHash:    b.LogHash,
+		GotHash:     run.LogHash,
+		WantRecords: b.LogRecords,
+		WantTrades:  b.TradeCount,
+	}
+	res.GotRecords, res.GotTrades = countLog(logData)
+	if res.Match || len(b.Log) == 0 {
+		return res, nil
+	}
+
+	want, err := b.openLog()
+	if err != nil {
+		return nil, fmt.Errorf("open bundled log: %w", err)
+	}
+	defer want.Close()
+	res.Diagnosis, err = Diagnose(want, bytes.NewReader(logData), DefaultBookDepth)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func hashBytes(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
@@ -0,0 +1,174 @@
+package repro
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+func bundleRun(t *testing.T, includeLog bool) *Bundle {
+	t.Helper()
+	cfg := scenario.GetConfig("thin", 11)
+	cfg.Duration = latency.MsToNs(1_000)
+	runner, err := sim.NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	run, err := runner.Run()
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	b, err := FromRunDir(filepath.Dir(run.LogPath), includeLog)
+	if err != nil {
+		t.Fatalf("bundle: %v", err)
+	}
+	if b.LogHash != run.LogHash {
+		t.Fatalf("bundle hash %s, run hash %s", b.LogHash, run.LogHash)
+	}
+	if b.TradeCount != run.TradeCount {
+		t.Fatalf("bundle trades %d, run trades %d", b.TradeCount, run.TradeCount)
+	}
+	return b
+}
+
+func TestVerifyReproducesBundle(t *testing.T) {
+	b := bundleRun(t, true)
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	if err := b.Write(path); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	loaded, err := Read(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	res, err := Verify(loaded, t.TempDir())
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !res.Match || res.Diagnosis != nil {
+		t.Fatalf("bundle did not reproduce: %+v", res)
+	}
+	if res.GotRecords != b.LogRecords || res.GotTrades != b.TradeCount {
+		t.Errorf("counts %d/%d, want %d/%d", res.GotRecords, res.GotTrades, b.LogRecords, b.TradeCount)
+	}
+}
+
+func TestVerifyLocatesDivergence(t *testing.T) {
+	b := bundleRun(t, true)
+
+	// Tamper with the bundled log the way a platform difference would show up
+	zr, err := gzip.NewReader(bytes.NewReader(b.Log))
+	if err != nil {
+		t.Fatalf("gunzip: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	lines := strings.Split(string(data), "\n")
+	target := -1
+	for i, line := range lines {
+		if strings.Contains(line, `"type":"TRADE_EXECUTED"`) {
+			target = i
+			break
+		}
+	}
+	if target < 0 {
+		t.Fatal("no trade in log")
+	}
+	lines[target] = strings.Replace(lines[target], `"qty":`, `"qty":9`, 1)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(strings.Join(lines, "\n")))
+	zw.Close()
+	b.Log = buf.Bytes()
+	b.LogHash = "tampered"
+
+	res, err := Verify(b, t.TempDir())
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if res.Match {
+		t.Fatal("tampered bundle matched")
+	}
+	d := res.Diagnosis
+	if d == nil {
+		t.Fatal("no divergence reported")
+	}
+	if d.Line != target+1 {
+		t.Errorf("divergence at line %d, want %d", d.Line, target+1)
+	}
+	if !reflect.DeepEqual(d.Fields, []string{"trade.qty"}) {
+		t.Errorf("fields = %v, want [trade.qty]", d.Fields)
+	}
+}
+
+func TestVerifyWithoutLog(t *testing.T) {
+	b := bundleRun(t, false)
+	if len(b.Log) != 0 {
+		t.Fatal("log bundled despite includeLog=false")
+	}
+	b.LogHash = "stale"
+	res, err := Verify(b, t.TempDir())
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if res.Match || res.Diagnosis != nil {
+		t.Fatalf("want hash mismatch without divergence, got %+v", res)
+	}
+}
+
+func TestFirstDivergence(t *testing.T) {
+	tests := []struct {
+		name      string
+		want, got string
+		line      int
+		fields    []string
+	}{
+		{"identical", "{\"a\":1}\n{\"b\":2}\n", "{\"a\":1}\n{\"b\":2}\n", 0, nil},
+		{"field", "{\"a\":1}\n{\"b\":{\"c\":2,\"d\":3}}\n", "{\"a\":1}\n{\"b\":{\"c\":2,\"d\":4}}\n", 2, []string{"b.d"}},
+		{"added field", "{\"a\":1}\n", "{\"a\":1,\"z\":0}\n", 1, []string{"z"}},
+		{"truncated", "{\"a\":1}\n{\"b\":2}\n", "{\"a\":1}\n", 2, nil},
+		{"not json", "x\n", "y\n", 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := FirstDivergence(strings.NewReader(tt.want), strings.NewReader(tt.got))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.line == 0 {
+				if d != nil {
+					t.Fatalf("unexpected divergence %+v", d)
+				}
+				return
+			}
+			if d == nil || d.Line != tt.line {
+				t.Fatalf("divergence = %+v, want line %d", d, tt.line)
+			}
+			if !reflect.DeepEqual(d.Fields, tt.fields) {
+				t.Errorf("fields = %v, want %v", d.Fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestReadRejectsOtherVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	b := &Bundle{Version: BundleVersion + 1, Config: scenario.GetConfig("calm", 1)}
+	if err := b.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(path); err == nil {
+		t.Fatal("expected version error")
+	}
+}
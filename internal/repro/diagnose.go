@@ -0,0 +1,206 @@
+package repro
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/orderbook"
+)
+
+// DefaultBookDepth is how many levels per side a diagnosis captures
+const DefaultBookDepth = 5
+
+// Divergence locates the first line where two event logs differ
+type Divergence struct {
+	Line int    // 1-based
+	Want string // empty if the expected log ended first
+	Got  string // empty if the replayed log ended first
+	// Fields lists the dotted JSON paths whose values differ, when both
+	// lines decode as JSON objects
+	Fields []string
+}
+
+// Diagnosis explains a divergence: which loop event produced the first
+// differing line and what the book looked like before that event ran
+type Diagnosis struct {
+	*Divergence
+
+	// SeqNo, Timestamp and Type describe the divergent record, taken from
+	// the expected log unless it ended first
+	SeqNo     uint64
+	Timestamp int64
+	Type      string
+
+	// Trades, BBO updates and cancel acks are logged with seq 0 after the
+	// loop event that caused them. For such a record Cause is that event's
+	// line; otherwise it equals Line
+	CauseLine int
+	Cause     string
+
+	// Bids and Asks are the top levels of the book just before the
+	// causing event, rebuilt from the orders accepted before it
+	Bids []LevelSnapshot
+	Asks []LevelSnapshot
+}
+
+// LevelSnapshot is one price level of a rebuilt book
+type LevelSnapshot struct {
+	Price  int64
+	Qty    int64
+	Orders []OrderSnapshot // FIFO order
+}
+
+// OrderSnapshot is a resting order in a rebuilt book
+type OrderSnapshot struct {
+	ID        uint64
+	TraderID  string
+	Remaining int64
+}
+
+// FirstDivergence compares two JSON-lines logs and returns the first
+// differing line, or nil if they are identical
+func FirstDivergence(want, got io.Reader) (*Divergence, error) {
+	d, err := Diagnose(want, got, 0)
+	if d == nil || err != nil {
+		return nil, err
+	}
+	return d.Divergence, nil
+}
+
+// Diagnose compares two event logs record by record and returns a
+// diagnosis of the first difference, or nil if they are identical. depth
+// limits the book levels captured per side
+func Diagnose(want, got io.Reader, depth int) (*Diagnosis, error) {
+	ws := bufio.NewScanner(want)
+	gs := bufio.NewScanner(got)
+	ws.Buffer(make([]byte, 256*1024), 1024*1024)
+	gs.Buffer(make([]byte, 256*1024), 1024*1024)
+
+	// The last accepted order is applied to the book only once the next
+	// loop event shows that all of its side effects matched
+	book := orderbook.New()
+	var pending *domain.Event
+	cause, causeLine := "", 0
+
+	for line := 1; ; line++ {
+		wok, gok := ws.Scan(), gs.Scan()
+		if err := ws.Err(); err != nil {
+			return nil, fmt.Errorf("read expected log: %w", err)
+		}
+		if err := gs.Err(); err != nil {
+			return nil, fmt.Errorf("read replayed log: %w", err)
+		}
+		if !wok && !gok {
+			return nil, nil
+		}
+		var w, g string
+		if wok {
+			w = ws.Text()
+		}
+		if gok {
+			g = gs.Text()
+		}
+
+		rec := w
+		if !wok {
+			rec = g
+		}
+		e, _ := eventlog.DecodeLine([]byte(rec))
+		if e != nil && e.SeqNo != 0 {
+			applyPending(book, pending)
+			pending = nil
+			cause, causeLine = rec, line
+		}
+
+		if wok && gok && w == g {
+			if e != nil && e.Type == domain.EventOrderAccepted && e.Order() != nil {
+				pending = e
+			}
+			continue
+		}
+
+		d := &Diagnosis{
+			Divergence: &Divergence{Line: line, Want: w, Got: g, Fields: diffFields(w, g)},
+			CauseLine:  causeLine,
+			Cause:      cause,
+		}
+		if e != nil {
+			d.SeqNo, d.Timestamp, d.Type = e.SeqNo, e.Timestamp, e.Type.String()
+		}
+		d.Bids = snapshotSide(book, domain.Buy, depth)
+		d.Asks = snapshotSide(book, domain.Sell, depth)
+		return d, nil
+	}
+}
+
+// applyPending replays an accepted order against the rebuilt book. The
+// logged order carries its post-match remaining qty, so it is reset first
+func applyPending(book *orderbook.Book, e *domain.Event) {
+	if e == nil {
+		return
+	}
+	o := *e.Order()
+	o.RemainingQty = o.Qty
+	book.ProcessOrder(&o, e.Timestamp)
+}
+
+func snapshotSide(book *orderbook.Book, side domain.Side, depth int) []LevelSnapshot {
+	var levels []LevelSnapshot
+	for i, pl := range book.Levels(side) {
+		if i >= depth {
+			break
+		}
+		ls := LevelSnapshot{Price: pl.Price, Qty: pl.TotalQty()}
+		for _, o := range pl.Orders() {
+			ls.Orders = append(ls.Orders, OrderSnapshot{ID: o.ID, TraderID: o.TraderID, Remaining: o.RemainingQty})
+		}
+		levels = append(levels, ls)
+	}
+	return levels
+}
+
+// diffFields returns the sorted dotted paths at which two JSON objects
+// differ, or nil if either side is not a JSON object
+func diffFields(want, got string) []string {
+	var w, g map[string]interface{}
+	if json.Unmarshal([]byte(want), &w) != nil || json.Unmarshal([]byte(got), &g) != nil {
+		return nil
+	}
+	wf, gf := map[string]string{}, map[string]string{}
+	flatten("", w, wf)
+	flatten("", g, gf)
+
+	var fields []string
+	for k, v := range wf {
+		if gv, ok := gf[k]; !ok || gv != v {
+			fields = append(fields, k)
+		}
+	}
+	for k := range gf {
+		if _, ok := wf[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func flatten(prefix string, v interface{}, out map[string]string) {
+	if obj, ok := v.(map[string]interface{}); ok {
+		for k, child := range obj {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			flatten(key, child, out)
+		}
+		return
+	}
+	data, _ := json.Marshal(v)
+	out[prefix] = string(data)
+}
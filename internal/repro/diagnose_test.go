@@ -0,0 +1,103 @@
+package repro
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+func logLines(t *testing.T, events ...*domain.Event) []string {
+	t.Helper()
+	lines := make([]string, len(events))
+	for i, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines[i] = string(data)
+	}
+	return lines
+}
+
+// tinyLog has two resting bids, then a market sell that partially fills
+// the first and a cancel of the second
+func tinyLog(t *testing.T, tradeQty int64) []string {
+	bid1 := &domain.Order{ID: 1, TraderID: "fast", Side: domain.Buy, Type: domain.LimitOrder, Price: 1_000_000, Qty: 5, RemainingQty: 2}
+	bid2 := &domain.Order{ID: 2, TraderID: "slow", Side: domain.Buy, Type: domain.LimitOrder, Price: 999_900, Qty: 4, RemainingQty: 4}
+	sell := &domain.Order{ID: 3, TraderID: "background", Side: domain.Sell, Type: domain.MarketOrder, Qty: 3}
+	cancel := &domain.Order{ID: 4, TraderID: "slow", Side: domain.Sell, Type: domain.CancelOrder, CancelID: 2}
+	return logLines(t,
+		&domain.Event{SeqNo: 1, Timestamp: 0, Type: domain.EventSimStart},
+		&domain.Event{SeqNo: 2, Timestamp: 10, Type: domain.EventOrderAccepted, Payload: bid1},
+		&domain.Event{SeqNo: 3, Timestamp: 20, Type: domain.EventOrderAccepted, Payload: bid2},
+		&domain.Event{SeqNo: 4, Timestamp: 30, Type: domain.EventOrderAccepted, Payload: sell},
+		&domain.Event{Timestamp: 30, Type: domain.EventTradeExecuted, Payload: &domain.Trade{ID: 1, BuyOrderID: 1, SellOrderID: 3, BuyTrader: "fast", SellTrader: "background", Price: 1_000_000, Qty: tradeQty, Timestamp: 30}},
+		&domain.Event{SeqNo: 5, Timestamp: 40, Type: domain.EventOrderAccepted, Payload: cancel},
+	)
+}
+
+func diagnoseLines(t *testing.T, want, got []string) *Diagnosis {
+	t.Helper()
+	d, err := Diagnose(strings.NewReader(strings.Join(want, "\n")), strings.NewReader(strings.Join(got, "\n")), DefaultBookDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestDiagnoseSideEffectShowsBookBeforeCause(t *testing.T) {
+	want, got := tinyLog(t, 3), tinyLog(t, 2)
+	d := diagnoseLines(t, want, got)
+	if d == nil {
+		t.Fatal("no divergence")
+	}
+	if d.Line != 5 || d.Type != "TRADE_EXECUTED" || d.Timestamp != 30 {
+		t.Errorf("divergence at line %d %s t=%d, want line 5 TRADE_EXECUTED t=30", d.Line, d.Type, d.Timestamp)
+	}
+	if d.CauseLine != 4 || d.Cause != want[3] {
+		t.Errorf("cause line %d %q, want line 4", d.CauseLine, d.Cause)
+	}
+	// The market sell has not been applied: both bids rest untouched
+	if len(d.Asks) != 0 || len(d.Bids) != 2 {
+		t.Fatalf("book = %+v / %+v, want two bid levels", d.Bids, d.Asks)
+	}
+	if d.Bids[0].Price != 1_000_000 || d.Bids[0].Qty != 5 || d.Bids[0].Orders[0] != (OrderSnapshot{ID: 1, TraderID: "fast", Remaining: 5}) {
+		t.Errorf("best bid = %+v", d.Bids[0])
+	}
+}
+
+func TestDiagnoseLoopEventShowsBookBeforeIt(t *testing.T) {
+	want := tinyLog(t, 3)
+	got := append([]string(nil), want...)
+	got[5] = strings.Replace(got[5], `"timestamp":40`, `"timestamp":41`, 1)
+
+	d := diagnoseLines(t, want, got)
+	if d == nil || d.Line != 6 || d.CauseLine != 6 || d.SeqNo != 5 {
+		t.Fatalf("diagnosis = %+v, want line 6 seq 5 as its own cause", d)
+	}
+	// The market sell has been applied, the cancel has not
+	if len(d.Bids) != 2 || d.Bids[0].Qty != 2 || d.Bids[1].Qty != 4 {
+		t.Errorf("bids = %+v, want 2 @ 100.00 and 4 @ 99.99", d.Bids)
+	}
+
+	shallow, err := Diagnose(strings.NewReader(strings.Join(want, "\n")), strings.NewReader(strings.Join(got, "\n")), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shallow.Bids) != 1 {
+		t.Errorf("depth 1 captured %d bid levels", len(shallow.Bids))
+	}
+}
+
+func TestDiagnoseTruncatedReplay(t *testing.T) {
+	want := tinyLog(t, 3)
+	d := diagnoseLines(t, want, want[:4])
+	if d == nil || d.Line != 5 || d.Got != "" || d.Want != want[4] {
+		t.Fatalf("diagnosis = %+v, want replay ending at line 5", d)
+	}
+	if d := diagnoseLines(t, want, want); d != nil {
+		t.Errorf("identical logs diagnosed: %+v", d)
+	}
+}
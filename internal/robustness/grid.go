@@ -0,0 +1,142 @@
+package robustness
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sensitivity"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+// A grid crosses a latency sweep with one market parameter: every slow
+// trader latency is run at every value of the parameter, with the seed
+// fixed, so each cell differs from its neighbours only along one axis.
+// Each metric's gap then covers the plane, and where it changes sign or
+// steepens shows which combinations the conclusion depends on
+
+// GridCell is one run of a grid. A run stopped by a guard has no gaps
+type GridCell struct {
+	Value     float64            `json:"value"`
+	LatencyNs int64              `json:"slow_latency_ns"`
+	Gaps      map[string]float64 `json:"gaps,omitempty"` // by metric key, fast - slow
+	Guard     string             `json:"guard,omitempty"`
+	RunDir    string             `json:"run_dir"`
+}
+
+// GridResult is a completed grid. Cells[i][j] is Values[i] at Latencies[j]
+type GridResult struct {
+	Scenario  string       `json:"scenario"`
+	Seed      int64        `json:"seed"`
+	FastNs    int64        `json:"fast_latency_ns"`
+	Param     string       `json:"param"`
+	Values    []float64    `json:"values"`
+	Latencies []int64      `json:"latencies_ns"`
+	Cells     [][]GridCell `json:"cells"`
+}
+
+// GridValues returns steps values evenly spaced over b, inclusive,
+// rounded as the parameter's draws are
+func GridValues(b Bound, steps int) ([]float64, error) {
+	if err := Validate([]Bound{b}); err != nil {
+		return nil, err
+	}
+	if steps < 2 {
+		return nil, fmt.Errorf("need at least 2 steps, got %d", steps)
+	}
+	p, _ := LookupParam(b.Param)
+	var out []float64
+	for i := 0; i < steps; i++ {
+		v := b.Lo + float64(i)/float64(steps-1)*(b.Hi-b.Lo)
+		switch {
+		case p.Integer:
+			v = math.Round(v)
+		case p.Duration:
+			v = math.Round(v/1_000) * 1_000
+		}
+		if len(out) > 0 && v <= out[len(out)-1] {
+			continue
+		}
+		out = append(out, v)
+	}
+	if len(out) < 2 {
+		return nil, fmt.Errorf("%s: bounds %s to %s give a single value", b.Param, p.Format(b.Lo), p.Format(b.Hi))
+	}
+	return out, nil
+}
+
+// Grid runs cfg at every pair of parameter value and slow-trader latency.
+// Runs write under dir, one directory per value holding one per latency
+func Grid(cfg *scenario.Config, param string, values []float64, latencies []int64, dir string, progress func(value float64, latencyNs int64)) (*GridResult, error) {
+	p, ok := LookupParam(param)
+	if !ok {
+		return nil, fmt.Errorf("unknown parameter %q", param)
+	}
+	res := &GridResult{
+		Scenario: cfg.Name, Seed: cfg.Seed, FastNs: cfg.FastTrader.BaseLatencyNs(),
+		Param: param, Values: values, Latencies: latencies,
+	}
+	for _, v := range values {
+		row := make([]GridCell, 0, len(latencies))
+		for _, lat := range latencies {
+			if progress != nil {
+				progress(v, lat)
+			}
+			c := *cfg
+			p.set(&c.Scenario, v)
+			if c.Scenario.MaxOrderSize < c.Scenario.MinOrderSize {
+				return nil, fmt.Errorf("max_size %d below the scenario's minimum order size %d",
+					c.Scenario.MaxOrderSize, c.Scenario.MinOrderSize)
+			}
+			if err := c.SlowTrader.SetLatency(time.Duration(lat), time.Duration(cfg.SlowTrader.JitterNs())); err != nil {
+				return nil, err
+			}
+			cell := GridCell{Value: v, LatencyNs: lat}
+			runDir := filepath.Join(dir, param+"_"+dirValue(p, v), "slow_"+time.Duration(lat).String())
+			runner, err := sim.NewRunner(&c, runDir)
+			if err != nil {
+				return nil, err
+			}
+			result, err := runner.Run()
+			var guard *sim.GuardError
+			if errors.As(err, &guard) {
+				cell.Guard, cell.RunDir = guard.Guard, guard.OutputDir
+				row = append(row, cell)
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s %s, slow at %s: %w", param, p.Format(v), time.Duration(lat), err)
+			}
+			m, err := metrics.ComputeFromLog(result.LogPath, c.Scale(), c.Metrics)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s, slow at %s metrics: %w", param, p.Format(v), time.Duration(lat), err)
+			}
+			cell.RunDir = result.OutputDir
+			cell.Gaps = make(map[string]float64, len(sensitivity.Metrics))
+			fast, slow := m[c.FastTrader.ID], m[c.SlowTrader.ID]
+			for _, mt := range sensitivity.Metrics {
+				if fast != nil && slow != nil {
+					cell.Gaps[mt.Key] = mt.Get(fast) - mt.Get(slow)
+				} else {
+					cell.Gaps[mt.Key] = 0
+				}
+			}
+			row = append(row, cell)
+		}
+		res.Cells = append(res.Cells, row)
+	}
+	return res, nil
+}
+
+// dirValue renders a parameter value for a directory name
+func dirValue(p Param, v float64) string {
+	if p.Duration {
+		return time.Duration(v).String()
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,430 @@
+// Package robustness reruns a scenario many times with its market
+// parameters drawn at random within bounds, to see whether a fairness
+// conclusion survives away from the one hand-picked point. Each metric's
+// fast-minus-slow gap gets a distribution over the runs, and each
+// parameter a rank correlation with each gap: the parameters with the
+// strongest correlations are the ones the conclusions hinge on
+package robustness
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/quantile"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sensitivity"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+// Param is a scenario parameter a study can vary
+type Param struct {
+	Name     string
+	Doc      string
+	Integer  bool // drawn values are rounded
+	Duration bool // bounds are durations, drawn values rounded to the microsecond
+	Min, Max float64
+	get      func(*scenario.ScenarioParams) float64
+	set      func(*scenario.ScenarioParams, float64)
+}
+
+// Params are the parameters a study can vary
+var Params = []Param{
+	{
+		Name: "depth", Doc: "orders per level in the initial book", Integer: true, Min: 1, Max: math.Inf(1),
+		get: func(p *scenario.ScenarioParams) float64 { return float64(p.DepthPerLevel) },
+		set: func(p *scenario.ScenarioParams, v float64) { p.DepthPerLevel = int64(v) },
+	},
+	{
+		Name: "order_interval", Doc: "mean time between background orders, the inverse of the order rate", Duration: true, Min: 1_000, Max: math.Inf(1),
+		get: func(p *scenario.ScenarioParams) float64 { return float64(p.OrderIntervalNs) },
+		set: func(p *scenario.ScenarioParams, v float64) { p.OrderIntervalNs = int64(v) },
+	},
+	{
+		Name: "market_ratio", Doc: "fraction of background orders that are market orders", Min: 0, Max: 1,
+		get: func(p *scenario.ScenarioParams) float64 { return p.MarketOrderRatio },
+		set: func(p *scenario.ScenarioParams, v float64) { p.MarketOrderRatio = v },
+	},
+	{
+		Name: "cancel_rate", Doc: "chance of a background cancel per arrival", Min: 0, Max: 1,
+		get: func(p *scenario.ScenarioParams) float64 { return p.CancelRate },
+		set: func(p *scenario.ScenarioParams, v float64) { p.CancelRate = v },
+	},
+	{
+		Name: "max_size", Doc: "largest background order", Integer: true, Min: 1, Max: math.Inf(1),
+		get: func(p *scenario.ScenarioParams) float64 { return float64(p.MaxOrderSize) },
+		set: func(p *scenario.ScenarioParams, v float64) { p.MaxOrderSize = int64(v) },
+	},
+}
+
+// DefaultVaried are the parameters varied when none are given
+var DefaultVaried = []string{"depth", "order_interval", "market_ratio"}
+
+// LookupParam returns the named parameter
+func LookupParam(name string) (Param, bool) {
+	for _, p := range Params {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Param{}, false
+}
+
+// Format renders a value of the parameter
+func (p Param) Format(v float64) string {
+	switch {
+	case p.Duration:
+		return latency.Format(int64(v))
+	case p.Integer:
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'f', 3, 64)
+}
+
+// Bound is the range a parameter is drawn from, uniformly
+type Bound struct {
+	Param string  `json:"param"`
+	Lo    float64 `json:"lo"`
+	Hi    float64 `json:"hi"`
+}
+
+// ParseBounds parses "name=lo:hi" ranges separated by commas, e.g.
+// "depth=5:30,order_interval=2ms:20ms,market_ratio=0.05:0.4"
+func ParseBounds(spec string) ([]Bound, error) {
+	var bounds []Bound
+	for _, part := range strings.Split(spec, ",") {
+		name, rng, ok := strings.Cut(strings.TrimSpace(part), "=")
+		lo, hi, ok2 := strings.Cut(rng, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("bound %q: want name=lo:hi", part)
+		}
+		p, ok := LookupParam(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown parameter %q", name)
+		}
+		b := Bound{Param: name}
+		for _, f := range []struct {
+			s string
+			v *float64
+		}{{lo, &b.Lo}, {hi, &b.Hi}} {
+			if p.Duration {
+				ns, err := scenario.ParseDuration(f.s)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+				*f.v = float64(ns)
+				continue
+			}
+			v, err := strconv.ParseFloat(f.s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %q is not a number", name, f.s)
+			}
+			*f.v = v
+		}
+		bounds = append(bounds, b)
+	}
+	return bounds, nil
+}
+
+// DefaultBounds varies each of names from half to one and a half times
+// its value in cfg, within the parameter's limits
+func DefaultBounds(cfg *scenario.Config, names []string) []Bound {
+	var bounds []Bound
+	for _, name := range names {
+		p, ok := LookupParam(name)
+		if !ok {
+			continue
+		}
+		v := p.get(&cfg.Scenario)
+		b := Bound{Param: name, Lo: math.Max(p.Min, v*0.5), Hi: math.Min(p.Max, v*1.5)}
+		if p.Integer {
+			b.Lo, b.Hi = math.Round(b.Lo), math.Round(b.Hi)
+		}
+		bounds = append(bounds, b)
+	}
+	return bounds
+}
+
+// Validate checks the bounds name distinct parameters and stay within
+// their limits
+func Validate(bounds []Bound) error {
+	if len(bounds) == 0 {
+		return fmt.Errorf("no parameters to vary")
+	}
+	seen := make(map[string]bool)
+	for _, b := range bounds {
+		p, ok := LookupParam(b.Param)
+		if !ok {
+			return fmt.Errorf("unknown parameter %q", b.Param)
+		}
+		if seen[b.Param] {
+			return fmt.Errorf("%s bounded twice", b.Param)
+		}
+		seen[b.Param] = true
+		if b.Lo > b.Hi {
+			return fmt.Errorf("%s: lower bound %s above upper bound %s", b.Param, p.Format(b.Lo), p.Format(b.Hi))
+		}
+		if b.Lo < p.Min || b.Hi > p.Max {
+			return fmt.Errorf("%s: bounds %s to %s outside %s to %s", b.Param,
+				p.Format(b.Lo), p.Format(b.Hi), p.Format(p.Min), p.Format(p.Max))
+		}
+	}
+	return nil
+}
+
+// Spec is a study: how many runs, and what to vary
+type Spec struct {
+	Bounds []Bound
+	Runs   int
+	// FixedSeed reuses cfg's seed on every run, so only the parameters
+	// vary. Otherwise run i uses seed cfg.Seed + i, and the spread also
+	// covers the luck of the draw
+	FixedSeed bool
+}
+
+// Run is one randomized run
+type Run struct {
+	Index  int                `json:"run"`
+	Seed   int64              `json:"seed"`
+	Params map[string]float64 `json:"params"`
+	Gaps   map[string]float64 `json:"gaps"` // by metric key, fast - slow
+	RunDir string             `json:"run_dir"`
+}
+
+// Abort is a run a scenario guard stopped: its parameters left the
+// market degenerate, so it has no gaps to contribute
+type Abort struct {
+	Run    int                `json:"run"`
+	Seed   int64              `json:"seed"`
+	Params map[string]float64 `json:"params"`
+	Guard  string             `json:"guard"`
+	Detail string             `json:"detail"`
+	RunDir string             `json:"run_dir"`
+}
+
+// Distribution summarizes one metric's gap over the runs
+type Distribution struct {
+	Metric   string  `json:"metric"`
+	Key      string  `json:"key"`
+	Mean     float64 `json:"mean"`
+	Std      float64 `json:"std"`
+	Min      float64 `json:"min"`
+	P5       float64 `json:"p5"`
+	P50      float64 `json:"p50"`
+	P95      float64 `json:"p95"`
+	Max      float64 `json:"max"`
+	Positive float64 `json:"positive_share"` // share of runs with a gap above zero
+}
+
+// Influence is one parameter's Spearman rank correlation with each
+// metric's gap, and the mean of their absolute values
+type Influence struct {
+	Param   string             `json:"param"`
+	Rho     map[string]float64 `json:"rho"` // by metric key
+	MeanAbs float64            `json:"mean_abs_rho"`
+}
+
+// Result is a completed study
+type Result struct {
+	Scenario      string         `json:"scenario"`
+	Seed          int64          `json:"seed"`
+	FixedSeed     bool           `json:"fixed_seed"`
+	Bounds        []Bound        `json:"bounds"`
+	Runs          []Run          `json:"runs"`
+	Aborted       []Abort        `json:"aborted,omitempty"`
+	Distributions []Distribution `json:"distributions"`
+	Influence     []Influence    `json:"influence"` // most influential first
+}
+
+// Noise is the absolute rank correlation below which a parameter's effect
+// cannot be told from chance, at about 95% confidence
+func (r *Result) Noise() float64 {
+	if len(r.Runs) < 2 {
+		return 1
+	}
+	return 2 / math.Sqrt(float64(len(r.Runs)-1))
+}
+
+// MostSensitive returns, for the metric key, the parameter with the
+// strongest rank correlation, or "" if none beats Noise
+func (r *Result) MostSensitive(key string) string {
+	best, bestRho := "", r.Noise()
+	for _, in := range r.Influence {
+		if rho := math.Abs(in.Rho[key]); rho > bestRho {
+			best, bestRho = in.Param, rho
+		}
+	}
+	return best
+}
+
+// Study runs cfg spec.Runs times, each with the bounded parameters drawn
+// at random. Draws come from their own RNG seeded with cfg.Seed, so a
+// study reruns identically. Runs write under dir, one directory per run.
+// A run stopped by one of cfg's guards is recorded as aborted and left
+// out of the distributions; at least 2 runs must complete
+func Study(cfg *scenario.Config, spec Spec, dir string, progress func(run int)) (*Result, error) {
+	if err := Validate(spec.Bounds); err != nil {
+		return nil, err
+	}
+	if spec.Runs < 2 {
+		return nil, fmt.Errorf("need at least 2 runs, got %d", spec.Runs)
+	}
+	res := &Result{Scenario: cfg.Name, Seed: cfg.Seed, FixedSeed: spec.FixedSeed, Bounds: spec.Bounds}
+	rng := rand.New(rand.NewSource(cfg.Seed))
+
+	for i := 0; i < spec.Runs; i++ {
+		if progress != nil {
+			progress(i)
+		}
+		c := *cfg
+		if !spec.FixedSeed {
+			c.Seed = cfg.Seed + int64(i)
+		}
+		run := Run{Index: i, Seed: c.Seed, Params: make(map[string]float64), Gaps: make(map[string]float64)}
+		for _, b := range spec.Bounds {
+			p, _ := LookupParam(b.Param)
+			v := b.Lo + rng.Float64()*(b.Hi-b.Lo)
+			switch {
+			case p.Integer:
+				v = math.Round(v)
+			case p.Duration:
+				v = math.Round(v/1_000) * 1_000
+			}
+			p.set(&c.Scenario, v)
+			run.Params[b.Param] = v
+		}
+		if c.Scenario.MaxOrderSize < c.Scenario.MinOrderSize {
+			return nil, fmt.Errorf("run %d: max_size %d below the scenario's minimum order size %d",
+				i, c.Scenario.MaxOrderSize, c.Scenario.MinOrderSize)
+		}
+
+		runner, err := sim.NewRunner(&c, filepath.Join(dir, fmt.Sprintf("run_%03d", i)))
+		if err != nil {
+			return nil, err
+		}
+		result, err := runner.Run()
+		var guard *sim.GuardError
+		if errors.As(err, &guard) {
+			res.Aborted = append(res.Aborted, Abort{
+				Run: i, Seed: c.Seed, Params: run.Params,
+				Guard: guard.Guard, Detail: guard.Detail, RunDir: guard.OutputDir,
+			})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("run %d: %w", i, err)
+		}
+		m, err := metrics.ComputeFromLog(result.LogPath, c.Scale(), c.Metrics)
+		if err != nil {
+			return nil, fmt.Errorf("run %d metrics: %w", i, err)
+		}
+		run.RunDir = result.OutputDir
+		fast, slow := m[c.FastTrader.ID], m[c.SlowTrader.ID]
+		for _, mt := range sensitivity.Metrics {
+			if fast != nil && slow != nil {
+				run.Gaps[mt.Key] = mt.Get(fast) - mt.Get(slow)
+			} else {
+				run.Gaps[mt.Key] = 0
+			}
+		}
+		res.Runs = append(res.Runs, run)
+	}
+	if len(res.Runs) < 2 {
+		return nil, fmt.Errorf("only %d of %d runs completed; %d aborted by guards", len(res.Runs), spec.Runs, len(res.Aborted))
+	}
+
+	for _, mt := range sensitivity.Metrics {
+		gaps := make([]float64, len(res.Runs))
+		for i, run := range res.Runs {
+			gaps[i] = run.Gaps[mt.Key]
+		}
+		res.Distributions = append(res.Distributions, distribution(mt, gaps))
+	}
+	for _, b := range spec.Bounds {
+		in := Influence{Param: b.Param, Rho: make(map[string]float64)}
+		xs := make([]float64, len(res.Runs))
+		for i, run := range res.Runs {
+			xs[i] = run.Params[b.Param]
+		}
+		for _, d := range res.Distributions {
+			ys := make([]float64, len(res.Runs))
+			for i, run := range res.Runs {
+				ys[i] = run.Gaps[d.Key]
+			}
+			in.Rho[d.Key] = Spearman(xs, ys)
+			in.MeanAbs += math.Abs(in.Rho[d.Key]) / float64(len(res.Distributions))
+		}
+		res.Influence = append(res.Influence, in)
+	}
+	sort.SliceStable(res.Influence, func(a, b int) bool { return res.Influence[a].MeanAbs > res.Influence[b].MeanAbs })
+	return res, nil
+}
+
+func distribution(mt sensitivity.Metric, gaps []float64) Distribution {
+	d := Distribution{Metric: mt.Name, Key: mt.Key}
+	sorted := append([]float64(nil), gaps...)
+	sort.Float64s(sorted)
+	for _, g := range gaps {
+		d.Mean += g / float64(len(gaps))
+		if g > 0 {
+			d.Positive += 1 / float64(len(gaps))
+		}
+	}
+	for _, g := range gaps {
+		d.Std += (g - d.Mean) * (g - d.Mean)
+	}
+	d.Std = math.Sqrt(d.Std / float64(len(gaps)-1))
+	d.Min, d.Max = sorted[0], sorted[len(sorted)-1]
+	q := quantile.Sorted(sorted)
+	d.P5, d.P50, d.P95 = q.Quantile(0.05), q.Quantile(0.5), q.Quantile(0.95)
+	return d
+}
+
+// Spearman is the rank correlation of xs and ys, with tied values given
+// their average rank. It is 0 if either side is constant
+func Spearman(xs, ys []float64) float64 {
+	rx, ry := ranks(xs), ranks(ys)
+	n := float64(len(xs))
+	var mx, my float64
+	for i := range rx {
+		mx += rx[i] / n
+		my += ry[i] / n
+	}
+	var cov, vx, vy float64
+	for i := range rx {
+		cov += (rx[i] - mx) * (ry[i] - my)
+		vx += (rx[i] - mx) * (rx[i] - mx)
+		vy += (ry[i] - my) * (ry[i] - my)
+	}
+	if vx == 0 || vy == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+func ranks(vs []float64) []float64 {
+	idx := make([]int, len(vs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return vs[idx[a]] < vs[idx[b]] })
+	r := make([]float64, len(vs))
+	for i := 0; i < len(idx); {
+		j := i
+		for j+1 < len(idx) && vs[idx[j+1]] == vs[idx[i]] {
+			j++
+		}
+		for k := i; k <= j; k++ {
+			r[idx[k]] = float64(i+j)/2 + 1
+		}
+		i = j + 1
+	}
+	return r
+}
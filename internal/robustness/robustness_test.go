@@ -0,0 +1,202 @@
+package robustness
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+func TestParseBounds(t *testing.T) {
+	got, err := ParseBounds("depth=5:30, order_interval=2ms:20ms,market_ratio=0.05:0.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Bound{
+		{"depth", 5, 30},
+		{"order_interval", float64(latency.MsToNs(2)), float64(latency.MsToNs(20))},
+		{"market_ratio", 0.05, 0.4},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("bound %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	for _, bad := range []string{"depth=5", "depth", "volume=1:2", "depth=a:3", "order_interval=2ms:soon"} {
+		if _, err := ParseBounds(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+	for _, bad := range [][]Bound{
+		nil,
+		{{"depth", 30, 5}},
+		{{"market_ratio", 0.5, 1.5}},
+		{{"depth", 0, 5}},
+		{{"depth", 1, 5}, {"depth", 2, 6}},
+	} {
+		if err := Validate(bad); err == nil {
+			t.Errorf("%v passed validation", bad)
+		}
+	}
+}
+
+func TestDefaultBounds(t *testing.T) {
+	cfg := scenario.GetConfig("thin", 1)
+	b := DefaultBounds(cfg, DefaultVaried)
+	if err := Validate(b); err != nil {
+		t.Fatal(err)
+	}
+	if b[0] != (Bound{"depth", 3, 8}) {
+		t.Errorf("depth bound = %+v", b[0])
+	}
+	if b[2].Lo != 0.125 || b[2].Hi != 0.375 {
+		t.Errorf("market_ratio bound = %+v", b[2])
+	}
+}
+
+func TestSpearman(t *testing.T) {
+	xs := []float64{1, 2, 3, 4, 5}
+	if r := Spearman(xs, []float64{1, 4, 9, 16, 25}); math.Abs(r-1) > 1e-12 {
+		t.Errorf("monotone rise rho = %v", r)
+	}
+	if r := Spearman(xs, []float64{5, 3, 2, 1, 0}); math.Abs(r+1) > 1e-12 {
+		t.Errorf("monotone fall rho = %v", r)
+	}
+	if r := Spearman(xs, []float64{7, 7, 7, 7, 7}); r != 0 {
+		t.Errorf("constant rho = %v", r)
+	}
+	// Ties share their average rank
+	if r := Spearman([]float64{1, 1, 2}, []float64{1, 1, 2}); math.Abs(r-1) > 1e-12 {
+		t.Errorf("tied rho = %v", r)
+	}
+}
+
+// TestStudyReruns checks a study draws within bounds, varies the seed per
+// run unless fixed, and gives the same result twice
+func TestStudyReruns(t *testing.T) {
+	cfg := scenario.GetConfig("thin", 5)
+	cfg.Duration = latency.MsToNs(500)
+	spec := Spec{Bounds: []Bound{{"depth", 2, 8}, {"market_ratio", 0.1, 0.4}}, Runs: 4}
+	a, err := Study(cfg, spec, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Study(cfg, spec, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, run := range a.Runs {
+		if run.Seed != 5+int64(i) {
+			t.Errorf("run %d seed %d", i, run.Seed)
+		}
+		d := run.Params["depth"]
+		if d < 2 || d > 8 || d != math.Round(d) {
+			t.Errorf("run %d depth %v", i, d)
+		}
+		for k, g := range run.Gaps {
+			if b.Runs[i].Gaps[k] != g || b.Runs[i].Params["depth"] != d {
+				t.Errorf("run %d differs on rerun", i)
+			}
+		}
+	}
+	if len(a.Distributions) == 0 || len(a.Influence) != 2 {
+		t.Fatalf("%d distributions, %d influences", len(a.Distributions), len(a.Influence))
+	}
+	if cfg.Scenario.DepthPerLevel != 5 {
+		t.Error("study modified the caller's config")
+	}
+
+	spec.FixedSeed = true
+	fixed, err := Study(cfg, spec, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, run := range fixed.Runs {
+		if run.Seed != 5 {
+			t.Errorf("fixed seed run used seed %d", run.Seed)
+		}
+	}
+}
+
+// TestStudySkipsAborted checks runs a guard stops are listed apart and
+// left out of the distributions, and that a study needs 2 that complete
+func TestStudySkipsAborted(t *testing.T) {
+	cfg := scenario.GetConfig("thin", 42)
+	cfg.Duration = latency.MsToNs(3_000)
+	cfg.Guards.OneSidedNs = latency.MsToNs(1_000)
+	spec := Spec{Bounds: []Bound{{"depth", 1, 10}, {"market_ratio", 0.2, 1}}, Runs: 6}
+	res, err := Study(cfg, spec, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Aborted) == 0 || len(res.Runs)+len(res.Aborted) != spec.Runs {
+		t.Fatalf("%d runs, %d aborted", len(res.Runs), len(res.Aborted))
+	}
+	for _, a := range res.Aborted {
+		if a.Guard != "one_sided_ns" || a.Seed != 42+int64(a.Run) || a.RunDir == "" {
+			t.Errorf("aborted %+v", a)
+		}
+		for _, run := range res.Runs {
+			if run.Index == a.Run {
+				t.Errorf("run %d both aborted and completed", a.Run)
+			}
+		}
+	}
+
+	cfg.Guards = scenario.Guards{NoTradeNs: 1}
+	if _, err := Study(cfg, spec, t.TempDir(), nil); err == nil {
+		t.Error("study with every run aborted succeeded")
+	}
+}
+
+// TestGrid checks grid values are spaced and rounded like draws, and that
+// a grid runs every pair and matches the same runs made one at a time
+func TestGrid(t *testing.T) {
+	values, err := GridValues(Bound{"depth", 2, 5}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2, 2.75, 3.5, 4.25, 5 round to 2, 3, 4, 4, 5; the repeat is dropped
+	if len(values) != 4 || values[0] != 2 || values[1] != 3 || values[2] != 4 || values[3] != 5 {
+		t.Errorf("depth values = %v", values)
+	}
+	if _, err := GridValues(Bound{"depth", 3, 3}, 4); err == nil {
+		t.Error("single-valued bound accepted")
+	}
+	if _, err := GridValues(Bound{"market_ratio", 0.1, 0.4}, 1); err == nil {
+		t.Error("one step accepted")
+	}
+
+	cfg := scenario.GetConfig("thin", 5)
+	cfg.Duration = latency.MsToNs(500)
+	latencies := []int64{latency.MsToNs(1), latency.MsToNs(50)}
+	g, err := Grid(cfg, "depth", []float64{2, 6}, latencies, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Cells) != 2 || len(g.Cells[0]) != 2 || len(g.Cells[1]) != 2 {
+		t.Fatalf("grid shape %d rows", len(g.Cells))
+	}
+	if cfg.Scenario.DepthPerLevel != 5 {
+		t.Error("grid modified the caller's config")
+	}
+	one := *cfg
+	one.Scenario.DepthPerLevel = 6
+	if err := one.SlowTrader.SetLatency(50*time.Millisecond, time.Duration(cfg.SlowTrader.JitterNs())); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Study(&one, Spec{Bounds: []Bound{{"depth", 6, 6}}, Runs: 2, FixedSeed: true}, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, gap := range res.Runs[0].Gaps {
+		if g.Cells[1][1].Gaps[k] != gap {
+			t.Errorf("%s: grid cell %v, single run %v", k, g.Cells[1][1].Gaps[k], gap)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// Participant is a group of identical background traders. Each trader
+// sends a Weight-proportional share of the background arrivals, and each
+// arrival is a cancel of one of its own resting orders, a market order or
+// a limit order, by its own propensities
+type Participant struct {
+	Name             string  `json:"name"`
+	Count            int     `json:"count,omitempty"` // traders in the group, default 1
+	Weight           float64 `json:"weight"`          // each trader's arrival rate, relative to the others
+	MarketOrderRatio float64 `json:"market_order_ratio"`
+	CancelRate       float64 `json:"cancel_rate"`
+}
+
+// UnmarshalJSON rejects unknown fields
+func (p *Participant) UnmarshalJSON(data []byte) error {
+	type plain Participant
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*plain)(p))
+}
+
+// TraderIDs returns the group's trader IDs: background/<name>, or
+// background/<name>-1 ... -<count> for a group of more than one
+func (p Participant) TraderIDs() []string {
+	prefix := domain.BackgroundTrader + "/" + p.Name
+	if p.Count <= 1 {
+		return []string{prefix}
+	}
+	ids := make([]string, p.Count)
+	for i := range ids {
+		ids[i] = prefix + "-" + strconv.Itoa(i+1)
+	}
+	return ids
+}
+
+// LimitRate is the chance an arrival is a limit order
+func (p Participant) LimitRate() float64 {
+	return max(0, 1-p.MarketOrderRatio-p.CancelRate)
+}
+
+// ValidateBackground checks the background population: named groups with
+// unique trader IDs, positive weights and propensities that fit in one
+func (c *Config) ValidateBackground() error {
+	seen := make(map[string]bool)
+	for i, p := range c.Background {
+		switch {
+		case p.Name == "" || strings.ContainsAny(p.Name, "/ \t"):
+			return fmt.Errorf("background %d: name %q must be non-empty, without spaces or slashes", i+1, p.Name)
+		case p.Count < 0:
+			return fmt.Errorf("background %s: count must not be negative", p.Name)
+		case p.Weight <= 0:
+			return fmt.Errorf("background %s: weight must be positive", p.Name)
+		case p.MarketOrderRatio < 0 || p.CancelRate < 0 || p.MarketOrderRatio+p.CancelRate > 1:
+			return fmt.Errorf("background %s: market_order_ratio and cancel_rate must be non-negative and add up to at most 1", p.Name)
+		}
+		for _, id := range p.TraderIDs() {
+			if seen[id] {
+				return fmt.Errorf("background %s: trader %s defined twice", p.Name, id)
+			}
+			seen[id] = true
+		}
+	}
+	return nil
+}
+
+// BackgroundIDs returns the background trader IDs in config order
+func (c *Config) BackgroundIDs() []string {
+	if len(c.Background) == 0 {
+		return []string{domain.BackgroundTrader}
+	}
+	var ids []string
+	for _, p := range c.Background {
+		ids = append(ids, p.TraderIDs()...)
+	}
+	return ids
+}
+
+// bgTrader is one background trader as the generator runs it
+type bgTrader struct {
+	id          string
+	weight      float64 // cumulative, for picking
+	bookWeight  float64 // cumulative over limit-order rates, for the initial book
+	marketRatio float64
+	cancelRate  float64
+	resting     []uint64 // its limit orders, for cancels
+	population  bool     // one of a configured population
+}
+
+// newPopulation expands the config's background population. Without
+// one the background is a single trader with the scenario's propensities
+func newPopulation(cfg *Config) []*bgTrader {
+	if len(cfg.Background) == 0 {
+		return []*bgTrader{{
+			id:          domain.BackgroundTrader,
+			marketRatio: cfg.Scenario.MarketOrderRatio,
+			cancelRate:  cfg.Scenario.CancelRate,
+		}}
+	}
+	var pop []*bgTrader
+	var weight, bookWeight float64
+	for _, p := range cfg.Background {
+		for _, id := range p.TraderIDs() {
+			weight += p.Weight
+			bookWeight += p.Weight * p.LimitRate()
+			pop = append(pop, &bgTrader{
+				id:          id,
+				weight:      weight,
+				bookWeight:  bookWeight,
+				marketRatio: p.MarketOrderRatio,
+				cancelRate:  p.CancelRate,
+				population:  true,
+			})
+		}
+	}
+	return pop
+}
+
+// pick draws the trader behind the next arrival in proportion to weight.
+// A lone trader is picked without a draw, so a run without a population
+// draws exactly as before
+func (g *backgroundGen) pick() *bgTrader {
+	if len(g.population) == 1 {
+		return g.population[0]
+	}
+	last := g.population[len(g.population)-1]
+	x := g.rng.Float64() * last.weight
+	for _, t := range g.population {
+		if x < t.weight {
+			return t
+		}
+	}
+	return last
+}
+
+// pickMaker draws the owner of an initial book order in proportion to
+// weight times limit-order rate, so pure takers own none of it
+func (g *backgroundGen) pickMaker() *bgTrader {
+	if len(g.population) == 1 {
+		return g.population[0]
+	}
+	last := g.population[len(g.population)-1]
+	if last.bookWeight == 0 {
+		return g.pick()
+	}
+	x := g.rng.Float64() * last.bookWeight
+	for _, t := range g.population {
+		if x < t.bookWeight {
+			return t
+		}
+	}
+	return last
+}
+
+// takeResting removes and returns one of t's resting orders at random
+func (t *bgTrader) takeResting(rng *rand.Rand) uint64 {
+	idx := rng.Intn(len(t.resting))
+	id := t.resting[idx]
+	t.resting = append(t.resting[:idx], t.resting[idx+1:]...)
+	return id
+}
+
+// Arrival kinds
+const (
+	arriveLimit = iota
+	arriveMarket
+	arriveCancel
+)
+
+// arrival draws what bt's next arrival is. A cancel roll with nothing of
+// its own to cancel becomes a market order for the lone default trader,
+// as it always has, and a limit order for a population trader, so that
+// a pure maker never takes
+func (g *backgroundGen) arrival(bt *bgTrader, cancelRate, marketRatio float64) int {
+	roll := g.rng.Float64()
+	switch {
+	case roll < cancelRate && len(bt.resting) > 0:
+		return arriveCancel
+	case roll < cancelRate && bt.population:
+		return arriveLimit
+	case roll < cancelRate+marketRatio:
+		return arriveMarket
+	}
+	return arriveLimit
+}
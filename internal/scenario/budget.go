@@ -0,0 +1,40 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Budget caps how much work a run may do. A run that reaches a limit
+// stops cleanly: SIM_END is logged early, every file is written and the
+// metrics cover the part that ran. Zero fields are off
+type Budget struct {
+	// MaxEvents caps the events the loop handles
+	MaxEvents uint64 `json:"max_events,omitempty"`
+	// MaxWallSeconds caps the run's wall time. Where it stops depends on
+	// the machine, so the run's config.json records the stop as
+	// max_events instead, for replay
+	MaxWallSeconds float64 `json:"max_wall_seconds,omitempty"`
+}
+
+// IsZero reports whether the run is unlimited
+func (b Budget) IsZero() bool { return b == Budget{} }
+
+// Validate rejects a negative or non-finite wall time
+func (b Budget) Validate() error {
+	if b.MaxWallSeconds < 0 || math.IsInf(b.MaxWallSeconds, 0) || math.IsNaN(b.MaxWallSeconds) {
+		return fmt.Errorf("budget max_wall_seconds must be a non-negative number")
+	}
+	return nil
+}
+
+// UnmarshalJSON rejects unknown keys, so a misspelt limit fails loudly
+// rather than leaving the run unbounded
+func (b *Budget) UnmarshalJSON(data []byte) error {
+	type plain Budget
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*plain)(b))
+}
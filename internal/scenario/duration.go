@@ -0,0 +1,170 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+)
+
+// ParseDuration parses a Go duration string such as "10s", "500ms" or
+// "250us" into nanoseconds. Negative durations are rejected
+func ParseDuration(s string) (int64, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %q", s)
+	}
+	return int64(d), nil
+}
+
+// nanos decodes a JSON duration into an int64 nanosecond field: either an
+// integer count of nanoseconds or a duration string. Configs are always
+// written back as integers, so stored configs and hashes do not change
+type nanos struct{ ns *int64 }
+
+func (n nanos) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		s, err := strconv.Unquote(string(data))
+		if err != nil {
+			return err
+		}
+		v, err := ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*n.ns = v
+		return nil
+	}
+	return json.Unmarshal(data, n.ns)
+}
+
+// UnmarshalJSON accepts duration strings for duration_ns
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type plain Config
+	aux := struct {
+		*plain
+		Duration nanos `json:"duration_ns"`
+	}{plain: (*plain)(c), Duration: nanos{&c.Duration}}
+	return json.Unmarshal(data, &aux)
+}
+
+// UnmarshalJSON accepts duration strings for signal_delay_ns, gateway_ns,
+// risk_check_ns, market_data_ns and the connection warm-up fields
+func (tc *TraderConfig) UnmarshalJSON(data []byte) error {
+	type plain TraderConfig
+	aux := struct {
+		*plain
+		SignalDelayNs nanos `json:"signal_delay_ns"`
+		GatewayNs     nanos `json:"gateway_ns"`
+		RiskCheckNs   nanos `json:"risk_check_ns"`
+		MarketDataNs  nanos `json:"market_data_ns"`
+		IdleNs        nanos `json:"idle_ns"`
+		WarmupNs      nanos `json:"warmup_ns"`
+		HandshakeNs   nanos `json:"handshake_ns"`
+	}{plain: (*plain)(tc), SignalDelayNs: nanos{&tc.SignalDelayNs}, GatewayNs: nanos{&tc.GatewayNs}, RiskCheckNs: nanos{&tc.RiskCheckNs}, MarketDataNs: nanos{&tc.MarketDataNs},
+		IdleNs: nanos{&tc.IdleNs}, WarmupNs: nanos{&tc.WarmupNs}, HandshakeNs: nanos{&tc.HandshakeNs}}
+	return json.Unmarshal(data, &aux)
+}
+
+// UnmarshalJSON accepts duration strings for the interval, half-life,
+// flow window and burst fields
+func (p *ScenarioParams) UnmarshalJSON(data []byte) error {
+	type plain ScenarioParams
+	aux := struct {
+		*plain
+		OrderIntervalNs  nanos `json:"order_interval_ns"`
+		SignalIntervalNs nanos `json:"signal_interval_ns"`
+		SignalHalfLifeNs nanos `json:"signal_half_life_ns"`
+		SignalFlowWindow nanos `json:"signal_flow_window_ns"`
+		BurstWindowNs    nanos `json:"burst_window_ns"`
+		BurstIntervalNs  nanos `json:"burst_interval_ns"`
+	}{
+		plain:            (*plain)(p),
+		OrderIntervalNs:  nanos{&p.OrderIntervalNs},
+		SignalIntervalNs: nanos{&p.SignalIntervalNs},
+		SignalHalfLifeNs: nanos{&p.SignalHalfLifeNs},
+		SignalFlowWindow: nanos{&p.SignalFlowWindowNs},
+		BurstWindowNs:    nanos{&p.BurstWindowNs},
+		BurstIntervalNs:  nanos{&p.BurstIntervalNs},
+	}
+	return json.Unmarshal(data, &aux)
+}
+
+// LoadConfig reads a JSON config file. Its name must be a built-in
+// scenario, whose defaults fill any field the file leaves out, so a file
+// need only list what it changes:
+//
+//	{"name": "spike", "duration_ns": "10s", "scenario": {"burst_window_ns": "250ms"}}
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ParseConfig reads a config in LoadConfig's format from data
+func ParseConfig(data []byte) (*Config, error) {
+	var head struct {
+		Name     string          `json:"name"`
+		Seed     int64           `json:"seed"`
+		Duration json.RawMessage `json:"duration_ns"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+	cfg := GetConfig(head.Name, head.Seed)
+	if cfg == nil {
+		return nil, fmt.Errorf("unknown scenario %q (calm, thin, spike)", head.Name)
+	}
+	if err := unknownField(data, reflect.TypeOf(*cfg), ""); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	// Sessions set the duration unless the file gives it
+	if len(cfg.Sessions) > 0 && head.Duration == nil {
+		cfg.Duration = cfg.SessionsDuration()
+	}
+	return cfg, nil
+}
+
+// unknownField rejects keys of a JSON object that match no field of t, at
+// any depth, so a misspelled field fails instead of silently keeping its
+// default. The custom decoders above cannot pass DisallowUnknownFields on
+func unknownField(data []byte, t reflect.Type, prefix string) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields[name] = t.Field(i).Type
+	}
+	for _, key := range detorder.Keys(obj) {
+		ft, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("unknown field %q", prefix+key)
+		}
+		if ft.Kind() == reflect.Struct {
+			if err := unknownField(obj[key], ft, prefix+key+"."); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -1,8 +1,10 @@
 package scenario
 
 import (
+	"fmt"
+	"math"
 	"math/rand"
-	"sort"
+	"strconv"
 
 	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
 )
@@ -12,13 +14,23 @@ type backgroundGen struct {
 	cfg    *Config
 	rng    *rand.Rand
 	nextID uint64
+
+	// population are the background traders orders are drawn for
+	population []*bgTrader
+
+	// Signals as generated, for tilting flow; the events themselves are
+	// handed to the runner and recycled
+	signals []domain.Signal
+	sigTime []int64
+	sigNext int
 }
 
 func newBackgroundGen(cfg *Config) *backgroundGen {
 	return &backgroundGen{
-		cfg:    cfg,
-		rng:    rand.New(rand.NewSource(cfg.Seed)),
-		nextID: 100_000, // background orders start at high IDs to avoid collision
+		cfg:        cfg,
+		rng:        rand.New(rand.NewSource(cfg.Seed)),
+		nextID:     100_000, // background orders start at high IDs to avoid collision
+		population: newPopulation(cfg),
 	}
 }
 
@@ -42,6 +54,105 @@ func (g *backgroundGen) randSide() domain.Side {
 	return domain.Sell
 }
 
+// informedSide picks the side of a background market order at t, tilted
+// toward the latest signal by SignalInformation. With no information it
+// draws exactly like randSide
+func (g *backgroundGen) informedSide(t int64) domain.Side {
+	for g.sigNext < len(g.sigTime) && g.sigTime[g.sigNext] <= t {
+		g.sigNext++
+	}
+	pBuy := 0.5
+	if info := g.cfg.Scenario.SignalInformation; info != 0 && g.sigNext > 0 {
+		i := g.sigNext - 1
+		tilt := info * g.signals[i].Strength(t-g.sigTime[i])
+		pBuy += 0.5 * math.Max(-1, math.Min(1, tilt))
+	}
+	if g.rng.Float64() < pBuy {
+		return domain.Buy
+	}
+	return domain.Sell
+}
+
+// eventStream accumulates background events in timestamp order without a
+// final sort. Order flow is generated with non-decreasing timestamps (each
+// event's jitter is smaller than the step to the next), so the short signal
+// stream only needs interleaving. Signals go first on ties, matching the
+// stable sort over initial book + signals + order flow this replaces
+//
+// With an emit func set, events are handed off in batches as they are
+// produced instead of being accumulated; emit takes ownership of each batch
+type eventStream struct {
+	events  []*domain.Event
+	signals []*domain.Event
+	next    int
+	last    *domain.Event
+	emit    func([]*domain.Event)
+}
+
+// streamBatch is the number of events per batch handed to emit
+const streamBatch = 1024
+
+func newEventStream(initial, signals []*domain.Event, flowHint int64, emit func([]*domain.Event)) *eventStream {
+	capacity := int64(len(initial)+len(signals)) + flowHint
+	if emit != nil {
+		capacity = streamBatch
+	}
+	s := &eventStream{
+		events:  make([]*domain.Event, 0, capacity),
+		signals: signals,
+		emit:    emit,
+	}
+	for _, e := range initial {
+		s.add(e)
+	}
+	return s
+}
+
+// push appends an order-flow event, first emitting any signals due by then
+func (s *eventStream) push(e *domain.Event) {
+	for s.next < len(s.signals) && s.signals[s.next].Timestamp <= e.Timestamp {
+		s.add(s.signals[s.next])
+		s.next++
+	}
+	if s.last != nil && s.last.Timestamp > e.Timestamp {
+		panic(fmt.Sprintf("order flow out of order: %d after %d", e.Timestamp, s.last.Timestamp))
+	}
+	s.add(e)
+}
+
+func (s *eventStream) add(e *domain.Event) {
+	s.events = append(s.events, e)
+	s.last = e
+	if s.emit != nil && len(s.events) == streamBatch {
+		s.emit(s.events)
+		s.events = make([]*domain.Event, 0, streamBatch)
+	}
+}
+
+// finish appends the remaining signals and returns the ordered events
+// When streaming, the final partial batch is emitted and nil is returned
+func (s *eventStream) finish() []*domain.Event {
+	for _, e := range s.signals[s.next:] {
+		s.add(e)
+	}
+	s.next = len(s.signals)
+	if s.emit != nil {
+		if len(s.events) > 0 {
+			s.emit(s.events)
+		}
+		s.events = nil
+	}
+	return s.events
+}
+
+// flowCapacity estimates the number of order-flow events for preallocation
+func (g *backgroundGen) flowCapacity() int64 {
+	if g.cfg.Scenario.OrderIntervalNs <= 0 {
+		return 0
+	}
+	return g.cfg.Duration / g.cfg.Scenario.OrderIntervalNs
+}
+
 // generateInitialBook creates initial resting limit orders to seed the book
 func (g *backgroundGen) generateInitialBook() []*domain.Event {
 	p := g.cfg.Scenario
@@ -55,20 +166,17 @@ func (g *backgroundGen) generateInitialBook() []*domain.Event {
 	for lvl := 0; lvl < p.MaxPriceLevels; lvl++ {
 		price := bestBid - int64(lvl)*p.PriceTickSize
 		for i := int64(0); i < p.DepthPerLevel; i++ {
+			owner := g.pickMaker()
 			id := g.nextOrderID()
-			order := &domain.Order{
+			order := domain.PooledOrder(domain.Order{
 				ID:       id,
-				TraderID: "background",
+				TraderID: owner.id,
 				Side:     domain.Buy,
 				Type:     domain.LimitOrder,
 				Price:    price,
 				Qty:      g.randSize(),
-			}
-			events = append(events, &domain.Event{
-				Timestamp: 0,
-				Type:      domain.EventOrderAccepted,
-				Order:     order,
 			})
+			events = append(events, domain.PooledEvent(domain.NewOrderEvent(0, order)))
 		}
 	}
 
@@ -76,20 +184,17 @@ func (g *backgroundGen) generateInitialBook() []*domain.Event {
 	for lvl := 0; lvl < p.MaxPriceLevels; lvl++ {
 		price := bestAsk + int64(lvl)*p.PriceTickSize
 		for i := int64(0); i < p.DepthPerLevel; i++ {
+			owner := g.pickMaker()
 			id := g.nextOrderID()
-			order := &domain.Order{
+			order := domain.PooledOrder(domain.Order{
 				ID:       id,
-				TraderID: "background",
+				TraderID: owner.id,
 				Side:     domain.Sell,
 				Type:     domain.LimitOrder,
 				Price:    price,
 				Qty:      g.randSize(),
-			}
-			events = append(events, &domain.Event{
-				Timestamp: 0,
-				Type:      domain.EventOrderAccepted,
-				Order:     order,
 			})
+			events = append(events, domain.PooledEvent(domain.NewOrderEvent(0, order)))
 		}
 	}
 
@@ -106,14 +211,13 @@ func (g *backgroundGen) generateSignals() []*domain.Event {
 
 	for t := interval; t < g.cfg.Duration; t += interval {
 		// Signal value is sampled from N(0, 0.5^2)
-		value := g.rng.NormFloat64() * 0.5
-		events = append(events, &domain.Event{
-			Timestamp: t,
-			Type:      domain.EventSignal,
-			Signal: &domain.Signal{
-				Value: value,
-			},
-		})
+		sig := domain.Signal{
+			Value:      g.rng.NormFloat64() * 0.5,
+			HalfLifeNs: g.cfg.Scenario.SignalHalfLifeNs,
+		}
+		g.signals = append(g.signals, sig)
+		g.sigTime = append(g.sigTime, t)
+		events = append(events, domain.PooledEvent(domain.NewSignalEvent(t, &sig)))
 	}
 	return events
 }
@@ -128,11 +232,17 @@ func NewCalmGenerator(cfg *Config) *CalmGenerator {
 }
 
 func (g *CalmGenerator) Generate() []*domain.Event {
-	events := g.generateInitialBook()
-	events = append(events, g.generateSignals()...)
+	return g.generate(nil)
+}
+
+func (g *CalmGenerator) Stream(emit func([]*domain.Event)) {
+	g.generate(emit)
+}
+
+func (g *CalmGenerator) generate(emit func([]*domain.Event)) []*domain.Event {
+	out := newEventStream(g.generateInitialBook(), g.generateSignals(), g.flowCapacity(), emit)
 
 	p := g.cfg.Scenario
-	var restingIDs []uint64 // track IDs for potential cancels
 
 	for t := p.OrderIntervalNs; t < g.cfg.Duration; t += p.OrderIntervalNs {
 		// Small random timing jitter
@@ -143,40 +253,31 @@ func (g *CalmGenerator) Generate() []*domain.Event {
 			break
 		}
 
-		// Decide: cancel, market, or limit
-		roll := g.rng.Float64()
+		// Decide who, then: cancel, market, or limit
+		bt := g.pick()
+		kind := g.arrival(bt, bt.cancelRate, bt.marketRatio)
 
-		if roll < p.CancelRate && len(restingIDs) > 0 {
-			// Cancel a random resting order
-			idx := g.rng.Intn(len(restingIDs))
-			cancelID := restingIDs[idx]
-			restingIDs = append(restingIDs[:idx], restingIDs[idx+1:]...)
+		if kind == arriveCancel {
+			// Cancel one of its resting orders
+			cancelID := bt.takeResting(g.rng)
 
 			id := g.nextOrderID()
-			events = append(events, &domain.Event{
-				Timestamp: eventTime,
-				Type:      domain.EventOrderAccepted,
-				Order: &domain.Order{
-					ID:       id,
-					TraderID: "background",
-					Type:     domain.CancelOrder,
-					CancelID: cancelID,
-				},
-			})
-		} else if roll < p.CancelRate+p.MarketOrderRatio {
+			out.push(domain.PooledEvent(domain.NewOrderEvent(eventTime, domain.PooledOrder(domain.Order{
+				ID:       id,
+				TraderID: bt.id,
+				Type:     domain.CancelOrder,
+				CancelID: cancelID,
+			}))))
+		} else if kind == arriveMarket {
 			// Market order
 			id := g.nextOrderID()
-			events = append(events, &domain.Event{
-				Timestamp: eventTime,
-				Type:      domain.EventOrderAccepted,
-				Order: &domain.Order{
-					ID:       id,
-					TraderID: "background",
-					Side:     g.randSide(),
-					Type:     domain.MarketOrder,
-					Qty:      g.randSize(),
-				},
-			})
+			out.push(domain.PooledEvent(domain.NewOrderEvent(eventTime, domain.PooledOrder(domain.Order{
+				ID:       id,
+				TraderID: bt.id,
+				Side:     g.informedSide(eventTime),
+				Type:     domain.MarketOrder,
+				Qty:      g.randSize(),
+			}))))
 		} else {
 			// Limit order near the mid
 			id := g.nextOrderID()
@@ -190,29 +291,20 @@ func (g *CalmGenerator) Generate() []*domain.Event {
 				price = p.InitialMidPrice + p.InitialSpread/2 + offset
 			}
 
-			order := &domain.Order{
+			order := domain.PooledOrder(domain.Order{
 				ID:       id,
-				TraderID: "background",
+				TraderID: bt.id,
 				Side:     side,
 				Type:     domain.LimitOrder,
 				Price:    price,
 				Qty:      g.randSize(),
-			}
-			events = append(events, &domain.Event{
-				Timestamp: eventTime,
-				Type:      domain.EventOrderAccepted,
-				Order:     order,
 			})
-			restingIDs = append(restingIDs, id)
+			out.push(domain.PooledEvent(domain.NewOrderEvent(eventTime, order)))
+			bt.resting = append(bt.resting, id)
 		}
 	}
 
-	// Sort by timestamp for deterministic processing
-	sort.SliceStable(events, func(i, j int) bool {
-		return events[i].Timestamp < events[j].Timestamp
-	})
-
-	return events
+	return out.finish()
 }
 
 // ThinGenerator produces low-depth order flow with sporadic sweeps
@@ -225,11 +317,17 @@ func NewThinGenerator(cfg *Config) *ThinGenerator {
 }
 
 func (g *ThinGenerator) Generate() []*domain.Event {
-	events := g.generateInitialBook()
-	events = append(events, g.generateSignals()...)
+	return g.generate(nil)
+}
+
+func (g *ThinGenerator) Stream(emit func([]*domain.Event)) {
+	g.generate(emit)
+}
+
+func (g *ThinGenerator) generate(emit func([]*domain.Event)) []*domain.Event {
+	out := newEventStream(g.generateInitialBook(), g.generateSignals(), g.flowCapacity(), emit)
 
 	p := g.cfg.Scenario
-	var restingIDs []uint64
 
 	for t := p.OrderIntervalNs; t < g.cfg.Duration; t += p.OrderIntervalNs {
 		jitter := g.rng.Int63n(p.OrderIntervalNs / 4)
@@ -238,39 +336,30 @@ func (g *ThinGenerator) Generate() []*domain.Event {
 			break
 		}
 
-		roll := g.rng.Float64()
+		bt := g.pick()
+		kind := g.arrival(bt, bt.cancelRate, bt.marketRatio)
 
-		if roll < p.CancelRate && len(restingIDs) > 0 {
-			idx := g.rng.Intn(len(restingIDs))
-			cancelID := restingIDs[idx]
-			restingIDs = append(restingIDs[:idx], restingIDs[idx+1:]...)
+		if kind == arriveCancel {
+			cancelID := bt.takeResting(g.rng)
 
 			id := g.nextOrderID()
-			events = append(events, &domain.Event{
-				Timestamp: eventTime,
-				Type:      domain.EventOrderAccepted,
-				Order: &domain.Order{
-					ID:       id,
-					TraderID: "background",
-					Type:     domain.CancelOrder,
-					CancelID: cancelID,
-				},
-			})
-		} else if roll < p.CancelRate+p.MarketOrderRatio {
+			out.push(domain.PooledEvent(domain.NewOrderEvent(eventTime, domain.PooledOrder(domain.Order{
+				ID:       id,
+				TraderID: bt.id,
+				Type:     domain.CancelOrder,
+				CancelID: cancelID,
+			}))))
+		} else if kind == arriveMarket {
 			// Sporadic market sweep — larger size to move price
 			id := g.nextOrderID()
 			sweepSize := g.randSize() * 2 // larger to cause slippage
-			events = append(events, &domain.Event{
-				Timestamp: eventTime,
-				Type:      domain.EventOrderAccepted,
-				Order: &domain.Order{
-					ID:       id,
-					TraderID: "background",
-					Side:     g.randSide(),
-					Type:     domain.MarketOrder,
-					Qty:      sweepSize,
-				},
-			})
+			out.push(domain.PooledEvent(domain.NewOrderEvent(eventTime, domain.PooledOrder(domain.Order{
+				ID:       id,
+				TraderID: bt.id,
+				Side:     g.informedSide(eventTime),
+				Type:     domain.MarketOrder,
+				Qty:      sweepSize,
+			}))))
 		} else {
 			// Limit order — thin depth
 			id := g.nextOrderID()
@@ -283,27 +372,20 @@ func (g *ThinGenerator) Generate() []*domain.Event {
 				price = p.InitialMidPrice + p.InitialSpread/2 + offset
 			}
 
-			order := &domain.Order{
+			order := domain.PooledOrder(domain.Order{
 				ID:       id,
-				TraderID: "background",
+				TraderID: bt.id,
 				Side:     side,
 				Type:     domain.LimitOrder,
 				Price:    price,
 				Qty:      g.randSize(),
-			}
-			events = append(events, &domain.Event{
-				Timestamp: eventTime,
-				Type:      domain.EventOrderAccepted,
-				Order:     order,
 			})
-			restingIDs = append(restingIDs, id)
+			out.push(domain.PooledEvent(domain.NewOrderEvent(eventTime, order)))
+			bt.resting = append(bt.resting, id)
 		}
 	}
 
-	sort.SliceStable(events, func(i, j int) bool {
-		return events[i].Timestamp < events[j].Timestamp
-	})
-	return events
+	return out.finish()
 }
 
 // SpikeGenerator produces order flow with periodic burst windows
@@ -316,11 +398,17 @@ func NewSpikeGenerator(cfg *Config) *SpikeGenerator {
 }
 
 func (g *SpikeGenerator) Generate() []*domain.Event {
-	events := g.generateInitialBook()
-	events = append(events, g.generateSignals()...)
+	return g.generate(nil)
+}
+
+func (g *SpikeGenerator) Stream(emit func([]*domain.Event)) {
+	g.generate(emit)
+}
+
+func (g *SpikeGenerator) generate(emit func([]*domain.Event)) []*domain.Event {
+	out := newEventStream(g.generateInitialBook(), g.generateSignals(), g.flowCapacity(), emit)
 
 	p := g.cfg.Scenario
-	var restingIDs []uint64
 
 	// Determine burst windows
 	type window struct{ start, end int64 }
@@ -331,20 +419,22 @@ func (g *SpikeGenerator) Generate() []*domain.Event {
 		}
 	}
 
-	inBurst := func(t int64) bool {
-		for _, w := range bursts {
+	// burstAt returns the 1-based burst containing t, or 0
+	burstAt := func(t int64) int {
+		for i, w := range bursts {
 			if t >= w.start && t < w.end {
-				return true
+				return i + 1
 			}
 		}
-		return false
+		return 0
 	}
 
 	// During bursts, interval is reduced by BurstRate
 	t := p.OrderIntervalNs
 	for t < g.cfg.Duration {
 		interval := p.OrderIntervalNs
-		isBurst := inBurst(t)
+		burst := burstAt(t)
+		isBurst := burst > 0
 		if isBurst && p.BurstRate > 0 {
 			interval = int64(float64(p.OrderIntervalNs) / p.BurstRate)
 			if interval < 1 {
@@ -358,8 +448,9 @@ func (g *SpikeGenerator) Generate() []*domain.Event {
 			break
 		}
 
-		cancelRate := p.CancelRate
-		marketRatio := p.MarketOrderRatio
+		bt := g.pick()
+		cancelRate := bt.cancelRate
+		marketRatio := bt.marketRatio
 		if isBurst {
 			cancelRate *= p.BurstCancelMul
 			marketRatio *= p.BurstMarketMul
@@ -371,41 +462,32 @@ func (g *SpikeGenerator) Generate() []*domain.Event {
 			}
 		}
 
-		roll := g.rng.Float64()
+		kind := g.arrival(bt, cancelRate, marketRatio)
 
-		if roll < cancelRate && len(restingIDs) > 0 {
-			idx := g.rng.Intn(len(restingIDs))
-			cancelID := restingIDs[idx]
-			restingIDs = append(restingIDs[:idx], restingIDs[idx+1:]...)
+		var ev *domain.Event
+		if kind == arriveCancel {
+			cancelID := bt.takeResting(g.rng)
 
 			id := g.nextOrderID()
-			events = append(events, &domain.Event{
-				Timestamp: eventTime,
-				Type:      domain.EventOrderAccepted,
-				Order: &domain.Order{
-					ID:       id,
-					TraderID: "background",
-					Type:     domain.CancelOrder,
-					CancelID: cancelID,
-				},
-			})
-		} else if roll < cancelRate+marketRatio {
+			ev = domain.PooledEvent(domain.NewOrderEvent(eventTime, domain.PooledOrder(domain.Order{
+				ID:       id,
+				TraderID: bt.id,
+				Type:     domain.CancelOrder,
+				CancelID: cancelID,
+			})))
+		} else if kind == arriveMarket {
 			id := g.nextOrderID()
 			size := g.randSize()
 			if isBurst && p.BurstSizeMul > 0 {
 				size = int64(float64(size) * p.BurstSizeMul)
 			}
-			events = append(events, &domain.Event{
-				Timestamp: eventTime,
-				Type:      domain.EventOrderAccepted,
-				Order: &domain.Order{
-					ID:       id,
-					TraderID: "background",
-					Side:     g.randSide(),
-					Type:     domain.MarketOrder,
-					Qty:      size,
-				},
-			})
+			ev = domain.PooledEvent(domain.NewOrderEvent(eventTime, domain.PooledOrder(domain.Order{
+				ID:       id,
+				TraderID: bt.id,
+				Side:     g.informedSide(eventTime),
+				Type:     domain.MarketOrder,
+				Qty:      size,
+			})))
 		} else {
 			id := g.nextOrderID()
 			side := g.randSide()
@@ -417,33 +499,47 @@ func (g *SpikeGenerator) Generate() []*domain.Event {
 				price = p.InitialMidPrice + p.InitialSpread/2 + offset
 			}
 
-			order := &domain.Order{
+			order := domain.PooledOrder(domain.Order{
 				ID:       id,
-				TraderID: "background",
+				TraderID: bt.id,
 				Side:     side,
 				Type:     domain.LimitOrder,
 				Price:    price,
 				Qty:      g.randSize(),
-			}
-			events = append(events, &domain.Event{
-				Timestamp: eventTime,
-				Type:      domain.EventOrderAccepted,
-				Order:     order,
 			})
-			restingIDs = append(restingIDs, id)
+			ev = domain.PooledEvent(domain.NewOrderEvent(eventTime, order))
+			bt.resting = append(bt.resting, id)
 		}
+		if isBurst {
+			ev.Annotate("burst_id", strconv.Itoa(burst))
+		}
+		out.push(ev)
 
 		t += interval
 	}
 
-	sort.SliceStable(events, func(i, j int) bool {
-		return events[i].Timestamp < events[j].Timestamp
-	})
-	return events
+	return out.finish()
 }
 
 // NewGenerator creates the appropriate generator for a config
 func NewGenerator(cfg *Config) Generator {
+	if len(cfg.Sessions) > 0 {
+		return &sessionGenerator{cfg: cfg}
+	}
+	return newFlowGenerator(cfg)
+}
+
+// flowGenerator is a scenario's generator, whose background state a
+// session generator carries from one session to the next
+type flowGenerator interface {
+	Generator
+	base() *backgroundGen
+}
+
+func (g *backgroundGen) base() *backgroundGen { return g }
+
+// newFlowGenerator creates the generator for cfg's scenario
+func newFlowGenerator(cfg *Config) flowGenerator {
 	switch cfg.Name {
 	case "calm":
 		return NewCalmGenerator(cfg)
@@ -0,0 +1,81 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+)
+
+// Guards abort a run that has turned pathological, so a degenerate
+// parameter draw fails fast instead of producing meaningless metrics.
+// Zero fields are off
+type Guards struct {
+	// OneSidedNs is how long the book may have an empty side, once it
+	// has had both
+	OneSidedNs int64 `json:"one_sided_ns,omitempty"`
+	// MaxBacklog caps the events queued by the run itself, beyond the
+	// background flow and timers scheduled up front
+	MaxBacklog int `json:"max_backlog,omitempty"`
+	// NoTradeNs is how long the run may go without a trade
+	NoTradeNs int64 `json:"no_trade_ns,omitempty"`
+}
+
+// IsZero reports whether every guard is off
+func (g Guards) IsZero() bool { return g == Guards{} }
+
+// Validate rejects negative guards
+func (g Guards) Validate() error {
+	if g.OneSidedNs < 0 || g.MaxBacklog < 0 || g.NoTradeNs < 0 {
+		return fmt.Errorf("guards must be non-negative")
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts duration strings for the time guards and
+// rejects unknown guards
+func (g *Guards) UnmarshalJSON(data []byte) error {
+	type plain Guards
+	aux := struct {
+		*plain
+		OneSidedNs nanos `json:"one_sided_ns"`
+		NoTradeNs  nanos `json:"no_trade_ns"`
+	}{plain: (*plain)(g), OneSidedNs: nanos{&g.OneSidedNs}, NoTradeNs: nanos{&g.NoTradeNs}}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(&aux)
+}
+
+// ParseGuards parses "key=value,..." with the JSON keys, e.g.
+// "one_sided_ns=500ms,no_trade_ns=2s,max_backlog=100000"
+func ParseGuards(s string) (Guards, error) {
+	fields := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok || k == "" {
+			return Guards{}, fmt.Errorf("guard %q: want key=value", kv)
+		}
+		fields[k] = v
+	}
+	var g Guards
+	var err error
+	for k, v := range detorder.All(fields) {
+		switch k {
+		case "one_sided_ns":
+			g.OneSidedNs, err = ParseDuration(v)
+		case "no_trade_ns":
+			g.NoTradeNs, err = ParseDuration(v)
+		case "max_backlog":
+			g.MaxBacklog, err = strconv.Atoi(v)
+		default:
+			return Guards{}, fmt.Errorf("unknown guard %q", k)
+		}
+		if err != nil {
+			return Guards{}, fmt.Errorf("guard %s: %w", k, err)
+		}
+	}
+	return g, g.Validate()
+}
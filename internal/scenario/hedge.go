@@ -0,0 +1,87 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+)
+
+// Hedge adds a second instrument, a delta-one future on the primary, on
+// which the fast and slow traders lay off the position each primary
+// fill gives them. The future has no book: its fair value is the
+// primary's mid plus BasisBps, and a dealer quotes it HalfSpreadBps
+// either side, so a hedge pays the half spread plus however far fair
+// value moved while it was in flight
+type Hedge struct {
+	BasisBps      float64 `json:"basis_bps,omitempty"`
+	HalfSpreadBps float64 `json:"half_spread_bps,omitempty"`
+	// Ratio is the future qty sold per primary qty bought, and the
+	// reverse; 0 is 1, a full hedge
+	Ratio float64 `json:"ratio,omitempty"`
+}
+
+// HedgeRatio is the ratio in effect
+func (h Hedge) HedgeRatio() float64 {
+	if h.Ratio == 0 {
+		return 1
+	}
+	return h.Ratio
+}
+
+// Validate rejects a non-finite basis and a negative or non-finite half
+// spread or ratio
+func (h Hedge) Validate() error {
+	for _, v := range []float64{h.BasisBps, h.HalfSpreadBps, h.Ratio} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("hedge params must be finite")
+		}
+	}
+	if h.HalfSpreadBps < 0 || h.Ratio < 0 {
+		return fmt.Errorf("hedge half_spread_bps and ratio must be non-negative")
+	}
+	return nil
+}
+
+// UnmarshalJSON rejects unknown hedge params
+func (h *Hedge) UnmarshalJSON(data []byte) error {
+	type plain Hedge
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*plain)(h))
+}
+
+// ParseHedge parses "key=value,..." with the JSON keys, e.g.
+// "basis_bps=5,half_spread_bps=0.5"
+func ParseHedge(s string) (Hedge, error) {
+	fields := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok || k == "" {
+			return Hedge{}, fmt.Errorf("hedge param %q: want key=value", kv)
+		}
+		fields[k] = v
+	}
+	var h Hedge
+	var err error
+	for k, v := range detorder.All(fields) {
+		switch k {
+		case "basis_bps":
+			h.BasisBps, err = strconv.ParseFloat(v, 64)
+		case "half_spread_bps":
+			h.HalfSpreadBps, err = strconv.ParseFloat(v, 64)
+		case "ratio":
+			h.Ratio, err = strconv.ParseFloat(v, 64)
+		default:
+			return Hedge{}, fmt.Errorf("unknown hedge param %q", k)
+		}
+		if err != nil {
+			return Hedge{}, fmt.Errorf("hedge param %s: %w", k, err)
+		}
+	}
+	return h, h.Validate()
+}
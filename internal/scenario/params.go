@@ -2,8 +2,14 @@
 package scenario
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
 )
 
 // Config holds all parameters for a simulation run
@@ -12,33 +18,307 @@ type Config struct {
 	Seed     int64  `json:"seed"`
 	Duration int64  `json:"duration_ns"` // total simulation duration in nanos
 
+	// PriceScale is the instrument's fixed-point units per 1.0 of price
+	// All prices below are in this scale; 0 means domain.DefaultScale
+	PriceScale int64 `json:"price_scale,omitempty"`
+
 	// Trader configs
-	FastTrader  TraderConfig `json:"fast_trader"`
-	SlowTrader  TraderConfig `json:"slow_trader"`
+	FastTrader TraderConfig `json:"fast_trader"`
+	SlowTrader TraderConfig `json:"slow_trader"`
+
+	// JitterMode is how the traders' jitter is drawn: stream (the
+	// default) or hashed. See latency.JitterHashed
+	JitterMode string `json:"jitter_mode,omitempty"`
 
 	// Scenario-specific parameters
 	Scenario ScenarioParams `json:"scenario"`
+
+	// Background splits the background flow among identifiable traders
+	// with their own rates and propensities. None means a single
+	// "background" trader with the scenario's
+	Background []Participant `json:"background,omitempty"`
+
+	// Per-share fees in price units, charged to the passive (maker) and
+	// aggressing (taker) side of each fill; negative values are rebates
+	MakerFee int64 `json:"maker_fee,omitempty"`
+	TakerFee int64 `json:"taker_fee,omitempty"`
+
+	// Venue is the market mechanism; the zero value is continuous FIFO
+	Venue Venue `json:"venue,omitzero"`
+
+	// Metrics chooses the slippage benchmark and markout horizons
+	Metrics metrics.Options `json:"metrics,omitzero"`
+
+	// Assertions are runtime rules checked as events flow; the first
+	// violation fails the run. See package assertion for the syntax
+	Assertions []string `json:"assertions,omitempty"`
+
+	// LogLevel is how much the event log records: trades, orders, bbo,
+	// depth or full (the default). See eventlog.Level
+	LogLevel string `json:"log_level,omitempty"`
+
+	// ExternalFlow is an event log whose orders are injected at their
+	// recorded arrival times, alongside the generated background flow
+	ExternalFlow string `json:"external_flow,omitempty"`
+
+	// BackgroundFrom is a flow.jsonl recorded by an earlier run, scheduled
+	// in place of the generated background flow and signals, so runs
+	// under other mechanisms or traders face the byte-identical market
+	BackgroundFrom string `json:"background_from,omitempty"`
+
+	// Guards abort the run if the market degenerates; off by default
+	Guards Guards `json:"guards,omitzero"`
+
+	// Budget stops the run early, cleanly, once it has done this much
+	// work; off by default
+	Budget Budget `json:"budget,omitzero"`
+
+	// Hedge gives the traders a delta-one future to lay off their fills
+	// on; nil for none
+	Hedge *Hedge `json:"hedge,omitempty"`
+
+	// Sessions split the run into trading sessions, back to back; they
+	// must add up to Duration. None means one continuous session
+	Sessions []Session `json:"sessions,omitempty"`
+}
+
+// Scale returns the instrument's price scale
+func (c *Config) Scale() domain.Scale {
+	if c.PriceScale == 0 {
+		return domain.DefaultScale
+	}
+	return domain.Scale(c.PriceScale)
+}
+
+// Rescale converts every price in the config to a new scale, e.g. to
+// simulate a crypto instrument quoted to 8 decimals. It fails rather than
+// round if a price is not representable in the new scale
+func (c *Config) Rescale(to domain.Scale) error {
+	if err := to.Validate(); err != nil {
+		return err
+	}
+	from := c.Scale()
+	prices := []*int64{&c.Scenario.InitialMidPrice, &c.Scenario.InitialSpread, &c.Scenario.PriceTickSize,
+		&c.MakerFee, &c.TakerFee}
+	converted := make([]int64, len(prices))
+	for i, p := range prices {
+		if to >= from {
+			converted[i] = *p * int64(to/from)
+			continue
+		}
+		div := int64(from / to)
+		if *p%div != 0 {
+			return fmt.Errorf("price %s not representable at scale %d", from.Format(*p), to)
+		}
+		converted[i] = *p / div
+	}
+	for i, p := range prices {
+		*p = converted[i]
+	}
+	c.PriceScale = int64(to)
+	return nil
+}
+
+// LatencyAsymmetric reports whether the traders' latencies differ
+func (c *Config) LatencyAsymmetric() bool {
+	f, s := c.FastTrader, c.SlowTrader
+	return f.BaseLatencyNs() != s.BaseLatencyNs() || f.JitterNs() != s.JitterNs() || f.GatewayNs != s.GatewayNs || f.RiskCheckNs != s.RiskCheckNs ||
+		f.MarketDataLatencyNs() != s.MarketDataLatencyNs() || f.connection() != s.connection()
+}
+
+// InformationAsymmetric reports whether the traders' signal access differs
+func (c *Config) InformationAsymmetric() bool {
+	return !c.FastTrader.sameSignalAccess(c.SlowTrader)
+}
+
+// CapitalAsymmetric reports whether the traders' capital limits differ
+func (c *Config) CapitalAsymmetric() bool {
+	f, s := c.FastTrader, c.SlowTrader
+	return f.Capital != s.Capital || f.Capital > 0 && f.MarginShare() != s.MarginShare()
+}
+
+// MarginShare is the share of exposure held against capital, Margin or
+// its default of 1
+func (tc TraderConfig) MarginShare() float64 {
+	if tc.Margin == 0 {
+		return 1
+	}
+	return tc.Margin
+}
+
+// StrategyAsymmetric reports whether the traders' strategies differ
+func (c *Config) StrategyAsymmetric() bool {
+	return c.FastTrader.Strategy != c.SlowTrader.Strategy
 }
 
 // TraderConfig holds trader-specific parameters
+// The microsecond fields add to the millisecond ones, so colo-scale
+// latencies such as 150 µs are expressible; use BaseLatencyNs and JitterNs
 type TraderConfig struct {
-	ID           string `json:"id"`
-	BaseLatencyMs int64 `json:"base_latency_ms"`
-	JitterMs      int64 `json:"jitter_ms"`
+	ID            string `json:"id"`
+	BaseLatencyMs int64  `json:"base_latency_ms"`
+	JitterMs      int64  `json:"jitter_ms"`
+	BaseLatencyUs int64  `json:"base_latency_us,omitempty"`
+	JitterUs      int64  `json:"jitter_us,omitempty"`
+
+	// GatewayNs is how long the trader's order entry gateway takes to
+	// send one message. Messages go one at a time, so a burst queues
+	// behind its first; 0 sends each at once
+	GatewayNs int64 `json:"gateway_ns,omitempty"`
+
+	// RiskCheckNs is how long the trader's pre-trade risk check holds each
+	// message, before its gateway; 0 for none
+	RiskCheckNs int64 `json:"risk_check_ns,omitempty"`
+
+	// Capital is the trader's buying power in the instrument's currency;
+	// 0 leaves it unlimited. Margin is the share of the trader's exposure
+	// held against it: 1 (the default) for a cash account, 0.25 for 4:1
+	// leverage. An order that would need more than the trader's equity
+	// is rejected before it reaches the book
+	Capital float64 `json:"capital,omitempty"`
+	Margin  float64 `json:"margin,omitempty"`
+
+	// Connection warm-up. The trader's first message pays HandshakeNs to
+	// open its session, and a message after IdleNs or more of quiet pays
+	// WarmupNs; 0 turns either off
+	IdleNs      int64 `json:"idle_ns,omitempty"`
+	WarmupNs    int64 `json:"warmup_ns,omitempty"`
+	HandshakeNs int64 `json:"handshake_ns,omitempty"`
+
+	// MarketDataNs is how long BBO updates and trade prints take to reach
+	// the trader; 0 uses its base latency. Only strategies with a
+	// market-data rule on are sent them
+	MarketDataNs int64 `json:"market_data_ns,omitempty"`
+
+	// Signal access. SignalNoise is the standard deviation of Gaussian
+	// noise added to each signal this trader sees; 0 sees the true value
+	// SignalDelayNs delivers signals late, and NoSignal hides their value
+	// (the trader still hears that one fired)
+	SignalNoise   float64 `json:"signal_noise,omitempty"`
+	SignalDelayNs int64   `json:"signal_delay_ns,omitempty"`
+	NoSignal      bool    `json:"no_signal,omitempty"`
+
+	// Strategy overrides the strategy's defaults for this trader
+	Strategy StrategyParams `json:"strategy,omitzero"`
+}
+
+// SignalAccess describes the trader's signal access, e.g. "full" or
+// "delayed 20ms, noise σ=0.3"
+func (tc TraderConfig) SignalAccess() string {
+	if tc.NoSignal {
+		return "none"
+	}
+	var parts []string
+	if tc.SignalDelayNs > 0 {
+		parts = append(parts, "delayed "+latency.Format(tc.SignalDelayNs))
+	}
+	if tc.SignalNoise > 0 {
+		parts = append(parts, "noise σ="+strconv.FormatFloat(tc.SignalNoise, 'g', -1, 64))
+	}
+	if len(parts) == 0 {
+		return "full"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sameSignalAccess reports whether two traders see signals identically
+func (tc TraderConfig) sameSignalAccess(o TraderConfig) bool {
+	if tc.NoSignal || o.NoSignal {
+		return tc.NoSignal == o.NoSignal
+	}
+	return tc.SignalNoise == o.SignalNoise && tc.SignalDelayNs == o.SignalDelayNs
+}
+
+// WithSignalAccess returns tc with o's signal access
+func (tc TraderConfig) WithSignalAccess(o TraderConfig) TraderConfig {
+	tc.SignalNoise, tc.SignalDelayNs, tc.NoSignal = o.SignalNoise, o.SignalDelayNs, o.NoSignal
+	return tc
+}
+
+// WithLatency returns tc with o's latency, gateway, connection warm-up
+// and market-data feed included
+func (tc TraderConfig) WithLatency(o TraderConfig) TraderConfig {
+	tc.BaseLatencyMs, tc.JitterMs, tc.BaseLatencyUs, tc.JitterUs = o.BaseLatencyMs, o.JitterMs, o.BaseLatencyUs, o.JitterUs
+	tc.GatewayNs, tc.MarketDataNs = o.GatewayNs, o.MarketDataNs
+	tc.IdleNs, tc.WarmupNs, tc.HandshakeNs = o.IdleNs, o.WarmupNs, o.HandshakeNs
+	return tc
+}
+
+// HasConnection reports whether the trader's messages pay a handshake or
+// warm-up
+func (tc TraderConfig) HasConnection() bool {
+	return tc.HandshakeNs > 0 || (tc.IdleNs > 0 && tc.WarmupNs > 0)
+}
+
+// connection is the trader's warm-up settings, zero when it has none
+func (tc TraderConfig) connection() [3]int64 {
+	if !tc.HasConnection() {
+		return [3]int64{}
+	}
+	return [3]int64{tc.IdleNs, tc.WarmupNs, tc.HandshakeNs}
+}
+
+// MarketDataLatencyNs is how long market data takes to reach the trader
+func (tc TraderConfig) MarketDataLatencyNs() int64 {
+	if tc.MarketDataNs > 0 {
+		return tc.MarketDataNs
+	}
+	return tc.BaseLatencyNs()
+}
+
+// BaseLatencyNs returns the total base latency in nanoseconds
+func (tc TraderConfig) BaseLatencyNs() int64 {
+	return latency.MsToNs(tc.BaseLatencyMs) + latency.UsToNs(tc.BaseLatencyUs)
+}
+
+// JitterNs returns the total jitter bound in nanoseconds
+func (tc TraderConfig) JitterNs() int64 {
+	return latency.MsToNs(tc.JitterMs) + latency.UsToNs(tc.JitterUs)
+}
+
+// SetLatency sets base latency and jitter from durations. Whole
+// milliseconds go in the ms fields, anything finer in the µs fields
+func (tc *TraderConfig) SetLatency(base, jitter time.Duration) error {
+	if base < 0 || jitter < 0 {
+		return fmt.Errorf("latency must be non-negative")
+	}
+	if base%time.Microsecond != 0 || jitter%time.Microsecond != 0 {
+		return fmt.Errorf("latency must be a whole number of microseconds")
+	}
+	tc.BaseLatencyMs, tc.BaseLatencyUs = splitMs(base)
+	tc.JitterMs, tc.JitterUs = splitMs(jitter)
+	return nil
+}
+
+func splitMs(d time.Duration) (ms, us int64) {
+	if d%time.Millisecond == 0 {
+		return int64(d / time.Millisecond), 0
+	}
+	return 0, int64(d / time.Microsecond)
 }
 
 // ScenarioParams holds background order flow parameters
 type ScenarioParams struct {
-	InitialMidPrice     int64   `json:"initial_mid_price"`    // fixed-point
-	InitialSpread       int64   `json:"initial_spread"`       // fixed-point
-	OrderIntervalNs     int64   `json:"order_interval_ns"`    // mean inter-arrival
-	MarketOrderRatio    float64 `json:"market_order_ratio"`   // fraction of orders that are market
-	CancelRate          float64 `json:"cancel_rate"`          // probability of cancel per interval
-	MinOrderSize        int64   `json:"min_order_size"`
-	MaxOrderSize        int64   `json:"max_order_size"`
-	PriceTickSize       int64   `json:"price_tick_size"`
-	MaxPriceLevels      int     `json:"max_price_levels"`     // how many levels to populate
-	SignalIntervalNs    int64   `json:"signal_interval_ns"`   // how often signals fire
+	InitialMidPrice  int64   `json:"initial_mid_price"`  // fixed-point
+	InitialSpread    int64   `json:"initial_spread"`     // fixed-point
+	OrderIntervalNs  int64   `json:"order_interval_ns"`  // mean inter-arrival
+	MarketOrderRatio float64 `json:"market_order_ratio"` // fraction of orders that are market
+	CancelRate       float64 `json:"cancel_rate"`        // probability of cancel per interval
+	MinOrderSize     int64   `json:"min_order_size"`
+	MaxOrderSize     int64   `json:"max_order_size"`
+	PriceTickSize    int64   `json:"price_tick_size"`
+	MaxPriceLevels   int     `json:"max_price_levels"`   // how many levels to populate
+	SignalIntervalNs int64   `json:"signal_interval_ns"` // how often signals fire
+
+	// SignalInformation is how far a unit signal tilts background market
+	// orders its way: P(buy) = 0.5 + 0.5*information*strength, clamped
+	// 0 makes signals pure noise. Strength halves every SignalHalfLifeNs
+	SignalInformation float64 `json:"signal_information,omitempty"`
+	SignalHalfLifeNs  int64   `json:"signal_half_life_ns,omitempty"`
+
+	// SignalFlowWindowNs is how far back a signal's trade flow looks;
+	// 0 means one signal interval
+	SignalFlowWindowNs int64 `json:"signal_flow_window_ns,omitempty"`
 
 	// Thin-book specific
 	DepthPerLevel int64 `json:"depth_per_level,omitempty"`
@@ -54,10 +334,23 @@ type ScenarioParams struct {
 	BurstMarketCap  float64 `json:"burst_market_cap,omitempty"` // max market ratio during bursts
 }
 
+// FlowWindowNs is the trade flow window in effect
+func (p ScenarioParams) FlowWindowNs() int64 {
+	if p.SignalFlowWindowNs > 0 {
+		return p.SignalFlowWindowNs
+	}
+	return p.SignalIntervalNs
+}
+
 // Generator produces background order flow events
 type Generator interface {
 	// Generate returns all background events for the scenario duration
 	Generate() []*domain.Event
+
+	// Stream produces the same events in the same order, handing them to
+	// emit in batches as they are generated. A batch never goes before
+	// the latest event of the one before it. emit owns each batch
+	Stream(emit func([]*domain.Event))
 }
 
 // DefaultCalm returns the default configuration for a calm market scenario
@@ -88,6 +381,9 @@ func DefaultCalm(seed int64) *Config {
 			MaxPriceLevels:   5,
 			SignalIntervalNs: latency.MsToNs(200),
 			DepthPerLevel:    20,
+
+			SignalInformation: 0.5,
+			SignalHalfLifeNs:  latency.MsToNs(100),
 		},
 	}
 }
@@ -120,6 +416,9 @@ func DefaultThin(seed int64) *Config {
 			MaxPriceLevels:   3,
 			SignalIntervalNs: latency.MsToNs(200),
 			DepthPerLevel:    5,
+
+			SignalInformation: 0.5,
+			SignalHalfLifeNs:  latency.MsToNs(100),
 		},
 	}
 }
@@ -160,6 +459,9 @@ func DefaultSpike(seed int64) *Config {
 			BurstSizeMul:     2.0,
 			BurstCancelCap:   0.5,
 			BurstMarketCap:   0.6,
+
+			SignalInformation: 0.5,
+			SignalHalfLifeNs:  latency.MsToNs(75),
 		},
 	}
 }
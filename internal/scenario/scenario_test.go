@@ -1,9 +1,19 @@
 package scenario
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
 )
 
 func TestCalmGeneratorReproducibility(t *testing.T) {
@@ -40,8 +50,8 @@ func TestGeneratorsProduceNonEmptyFlow(t *testing.T) {
 		// Check we have both buy and sell orders
 		var buys, sells int
 		for _, e := range events {
-			if e.Order != nil && e.Order.Type != domain.CancelOrder {
-				if e.Order.Side == domain.Buy {
+			if e.Order() != nil && e.Order().Type != domain.CancelOrder {
+				if e.Order().Side == domain.Buy {
 					buys++
 				} else {
 					sells++
@@ -99,11 +109,19 @@ func TestSpikeGeneratorHasBurstPeriods(t *testing.T) {
 		return false
 	}
 
-	var burstCount, normalCount int
+	var burstCount, normalCount, tagged int
 	for _, e := range events {
 		if e.Timestamp == 0 {
 			continue // skip initial book
 		}
+		if id, ok := e.Annotation("burst_id"); ok {
+			// Tagged by the burst its arrival slot falls in; jitter may push it past the window
+			tagged++
+			n, _ := strconv.ParseInt(id, 10, 64)
+			if start := n * p.BurstIntervalNs; n < 1 || e.Timestamp < start || e.Timestamp >= start+p.BurstIntervalNs {
+				t.Errorf("event at %d tagged burst %q", e.Timestamp, id)
+			}
+		}
 		if inBurst(e.Timestamp) {
 			burstCount++
 		} else {
@@ -118,4 +136,541 @@ func TestSpikeGeneratorHasBurstPeriods(t *testing.T) {
 	if normalCount == 0 {
 		t.Error("no events outside burst windows")
 	}
+	if tagged == 0 {
+		t.Error("no events tagged with burst_id")
+	}
+}
+
+// TestGeneratorsMatchStableSort verifies the streaming merge yields exactly
+// the order a stable timestamp sort would, including tie-breaks
+func TestGeneratorsMatchStableSort(t *testing.T) {
+	for _, name := range []string{"calm", "thin", "spike"} {
+		for seed := int64(1); seed <= 5; seed++ {
+			events := NewGenerator(GetConfig(name, seed)).Generate()
+			sorted := append([]*domain.Event(nil), events...)
+			sort.SliceStable(sorted, func(i, j int) bool {
+				return sorted[i].Timestamp < sorted[j].Timestamp
+			})
+			for i := range events {
+				if events[i] != sorted[i] {
+					t.Fatalf("%s seed %d: event %d differs from stable sort order", name, seed, i)
+				}
+			}
+		}
+	}
+}
+
+func TestStreamMatchesGenerate(t *testing.T) {
+	for _, name := range []string{"calm", "thin", "spike"} {
+		want := NewGenerator(GetConfig(name, 3)).Generate()
+		var got []*domain.Event
+		NewGenerator(GetConfig(name, 3)).Stream(func(batch []*domain.Event) {
+			if len(batch) == 0 || len(batch) > streamBatch {
+				t.Fatalf("%s: bad batch size %d", name, len(batch))
+			}
+			got = append(got, batch...)
+		})
+		if len(got) != len(want) {
+			t.Fatalf("%s: streamed %d events, generated %d", name, len(got), len(want))
+		}
+		for i := range want {
+			if got[i].Timestamp != want[i].Timestamp || got[i].Type != want[i].Type ||
+				(got[i].Order() == nil) != (want[i].Order() == nil) ||
+				(got[i].Order() != nil && *got[i].Order() != *want[i].Order()) {
+				t.Fatalf("%s: event %d differs", name, i)
+			}
+		}
+	}
+}
+
+func TestTraderConfigSetLatency(t *testing.T) {
+	var tc TraderConfig
+	if err := tc.SetLatency(150*time.Microsecond, 1500*time.Microsecond); err != nil {
+		t.Fatal(err)
+	}
+	if tc.BaseLatencyNs() != 150_000 || tc.JitterNs() != 1_500_000 {
+		t.Errorf("latency %d/%d ns, want 150000/1500000", tc.BaseLatencyNs(), tc.JitterNs())
+	}
+	if tc.BaseLatencyMs != 0 || tc.BaseLatencyUs != 150 || tc.JitterMs != 0 || tc.JitterUs != 1500 {
+		t.Errorf("fields = %+v", tc)
+	}
+
+	if err := tc.SetLatency(2*time.Millisecond, 0); err != nil {
+		t.Fatal(err)
+	}
+	if tc.BaseLatencyMs != 2 || tc.BaseLatencyUs != 0 || tc.JitterNs() != 0 {
+		t.Errorf("whole ms should use the ms fields: %+v", tc)
+	}
+
+	if err := tc.SetLatency(1500*time.Nanosecond, 0); err == nil {
+		t.Error("sub-microsecond latency accepted")
+	}
+	if err := tc.SetLatency(-time.Millisecond, 0); err == nil {
+		t.Error("negative latency accepted")
+	}
+}
+
+// TestDefaultConfigJSONUnchanged guards the config.json shape: the µs
+// fields are omitted unless used
+func TestDefaultConfigJSONUnchanged(t *testing.T) {
+	data, err := json.Marshal(DefaultCalm(1).FastTrader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "_us") {
+		t.Errorf("default trader config has µs fields: %s", data)
+	}
+}
+
+// TestInformedFlowFollowsSignal checks background market orders lean the
+// way of the latest signal when it carries information, and not without
+func TestInformedFlowFollowsSignal(t *testing.T) {
+	lean := func(information float64) float64 {
+		cfg := DefaultCalm(3)
+		cfg.Duration = latency.MsToNs(60_000)
+		cfg.Scenario.SignalInformation = information
+		cfg.Scenario.SignalHalfLifeNs = 0
+		var last float64
+		var with, total int
+		for _, e := range NewGenerator(cfg).Generate() {
+			if s := e.Signal(); s != nil {
+				last = s.Value
+			}
+			o := e.Order()
+			if o == nil || o.Type != domain.MarketOrder || last == 0 {
+				continue
+			}
+			total++
+			if (o.Side == domain.Buy) == (last > 0) {
+				with++
+			}
+		}
+		return float64(with) / float64(total)
+	}
+	if got := lean(0); got < 0.45 || got > 0.55 {
+		t.Errorf("uninformed flow follows the signal %.2f of the time, want about half", got)
+	}
+	if got := lean(2); got < 0.7 {
+		t.Errorf("informed flow follows the signal %.2f of the time, want > 0.7", got)
+	}
+}
+
+func TestSignalStrengthDecays(t *testing.T) {
+	s := domain.Signal{Value: 0.8, HalfLifeNs: 100}
+	if s.Strength(0) != 0.8 || s.Strength(100) != 0.4 || s.Strength(300) != 0.1 {
+		t.Errorf("strength at 0, 100, 300 = %v, %v, %v", s.Strength(0), s.Strength(100), s.Strength(300))
+	}
+	s.HalfLifeNs = 0
+	if s.Strength(1_000_000) != 0.8 {
+		t.Error("signal without a half-life decayed")
+	}
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	for _, name := range []string{"calm", "thin", "spike"} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				NewGenerator(GetConfig(name, 42)).Generate()
+			}
+		})
+	}
+}
+
+func TestRescale(t *testing.T) {
+	cfg := DefaultCalm(42)
+	if err := cfg.Rescale(100_000_000); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Scale() != 100_000_000 {
+		t.Fatalf("scale = %d", cfg.Scale())
+	}
+	if got := cfg.Scale().Format(cfg.Scenario.InitialMidPrice); got != "100.00000000" {
+		t.Errorf("mid = %s", got)
+	}
+	if got := cfg.Scale().Format(cfg.Scenario.PriceTickSize); got != "0.01000000" {
+		t.Errorf("tick = %s", got)
+	}
+
+	// Cents survive a downscale to 2 decimals; a sub-cent tick does not
+	if err := cfg.Rescale(100); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Scenario.InitialSpread != 2 {
+		t.Errorf("spread = %d, want 2", cfg.Scenario.InitialSpread)
+	}
+	cfg.Scenario.PriceTickSize = 1
+	before := *cfg
+	if err := cfg.Rescale(1); err == nil {
+		t.Fatal("lossy rescale accepted")
+	}
+	if cfg.Scenario != before.Scenario || cfg.PriceScale != before.PriceScale {
+		t.Error("failed rescale modified the config")
+	}
+	if err := cfg.Rescale(250); err == nil {
+		t.Error("non power of ten scale accepted")
+	}
+}
+
+func TestSignalAccess(t *testing.T) {
+	cfg := DefaultCalm(1)
+	if cfg.InformationAsymmetric() || !cfg.LatencyAsymmetric() {
+		t.Fatal("default calm should differ in latency only")
+	}
+	cfg.SlowTrader.SignalDelayNs = latency.MsToNs(20)
+	cfg.SlowTrader.SignalNoise = 0.3
+	if !cfg.InformationAsymmetric() {
+		t.Error("delayed, noisy slow trader not asymmetric")
+	}
+	if got := cfg.SlowTrader.SignalAccess(); got != "delayed 20 ms, noise σ=0.3" {
+		t.Errorf("SignalAccess() = %q", got)
+	}
+
+	cfg.SlowTrader = cfg.SlowTrader.WithSignalAccess(cfg.FastTrader).WithLatency(cfg.FastTrader)
+	if cfg.InformationAsymmetric() || cfg.LatencyAsymmetric() || cfg.SlowTrader.ID != "slow" {
+		t.Errorf("equalized slow trader = %+v", cfg.SlowTrader)
+	}
+	cfg.FastTrader.NoSignal, cfg.FastTrader.SignalNoise = true, 1
+	if cfg.FastTrader.SignalAccess() != "none" || !cfg.InformationAsymmetric() {
+		t.Errorf("no-signal trader: %q", cfg.FastTrader.SignalAccess())
+	}
+}
+
+// TestDurationStrings checks duration fields accept Go duration strings
+// as well as nanoseconds, and that configs still round-trip as integers
+func TestDurationStrings(t *testing.T) {
+	spike := DefaultSpike(3)
+	data, err := json.Marshal(spike)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Config
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&back, spike) {
+		t.Errorf("round trip changed config:\n  got  %+v\n  want %+v", back, *spike)
+	}
+
+	in := `{"duration_ns": "10s", "slow_trader": {"signal_delay_ns": "250us"},
+		"scenario": {"order_interval_ns": "1.5ms", "signal_interval_ns": 200000000,
+		"signal_half_life_ns": "75ms", "signal_flow_window_ns": "50ms", "burst_window_ns": "500ms", "burst_interval_ns": "2s"}}`
+	var cfg Config
+	if err := json.Unmarshal([]byte(in), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	got := []int64{cfg.Duration, cfg.SlowTrader.SignalDelayNs, cfg.Scenario.OrderIntervalNs,
+		cfg.Scenario.SignalIntervalNs, cfg.Scenario.SignalHalfLifeNs, cfg.Scenario.SignalFlowWindowNs,
+		cfg.Scenario.BurstWindowNs, cfg.Scenario.BurstIntervalNs}
+	want := []int64{10_000_000_000, 250_000, 1_500_000, 200_000_000, 75_000_000, 50_000_000, 500_000_000, 2_000_000_000}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("durations = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{`{"duration_ns": "10"}`, `{"duration_ns": "-1s"}`, `{"scenario": {"burst_window_ns": "soon"}}`} {
+		if err := json.Unmarshal([]byte(bad), &cfg); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	cfg, err := LoadConfig(write("short.json", `{"name": "spike", "seed": 9, "duration_ns": "3s",
+		"scenario": {"burst_window_ns": "250ms"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultSpike(9)
+	want.Duration = latency.MsToNs(3_000)
+	want.Scenario.BurstWindowNs = latency.MsToNs(250)
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("loaded %+v, want defaults with overrides %+v", *cfg, *want)
+	}
+
+	if _, err := LoadConfig(write("unknown.json", `{"name": "choppy"}`)); err == nil {
+		t.Error("unknown scenario accepted")
+	}
+	if _, err := LoadConfig(write("typo.json", `{"name": "calm", "duraton_ns": "1s"}`)); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
+
+func TestVenueConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "venue.json")
+	body := `{"name": "calm", "venue": {"matching": "pro_rata", "speed_bump_ns": "5ms", "min_resting_ns": 1000}}`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Venue{Matching: MatchProRata, SpeedBumpNs: latency.MsToNs(5), MinRestingNs: 1000}
+	if !reflect.DeepEqual(cfg.Venue, want) {
+		t.Errorf("venue = %+v, want %+v", cfg.Venue, want)
+	}
+	if err := (Venue{Matching: "lottery"}).Validate(); err == nil {
+		t.Error("unknown matching rule accepted")
+	}
+	if err := (Venue{SpeedBumpNs: -1}).Validate(); err == nil {
+		t.Error("negative speed bump accepted")
+	}
+	for _, m := range Mechanisms() {
+		if err := m.Venue.Validate(); err != nil {
+			t.Errorf("%s: %v", m.Name, err)
+		}
+	}
+}
+
+// TestMechanismSpecs checks listed venue mechanisms read the same from a
+// config file and from the --mechanism flag, and survive config.json
+func TestMechanismSpecs(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`{"name": "calm", "venue": {"mechanisms": [
+		{"type": "speed_bump", "delay": "10ms"},
+		{"type": "throttle", "max": 5, "window": "1ms"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flags []MechanismSpec
+	for _, s := range []string{"speed_bump:delay=10ms", "throttle:window=1ms, max=5"} {
+		spec, err := ParseMechanismSpec(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		flags = append(flags, spec)
+	}
+	if !reflect.DeepEqual(cfg.Venue.Mechanisms, flags) {
+		t.Errorf("config %v, flags %v", cfg.Venue.Mechanisms, flags)
+	}
+	if got := flags[1].String(); got != "throttle:max=5,window=1ms" {
+		t.Errorf("String() = %q", got)
+	}
+
+	data, err := json.Marshal(cfg.Venue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Venue
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, cfg.Venue) {
+		t.Errorf("round trip %s gave %+v", data, back)
+	}
+
+	for _, bad := range []string{"", ":delay=1ms", "speed_bump:delay", "speed_bump:delay=1ms,delay=2ms"} {
+		if _, err := ParseMechanismSpec(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+	if _, err := ParseConfig([]byte(`{"name": "calm", "venue": {"mechanisms": [{"delay": "1ms"}]}}`)); err == nil {
+		t.Error("mechanism without a type accepted")
+	}
+}
+
+// TestStrategyParams checks a strategy A/B loads from a config and parses
+// from the command line form
+func TestStrategyParams(t *testing.T) {
+	body := `{"name": "calm", "slow_trader": {"base_latency_ms": 1, "jitter_ms": 0,
+		"strategy": {"requote_interval_ns": "50ms", "cross_threshold": 0.5}}}`
+	cfg, err := ParseConfig([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := StrategyParams{ReQuoteIntervalNs: latency.MsToNs(50), CrossThreshold: 0.5}
+	if cfg.SlowTrader.Strategy != want || !cfg.FastTrader.Strategy.IsZero() {
+		t.Errorf("strategies = %+v / %+v", cfg.FastTrader.Strategy, cfg.SlowTrader.Strategy)
+	}
+	if !cfg.StrategyAsymmetric() || cfg.LatencyAsymmetric() {
+		t.Error("want a strategy-only A/B")
+	}
+	if got := cfg.SlowTrader.Strategy.String(); got != "requote every 50 ms, cross ±0.5" {
+		t.Errorf("String = %q", got)
+	}
+
+	p, err := ParseStrategyParams("cross_threshold=0.5, requote_interval_ns=50ms")
+	if err != nil || p != want {
+		t.Errorf("ParseStrategyParams = %+v, %v", p, err)
+	}
+	if p, err := ParseStrategyParams("display_qty=2"); err != nil || p.DisplayQty != 2 || p.String() != "show 2" {
+		t.Errorf("ParseStrategyParams(display_qty) = %+v, %v", p, err)
+	}
+	for _, bad := range []string{"cross=1", "target_qty", "target_qty=-1", "cancel_timeout_ns=soon",
+		"display_qty=5", "target_qty=3, display_qty=4"} {
+		if _, err := ParseStrategyParams(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+	if _, err := ParseConfig([]byte(`{"name": "calm", "fast_trader": {"strategy": {"qty": 3}}}`)); err == nil {
+		t.Error("unknown strategy param accepted")
+	}
+}
+
+// TestGuards checks guards load from a config and parse from the command
+// line form
+func TestGuards(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`{"name": "thin", "guards": {"one_sided_ns": "500ms", "max_backlog": 1000}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Guards{OneSidedNs: latency.MsToNs(500), MaxBacklog: 1000}
+	if cfg.Guards != want {
+		t.Errorf("guards = %+v", cfg.Guards)
+	}
+	if !GetConfig("thin", 1).Guards.IsZero() {
+		t.Error("guards on by default")
+	}
+
+	g, err := ParseGuards("max_backlog=1000, one_sided_ns=500ms")
+	if err != nil || g != want {
+		t.Errorf("ParseGuards = %+v, %v", g, err)
+	}
+	for _, bad := range []string{"idle=1s", "no_trade_ns", "max_backlog=-1", "no_trade_ns=later"} {
+		if _, err := ParseGuards(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+	if _, err := ParseConfig([]byte(`{"name": "calm", "guards": {"idle_ns": 5}}`)); err == nil {
+		t.Error("unknown guard accepted")
+	}
+}
+
+func TestSessions(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`{"name": "calm", "seed": 3, "sessions": [
+		{"duration_ns": "1s", "open_auction_ns": "100ms", "close_auction_ns": "50ms"},
+		{"name": "tue", "duration_ns": "2s", "gap_bps": -25}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Duration != latency.MsToNs(3_000) {
+		t.Errorf("duration %d, want the sessions' 3s", cfg.Duration)
+	}
+	if err := cfg.ValidateSessions(); err != nil {
+		t.Fatal(err)
+	}
+	plan := cfg.SessionPlan()
+	want := []SessionSpan{
+		{Index: 0, Name: "day1", Start: 0, OpenEnd: latency.MsToNs(100), CloseStart: latency.MsToNs(950),
+			End: latency.MsToNs(1_000), RefPrice: 1_000_000},
+		{Index: 1, Name: "tue", Start: latency.MsToNs(1_000), OpenEnd: latency.MsToNs(1_000), CloseStart: latency.MsToNs(3_000),
+			End: latency.MsToNs(3_000), RefPrice: 997_500},
+	}
+	if len(plan) != len(want) || plan[0] != want[0] || plan[1] != want[1] {
+		t.Errorf("plan = %+v", plan)
+	}
+	if p := plan[0].Phase(latency.MsToNs(960)); p != domain.PhaseCloseAuction {
+		t.Errorf("phase at 960ms = %s", p)
+	}
+
+	// Each session's flow stays inside it, in order, and IDs never repeat
+	events := NewGenerator(cfg).Generate()
+	ids := make(map[uint64]bool)
+	for i, e := range events {
+		if i > 0 && e.Timestamp < events[i-1].Timestamp {
+			t.Fatalf("event %d out of order", i)
+		}
+		if e.Timestamp >= cfg.Duration {
+			t.Fatalf("event at %d, after the last close", e.Timestamp)
+		}
+		if o := e.Order(); o != nil {
+			if ids[o.ID] {
+				t.Fatalf("order ID %d reused", o.ID)
+			}
+			ids[o.ID] = true
+		}
+	}
+	var streamed []*domain.Event
+	NewGenerator(cfg).Stream(func(batch []*domain.Event) { streamed = append(streamed, batch...) })
+	if len(streamed) != len(events) || streamed[len(streamed)-1].Timestamp != events[len(events)-1].Timestamp {
+		t.Error("Stream differs from Generate")
+	}
+
+	for _, bad := range []string{
+		`{"name": "calm", "sessions": [{"duration_ns": "1s", "open_auction_ns": "600ms", "close_auction_ns": "400ms"}]}`,
+		`{"name": "calm", "sessions": [{"duration_ns": "1s", "gap_bps": -10000}]}`,
+		`{"name": "calm", "duration_ns": "2s", "sessions": [{"duration_ns": "1s"}]}`,
+	} {
+		cfg, err := ParseConfig([]byte(bad))
+		if err == nil {
+			err = cfg.ValidateSessions()
+		}
+		if err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+	if _, err := ParseConfig([]byte(`{"name": "calm", "sessions": [{"duration_ns": "1s", "lunch_ns": 5}]}`)); err == nil {
+		t.Error("unknown session field accepted")
+	}
+}
+
+func TestBackgroundPopulation(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`{"name": "calm", "seed": 5, "duration_ns": "3s", "background": [
+		{"name": "maker", "count": 2, "weight": 3, "market_order_ratio": 0, "cancel_rate": 0.2},
+		{"name": "taker", "weight": 1, "market_order_ratio": 1, "cancel_rate": 0}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ValidateBackground(); err != nil {
+		t.Fatal(err)
+	}
+	ids := cfg.BackgroundIDs()
+	if !slices.Equal(ids, []string{"background/maker-1", "background/maker-2", "background/taker"}) {
+		t.Fatalf("IDs = %v", ids)
+	}
+
+	// Makers never take, the taker never rests, and a cancel only ever
+	// targets its sender's own order
+	owner := make(map[uint64]string)
+	counts := make(map[string]int)
+	for _, e := range NewGenerator(cfg).Generate() {
+		o := e.Order()
+		if o == nil {
+			continue
+		}
+		if !domain.IsBackground(o.TraderID) || o.TraderID == domain.BackgroundTrader {
+			t.Fatalf("order from %q", o.TraderID)
+		}
+		counts[o.TraderID]++
+		switch {
+		case o.Type == domain.MarketOrder && o.TraderID != "background/taker":
+			t.Errorf("%s sent a market order", o.TraderID)
+		case o.Type == domain.LimitOrder && o.TraderID == "background/taker":
+			t.Error("the taker rested an order")
+		case o.Type == domain.CancelOrder && owner[o.CancelID] != o.TraderID:
+			t.Errorf("%s canceled %s's order", o.TraderID, owner[o.CancelID])
+		}
+		owner[o.ID] = o.TraderID
+	}
+	// Each maker sends three times the taker's arrivals; the initial book
+	// is all makers'
+	if counts["background/taker"] == 0 || counts["background/maker-1"] < 2*counts["background/taker"] {
+		t.Errorf("counts = %v", counts)
+	}
+
+	for _, bad := range []string{
+		`[{"name": "a/b", "weight": 1}]`,
+		`[{"name": "a", "weight": 0}]`,
+		`[{"name": "a", "weight": 1, "market_order_ratio": 0.7, "cancel_rate": 0.4}]`,
+		`[{"name": "a", "weight": 1}, {"name": "a", "weight": 2}]`,
+	} {
+		cfg, err := ParseConfig([]byte(`{"name": "calm", "background": ` + bad + `}`))
+		if err == nil {
+			err = cfg.ValidateBackground()
+		}
+		if err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+	if _, err := ParseConfig([]byte(`{"name": "calm", "background": [{"name": "a", "wieght": 1}]}`)); err == nil {
+		t.Error("unknown participant field accepted")
+	}
 }
@@ -0,0 +1,193 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// Session is one trading session of a multi-session run. Sessions run
+// back to back from t=0: each may open and close with a call auction,
+// day orders expire at its close, and the reference price the background
+// flow quotes around moves by GapBps overnight before it opens
+type Session struct {
+	Name           string  `json:"name,omitempty"` // default "day1", "day2", ...
+	DurationNs     int64   `json:"duration_ns"`
+	OpenAuctionNs  int64   `json:"open_auction_ns,omitempty"`
+	CloseAuctionNs int64   `json:"close_auction_ns,omitempty"`
+	GapBps         float64 `json:"gap_bps,omitempty"`
+}
+
+// UnmarshalJSON accepts duration strings for the session's durations and
+// rejects unknown fields
+func (s *Session) UnmarshalJSON(data []byte) error {
+	type plain Session
+	aux := struct {
+		*plain
+		DurationNs     nanos `json:"duration_ns"`
+		OpenAuctionNs  nanos `json:"open_auction_ns"`
+		CloseAuctionNs nanos `json:"close_auction_ns"`
+	}{
+		plain:          (*plain)(s),
+		DurationNs:     nanos{&s.DurationNs},
+		OpenAuctionNs:  nanos{&s.OpenAuctionNs},
+		CloseAuctionNs: nanos{&s.CloseAuctionNs},
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(&aux)
+}
+
+// SessionSpan is a session laid out on the run's clock. Its phases are
+// the open auction [Start, OpenEnd), continuous trading [OpenEnd,
+// CloseStart) and the close auction [CloseStart, End)
+type SessionSpan struct {
+	Index                           int
+	Name                            string
+	Start, OpenEnd, CloseStart, End int64
+	RefPrice                        int64 // the background flow's mid after the gap
+}
+
+// Phase returns the phase in force at t, which must fall in the span
+func (s SessionSpan) Phase(t int64) string {
+	switch {
+	case t < s.OpenEnd:
+		return domain.PhaseOpenAuction
+	case t < s.CloseStart:
+		return domain.PhaseContinuous
+	default:
+		return domain.PhaseCloseAuction
+	}
+}
+
+// SessionsDuration is the total length of the configured sessions
+func (c *Config) SessionsDuration() int64 {
+	var total int64
+	for _, s := range c.Sessions {
+		total += s.DurationNs
+	}
+	return total
+}
+
+// ValidateSessions checks each session's phases fit inside it and that
+// the sessions fill the run's duration exactly
+func (c *Config) ValidateSessions() error {
+	for i, s := range c.Sessions {
+		switch {
+		case s.DurationNs <= 0:
+			return fmt.Errorf("session %d: duration must be positive", i+1)
+		case s.OpenAuctionNs < 0 || s.CloseAuctionNs < 0:
+			return fmt.Errorf("session %d: auction lengths must not be negative", i+1)
+		case s.OpenAuctionNs+s.CloseAuctionNs >= s.DurationNs:
+			return fmt.Errorf("session %d: auctions leave no continuous trading", i+1)
+		case s.GapBps <= -10_000:
+			return fmt.Errorf("session %d: gap of %g bps leaves no price", i+1, s.GapBps)
+		}
+	}
+	if len(c.Sessions) > 0 && c.SessionsDuration() != c.Duration {
+		return fmt.Errorf("sessions last %d ns but the run lasts %d ns", c.SessionsDuration(), c.Duration)
+	}
+	return nil
+}
+
+// SessionPlan lays the sessions out end to end from t=0. Each session's
+// reference price is the previous one's moved by its gap, rounded to the
+// tick; the first starts from the scenario's initial mid. Nil without
+// sessions
+func (c *Config) SessionPlan() []SessionSpan {
+	if len(c.Sessions) == 0 {
+		return nil
+	}
+	spans := make([]SessionSpan, len(c.Sessions))
+	var start int64
+	ref := c.Scenario.InitialMidPrice
+	for i, s := range c.Sessions {
+		if s.GapBps != 0 {
+			tick := max(c.Scenario.PriceTickSize, 1)
+			ref = int64(math.Round(float64(ref)*(1+s.GapBps/10_000)/float64(tick))) * tick
+		}
+		name := s.Name
+		if name == "" {
+			name = fmt.Sprintf("day%d", i+1)
+		}
+		end := start + s.DurationNs
+		spans[i] = SessionSpan{
+			Index:      i,
+			Name:       name,
+			Start:      start,
+			OpenEnd:    start + s.OpenAuctionNs,
+			CloseStart: end - s.CloseAuctionNs,
+			End:        end,
+			RefPrice:   ref,
+		}
+		start = end
+	}
+	return spans
+}
+
+// HasAuctions reports whether any session opens or closes with an auction
+func (c *Config) HasAuctions() bool {
+	for _, s := range c.Sessions {
+		if s.OpenAuctionNs > 0 || s.CloseAuctionNs > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionGenerator runs the scenario's generator once per session, around
+// the session's reference price, and shifts its events to the session's
+// start. Session 0 draws from the run's seed, so a single session without
+// a gap generates exactly the flow of a plain run
+type sessionGenerator struct {
+	cfg *Config
+}
+
+func (g *sessionGenerator) Generate() []*domain.Event {
+	var events []*domain.Event
+	g.Stream(func(batch []*domain.Event) {
+		events = append(events, batch...)
+	})
+	return events
+}
+
+func (g *sessionGenerator) Stream(emit func([]*domain.Event)) {
+	var nextID uint64
+	for _, span := range g.cfg.SessionPlan() {
+		c := *g.cfg
+		c.Sessions = nil
+		c.Duration = span.End - span.Start
+		c.Scenario.InitialMidPrice = span.RefPrice
+		c.Seed = sessionSeed(g.cfg.Seed, span.Index)
+		gen := newFlowGenerator(&c)
+		// Order IDs run on across sessions
+		if nextID > 0 {
+			gen.base().nextID = nextID
+		}
+		// The generator checks each event against the last one it added, so
+		// a batch is shifted only once the next has been produced
+		var pending []*domain.Event
+		flush := func() {
+			for _, e := range pending {
+				e.Timestamp += span.Start
+			}
+			if len(pending) > 0 {
+				emit(pending)
+			}
+		}
+		gen.Stream(func(batch []*domain.Event) {
+			flush()
+			pending = batch
+		})
+		flush()
+		nextID = gen.base().nextID
+	}
+}
+
+// sessionSeed is the generator seed for session i
+func sessionSeed(seed int64, i int) int64 {
+	return seed + int64(i)*1_000_003
+}
@@ -0,0 +1,158 @@
+package scenario
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+)
+
+// StrategyParams tune a trader's strategy. Zero fields keep the strategy's
+// defaults, so giving the two traders different params, and the same
+// latency, makes a strategy A/B test on one shared background flow
+type StrategyParams struct {
+	ReQuoteIntervalNs int64   `json:"requote_interval_ns,omitempty"`
+	CancelTimeoutNs   int64   `json:"cancel_timeout_ns,omitempty"`
+	CrossThreshold    float64 `json:"cross_threshold,omitempty"`  // |signal| above which it crosses the spread
+	CrossTIF          string  `json:"cross_tif,omitempty"`        // ioc or fok: cross with a limit at the far touch instead of a market order
+	ReduceThreshold   float64 `json:"reduce_threshold,omitempty"` // |signal| against a quote above which it halves it
+	TargetQty         int64   `json:"target_qty,omitempty"`
+	DisplayQty        int64   `json:"display_qty,omitempty"` // below the quote's qty: quote icebergs showing this much
+
+	// BatchSignals, above 1, has the trader act once every this many
+	// signals, on their mean, instead of on each
+	BatchSignals int `json:"batch_signals,omitempty"`
+
+	// Market-data rules; each is off unless set. A trader with one on is
+	// sent BBO updates and trade prints over its market-data feed
+	PullSweepQty int64 `json:"pull_sweep_qty,omitempty"` // pull quotes on the side a sweep of this much hits
+	JoinWiden    bool  `json:"join_widen,omitempty"`     // quote at the new best when the spread widens
+}
+
+// defaultTargetQty is the quote qty of a strategy without target_qty, as
+// trader.NewStrategy sets it
+const defaultTargetQty = 5
+
+// IsZero reports whether p keeps every default
+func (p StrategyParams) IsZero() bool { return p == StrategyParams{} }
+
+// String describes the overrides, e.g. "cross ±0.5, qty 10", or
+// "default"
+func (p StrategyParams) String() string {
+	var parts []string
+	if p.ReQuoteIntervalNs > 0 {
+		parts = append(parts, "requote every "+latency.Format(p.ReQuoteIntervalNs))
+	}
+	if p.CancelTimeoutNs > 0 {
+		parts = append(parts, "cancel after "+latency.Format(p.CancelTimeoutNs))
+	}
+	if p.CrossThreshold > 0 {
+		parts = append(parts, "cross ±"+strconv.FormatFloat(p.CrossThreshold, 'g', -1, 64))
+	}
+	if p.CrossTIF != "" {
+		parts = append(parts, "cross "+strings.ToUpper(p.CrossTIF))
+	}
+	if p.ReduceThreshold > 0 {
+		parts = append(parts, "reduce ±"+strconv.FormatFloat(p.ReduceThreshold, 'g', -1, 64))
+	}
+	if p.TargetQty > 0 {
+		parts = append(parts, "qty "+strconv.FormatInt(p.TargetQty, 10))
+	}
+	if p.DisplayQty > 0 {
+		parts = append(parts, "show "+strconv.FormatInt(p.DisplayQty, 10))
+	}
+	if p.BatchSignals > 1 {
+		parts = append(parts, "act every "+strconv.Itoa(p.BatchSignals)+" signals")
+	}
+	if p.PullSweepQty > 0 {
+		parts = append(parts, "pull on sweeps of "+strconv.FormatInt(p.PullSweepQty, 10))
+	}
+	if p.JoinWiden {
+		parts = append(parts, "join on widening")
+	}
+	if len(parts) == 0 {
+		return "default"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Validate rejects negative params, an unknown cross time in force and
+// a display qty that would show the whole quote
+func (p StrategyParams) Validate() error {
+	if p.ReQuoteIntervalNs < 0 || p.CancelTimeoutNs < 0 || p.CrossThreshold < 0 || p.ReduceThreshold < 0 || p.TargetQty < 0 || p.DisplayQty < 0 || p.BatchSignals < 0 || p.PullSweepQty < 0 {
+		return fmt.Errorf("strategy params must be non-negative")
+	}
+	if qty := cmp.Or(p.TargetQty, defaultTargetQty); p.DisplayQty >= qty {
+		return fmt.Errorf("display_qty %d must be below the quote qty %d", p.DisplayQty, qty)
+	}
+	switch p.CrossTIF {
+	case "", "ioc", "fok":
+	default:
+		return fmt.Errorf("cross_tif %q: want ioc or fok", p.CrossTIF)
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts duration strings for the interval and timeout
+// and rejects unknown params
+func (p *StrategyParams) UnmarshalJSON(data []byte) error {
+	type plain StrategyParams
+	aux := struct {
+		*plain
+		ReQuoteIntervalNs nanos `json:"requote_interval_ns"`
+		CancelTimeoutNs   nanos `json:"cancel_timeout_ns"`
+	}{plain: (*plain)(p), ReQuoteIntervalNs: nanos{&p.ReQuoteIntervalNs}, CancelTimeoutNs: nanos{&p.CancelTimeoutNs}}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(&aux)
+}
+
+// ParseStrategyParams parses "key=value,..." with the JSON keys, e.g.
+// "cross_threshold=0.5,requote_interval_ns=50ms"
+func ParseStrategyParams(s string) (StrategyParams, error) {
+	fields := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok || k == "" {
+			return StrategyParams{}, fmt.Errorf("strategy param %q: want key=value", kv)
+		}
+		fields[k] = v
+	}
+	var p StrategyParams
+	var err error
+	for k, v := range detorder.All(fields) {
+		switch k {
+		case "requote_interval_ns":
+			p.ReQuoteIntervalNs, err = ParseDuration(v)
+		case "cancel_timeout_ns":
+			p.CancelTimeoutNs, err = ParseDuration(v)
+		case "cross_threshold":
+			p.CrossThreshold, err = strconv.ParseFloat(v, 64)
+		case "cross_tif":
+			p.CrossTIF = v
+		case "reduce_threshold":
+			p.ReduceThreshold, err = strconv.ParseFloat(v, 64)
+		case "target_qty":
+			p.TargetQty, err = strconv.ParseInt(v, 10, 64)
+		case "display_qty":
+			p.DisplayQty, err = strconv.ParseInt(v, 10, 64)
+		case "batch_signals":
+			p.BatchSignals, err = strconv.Atoi(v)
+		case "pull_sweep_qty":
+			p.PullSweepQty, err = strconv.ParseInt(v, 10, 64)
+		case "join_widen":
+			p.JoinWiden, err = strconv.ParseBool(v)
+		default:
+			return StrategyParams{}, fmt.Errorf("unknown strategy param %q", k)
+		}
+		if err != nil {
+			return StrategyParams{}, fmt.Errorf("strategy param %s: %w", k, err)
+		}
+	}
+	return p, p.Validate()
+}
@@ -0,0 +1,213 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+)
+
+// Matching rules for orders resting at the same price
+const (
+	MatchFIFO    = "fifo"     // price-time priority
+	MatchProRata = "pro_rata" // fills split by resting size, remainder by time
+)
+
+// Venue selects the exchange's market mechanism. The zero value is a
+// continuous price-time (FIFO) book; the fields combine, e.g. a speed
+// bump on a pro-rata book. The named fields are shorthands for the common
+// mechanisms; Mechanisms lists any others (package venue builds both)
+type Venue struct {
+	// Matching is MatchFIFO (default when empty) or MatchProRata
+	Matching string `json:"matching,omitempty"`
+
+	// SpeedBumpNs holds orders that would take liquidity on arrival this
+	// long before they reach the book; cancels and orders that would rest
+	// pass straight through, so makers can pull stale quotes first
+	SpeedBumpNs int64 `json:"speed_bump_ns,omitempty"`
+
+	// BatchIntervalNs replaces continuous matching with frequent batch
+	// auctions: orders are collected for each interval and cross at one
+	// uniform price at its end, with no time priority inside a batch
+	BatchIntervalNs int64 `json:"batch_interval_ns,omitempty"`
+
+	// MinRestingNs holds a cancel until its target has rested this long
+	MinRestingNs int64 `json:"min_resting_ns,omitempty"`
+
+	// Mechanisms are further venue rules, applied after the shorthands in
+	// the order listed
+	Mechanisms []MechanismSpec `json:"mechanisms,omitempty"`
+}
+
+// MechanismSpec configures one venue mechanism by its registered type, e.g.
+// {"type": "maker_taker", "maker": "-0.002", "taker": "0.003"}. Params
+// keep each value's text; the mechanism parses its own
+type MechanismSpec struct {
+	Type   string
+	Params map[string]string
+}
+
+// String renders the spec as the --mechanism flag takes it, e.g.
+// "speed_bump:delay=10ms"
+func (m MechanismSpec) String() string {
+	keys := make([]string, 0, len(m.Params))
+	for k := range m.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString(m.Type)
+	for i, k := range keys {
+		if i == 0 {
+			sb.WriteByte(':')
+		} else {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(k + "=" + m.Params[k])
+	}
+	return sb.String()
+}
+
+// ParseMechanismSpec parses "type[:key=value,...]"
+func ParseMechanismSpec(s string) (MechanismSpec, error) {
+	typ, rest, _ := strings.Cut(s, ":")
+	spec := MechanismSpec{Type: strings.TrimSpace(typ)}
+	if spec.Type == "" {
+		return spec, fmt.Errorf("mechanism %q has no type", s)
+	}
+	if rest == "" {
+		return spec, nil
+	}
+	spec.Params = make(map[string]string)
+	for _, kv := range strings.Split(rest, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return spec, fmt.Errorf("mechanism %s: expected key=value, got %q", spec.Type, kv)
+		}
+		if _, dup := spec.Params[k]; dup {
+			return spec, fmt.Errorf("mechanism %s: %s given twice", spec.Type, k)
+		}
+		spec.Params[k] = strings.TrimSpace(v)
+	}
+	return spec, nil
+}
+
+// MarshalJSON writes the spec as one flat object, type first
+func (m MechanismSpec) MarshalJSON() ([]byte, error) {
+	keys := make([]string, 0, len(m.Params))
+	for k := range m.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	typ, _ := json.Marshal(m.Type)
+	buf.WriteString(`{"type":`)
+	buf.Write(typ)
+	for _, k := range keys {
+		key, _ := json.Marshal(k)
+		val, _ := json.Marshal(m.Params[k])
+		buf.WriteByte(',')
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON reads a flat object; parameter values may be strings,
+// numbers or booleans
+func (m *MechanismSpec) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = MechanismSpec{}
+	for k, v := range raw {
+		if k == "type" {
+			if err := json.Unmarshal(v, &m.Type); err != nil {
+				return fmt.Errorf("mechanism type: %w", err)
+			}
+			continue
+		}
+		var text string
+		if err := json.Unmarshal(v, &text); err != nil {
+			var scalar any
+			if json.Unmarshal(v, &scalar) != nil {
+				return fmt.Errorf("mechanism parameter %s: %w", k, err)
+			}
+			switch scalar.(type) {
+			case float64, bool:
+				text = string(bytes.TrimSpace(v))
+			default:
+				return fmt.Errorf("mechanism parameter %s must be a string, number or boolean", k)
+			}
+		}
+		if m.Params == nil {
+			m.Params = make(map[string]string)
+		}
+		m.Params[k] = text
+	}
+	if m.Type == "" {
+		return fmt.Errorf("mechanism has no type")
+	}
+	return nil
+}
+
+// Validate rejects an unknown matching rule or a negative duration
+func (v Venue) Validate() error {
+	switch v.Matching {
+	case "", MatchFIFO, MatchProRata:
+	default:
+		return fmt.Errorf("unknown matching rule %q (fifo, pro_rata)", v.Matching)
+	}
+	if v.SpeedBumpNs < 0 || v.BatchIntervalNs < 0 || v.MinRestingNs < 0 {
+		return fmt.Errorf("venue durations must not be negative")
+	}
+	return nil
+}
+
+// IsZero reports whether v is the default continuous FIFO book
+func (v Venue) IsZero() bool {
+	return v.Matching == "" && v.SpeedBumpNs == 0 && v.BatchIntervalNs == 0 &&
+		v.MinRestingNs == 0 && len(v.Mechanisms) == 0
+}
+
+// UnmarshalJSON accepts duration strings for the venue's durations
+func (v *Venue) UnmarshalJSON(data []byte) error {
+	type plain Venue
+	aux := struct {
+		*plain
+		SpeedBumpNs     nanos `json:"speed_bump_ns"`
+		BatchIntervalNs nanos `json:"batch_interval_ns"`
+		MinRestingNs    nanos `json:"min_resting_ns"`
+	}{
+		plain:           (*plain)(v),
+		SpeedBumpNs:     nanos{&v.SpeedBumpNs},
+		BatchIntervalNs: nanos{&v.BatchIntervalNs},
+		MinRestingNs:    nanos{&v.MinRestingNs},
+	}
+	return json.Unmarshal(data, &aux)
+}
+
+// Mechanism is a named venue design
+type Mechanism struct {
+	Name  string
+	Venue Venue
+}
+
+// Mechanisms returns the venue designs `fairsim mechanisms` compares,
+// continuous FIFO first as the baseline
+func Mechanisms() []Mechanism {
+	return []Mechanism{
+		{Name: "continuous_fifo"},
+		{Name: "pro_rata", Venue: Venue{Matching: MatchProRata}},
+		{Name: "speed_bump", Venue: Venue{SpeedBumpNs: latency.MsToNs(10)}},
+		{Name: "batch_auction", Venue: Venue{BatchIntervalNs: latency.MsToNs(50)}},
+		{Name: "min_resting", Venue: Venue{MinRestingNs: latency.MsToNs(20)}},
+	}
+}
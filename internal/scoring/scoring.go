@@ -0,0 +1,272 @@
+// Package scoring ranks the runs of a sweep by one composite score. Each
+// run is measured on fairness terms, the absolute fast-minus-slow gaps,
+// and market-quality terms, the spread, depth and volume the market
+// offered. Each term is scaled across the sweep from 0 at its worst run
+// to 1 at its best, and the score is their weighted mean. Since fairer
+// venues are often thinner ones, the runs no other run beats on both
+// fairness and quality form a Pareto front of the trade-offs worth
+// choosing between
+package scoring
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sensitivity"
+)
+
+// Term is one quantity a run is scored on
+type Term struct {
+	Key      string
+	Name     string
+	Fairness bool // an absolute gap; otherwise a market-quality measure
+	Higher   bool // higher raw values are better
+	get      func(r *Run) float64
+}
+
+// Terms are the fairness gaps the sweeps trace, then market quality
+var Terms = func() []Term {
+	var terms []Term
+	for _, m := range sensitivity.Metrics {
+		key := m.Key
+		terms = append(terms, Term{Key: key, Name: "|Gap| " + m.Name, Fairness: true,
+			get: func(r *Run) float64 { return math.Abs(r.Gaps[key]) }})
+	}
+	return append(terms,
+		Term{Key: "spread_bps", Name: "Quoted Spread (bps)", get: func(r *Run) float64 { return r.Quality.AvgSpreadBps }},
+		Term{Key: "top_depth", Name: "Top-of-Book Depth", Higher: true, get: func(r *Run) float64 { return r.Quality.AvgTopDepth }},
+		Term{Key: "volume", Name: "Volume", Higher: true, get: func(r *Run) float64 { return float64(r.Quality.Volume) }},
+	)
+}()
+
+// Weights weigh each term by key. A term weighted 0 is left out
+type Weights map[string]float64
+
+// DefaultWeights weighs every term equally
+func DefaultWeights() Weights {
+	w := make(Weights, len(Terms))
+	for _, t := range Terms {
+		w[t.Key] = 1
+	}
+	return w
+}
+
+// ParseWeights overrides the default weights from a spec such as
+// "fill_rate_pct=2,volume=0". The groups "fairness" and "quality" set
+// every term of their kind; a term's own weight overrides its group's
+func ParseWeights(spec string) (Weights, error) {
+	w := DefaultWeights()
+	var terms []string
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("weight %q: want key=weight", part)
+		}
+		v, err := strconv.ParseFloat(val, 64)
+		if err != nil || v < 0 || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("weight %q: want a non-negative number", part)
+		}
+		switch key {
+		case "fairness", "quality":
+			for _, t := range Terms {
+				if t.Fairness == (key == "fairness") {
+					w[t.Key] = v
+				}
+			}
+		default:
+			if _, ok := w[key]; !ok {
+				return nil, fmt.Errorf("weight %q: unknown term (fairness, quality, %s)", part, strings.Join(keys(), ", "))
+			}
+			terms = append(terms, part)
+		}
+	}
+	// Individual terms after groups, wherever they appear
+	for _, part := range terms {
+		key, val, _ := strings.Cut(part, "=")
+		w[key], _ = strconv.ParseFloat(val, 64)
+	}
+	if w.total(nil) == 0 {
+		return nil, fmt.Errorf("weights %q leave nothing to score", spec)
+	}
+	return w, nil
+}
+
+func keys() []string {
+	out := make([]string, len(Terms))
+	for i, t := range Terms {
+		out[i] = t.Key
+	}
+	return out
+}
+
+// total sums the weights of the terms keep accepts, or of all if nil
+func (w Weights) total(keep func(Term) bool) float64 {
+	var sum float64
+	for _, t := range Terms {
+		if keep == nil || keep(t) {
+			sum += w[t.Key]
+		}
+	}
+	return sum
+}
+
+// Run is one configuration of a sweep
+type Run struct {
+	Label   string
+	RunDir  string
+	Gaps    map[string]float64 // fast - slow, by sensitivity metric key
+	Quality metrics.MarketQuality
+}
+
+// NewRun measures the market quality of the run in runDir. gaps are its
+// fast-minus-slow gaps
+func NewRun(label, runDir string, gaps map[string]float64) (Run, error) {
+	q, err := metrics.ComputeMarketQuality(filepath.Join(runDir, "events.jsonl"))
+	if err != nil {
+		return Run{}, fmt.Errorf("%s: %w", label, err)
+	}
+	return Run{Label: label, RunDir: runDir, Gaps: gaps, Quality: *q}, nil
+}
+
+// Gaps are fast's metrics minus slow's, by sensitivity metric key
+func Gaps(fast, slow *metrics.TraderMetrics) map[string]float64 {
+	gaps := make(map[string]float64, len(sensitivity.Metrics))
+	for _, m := range sensitivity.Metrics {
+		if fast != nil && slow != nil {
+			gaps[m.Key] = m.Get(fast) - m.Get(slow)
+		}
+	}
+	return gaps
+}
+
+// Score is one run's place in a ranking
+type Score struct {
+	Label    string                `json:"label"`
+	RunDir   string                `json:"run_dir"`
+	Market   metrics.MarketQuality `json:"market_quality"`
+	Raw      map[string]float64    `json:"raw"`      // each term before scaling
+	Scaled   map[string]float64    `json:"scaled"`   // 0 at the sweep's worst, 1 at its best
+	Fairness float64               `json:"fairness"` // weighted mean of the fairness terms, or plain if all weigh 0
+	Quality  float64               `json:"quality"`  // weighted mean of the quality terms, or plain if all weigh 0
+	Score    float64               `json:"score"`    // weighted mean of every term
+	Rank     int                   `json:"rank"`     // 1 is the highest score
+	Pareto   bool                  `json:"pareto"`   // no run beats it on both fairness and quality
+}
+
+// Ranking is a sweep's runs, best score first
+type Ranking struct {
+	Weights Weights `json:"weights"`
+	Scores  []Score `json:"scores"`
+}
+
+// Rank scores runs against each other under w
+func Rank(runs []Run, w Weights) *Ranking {
+	lo := make(map[string]float64, len(Terms))
+	hi := make(map[string]float64, len(Terms))
+	for _, t := range Terms {
+		lo[t.Key], hi[t.Key] = math.Inf(1), math.Inf(-1)
+		for i := range runs {
+			if v := t.get(&runs[i]); !math.IsNaN(v) {
+				lo[t.Key], hi[t.Key] = min(lo[t.Key], v), max(hi[t.Key], v)
+			}
+		}
+	}
+
+	// A kind weighted out of the score still gets an axis of the front,
+	// its terms weighed equally
+	axis := Weights{}
+	for _, fairness := range []bool{true, false} {
+		zero := w.total(func(t Term) bool { return t.Fairness == fairness }) == 0
+		for _, t := range Terms {
+			if t.Fairness == fairness {
+				axis[t.Key] = w[t.Key]
+				if zero {
+					axis[t.Key] = 1
+				}
+			}
+		}
+	}
+	fairW := axis.total(func(t Term) bool { return t.Fairness })
+	qualW := axis.total(func(t Term) bool { return !t.Fairness })
+	total := w.total(nil)
+
+	scores := make([]Score, len(runs))
+	for i := range runs {
+		s := Score{Label: runs[i].Label, RunDir: runs[i].RunDir, Raw: map[string]float64{}, Scaled: map[string]float64{}, Market: runs[i].Quality}
+		for _, t := range Terms {
+			v := t.get(&runs[i])
+			s.Raw[t.Key] = v
+			s.Scaled[t.Key] = scale(v, lo[t.Key], hi[t.Key], t.Higher)
+			if t.Fairness {
+				s.Fairness += axis[t.Key] * s.Scaled[t.Key] / fairW
+			} else {
+				s.Quality += axis[t.Key] * s.Scaled[t.Key] / qualW
+			}
+			s.Score += w[t.Key] * s.Scaled[t.Key] / total
+		}
+		scores[i] = s
+	}
+
+	for i := range scores {
+		scores[i].Pareto = true
+		for j := range scores {
+			if dominates(scores[j], scores[i]) {
+				scores[i].Pareto = false
+				break
+			}
+		}
+	}
+	sort.SliceStable(scores, func(a, b int) bool { return scores[a].Score > scores[b].Score })
+	for i := range scores {
+		scores[i].Rank = i + 1
+	}
+	out := make(Weights, len(w))
+	for k, v := range w {
+		if v > 0 {
+			out[k] = v
+		}
+	}
+	return &Ranking{Weights: out, Scores: scores}
+}
+
+// scale maps v into [0, 1] from worst to best; a term every run ties on
+// scores 1, and a NaN 0
+func scale(v, lo, hi float64, higher bool) float64 {
+	switch {
+	case math.IsNaN(v):
+		return 0
+	case hi <= lo:
+		return 1
+	case higher:
+		return (v - lo) / (hi - lo)
+	default:
+		return (hi - v) / (hi - lo)
+	}
+}
+
+// dominates reports whether a is at least as good as b on fairness and
+// quality, and better on one
+func dominates(a, b Score) bool {
+	return a.Fairness >= b.Fairness && a.Quality >= b.Quality && (a.Fairness > b.Fairness || a.Quality > b.Quality)
+}
+
+// Front is the Pareto front, fairest first
+func (r *Ranking) Front() []Score {
+	var front []Score
+	for _, s := range r.Scores {
+		if s.Pareto {
+			front = append(front, s)
+		}
+	}
+	sort.SliceStable(front, func(a, b int) bool { return front[a].Fairness > front[b].Fairness })
+	return front
+}
@@ -0,0 +1,95 @@
+package scoring
+
+import (
+	"math"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+)
+
+func run(label string, fillGap, spread float64, volume int64) Run {
+	return Run{
+		Label:   label,
+		Gaps:    map[string]float64{"fill_rate_pct": fillGap},
+		Quality: metrics.MarketQuality{AvgSpreadBps: spread, Volume: volume},
+	}
+}
+
+// TestRank scales each term across the runs, ranks by the weighted mean
+// and marks the runs no other beats on both fairness and quality
+func TestRank(t *testing.T) {
+	runs := []Run{
+		run("fair_thin", 0, 4, 100),     // fairest, worst market
+		run("unfair_deep", -10, 2, 300), // least fair, best market
+		run("middle", 5, 3, 200),
+		run("dominated", 10, 4, 100), // no better than fair_thin on anything
+	}
+	r := Rank(runs, DefaultWeights())
+
+	byLabel := map[string]Score{}
+	for _, s := range r.Scores {
+		byLabel[s.Label] = s
+	}
+	if s := byLabel["fair_thin"]; s.Scaled["fill_rate_pct"] != 1 || s.Scaled["spread_bps"] != 0 || s.Scaled["slippage_bps"] != 1 {
+		t.Errorf("fair_thin scaled = %v", s.Scaled)
+	}
+	if s := byLabel["unfair_deep"]; s.Raw["fill_rate_pct"] != 10 || s.Scaled["fill_rate_pct"] != 0 || s.Scaled["volume"] != 1 {
+		t.Errorf("unfair_deep raw %v scaled %v", s.Raw, s.Scaled)
+	}
+	for label, want := range map[string]bool{"fair_thin": true, "unfair_deep": true, "middle": true, "dominated": false} {
+		if byLabel[label].Pareto != want {
+			t.Errorf("%s pareto = %v, want %v", label, byLabel[label].Pareto, want)
+		}
+	}
+	for i, s := range r.Scores {
+		if s.Rank != i+1 || (i > 0 && s.Score > r.Scores[i-1].Score) {
+			t.Fatalf("scores out of order: %+v", r.Scores)
+		}
+	}
+	if r.Scores[len(r.Scores)-1].Label != "dominated" {
+		t.Errorf("dominated ranked %d", byLabel["dominated"].Rank)
+	}
+	if front := r.Front(); len(front) != 3 || front[0].Label != "fair_thin" || front[2].Label != "unfair_deep" {
+		t.Errorf("front = %v", front)
+	}
+
+	// Weighing only fairness puts the fairest run first
+	w, err := ParseWeights("quality=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fairOnly := Rank(runs, w)
+	if top := fairOnly.Scores[0]; top.Label != "fair_thin" || math.Abs(top.Score-1) > 1e-12 {
+		t.Errorf("fairness only: top = %+v", top)
+	}
+	// but quality still spans the front
+	if len(fairOnly.Front()) != 3 || math.Abs(fairOnly.Scores[0].Quality-1.0/3) > 1e-12 {
+		t.Errorf("fairness only: front = %v", fairOnly.Front())
+	}
+	w, err = ParseWeights("fairness=0,volume=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top := Rank(runs, w).Scores[0]; top.Label != "unfair_deep" {
+		t.Errorf("quality only: top = %s", top.Label)
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	w, err := ParseWeights("fairness=2, net_pnl=0,volume=0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w["fill_rate_pct"] != 2 || w["net_pnl"] != 0 || w["volume"] != 0.5 || w["spread_bps"] != 1 {
+		t.Errorf("weights = %v", w)
+	}
+	// A term's own weight wins over its group's in either order
+	if w, _ := ParseWeights("net_pnl=3,fairness=0"); w["net_pnl"] != 3 || w["fill_rate_pct"] != 0 {
+		t.Errorf("weights = %v", w)
+	}
+	for _, bad := range []string{"fill_rate=1", "volume", "volume=-1", "volume=x", "fairness=0,quality=0"} {
+		if _, err := ParseWeights(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
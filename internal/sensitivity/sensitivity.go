@@ -0,0 +1,204 @@
+// Package sensitivity sweeps the slow trader's base latency with
+// everything else held fixed: the same seed, hence the same background
+// flow, signals and jitter draws, so each point differs from the others
+// only in latency. Each fairness metric's fast-minus-slow gap then forms
+// a curve over latency, and the curve's knee marks where extra latency
+// starts to cost the slow trader disproportionately
+package sensitivity
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"time"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+// Metric reads one fairness metric off a trader's metrics
+type Metric struct {
+	Key  string // for column and field names
+	Name string
+	Get  func(*metrics.TraderMetrics) float64
+}
+
+// Metrics are the curves a sweep traces
+var Metrics = []Metric{
+	{"fill_rate_pct", "Fill Rate (%)", func(m *metrics.TraderMetrics) float64 { return m.FillRate * 100 }},
+	{"slippage_bps", "Slippage (bps)", func(m *metrics.TraderMetrics) float64 { return m.SlippageBps }},
+	{"time_to_fill_ms", "Avg TTF (ms)", func(m *metrics.TraderMetrics) float64 { return m.AvgTimeToFillMs }},
+	{"adverse_selection_bps", "Adverse Selection (bps)", func(m *metrics.TraderMetrics) float64 { return m.AdverseSelectionBps }},
+	{"net_pnl", "Net PnL", func(m *metrics.TraderMetrics) float64 { return m.NetPnL }},
+}
+
+// QueueMetrics are the curves of the queue-position experiment: where
+// each trader's limit orders joined the queue, and how likely its orders
+// were to fill
+var QueueMetrics = []Metric{
+	{"queue_pos_place", "Avg Queue Pos (place)", func(m *metrics.TraderMetrics) float64 { return m.AvgQueuePosPlace }},
+	{"queue_front_pct", "Joined Queue Front (%)", func(m *metrics.TraderMetrics) float64 { return m.QueueFrontPct() }},
+	{"fill_prob_pct", "Fill Probability (%)", func(m *metrics.TraderMetrics) float64 { return m.FillRate * 100 }},
+}
+
+// Point is one metric at one slow-trader latency
+type Point struct {
+	LatencyNs int64   `json:"latency_ns"`
+	Fast      float64 `json:"fast"`
+	Slow      float64 `json:"slow"`
+	Gap       float64 `json:"gap"` // fast - slow
+}
+
+// Curve is one metric's gap over the sweep. KneeNs is the latency at the
+// knee, or 0 if the curve has none (fewer than three points, or flat)
+type Curve struct {
+	Metric string  `json:"metric"`
+	Points []Point `json:"points"`
+	KneeNs int64   `json:"knee_ns,omitempty"`
+}
+
+// Result is a completed sweep
+type Result struct {
+	Scenario  string   `json:"scenario"`
+	Seed      int64    `json:"seed"`
+	FastNs    int64    `json:"fast_latency_ns"`
+	Latencies []int64  `json:"latencies_ns"`
+	RunDirs   []string `json:"run_dirs"`
+	Curves    []Curve  `json:"curves"`
+}
+
+// FirstKneeNs is the smallest knee over all curves: the latency at which
+// the first metric turns, or 0 if none does
+func (r *Result) FirstKneeNs() int64 {
+	var first int64
+	for _, c := range r.Curves {
+		if c.KneeNs > 0 && (first == 0 || c.KneeNs < first) {
+			first = c.KneeNs
+		}
+	}
+	return first
+}
+
+// Latencies returns steps latencies from from to to inclusive, evenly
+// spaced on a log scale unless linear, rounded to the microsecond
+func Latencies(from, to int64, steps int, linear bool) ([]int64, error) {
+	if from <= 0 || to <= from {
+		return nil, fmt.Errorf("latency range must satisfy 0 < from < to, got %s to %s",
+			time.Duration(from), time.Duration(to))
+	}
+	if steps < 2 {
+		return nil, fmt.Errorf("need at least 2 steps, got %d", steps)
+	}
+	out := make([]int64, 0, steps)
+	for i := 0; i < steps; i++ {
+		f := float64(i) / float64(steps-1)
+		var ns float64
+		if linear {
+			ns = float64(from) + f*float64(to-from)
+		} else {
+			ns = float64(from) * math.Pow(float64(to)/float64(from), f)
+		}
+		v := int64(math.Round(ns))
+		if v >= 1_000 {
+			v = (v + 500) / 1_000 * 1_000
+		}
+		if len(out) > 0 && v <= out[len(out)-1] {
+			continue
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// Sweep runs cfg once per latency, with the slow trader's base latency
+// set to it and its jitter unchanged, tracing the fairness Metrics. Runs
+// write under dir, one directory per latency
+func Sweep(cfg *scenario.Config, latencies []int64, dir string, progress func(latencyNs int64)) (*Result, error) {
+	return SweepMetrics(cfg, latencies, Metrics, dir, progress)
+}
+
+// SweepMetrics is Sweep tracing the curves of ms instead
+func SweepMetrics(cfg *scenario.Config, latencies []int64, ms []Metric, dir string, progress func(latencyNs int64)) (*Result, error) {
+	res := &Result{Scenario: cfg.Name, Seed: cfg.Seed, FastNs: cfg.FastTrader.BaseLatencyNs(), Latencies: latencies}
+	res.Curves = make([]Curve, len(ms))
+	for i, m := range ms {
+		res.Curves[i].Metric = m.Name
+	}
+
+	for _, lat := range latencies {
+		if progress != nil {
+			progress(lat)
+		}
+		c := *cfg
+		if err := c.SlowTrader.SetLatency(time.Duration(lat), time.Duration(cfg.SlowTrader.JitterNs())); err != nil {
+			return nil, err
+		}
+		runner, err := sim.NewRunner(&c, filepath.Join(dir, "slow_"+time.Duration(lat).String()))
+		if err != nil {
+			return nil, err
+		}
+		result, err := runner.Run()
+		if err != nil {
+			return nil, fmt.Errorf("slow at %s: %w", time.Duration(lat), err)
+		}
+		m, err := metrics.ComputeFromLog(result.LogPath, c.Scale(), c.Metrics)
+		if err != nil {
+			return nil, fmt.Errorf("slow at %s metrics: %w", time.Duration(lat), err)
+		}
+		res.RunDirs = append(res.RunDirs, result.OutputDir)
+
+		fast, slow := m[c.FastTrader.ID], m[c.SlowTrader.ID]
+		for i, mt := range ms {
+			p := Point{LatencyNs: lat}
+			if fast != nil && slow != nil {
+				p.Fast, p.Slow = mt.Get(fast), mt.Get(slow)
+				p.Gap = p.Fast - p.Slow
+			}
+			res.Curves[i].Points = append(res.Curves[i].Points, p)
+		}
+	}
+
+	for i := range res.Curves {
+		xs := make([]float64, len(latencies))
+		ys := make([]float64, len(latencies))
+		for j, p := range res.Curves[i].Points {
+			xs[j], ys[j] = math.Log(float64(p.LatencyNs)), p.Gap
+		}
+		if k := Knee(xs, ys); k >= 0 {
+			res.Curves[i].KneeNs = latencies[k]
+		}
+	}
+	return res, nil
+}
+
+// Knee finds the point of maximum curvature in the manner of Kneedle:
+// with x and y scaled to [0, 1], the interior point farthest from the
+// chord between the first and last points. Latency is taken on a log
+// scale by the caller, since its effects compound. It returns -1 if there
+// are fewer than three points or the curve does not bend
+func Knee(xs, ys []float64) int {
+	n := len(xs)
+	if n < 3 || n != len(ys) || xs[n-1] == xs[0] {
+		return -1
+	}
+	lo, hi := ys[0], ys[0]
+	for _, y := range ys {
+		lo, hi = min(lo, y), max(hi, y)
+	}
+	if hi == lo {
+		return -1
+	}
+	scaleY := func(y float64) float64 { return (y - lo) / (hi - lo) }
+	y0, y1 := scaleY(ys[0]), scaleY(ys[n-1])
+	// Points within rounding of the chord are not a bend
+	knee, best := -1, 1e-9
+	for i := 1; i < n-1; i++ {
+		x := (xs[i] - xs[0]) / (xs[n-1] - xs[0])
+		d := math.Abs(scaleY(ys[i]) - (y0 + (y1-y0)*x))
+		if d > best {
+			knee, best = i, d
+		}
+	}
+	return knee
+}
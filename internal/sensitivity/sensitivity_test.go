@@ -0,0 +1,83 @@
+package sensitivity
+
+import (
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+func TestLatencies(t *testing.T) {
+	got, err := Latencies(latency.MsToNs(1), latency.MsToNs(100), 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{latency.MsToNs(1), latency.MsToNs(10), latency.MsToNs(100)}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("log spacing = %v, want %v", got, want)
+		}
+	}
+	got, _ = Latencies(latency.MsToNs(10), latency.MsToNs(30), 3, true)
+	if got[1] != latency.MsToNs(20) {
+		t.Errorf("linear midpoint = %d", got[1])
+	}
+	for _, bad := range [][2]int64{{0, 10}, {10, 10}, {20, 10}} {
+		if _, err := Latencies(bad[0], bad[1], 5, false); err == nil {
+			t.Errorf("range %v accepted", bad)
+		}
+	}
+	if _, err := Latencies(1, 10, 1, false); err == nil {
+		t.Error("single step accepted")
+	}
+}
+
+func TestKnee(t *testing.T) {
+	xs := []float64{0, 1, 2, 3, 4, 5}
+	// Flat, then a sharp rise: the knee is where the rise begins
+	if k := Knee(xs, []float64{0, 0, 0, 1, 5, 10}); k != 3 {
+		t.Errorf("hockey stick knee = %d, want 3", k)
+	}
+	// Rises fast, then saturates
+	if k := Knee(xs, []float64{0, 8, 9, 9.5, 9.8, 10}); k != 1 {
+		t.Errorf("saturating knee = %d, want 1", k)
+	}
+	// A straight line has no interior point off the chord
+	if k := Knee(xs, []float64{1, 2, 3, 4, 5, 6}); k != -1 {
+		t.Errorf("straight line knee = %d, want -1", k)
+	}
+	if k := Knee(xs, []float64{3, 3, 3, 3, 3, 3}); k != -1 {
+		t.Errorf("flat knee = %d, want -1", k)
+	}
+	if k := Knee(xs[:2], []float64{0, 1}); k != -1 {
+		t.Errorf("two-point knee = %d, want -1", k)
+	}
+}
+
+// TestSweepHoldsFlowFixed checks each point reruns the same market: the
+// fast trader's latency never changes, the slow trader's follows the sweep
+func TestSweepHoldsFlowFixed(t *testing.T) {
+	cfg := scenario.GetConfig("spike", 3)
+	cfg.Duration = latency.MsToNs(1_000)
+	lats := []int64{latency.MsToNs(2), latency.MsToNs(20), latency.MsToNs(200)}
+	res, err := Sweep(cfg, lats, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Curves) != len(Metrics) || len(res.RunDirs) != len(lats) {
+		t.Fatalf("sweep shape: %d curves, %d runs", len(res.Curves), len(res.RunDirs))
+	}
+	for _, c := range res.Curves {
+		if len(c.Points) != len(lats) {
+			t.Fatalf("%s: %d points", c.Metric, len(c.Points))
+		}
+		for i, p := range c.Points {
+			if p.LatencyNs != lats[i] || p.Gap != p.Fast-p.Slow {
+				t.Errorf("%s point %d: %+v", c.Metric, i, p)
+			}
+		}
+	}
+	if cfg.SlowTrader.BaseLatencyNs() == lats[0] {
+		t.Error("sweep modified the caller's config")
+	}
+}
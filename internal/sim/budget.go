@@ -0,0 +1,66 @@
+package sim
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+// Truncation says which budget limit ended a run before the scenario's
+// end. The log's SIM_END comes early, annotated with the events handled
+type Truncation struct {
+	Limit     string `json:"limit"`  // max_events or max_wall_seconds
+	Detail    string `json:"detail"` // the limit and what the run reached
+	Events    uint64 `json:"events"` // events handled before SIM_END
+	Timestamp int64  `json:"timestamp_ns"`
+}
+
+// wallCheckEvery is how many events pass between wall clock reads
+const wallCheckEvery = 1024
+
+// checkBudget runs after each handled event. Once a limit is reached it
+// returns a SIM_END to schedule at the current time; the loop stops after
+// handling it. The annotation names only the event count, so a replay
+// stopping at max_events logs the same bytes as a run stopped by the clock
+func (r *Runner) checkBudget(event *domain.Event) *domain.Event {
+	b := r.cfg.Budget
+	if r.truncation != nil || r.closed || b.IsZero() {
+		return nil
+	}
+	// The scenario's own SIM_END is due now anyway
+	if event.Timestamp >= r.cfg.Duration {
+		return nil
+	}
+	handled := r.loop.EventsProcessed
+	var limit, detail string
+	switch {
+	case b.MaxEvents > 0 && handled >= b.MaxEvents:
+		limit, detail = "max_events", fmt.Sprintf("%d events handled (limit %d)", handled, b.MaxEvents)
+	case b.MaxWallSeconds > 0 && handled%wallCheckEvery == 0:
+		if wall := time.Since(r.startWall); wall.Seconds() >= b.MaxWallSeconds {
+			limit, detail = "max_wall_seconds", fmt.Sprintf("%v of wall time (limit %gs), %d events handled",
+				wall.Round(time.Millisecond), b.MaxWallSeconds, handled)
+		}
+	}
+	if limit == "" {
+		return nil
+	}
+	r.truncation = &Truncation{Limit: limit, Detail: detail, Events: handled, Timestamp: event.Timestamp}
+	end := &domain.Event{Timestamp: event.Timestamp, Type: domain.EventSimEnd}
+	end.Annotate("truncated_after_events", strconv.FormatUint(handled, 10))
+	return end
+}
+
+// savedConfig is the config written to config.json. A run the clock cut
+// short records its stop as max_events, so a replay stops at the same event
+func (r *Runner) savedConfig() *scenario.Config {
+	if r.truncation == nil || r.truncation.Limit == "max_events" {
+		return r.cfg
+	}
+	saved := *r.cfg
+	saved.Budget = scenario.Budget{MaxEvents: r.truncation.Events}
+	return &saved
+}
@@ -0,0 +1,159 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/ledger"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/trader"
+)
+
+// Capital limits. Each order of a trader with capital set is checked as
+// it reaches the venue: its resting orders and position, with the new
+// order's quantity on top, are valued at the mid and held at margin
+// against the trader's equity (ledger.Limit). An order the equity cannot
+// cover is rejected. It never reaches the book, and is logged only as
+// its ORDER_STATE rejection, with RejectCapital as the reason. Capital is
+// taken up as orders rest and positions open and released as they are
+// canceled, filled away or closed; it is measured after every order and
+// fill of the trader's
+
+// RejectCapital is the reason logged for an order rejected for capital
+const RejectCapital = "insufficient capital"
+
+// CapitalUsage is a trader's use of its capital over a run; money is in
+// the instrument's currency
+type CapitalUsage struct {
+	TraderID    string  `json:"trader_id"`
+	Capital     float64 `json:"capital"`
+	Margin      float64 `json:"margin"`
+	PeakUsed    float64 `json:"peak_used"`
+	Rejected    int     `json:"rejected"`
+	RejectedQty int64   `json:"rejected_qty"`
+}
+
+// capitalAccount is what the runner tracks of a trader's capital
+type capitalAccount struct {
+	limit ledger.Limit
+	usage CapitalUsage
+	peak  int64 // in price units
+}
+
+// validateCapital rejects a negative, non-finite or unrepresentable
+// capital and a margin outside (0, 1] or without capital
+func validateCapital(tc scenario.TraderConfig, scale domain.Scale) error {
+	if tc.Capital < 0 || math.IsNaN(tc.Capital) || math.IsInf(tc.Capital, 0) {
+		return fmt.Errorf("capital must be a non-negative number")
+	}
+	if _, err := scale.FromFloat(tc.Capital); err != nil {
+		return fmt.Errorf("capital: %w", err)
+	}
+	if tc.Margin < 0 || tc.Margin > 1 || math.IsNaN(tc.Margin) {
+		return fmt.Errorf("margin must be between 0 and 1")
+	}
+	if tc.Margin > 0 && tc.Capital == 0 {
+		return fmt.Errorf("margin needs capital, the buying power it is held against")
+	}
+	return nil
+}
+
+// newCapital sets up the limits of the traders that have capital set
+func newCapital(cfg *scenario.Config) map[string]*capitalAccount {
+	accounts := make(map[string]*capitalAccount)
+	for _, tc := range []scenario.TraderConfig{cfg.FastTrader, cfg.SlowTrader} {
+		if tc.Capital == 0 {
+			continue
+		}
+		margin := tc.MarginShare()
+		capital, _ := cfg.Scale().FromFloat(tc.Capital) // checked by validateCapital
+		accounts[tc.ID] = &capitalAccount{
+			limit: ledger.Limit{
+				Capital:   capital,
+				MarginBps: max(int64(math.Round(margin*10_000)), 1),
+			},
+			usage: CapitalUsage{TraderID: tc.ID, Capital: tc.Capital, Margin: margin},
+		}
+	}
+	return accounts
+}
+
+// openQty sums an agent's resting orders by side
+func openQty(agent *trader.Agent) (buy, sell int64) {
+	for _, o := range agent.ActiveOrders {
+		if o.Side == domain.Buy {
+			buy += o.RemainingQty
+		} else {
+			sell += o.RemainingQty
+		}
+	}
+	return buy, sell
+}
+
+// capitalMark is the price exposure is valued at: the mid, or fallback
+// while one side of the book is empty
+func (r *Runner) capitalMark(fallback int64) int64 {
+	if bbo := r.currentBBO; bbo.BidPrice > 0 && bbo.AskPrice > 0 {
+		return (bbo.BidPrice + bbo.AskPrice) / 2
+	}
+	return fallback
+}
+
+// checkCapital reports whether an order reaching the venue is within its
+// trader's capital. One that is not is logged as rejected
+func (r *Runner) checkCapital(event *domain.Event) bool {
+	order := event.Order()
+	c, ok := r.capital[order.TraderID]
+	agent := r.agent(order.TraderID)
+	if !ok || agent == nil || order.Type == domain.CancelOrder {
+		return true
+	}
+	mark := r.capitalMark(order.Price)
+	if mark <= 0 {
+		return true
+	}
+	buy, sell := openQty(agent)
+	if allowed, _ := c.limit.Allows(r.ledger.Account(order.TraderID), order.Side, order.Qty, buy, sell, mark); allowed {
+		return true
+	}
+	c.usage.Rejected++
+	c.usage.RejectedQty += order.Qty
+	change := r.lifecycle.Reject(order, RejectCapital)
+	state := domain.NewStateEvent(event.Timestamp, &change)
+	r.logEvent(&state)
+	return false
+}
+
+// measureCapital records the capital a trader has in use, if it has a
+// limit
+func (r *Runner) measureCapital(traderID string) {
+	c, ok := r.capital[traderID]
+	agent := r.agent(traderID)
+	if !ok || agent == nil {
+		return
+	}
+	mark := r.capitalMark(0)
+	if mark <= 0 {
+		return
+	}
+	buy, sell := openQty(agent)
+	used := c.limit.Required(ledger.Exposure(r.ledger.Position(traderID), buy, sell), mark)
+	c.peak = max(c.peak, used)
+}
+
+// capitalUsage reports each limited trader's use of its capital, fast
+// trader first
+func (r *Runner) capitalUsage() []CapitalUsage {
+	var out []CapitalUsage
+	for _, id := range []string{r.fastAgent.ID, r.slowAgent.ID} {
+		c, ok := r.capital[id]
+		if !ok {
+			continue
+		}
+		u := c.usage
+		u.PeakUsed = r.cfg.Scale().ToFloat(c.peak)
+		out = append(out, u)
+	}
+	return out
+}
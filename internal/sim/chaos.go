@@ -0,0 +1,143 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"syscall"
+)
+
+// ErrInjected is the error produced by injected write faults
+var ErrInjected = errors.New("injected fault")
+
+// Faults injects failures into a run to exercise its error handling
+// Counts are 1-based; zero disables a fault. A nil *Faults injects nothing
+type Faults struct {
+	// LogErrorAt fails the Nth event log write with ErrInjected
+	LogErrorAt uint64
+
+	// DiskFullAfter lets the event log file take this many bytes, then
+	// fails further output with ENOSPC as a full disk would
+	DiskFullAfter int64
+
+	// TradeErrorAt fails the Nth trades.json write with ErrInjected
+	TradeErrorAt int
+
+	// StrategyPanicAt panics inside the Nth strategy callback
+	StrategyPanicAt int
+
+	logWrites   uint64
+	tradeWrites int
+	strategy    int
+}
+
+func (f *Faults) logWrite() error {
+	if f == nil || f.LogErrorAt == 0 {
+		return nil
+	}
+	f.logWrites++
+	if f.logWrites == f.LogErrorAt {
+		return fmt.Errorf("event log write %d: %w", f.logWrites, ErrInjected)
+	}
+	return nil
+}
+
+func (f *Faults) tradeWrite() error {
+	if f == nil || f.TradeErrorAt == 0 {
+		return nil
+	}
+	f.tradeWrites++
+	if f.tradeWrites == f.TradeErrorAt {
+		return fmt.Errorf("trade write %d: %w", f.tradeWrites, ErrInjected)
+	}
+	return nil
+}
+
+func (f *Faults) strategyCall() {
+	if f == nil || f.StrategyPanicAt == 0 {
+		return
+	}
+	f.strategy++
+	if f.strategy == f.StrategyPanicAt {
+		panic(fmt.Sprintf("injected strategy panic in callback %d", f.strategy))
+	}
+}
+
+// wrapLog returns the event log output wrapper, or nil if not needed
+func (f *Faults) wrapLog() func(io.Writer) io.Writer {
+	if f == nil || f.DiskFullAfter <= 0 {
+		return nil
+	}
+	return func(w io.Writer) io.Writer {
+		return &diskFullWriter{w: w, left: f.DiskFullAfter}
+	}
+}
+
+// diskFullWriter passes through a fixed number of bytes, then fails with
+// ENOSPC after a short write
+type diskFullWriter struct {
+	w    io.Writer
+	left int64
+}
+
+func (d *diskFullWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) <= d.left {
+		n, err := d.w.Write(p)
+		d.left -= int64(n)
+		return n, err
+	}
+	n, err := d.w.Write(p[:d.left])
+	d.left -= int64(n)
+	if err == nil {
+		err = syscall.ENOSPC
+	}
+	return n, err
+}
+
+// PanicError is a panic recovered while handling an event
+type PanicError struct {
+	Value interface{}
+	Stack string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// RunError reports a run that stopped early. What the run wrote before
+// failing (partial events.jsonl and trades.json, config.json) is left in
+// OutputDir
+type RunError struct {
+	OutputDir string
+	SeqNo     uint64 // last event handled
+	Timestamp int64
+	Err       error
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("run failed at t=%.3fms (seq %d): %v", float64(e.Timestamp)/1e6, e.SeqNo, e.Err)
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// fail records the first error and stops the event loop
+func (r *Runner) fail(err error) {
+	if r.err == nil {
+		r.err = err
+		r.loop.Stop()
+	}
+}
+
+// runLoop runs the event loop, turning a panic in any handler (strategy
+// callbacks, book invariant checks) into an error
+func (r *Runner) runLoop() {
+	defer func() {
+		if p := recover(); p != nil {
+			r.fail(fmt.Errorf("handle %s event: %w", r.currentType, &PanicError{Value: p, Stack: string(debug.Stack())}))
+		}
+	}()
+	r.loop.Run()
+}
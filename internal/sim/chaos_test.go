@@ -0,0 +1,138 @@
+package sim
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+)
+
+// TestChaos injects each fault into serial and pipelined runs and checks
+// the run returns a wrapped error instead of panicking, leaves partial
+// artifacts behind, and that a later clean run is unaffected
+func TestChaos(t *testing.T) {
+	cleanHash := func() string {
+		r, err := NewRunner(shortCalm(7), t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := r.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.LogHash
+	}
+	baseline := cleanHash()
+
+	tests := []struct {
+		name   string
+		faults Faults
+		check  func(t *testing.T, err error)
+		// validLog is false when the fault can cut the log mid-line
+		validLog bool
+	}{
+		{
+			name:     "log write error",
+			faults:   Faults{LogErrorAt: 200},
+			check:    wantIs(ErrInjected),
+			validLog: true,
+		},
+		{
+			name:   "disk full",
+			faults: Faults{DiskFullAfter: 10_000},
+			check:  wantIs(syscall.ENOSPC),
+		},
+		{
+			name:     "trade write error",
+			faults:   Faults{TradeErrorAt: 5},
+			check:    wantIs(ErrInjected),
+			validLog: true,
+		},
+		{
+			name:   "strategy panic",
+			faults: Faults{StrategyPanicAt: 3},
+			check: func(t *testing.T, err error) {
+				var p *PanicError
+				if !errors.As(err, &p) {
+					t.Fatalf("err = %v, want PanicError", err)
+				}
+				if !strings.Contains(err.Error(), "injected strategy panic") || p.Stack == "" {
+					t.Errorf("unclear panic error: %v", err)
+				}
+			},
+			validLog: true,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, pipeline := range []bool{false, true} {
+			name := tt.name + "/serial"
+			if pipeline {
+				name = tt.name + "/pipeline"
+			}
+			t.Run(name, func(t *testing.T) {
+				dir := t.TempDir()
+				r, err := NewRunner(shortCalm(7), dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				faults := tt.faults
+				r.Faults = &faults
+				r.Pipeline = pipeline
+
+				res, err := r.Run()
+				if err == nil {
+					t.Fatalf("run succeeded despite fault: %+v", res)
+				}
+				var runErr *RunError
+				if !errors.As(err, &runErr) {
+					t.Fatalf("err = %v, want RunError", err)
+				}
+				tt.check(t, err)
+				// Async output errors may only surface at Close
+				async := pipeline && tt.faults.DiskFullAfter > 0
+				if !async && r.loop.Pending() == 0 {
+					t.Error("run was not stopped early")
+				}
+
+				runDir := filepath.Join(dir, "calm_seed7")
+				if runErr.OutputDir != runDir {
+					t.Errorf("output dir %q, want %q", runErr.OutputDir, runDir)
+				}
+				for _, name := range []string{"events.jsonl", "trades.json", "config.json"} {
+					if _, err := os.Stat(filepath.Join(runDir, name)); err != nil {
+						t.Errorf("missing partial artifact: %v", err)
+					}
+				}
+				logPath := filepath.Join(runDir, "events.jsonl")
+				if tt.faults.DiskFullAfter > 0 {
+					info, err := os.Stat(logPath)
+					if err == nil && info.Size() != tt.faults.DiskFullAfter {
+						t.Errorf("log size %d, want %d", info.Size(), tt.faults.DiskFullAfter)
+					}
+				}
+				if tt.validLog {
+					if err := eventlog.Validate(logPath); err != nil {
+						t.Errorf("partial log invalid: %v", err)
+					}
+				}
+			})
+		}
+	}
+
+	if got := cleanHash(); got != baseline {
+		t.Errorf("clean run after faults hashed %s, want %s", got, baseline)
+	}
+}
+
+func wantIs(target error) func(t *testing.T, err error) {
+	return func(t *testing.T, err error) {
+		if !errors.Is(err, target) {
+			t.Fatalf("err = %v, want %v", err, target)
+		}
+	}
+}
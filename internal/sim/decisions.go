@@ -0,0 +1,79 @@
+package sim
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/trader"
+)
+
+// decisionWriter streams one trader's decisions to decisions_<id>.jsonl,
+// one JSON object per line
+type decisionWriter struct {
+	file   *os.File
+	writer *bufio.Writer
+	enc    *json.Encoder
+
+	// Decisions held, with the loop's SeqNos, until a pipelined run
+	// knows the SeqNos a serial run gives them
+	held []*trader.Decision
+}
+
+// openDecisions starts a decision log for each trader and has its agent
+// record into it. Each record carries the event being handled
+func (r *Runner) openDecisions() error {
+	for _, agent := range [2]*trader.Agent{r.fastAgent, r.slowAgent} {
+		f, err := os.Create(filepath.Join(r.outputDir, "decisions_"+agent.ID+".jsonl"))
+		if err != nil {
+			return fmt.Errorf("create decision log: %w", err)
+		}
+		w := &decisionWriter{file: f, writer: bufio.NewWriterSize(f, 64*1024)}
+		w.enc = json.NewEncoder(w.writer)
+		r.decisions = append(r.decisions, w)
+		agent.OnDecision = func(d *trader.Decision) {
+			if r.feed != nil && !r.feed.done {
+				d.SeqNo = r.currentSeq
+				w.held = append(w.held, d)
+				return
+			}
+			d.SeqNo = r.seqNo(r.currentSeq)
+			if err := w.enc.Encode(d); err != nil {
+				r.fail(fmt.Errorf("write decision log: %w", err))
+			}
+		}
+	}
+	return nil
+}
+
+// flushDecisions writes the decisions held back, renumbered
+func (r *Runner) flushDecisions() {
+	for _, w := range r.decisions {
+		for _, d := range w.held {
+			d.SeqNo = r.seqNo(d.SeqNo)
+			if err := w.enc.Encode(d); err != nil {
+				r.fail(fmt.Errorf("write decision log: %w", err))
+				break
+			}
+		}
+		w.held = nil
+	}
+}
+
+// closeDecisions flushes and closes the decision logs, returning the
+// first error
+func (r *Runner) closeDecisions() error {
+	var first error
+	for _, w := range r.decisions {
+		err := w.writer.Flush()
+		if cerr := w.file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil && first == nil {
+			first = fmt.Errorf("close decision log: %w", err)
+		}
+	}
+	return first
+}
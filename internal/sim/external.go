@@ -0,0 +1,80 @@
+package sim
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+)
+
+// externalIDBase offsets the IDs of injected orders so they can't collide
+// with the traders' or the generated background flow's
+const externalIDBase = 1 << 40
+
+// Inject schedules orders supplied from outside the simulation, such as
+// a recorded flow or an external agent, alongside the generated ones.
+// Each event's timestamp is the order's arrival time at the venue and its
+// SeqNo a hint that orders it among the source's events at that time.
+// Events must come in (timestamp, hint) order, and may not belong to a
+// live trader. Call it before Run
+func (r *Runner) Inject(source string, events []*domain.Event) error {
+	src := r.loop.NewSource(source)
+	for _, e := range events {
+		o := e.Order()
+		if e.Type != domain.EventOrderAccepted || o == nil {
+			return fmt.Errorf("%s: seq %d: want an %s order, got %s", source, e.SeqNo, domain.EventOrderAccepted, e.Type)
+		}
+		if r.agent(o.TraderID) != nil {
+			return fmt.Errorf("%s: seq %d: order %d belongs to live trader %s", source, e.SeqNo, o.ID, o.TraderID)
+		}
+		order := *o
+		order.ID += externalIDBase
+		if order.CancelID != 0 {
+			order.CancelID += externalIDBase
+		}
+		order.ArrivalTime = e.Timestamp
+		if order.DecisionTime == 0 {
+			order.DecisionTime = order.ArrivalTime
+		}
+		if order.DecisionTime > order.ArrivalTime {
+			return fmt.Errorf("%s: seq %d: order %d decided at %d ns, after it arrives at %d ns",
+				source, e.SeqNo, o.ID, order.DecisionTime, order.ArrivalTime)
+		}
+		if order.Type != domain.CancelOrder {
+			order.RemainingQty = order.Qty
+		}
+		order.QueuePos, order.SeqNo = 0, 0
+
+		ev := domain.PooledEvent(domain.NewOrderEvent(e.Timestamp, domain.PooledOrder(order)))
+		ev.Annotate("source", source)
+		if err := src.Inject(ev, e.SeqNo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadExternalFlow reads the orders in an event log. Only ORDER_ACCEPTED
+// records are taken; trades, quotes and the rest are derived by the run,
+// so a previous run's events.jsonl can be replayed as a recorded flow
+func loadExternalFlow(path string) ([]*domain.Event, error) {
+	reader, err := eventlog.NewReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	var events []*domain.Event
+	for {
+		e, err := reader.Next()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("external flow %s: %w", path, err)
+		}
+		if e.Type == domain.EventOrderAccepted {
+			events = append(events, e)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package sim
+
+import "github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+
+// flowWindow keeps the trades of the last window nanoseconds, for the
+// trade flow attached to each signal. A trade is buyer-initiated when the
+// buy order took liquidity and seller-initiated when the sell order did;
+// auction crosses have no aggressor and only add to the volume
+type flowWindow struct {
+	window int64
+	trades []flowTrade // oldest first
+}
+
+type flowTrade struct {
+	ts     int64
+	qty    int64
+	signed int64
+}
+
+func (f *flowWindow) add(ts int64, trade *domain.Trade) {
+	t := flowTrade{ts: ts, qty: trade.Qty}
+	switch trade.AggressorOrderID {
+	case 0:
+	case trade.BuyOrderID:
+		t.signed = trade.Qty
+	case trade.SellOrderID:
+		t.signed = -trade.Qty
+	}
+	f.trades = append(f.trades, t)
+}
+
+// at drops trades older than the window before ts and sums the rest
+func (f *flowWindow) at(ts int64) (flow, volume int64) {
+	drop := 0
+	for drop < len(f.trades) && f.trades[drop].ts <= ts-f.window {
+		drop++
+	}
+	f.trades = f.trades[drop:]
+	for _, t := range f.trades {
+		flow += t.signed
+		volume += t.qty
+	}
+	return flow, volume
+}
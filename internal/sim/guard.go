@@ -0,0 +1,106 @@
+package sim
+
+import (
+	"fmt"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+// GuardError reports a run aborted by a scenario guard. Like a failed
+// assertion, the log up to the aborting event is left in OutputDir
+type GuardError struct {
+	Guard     string // one_sided_ns, max_backlog or no_trade_ns
+	Timestamp int64
+	SeqNo     uint64
+	Detail    string // what the guard saw
+	OutputDir string
+}
+
+func (e *GuardError) Error() string {
+	return fmt.Sprintf("guard %s tripped at t=%.3fms (seq %d): %s",
+		e.Guard, float64(e.Timestamp)/1e6, e.SeqNo, e.Detail)
+}
+
+// guardState is what the guards track as the run goes
+type guardState struct {
+	scenario.Guards
+
+	// The book has had both sides, and since when one has been empty;
+	// -1 while both have orders
+	armed    bool
+	oneSided int64
+
+	lastTrade int64
+
+	// Events scheduled before the loop started (SeqNo up to upfrontMax)
+	// and not yet handled; the rest of the queue is the backlog
+	upfrontMax uint64
+	upfront    int
+}
+
+// startGuards records the events scheduled up front, the last of which
+// is end. Call it just before the loop runs
+func (r *Runner) startGuards(end *domain.Event) {
+	r.guards.oneSided = -1
+	r.guards.upfrontMax = end.SeqNo
+	r.guards.upfront = r.loop.Pending()
+}
+
+// checkGuards runs after each handled event, with the events it is
+// about to schedule. The first guard tripped stops the run
+func (r *Runner) checkGuards(event *domain.Event, scheduled int) {
+	g := &r.guards
+	if event.SeqNo <= g.upfrontMax {
+		g.upfront--
+	}
+	if g.IsZero() || r.closed || r.guard != nil {
+		return
+	}
+	ts := event.Timestamp
+	bbo := r.currentBBO
+
+	if bbo.BidQty > 0 && bbo.AskQty > 0 {
+		g.armed, g.oneSided = true, -1
+	} else if g.armed && g.oneSided < 0 {
+		g.oneSided = ts
+	}
+
+	switch {
+	case g.OneSidedNs > 0 && g.oneSided >= 0 && ts-g.oneSided > g.OneSidedNs:
+		empty := "bid"
+		if bbo.BidQty > 0 {
+			empty = "ask"
+		}
+		if bbo.BidQty == 0 && bbo.AskQty == 0 {
+			empty = "bid and ask"
+		}
+		r.tripGuard("one_sided_ns", event, fmt.Sprintf("%s side empty for %s, since t=%.3fms (limit %s); bid %s x %d, ask %s x %d",
+			empty, latency.Format(ts-g.oneSided), float64(g.oneSided)/1e6, latency.Format(g.OneSidedNs),
+			r.cfg.Scale().Format(bbo.BidPrice), bbo.BidQty, r.cfg.Scale().Format(bbo.AskPrice), bbo.AskQty))
+
+	case g.MaxBacklog > 0 && r.loop.Pending()+scheduled-g.upfront > g.MaxBacklog:
+		r.tripGuard("max_backlog", event, fmt.Sprintf("%d events queued by the run (limit %d), %d more scheduled up front",
+			r.loop.Pending()+scheduled-g.upfront, g.MaxBacklog, g.upfront))
+
+	case g.NoTradeNs > 0 && ts-g.lastTrade > g.NoTradeNs:
+		last := "since the start"
+		if g.lastTrade > 0 {
+			last = fmt.Sprintf("since t=%.3fms", float64(g.lastTrade)/1e6)
+		}
+		r.tripGuard("no_trade_ns", event, fmt.Sprintf("no trade for %s %s (limit %s); %d trades in all",
+			latency.Format(ts-g.lastTrade), last, latency.Format(g.NoTradeNs), r.tradeCount()))
+	}
+}
+
+func (r *Runner) tripGuard(name string, event *domain.Event, detail string) {
+	r.guard = &GuardError{
+		Guard:     name,
+		Timestamp: event.Timestamp,
+		SeqNo:     event.SeqNo,
+		Detail:    detail,
+		OutputDir: r.outputDir,
+	}
+	r.loop.Stop()
+}
@@ -0,0 +1,187 @@
+package sim
+
+import (
+	"math"
+	"sort"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/trader"
+)
+
+// The hedge leg (scenario.Hedge). Each primary fill of the fast or slow
+// trader sends a hedge of the opposite side on the future, decided at the
+// fill and arriving after the trader's base latency plus jitter from its
+// own hedge substream, so hedging draws nothing from the primary's RNGs.
+// It executes at the dealer's quote around fair value at arrival. The
+// future has no book and its trades are not logged: the event log is
+// the one the run would write without a hedge leg. Hedge slippage is
+// measured against fair value at the fill and split into the half spread
+// and the drift of fair value in flight, the part latency is to blame for
+
+// HedgeLeg is a trader's hedging over a run; money is in the instrument's
+// currency, and bps are of fair value at the fill
+type HedgeLeg struct {
+	TraderID    string  `json:"trader_id"`
+	Hedges      int     `json:"hedges"`
+	Qty         int64   `json:"qty"`
+	AvgDelayNs  float64 `json:"avg_delay_ns"`
+	SlippageBps float64 `json:"slippage_bps"`
+	LatencyBps  float64 `json:"latency_bps"` // fair value's drift while in flight
+	SpreadBps   float64 `json:"spread_bps"`
+	Cost        float64 `json:"cost"` // slippage times qty
+	LatencyCost float64 `json:"latency_cost"`
+	InFlight    int     `json:"in_flight"` // sent but not arrived by the close; never executed
+	Position    int64   `json:"position"`  // in the future, at the close
+	PnL         float64 `json:"pnl"`       // marked at fair value at the close
+}
+
+// pendingHedge is a hedge on its way to the dealer
+type pendingHedge struct {
+	traderID string
+	side     domain.Side
+	qty      int64
+	decided  int64
+	arrival  int64
+	fair     int64 // fair value at the fill
+}
+
+// hedgeAccount is what the runner tracks of a trader's hedging
+type hedgeAccount struct {
+	leg            HedgeLeg
+	cash, position int64
+	delay          int64
+	notional       int64 // qty times fair value at the fill
+	slip, drift    int64 // qty times price units
+}
+
+// hedger runs the hedge leg
+type hedger struct {
+	cfg      scenario.Hedge
+	pending  []pendingHedge // by arrival, then in the order sent
+	accounts map[string]*hedgeAccount
+	lastMid  int64
+}
+
+func newHedger(cfg *scenario.Config) *hedger {
+	if cfg.Hedge == nil {
+		return nil
+	}
+	h := &hedger{cfg: *cfg.Hedge, accounts: make(map[string]*hedgeAccount)}
+	for _, id := range []string{cfg.FastTrader.ID, cfg.SlowTrader.ID} {
+		h.accounts[id] = &hedgeAccount{leg: HedgeLeg{TraderID: id}}
+	}
+	return h
+}
+
+// bps is v basis points of p, rounded to price units
+func bps(p int64, v float64) int64 {
+	return int64(math.Round(float64(p) * v / 10_000))
+}
+
+// fair is the future's fair value on the primary's current mid, or the
+// last one while a side of the book is empty
+func (h *hedger) fair(bbo *domain.BBO, fallback int64) int64 {
+	if bbo.BidPrice > 0 && bbo.AskPrice > 0 {
+		h.lastMid = (bbo.BidPrice + bbo.AskPrice) / 2
+	}
+	mid := h.lastMid
+	if mid == 0 {
+		mid = fallback
+	}
+	return mid + bps(mid, h.cfg.BasisBps)
+}
+
+// hedgeFill sends the hedges of a primary trade for whichever of its
+// sides is the fast or slow trader
+func (r *Runner) hedgeFill(ts int64, trade *domain.Trade) {
+	h := r.hedger
+	if h == nil {
+		return
+	}
+	qty := int64(math.Round(float64(trade.Qty) * h.cfg.HedgeRatio()))
+	if qty <= 0 {
+		return
+	}
+	fair := h.fair(r.currentBBO, trade.Price)
+	for _, leg := range []struct {
+		id   string
+		side domain.Side
+	}{{trade.BuyTrader, domain.Sell}, {trade.SellTrader, domain.Buy}} {
+		agent := r.agent(leg.id)
+		if agent == nil || h.accounts[leg.id] == nil {
+			continue
+		}
+		arrival := ts + agent.Latency.BaseNs
+		if agent.Latency.JitterNs > 0 {
+			arrival += agent.Rand(trader.StreamHedge).Int63n(agent.Latency.JitterNs)
+		}
+		p := pendingHedge{traderID: leg.id, side: leg.side, qty: qty, decided: ts, arrival: arrival, fair: fair}
+		i := sort.Search(len(h.pending), func(i int) bool { return h.pending[i].arrival > arrival })
+		h.pending = append(h.pending, pendingHedge{})
+		copy(h.pending[i+1:], h.pending[i:])
+		h.pending[i] = p
+	}
+}
+
+// settleHedges executes the hedges that have arrived by ts, against the
+// book as it stood before the event at ts
+func (r *Runner) settleHedges(ts int64) {
+	h := r.hedger
+	n := 0
+	for n < len(h.pending) && h.pending[n].arrival <= ts {
+		p := h.pending[n]
+		fair := h.fair(r.currentBBO, p.fair)
+		half := bps(fair, h.cfg.HalfSpreadBps)
+		price, dir := fair+half, int64(1)
+		if p.side == domain.Sell {
+			price, dir = fair-half, -1
+		}
+		a := h.accounts[p.traderID]
+		a.leg.Hedges++
+		a.leg.Qty += p.qty
+		a.cash -= dir * p.qty * price
+		a.position += dir * p.qty
+		a.delay += p.arrival - p.decided
+		a.notional += p.qty * p.fair
+		a.slip += dir * p.qty * (price - p.fair)
+		a.drift += dir * p.qty * (fair - p.fair)
+		n++
+	}
+	h.pending = h.pending[n:]
+}
+
+// hedgeLegs reports each trader's hedging, fast trader first, with the
+// hedges still in flight at the close counted but not executed
+func (r *Runner) hedgeLegs() []HedgeLeg {
+	h := r.hedger
+	if h == nil {
+		return nil
+	}
+	scale := r.cfg.Scale()
+	mark := h.fair(r.currentBBO, h.lastMid)
+	var out []HedgeLeg
+	for _, id := range []string{r.fastAgent.ID, r.slowAgent.ID} {
+		a := h.accounts[id]
+		leg := a.leg
+		for _, p := range h.pending {
+			if p.traderID == id {
+				leg.InFlight++
+			}
+		}
+		if leg.Hedges > 0 {
+			leg.AvgDelayNs = float64(a.delay) / float64(leg.Hedges)
+		}
+		if a.notional > 0 {
+			leg.SlippageBps = float64(a.slip) / float64(a.notional) * 10_000
+			leg.LatencyBps = float64(a.drift) / float64(a.notional) * 10_000
+			leg.SpreadBps = float64(a.slip-a.drift) / float64(a.notional) * 10_000
+		}
+		leg.Cost = scale.ToFloat(a.slip)
+		leg.LatencyCost = scale.ToFloat(a.drift)
+		leg.Position = a.position
+		leg.PnL = scale.ToFloat(a.cash + a.position*mark)
+		out = append(out, leg)
+	}
+	return out
+}
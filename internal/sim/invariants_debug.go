@@ -0,0 +1,7 @@
+//go:build fairsimdebug
+
+package sim
+
+// defaultCheckInvariants enables the full O(book) invariant walk after
+// every order in debug builds (go build -tags fairsimdebug)
+const defaultCheckInvariants = true
@@ -0,0 +1,7 @@
+//go:build !fairsimdebug
+
+package sim
+
+// defaultCheckInvariants leaves the full invariant walk off by default;
+// only the O(1) top-of-book checks run per order
+const defaultCheckInvariants = false
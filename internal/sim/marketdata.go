@@ -0,0 +1,46 @@
+package sim
+
+import (
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/trader"
+)
+
+// publishTrade sends a trade print to each trader that wants market data,
+// after its market-data latency. Prints are anonymous: the order IDs stay,
+// so a sweep's prints can be told apart, but the trader IDs go
+func (r *Runner) publishTrade(ts int64, trade *domain.Trade) {
+	for _, agent := range [2]*trader.Agent{r.fastAgent, r.slowAgent} {
+		if !agent.WantsMarketData() || r.closed {
+			continue
+		}
+		tick := *trade
+		tick.BuyTrader, tick.SellTrader = "", ""
+		e := domain.NewMarketDataEvent(ts+agent.MarketDataNs, agent.ID, &tick)
+		r.loop.Schedule(domain.PooledEvent(e))
+	}
+}
+
+// publishBBO sends a BBO update to each trader that wants market data,
+// after its market-data latency
+func (r *Runner) publishBBO(ts int64, bbo *domain.BBO) {
+	for _, agent := range [2]*trader.Agent{r.fastAgent, r.slowAgent} {
+		if !agent.WantsMarketData() || r.closed {
+			continue
+		}
+		quote := *bbo
+		e := domain.NewMarketDataEvent(ts+agent.MarketDataNs, agent.ID, &quote)
+		r.loop.Schedule(domain.PooledEvent(e))
+	}
+}
+
+// handleMarketData hands a tick to the trader it reached and schedules
+// its response. Ticks are not logged: the BBO update or trade they copy is
+func (r *Runner) handleMarketData(event *domain.Event) []*domain.Event {
+	agent := r.agent(event.TraderID)
+	if agent == nil || r.closed {
+		return nil
+	}
+	md := &trader.MarketData{Published: event.Timestamp - agent.MarketDataNs, BBO: event.BBO(), Trade: event.Trade()}
+	r.Faults.strategyCall()
+	return r.schedule(agent, agent.OnMarketData(md, event.Timestamp))
+}
@@ -0,0 +1,75 @@
+package sim
+
+import (
+	"time"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/engine"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+// MemoryResult is the output of an in-memory run
+type MemoryResult struct {
+	Config     *scenario.Config
+	EventCount uint64
+	Duration   time.Duration
+
+	// Events is the event log as events.jsonl would hold it, and LogHash
+	// that file's hash, so a memory run can be checked against a disk run
+	Events  []*domain.Event
+	LogHash string
+	Trades  []domain.Trade
+
+	// Metrics are computed from Events as from a run's log; nil when the
+	// log level leaves out what they need
+	Metrics map[string]*metrics.TraderMetrics
+
+	Ties      []engine.TiePair
+	Capital   []CapitalUsage
+	Hedge     []HedgeLeg
+	Truncated *Truncation
+}
+
+// RunInMemory runs cfg without touching the filesystem: no run directory,
+// event log or other artifacts are written, and the log and trades are
+// returned instead. It is for library use and tests that run many small
+// simulations. Failures are reported as by Runner.Run, with no output
+// directory to inspect
+func RunInMemory(cfg *scenario.Config) (*MemoryResult, error) {
+	r, err := newRunner(cfg, "")
+	if err != nil {
+		return nil, err
+	}
+	r.simulate()
+	if r.err != nil {
+		return nil, &RunError{SeqNo: r.seqNo(r.currentSeq), Timestamp: r.loop.CurrentTime, Err: r.err}
+	}
+	if r.failure != nil {
+		return nil, r.failure
+	}
+	if r.guard != nil {
+		return nil, r.guard
+	}
+	wall := time.Since(r.startWall)
+
+	events := r.logWriter.Events()
+	res := &MemoryResult{
+		Config:     r.cfg,
+		EventCount: r.loop.EventsProcessed,
+		Duration:   wall,
+		Events:     events,
+		LogHash:    r.logWriter.Hash(),
+		Trades:     r.trades,
+		Ties:       r.ties.Pairs(),
+		Capital:    r.capitalUsage(),
+		Hedge:      r.hedgeLegs(),
+		Truncated:  r.truncation,
+	}
+	if metrics.CheckLevel(r.logLevel) == nil {
+		if res.Metrics, err = metrics.ComputeEvents(events, r.cfg.Scale(), r.cfg.Metrics); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,146 @@
+package sim
+
+import (
+	"fmt"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+// The pipelined run has three stages joined by bounded channels:
+//
+//	generator goroutine -> batches of background events -> event loop
+//	event loop          -> copied events -> event log goroutine
+//
+// The event loop takes each batch as it arrives and handles an event as
+// soon as no background event still to come can go before it. Batches
+// come in timestamp order, so that is an event earlier than the latest
+// background event in, or a background event itself at that time.
+//
+// A serial run numbers the re-quote timers, SIM_END and every event
+// scheduled while the loop runs after the last background event, and the
+// log records those SeqNos. While the generator runs their count is not
+// known, so the loop numbers them on from pendingSeqBase, and the event
+// and decision logs hold their records back. When the generator is done
+// the held records are renumbered as a serial run numbers them and
+// written, and the log goes on alongside the loop. The log matches a
+// serial run byte for byte; only its writing waits for generation
+
+// pipelineDepth bounds each channel, in batches
+const pipelineDepth = 8
+
+// pendingSeqBase is where SeqNos assigned before the number of background
+// events is known start. It sits far above any real run's SeqNos, so such
+// events still sort after every background event at their time
+const pendingSeqBase = uint64(1) << 62
+
+// streamBackground runs the generator on its own goroutine and delivers
+// its events in generation order
+func streamBackground(gen scenario.Generator) <-chan []*domain.Event {
+	ch := make(chan []*domain.Event, pipelineDepth)
+	go func() {
+		defer close(ch)
+		gen.Stream(func(batch []*domain.Event) {
+			ch <- batch
+		})
+	}()
+	return ch
+}
+
+// backgroundFeed schedules the generator's batches into the loop as the
+// loop runs
+type backgroundFeed struct {
+	r      *Runner
+	ch     <-chan []*domain.Event
+	record *eventlog.Writer
+	seq    uint64 // SeqNo of the last background event scheduled
+	latest int64  // latest background timestamp scheduled
+	done   bool
+}
+
+// feedBackground starts the generator and has the loop wait on it. The
+// background SeqNos follow the ones already assigned; what the loop
+// schedules next is numbered from pendingSeqBase
+func (r *Runner) feedBackground(gen scenario.Generator, record *eventlog.Writer) {
+	r.feed = &backgroundFeed{r: r, ch: streamBackground(gen), record: record, seq: r.loop.SeqNo(), latest: -1}
+	r.loop.SetSeqNo(pendingSeqBase)
+	r.logWriter.HoldSeqNos(pendingSeqBase)
+	r.loop.Await = r.feed.await
+}
+
+// await takes whatever batches have arrived, then waits for more until
+// the first queued event is safe to handle
+func (f *backgroundFeed) await() {
+	for !f.done {
+		select {
+		case batch, ok := <-f.ch:
+			f.take(batch, ok)
+			continue
+		default:
+		}
+		if next := f.r.loop.Peek(); next != nil && f.ready(next) {
+			return
+		}
+		batch, ok := <-f.ch
+		f.take(batch, ok)
+	}
+}
+
+// ready reports whether no background event still to come goes before e
+func (f *backgroundFeed) ready(e *domain.Event) bool {
+	return e.Timestamp < f.latest || e.Timestamp == f.latest && e.SeqNo <= f.seq
+}
+
+// take schedules a batch, or finishes the feed once the channel is closed
+func (f *backgroundFeed) take(batch []*domain.Event, ok bool) {
+	if !ok {
+		f.finish()
+		return
+	}
+	for _, e := range batch {
+		if f.record != nil {
+			if err := f.record.Write(e); err != nil {
+				f.r.fail(fmt.Errorf("write flow record: %w", err))
+			}
+		}
+		f.seq++
+		e.SeqNo = f.seq
+		f.r.loop.ScheduleWithSeqNo(e)
+		f.latest = max(f.latest, e.Timestamp)
+	}
+	f.r.guards.upfront += len(batch)
+}
+
+// drain waits for the generator to finish when the loop stopped first
+func (f *backgroundFeed) drain() {
+	for !f.done {
+		batch, ok := <-f.ch
+		f.take(batch, ok)
+	}
+}
+
+// finish closes the flow record and, with the number of background events
+// known, renumbers and writes what the logs held back
+func (f *backgroundFeed) finish() {
+	f.done = true
+	f.r.loop.Await = nil
+	if f.record != nil {
+		if err := f.record.Close(); err != nil {
+			f.r.fail(fmt.Errorf("write flow record: %w", err))
+		}
+	}
+	f.r.seqBase = f.seq
+	if err := f.r.logWriter.Renumber(f.seq); err != nil {
+		f.r.fail(fmt.Errorf("write event log: %w", err))
+	}
+	f.r.flushDecisions()
+}
+
+// seqNo is the SeqNo a serial run gives the event the loop numbered seq
+func (r *Runner) seqNo(seq uint64) uint64 {
+	if r.feed != nil && seq > pendingSeqBase {
+		return seq - pendingSeqBase + r.seqBase
+	}
+	return seq
+}
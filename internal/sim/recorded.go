@@ -0,0 +1,84 @@
+package sim
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+// flowFile is the recorded background flow a run writes with RecordFlow
+const flowFile = "flow.jsonl"
+
+// recordedFlow is a scenario.Generator that hands back a recorded flow.
+// Scheduled in the recorded order, it gets the same seq numbers as the
+// events it was recorded from
+type recordedFlow struct {
+	events []*domain.Event
+}
+
+func (f *recordedFlow) Generate() []*domain.Event { return f.events }
+
+func (f *recordedFlow) Stream(emit func([]*domain.Event)) { emit(f.events) }
+
+// loadFlow reads the flow recorded by RecordFlow that cfg names. Only
+// background orders and signals belong in one; a run's events.jsonl is
+// refused, since its records are in handling order, not schedule order
+func loadFlow(cfg *scenario.Config) ([]*domain.Event, error) {
+	path := cfg.BackgroundFrom
+	reader, err := eventlog.NewReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	var events []*domain.Event
+	for {
+		e, err := reader.Next()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("background flow %s: %w", path, err)
+		}
+		switch e.Type {
+		case domain.EventOrderAccepted:
+			if o := e.Order(); o == nil || o.TraderID == cfg.FastTrader.ID || o.TraderID == cfg.SlowTrader.ID {
+				return nil, fmt.Errorf("background flow %s: seq %d: not a background order", path, e.SeqNo)
+			}
+		case domain.EventSignal:
+		default:
+			return nil, fmt.Errorf("background flow %s: %s record; want a flow written by --record-flow", path, e.Type)
+		}
+		// Whatever lies past this run's close would never be handled
+		if e.Timestamp <= cfg.Duration {
+			events = append(events, e)
+		}
+	}
+}
+
+// generator is the recorded flow when the config names one, otherwise
+// the scenario's generator
+func (r *Runner) generator() scenario.Generator {
+	if r.background != nil {
+		return r.background
+	}
+	if r.cfg.BackgroundFrom != "" {
+		return &recordedFlow{events: r.recorded}
+	}
+	return scenario.NewGenerator(r.cfg)
+}
+
+// openFlowRecord creates flow.jsonl when RecordFlow is set
+func (r *Runner) openFlowRecord() (*eventlog.Writer, error) {
+	if !r.RecordFlow {
+		return nil, nil
+	}
+	w, err := eventlog.NewWriter(filepath.Join(r.outputDir, flowFile))
+	if err != nil {
+		return nil, fmt.Errorf("create flow record: %w", err)
+	}
+	return w, nil
+}
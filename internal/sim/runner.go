@@ -3,20 +3,28 @@
 package sim
 
 import (
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/akshitanchan/execution-fairness-simulator/internal/assertion"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/audit"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/dataset"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/engine"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/heatmap"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/ledger"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/lifecycle"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/orderbook"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/provenance"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/trader"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/venue"
 )
 
 // RunResult holds the output of a simulation run
@@ -29,6 +37,27 @@ type RunResult struct {
 	LogPath    string           `json:"log_path"`
 	LogHash    string           `json:"log_hash"`
 	OutputDir  string           `json:"output_dir"`
+
+	// AuditViolations counts accounting violations found in the log;
+	// details are in audit.json
+	AuditViolations int `json:"audit_violations"`
+
+	// Ties are the traders' messages that reached the venue at the same
+	// timestamp, which the loop ordered by SeqNo
+	Ties []engine.TiePair `json:"ties,omitempty"`
+
+	// Capital is the use of their capital by the traders with a limit
+	Capital []CapitalUsage `json:"capital,omitempty"`
+
+	// Hedge is each trader's hedging on the future, in runs with a hedge
+	// leg
+	Hedge []HedgeLeg `json:"hedge,omitempty"`
+
+	// Truncated is set when a time budget ended the run early
+	Truncated *Truncation `json:"truncated,omitempty"`
+
+	// Provenance says what produced the run; it is also in provenance.json
+	Provenance *provenance.Stamp `json:"provenance"`
 }
 
 // Runner executes a simulation
@@ -37,141 +66,595 @@ type Runner struct {
 	book      *orderbook.Book
 	loop      *engine.EventLoop
 	logWriter *eventlog.Writer
+	logLevel  eventlog.Level
+
+	// Next depth snapshot time, at LevelDepth and above
+	nextDepth int64
 
 	fastAgent *trader.Agent
 	slowAgent *trader.Agent
 
-	// Current BBO for signal dispatch
+	// Current BBO and recent trades for signal dispatch
 	currentBBO *domain.BBO
+	flow       *flowWindow
 
-	// Collected trades
-	trades []domain.Trade
+	// Trades are streamed to trades.json rather than retained in memory
+	tradeWriter *tradeWriter
+	tape        *tapeWriter
 
-	// Output directory
+	// Output directory. A memory run has none, and keeps its trades
+	memory    bool
 	outputDir string
+	trades    []domain.Trade
+
+	// Order lifecycle, advanced by every logged event
+	lifecycle *lifecycle.Tracker
+
+	// Per-trader cash, position, fees and PnL, booked from every trade
+	ledger *ledger.Ledger
+
+	// Capital limits of the traders that have one, by trader ID
+	capital map[string]*capitalAccount
+
+	// The hedge leg, nil without one
+	hedger *hedger
+
+	// Scenario assertions and the first one that failed
+	checker *assertion.Checker
+	failure *assertion.Failure
+
+	// Scenario guards and the one that aborted the run
+	guards guardState
+	guard  *GuardError
+
+	// The venue's mechanisms stand between arriving messages and the book
+	venue *venue.Venue
+
+	// Same-timestamp collisions between traders' messages
+	ties *engine.TieAudit
+
+	// The generator feeding a pipelined run, and the SeqNo of the last
+	// background event once it is known
+	feed    *backgroundFeed
+	seqBase uint64
+
+	// closed is set once SIM_END is handled
+	closed bool
+
+	// First error that stopped the run, and the event being handled
+	err         error
+	currentSeq  uint64
+	currentType domain.EventType
+
+	// CheckInvariants runs the full book invariant walk after every order
+	// When false only cheap top-of-book and per-order checks are performed
+	CheckInvariants bool
+
+	// StdJSON writes the event log with encoding/json instead of the
+	// hand-rolled encoder. The bytes are identical; use it to cross-check
+	StdJSON bool
+
+	// Pipeline runs scenario generation and event log serialization on
+	// their own goroutines, alongside the event loop. The log is
+	// byte-identical to a serial run
+	Pipeline bool
+
+	// NoLastRun leaves <runs>/last-run alone. Set it for runs that share
+	// a runs directory concurrently, which would race to rewrite it
+	NoLastRun bool
+
+	// Faults injects failures for robustness testing; nil in normal runs
+	Faults *Faults
+
+	// HeatmapIntervalNs samples the book's full depth on this grid and
+	// writes it to heatmap.csv, or heatmap.npy with HeatmapNPY; 0 is off.
+	// The event log is unchanged
+	HeatmapIntervalNs int64
+	HeatmapNPY        bool
+	heatmap           *heatmap.Sampler
+
+	// DecisionLog writes each trader's strategy decisions to
+	// decisions_<id>.jsonl. The event log is unchanged
+	DecisionLog bool
+	decisions   []*decisionWriter
+
+	// RecordFlow writes the background flow the run schedules, orders and
+	// signals in schedule order, to flow.jsonl. Config.BackgroundFrom
+	// reruns it; the event log is unchanged
+	RecordFlow bool
+	recorded   []*domain.Event
+
+	// background, if set, stands in for the generator; tests use it
+	// to pace a pipelined run's flow
+	background scenario.Generator
+
+	// decided, if set, sees each decision's orders before they are sent
+	// and returns the orders to send; tests use it to add one
+	decided func(agent *trader.Agent, orders []*domain.Order) []*domain.Order
+
+	// When the run started, for the wall time budget, and the budget
+	// limit that ended it early
+	startWall  time.Time
+	truncation *Truncation
 }
 
 // NewRunner creates a simulation runner
 func NewRunner(cfg *scenario.Config, baseOutputDir string) (*Runner, error) {
 	runID := fmt.Sprintf("%s_seed%d", cfg.Name, cfg.Seed)
-	outputDir := filepath.Join(baseOutputDir, runID)
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return nil, fmt.Errorf("create output dir: %w", err)
-	}
+	return newRunner(cfg, filepath.Join(baseOutputDir, runID))
+}
 
-	logPath := filepath.Join(outputDir, "events.jsonl")
-	logWriter, err := eventlog.NewWriter(logPath)
+// newRunner creates a runner writing to outputDir, or, if it is empty, one
+// that keeps its log and trades in memory and creates no files
+func newRunner(cfg *scenario.Config, outputDir string) (*Runner, error) {
+	if err := cfg.Scale().Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Metrics.Validate(); err != nil {
+		return nil, err
+	}
+	for _, tc := range []scenario.TraderConfig{cfg.FastTrader, cfg.SlowTrader} {
+		if err := tc.Strategy.Validate(); err != nil {
+			return nil, fmt.Errorf("%s trader: %w", tc.ID, err)
+		}
+		if tc.GatewayNs < 0 {
+			return nil, fmt.Errorf("%s trader: gateway_ns must not be negative", tc.ID)
+		}
+		if tc.MarketDataNs < 0 {
+			return nil, fmt.Errorf("%s trader: market_data_ns must not be negative", tc.ID)
+		}
+		if tc.RiskCheckNs < 0 {
+			return nil, fmt.Errorf("%s trader: risk_check_ns must not be negative", tc.ID)
+		}
+		if err := validateCapital(tc, cfg.Scale()); err != nil {
+			return nil, fmt.Errorf("%s trader: %w", tc.ID, err)
+		}
+		if tc.IdleNs < 0 || tc.WarmupNs < 0 || tc.HandshakeNs < 0 {
+			return nil, fmt.Errorf("%s trader: idle_ns, warmup_ns and handshake_ns must not be negative", tc.ID)
+		}
+		if tc.WarmupNs > 0 && tc.IdleNs == 0 {
+			return nil, fmt.Errorf("%s trader: warmup_ns needs idle_ns, the quiet time after which it applies", tc.ID)
+		}
+	}
+	if err := cfg.Guards.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Budget.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Hedge != nil {
+		if err := cfg.Hedge.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := latency.ValidateJitterMode(cfg.JitterMode); err != nil {
+		return nil, err
+	}
+	if err := cfg.ValidateSessions(); err != nil {
+		return nil, err
+	}
+	if err := cfg.ValidateBackground(); err != nil {
+		return nil, err
+	}
+	level, err := eventlog.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	v, err := venue.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	accounts := ledger.New(v.MakerFee, v.TakerFee)
+	checker, err := assertion.NewChecker(cfg.Assertions, cfg.Scale(), accounts)
 	if err != nil {
-		return nil, fmt.Errorf("create event log: %w", err)
+		return nil, err
+	}
+
+	// Read a recorded flow before creating the run's files: it may be the
+	// flow.jsonl of an earlier run into this same directory
+	var recorded []*domain.Event
+	if cfg.BackgroundFrom != "" {
+		if recorded, err = loadFlow(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	memory := outputDir == ""
+	var (
+		logWriter   *eventlog.Writer
+		tradeWriter *tradeWriter
+		tape        *tapeWriter
+	)
+	if memory {
+		logWriter = eventlog.NewMemoryWriter()
+	} else {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return nil, fmt.Errorf("create output dir: %w", err)
+		}
+		logPath := filepath.Join(outputDir, "events.jsonl")
+		if logWriter, err = eventlog.NewWriter(logPath); err != nil {
+			return nil, fmt.Errorf("create event log: %w", err)
+		}
+		if tradeWriter, err = newTradeWriter(filepath.Join(outputDir, "trades.json")); err != nil {
+			logWriter.Close()
+			return nil, err
+		}
+		if tape, err = newTapeWriter(filepath.Join(outputDir, "tape.csv"), cfg.Scale()); err != nil {
+			logWriter.Close()
+			tradeWriter.Close()
+			return nil, err
+		}
 	}
 
 	r := &Runner{
-		cfg:        cfg,
-		book:       orderbook.New(),
-		logWriter:  logWriter,
-		outputDir:  outputDir,
-		currentBBO: &domain.BBO{},
+		cfg:         cfg,
+		book:        v.Book,
+		venue:       v,
+		logWriter:   logWriter,
+		logLevel:    level,
+		nextDepth:   depthInterval,
+		tradeWriter: tradeWriter,
+		tape:        tape,
+		memory:      memory,
+		outputDir:   outputDir,
+		currentBBO:  &domain.BBO{},
+		flow:        &flowWindow{window: cfg.Scenario.FlowWindowNs()},
+		recorded:    recorded,
+		checker:     checker,
+		guards:      guardState{Guards: cfg.Guards},
+		lifecycle:   lifecycle.New(),
+		ledger:      accounts,
+		capital:     newCapital(cfg),
+		hedger:      newHedger(cfg),
+		ties:        engine.NewTieAudit(),
+
+		CheckInvariants: defaultCheckInvariants,
+		Pipeline:        true,
 	}
 
+	v.Host = venueHost{r}
+
 	r.loop = engine.NewEventLoop(r.handleEvent)
+	r.loop.Release = r.releaseEvent
 
 	// Create trader agents with deterministic seeds derived from main seed
-	fastLat := latency.NewModel(
-		latency.MsToNs(cfg.FastTrader.BaseLatencyMs),
-		latency.MsToNs(cfg.FastTrader.JitterMs),
+	newModel := latency.NewModel
+	if cfg.JitterMode == latency.JitterHashed {
+		newModel = latency.NewHashedModel
+	}
+	fastLat := newModel(
+		cfg.FastTrader.BaseLatencyNs(),
+		cfg.FastTrader.JitterNs(),
 		cfg.Seed+1,
 	)
-	slowLat := latency.NewModel(
-		latency.MsToNs(cfg.SlowTrader.BaseLatencyMs),
-		latency.MsToNs(cfg.SlowTrader.JitterMs),
+	slowLat := newModel(
+		cfg.SlowTrader.BaseLatencyNs(),
+		cfg.SlowTrader.JitterNs(),
 		cfg.Seed+2,
 	)
 
 	r.fastAgent = trader.NewAgent(cfg.FastTrader.ID, fastLat, cfg.Seed+3, 1_000_000)
 	r.slowAgent = trader.NewAgent(cfg.SlowTrader.ID, slowLat, cfg.Seed+4, 2_000_000)
+	for _, a := range []struct {
+		agent *trader.Agent
+		tc    scenario.TraderConfig
+	}{{r.fastAgent, cfg.FastTrader}, {r.slowAgent, cfg.SlowTrader}} {
+		a.agent.SignalNoise = a.tc.SignalNoise
+		a.agent.SignalDelayNs = a.tc.SignalDelayNs
+		a.agent.NoSignal = a.tc.NoSignal
+		a.agent.MarketDataNs = a.tc.MarketDataLatencyNs()
+		a.agent.Path = orderPath(a.tc, a.agent.Latency)
+		applyStrategy(a.agent.Strategy, a.tc.Strategy)
+	}
+
+	if cfg.ExternalFlow != "" {
+		events, err := loadExternalFlow(cfg.ExternalFlow)
+		if err == nil {
+			err = r.Inject("external_flow", events)
+		}
+		if err != nil {
+			r.closeOutput()
+			return nil, err
+		}
+	}
 
 	return r, nil
 }
 
+// applyStrategy overrides the strategy defaults p sets
+func applyStrategy(s *trader.Strategy, p scenario.StrategyParams) {
+	if p.ReQuoteIntervalNs > 0 {
+		s.ReQuoteIntervalNs = p.ReQuoteIntervalNs
+	}
+	if p.CancelTimeoutNs > 0 {
+		s.CancelTimeoutNs = p.CancelTimeoutNs
+	}
+	if p.CrossThreshold > 0 {
+		s.CrossThreshold = p.CrossThreshold
+	}
+	switch p.CrossTIF {
+	case "ioc":
+		s.CrossTIF = domain.IOC
+	case "fok":
+		s.CrossTIF = domain.FOK
+	}
+	if p.ReduceThreshold > 0 {
+		s.ReduceThreshold = p.ReduceThreshold
+	}
+	if p.TargetQty > 0 {
+		s.TargetQty = p.TargetQty
+	}
+	s.DisplayQty = p.DisplayQty
+	if p.BatchSignals > 1 {
+		s.BatchSignals = p.BatchSignals
+	}
+	if p.PullSweepQty > 0 {
+		s.PullSweepQty = p.PullSweepQty
+	}
+	if p.JoinWiden {
+		s.JoinWiden = true
+	}
+}
+
 // Run executes the simulation and returns results
 func (r *Runner) Run() (*RunResult, error) {
-	startWall := time.Now()
+	r.simulate()
+
+	logPath := filepath.Join(r.outputDir, "events.jsonl")
+	hash := r.logWriter.Hash()
+
+	cfgPath := filepath.Join(r.outputDir, "config.json")
+	cfgData, _ := json.MarshalIndent(r.savedConfig(), "", "  ")
+	os.WriteFile(cfgPath, cfgData, 0644)
+	r.writeSeeds()
+	stamp := provenance.New(cfgData)
+	stamp.LogHash = hash
+	stamp.Write(r.outputDir)
+
+	if r.err != nil {
+		return nil, &RunError{
+			OutputDir: r.outputDir,
+			SeqNo:     r.seqNo(r.currentSeq),
+			Timestamp: r.loop.CurrentTime,
+			Err:       r.err,
+		}
+	}
+
+	if !r.NoLastRun {
+		lastRunPath := filepath.Join(filepath.Dir(r.outputDir), "last-run")
+		if err := os.WriteFile(lastRunPath, []byte(r.outputDir), 0644); err != nil {
+			return nil, fmt.Errorf("write last-run: %w", err)
+		}
+	}
+
+	// The log stops at the failing event; it is left on disk for inspection
+	if r.failure != nil {
+		return nil, r.failure
+	}
+	if r.guard != nil {
+		return nil, r.guard
+	}
+
+	// Wall time covers the simulation itself, not the post-run audit
+	wall := time.Since(r.startWall)
 
-	r.logEvent(&domain.Event{
+	if r.heatmap != nil {
+		name := "heatmap.csv"
+		if r.HeatmapNPY {
+			name = "heatmap.npy"
+		}
+		if err := r.heatmap.Grid().WriteFile(filepath.Join(r.outputDir, name), r.cfg.Scale()); err != nil {
+			return nil, err
+		}
+	}
+
+	// The audit and the feature dataset read every kind of record, so
+	// a reduced log goes without them
+	violations := 0
+	if r.logLevel == eventlog.LevelFull {
+		var err error
+		if violations, err = r.audit(logPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return &RunResult{
+		RunID:           filepath.Base(r.outputDir),
+		Config:          r.cfg,
+		EventCount:      r.loop.EventsProcessed,
+		TradeCount:      r.tradeCount(),
+		Duration:        wall,
+		LogPath:         logPath,
+		LogHash:         hash,
+		OutputDir:       r.outputDir,
+		AuditViolations: violations,
+		Ties:            r.ties.Pairs(),
+		Capital:         r.capitalUsage(),
+		Hedge:           r.hedgeLegs(),
+		Truncated:       r.truncation,
+		Provenance:      stamp,
+	}, nil
+}
+
+// simulate runs the event loop to the end of the run, or its first
+// failure, and closes the log, trades and decision logs. What stopped it
+// is left in r.err, r.failure or r.guard
+func (r *Runner) simulate() {
+	r.startWall = time.Now()
+	r.logWriter.StdJSON = r.StdJSON
+	if wrap := r.Faults.wrapLog(); wrap != nil {
+		r.logWriter.WrapOutput(wrap)
+	}
+	if r.Pipeline {
+		r.logWriter.StartAsync(pipelineDepth)
+	}
+
+	start := &domain.Event{
 		Timestamp: 0,
 		Type:      domain.EventSimStart,
-	})
+	}
+	if r.HeatmapIntervalNs > 0 {
+		r.heatmap = heatmap.NewSampler(r.HeatmapIntervalNs)
+	}
+	if r.DecisionLog {
+		if err := r.openDecisions(); err != nil {
+			r.fail(err)
+		}
+	}
+	eventlog.DeclareLevel(start, r.logLevel)
+	provenance.Declare(start)
+	r.logEvent(start)
+	r.scheduleSessions()
 
-	gen := scenario.NewGenerator(r.cfg)
-	bgEvents := gen.Generate()
-	for _, e := range bgEvents {
-		r.loop.Schedule(e)
+	gen := r.generator()
+	record, err := r.openFlowRecord()
+	if err != nil {
+		r.fail(err)
+	}
+	if r.Pipeline {
+		r.feedBackground(gen, record)
+	} else {
+		for _, e := range gen.Generate() {
+			if record != nil {
+				if err := record.Write(e); err != nil {
+					r.fail(fmt.Errorf("write flow record: %w", err))
+				}
+			}
+			r.loop.Schedule(e)
+		}
+		r.seqBase = r.loop.SeqNo()
+		if record != nil {
+			if err := record.Close(); err != nil {
+				r.fail(fmt.Errorf("write flow record: %w", err))
+			}
+		}
 	}
 
-	// Schedule periodic re-quote events for both traders
-	reQuoteInterval := r.fastAgent.Strategy.ReQuoteIntervalNs
-	if reQuoteInterval > 0 {
-		for t := reQuoteInterval; t < r.cfg.Duration; t += reQuoteInterval {
-			r.loop.Schedule(&domain.Event{
-				Timestamp: t,
-				Type:      domain.EventReQuote,
-				TraderID:  r.fastAgent.ID,
-			})
+	// Schedule periodic re-quote events for both traders, each on its
+	// own strategy's interval
+	for _, agent := range [2]*trader.Agent{r.fastAgent, r.slowAgent} {
+		interval := agent.Strategy.ReQuoteIntervalNs
+		if interval <= 0 {
+			continue
+		}
+		for t := interval; t < r.cfg.Duration; t += interval {
 			r.loop.Schedule(&domain.Event{
 				Timestamp: t,
 				Type:      domain.EventReQuote,
-				TraderID:  r.slowAgent.ID,
+				TraderID:  agent.ID,
 			})
 		}
 	}
 
-	r.loop.Schedule(&domain.Event{
+	end := &domain.Event{
 		Timestamp: r.cfg.Duration,
 		Type:      domain.EventSimEnd,
-	})
+	}
+	r.loop.Schedule(end)
+	r.startGuards(end)
 
-	r.loop.Run()
+	r.runLoop()
+	if r.feed != nil {
+		r.feed.drain()
+		if r.guard != nil {
+			r.guard.SeqNo = r.seqNo(r.guard.SeqNo)
+		}
+		if r.failure != nil {
+			r.failure.SeqNo = r.seqNo(r.failure.SeqNo)
+		}
+	}
 
-	if err := r.logWriter.Close(); err != nil {
-		return nil, fmt.Errorf("close event log: %w", err)
+	// The files are closed even after a failure so partial output is flushed
+	if err := r.closeOutput(); err != nil && r.err == nil {
+		r.err = err
 	}
+	if err := r.closeDecisions(); err != nil && r.err == nil {
+		r.err = err
+	}
+}
 
-	logPath := filepath.Join(r.outputDir, "events.jsonl")
-	hash, err := hashFile(logPath)
+// closeOutput closes the event log, trades file and tape, returning the
+// first error
+func (r *Runner) closeOutput() error {
+	err := r.logWriter.Close()
 	if err != nil {
-		return nil, fmt.Errorf("hash log: %w", err)
+		err = fmt.Errorf("close event log: %w", err)
 	}
+	if r.memory {
+		return err
+	}
+	if cerr := r.tradeWriter.Close(); cerr != nil && err == nil {
+		err = fmt.Errorf("close trades file: %w", cerr)
+	}
+	if cerr := r.tape.Close(); cerr != nil && err == nil {
+		err = fmt.Errorf("close tape: %w", cerr)
+	}
+	return err
+}
 
-	cfgPath := filepath.Join(r.outputDir, "config.json")
-	cfgData, _ := json.MarshalIndent(r.cfg, "", "  ")
-	os.WriteFile(cfgPath, cfgData, 0644)
-
-	tradesPath := filepath.Join(r.outputDir, "trades.json")
-	tradesData, _ := json.MarshalIndent(r.trades, "", "  ")
-	os.WriteFile(tradesPath, tradesData, 0644)
-
-	lastRunPath := filepath.Join(filepath.Dir(r.outputDir), "last-run")
-	os.WriteFile(lastRunPath, []byte(r.outputDir), 0644)
-
-	return &RunResult{
-		RunID:      filepath.Base(r.outputDir),
-		Config:     r.cfg,
-		EventCount: r.loop.EventsProcessed,
-		TradeCount: len(r.trades),
-		Duration:   time.Since(startWall),
-		LogPath:    logPath,
-		LogHash:    hash,
-		OutputDir:  r.outputDir,
-	}, nil
+// audit writes audit.json, audit_trail.csv and features.csv from the log
+// and returns the number of accounting violations
+func (r *Runner) audit(logPath string) (int, error) {
+	audited, err := audit.Log(logPath)
+	if err != nil {
+		return 0, fmt.Errorf("audit event log: %w", err)
+	}
+	if err := audited.WriteFile(filepath.Join(r.outputDir, "audit.json")); err != nil {
+		return 0, fmt.Errorf("write audit: %w", err)
+	}
+	trail, err := audit.TrailLog(logPath, r.cfg.Scale())
+	if err != nil {
+		return 0, err
+	}
+	if err := trail.WriteFile(filepath.Join(r.outputDir, "audit_trail.csv")); err != nil {
+		return 0, err
+	}
+	features, err := dataset.BuildLog(logPath, r.cfg.Scale(), r.cfg.Metrics.Horizons()[0])
+	if err != nil {
+		return 0, err
+	}
+	if err := features.WriteFile(filepath.Join(r.outputDir, "features.csv")); err != nil {
+		return 0, err
+	}
+	return audited.ViolationCount, nil
 }
 
 // handleEvent is the central event dispatcher
 func (r *Runner) handleEvent(event *domain.Event) []*domain.Event {
+	r.currentSeq, r.currentType = event.SeqNo, event.Type
+	if err := event.CheckPayload(); err != nil {
+		r.fail(err)
+		return nil
+	}
 	var newEvents []*domain.Event
+	if r.logLevel >= eventlog.LevelDepth && !r.closed {
+		r.snapshotDepth(event.Timestamp)
+	}
+	if r.heatmap != nil && !r.closed {
+		r.heatmap.Advance(event.Timestamp, r.fullDepth)
+	}
+	if r.hedger != nil && !r.closed {
+		r.settleHedges(event.Timestamp)
+	}
 
 	switch event.Type {
 	case domain.EventOrderAccepted:
-		newEvents = r.handleOrder(event)
+		// Messages still in flight at the close never reach the book
+		if r.closed {
+			break
+		}
+		r.ties.Observe(event)
+		if held, release := r.venue.Admit(event); held {
+			newEvents = release
+		} else {
+			newEvents = r.handleOrder(event)
+		}
+
+	case domain.EventAuction:
+		r.venue.Wake(event.Timestamp)
+
+	case domain.EventSession:
+		r.handleSession(event)
 
 	case domain.EventSignal:
 		newEvents = r.handleSignal(event)
@@ -179,31 +662,59 @@ func (r *Runner) handleEvent(event *domain.Event) []*domain.Event {
 	case domain.EventReQuote:
 		newEvents = r.handleReQuote(event)
 
+	case domain.EventMarketData:
+		newEvents = r.handleMarketData(event)
+
 	case domain.EventSimStart, domain.EventSimEnd:
+		if event.Type == domain.EventSimEnd {
+			r.venue.Wake(event.Timestamp)
+			r.closed = true
+		}
 		r.logEvent(event)
+		if r.truncation != nil {
+			// Whatever is still queued lies past the budget
+			r.loop.Stop()
+		}
 
 	case domain.EventTradeExecuted, domain.EventBBOUpdate, domain.EventOrderCanceled:
 		// These are logged when produced; no further dispatch needed
 	}
 
+	r.checkGuards(event, len(newEvents))
+	if end := r.checkBudget(event); end != nil {
+		newEvents = append(newEvents, end)
+	}
 	return newEvents
 }
 
 // handleOrder processes an incoming order through the matching engine
 func (r *Runner) handleOrder(event *domain.Event) []*domain.Event {
-	order := event.Order
-	if order == nil {
+	order := event.Order()
+	var newEvents []*domain.Event
+	if !r.checkCapital(event) {
 		return nil
 	}
 
-	var newEvents []*domain.Event
-
-	trades, bbo := r.book.ProcessOrder(order, event.Timestamp)
+	var trades []domain.Trade
+	var bbo *domain.BBO
+	var refused error
+	if order.Type == domain.CancelOrder {
+		bbo, refused = r.book.Cancel(order)
+	} else {
+		trades, bbo = r.book.ProcessOrder(order, event.Timestamp)
+	}
 
-	r.book.AssertInvariants()
+	if r.CheckInvariants {
+		r.book.AssertInvariants()
+	} else {
+		r.book.AssertTopOfBook()
+	}
+	if order.RemainingQty < 0 {
+		panic(fmt.Sprintf("negative remaining qty on order %d: %d", order.ID, order.RemainingQty))
+	}
 
 	// Record queue position at placement for limit orders that rested
-	if order.Type == domain.LimitOrder && order.RemainingQty > 0 {
+	if order.Type == domain.LimitOrder && order.RemainingQty > 0 && !order.Immediate() {
 		order.QueuePos = r.book.QueuePosition(order.ID)
 	}
 
@@ -212,7 +723,7 @@ func (r *Runner) handleOrder(event *domain.Event) []*domain.Event {
 
 	// Track trader active orders for limit orders that rest
 	// Must be done BEFORE processing fills so the agent can look up the order
-	if order.Type == domain.LimitOrder {
+	if order.Type == domain.LimitOrder && !order.Immediate() {
 		if order.TraderID == r.fastAgent.ID {
 			r.fastAgent.ActiveOrders[order.ID] = order
 		} else if order.TraderID == r.slowAgent.ID {
@@ -220,13 +731,10 @@ func (r *Runner) handleOrder(event *domain.Event) []*domain.Event {
 		}
 	}
 
-	if order.Type == domain.CancelOrder {
-		cancelEvent := &domain.Event{
-			Timestamp: event.Timestamp,
-			Type:      domain.EventOrderCanceled,
-			Order:     order,
-		}
-		r.logEvent(cancelEvent)
+	// A refused cancel gets no ack; the lifecycle tracker logs its rejection
+	if order.Type == domain.CancelOrder && refused == nil {
+		cancelEvent := domain.PooledEvent(domain.NewCancelEvent(event.Timestamp, order))
+		r.logTransient(cancelEvent)
 
 		// Notify agents
 		if order.TraderID == r.fastAgent.ID {
@@ -236,16 +744,23 @@ func (r *Runner) handleOrder(event *domain.Event) []*domain.Event {
 		}
 	}
 
+	refreshes := r.book.Refreshes()
 	for i := range trades {
 		trade := &trades[i]
-		r.trades = append(r.trades, *trade)
+		if err := r.writeTrade(trade); err != nil {
+			r.fail(fmt.Errorf("write trade %d: %w", trade.ID, err))
+		}
 
-		tradeEvent := &domain.Event{
-			Timestamp: event.Timestamp,
-			Type:      domain.EventTradeExecuted,
-			Trade:     trade,
+		tradeEvent := domain.PooledEvent(domain.NewTradeEvent(event.Timestamp, trade))
+		r.logTransient(tradeEvent)
+		// An iceberg whose slice the trade took shows its next one
+		for len(refreshes) > 0 && refreshes[0].TradeID == trade.ID {
+			r.logTransient(domain.PooledEvent(domain.NewRefreshEvent(event.Timestamp, &refreshes[0].Order)))
+			refreshes = refreshes[1:]
 		}
-		r.logEvent(tradeEvent)
+		r.bookTrade(event.Timestamp, trade)
+		r.publishTrade(event.Timestamp, trade)
+		r.hedgeFill(event.Timestamp, trade)
 
 		// Notify agents of fills
 		if trade.BuyTrader == r.fastAgent.ID {
@@ -260,109 +775,326 @@ func (r *Runner) handleOrder(event *domain.Event) []*domain.Event {
 		}
 	}
 
+	// An IOC or FOK remainder is canceled once its fills are logged; in a
+	// batch auction the venue cancels it when the auction ends
+	if order.Immediate() && order.RemainingQty > 0 && r.book.AuctionPrice() == 0 {
+		r.kill(event.Timestamp, order)
+	}
+
 	// Log BBO update
 	if bbo != nil {
 		r.currentBBO = bbo
-		bboEvent := &domain.Event{
-			Timestamp: event.Timestamp,
-			Type:      domain.EventBBOUpdate,
-			BBO:       bbo,
+		bboEvent := domain.PooledEvent(domain.NewBBOEvent(event.Timestamp, bbo))
+		r.logTransient(bboEvent)
+		r.publishBBO(event.Timestamp, bbo)
+	}
+
+	if len(r.capital) > 0 {
+		r.measureCapital(order.TraderID)
+		// A fill also moves the resting side's position and orders
+		for i := range trades {
+			r.measureCapital(trades[i].BuyTrader)
+			r.measureCapital(trades[i].SellTrader)
+		}
+	}
+
+	if !r.checker.Empty() {
+		if f := r.checker.Check(event.Timestamp, event.SeqNo, r.currentBBO); f != nil {
+			r.failure = f
+			r.loop.Stop()
 		}
-		r.logEvent(bboEvent)
 	}
 
 	return newEvents
 }
 
-// handleSignal dispatches a signal to both traders and schedules their responses
+// handleSignal dispatches a signal to both traders by their signal
+// access and schedules their responses. A SIGNAL with a trader_id is a
+// delayed delivery to that trader alone
 func (r *Runner) handleSignal(event *domain.Event) []*domain.Event {
-	signal := event.Signal
-	if signal == nil {
+	signal := event.Signal()
+	if event.TraderID != "" {
+		r.logEvent(event)
+		if agent := r.agent(event.TraderID); agent != nil {
+			return r.deliverSignal(agent, signal, event.Timestamp)
+		}
 		return nil
 	}
 
-	// Set mid price on signal from current BBO
-	signal.MidPrice = r.currentBBO.MidPrice
+	// Set mid price and market features on signal from current BBO
+	r.describeMarket(signal, event.Timestamp)
 
 	r.logEvent(event)
 
+	// Traders with full access see the signal at the same time
+	// Their response is delayed by their latency
 	var newEvents []*domain.Event
+	for _, agent := range [2]*trader.Agent{r.fastAgent, r.slowAgent} {
+		switch {
+		case agent.NoSignal:
+			// Hears that a signal fired, but not its value; the market
+			// features are public
+			uninformed := *signal
+			uninformed.Value = 0
+			newEvents = append(newEvents, r.deliverSignal(agent, &uninformed, event.Timestamp)...)
+		case agent.SignalDelayNs > 0:
+			delayed := *signal
+			e := domain.NewSignalEvent(event.Timestamp+agent.SignalDelayNs, &delayed)
+			e.TraderID = agent.ID
+			newEvents = append(newEvents, domain.PooledEvent(e))
+		default:
+			newEvents = append(newEvents, r.deliverSignal(agent, signal, event.Timestamp)...)
+		}
+	}
+	return newEvents
+}
 
-	// Both traders see the same signal at the same time
-	// Their response is delayed by their latency
-	fastOrders := r.fastAgent.OnSignal(signal, r.currentBBO, event.Timestamp)
-	for _, order := range fastOrders {
-		arrivalTime := r.fastAgent.Latency.Apply(order.DecisionTime)
-		order.ArrivalTime = arrivalTime
-		newEvents = append(newEvents, &domain.Event{
-			Timestamp: arrivalTime,
-			Type:      domain.EventOrderAccepted,
-			Order:     order,
-		})
-	}
-
-	slowOrders := r.slowAgent.OnSignal(signal, r.currentBBO, event.Timestamp)
-	for _, order := range slowOrders {
-		arrivalTime := r.slowAgent.Latency.Apply(order.DecisionTime)
-		order.ArrivalTime = arrivalTime
-		newEvents = append(newEvents, &domain.Event{
-			Timestamp: arrivalTime,
-			Type:      domain.EventOrderAccepted,
-			Order:     order,
-		})
+// describeMarket sets the signal's mid, book imbalance and trade flow
+// from the market at ts
+func (r *Runner) describeMarket(s *domain.Signal, ts int64) {
+	s.MidPrice = r.currentBBO.MidPrice
+	s.Imbalance = r.currentBBO.Imbalance()
+	s.TradeFlow, s.TradeVolume = r.flow.at(ts)
+}
+
+// deliverSignal hands one trader a signal and schedules its orders
+func (r *Runner) deliverSignal(agent *trader.Agent, signal *domain.Signal, ts int64) []*domain.Event {
+	r.Faults.strategyCall()
+	return r.schedule(agent, agent.OnSignal(signal, r.currentBBO, ts))
+}
+
+// orderPath is the stages a trader's messages pass to reach the venue:
+// its risk check, gateway queue and connection warm-up, those it has, and
+// then its network latency
+func orderPath(tc scenario.TraderConfig, lat *latency.Model) latency.Path {
+	var path latency.Path
+	if tc.RiskCheckNs > 0 {
+		path = append(path, latency.RiskCheck{Ns: tc.RiskCheckNs})
+	}
+	if tc.GatewayNs > 0 {
+		path = append(path, &latency.Gateway{ServiceNs: tc.GatewayNs})
 	}
+	if tc.HasConnection() {
+		path = append(path, &latency.Connection{IdleNs: tc.IdleNs, WarmupNs: tc.WarmupNs, HandshakeNs: tc.HandshakeNs})
+	}
+	return append(path, latency.Network{Model: lat})
+}
 
+// schedule sends the agent's orders down its path and returns their
+// arrival events, carrying each stage's annotations
+func (r *Runner) schedule(agent *trader.Agent, orders []*domain.Order) []*domain.Event {
+	if r.decided != nil {
+		orders = r.decided(agent, orders)
+	}
+	trigger := r.trigger()
+	var newEvents []*domain.Event
+	for i, order := range orders {
+		msg := latency.Message{TraderID: agent.ID, DecisionTime: order.DecisionTime, Trigger: trigger, Index: i}
+		order.ArrivalTime = agent.Path.Send(&msg)
+		event := domain.PooledEvent(domain.NewOrderEvent(order.ArrivalTime, order))
+		for k, v := range msg.Meta {
+			event.Annotate(k, v)
+		}
+		newEvents = append(newEvents, event)
+	}
 	return newEvents
 }
 
+// trigger identifies the event being handled to the hashed jitter. It is
+// the event's SeqNo, except that events the loop scheduled after the
+// background flow count from its end, with the top bit set: a pipelined
+// run numbers them before it knows where the flow ends, and more or fewer
+// background events should not move them
+func (r *Runner) trigger() uint64 {
+	base := r.seqBase
+	if r.feed != nil {
+		base = pendingSeqBase
+	}
+	if r.currentSeq > base {
+		return r.currentSeq - base | 1<<63
+	}
+	return r.currentSeq
+}
+
+// agent returns the trader with id, or nil
+func (r *Runner) agent(id string) *trader.Agent {
+	switch id {
+	case r.fastAgent.ID:
+		return r.fastAgent
+	case r.slowAgent.ID:
+		return r.slowAgent
+	}
+	return nil
+}
+
 // handleReQuote processes a periodic re-quote event for a specific trader
 func (r *Runner) handleReQuote(event *domain.Event) []*domain.Event {
 	if r.currentBBO.BidPrice == 0 || r.currentBBO.AskPrice == 0 {
 		return nil
 	}
 
-	var agent *trader.Agent
-	if event.TraderID == r.fastAgent.ID {
-		agent = r.fastAgent
-	} else if event.TraderID == r.slowAgent.ID {
-		agent = r.slowAgent
-	} else {
+	agent := r.agent(event.TraderID)
+	if agent == nil {
 		return nil
 	}
 
 	// Create a neutral signal for re-quote (value=0 means no directional bias)
-	neutralSignal := &domain.Signal{
-		Value:    0,
-		MidPrice: r.currentBBO.MidPrice,
+	neutralSignal := &domain.Signal{Value: 0}
+	r.describeMarket(neutralSignal, event.Timestamp)
+
+	r.Faults.strategyCall()
+	return r.schedule(agent, agent.OnReQuote(neutralSignal, r.currentBBO, event.Timestamp))
+}
+
+// logEvent appends to the event log, if the log level records events of
+// its type. A write error fails the run; the rest of the current event
+// is still handled but nothing after it. Lifecycles are tracked whether
+// or not the event is written
+func (r *Runner) logEvent(event *domain.Event) {
+	if r.logLevel.Records(event.Type) {
+		err := r.Faults.logWrite()
+		if err == nil {
+			err = r.logWriter.Write(event)
+		}
+		if err != nil {
+			r.fail(fmt.Errorf("write event log: %w", err))
+			return
+		}
 	}
+	r.trackState(event)
+}
 
-	orders := agent.OnSignal(neutralSignal, r.currentBBO, event.Timestamp)
+// Depth snapshots are taken on a fixed grid and list this many levels
+const (
+	depthInterval = int64(100_000_000)
+	depthLevels   = 5
+)
 
-	var newEvents []*domain.Event
-	for _, order := range orders {
-		arrivalTime := agent.Latency.Apply(order.DecisionTime)
-		order.ArrivalTime = arrivalTime
-		newEvents = append(newEvents, &domain.Event{
-			Timestamp: arrivalTime,
-			Type:      domain.EventOrderAccepted,
-			Order:     order,
-		})
+// snapshotDepth logs the book's depth at each grid time up to ts not yet
+// logged. It runs before the first event at or after a grid time, so the
+// book is as it stood then
+func (r *Runner) snapshotDepth(ts int64) {
+	for r.nextDepth <= ts && r.nextDepth <= r.cfg.Duration {
+		snap := domain.NewDepthEvent(r.nextDepth, r.book.DepthSnapshot(depthLevels))
+		r.logEvent(&snap)
+		r.nextDepth += depthInterval
 	}
+}
 
-	return newEvents
+// fullDepth is every level of the book, for the heatmap
+func (r *Runner) fullDepth() *domain.Depth {
+	return r.book.DepthSnapshot(math.MaxInt)
 }
 
-func (r *Runner) logEvent(event *domain.Event) {
-	if err := r.logWriter.Write(event); err != nil {
-		panic(fmt.Sprintf("failed to write event: %v", err))
+// trackState advances order lifecycles with a logged event and logs the
+// transitions it caused right after it. An illegal transition fails the run
+func (r *Runner) trackState(event *domain.Event) {
+	changes, err := r.lifecycle.Apply(event)
+	if err != nil {
+		r.fail(err)
+	}
+	for i := range changes {
+		state := domain.NewStateEvent(event.Timestamp, &changes[i])
+		r.logEvent(&state)
 	}
 }
 
-func hashFile(path string) (string, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return "", err
+// bookTrade updates both sides' accounts and logs them. The background
+// flow's account is kept, so positions net to zero, but not logged
+func (r *Runner) bookTrade(ts int64, trade *domain.Trade) {
+	r.flow.add(ts, trade)
+	r.guards.lastTrade = ts
+	buyer, seller := r.ledger.Apply(trade)
+	for i, a := range [2]*domain.Account{buyer, seller} {
+		if domain.IsBackground(a.TraderID) || (i == 1 && seller == buyer) {
+			continue
+		}
+		update := domain.NewAccountEvent(ts, a)
+		r.logEvent(&update)
+	}
+}
+
+// tradeCount is the number of trades executed so far
+func (r *Runner) tradeCount() int {
+	if r.memory {
+		return len(r.trades)
+	}
+	return r.tradeWriter.Count()
+}
+
+func (r *Runner) writeTrade(trade *domain.Trade) error {
+	if err := r.Faults.tradeWrite(); err != nil {
+		return err
+	}
+	if r.memory {
+		r.trades = append(r.trades, *trade)
+		return nil
+	}
+	if err := r.tradeWriter.Write(trade); err != nil {
+		return err
+	}
+	return r.tape.Write(trade)
+}
+
+// logTransient logs an event that is never scheduled and recycles it
+func (r *Runner) logTransient(event *domain.Event) {
+	r.logEvent(event)
+	domain.ReleaseEvent(event)
+}
+
+// venueHost carries out the venue mechanisms' decisions for the runner
+type venueHost struct{ r *Runner }
+
+func (h venueHost) Match(e *domain.Event) {
+	h.r.handleOrder(e)
+	h.r.releaseEvent(e)
+}
+
+func (h venueHost) Record(e *domain.Event) {
+	h.r.logTransient(e)
+}
+
+func (h venueHost) Quote(ts int64, bbo *domain.BBO) {
+	h.r.currentBBO = bbo
+	h.r.logTransient(domain.PooledEvent(domain.NewBBOEvent(ts, bbo)))
+	h.r.publishBBO(ts, bbo)
+}
+
+func (h venueHost) Kill(ts int64, o *domain.Order) {
+	h.r.kill(ts, o)
+}
+
+// kill logs the cancel of what an IOC or FOK order left unfilled, as the
+// ORDER_CANCELED ack of a cancel the venue made on its own: one with the
+// order's own ID that names it as its target, for the qty it left
+func (r *Runner) kill(ts int64, o *domain.Order) {
+	cancel := domain.Order{
+		ID:           o.ID,
+		TraderID:     o.TraderID,
+		Side:         o.Side,
+		Type:         domain.CancelOrder,
+		Price:        o.Price,
+		Qty:          o.RemainingQty,
+		DecisionTime: o.DecisionTime,
+		ArrivalTime:  o.ArrivalTime,
+		CancelID:     o.ID,
+		TimeInForce:  o.TimeInForce,
+	}
+	r.logTransient(domain.PooledEvent(domain.NewCancelEvent(ts, &cancel)))
+}
+
+// releaseEvent recycles a handled event, plus its order once nothing can
+// reference it: market and cancel orders never rest, and a limit order with
+// no remaining quantity has left the book and (if filled) its agent's set
+func (r *Runner) releaseEvent(event *domain.Event) {
+	if r.venue.Held(event) {
+		return
+	}
+	if o := event.Order(); o != nil && event.Type == domain.EventOrderAccepted {
+		held := r.fastAgent.ActiveOrders[o.ID] == o || r.slowAgent.ActiveOrders[o.ID] == o
+		if !held && (o.Type != domain.LimitOrder || o.RemainingQty <= 0) {
+			domain.ReleaseOrder(o)
+		}
 	}
-	h := sha256.Sum256(data)
-	return fmt.Sprintf("%x", h), nil
+	domain.ReleaseEvent(event)
 }
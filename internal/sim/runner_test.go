@@ -0,0 +1,1880 @@
+package sim
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/assertion"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/engine"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/heatmap"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/lifecycle"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/provenance"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/trader"
+)
+
+func shortCalm(seed int64) *scenario.Config {
+	cfg := scenario.DefaultCalm(seed)
+	cfg.Duration = latency.MsToNs(1_000)
+	return cfg
+}
+
+// TestCheckInvariantsDoesNotAffectLog verifies the full invariant walk is
+// observation-only: the event log is identical with and without it
+func TestCheckInvariantsDoesNotAffectLog(t *testing.T) {
+	var hashes []string
+	for _, check := range []bool{false, true} {
+		r, err := NewRunner(shortCalm(7), t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.CheckInvariants = check
+		res, err := r.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, res.LogHash)
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("log hash differs with CheckInvariants: %s vs %s", hashes[0], hashes[1])
+	}
+}
+
+// TestPipelineMatchesSerial verifies a pipelined run, whose event loop
+// runs while the flow is still being generated, writes the same log,
+// trades and decision logs as a serial run
+func TestPipelineMatchesSerial(t *testing.T) {
+	for _, name := range []string{"calm", "thin", "spike", "sessions"} {
+		var hashes, decisions []string
+		var trades []int
+		for _, pipeline := range []bool{false, true} {
+			var cfg *scenario.Config
+			if name == "sessions" {
+				cfg = shortCalm(11)
+				cfg.Sessions = []scenario.Session{
+					{DurationNs: latency.MsToNs(500), OpenAuctionNs: latency.MsToNs(50)},
+					{Name: "tue", DurationNs: latency.MsToNs(500), CloseAuctionNs: latency.MsToNs(50), GapBps: 100},
+				}
+			} else {
+				cfg = scenario.GetConfig(name, 11)
+				cfg.Duration = latency.MsToNs(1_000)
+			}
+			dir := t.TempDir()
+			r, err := NewRunner(cfg, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Pipeline = pipeline
+			r.DecisionLog = true
+			res, err := r.Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			hashes = append(hashes, res.LogHash)
+			trades = append(trades, res.TradeCount)
+			data, err := os.ReadFile(filepath.Join(res.OutputDir, "decisions_fast.jsonl"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			decisions = append(decisions, string(data))
+		}
+		if hashes[0] != hashes[1] || trades[0] != trades[1] {
+			t.Errorf("%s: pipelined run (%s, %d trades) differs from serial (%s, %d trades)",
+				name, hashes[1], trades[1], hashes[0], trades[0])
+		}
+		if decisions[0] != decisions[1] {
+			t.Errorf("%s: pipelined decision log differs from serial", name)
+		}
+	}
+}
+
+// trickleFlow hands a generator's events over one at a time, yielding
+// between them, so a pipelined loop keeps catching up with the flow
+type trickleFlow struct{ scenario.Generator }
+
+func (f trickleFlow) Stream(emit func([]*domain.Event)) {
+	f.Generator.Stream(func(batch []*domain.Event) {
+		for _, e := range batch {
+			emit([]*domain.Event{e})
+			runtime.Gosched()
+		}
+	})
+}
+
+// TestPipelineWaitsForFlow verifies a loop running ahead of a slow
+// generator still handles events in a serial run's order
+func TestPipelineWaitsForFlow(t *testing.T) {
+	var hashes []string
+	for _, trickle := range []bool{false, true} {
+		cfg := scenario.GetConfig("thin", 5)
+		cfg.Duration = latency.MsToNs(1_000)
+		r, err := NewRunner(cfg, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Pipeline = trickle
+		if trickle {
+			r.background = trickleFlow{scenario.NewGenerator(cfg)}
+		}
+		res, err := r.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, res.LogHash)
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("trickled pipelined log %s differs from serial %s", hashes[1], hashes[0])
+	}
+}
+
+// TestPassingAssertionsDoNotAffectLog verifies assertions only observe
+func TestPassingAssertionsDoNotAffectLog(t *testing.T) {
+	var hashes []string
+	for _, rules := range [][]string{nil, {"sides >= 1", "spread < 5", "position.fast within 1000000"}} {
+		cfg := shortCalm(7)
+		cfg.Assertions = rules
+		r, err := NewRunner(cfg, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := r.Run()
+		if err != nil {
+			t.Fatalf("rules %v: %v", rules, err)
+		}
+		hashes = append(hashes, res.LogHash)
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("assertions changed the log: %s vs %s", hashes[0], hashes[1])
+	}
+}
+
+// TestFailingAssertionStopsRun verifies a broken rule fails the run at the
+// offending event and leaves the log truncated there
+func TestFailingAssertionStopsRun(t *testing.T) {
+	// Full length: the fast trader's first fill is a few seconds in
+	cfg := scenario.DefaultCalm(7)
+	cfg.Assertions = []string{"position.fast == 0"}
+	dir := t.TempDir()
+	r, err := NewRunner(cfg, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Run()
+	f, ok := err.(*assertion.Failure)
+	if !ok {
+		t.Fatalf("err = %v, want *assertion.Failure", err)
+	}
+	if f.Rule != "position.fast == 0" || f.Observed == 0 || f.Timestamp <= 0 {
+		t.Errorf("unexpected failure %+v", f)
+	}
+	if r.loop.Pending() == 0 {
+		t.Error("run was not stopped early")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "calm_seed7", "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"type":"SIM_END"`) {
+		t.Error("log of failed run contains SIM_END")
+	}
+}
+
+// TestSubMillisecondLatency checks µs latency config reaches arrival times
+func TestSubMillisecondLatency(t *testing.T) {
+	cfg := shortCalm(7)
+	cfg.FastTrader.BaseLatencyMs, cfg.FastTrader.BaseLatencyUs = 0, 150
+	cfg.FastTrader.JitterUs = 20
+	r, err := NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := eventlog.NewReader(res.LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	events, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := 0
+	for _, e := range events {
+		o := e.Order()
+		if e.Type != domain.EventOrderAccepted || o == nil || o.TraderID != "fast" {
+			continue
+		}
+		seen++
+		if delay := o.ArrivalTime - o.DecisionTime; delay < 150_000 || delay >= 170_000 {
+			t.Fatalf("order %d delay %d ns outside [150µs, 170µs)", o.ID, delay)
+		}
+	}
+	if seen == 0 {
+		t.Fatal("fast trader sent no orders")
+	}
+}
+
+// TestHashedJitterIgnoresExtraOrder sends one extra fast order, an IOC
+// that cannot fill, in a hashed run: every other order keeps its delay,
+// the fast trader's later ones included, and pipelined runs draw the
+// delays serial runs do
+func TestHashedJitterIgnoresExtraOrder(t *testing.T) {
+	cfg := scenario.DefaultCalm(7)
+	cfg.Duration = latency.MsToNs(5_000)
+	cfg.JitterMode = latency.JitterHashed
+	cfg.FastTrader.JitterMs = 2
+	delays := func(extra, pipeline bool) map[string][]int64 {
+		r, err := NewRunner(cfg, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Pipeline = pipeline
+		if extra {
+			r.decided = func(agent *trader.Agent, orders []*domain.Order) []*domain.Order {
+				if agent.ID != "fast" || len(orders) == 0 || !extra {
+					return orders
+				}
+				extra = false
+				s := *agent.Strategy
+				s.CancelTimeoutNs, s.ReduceThreshold, s.CrossTIF, s.TargetQty = math.MaxInt64, 0, domain.IOC, 1
+				ioc := s.Decide(agent, &domain.Signal{Value: math.Inf(1)}, &domain.BBO{BidPrice: 1, AskPrice: 1}, orders[0].DecisionTime, nil)
+				return append(orders, ioc...)
+			}
+		}
+		res, err := r.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := eventlog.NewReader(res.LogPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reader.Close()
+		events, err := reader.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Orders are matched on what they are, not their IDs, which the
+		// extra order renumbers
+		seen := make(map[uint64]bool)
+		out := make(map[string][]int64)
+		for _, e := range events {
+			o := e.Order()
+			if o == nil || o.ArrivalTime == 0 || domain.IsBackground(o.TraderID) || seen[o.ID] {
+				continue
+			}
+			seen[o.ID] = true
+			key := fmt.Sprintf("%s t=%d %s %s %d@%d", o.TraderID, o.DecisionTime, o.Side, o.Type, o.Qty, o.Price)
+			out[key] = append(out[key], o.ArrivalTime-o.DecisionTime)
+		}
+		return out
+	}
+
+	serial := delays(false, false)
+	for _, pipeline := range []bool{false, true} {
+		base, extra := delays(false, pipeline), delays(true, pipeline)
+		if !reflect.DeepEqual(base, serial) {
+			t.Errorf("pipeline %v: delays differ from a serial run's", pipeline)
+		}
+		later := 0
+		for key, want := range base {
+			if got := extra[key]; !slices.Equal(got, want) {
+				t.Errorf("pipeline %v: %s delays %v, %v with the extra order", pipeline, key, want, got)
+			}
+			if strings.HasPrefix(key, "fast ") {
+				later++
+			}
+		}
+		if later < 10 || len(extra) != len(base)+1 {
+			t.Fatalf("pipeline %v: %d fast orders, %d keys with the extra order against %d", pipeline, later, len(extra), len(base))
+		}
+	}
+}
+
+// TestRescaledRunMatches checks the price scale is only a unit: the same
+// run at 8 decimals trades the same quantities at the same prices
+func TestRescaledRunMatches(t *testing.T) {
+	var runs [2][]domain.Trade
+	for i, scale := range []domain.Scale{domain.DefaultScale, 100_000_000} {
+		cfg := shortCalm(7)
+		if err := cfg.Rescale(scale); err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewRunner(cfg, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := r.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := eventlog.NewReader(res.LogPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, err := reader.ReadAll()
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range events {
+			if e.Type == domain.EventTradeExecuted {
+				runs[i] = append(runs[i], *e.Trade())
+			}
+		}
+	}
+	if len(runs[0]) == 0 || len(runs[0]) != len(runs[1]) {
+		t.Fatalf("trade counts differ: %d vs %d", len(runs[0]), len(runs[1]))
+	}
+	for i, a := range runs[0] {
+		b := runs[1][i]
+		if b.Price != a.Price*10_000 || b.Qty != a.Qty || b.BuyOrderID != a.BuyOrderID {
+			t.Fatalf("trade %d: %+v vs %+v", i, a, b)
+		}
+	}
+}
+
+// TestOrderStatesMatchReplay checks the logged ORDER_STATE records are
+// exactly what the tracker derives from the rest of the log, and that
+// every order ends in a terminal state
+func TestOrderStatesMatchReplay(t *testing.T) {
+	for _, name := range []string{"calm", "thin", "spike"} {
+		cfg := scenario.GetConfig(name, 7)
+		cfg.Duration = latency.MsToNs(2_000)
+		r, err := NewRunner(cfg, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := r.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := eventlog.NewReader(res.LogPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, err := reader.ReadAll()
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tracker := lifecycle.New()
+		var want []domain.StateChange
+		var logged []domain.StateChange
+		owners := make(map[uint64]string)
+		for _, e := range events {
+			if c := e.StateChange(); c != nil {
+				logged = append(logged, *c)
+				continue
+			}
+			if o := e.Order(); e.Type == domain.EventOrderAccepted && o.Type != domain.CancelOrder {
+				owners[o.ID] = o.TraderID
+			}
+			if c := e.Cancel(); e.Type == domain.EventOrderCanceled && owners[c.CancelID] != c.TraderID {
+				t.Errorf("%s: %s's cancel %d acked for %s's order %d", name, c.TraderID, c.ID, owners[c.CancelID], c.CancelID)
+			}
+			changes, err := tracker.Apply(e)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			want = append(want, changes...)
+		}
+		if len(logged) == 0 || len(logged) != len(want) {
+			t.Fatalf("%s: logged %d transitions, replay derives %d", name, len(logged), len(want))
+		}
+		for i := range want {
+			if logged[i] != want[i] {
+				t.Fatalf("%s: transition %d: logged %+v, replay %+v", name, i, logged[i], want[i])
+			}
+		}
+		if tracker.Live() != 0 {
+			t.Errorf("%s: %d orders not terminal at the close", name, tracker.Live())
+		}
+	}
+}
+
+func TestAccountsMatchTrades(t *testing.T) {
+	cfg := scenario.GetConfig("spike", 7)
+	cfg.Duration = latency.MsToNs(2_000)
+	cfg.MakerFee, cfg.TakerFee = -1, 3
+	r, err := NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := eventlog.NewReader(res.LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := reader.ReadAll()
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	net := make(map[string]int64)
+	fees := make(map[string]int64)
+	last := make(map[string]domain.Account)
+	for _, e := range events {
+		if tr := e.Trade(); tr != nil {
+			net[tr.BuyTrader] += tr.Qty
+			net[tr.SellTrader] -= tr.Qty
+			for _, side := range []struct {
+				trader string
+				order  uint64
+			}{{tr.BuyTrader, tr.BuyOrderID}, {tr.SellTrader, tr.SellOrderID}} {
+				if side.order == tr.PassiveOrderID {
+					fees[side.trader] -= tr.Qty
+				} else {
+					fees[side.trader] += 3 * tr.Qty
+				}
+			}
+		}
+		if a := e.Account(); a != nil {
+			last[a.TraderID] = *a
+		}
+	}
+	if len(last) == 0 {
+		t.Fatal("no ACCOUNT_UPDATE events logged")
+	}
+	if _, ok := last["background"]; ok {
+		t.Error("background account was logged")
+	}
+	for id, a := range last {
+		if a.Position != net[id] || a.Fees != fees[id] {
+			t.Errorf("%s: account position %d fees %d, trades give %d and %d", id, a.Position, a.Fees, net[id], fees[id])
+		}
+	}
+}
+
+func TestDelayedSignalDelivery(t *testing.T) {
+	cfg := shortCalm(7)
+	cfg.SlowTrader.SignalDelayNs = latency.MsToNs(30)
+	r, err := NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := eventlog.NewReader(res.LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := reader.ReadAll()
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broadcast := make(map[float64]int64)
+	delivered := 0
+	for _, e := range events {
+		s := e.Signal()
+		switch {
+		case s == nil:
+		case e.TraderID == "":
+			broadcast[s.Value] = e.Timestamp
+		case e.TraderID == "slow":
+			delivered++
+			if at, ok := broadcast[s.Value]; !ok || e.Timestamp-at != latency.MsToNs(30) {
+				t.Errorf("signal %v delivered at %d, broadcast at %d", s.Value, e.Timestamp, at)
+			}
+		default:
+			t.Errorf("signal delivered to %q", e.TraderID)
+		}
+	}
+	if delivered == 0 || delivered != len(broadcast) {
+		t.Errorf("%d signals broadcast, %d delivered to slow", len(broadcast), delivered)
+	}
+}
+
+// TestSignalMarketFeatures checks each signal carries the book imbalance
+// and trade flow the log shows just before it
+func TestSignalMarketFeatures(t *testing.T) {
+	cfg := shortCalm(7)
+	cfg.Scenario.SignalFlowWindowNs = latency.MsToNs(50)
+	r, err := NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := eventlog.NewReader(res.LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := reader.ReadAll()
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bbo domain.BBO
+	var trades []*domain.Event
+	signals, flowing := 0, 0
+	for _, e := range events {
+		switch {
+		case e.BBO() != nil:
+			bbo = *e.BBO()
+		case e.Trade() != nil:
+			trades = append(trades, e)
+		case e.Signal() != nil && e.TraderID == "":
+			s := e.Signal()
+			var flow, volume int64
+			for _, te := range trades {
+				if tr := te.Trade(); te.Timestamp > e.Timestamp-latency.MsToNs(50) {
+					volume += tr.Qty
+					switch tr.AggressorOrderID {
+					case tr.BuyOrderID:
+						flow += tr.Qty
+					case tr.SellOrderID:
+						flow -= tr.Qty
+					}
+				}
+			}
+			if s.Imbalance != bbo.Imbalance() || s.TradeFlow != flow || s.TradeVolume != volume {
+				t.Errorf("signal at %d: imbalance %v flow %d volume %d, want %v %d %d",
+					e.Timestamp, s.Imbalance, s.TradeFlow, s.TradeVolume, bbo.Imbalance(), flow, volume)
+			}
+			signals++
+			if volume > 0 {
+				flowing++
+			}
+		}
+	}
+	if signals == 0 || flowing == 0 {
+		t.Errorf("%d signals, %d with trade flow", signals, flowing)
+	}
+}
+
+// TestStrategyAB checks each trader runs its own strategy params,
+// re-quoting on its own interval
+func TestStrategyAB(t *testing.T) {
+	cfg := shortCalm(7)
+	cfg.SlowTrader = cfg.SlowTrader.WithLatency(cfg.FastTrader)
+	cfg.SlowTrader.Strategy = scenario.StrategyParams{ReQuoteIntervalNs: latency.MsToNs(30), TargetQty: 7}
+	r, err := NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := eventlog.NewReader(res.LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := reader.ReadAll()
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offGrid := 0 // slow decisions between the default 100 ms re-quotes
+	for _, e := range events {
+		o := e.Order()
+		if e.Type != domain.EventOrderAccepted || o == nil || o.Type != domain.LimitOrder {
+			continue
+		}
+		want := map[string]int64{"fast": 5, "slow": 7}[o.TraderID]
+		if want != 0 && o.Qty != want {
+			t.Fatalf("%s limit order of %d, want %d", o.TraderID, o.Qty, want)
+		}
+		if o.TraderID == "slow" && o.DecisionTime%latency.MsToNs(100) != 0 && o.DecisionTime%latency.MsToNs(30) == 0 {
+			offGrid++
+		}
+	}
+	if offGrid == 0 {
+		t.Error("slow trader never re-quoted on its own interval")
+	}
+
+	cfg.FastTrader.Strategy.CrossThreshold = -1
+	if _, err := NewRunner(cfg, t.TempDir()); err == nil {
+		t.Error("negative cross threshold accepted")
+	}
+}
+
+// TestTies checks equal latencies make the traders' messages tie, and
+// that the default latency gap does not
+func TestTies(t *testing.T) {
+	run := func(cfg *scenario.Config) []engine.TiePair {
+		r, err := NewRunner(cfg, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := r.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Ties
+	}
+	for _, p := range run(shortCalm(7)) {
+		if p.A == "fast" && p.B == "slow" {
+			t.Errorf("default latencies tied %d times", p.Ties)
+		}
+	}
+	cfg := shortCalm(7)
+	cfg.SlowTrader = cfg.SlowTrader.WithLatency(cfg.FastTrader)
+	ties := run(cfg)
+	if len(ties) == 0 || ties[0].A != "fast" || ties[0].B != "slow" || ties[0].SameDecision == 0 ||
+		ties[0].AFirst+ties[0].BFirst != ties[0].Ties {
+		t.Errorf("ties = %+v, want fast / slow ties decided at the same time", ties)
+	}
+}
+
+// TestHeatmap verifies the depth sampled during a run leaves the log alone
+// and matches the grid rebuilt from the log, also under batch auctions
+func TestHeatmap(t *testing.T) {
+	batched := shortCalm(7)
+	batched.Venue.BatchIntervalNs = latency.MsToNs(20)
+	for _, cfg := range []*scenario.Config{shortCalm(7), batched} {
+		var hashes []string
+		for _, interval := range []int64{0, latency.MsToNs(5)} {
+			r, err := NewRunner(cfg, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.HeatmapIntervalNs = interval
+			res, err := r.Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			hashes = append(hashes, res.LogHash)
+			if interval == 0 {
+				continue
+			}
+			sampled, err := os.ReadFile(filepath.Join(res.OutputDir, "heatmap.csv"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			g, err := heatmap.BuildLog(res.LogPath, interval)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rebuilt strings.Builder
+			if err := g.WriteCSV(&rebuilt, cfg.Scale()); err != nil {
+				t.Fatal(err)
+			}
+			if len(g.Times) != 200 || len(g.Prices) == 0 {
+				t.Errorf("grid is %d x %d, want 200 samples", len(g.Times), len(g.Prices))
+			}
+			if rebuilt.String() != string(sampled) {
+				t.Errorf("batch interval %d: heatmap rebuilt from the log differs from the run's", cfg.Venue.BatchIntervalNs)
+			}
+		}
+		if hashes[0] != hashes[1] {
+			t.Errorf("log hash differs with the heatmap: %s vs %s", hashes[0], hashes[1])
+		}
+	}
+}
+
+// TestDecisionLog verifies the decision logs leave the event log alone,
+// and that each order a decision sent reached the venue with that
+// decision's time, unless it was still in flight at the end
+func TestDecisionLog(t *testing.T) {
+	var hashes []string
+	var res *RunResult
+	for _, record := range []bool{false, true} {
+		r, err := NewRunner(shortCalm(7), t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.DecisionLog = record
+		if res, err = r.Run(); err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, res.LogHash)
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("log hash differs with the decision log: %s vs %s", hashes[0], hashes[1])
+	}
+
+	decided := make(map[uint64]int64)
+	for _, id := range []string{"fast", "slow"} {
+		data, err := os.ReadFile(filepath.Join(res.OutputDir, "decisions_"+id+".jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var d trader.Decision
+			if err := json.Unmarshal([]byte(line), &d); err != nil {
+				t.Fatal(err)
+			}
+			if d.Trader != id || len(d.Rules) == 0 {
+				t.Fatalf("decision %+v in %s's log", d, id)
+			}
+			for _, o := range d.Orders {
+				decided[o.ID] = d.Time
+			}
+		}
+	}
+	reader, err := eventlog.NewReader(res.LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := reader.ReadAll()
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	arrived := 0
+	for _, e := range events {
+		if o := e.Order(); o != nil && e.Type == domain.EventOrderAccepted && o.TraderID != "background" {
+			if at, ok := decided[o.ID]; !ok || at != o.DecisionTime {
+				t.Errorf("order %d decided at %d, decision log has %d (%v)", o.ID, o.DecisionTime, at, ok)
+			}
+			arrived++
+		}
+	}
+	if arrived == 0 || len(decided)-arrived > 4 {
+		t.Errorf("%d orders decided, %d reached the venue", len(decided), arrived)
+	}
+}
+
+// TestExternalFlow injects a recorded run's background orders into
+// another seed's run: the result is reproducible, the injected orders are
+// tagged with their source, and out-of-order or live-trader records fail
+func TestExternalFlow(t *testing.T) {
+	rec, err := NewRunner(shortCalm(3), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := rec.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := loadExternalFlow(recorded.LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flow []*domain.Event
+	for _, e := range all {
+		if e.Order().TraderID == "background" {
+			flow = append(flow, e)
+		}
+	}
+
+	run := func() *RunResult {
+		r, err := NewRunner(shortCalm(7), t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Inject("recorded", flow); err != nil {
+			t.Fatal(err)
+		}
+		res, err := r.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	first := run()
+	if second := run(); first.LogHash != second.LogHash {
+		t.Fatalf("hash %s then %s", first.LogHash, second.LogHash)
+	}
+
+	reader, err := eventlog.NewReader(first.LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := reader.ReadAll()
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	injected := 0
+	for _, e := range events {
+		if o := e.Order(); e.Type == domain.EventOrderAccepted && o.ID >= externalIDBase {
+			if src, _ := e.Annotation("source"); src != "recorded" {
+				t.Fatalf("order %d tagged %q", o.ID, src)
+			}
+			if e.Timestamp != o.ArrivalTime {
+				t.Fatalf("order %d logged at %d, arrives at %d", o.ID, e.Timestamp, o.ArrivalTime)
+			}
+			injected++
+		}
+	}
+	if injected == 0 {
+		t.Fatal("no injected orders reached the book")
+	}
+
+	r, err := NewRunner(shortCalm(7), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Inject("reversed", []*domain.Event{flow[1], flow[0]}); err == nil {
+		t.Error("out-of-order flow accepted")
+	}
+
+	cfg := shortCalm(7)
+	cfg.ExternalFlow = recorded.LogPath
+	if _, err := NewRunner(cfg, t.TempDir()); err == nil || !strings.Contains(err.Error(), "live trader") {
+		t.Errorf("flow with the live traders' orders: %v", err)
+	}
+}
+
+// TestRecordedFlow reruns a recorded background flow: unchanged, it logs
+// the same bytes as the run that recorded it, serially or pipelined, and
+// under another mechanism it still faces the same background orders
+func TestRecordedFlow(t *testing.T) {
+	r, err := NewRunner(shortCalm(3), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.RecordFlow = true
+	original, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flowPath := filepath.Join(original.OutputDir, flowFile)
+
+	rerun := func(cfg *scenario.Config, pipeline bool) *RunResult {
+		cfg.BackgroundFrom = flowPath
+		r, err := NewRunner(cfg, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Pipeline = pipeline
+		res, err := r.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	for _, pipeline := range []bool{true, false} {
+		if res := rerun(shortCalm(3), pipeline); res.LogHash != original.LogHash {
+			t.Errorf("pipeline=%v: rerun hash %s, recorded %s", pipeline, res.LogHash, original.LogHash)
+		}
+	}
+
+	// Neither the seed nor the venue changes what the flow fixes
+	background := func(res *RunResult) []string {
+		reader, err := eventlog.NewReader(res.LogPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reader.Close()
+		events, err := reader.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, e := range events {
+			if o := e.Order(); e.Type == domain.EventOrderAccepted && o.TraderID == domain.BackgroundTrader {
+				out = append(out, fmt.Sprintf("%d@%d", o.ID, o.ArrivalTime))
+			}
+		}
+		// A held order is logged when the bump releases it
+		slices.Sort(out)
+		return out
+	}
+	bumped := shortCalm(11)
+	bumped.Venue.SpeedBumpNs = latency.MsToNs(5)
+	if got, want := background(rerun(bumped, true)), background(original); !slices.Equal(got, want) {
+		t.Errorf("background orders differ under a speed bump: %d vs %d", len(got), len(want))
+	}
+
+	cfg := shortCalm(3)
+	cfg.BackgroundFrom = original.LogPath
+	if _, err := NewRunner(cfg, t.TempDir()); err == nil {
+		t.Error("a full event log accepted as a recorded flow")
+	}
+}
+
+// TestLogLevels runs the same scenario at every log level: each log
+// records only its level's event types, lower levels write less, the
+// market is the same, and metrics refuse any log short of full
+func TestLogLevels(t *testing.T) {
+	var lastSize int64
+	trades := -1
+	for l := eventlog.LevelTrades; l <= eventlog.LevelFull; l++ {
+		cfg := shortCalm(7)
+		cfg.LogLevel = l.String()
+		r, err := NewRunner(cfg, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := r.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trades >= 0 && res.TradeCount != trades {
+			t.Fatalf("%s: %d trades, want %d", l, res.TradeCount, trades)
+		}
+		trades = res.TradeCount
+		if err := eventlog.Validate(res.LogPath); err != nil {
+			t.Fatalf("%s: %v", l, err)
+		}
+		info, err := os.Stat(res.LogPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() <= lastSize {
+			t.Errorf("%s log is %d bytes, no more than the level below's %d", l, info.Size(), lastSize)
+		}
+		lastSize = info.Size()
+
+		reader, err := eventlog.NewReader(res.LogPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, err := reader.ReadAll()
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		depth := 0
+		for _, e := range events {
+			if e.Type == domain.EventDepth {
+				if e.Timestamp%depthInterval != 0 {
+					t.Fatalf("depth snapshot at %d ns, off the grid", e.Timestamp)
+				}
+				depth++
+			}
+		}
+		if want := l >= eventlog.LevelDepth; (depth > 0) != want {
+			t.Errorf("%s: %d depth snapshots", l, depth)
+		}
+
+		_, err = metrics.ComputeFromLog(res.LogPath, cfg.Scale(), cfg.Metrics)
+		var le *eventlog.LevelError
+		if got := errors.As(err, &le); got != (l < eventlog.LevelFull) {
+			t.Errorf("%s: metrics error %v", l, err)
+		}
+	}
+
+	cfg := shortCalm(7)
+	cfg.LogLevel = "verbose"
+	if _, err := NewRunner(cfg, t.TempDir()); err == nil {
+		t.Error("unknown log level accepted")
+	}
+}
+
+// TestGuards checks each guard aborts a degenerate run with its
+// diagnostics, and that guards which hold leave the log unchanged
+func TestGuards(t *testing.T) {
+	run := func(cfg *scenario.Config) (*RunResult, error) {
+		r, err := NewRunner(cfg, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.Run()
+	}
+	plain, err := run(shortCalm(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loose := shortCalm(7)
+	loose.Guards = scenario.Guards{OneSidedNs: latency.MsToNs(1_000), MaxBacklog: 100_000, NoTradeNs: latency.MsToNs(1_000)}
+	guarded, err := run(loose)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if guarded.LogHash != plain.LogHash {
+		t.Error("guards that hold changed the log")
+	}
+
+	drained := shortCalm(7)
+	drained.Scenario.DepthPerLevel = 1
+	drained.Scenario.MarketOrderRatio = 1
+	for _, tc := range []struct {
+		cfg    *scenario.Config
+		guards scenario.Guards
+		want   string
+	}{
+		{shortCalm(7), scenario.Guards{NoTradeNs: 1_000}, "no_trade_ns"},
+		{shortCalm(7), scenario.Guards{MaxBacklog: 1}, "max_backlog"},
+		{drained, scenario.Guards{OneSidedNs: latency.MsToNs(10)}, "one_sided_ns"},
+	} {
+		tc.cfg.Guards = tc.guards
+		_, err := run(tc.cfg)
+		var g *GuardError
+		if !errors.As(err, &g) {
+			t.Errorf("%s: err = %v, want a guard error", tc.want, err)
+			continue
+		}
+		if g.Guard != tc.want || g.Detail == "" || g.OutputDir == "" {
+			t.Errorf("%s: got %+v", tc.want, g)
+		}
+		if g.Timestamp >= tc.cfg.Duration {
+			t.Errorf("%s: tripped at the close", tc.want)
+		}
+	}
+
+	cfg := shortCalm(7)
+	cfg.Guards.MaxBacklog = -1
+	if _, err := NewRunner(cfg, t.TempDir()); err == nil {
+		t.Error("negative guard accepted")
+	}
+}
+
+// TestBudget stops a run at max_events: the log closes with an early SIM_END,
+// metrics cover the completed part, and the saved config replays the stop
+func TestBudget(t *testing.T) {
+	cfg := shortCalm(7)
+	cfg.Budget = scenario.Budget{MaxEvents: 300}
+	r, err := NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := result.Truncated
+	if tr == nil || tr.Limit != "max_events" || tr.Events != 300 {
+		t.Fatalf("truncated = %+v", tr)
+	}
+	if tr.Timestamp >= cfg.Duration {
+		t.Errorf("stopped at %d, the scenario's close", tr.Timestamp)
+	}
+	reader, err := eventlog.NewReader(result.LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	events, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var end *domain.Event
+	for _, e := range events {
+		if e.Type == domain.EventSimEnd {
+			end = e
+		}
+		if e.Timestamp > tr.Timestamp {
+			t.Fatalf("event past the stop: %+v", e)
+		}
+	}
+	if end == nil || end.Timestamp != tr.Timestamp || end.Meta["truncated_after_events"] != "300" {
+		t.Errorf("SIM_END = %+v", end)
+	}
+	if _, err := metrics.ComputeFromLog(result.LogPath, cfg.Scale(), cfg.Metrics); err != nil {
+		t.Errorf("metrics over a truncated log: %v", err)
+	}
+
+	// A wall time stop is saved as the event count it reached
+	r.truncation = &Truncation{Limit: "max_wall_seconds", Events: 300}
+	r.cfg.Budget = scenario.Budget{MaxWallSeconds: 1}
+	replayed, err := NewRunner(r.savedConfig(), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := replayed.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.LogHash != result.LogHash {
+		t.Error("saved config did not replay the truncated run")
+	}
+
+	bad := shortCalm(7)
+	bad.Budget.MaxWallSeconds = -1
+	if _, err := NewRunner(bad, t.TempDir()); err == nil {
+		t.Error("negative wall budget accepted")
+	}
+}
+
+// TestGateway gives the slow trader a gateway: its messages queue, the
+// gateway time shows in its metrics, and realized latency net of it still
+// matches the configured model
+func TestGateway(t *testing.T) {
+	cfg := shortCalm(7)
+	cfg.SlowTrader.GatewayNs = latency.MsToNs(2)
+	r, err := NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := metrics.ComputeFromLog(result.LogPath, cfg.Scale(), cfg.Metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow := m["slow"]
+	if slow.Gateway == nil || slow.Gateway.MinNs < cfg.SlowTrader.GatewayNs || slow.GatewayQueued == 0 {
+		t.Fatalf("gateway = %+v, %d queued", slow.Gateway, slow.GatewayQueued)
+	}
+	if m["fast"].Gateway != nil {
+		t.Error("fast trader has gateway stats without a gateway")
+	}
+	for msg, s := range slow.RealizedLatency {
+		if issues := s.Check(cfg.SlowTrader.BaseLatencyNs(), cfg.SlowTrader.JitterNs()); issues != nil {
+			t.Errorf("slow %s: %v", msg, issues)
+		}
+	}
+
+	cfg = shortCalm(7)
+	cfg.FastTrader.GatewayNs = -1
+	if _, err := NewRunner(cfg, t.TempDir()); err == nil {
+		t.Error("negative gateway accepted")
+	}
+}
+
+// TestConnectionWarmup gives the slow trader a handshake and idle warm-up:
+// its cold messages arrive late by exactly that, and realized latency net
+// of it still matches the configured model
+func TestConnectionWarmup(t *testing.T) {
+	cfg := shortCalm(7)
+	cfg.SlowTrader.HandshakeNs = latency.MsToNs(20)
+	cfg.SlowTrader.IdleNs = latency.MsToNs(200)
+	cfg.SlowTrader.WarmupNs = latency.MsToNs(5)
+	r, err := NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := metrics.ComputeFromLog(result.LogPath, cfg.Scale(), cfg.Metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow := m["slow"]
+	if slow.ColdMessages < 2 || slow.WarmupNs != cfg.SlowTrader.HandshakeNs+int64(slow.ColdMessages-1)*cfg.SlowTrader.WarmupNs {
+		t.Fatalf("%d cold messages added %d ns", slow.ColdMessages, slow.WarmupNs)
+	}
+	if m["fast"].ColdMessages != 0 {
+		t.Error("fast trader paid warm-up without a connection model")
+	}
+	for msg, s := range slow.RealizedLatency {
+		if issues := s.Check(cfg.SlowTrader.BaseLatencyNs(), cfg.SlowTrader.JitterNs()); issues != nil {
+			t.Errorf("slow %s: %v", msg, issues)
+		}
+	}
+
+	cfg = shortCalm(7)
+	cfg.FastTrader.WarmupNs = latency.MsToNs(1)
+	if _, err := NewRunner(cfg, t.TempDir()); err == nil {
+		t.Error("warm-up without an idle time accepted")
+	}
+}
+
+// TestRiskCheck stacks a risk check on the slow trader's gateway: every
+// message carries both holds, and realized latency net of them still
+// matches the configured model
+func TestRiskCheck(t *testing.T) {
+	cfg := shortCalm(7)
+	cfg.SlowTrader.RiskCheckNs = latency.UsToNs(300)
+	cfg.SlowTrader.GatewayNs = latency.MsToNs(1)
+	r, err := NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := eventlog.NewReader(result.LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	events, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked := 0
+	for _, e := range events {
+		if e.Type != domain.EventOrderAccepted || e.Order().TraderID != "slow" {
+			continue
+		}
+		risk, ok := e.Annotation("risk_check_ns")
+		_, gated := e.Annotation("gateway_ns")
+		if !ok || risk != "300000" || !gated {
+			t.Fatalf("order %d annotations %v", e.Order().ID, e.Meta)
+		}
+		checked++
+	}
+	if checked == 0 {
+		t.Fatal("no slow orders")
+	}
+	m, err := metrics.ComputeFromLog(result.LogPath, cfg.Scale(), cfg.Metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for msg, s := range m["slow"].RealizedLatency {
+		if issues := s.Check(cfg.SlowTrader.BaseLatencyNs(), cfg.SlowTrader.JitterNs()); issues != nil {
+			t.Errorf("slow %s: %v", msg, issues)
+		}
+	}
+
+	cfg = shortCalm(7)
+	cfg.FastTrader.RiskCheckNs = -1
+	if _, err := NewRunner(cfg, t.TempDir()); err == nil {
+		t.Error("negative risk check accepted")
+	}
+}
+
+// TestCapital checks orders beyond a trader's capital are rejected before
+// the book and logged as such, and that a trader without a limit is not
+func TestCapital(t *testing.T) {
+	cfg := shortCalm(7)
+	cfg.SlowTrader.Capital = 600 // one 5-share quote at $100
+	r, err := NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Capital) != 1 || result.Capital[0].TraderID != "slow" || result.Capital[0].Margin != 1 {
+		t.Fatalf("capital %+v", result.Capital)
+	}
+	usage := result.Capital[0]
+	if usage.Rejected == 0 || usage.RejectedQty < int64(usage.Rejected) || usage.PeakUsed <= 0 {
+		t.Fatalf("usage %+v", usage)
+	}
+
+	reader, err := eventlog.NewReader(result.LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	events, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted := make(map[uint64]bool)
+	rejected := 0
+	for _, e := range events {
+		if o := e.Order(); e.Type == domain.EventOrderAccepted && o != nil {
+			accepted[o.ID] = true
+		}
+		if c := e.StateChange(); c != nil && c.Reason == RejectCapital {
+			if c.TraderID != "slow" || accepted[c.OrderID] {
+				t.Fatalf("rejection %+v", c)
+			}
+			rejected++
+		}
+	}
+	if rejected != usage.Rejected {
+		t.Errorf("logged %d rejections, counted %d", rejected, usage.Rejected)
+	}
+
+	cfg = shortCalm(7)
+	cfg.FastTrader.Margin = 0.5
+	if _, err := NewRunner(cfg, t.TempDir()); err == nil {
+		t.Error("margin without capital accepted")
+	}
+
+	cfg = shortCalm(7)
+	cfg.FastTrader.Capital = 1e300
+	if _, err := NewRunner(cfg, t.TempDir()); err == nil {
+		t.Error("capital beyond the price range accepted")
+	}
+}
+
+// TestHedge runs a hedge leg: it must leave the event log alone, hedge
+// every fill of the fast and slow traders, and charge the half spread
+func TestHedge(t *testing.T) {
+	plain, err := RunInMemory(shortCalm(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := shortCalm(7)
+	cfg.Hedge = &scenario.Hedge{BasisBps: 5, HalfSpreadBps: 1}
+	res, err := RunInMemory(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.LogHash != plain.LogHash {
+		t.Fatal("hedge leg changed the event log")
+	}
+	if len(res.Hedge) != 2 || res.Hedge[0].TraderID != "fast" {
+		t.Fatalf("hedge %+v", res.Hedge)
+	}
+	for _, leg := range res.Hedge {
+		m := res.Metrics[leg.TraderID]
+		if leg.Qty > m.TotalQtyFilled || leg.Hedges+leg.InFlight != m.TotalFills {
+			t.Errorf("%s: %d hedges (%d in flight) of %d fills", leg.TraderID, leg.Hedges, leg.InFlight, m.TotalFills)
+		}
+		if leg.Hedges > 0 && math.Abs(leg.SpreadBps-1) > 0.05 {
+			t.Errorf("%s: spread %.4f bps, want 1", leg.TraderID, leg.SpreadBps)
+		}
+		if math.Abs(leg.SlippageBps-leg.LatencyBps-leg.SpreadBps) > 1e-9 {
+			t.Errorf("%s: slippage %v is not latency %v plus spread %v", leg.TraderID, leg.SlippageBps, leg.LatencyBps, leg.SpreadBps)
+		}
+	}
+
+	cfg.Hedge.HalfSpreadBps = -1
+	if _, err := RunInMemory(cfg); err == nil {
+		t.Error("negative half spread accepted")
+	}
+}
+
+// TestSeedsRecorded checks seeds.json names each trader's latency seed and
+// substreams, matching the seeds the runner derives
+func TestSeedsRecorded(t *testing.T) {
+	r, err := NewRunner(shortCalm(7), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(result.OutputDir, "seeds.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec SeedRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatal(err)
+	}
+	fast, slow := rec.Traders["fast"], rec.Traders["slow"]
+	if rec.Seed != 7 || fast.Latency != 8 || slow.Latency != 9 {
+		t.Errorf("seeds = %+v", rec)
+	}
+	if fast.Streams[trader.StreamSignalNoise] != 10 || fast.Streams[trader.StreamStrategy] != trader.SubstreamSeed(10, trader.StreamStrategy) {
+		t.Errorf("fast streams = %v", fast.Streams)
+	}
+}
+
+// TestProvenance checks a run stamps provenance.json with its version,
+// config and log hash, and declares the version in the log itself
+func TestProvenance(t *testing.T) {
+	r, err := NewRunner(shortCalm(7), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp, err := provenance.Read(result.OutputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgData, err := os.ReadFile(filepath.Join(result.OutputDir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := provenance.New(cfgData); stamp.ConfigHash != want.ConfigHash || stamp.SimulatorVersion != provenance.Version {
+		t.Errorf("stamp = %+v", stamp)
+	}
+	if stamp.LogHash != result.LogHash || *result.Provenance != *stamp {
+		t.Errorf("stamp log hash %s, run %s", stamp.LogHash, result.LogHash)
+	}
+	if v, err := provenance.LogVersion(result.LogPath); err != nil || v != provenance.Version {
+		t.Errorf("log declares version %d (%v)", v, err)
+	}
+}
+
+// TestSessions runs two sessions with auctions and a gap: boundaries are
+// logged, auctions cross only at their phase's end, day orders expire at
+// each close, and per-session metrics add up to the run's
+func TestSessions(t *testing.T) {
+	sessions := func() *scenario.Config {
+		cfg := shortCalm(7)
+		cfg.Sessions = []scenario.Session{
+			{DurationNs: latency.MsToNs(500), OpenAuctionNs: latency.MsToNs(50), CloseAuctionNs: latency.MsToNs(50)},
+			{Name: "tue", DurationNs: latency.MsToNs(500), CloseAuctionNs: latency.MsToNs(50), GapBps: 100},
+		}
+		return cfg
+	}
+	var results [2]*RunResult
+	for i := range results {
+		r, err := NewRunner(sessions(), t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results[i], err = r.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res := results[0]
+	if res.LogHash != results[1].LogHash {
+		t.Fatal("session run not deterministic")
+	}
+	if err := eventlog.Validate(res.LogPath); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := eventlog.NewReader(res.LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := reader.ReadAll()
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := sessions().SessionPlan()
+	tracker := lifecycle.New()
+	var boundaries, auctions int
+	for _, e := range events {
+		tracker.Apply(e)
+		switch e.Type {
+		case domain.EventSession:
+			boundaries++
+			phase, _ := e.Annotation("phase")
+			ref, _ := e.Annotation("ref_price")
+			if name, _ := e.Annotation("session"); name == "tue" && ref != "101.0000" {
+				t.Errorf("tue ref price %s, want 101.0000", ref)
+			}
+			if phase == domain.PhaseClosed && tracker.Live() != 0 {
+				t.Errorf("%d orders live after the close at %d", tracker.Live(), e.Timestamp)
+			}
+		case domain.EventAuction:
+			auctions++
+			if e.Timestamp != plan[0].OpenEnd && e.Timestamp != plan[0].End && e.Timestamp != plan[1].End {
+				t.Errorf("auction at %d, not at a phase end", e.Timestamp)
+			}
+		case domain.EventTradeExecuted:
+			for _, s := range plan {
+				if e.Timestamp >= s.Start && e.Timestamp < s.End && e.Timestamp != s.OpenEnd &&
+					s.Phase(e.Timestamp) != domain.PhaseContinuous {
+					t.Errorf("trade at %d during an auction phase", e.Timestamp)
+				}
+			}
+		}
+	}
+	if boundaries != 7 || auctions == 0 {
+		t.Errorf("%d SESSION events and %d auctions, want 7 and some", boundaries, auctions)
+	}
+
+	cfg := sessions()
+	whole, err := metrics.ComputeFromLog(res.LogPath, cfg.Scale(), cfg.Metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	per, err := metrics.ComputeSessionsFromLog(res.LogPath, cfg.Scale(), cfg.Metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(per) != 2 || per[0].Name != "day1" || per[1].StartNs != plan[1].Start {
+		t.Fatalf("sessions %+v", per)
+	}
+	for _, id := range []string{cfg.FastTrader.ID, cfg.SlowTrader.ID} {
+		fills := 0
+		for _, s := range per {
+			if m := s.Traders[id]; m != nil {
+				fills += m.TotalFills
+			}
+		}
+		if fills != whole[id].TotalFills {
+			t.Errorf("%s: sessions fill %d, the run %d", id, fills, whole[id].TotalFills)
+		}
+		if last := per[1].Traders[id]; last != nil && last.NetPnL != whole[id].NetPnL {
+			t.Errorf("%s: net PnL %.4f at the last close, %.4f for the run", id, last.NetPnL, whole[id].NetPnL)
+		}
+	}
+
+	long := sessions()
+	long.Duration *= 2
+	if _, err := NewRunner(long, t.TempDir()); err == nil {
+		t.Error("sessions shorter than the run accepted")
+	}
+	batched := sessions()
+	batched.Venue.BatchIntervalNs = latency.MsToNs(10)
+	if _, err := NewRunner(batched, t.TempDir()); err == nil {
+		t.Error("batch auctions alongside session auctions accepted")
+	}
+}
+
+func TestInvalidAssertionRejected(t *testing.T) {
+	cfg := shortCalm(7)
+	cfg.Assertions = []string{"spread is small"}
+	if _, err := NewRunner(cfg, t.TempDir()); err == nil {
+		t.Fatal("expected parse error")
+	}
+}
+
+func benchmarkRun(b *testing.B, checkInvariants bool) {
+	dir := b.TempDir()
+	var events uint64
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, err := NewRunner(shortCalm(42), dir)
+		if err != nil {
+			b.Fatal(err)
+		}
+		r.CheckInvariants = checkInvariants
+		res, err := r.Run()
+		if err != nil {
+			b.Fatal(err)
+		}
+		events += res.EventCount
+	}
+	b.ReportMetric(float64(events)/b.Elapsed().Seconds(), "events/s")
+}
+
+func BenchmarkRunCalmCheapChecks(b *testing.B)    { benchmarkRun(b, false) }
+func BenchmarkRunCalmFullInvariants(b *testing.B) { benchmarkRun(b, true) }
+
+func BenchmarkRunCalmInMemory(b *testing.B) {
+	var events uint64
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		res, err := RunInMemory(shortCalm(42))
+		if err != nil {
+			b.Fatal(err)
+		}
+		events += res.EventCount
+	}
+	b.ReportMetric(float64(events)/b.Elapsed().Seconds(), "events/s")
+}
+
+// TestTapeMatchesTrades checks tape.csv has a row per trade, in order,
+// with the aggressor's side and each side's class
+func TestTapeMatchesTrades(t *testing.T) {
+	cfg := scenario.DefaultSpike(7)
+	cfg.Duration = latency.MsToNs(2_000)
+	r, err := NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(result.OutputDir, "trades.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trades []domain.Trade
+	if err := json.Unmarshal(data, &trades); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(result.OutputDir, "tape.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) == 0 || len(rows) != len(trades)+1 || !slices.Equal(rows[0], TapeHeader) {
+		t.Fatalf("%d tape rows for %d trades", len(rows), len(trades))
+	}
+	traders := 0
+	for i, tr := range trades {
+		row := rows[i+1]
+		side, aggressor, passive := "BUY", tr.BuyTrader, tr.SellTrader
+		if tr.AggressorOrderID == tr.SellOrderID {
+			side, aggressor, passive = "SELL", tr.SellTrader, tr.BuyTrader
+		}
+		want := []string{strconv.FormatInt(tr.Timestamp, 10), cfg.Scale().Format(tr.Price), strconv.FormatInt(tr.Qty, 10),
+			side, traderClass(aggressor), traderClass(passive)}
+		if !slices.Equal(row, want) {
+			t.Fatalf("row %d = %v, want %v", i+1, row, want)
+		}
+		if row[4] != "background" || row[5] != "background" {
+			traders++
+		}
+	}
+	if traders == 0 {
+		t.Error("no trader on either side of any print")
+	}
+}
+
+// TestTradeWriterMatchesMarshalIndent verifies streamed trades.json is
+// byte-identical to marshaling the whole slice at once
+func TestTradeWriterMatchesMarshalIndent(t *testing.T) {
+	for _, n := range []int{0, 1, 3} {
+		var trades []domain.Trade
+		for i := 0; i < n; i++ {
+			trades = append(trades, domain.Trade{
+				ID: uint64(i + 1), BuyOrderID: 10, SellOrderID: 20, BuyTrader: "fast",
+				SellTrader: "background", Price: 1_000_000, Qty: int64(i + 1), Timestamp: 5,
+			})
+		}
+		path := filepath.Join(t.TempDir(), "trades.json")
+		tw, err := newTradeWriter(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range trades {
+			if err := tw.Write(&trades[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := json.MarshalIndent(trades, "", "  ")
+		if string(got) != string(want) {
+			t.Errorf("%d trades: streamed output differs:\n%s\nwant:\n%s", n, got, want)
+		}
+	}
+}
+
+// TestVenueMechanisms runs every venue mechanism and checks each run is
+// valid, clean under audit and reproducible
+func TestVenueMechanisms(t *testing.T) {
+	for _, mech := range scenario.Mechanisms() {
+		var hashes []string
+		for range 2 {
+			cfg := scenario.GetConfig("spike", 7)
+			cfg.Duration = latency.MsToNs(2_000)
+			cfg.Venue = mech.Venue
+			r, err := NewRunner(cfg, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.CheckInvariants = true
+			res, err := r.Run()
+			if err != nil {
+				t.Fatalf("%s: %v", mech.Name, err)
+			}
+			if res.AuditViolations != 0 {
+				t.Errorf("%s: %d audit violations", mech.Name, res.AuditViolations)
+			}
+			if err := eventlog.Validate(res.LogPath); err != nil {
+				t.Errorf("%s: %v", mech.Name, err)
+			}
+			hashes = append(hashes, res.LogHash)
+
+			data, err := os.ReadFile(res.LogPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			auctions := strings.Contains(string(data), `"type":"AUCTION"`)
+			if auctions != (mech.Venue.BatchIntervalNs > 0) {
+				t.Errorf("%s: AUCTION records present = %v", mech.Name, auctions)
+			}
+		}
+		if hashes[0] != hashes[1] {
+			t.Errorf("%s: reruns differ: %s vs %s", mech.Name, hashes[0], hashes[1])
+		}
+	}
+}
+
+// TestComposedVenueMatchesShorthands: a venue built from listed
+// mechanisms runs the same as one built from the shorthand fields, and a
+// throttled venue runs clean
+func TestComposedVenueMatchesShorthands(t *testing.T) {
+	run := func(cfg *scenario.Config) *RunResult {
+		t.Helper()
+		r, err := NewRunner(cfg, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := r.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.AuditViolations != 0 {
+			t.Errorf("%d audit violations", res.AuditViolations)
+		}
+		return res
+	}
+	short := scenario.GetConfig("spike", 7)
+	short.Duration = latency.MsToNs(2_000)
+	short.Venue = scenario.Venue{SpeedBumpNs: latency.MsToNs(10), BatchIntervalNs: latency.MsToNs(50)}
+	short.MakerFee, short.TakerFee = -1, 3
+
+	listed := scenario.GetConfig("spike", 7)
+	listed.Duration = short.Duration
+	for _, s := range []string{"speed_bump:delay=10ms", "batch_auction:interval=50ms", "maker_taker:maker=-0.0001,taker=0.0003"} {
+		spec, err := scenario.ParseMechanismSpec(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		listed.Venue.Mechanisms = append(listed.Venue.Mechanisms, spec)
+	}
+	if a, b := run(short).LogHash, run(listed).LogHash; a != b {
+		t.Errorf("listed mechanisms log %s, shorthands %s", b, a)
+	}
+
+	throttled := scenario.GetConfig("spike", 7)
+	throttled.Duration = short.Duration
+	spec, _ := scenario.ParseMechanismSpec("throttle:max=1,window=5ms")
+	throttled.Venue.Mechanisms = []scenario.MechanismSpec{spec}
+	data, err := os.ReadFile(run(throttled).LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "throttle_wait_ns") {
+		t.Error("no message was throttled")
+	}
+}
+
+func TestInvalidVenueRejected(t *testing.T) {
+	cfg := shortCalm(1)
+	cfg.Venue.Matching = "lottery"
+	if _, err := NewRunner(cfg, t.TempDir()); err == nil {
+		t.Fatal("expected an unknown matching rule to be rejected")
+	}
+}
+
+// TestInFlightAtCloseDropped: a slow trader's messages still in flight at
+// the close must not reach the book after SIM_END
+func TestInFlightAtCloseDropped(t *testing.T) {
+	cfg := scenario.GetConfig("calm", 42)
+	if err := cfg.SlowTrader.SetLatency(103_134_000, time.Duration(cfg.SlowTrader.JitterNs())); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := eventlog.Validate(res.LogPath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestMarketData gives the slow trader market-data rules and a 2ms feed,
+// and checks each BBO tick it acted on is one the venue published 2ms
+// earlier, while the fast trader, with no rules on, is sent nothing
+func TestMarketData(t *testing.T) {
+	cfg := scenario.DefaultSpike(7)
+	cfg.Duration = latency.MsToNs(3_000)
+	cfg.SlowTrader.MarketDataNs = latency.MsToNs(2)
+	cfg.SlowTrader.Strategy.JoinWiden = true
+	cfg.SlowTrader.Strategy.PullSweepQty = 3
+	var hashes []string
+	var res *RunResult
+	for _, pipeline := range []bool{false, true} {
+		r, err := NewRunner(cfg, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.DecisionLog = true
+		r.Pipeline = pipeline
+		if res, err = r.Run(); err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, res.LogHash)
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("log hash differs serial vs pipelined: %s vs %s", hashes[0], hashes[1])
+	}
+
+	reader, err := eventlog.NewReader(res.LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := reader.ReadAll()
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := make(map[int64][]domain.BBO)
+	for _, e := range events {
+		if q := e.BBO(); q != nil {
+			published[e.Timestamp] = append(published[e.Timestamp], *q)
+		}
+	}
+
+	rules := make(map[string]int)
+	for _, id := range []string{"fast", "slow"} {
+		data, err := os.ReadFile(filepath.Join(res.OutputDir, "decisions_"+id+".jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var d trader.Decision
+			if err := json.Unmarshal([]byte(line), &d); err != nil {
+				t.Fatal(err)
+			}
+			if d.Trigger != trader.TriggerMarketData {
+				continue
+			}
+			if id == "fast" {
+				t.Fatalf("fast trader decided on market data: %+v", d)
+			}
+			rules[d.Rules[0]]++
+			if d.Rules[0] != trader.RuleJoinWiden {
+				continue
+			}
+			seen := slices.ContainsFunc(published[d.Time-cfg.SlowTrader.MarketDataNs], func(q domain.BBO) bool {
+				return q.BidPrice == d.Book.BidPrice && q.AskPrice == d.Book.AskPrice
+			})
+			if !seen {
+				t.Errorf("t=%d: acted on %+v, not published 2ms before", d.Time, d.Book)
+			}
+		}
+	}
+	if rules[trader.RuleJoinWiden] == 0 || rules[trader.RulePullSweep] == 0 {
+		t.Errorf("market-data rules fired %v", rules)
+	}
+}
+
+// TestRunInMemoryMatchesDisk runs a spike both ways: the memory run's log
+// hashes as the file does, and its trades and metrics match the disk run's
+func TestRunInMemoryMatchesDisk(t *testing.T) {
+	cfg := scenario.DefaultSpike(7)
+	cfg.Duration = latency.MsToNs(1_000)
+	mem, err := RunInMemory(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	disk, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mem.LogHash != disk.LogHash || uint64(len(mem.Events)) == 0 || mem.EventCount != disk.EventCount {
+		t.Fatalf("memory run: hash %s, %d events logged, %d handled; disk run: hash %s, %d handled",
+			mem.LogHash, len(mem.Events), mem.EventCount, disk.LogHash, disk.EventCount)
+	}
+	if len(mem.Trades) == 0 || len(mem.Trades) != disk.TradeCount {
+		t.Fatalf("%d trades in memory, %d on disk", len(mem.Trades), disk.TradeCount)
+	}
+	want, err := metrics.ComputeFromLog(disk.LogPath, cfg.Scale(), cfg.Metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mem.Metrics, want) {
+		t.Fatal("metrics differ from those of the disk run's log")
+	}
+
+	cfg.LogLevel = "trades"
+	reduced, err := RunInMemory(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reduced.Metrics != nil || len(reduced.Trades) != len(mem.Trades) {
+		t.Fatalf("reduced run: metrics %v, %d trades", reduced.Metrics != nil, len(reduced.Trades))
+	}
+}
@@ -0,0 +1,41 @@
+package sim
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/trader"
+)
+
+// SeedRecord lists the seeds a run derived from its own, written to
+// seeds.json so each random stream can be traced back and diffed
+type SeedRecord struct {
+	Seed int64 `json:"seed"`
+	// Generator drives the background flow and signals; later sessions
+	// derive their own from it
+	Generator int64                  `json:"generator"`
+	Traders   map[string]TraderSeeds `json:"traders"`
+}
+
+// TraderSeeds are one trader's latency seed and RNG substream seeds
+type TraderSeeds struct {
+	Latency int64            `json:"latency"`
+	Streams map[string]int64 `json:"streams"`
+}
+
+// seedRecord collects the seeds in use once the run is over, so it
+// includes any substream a strategy opened along the way
+func (r *Runner) seedRecord() SeedRecord {
+	rec := SeedRecord{Seed: r.cfg.Seed, Generator: r.cfg.Seed, Traders: make(map[string]TraderSeeds)}
+	for _, a := range []*trader.Agent{r.fastAgent, r.slowAgent} {
+		rec.Traders[a.ID] = TraderSeeds{Latency: a.Latency.Seed(), Streams: a.Seeds()}
+	}
+	return rec
+}
+
+// writeSeeds writes seeds.json to the run directory, alongside config.json
+func (r *Runner) writeSeeds() {
+	data, _ := json.MarshalIndent(r.seedRecord(), "", "  ")
+	os.WriteFile(filepath.Join(r.outputDir, "seeds.json"), data, 0644)
+}
@@ -0,0 +1,45 @@
+package sim
+
+import (
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// scheduleSessions schedules a SESSION event at each phase boundary of
+// each session. They go in before the background flow, so a boundary is
+// handled before any message arriving at the same time
+func (r *Runner) scheduleSessions() {
+	scale := r.cfg.Scale()
+	for _, s := range r.cfg.SessionPlan() {
+		ref := scale.Format(s.RefPrice)
+		at := func(ts int64, phase string) {
+			e := domain.NewSessionEvent(ts, s.Name, phase, ref)
+			r.loop.Schedule(&e)
+		}
+		if s.OpenEnd > s.Start {
+			at(s.Start, domain.PhaseOpenAuction)
+		}
+		at(s.OpenEnd, domain.PhaseContinuous)
+		if s.CloseStart < s.End {
+			at(s.CloseStart, domain.PhaseCloseAuction)
+		}
+		at(s.End, domain.PhaseClosed)
+	}
+}
+
+// handleSession crosses the auction, if any, that ends at a session
+// boundary, then logs the boundary. At the close the session's orders
+// expire: the lifecycle tracker marks them, the book is cleared and the
+// traders forget them
+func (r *Runner) handleSession(event *domain.Event) {
+	r.venue.Wake(event.Timestamp)
+	r.logEvent(event)
+	if phase, _ := event.Annotation("phase"); phase != domain.PhaseClosed {
+		return
+	}
+	for _, o := range r.book.Clear() {
+		if agent := r.agent(o.TraderID); agent != nil {
+			agent.Forget(o.ID)
+		}
+	}
+	venueHost{r}.Quote(event.Timestamp, r.book.BBO())
+}
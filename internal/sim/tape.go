@@ -0,0 +1,77 @@
+package sim
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// TapeHeader is the header of tape.csv
+var TapeHeader = []string{"time_ns", "price", "size", "aggressor_side", "aggressor_class", "passive_class"}
+
+// tapeWriter streams the trade prints to tape.csv as they execute: one
+// row per trade, with the side that crossed the spread and the class of
+// trader on each side, fast, slow or background. It is the tape a market
+// replay tool expects, without the order IDs and queue detail of
+// trades.json
+type tapeWriter struct {
+	file  *os.File
+	buf   *bufio.Writer
+	csv   *csv.Writer
+	scale domain.Scale
+}
+
+func newTapeWriter(path string, scale domain.Scale) (*tapeWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create tape: %w", err)
+	}
+	buf := bufio.NewWriterSize(f, 64*1024)
+	tw := &tapeWriter{file: f, buf: buf, csv: csv.NewWriter(buf), scale: scale}
+	if err := tw.csv.Write(TapeHeader); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return tw, nil
+}
+
+// Write appends one trade print. A trade with no aggressor, which no
+// mechanism currently produces, has the side and classes left blank
+func (tw *tapeWriter) Write(t *domain.Trade) error {
+	var side, aggressor, passive string
+	switch t.AggressorOrderID {
+	case 0:
+	case t.BuyOrderID:
+		side, aggressor, passive = domain.Buy.String(), traderClass(t.BuyTrader), traderClass(t.SellTrader)
+	case t.SellOrderID:
+		side, aggressor, passive = domain.Sell.String(), traderClass(t.SellTrader), traderClass(t.BuyTrader)
+	}
+	return tw.csv.Write([]string{strconv.FormatInt(t.Timestamp, 10), tw.scale.Format(t.Price),
+		strconv.FormatInt(t.Qty, 10), side, aggressor, passive})
+}
+
+// traderClass is the trader's ID, or "background" for any background
+// participant
+func traderClass(id string) string {
+	if domain.IsBackground(id) {
+		return domain.BackgroundTrader
+	}
+	return id
+}
+
+func (tw *tapeWriter) Close() error {
+	tw.csv.Flush()
+	if err := tw.csv.Error(); err != nil {
+		tw.file.Close()
+		return err
+	}
+	if err := tw.buf.Flush(); err != nil {
+		tw.file.Close()
+		return err
+	}
+	return tw.file.Close()
+}
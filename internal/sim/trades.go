@@ -0,0 +1,73 @@
+package sim
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// tradeWriter streams trades to trades.json as they execute. The output is
+// byte-identical to json.MarshalIndent over the full trade slice, but memory
+// stays constant regardless of run length
+type tradeWriter struct {
+	file   *os.File
+	writer *bufio.Writer
+	count  int
+}
+
+func newTradeWriter(path string) (*tradeWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create trades file: %w", err)
+	}
+	return &tradeWriter{
+		file:   f,
+		writer: bufio.NewWriterSize(f, 64*1024),
+	}, nil
+}
+
+// Write appends one trade to the array
+func (tw *tradeWriter) Write(trade *domain.Trade) error {
+	data, err := json.MarshalIndent(trade, "  ", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal trade: %w", err)
+	}
+	sep := ",\n  "
+	if tw.count == 0 {
+		sep = "[\n  "
+	}
+	if _, err := tw.writer.WriteString(sep); err != nil {
+		return err
+	}
+	if _, err := tw.writer.Write(data); err != nil {
+		return err
+	}
+	tw.count++
+	return nil
+}
+
+// Close terminates the array and closes the file
+// An empty run is written as null, matching a marshaled nil slice
+func (tw *tradeWriter) Close() error {
+	tail := "\n]"
+	if tw.count == 0 {
+		tail = "null"
+	}
+	if _, err := tw.writer.WriteString(tail); err != nil {
+		tw.file.Close()
+		return err
+	}
+	if err := tw.writer.Flush(); err != nil {
+		tw.file.Close()
+		return err
+	}
+	return tw.file.Close()
+}
+
+// Count returns the number of trades written
+func (tw *tradeWriter) Count() int {
+	return tw.count
+}
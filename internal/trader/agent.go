@@ -4,8 +4,8 @@ package trader
 
 import (
 	"math/rand"
-	"sort"
 
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
 )
@@ -16,25 +16,59 @@ type Agent struct {
 	Latency  *latency.Model
 	Strategy *Strategy
 
-	rng      *rand.Rand
-	nextID   uint64
-	idBase   uint64
+	// Path is the stages the agent's messages pass on their way to the
+	// venue: its risk check, gateway and connection, if any, then Latency
+	Path latency.Path
+
+	// Signal access: SignalNoise is the standard deviation of noise on the
+	// signals this agent sees, drawn from its own seeded RNG. The runner
+	// applies SignalDelayNs and NoSignal when it delivers them
+	SignalNoise   float64
+	SignalDelayNs int64
+	NoSignal      bool
+
+	// MarketDataNs is how long market-data ticks take to reach the agent;
+	// the runner applies it. seen is the BBO as the ticks have shown it
+	MarketDataNs int64
+	seen         domain.BBO
+
+	// OnDecision, if set, receives a record of every strategy decision
+	OnDecision func(*Decision)
+
+	// seed roots the agent's RNG substreams; see Rand
+	seed    int64
+	streams map[string]*rand.Rand
+	seeds   map[string]int64
+
+	nextID uint64
+	idBase uint64
 
 	// Active orders this agent has on the book
 	ActiveOrders map[uint64]*domain.Order
+
+	// reducing holds active orders with a partial cancel not yet acked
+	reducing map[uint64]bool
 }
 
 // NewAgent creates a new trading agent
 func NewAgent(id string, lat *latency.Model, seed int64, idBase uint64) *Agent {
-	return &Agent{
+	a := &Agent{
 		ID:           id,
 		Latency:      lat,
+		Path:         latency.Path{latency.Network{Model: lat}},
 		Strategy:     NewStrategy(),
-		rng:          rand.New(rand.NewSource(seed)),
+		seed:         seed,
+		streams:      make(map[string]*rand.Rand),
+		seeds:        make(map[string]int64),
 		idBase:       idBase,
 		nextID:       idBase,
 		ActiveOrders: make(map[uint64]*domain.Order),
+		reducing:     make(map[uint64]bool),
 	}
+	// Open the standard substreams up front, so Seeds records them all
+	a.Rand(StreamSignalNoise)
+	a.Rand(StreamStrategy)
+	return a
 }
 
 func (a *Agent) allocateID() uint64 {
@@ -44,12 +78,53 @@ func (a *Agent) allocateID() uint64 {
 
 // OnSignal processes a signal event and returns orders to submit
 // The orders have DecisionTime set; the caller applies latency to get ArrivalTime
+// A batching strategy holds signals back and decides on every Nth, with
+// the batch's mean value and the latest signal's market features
 func (a *Agent) OnSignal(signal *domain.Signal, bbo *domain.BBO, currentTime int64) []*domain.Order {
+	s := a.Strategy
+	if s.BatchSignals <= 1 {
+		return a.decide(TriggerSignal, signal, bbo, currentTime)
+	}
+	s.batchSum += signal.Value
+	s.batched++
+	if s.batched < s.BatchSignals {
+		return nil
+	}
+	batch := *signal
+	batch.Value = s.batchSum / float64(s.batched)
+	s.batchSum, s.batched = 0, 0
+	return a.decide(TriggerBatch, &batch, bbo, currentTime)
+}
+
+// OnReQuote is a periodic re-quote: a decision on a neutral signal
+// carrying the market features
+func (a *Agent) OnReQuote(market *domain.Signal, bbo *domain.BBO, currentTime int64) []*domain.Order {
+	return a.decide(TriggerReQuote, market, bbo, currentTime)
+}
+
+func (a *Agent) decide(trigger string, signal *domain.Signal, bbo *domain.BBO, currentTime int64) []*domain.Order {
 	if bbo.BidPrice == 0 || bbo.AskPrice == 0 {
-		return nil // no market to trade against
+		// No market to trade against
+		if d := a.newDecision(trigger, signal, 0, bbo, currentTime); d != nil {
+			d.fire(RuleNoMarket)
+			a.OnDecision(d)
+		}
+		return nil
+	}
+	noise := 0.0
+	if a.SignalNoise > 0 {
+		seen := *signal
+		noise = a.Rand(StreamSignalNoise).NormFloat64() * a.SignalNoise
+		seen.Value += noise
+		signal = &seen
 	}
 
-	return a.Strategy.Decide(a, signal, bbo, currentTime)
+	d := a.newDecision(trigger, signal, noise, bbo, currentTime)
+	orders := a.Strategy.Decide(a, signal, bbo, currentTime, d)
+	if d != nil {
+		a.OnDecision(d)
+	}
+	return orders
 }
 
 // OnFill notifies the agent that one of its orders was filled
@@ -61,15 +136,26 @@ func (a *Agent) OnFill(trade *domain.Trade, orderID uint64) {
 		return
 	}
 	if order.RemainingQty <= 0 {
-		delete(a.ActiveOrders, orderID)
+		a.Forget(orderID)
 	}
 }
 
-// OnCancel notifies the agent that one of its orders was cancelled
+// OnCancelAck notifies the agent that a cancel of one of its orders took
+// effect. An order a partial cancel only reduced stays active
 func (a *Agent) OnCancelAck(orderID uint64) {
+	delete(a.reducing, orderID)
+	if order, exists := a.ActiveOrders[orderID]; exists && order.RemainingQty > 0 {
+		return
+	}
 	delete(a.ActiveOrders, orderID)
 }
 
+// Forget drops an order that has left the book
+func (a *Agent) Forget(orderID uint64) {
+	delete(a.ActiveOrders, orderID)
+	delete(a.reducing, orderID)
+}
+
 // Strategy defines the simple post-at-best + rebalance logic
 type Strategy struct {
 	// ReQuoteInterval: how long to wait before re-quoting (in nanos)
@@ -78,11 +164,40 @@ type Strategy struct {
 	CancelTimeoutNs int64
 	// CrossThreshold: if signal exceeds this, cross with market order
 	CrossThreshold float64
+	// CrossTIF: IOC or FOK crosses with a limit order at the far touch
+	// with that time in force instead, so a stale cross misses rather
+	// than sweeping. Day keeps the market order
+	CrossTIF domain.TimeInForce
+	// ReduceThreshold: if signal leans against a resting quote beyond
+	// this, halve the quote with a partial cancel. 0 never reduces
+	ReduceThreshold float64
 	// TargetQty: quantity to post
 	TargetQty int64
+	// DisplayQty: above 0, quotes rest as icebergs showing this much of
+	// TargetQty at a time
+	DisplayQty int64
+
+	// BatchSignals: above 1, act once every this many signals instead
+	// of on each; see OnSignal. Re-quotes and market data are not batched
+	BatchSignals int
+
+	// PullSweepQty and JoinWiden turn on the market-data rules; see
+	// OnMarketData
+	PullSweepQty int64
+	JoinWiden    bool
 
 	lastSignalValue float64
 	lastActionTime  int64
+
+	// the signals held back since the last batch decision
+	batchSum float64
+	batched  int
+
+	// the aggressing order whose prints are being added up, and whether
+	// its sweep has already pulled quotes
+	sweepID  uint64
+	sweepQty int64
+	swept    bool
 }
 
 // NewStrategy creates a strategy with default parameters
@@ -95,29 +210,71 @@ func NewStrategy() *Strategy {
 	}
 }
 
-// Decide generates orders based on the current signal and book state
-func (s *Strategy) Decide(agent *Agent, signal *domain.Signal, bbo *domain.BBO, currentTime int64) []*domain.Order {
+// Decide generates orders based on the current signal and book state.
+// d, if not nil, records the rules fired and the orders sent or held back
+func (s *Strategy) Decide(agent *Agent, signal *domain.Signal, bbo *domain.BBO, currentTime int64, d *Decision) []*domain.Order {
 	var orders []*domain.Order
 
 	// 1. Cancel stale orders that have been resting too long
 	// Sort keys for deterministic iteration
-	activeIDs := make([]uint64, 0, len(agent.ActiveOrders))
-	for id := range agent.ActiveOrders {
-		activeIDs = append(activeIDs, id)
-	}
-	sort.Slice(activeIDs, func(i, j int) bool { return activeIDs[i] < activeIDs[j] })
+	activeIDs := detorder.Keys(agent.ActiveOrders)
+	var canceling map[uint64]bool // for the decision log
 	for _, id := range activeIDs {
 		order := agent.ActiveOrders[id]
 		age := currentTime - order.DecisionTime
 		if age > s.CancelTimeoutNs {
-			cancelOrder := &domain.Order{
+			if len(orders) == 0 {
+				d.fire(RuleCancelStale)
+			}
+			cancelOrder := domain.PooledOrder(domain.Order{
 				ID:           agent.allocateID(),
 				TraderID:     agent.ID,
+				Side:         order.Side,
 				Type:         domain.CancelOrder,
+				Price:        order.Price,
 				CancelID:     id,
 				DecisionTime: currentTime,
-			}
+			})
 			orders = append(orders, cancelOrder)
+			if d != nil {
+				if canceling == nil {
+					canceling = make(map[uint64]bool)
+				}
+				canceling[id] = true
+			}
+			d.send(RuleCancelStale, cancelOrder, "rested %s, over the %s timeout", latency.Format(age), latency.Format(s.CancelTimeoutNs))
+		}
+	}
+
+	// 1b. Shrink quotes the signal leans against. A partial cancel keeps
+	// the quote's place in the queue, which pulling and re-posting would lose
+	if s.ReduceThreshold > 0 {
+		fired := false
+		for _, id := range activeIDs {
+			order := agent.ActiveOrders[id]
+			against := (order.Side == domain.Buy && signal.Value < -s.ReduceThreshold) ||
+				(order.Side == domain.Sell && signal.Value > s.ReduceThreshold)
+			cut := order.RemainingQty / 2
+			if !against || cut == 0 || agent.reducing[id] || currentTime-order.DecisionTime > s.CancelTimeoutNs {
+				continue
+			}
+			if !fired {
+				d.fire(RuleReduce)
+				fired = true
+			}
+			reduceOrder := domain.PooledOrder(domain.Order{
+				ID:           agent.allocateID(),
+				TraderID:     agent.ID,
+				Side:         order.Side,
+				Type:         domain.CancelOrder,
+				Price:        order.Price,
+				Qty:          cut,
+				CancelID:     id,
+				DecisionTime: currentTime,
+			})
+			agent.reducing[id] = true
+			orders = append(orders, reduceOrder)
+			d.send(RuleReduce, reduceOrder, "signal %g against it beyond ±%g, halve %d", signal.Value, s.ReduceThreshold, order.RemainingQty)
 		}
 	}
 
@@ -131,15 +288,26 @@ func (s *Strategy) Decide(agent *Agent, signal *domain.Signal, bbo *domain.BBO,
 			side = domain.Sell
 		}
 
-		marketOrder := &domain.Order{
+		crossOrder := domain.PooledOrder(domain.Order{
 			ID:           agent.allocateID(),
 			TraderID:     agent.ID,
 			Side:         side,
 			Type:         domain.MarketOrder,
 			Qty:          s.TargetQty,
 			DecisionTime: currentTime,
+		})
+		far := bbo.AskPrice
+		if side == domain.Sell {
+			far = bbo.BidPrice
 		}
-		orders = append(orders, marketOrder)
+		if s.CrossTIF != domain.Day && far > 0 {
+			crossOrder.Type, crossOrder.Price, crossOrder.TimeInForce = domain.LimitOrder, far, s.CrossTIF
+		}
+		orders = append(orders, crossOrder)
+		d.fire(RuleCross)
+		d.send(RuleCross, crossOrder, "signal %g beyond threshold %g", signal.Value, s.CrossThreshold)
+		d.suppress(RulePost, domain.Buy, "crossing instead of quoting")
+		d.suppress(RulePost, domain.Sell, "crossing instead of quoting")
 		s.lastSignalValue = signal.Value
 		s.lastActionTime = currentTime
 		return orders
@@ -147,45 +315,64 @@ func (s *Strategy) Decide(agent *Agent, signal *domain.Signal, bbo *domain.BBO,
 
 	// 3. Otherwise, post limit orders at best bid/ask
 	// Only if we don't already have orders on this side
+	d.fire(RulePost)
 	hasBid, hasAsk := false, false
+	var bidID, askID uint64
 	for _, id := range activeIDs {
 		o := agent.ActiveOrders[id]
-		_ = o
-		if o.Side == domain.Buy {
-			hasBid = true
+		if o.Side == domain.Buy && !hasBid {
+			hasBid, bidID = true, id
 		}
-		if o.Side == domain.Sell {
-			hasAsk = true
+		if o.Side == domain.Sell && !hasAsk {
+			hasAsk, askID = true, id
 		}
 	}
+	if hasBid {
+		d.suppress(RulePost, domain.Buy, "order %d %s on the bid", bidID, stillOn(canceling[bidID]))
+	}
+	if hasAsk {
+		d.suppress(RulePost, domain.Sell, "order %d %s on the ask", askID, stillOn(canceling[askID]))
+	}
 
 	if !hasBid && bbo.BidPrice > 0 {
-		bidOrder := &domain.Order{
+		bidOrder := domain.PooledOrder(domain.Order{
 			ID:           agent.allocateID(),
 			TraderID:     agent.ID,
 			Side:         domain.Buy,
 			Type:         domain.LimitOrder,
 			Price:        bbo.BidPrice,
 			Qty:          s.TargetQty,
+			DisplayQty:   s.DisplayQty,
 			DecisionTime: currentTime,
-		}
+		})
 		orders = append(orders, bidOrder)
+		d.send(RulePost, bidOrder, "join the best bid")
 	}
 
 	if !hasAsk && bbo.AskPrice > 0 {
-		askOrder := &domain.Order{
+		askOrder := domain.PooledOrder(domain.Order{
 			ID:           agent.allocateID(),
 			TraderID:     agent.ID,
 			Side:         domain.Sell,
 			Type:         domain.LimitOrder,
 			Price:        bbo.AskPrice,
 			Qty:          s.TargetQty,
+			DisplayQty:   s.DisplayQty,
 			DecisionTime: currentTime,
-		}
+		})
 		orders = append(orders, askOrder)
+		d.send(RulePost, askOrder, "join the best ask")
 	}
 
 	s.lastSignalValue = signal.Value
 	s.lastActionTime = currentTime
 	return orders
 }
+
+// stillOn says why a resting order blocks a new quote on its side
+func stillOn(canceling bool) string {
+	if canceling {
+		return "is canceling but still"
+	}
+	return "already"
+}
@@ -0,0 +1,138 @@
+package trader
+
+import (
+	"fmt"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// Decision triggers
+const (
+	TriggerSignal     = "signal"
+	TriggerBatch      = "batch" // every Nth signal of a batching strategy
+	TriggerReQuote    = "requote"
+	TriggerMarketData = "market_data"
+)
+
+// Strategy rules a decision can fire
+const (
+	RuleNoMarket    = "no_market"    // a book side is empty, nothing to do
+	RuleCancelStale = "cancel_stale" // cancel orders older than the timeout
+	RuleReduce      = "reduce"       // halve quotes the signal leans against
+	RuleCross       = "cross"        // signal beyond the threshold, take liquidity
+	RulePost        = "post"         // join the best bid and ask
+	RuleJoinWiden   = "join_widen"   // quote at the new best when the spread widens
+	RulePullSweep   = "pull_sweep"   // pull quotes on the side a sweep hits
+)
+
+// Decision is one call into a trader's strategy: what it saw, which rules
+// fired, the orders it sent and the ones it held back. It only explains
+// the strategy; the market's answer is in the event log
+type Decision struct {
+	Time    int64  `json:"time_ns"`
+	SeqNo   uint64 `json:"seq_no"` // the event decided on, set by the runner
+	Trader  string `json:"trader_id"`
+	Trigger string `json:"trigger"`
+
+	// Signal is the value the strategy acted on, after noise; SignalNoise
+	// is the noise added. Uninformed traders see 0
+	Signal      float64 `json:"signal"`
+	SignalNoise float64 `json:"signal_noise,omitempty"`
+	Uninformed  bool    `json:"uninformed,omitempty"`
+	Threshold   float64 `json:"cross_threshold"`
+
+	Book   DecisionBook    `json:"book"`
+	Active []DecisionOrder `json:"active,omitempty"` // own orders on the book, by ID
+
+	Rules      []string        `json:"rules"`
+	Orders     []DecisionOrder `json:"orders,omitempty"`
+	Suppressed []Suppressed    `json:"suppressed,omitempty"`
+}
+
+// DecisionBook is the market as the trader saw it
+type DecisionBook struct {
+	BidPrice    int64   `json:"bid_price"`
+	BidQty      int64   `json:"bid_qty"`
+	AskPrice    int64   `json:"ask_price"`
+	AskQty      int64   `json:"ask_qty"`
+	Imbalance   float64 `json:"imbalance"`
+	TradeFlow   int64   `json:"trade_flow"`
+	TradeVolume int64   `json:"trade_volume"`
+}
+
+// DecisionOrder is an order sent, or one the trader already had
+type DecisionOrder struct {
+	ID       uint64             `json:"order_id"`
+	Type     domain.OrderType   `json:"type"`
+	Side     domain.Side        `json:"side"`
+	Price    int64              `json:"price,omitempty"`
+	Qty      int64              `json:"qty,omitempty"` // for a partial cancel, the qty taken off
+	CancelID uint64             `json:"cancel_id,omitempty"`
+	TIF      domain.TimeInForce `json:"tif,omitempty"`
+	Display  int64              `json:"display_qty,omitempty"`
+	AgeNs    int64              `json:"age_ns,omitempty"` // active orders only
+	Rule     string             `json:"rule,omitempty"`   // sent orders only
+	Reason   string             `json:"reason,omitempty"`
+}
+
+// Suppressed is an order a rule would have sent but did not
+type Suppressed struct {
+	Rule   string      `json:"rule"`
+	Side   domain.Side `json:"side"`
+	Reason string      `json:"reason"`
+}
+
+// newDecision starts the record of a decision; nil unless the agent
+// records them, and every method below is a no-op on nil
+func (a *Agent) newDecision(trigger string, signal *domain.Signal, noise float64, bbo *domain.BBO, t int64) *Decision {
+	if a.OnDecision == nil {
+		return nil
+	}
+	d := &Decision{
+		Time:        t,
+		Trader:      a.ID,
+		Trigger:     trigger,
+		Signal:      signal.Value,
+		SignalNoise: noise,
+		Uninformed:  a.NoSignal,
+		Threshold:   a.Strategy.CrossThreshold,
+		Book: DecisionBook{
+			BidPrice:    bbo.BidPrice,
+			BidQty:      bbo.BidQty,
+			AskPrice:    bbo.AskPrice,
+			AskQty:      bbo.AskQty,
+			Imbalance:   signal.Imbalance,
+			TradeFlow:   signal.TradeFlow,
+			TradeVolume: signal.TradeVolume,
+		},
+		Rules: []string{},
+	}
+	for _, o := range detorder.All(a.ActiveOrders) {
+		d.Active = append(d.Active, DecisionOrder{
+			ID: o.ID, Type: o.Type, Side: o.Side, Price: o.Price, Qty: o.RemainingQty, AgeNs: t - o.DecisionTime,
+		})
+	}
+	return d
+}
+
+func (d *Decision) fire(rule string) {
+	if d != nil {
+		d.Rules = append(d.Rules, rule)
+	}
+}
+
+func (d *Decision) send(rule string, o *domain.Order, format string, args ...any) {
+	if d != nil {
+		d.Orders = append(d.Orders, DecisionOrder{
+			ID: o.ID, Type: o.Type, Side: o.Side, Price: o.Price, Qty: o.Qty, CancelID: o.CancelID, TIF: o.TimeInForce, Display: o.DisplayQty,
+			Rule: rule, Reason: fmt.Sprintf(format, args...),
+		})
+	}
+}
+
+func (d *Decision) suppress(rule string, side domain.Side, format string, args ...any) {
+	if d != nil {
+		d.Suppressed = append(d.Suppressed, Suppressed{Rule: rule, Side: side, Reason: fmt.Sprintf(format, args...)})
+	}
+}
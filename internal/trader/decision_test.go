@@ -0,0 +1,107 @@
+package trader
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+)
+
+// TestDecisionLog walks one agent through quoting, holding, timing out and
+// crossing, and checks each decision's record explains its orders
+func TestDecisionLog(t *testing.T) {
+	a := NewAgent("fast", latency.NewModel(0, 0, 1), 2, 1_000_000)
+	var log []*Decision
+	a.OnDecision = func(d *Decision) { log = append(log, d) }
+	bbo := &domain.BBO{BidPrice: 990, BidQty: 10, AskPrice: 1010, AskQty: 10}
+
+	if orders := a.OnSignal(&domain.Signal{}, &domain.BBO{BidPrice: 990, BidQty: 10}, 0); orders != nil {
+		t.Fatalf("quoted on a one-sided book: %v", orders)
+	}
+	posted := a.OnReQuote(&domain.Signal{}, bbo, 10)
+	for _, o := range posted {
+		a.ActiveOrders[o.ID] = o
+	}
+	a.OnSignal(&domain.Signal{Value: 0.5}, bbo, 20)
+	stale := a.OnReQuote(&domain.Signal{}, bbo, 10+a.Strategy.CancelTimeoutNs+1)
+	a.OnSignal(&domain.Signal{Value: -2}, bbo, 10+a.Strategy.CancelTimeoutNs+2)
+
+	if len(log) != 5 {
+		t.Fatalf("%d decisions, want 5", len(log))
+	}
+	for i, want := range []struct {
+		trigger    string
+		rules      []string
+		orders     int
+		suppressed string
+	}{
+		{TriggerSignal, []string{RuleNoMarket}, 0, ""},
+		{TriggerReQuote, []string{RulePost}, 2, ""},
+		{TriggerSignal, []string{RulePost}, 0, "already on the bid"},
+		{TriggerReQuote, []string{RuleCancelStale, RulePost}, 2, "is canceling but still on the bid"},
+		{TriggerSignal, []string{RuleCancelStale, RuleCross}, 3, "crossing instead of quoting"},
+	} {
+		d := log[i]
+		if d.Trigger != want.trigger || !slices.Equal(d.Rules, want.rules) || len(d.Orders) != want.orders {
+			t.Errorf("decision %d: %s %v with %d orders, want %s %v with %d",
+				i, d.Trigger, d.Rules, len(d.Orders), want.trigger, want.rules, want.orders)
+		}
+		if want.suppressed != "" && (len(d.Suppressed) == 0 || !strings.Contains(d.Suppressed[0].Reason, want.suppressed)) {
+			t.Errorf("decision %d suppressed %+v, want %q", i, d.Suppressed, want.suppressed)
+		}
+	}
+	if log[1].Orders[0].ID != posted[0].ID || log[3].Orders[0].CancelID != posted[0].ID || len(stale) != 2 {
+		t.Errorf("records do not match the orders sent: %+v", log[3].Orders)
+	}
+	if len(log[2].Active) != 2 || log[2].Active[0].AgeNs != 10 || log[1].Book.AskPrice != 1010 {
+		t.Errorf("decision 2 saw %+v and %+v", log[2].Active, log[2].Book)
+	}
+}
+
+// TestReduceRule checks a signal against a quote halves it with a partial
+// cancel, once per ack, and that the reduced quote stays active
+func TestReduceRule(t *testing.T) {
+	a := NewAgent("fast", latency.NewModel(0, 0, 1), 2, 1_000_000)
+	a.Strategy.ReduceThreshold = 0.3
+	var log []*Decision
+	a.OnDecision = func(d *Decision) { log = append(log, d) }
+	bbo := &domain.BBO{BidPrice: 990, BidQty: 10, AskPrice: 1010, AskQty: 10}
+
+	for _, o := range a.OnReQuote(&domain.Signal{}, bbo, 0) {
+		o.RemainingQty = o.Qty
+		a.ActiveOrders[o.ID] = o
+	}
+	bid := a.ActiveOrders[activeOn(a, domain.Buy)]
+
+	reduce := a.OnSignal(&domain.Signal{Value: -0.5}, bbo, 10)
+	if len(reduce) != 1 || reduce[0].Type != domain.CancelOrder || reduce[0].CancelID != bid.ID || reduce[0].Qty != 2 {
+		t.Fatalf("expected a cancel of 2 from the bid, got %+v", reduce)
+	}
+	if !slices.Equal(log[1].Rules, []string{RuleReduce, RulePost}) || log[1].Orders[0].Qty != 2 {
+		t.Errorf("decision %v with %+v", log[1].Rules, log[1].Orders)
+	}
+	if again := a.OnSignal(&domain.Signal{Value: -0.5}, bbo, 20); len(again) != 0 {
+		t.Errorf("reduced again before the ack: %+v", again)
+	}
+
+	bid.RemainingQty = 3
+	a.OnCancelAck(bid.ID)
+	if a.ActiveOrders[bid.ID] == nil {
+		t.Fatal("reduced bid dropped on its ack")
+	}
+	if again := a.OnSignal(&domain.Signal{Value: -0.5}, bbo, 30); len(again) != 1 || again[0].Qty != 1 {
+		t.Errorf("expected a cancel of 1 after the ack, got %+v", again)
+	}
+}
+
+// activeOn returns the ID of the agent's active order on side
+func activeOn(a *Agent, side domain.Side) uint64 {
+	for id, o := range a.ActiveOrders {
+		if o.Side == side {
+			return id
+		}
+	}
+	return 0
+}
@@ -0,0 +1,133 @@
+package trader
+
+import (
+	"github.com/akshitanchan/execution-fairness-simulator/internal/detorder"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+)
+
+// MarketData is one tick of the public feed: a BBO update or a trade
+// print, which carries no trader IDs
+type MarketData struct {
+	Published int64 // when the venue published it
+	BBO       *domain.BBO
+	Trade     *domain.Trade
+}
+
+// WantsMarketData reports whether the agent's strategy has a market-data
+// rule on. The runner only sends ticks to agents that do
+func (a *Agent) WantsMarketData() bool {
+	return a.Strategy.PullSweepQty > 0 || a.Strategy.JoinWiden
+}
+
+// OnMarketData processes a tick as it reaches the agent, after its
+// market-data latency, and returns orders to submit. Only ticks on which
+// a rule fires are recorded as decisions
+func (a *Agent) OnMarketData(md *MarketData, currentTime int64) []*domain.Order {
+	prev := a.seen
+	if md.BBO != nil {
+		a.seen = *md.BBO
+	}
+	d := a.newDecision(TriggerMarketData, &domain.Signal{}, 0, &a.seen, currentTime)
+	orders := a.Strategy.OnMarketData(a, md, prev, currentTime, d)
+	if d != nil && len(d.Rules) > 0 {
+		a.OnDecision(d)
+	}
+	return orders
+}
+
+// OnMarketData applies the market-data rules to one tick. prev is the
+// BBO the agent had seen before it
+func (s *Strategy) OnMarketData(agent *Agent, md *MarketData, prev domain.BBO, currentTime int64, d *Decision) []*domain.Order {
+	switch {
+	case md.BBO != nil && s.JoinWiden:
+		return s.joinWiden(agent, prev, md.BBO, currentTime, d)
+	case md.Trade != nil && s.PullSweepQty > 0:
+		return s.pullSweep(agent, md.Trade, currentTime, d)
+	}
+	return nil
+}
+
+// joinWiden quotes at the new best on each side that moved away when the
+// spread widens, unless the agent already has an order there
+func (s *Strategy) joinWiden(agent *Agent, prev domain.BBO, bbo *domain.BBO, currentTime int64, d *Decision) []*domain.Order {
+	if prev.BidPrice == 0 || prev.AskPrice == 0 || bbo.BidPrice == 0 || bbo.AskPrice == 0 {
+		return nil
+	}
+	if bbo.AskPrice-bbo.BidPrice <= prev.AskPrice-prev.BidPrice {
+		return nil
+	}
+	d.fire(RuleJoinWiden)
+	has := map[domain.Side]bool{}
+	for _, o := range detorder.All(agent.ActiveOrders) {
+		has[o.Side] = true
+	}
+	var orders []*domain.Order
+	for _, q := range []struct {
+		side        domain.Side
+		price, from int64
+	}{{domain.Buy, bbo.BidPrice, prev.BidPrice}, {domain.Sell, bbo.AskPrice, prev.AskPrice}} {
+		if q.price == q.from {
+			continue
+		}
+		if has[q.side] {
+			d.suppress(RuleJoinWiden, q.side, "already quoting that side")
+			continue
+		}
+		order := domain.PooledOrder(domain.Order{
+			ID:           agent.allocateID(),
+			TraderID:     agent.ID,
+			Side:         q.side,
+			Type:         domain.LimitOrder,
+			Price:        q.price,
+			Qty:          s.TargetQty,
+			DisplayQty:   s.DisplayQty,
+			DecisionTime: currentTime,
+		})
+		orders = append(orders, order)
+		d.send(RuleJoinWiden, order, "spread widened from %d to %d", prev.AskPrice-prev.BidPrice, bbo.AskPrice-bbo.BidPrice)
+	}
+	return orders
+}
+
+// pullSweep adds up the prints of one aggressing order. Once they reach
+// PullSweepQty it cancels the agent's orders on the side being hit, once
+// per sweep
+func (s *Strategy) pullSweep(agent *Agent, tr *domain.Trade, currentTime int64, d *Decision) []*domain.Order {
+	if tr.AggressorOrderID != s.sweepID {
+		s.sweepID, s.sweepQty, s.swept = tr.AggressorOrderID, 0, false
+	}
+	s.sweepQty += tr.Qty
+	if s.swept || s.sweepQty < s.PullSweepQty {
+		return nil
+	}
+	s.swept = true
+	d.fire(RulePullSweep)
+
+	// A buyer sweeps the asks, a seller the bids
+	hit, name := domain.Sell, "asks"
+	if tr.AggressorOrderID == tr.SellOrderID {
+		hit, name = domain.Buy, "bids"
+	}
+	var orders []*domain.Order
+	for _, id := range detorder.Keys(agent.ActiveOrders) {
+		order := agent.ActiveOrders[id]
+		if order.Side != hit {
+			continue
+		}
+		cancel := domain.PooledOrder(domain.Order{
+			ID:           agent.allocateID(),
+			TraderID:     agent.ID,
+			Side:         order.Side,
+			Type:         domain.CancelOrder,
+			Price:        order.Price,
+			CancelID:     id,
+			DecisionTime: currentTime,
+		})
+		orders = append(orders, cancel)
+		d.send(RulePullSweep, cancel, "a sweep of %d through the %s", s.sweepQty, name)
+	}
+	if len(orders) == 0 {
+		d.suppress(RulePullSweep, hit, "no order on the %s to pull", name)
+	}
+	return orders
+}
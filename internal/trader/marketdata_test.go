@@ -0,0 +1,65 @@
+package trader
+
+import (
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+)
+
+// TestMarketDataRules feeds an agent ticks: a widening spread it joins,
+// and a sweep whose prints add up past the threshold to pull its quote
+func TestMarketDataRules(t *testing.T) {
+	a := NewAgent("slow", latency.NewModel(0, 0, 1), 2, 2_000_000)
+	if a.WantsMarketData() {
+		t.Fatal("default strategy wants market data")
+	}
+	a.Strategy.JoinWiden = true
+	a.Strategy.PullSweepQty = 6
+	var log []*Decision
+	a.OnDecision = func(d *Decision) { log = append(log, d) }
+
+	tick := func(md *MarketData, at int64) []*domain.Order {
+		md.Published = at
+		return a.OnMarketData(md, at)
+	}
+	if orders := tick(&MarketData{BBO: &domain.BBO{BidPrice: 990, AskPrice: 1010}}, 10); orders != nil {
+		t.Fatalf("first BBO tick sent %v", orders)
+	}
+	// The ask lifts away: only that side is joined
+	joined := tick(&MarketData{BBO: &domain.BBO{BidPrice: 990, AskPrice: 1020}}, 20)
+	if len(joined) != 1 || joined[0].Side != domain.Sell || joined[0].Price != 1020 || joined[0].Type != domain.LimitOrder {
+		t.Fatalf("joined %+v", joined)
+	}
+	a.ActiveOrders[joined[0].ID] = joined[0]
+	// Narrowing again does nothing
+	if orders := tick(&MarketData{BBO: &domain.BBO{BidPrice: 1000, AskPrice: 1020}}, 30); orders != nil {
+		t.Fatalf("narrowing sent %v", orders)
+	}
+
+	// A buyer's prints: 4 then 3 reaches 6 and pulls the ask, once
+	sweep := func(aggressor, qty int64) []*domain.Order {
+		return tick(&MarketData{Trade: &domain.Trade{BuyOrderID: uint64(aggressor), SellOrderID: 1, AggressorOrderID: uint64(aggressor), Qty: qty}}, 40)
+	}
+	if orders := sweep(7, 4); orders != nil {
+		t.Fatalf("pulled under the threshold: %v", orders)
+	}
+	pulled := sweep(7, 3)
+	if len(pulled) != 1 || pulled[0].Type != domain.CancelOrder || pulled[0].CancelID != joined[0].ID {
+		t.Fatalf("pulled %+v", pulled)
+	}
+	if orders := sweep(7, 5); orders != nil {
+		t.Fatalf("pulled twice for one sweep: %v", orders)
+	}
+	// A new aggressor starts a new count
+	if orders := sweep(8, 5); orders != nil {
+		t.Fatalf("counted across sweeps: %v", orders)
+	}
+
+	if len(log) != 2 || log[0].Rules[0] != RuleJoinWiden || log[1].Rules[0] != RulePullSweep || log[1].Trigger != TriggerMarketData {
+		t.Errorf("decisions %+v", log)
+	}
+	if log[0].Book.AskPrice != 1020 {
+		t.Errorf("decision saw %+v, want the tick's BBO", log[0].Book)
+	}
+}
@@ -0,0 +1,49 @@
+package trader
+
+import (
+	"hash/fnv"
+	"maps"
+	"math/rand"
+)
+
+// RNG substreams. Each is its own generator seeded from the agent's seed,
+// so drawing more from one leaves every other's draws unchanged
+const (
+	// StreamSignalNoise draws the noise on the signals the agent sees
+	StreamSignalNoise = "signal_noise"
+	// StreamStrategy is for strategy logic that needs randomness
+	StreamStrategy = "strategy"
+	// StreamHedge draws the jitter on the agent's hedges, opened only in
+	// runs with a hedge leg
+	StreamHedge = "hedge"
+)
+
+// SubstreamSeed derives a substream's seed from an agent seed. Signal
+// noise keeps the agent seed itself, as it had before substreams
+func SubstreamSeed(seed int64, name string) int64 {
+	if name == StreamSignalNoise {
+		return seed
+	}
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return seed ^ int64(h.Sum64())
+}
+
+// Rand returns the agent's substream name, creating it on first use.
+// Strategies draw only from here, never from the global math/rand, whose
+// state is shared and unseeded; TestNoGlobalRand enforces it
+func (a *Agent) Rand(name string) *rand.Rand {
+	if rng, ok := a.streams[name]; ok {
+		return rng
+	}
+	seed := SubstreamSeed(a.seed, name)
+	rng := rand.New(rand.NewSource(seed))
+	a.streams[name] = rng
+	a.seeds[name] = seed
+	return rng
+}
+
+// Seeds returns the seed of every substream the agent has opened, by name
+func (a *Agent) Seeds() map[string]int64 {
+	return maps.Clone(a.seeds)
+}
@@ -0,0 +1,81 @@
+package trader
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+)
+
+// TestSubstreams checks each substream is independent of the others'
+// draws and that Seeds records every one opened
+func TestSubstreams(t *testing.T) {
+	a := NewAgent("fast", latency.NewModel(0, 0, 1), 7, 0)
+	b := NewAgent("fast", latency.NewModel(0, 0, 1), 7, 0)
+
+	for range 100 {
+		a.Rand(StreamStrategy).Int63()
+	}
+	if x, y := a.Rand(StreamSignalNoise).Int63(), b.Rand(StreamSignalNoise).Int63(); x != y {
+		t.Errorf("strategy draws moved the noise stream: %d != %d", x, y)
+	}
+	a.Rand("custom")
+	seeds := a.Seeds()
+	if len(seeds) != 3 || seeds[StreamSignalNoise] != 7 {
+		t.Errorf("seeds = %v", seeds)
+	}
+	if seeds[StreamStrategy] == seeds[StreamSignalNoise] || seeds["custom"] == seeds[StreamStrategy] {
+		t.Errorf("substreams share a seed: %v", seeds)
+	}
+}
+
+// TestNoGlobalRand keeps strategy code off the global math/rand, whose
+// shared state would make runs irreproducible: only constructors and types
+// may be named through the package
+func TestNoGlobalRand(t *testing.T) {
+	allowed := map[string]bool{"New": true, "NewSource": true, "Rand": true, "Source": true}
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		local := ""
+		for _, imp := range f.Imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			if path != "math/rand" && path != "math/rand/v2" {
+				continue
+			}
+			local = "rand"
+			if imp.Name != nil {
+				local = imp.Name.Name
+			}
+		}
+		if local == "" {
+			continue
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if id, ok := sel.X.(*ast.Ident); ok && id.Name == local && !allowed[sel.Sel.Name] {
+				t.Errorf("%s: global %s.%s; draw from Agent.Rand instead", fset.Position(sel.Pos()), local, sel.Sel.Name)
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,311 @@
+// Package testkit unit tests trader strategies without a simulation. A
+// Harness feeds an agent a script of BBO updates, signals, re-quotes,
+// trade prints and fills, stands in for the venue by accepting what the
+// agent sends, and checks the orders each step emits:
+//
+//	h := testkit.New(t, trader.NewStrategy())
+//	h.Book(0, domain.BBO{BidPrice: 990, BidQty: 10, AskPrice: 1010, AskQty: 10})
+//	posted := h.Signal(10, domain.Signal{Value: 0.2})
+//	h.Expect(posted, testkit.Limit(domain.Buy, 990, 5), testkit.Limit(domain.Sell, 1010, 5))
+//	h.Fill(20, posted[0].ID, 5)
+//	h.Expect(h.Signal(30, domain.Signal{Value: 1.5}), testkit.Market(domain.Buy, 5))
+//
+// There is no latency and no matching: orders are accepted at the time
+// they are decided, limit orders rest until the script fills them or a
+// cancel takes them off, and market orders fill only if the script says so
+package testkit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/trader"
+)
+
+// TraderID is the ID of the harness's agent
+const TraderID = "trader"
+
+// Harness drives one agent through a script. Steps must come in time
+// order
+type Harness struct {
+	t     testing.TB
+	Agent *trader.Agent
+
+	// BBO is the book as the script last set it; signals and re-quotes
+	// decide against it
+	BBO domain.BBO
+
+	// Manual, if set, leaves sent orders unaccepted until the script calls
+	// Accept, for testing what the strategy does with orders in flight
+	Manual bool
+
+	// Sent is every order the agent emitted, and Decisions its decision
+	// records, in order
+	Sent      []*domain.Order
+	Decisions []*trader.Decision
+
+	now     int64
+	tradeID uint64
+}
+
+// New returns a harness around an agent running s, with no latency and a
+// fixed seed, so a script always plays out the same way
+func New(t testing.TB, s *trader.Strategy) *Harness {
+	h := &Harness{t: t, Agent: trader.NewAgent(TraderID, latency.NewModel(0, 0, 1), 1, 1_000_000)}
+	h.Agent.Strategy = s
+	h.Agent.OnDecision = func(d *trader.Decision) { h.Decisions = append(h.Decisions, d) }
+	return h
+}
+
+// at moves the clock to a step's time
+func (h *Harness) at(ts int64) {
+	h.t.Helper()
+	if ts < h.now {
+		h.t.Fatalf("step at %d is before the previous one at %d", ts, h.now)
+	}
+	h.now = ts
+}
+
+// Book sets the BBO, filling in the mid, and sends it to the agent as a
+// market-data tick if its strategy has a market-data rule on. It returns
+// the orders the tick emitted
+func (h *Harness) Book(ts int64, bbo domain.BBO) []*domain.Order {
+	h.t.Helper()
+	h.at(ts)
+	if bbo.MidPrice == 0 && bbo.BidPrice > 0 && bbo.AskPrice > 0 {
+		bbo.MidPrice = (bbo.BidPrice + bbo.AskPrice) / 2
+	}
+	h.BBO = bbo
+	if !h.Agent.WantsMarketData() {
+		return nil
+	}
+	quote := bbo
+	return h.sent(ts, h.Agent.OnMarketData(&trader.MarketData{Published: ts, BBO: &quote}, ts))
+}
+
+// Signal delivers s, taking its mid from the BBO if it has none, and
+// returns the orders it emitted
+func (h *Harness) Signal(ts int64, s domain.Signal) []*domain.Order {
+	h.t.Helper()
+	h.at(ts)
+	if s.MidPrice == 0 {
+		s.MidPrice = h.BBO.MidPrice
+	}
+	bbo := h.BBO
+	return h.sent(ts, h.Agent.OnSignal(&s, &bbo, ts))
+}
+
+// ReQuote fires the agent's re-quote timer and returns the orders it
+// emitted
+func (h *Harness) ReQuote(ts int64) []*domain.Order {
+	h.t.Helper()
+	h.at(ts)
+	bbo := h.BBO
+	return h.sent(ts, h.Agent.OnReQuote(&domain.Signal{MidPrice: bbo.MidPrice}, &bbo, ts))
+}
+
+// Print sends an anonymous trade print of qty at price by an aggressor on
+// side, as a market-data tick if the strategy has a market-data rule on.
+// Prints with the same aggressor are one sweep. It returns the orders the
+// tick emitted
+func (h *Harness) Print(ts int64, aggressor uint64, side domain.Side, price, qty int64) []*domain.Order {
+	h.t.Helper()
+	h.at(ts)
+	if !h.Agent.WantsMarketData() {
+		return nil
+	}
+	tr := h.trade(ts, price, qty)
+	tr.AggressorOrderID = aggressor
+	if side == domain.Buy {
+		tr.BuyOrderID = aggressor
+	} else {
+		tr.SellOrderID = aggressor
+	}
+	return h.sent(ts, h.Agent.OnMarketData(&trader.MarketData{Published: ts, Trade: tr}, ts))
+}
+
+// Fill fills qty of the agent's resting order id at its price, as the
+// venue would when an aggressor hits it
+func (h *Harness) Fill(ts int64, id uint64, qty int64) {
+	h.t.Helper()
+	h.at(ts)
+	o, ok := h.Agent.ActiveOrders[id]
+	if !ok {
+		h.t.Fatalf("fill of order %d, which is not resting", id)
+	}
+	if qty <= 0 || qty > o.RemainingQty {
+		h.t.Fatalf("fill of %d on order %d with %d left", qty, id, o.RemainingQty)
+	}
+	o.RemainingQty -= qty
+	tr := h.trade(ts, o.Price, qty)
+	tr.PassiveOrderID = id
+	if o.Side == domain.Buy {
+		tr.BuyOrderID, tr.BuyTrader = id, TraderID
+	} else {
+		tr.SellOrderID, tr.SellTrader = id, TraderID
+	}
+	h.Agent.OnFill(tr, id)
+}
+
+func (h *Harness) trade(ts, price, qty int64) *domain.Trade {
+	h.tradeID++
+	return &domain.Trade{ID: h.tradeID, Price: price, Qty: qty, Timestamp: ts}
+}
+
+// Accept has the venue take orders the agent sent: a limit order rests
+// and a cancel takes all or, for a partial cancel, some of its target
+// off. A cancel of an order no longer resting is refused, getting no ack,
+// as at the venue. Unless Manual is set, every step accepts what it sent
+func (h *Harness) Accept(orders ...*domain.Order) {
+	h.t.Helper()
+	for _, o := range orders {
+		switch o.Type {
+		case domain.LimitOrder:
+			o.RemainingQty = o.Qty
+			h.Agent.ActiveOrders[o.ID] = o
+		case domain.MarketOrder:
+			o.RemainingQty = o.Qty
+		case domain.CancelOrder:
+			target, ok := h.Agent.ActiveOrders[o.CancelID]
+			if !ok || target.RemainingQty <= 0 {
+				continue
+			}
+			if o.Qty > 0 && o.Qty < target.RemainingQty {
+				target.RemainingQty -= o.Qty
+			} else {
+				target.RemainingQty = 0
+			}
+			o.RemainingQty = target.RemainingQty
+			h.Agent.OnCancelAck(o.CancelID)
+		}
+	}
+}
+
+// sent records orders a step emitted and accepts them unless Manual is set
+func (h *Harness) sent(ts int64, orders []*domain.Order) []*domain.Order {
+	h.t.Helper()
+	for _, o := range orders {
+		if o.TraderID != TraderID || o.DecisionTime != ts {
+			h.t.Fatalf("order %d sent as %q decided at %d, in a step at %d", o.ID, o.TraderID, o.DecisionTime, ts)
+		}
+	}
+	h.Sent = append(h.Sent, orders...)
+	if !h.Manual {
+		h.Accept(orders...)
+	}
+	return orders
+}
+
+// Resting returns the agent's resting orders on side, in ID order
+func (h *Harness) Resting(side domain.Side) []*domain.Order {
+	var out []*domain.Order
+	for _, o := range h.Sent {
+		if a, ok := h.Agent.ActiveOrders[o.ID]; ok && a == o && o.Side == side {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// Want describes an expected order. Build one with Limit, Market, Cancel
+// or Reduce
+type Want struct {
+	Type     domain.OrderType
+	Side     domain.Side // 0 matches either, for cancels
+	Price    int64       // limit orders only
+	Qty      int64       // for cancels, what to take off; 0 for all of it
+	CancelID uint64
+}
+
+// Limit expects a limit order of qty at price
+func Limit(side domain.Side, price, qty int64) Want {
+	return Want{Type: domain.LimitOrder, Side: side, Price: price, Qty: qty}
+}
+
+// Market expects a market order of qty
+func Market(side domain.Side, qty int64) Want {
+	return Want{Type: domain.MarketOrder, Side: side, Qty: qty}
+}
+
+// Cancel expects a full cancel of order id
+func Cancel(id uint64) Want {
+	return Want{Type: domain.CancelOrder, CancelID: id}
+}
+
+// Reduce expects a partial cancel taking qty off order id
+func Reduce(id uint64, qty int64) Want {
+	return Want{Type: domain.CancelOrder, CancelID: id, Qty: qty}
+}
+
+// Matches reports whether o is the order w describes
+func (w Want) Matches(o *domain.Order) bool {
+	if o.Type != w.Type || w.Side != 0 && o.Side != w.Side || o.Qty != w.Qty {
+		return false
+	}
+	switch w.Type {
+	case domain.LimitOrder:
+		return o.Price == w.Price
+	case domain.CancelOrder:
+		return o.CancelID == w.CancelID
+	}
+	return true
+}
+
+func (w Want) String() string {
+	switch w.Type {
+	case domain.LimitOrder:
+		return fmt.Sprintf("%s limit %d@%d", w.Side, w.Qty, w.Price)
+	case domain.MarketOrder:
+		return fmt.Sprintf("%s market %d", w.Side, w.Qty)
+	}
+	if w.Qty > 0 {
+		return fmt.Sprintf("reduce %d by %d", w.CancelID, w.Qty)
+	}
+	return fmt.Sprintf("cancel %d", w.CancelID)
+}
+
+// describe formats an order the way Want.String does
+func describe(o *domain.Order) string {
+	return Want{Type: o.Type, Side: o.Side, Price: o.Price, Qty: o.Qty, CancelID: o.CancelID}.String()
+}
+
+// Expect checks orders are exactly those wanted, in order, reporting
+// both lists if not
+func (h *Harness) Expect(orders []*domain.Order, want ...Want) {
+	h.t.Helper()
+	ok := len(orders) == len(want)
+	for i := 0; ok && i < len(want); i++ {
+		ok = want[i].Matches(orders[i])
+	}
+	if ok {
+		return
+	}
+	got := make([]string, len(orders))
+	for i, o := range orders {
+		got[i] = describe(o)
+	}
+	exp := make([]string, len(want))
+	for i, w := range want {
+		exp[i] = w.String()
+	}
+	h.t.Errorf("sent [%s], want [%s]", strings.Join(got, ", "), strings.Join(exp, ", "))
+}
+
+// ExpectNone checks a step emitted nothing
+func (h *Harness) ExpectNone(orders []*domain.Order) {
+	h.t.Helper()
+	h.Expect(orders)
+}
+
+// LastDecision returns the agent's latest decision record, failing the
+// test if it has made none
+func (h *Harness) LastDecision() *trader.Decision {
+	h.t.Helper()
+	if len(h.Decisions) == 0 {
+		h.t.Fatal("no decisions recorded")
+	}
+	return h.Decisions[len(h.Decisions)-1]
+}
@@ -0,0 +1,137 @@
+package testkit
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/trader"
+)
+
+var book = domain.BBO{BidPrice: 990, BidQty: 10, AskPrice: 1010, AskQty: 10}
+
+// TestDefaultStrategyScript walks the default strategy through quoting,
+// a fill, a re-quote, the stale timeout and a cross
+func TestDefaultStrategyScript(t *testing.T) {
+	h := New(t, trader.NewStrategy())
+	h.ExpectNone(h.Signal(0, domain.Signal{Value: 0.2}))
+	if got := h.LastDecision().Rules; len(got) != 1 || got[0] != trader.RuleNoMarket {
+		t.Fatalf("empty book fired %v", got)
+	}
+
+	h.ExpectNone(h.Book(0, book))
+	posted := h.Signal(latency.MsToNs(10), domain.Signal{Value: 0.2})
+	h.Expect(posted, Limit(domain.Buy, 990, 5), Limit(domain.Sell, 1010, 5))
+	h.ExpectNone(h.Signal(latency.MsToNs(20), domain.Signal{Value: -0.3}))
+
+	// The bid fills in two parts; only once it is gone does a re-quote replace it
+	bid, ask := posted[0], posted[1]
+	h.Fill(latency.MsToNs(30), bid.ID, 2)
+	h.ExpectNone(h.ReQuote(latency.MsToNs(100)))
+	h.Fill(latency.MsToNs(110), bid.ID, 3)
+	if len(h.Resting(domain.Buy)) != 0 {
+		t.Fatal("filled bid still resting")
+	}
+	requoted := h.ReQuote(latency.MsToNs(200))
+	h.Expect(requoted, Limit(domain.Buy, 990, 5))
+
+	// Past the timeout the ask is canceled and, the signal strong, a market
+	// order crosses
+	crossed := h.Signal(latency.MsToNs(520), domain.Signal{Value: 1.5})
+	h.Expect(crossed, Cancel(ask.ID), Market(domain.Buy, 5))
+	if len(h.Resting(domain.Sell)) != 0 || len(h.Resting(domain.Buy)) != 1 {
+		t.Fatalf("resting after the cross: %d bids, %d asks", len(h.Resting(domain.Buy)), len(h.Resting(domain.Sell)))
+	}
+	if len(h.Sent) != 5 {
+		t.Fatalf("sent %d orders", len(h.Sent))
+	}
+}
+
+// TestManualAccept holds a partial cancel in flight: the strategy must not
+// send a second one for the same quote until it is acked
+func TestManualAccept(t *testing.T) {
+	s := trader.NewStrategy()
+	s.ReduceThreshold = 0.5
+	s.TargetQty = 8
+	h := New(t, s)
+	h.Book(0, book)
+	posted := h.Signal(10, domain.Signal{Value: 0.1})
+	h.Manual = true
+	reduce := h.Signal(20, domain.Signal{Value: -0.8})
+	h.Expect(reduce, Reduce(posted[0].ID, 4))
+	h.ExpectNone(h.Signal(30, domain.Signal{Value: -0.8}))
+	h.Accept(reduce...)
+	if bid := h.Resting(domain.Buy); len(bid) != 1 || bid[0].RemainingQty != 4 {
+		t.Fatalf("bid after the reduce: %+v", bid)
+	}
+	h.Expect(h.Signal(40, domain.Signal{Value: -0.8}), Reduce(posted[0].ID, 2))
+}
+
+// TestMarketDataScript drives the market-data rules from book updates and
+// prints
+func TestMarketDataScript(t *testing.T) {
+	s := trader.NewStrategy()
+	s.JoinWiden = true
+	s.PullSweepQty = 6
+	h := New(t, s)
+	h.ExpectNone(h.Book(0, book))
+	joined := h.Book(10, domain.BBO{BidPrice: 990, BidQty: 10, AskPrice: 1020, AskQty: 3})
+	h.Expect(joined, Limit(domain.Sell, 1020, 5))
+	h.ExpectNone(h.Print(20, 7, domain.Buy, 1020, 4))
+	h.Expect(h.Print(20, 7, domain.Buy, 1020, 2), Cancel(joined[0].ID))
+	if h.LastDecision().Trigger != trader.TriggerMarketData {
+		t.Errorf("trigger %s", h.LastDecision().Trigger)
+	}
+}
+
+// TestBatchedSignals holds signals back until a batch is full, then
+// decides once on their mean
+func TestBatchedSignals(t *testing.T) {
+	s := trader.NewStrategy()
+	s.BatchSignals = 3
+	h := New(t, s)
+	h.Book(0, book)
+	h.ExpectNone(h.Signal(10, domain.Signal{Value: 0.2}))
+	h.ExpectNone(h.Signal(20, domain.Signal{Value: 0.2}))
+	if len(h.Decisions) != 0 {
+		t.Fatalf("%d decisions before the batch filled", len(h.Decisions))
+	}
+	// The last signal alone would cross; the batch's mean only quotes
+	h.Expect(h.Signal(30, domain.Signal{Value: 1.4}), Limit(domain.Buy, 990, 5), Limit(domain.Sell, 1010, 5))
+	if d := h.LastDecision(); d.Trigger != trader.TriggerBatch || math.Abs(d.Signal-0.6) > 1e-9 {
+		t.Fatalf("decided on %s %v", d.Trigger, d.Signal)
+	}
+	// The next batch starts empty, and re-quotes are not held back
+	h.ExpectNone(h.Signal(40, domain.Signal{Value: 5}))
+	h.ReQuote(latency.MsToNs(100))
+	if got := len(h.Decisions); got != 2 || h.LastDecision().Trigger != trader.TriggerReQuote {
+		t.Fatalf("%d decisions, last on %s", got, h.LastDecision().Trigger)
+	}
+}
+
+// recorder catches a harness's errors
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func TestExpectReportsMismatch(t *testing.T) {
+	r := &recorder{TB: t}
+	h := New(r, trader.NewStrategy())
+	h.Book(0, book)
+	posted := h.Signal(10, domain.Signal{Value: 0.2})
+	h.Expect(posted, Limit(domain.Buy, 990, 5), Limit(domain.Sell, 1000, 5))
+	h.Expect(posted, Limit(domain.Buy, 990, 5))
+	h.Expect(posted, Limit(domain.Buy, 990, 5), Limit(domain.Sell, 1010, 5))
+	if len(r.errs) != 2 || r.errs[0] != "sent [BUY limit 5@990, SELL limit 5@1010], want [BUY limit 5@990, SELL limit 5@1000]" {
+		t.Fatalf("errors %q", r.errs)
+	}
+}
@@ -0,0 +1,256 @@
+// Package traderview cuts a run's event log down to what one trader could
+// observe, when it could observe it: its own orders, acks, state changes,
+// accounts and fills; signals as they reached it; its own re-quote
+// timers; and every BBO update and trade print as an anonymous
+// MARKET_DATA tick, late by its market-data latency. Session boundaries
+// and auction results are public. Other traders' orders and the book's
+// depth are not in it.
+//
+// A strategy decides only when something reaches it, so every decision
+// the trader made should fall at the time of a signal, re-quote or tick
+// in its view. A decision that does not was made on something the view
+// leaves out, which is information the trader should not have had. The
+// view also serves as input for offline strategy research
+package traderview
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/trader"
+)
+
+// Trader is what decides a trader's view: who it is and how information
+// reaches it
+type Trader struct {
+	ID                string
+	MarketDataNs      int64   // how late BBO updates and trade prints reach it
+	SignalDelayNs     int64   // how late signals reach it; 0 for on time
+	NoSignal          bool    // whether it hears signals without their value
+	SignalNoise       float64 // the noise it sees signals through, which the log does not record
+	ReQuoteIntervalNs int64   // its re-quote timer; 0 for none
+	DurationNs        int64   // the run's length, which bounds the timers
+}
+
+// FromConfig is the view of tc's trader in a run of cfg
+func FromConfig(cfg *scenario.Config, tc scenario.TraderConfig) Trader {
+	interval := trader.NewStrategy().ReQuoteIntervalNs
+	if tc.Strategy.ReQuoteIntervalNs > 0 {
+		interval = tc.Strategy.ReQuoteIntervalNs
+	}
+	return Trader{ID: tc.ID, MarketDataNs: tc.MarketDataLatencyNs(), SignalDelayNs: tc.SignalDelayNs,
+		NoSignal: tc.NoSignal, SignalNoise: tc.SignalNoise, ReQuoteIntervalNs: interval, DurationNs: cfg.Duration}
+}
+
+// Summary describes a written view
+type Summary struct {
+	Trader     string
+	Events     int // events written
+	Orders     int // the trader's accepted orders and cancels
+	Fills      int
+	Signals    int
+	ReQuotes   int
+	MarketData int // ticks
+
+	// Unexplained are the trader's orders decided at a time its view
+	// shows nothing reaching it, in log order
+	Unexplained []Unexplained
+}
+
+// Unexplained is an order decided with no trigger in the trader's view
+type Unexplained struct {
+	OrderID      uint64
+	DecisionTime int64
+}
+
+// Write writes t's view of the log at src to dst. The log must be at
+// LevelFull, since signals, states and accounts are part of the view
+func Write(src, dst string, t Trader) (*Summary, error) {
+	r, err := eventlog.NewReader(src)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if err := r.Require(eventlog.LevelFull, "trader views"); err != nil {
+		return nil, err
+	}
+	w, err := eventlog.NewWriter(dst)
+	if err != nil {
+		return nil, err
+	}
+	v := newView(t, w.Write)
+	for line := 1; ; line++ {
+		e, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = v.process(e)
+		}
+		if err != nil {
+			w.Close()
+			return nil, fmt.Errorf("view line %d: %w", line, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return &v.sum, nil
+}
+
+// view filters one trader's events, holding back ticks and timers until
+// the log reaches their time
+type view struct {
+	t       Trader
+	emit    func(*domain.Event) error
+	pending []*domain.Event // ticks in time order, the latency being fixed
+	next    int64           // the next re-quote timer; 0 once they run out
+	seen    map[int64]bool  // times something reached the trader
+	sum     Summary
+}
+
+func newView(t Trader, emit func(*domain.Event) error) *view {
+	v := &view{t: t, emit: emit, seen: make(map[int64]bool), sum: Summary{Trader: t.ID}}
+	if t.ReQuoteIntervalNs > 0 && t.ReQuoteIntervalNs < t.DurationNs {
+		v.next = t.ReQuoteIntervalNs
+	}
+	return v
+}
+
+// process takes the next log event, writing whatever of it, and of what
+// was held back until its time, the trader could see
+func (v *view) process(e *domain.Event) error {
+	if err := v.flush(e.Timestamp, e.Type == domain.EventSimEnd); err != nil {
+		return err
+	}
+	switch e.Type {
+	case domain.EventSimStart:
+		e.Annotate("view_trader", v.t.ID)
+		e.Annotate("view_market_data_ns", fmt.Sprint(v.t.MarketDataNs))
+		return v.write(e)
+	case domain.EventSimEnd, domain.EventSession, domain.EventAuction:
+		return v.write(e)
+	case domain.EventOrderAccepted, domain.EventOrderCanceled:
+		o := e.Order()
+		if o == nil || o.TraderID != v.t.ID {
+			return nil
+		}
+		if e.Type == domain.EventOrderAccepted {
+			v.sum.Orders++
+			if !v.seen[o.DecisionTime] {
+				v.sum.Unexplained = append(v.sum.Unexplained, Unexplained{OrderID: o.ID, DecisionTime: o.DecisionTime})
+			}
+		}
+		return v.write(e)
+	case domain.EventOrderState:
+		if c := e.StateChange(); c != nil && c.TraderID == v.t.ID {
+			return v.write(e)
+		}
+	case domain.EventAccountUpdate:
+		if a := e.Account(); a != nil && a.TraderID == v.t.ID {
+			return v.write(e)
+		}
+	case domain.EventSignal:
+		return v.signal(e)
+	case domain.EventTradeExecuted:
+		tr := e.Trade()
+		if tr == nil {
+			return nil
+		}
+		// A fill reaches its trader as it happens, without the
+		// counterparty's name
+		if tr.BuyTrader == v.t.ID || tr.SellTrader == v.t.ID {
+			fill := *tr
+			if fill.BuyTrader != v.t.ID {
+				fill.BuyTrader = ""
+			}
+			if fill.SellTrader != v.t.ID {
+				fill.SellTrader = ""
+			}
+			e.Payload = &fill
+			v.sum.Fills++
+			if err := v.write(e); err != nil {
+				return err
+			}
+		}
+		tick := *tr
+		tick.BuyTrader, tick.SellTrader = "", ""
+		v.hold(domain.NewMarketDataEvent(e.Timestamp+v.t.MarketDataNs, v.t.ID, &tick))
+	case domain.EventBBOUpdate:
+		if bbo := e.BBO(); bbo != nil {
+			quote := *bbo
+			v.hold(domain.NewMarketDataEvent(e.Timestamp+v.t.MarketDataNs, v.t.ID, &quote))
+		}
+	}
+	return nil
+}
+
+// signal passes on a signal as it reached the trader: a public one on
+// time unless its signals are delayed, when the delayed copy logged for
+// it stands in, and without its value if it has no signal access
+func (v *view) signal(e *domain.Event) error {
+	switch {
+	case e.TraderID == v.t.ID:
+	case e.TraderID != "" || v.t.SignalDelayNs > 0 && !v.t.NoSignal:
+		return nil
+	}
+	e.TraderID = v.t.ID
+	if v.t.NoSignal {
+		e.Signal().Value = 0
+	}
+	if v.t.SignalNoise > 0 {
+		e.Annotate("signal_noise", fmt.Sprint(v.t.SignalNoise))
+	}
+	v.sum.Signals++
+	v.seen[e.Timestamp] = true
+	return v.write(e)
+}
+
+// hold keeps a tick until the log reaches its time
+func (v *view) hold(e domain.Event) {
+	v.pending = append(v.pending, &e)
+}
+
+// flush writes the ticks and re-quote timers due by ts; at the end of the
+// run, only those strictly before it, as the run stops there
+func (v *view) flush(ts int64, end bool) error {
+	due := func(at int64) bool { return at < ts || !end && at == ts }
+	for {
+		var tick *domain.Event
+		if len(v.pending) > 0 {
+			tick = v.pending[0]
+		}
+		switch {
+		case v.next > 0 && due(v.next) && (tick == nil || v.next <= tick.Timestamp):
+			e := domain.NewReQuoteEvent(v.next, v.t.ID)
+			v.sum.ReQuotes++
+			v.seen[v.next] = true
+			if v.next += v.t.ReQuoteIntervalNs; v.next >= v.t.DurationNs {
+				v.next = 0
+			}
+			if err := v.write(&e); err != nil {
+				return err
+			}
+		case tick != nil && due(tick.Timestamp):
+			v.pending = v.pending[1:]
+			v.sum.MarketData++
+			v.seen[tick.Timestamp] = true
+			if err := v.write(tick); err != nil {
+				return err
+			}
+		default:
+			if end {
+				v.pending = nil
+			}
+			return nil
+		}
+	}
+}
+
+func (v *view) write(e *domain.Event) error {
+	v.sum.Events++
+	return v.emit(e)
+}
@@ -0,0 +1,80 @@
+package traderview
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
+)
+
+// TestViewsExplainDecisions writes both traders' views of a run, one with
+// delayed signals, and checks each passes the schema, holds nothing of the
+// other trader's, and explains every decision. A view missing the
+// trader's re-quote timer must not
+func TestViewsExplainDecisions(t *testing.T) {
+	cfg := scenario.DefaultCalm(42)
+	cfg.Duration = latency.MsToNs(1_000)
+	cfg.SlowTrader.SignalDelayNs = latency.MsToNs(3)
+	dir := t.TempDir()
+	r, err := sim.NewRunner(cfg, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []scenario.TraderConfig{cfg.FastTrader, cfg.SlowTrader} {
+		out := filepath.Join(dir, "view_"+tc.ID+".jsonl")
+		sum, err := Write(result.LogPath, out, FromConfig(cfg, tc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum.Orders == 0 || sum.Signals == 0 || sum.MarketData == 0 || sum.ReQuotes == 0 {
+			t.Fatalf("%s: summary = %+v", tc.ID, sum)
+		}
+		if len(sum.Unexplained) > 0 {
+			t.Fatalf("%s: unexplained decisions %+v", tc.ID, sum.Unexplained)
+		}
+
+		rd, err := eventlog.NewReader(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, err := rd.ReadAll()
+		rd.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := eventlog.NewValidator()
+		for _, e := range events {
+			if err := v.Check(e); err != nil {
+				t.Fatalf("%s: %v", tc.ID, err)
+			}
+			if e.TraderID != "" && e.TraderID != tc.ID {
+				t.Fatalf("%s: view holds %s event for %s", tc.ID, e.Type, e.TraderID)
+			}
+			if o := e.Order(); o != nil && o.TraderID != tc.ID {
+				t.Fatalf("%s: view holds order %d of %s", tc.ID, o.ID, o.TraderID)
+			}
+			if tr := e.Trade(); tr != nil && e.Type == domain.EventTradeExecuted && tr.BuyTrader != tc.ID && tr.SellTrader != tc.ID {
+				t.Fatalf("%s: view holds a trade it was not in", tc.ID)
+			}
+		}
+	}
+
+	wrong := FromConfig(cfg, cfg.SlowTrader)
+	wrong.ReQuoteIntervalNs = 0
+	sum, err := Write(result.LogPath, filepath.Join(dir, "wrong.jsonl"), wrong)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sum.Unexplained) == 0 {
+		t.Fatal("a view without re-quotes explained every decision")
+	}
+}
@@ -0,0 +1,316 @@
+package venue
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+func init() {
+	Register("pro_rata", "split same-price fills by resting size", func(p *Params) (Mechanism, error) {
+		return ProRata{}, nil
+	})
+	Register("speed_bump", "delay=<d>: hold liquidity-taking orders", func(p *Params) (Mechanism, error) {
+		d, err := p.Duration("delay")
+		return SpeedBump{DelayNs: d}, err
+	})
+	Register("min_resting", "time=<d>: hold cancels until the order has rested", func(p *Params) (Mechanism, error) {
+		d, err := p.Duration("time")
+		return MinResting{Ns: d}, err
+	})
+	Register("batch_auction", "interval=<d>: cross orders in uniform-price batches", func(p *Params) (Mechanism, error) {
+		d, err := p.Duration("interval")
+		return &BatchAuction{IntervalNs: d}, err
+	})
+	Register("maker_taker", "maker=<price>,taker=<price>: per-share fees, negative for rebates", func(p *Params) (Mechanism, error) {
+		maker, err := p.Price("maker")
+		if err != nil {
+			return nil, err
+		}
+		taker, err := p.Price("taker")
+		return MakerTaker{Maker: maker, Taker: taker, Scale: p.scale}, err
+	})
+	Register("throttle", "max=<n>,window=<d>: queue a trader's messages beyond max per window", func(p *Params) (Mechanism, error) {
+		max, err := p.Int("max")
+		if err != nil {
+			return nil, err
+		}
+		window, err := p.Duration("window")
+		return &Throttle{Max: max, WindowNs: window}, err
+	})
+}
+
+// ProRata splits a fill smaller than a price level across its orders by
+// resting size, the remainder by time
+type ProRata struct{}
+
+func (ProRata) Name() string   { return "pro_rata" }
+func (ProRata) String() string { return "pro-rata" }
+func (ProRata) matching()      {}
+
+func (ProRata) Configure(v *Venue) error {
+	v.Book.ProRata = true
+	return nil
+}
+
+// SpeedBump holds orders that would take liquidity on arrival for DelayNs
+// before they reach the book; cancels and orders that would rest pass
+// straight through, so makers can pull stale quotes first
+type SpeedBump struct {
+	DelayNs int64
+}
+
+func (SpeedBump) Name() string     { return "speed_bump" }
+func (s SpeedBump) String() string { return "speed bump " + latency.Format(s.DelayNs) }
+
+func (s SpeedBump) Admit(v *Venue, e *domain.Event) (bool, []*domain.Event) {
+	order := e.Order()
+	if _, bumped := e.Annotation("speed_bump_applied"); bumped || order.Type == domain.CancelOrder || !v.TakesLiquidity(order) {
+		return false, nil
+	}
+	e.Annotate("speed_bump_applied", "true")
+	return v.Hold(e, e.Timestamp+s.DelayNs)
+}
+
+// MinResting holds a cancel until its target has rested Ns
+type MinResting struct {
+	Ns int64
+}
+
+func (MinResting) Name() string     { return "min_resting" }
+func (m MinResting) String() string { return "min resting " + latency.Format(m.Ns) }
+
+func (m MinResting) Admit(v *Venue, e *domain.Event) (bool, []*domain.Event) {
+	order := e.Order()
+	if order.Type != domain.CancelOrder {
+		return false, nil
+	}
+	since, resting := v.Book.RestingSince(order.CancelID)
+	if !resting || e.Timestamp >= since+m.Ns {
+		return false, nil
+	}
+	until := since + m.Ns
+	e.Annotate("min_resting_wait_ns", strconv.FormatInt(until-e.Timestamp, 10))
+	return v.Hold(e, until)
+}
+
+// BatchAuction replaces continuous matching with frequent batch auctions:
+// messages are collected for each interval and cross at one uniform price
+// at its end, with no time priority inside a batch
+type BatchAuction struct {
+	IntervalNs int64
+
+	batch    []*domain.Event
+	auctions int
+	rng      *rand.Rand
+}
+
+func (*BatchAuction) Name() string     { return "batch_auction" }
+func (b *BatchAuction) String() string { return "batch auction every " + latency.Format(b.IntervalNs) }
+func (*BatchAuction) matching()        {}
+
+func (b *BatchAuction) Configure(v *Venue) error {
+	b.rng = rand.New(rand.NewSource(v.Seed + 5))
+	return nil
+}
+
+func (b *BatchAuction) Admit(v *Venue, e *domain.Event) (bool, []*domain.Event) {
+	if e.Timestamp >= v.CloseNs {
+		return true, nil
+	}
+	v.Keep(e)
+	b.batch = append(b.batch, e)
+	if len(b.batch) > 1 {
+		return true, nil
+	}
+	// The close flushes the last batch before SIM_END
+	end := (e.Timestamp/b.IntervalNs + 1) * b.IntervalNs
+	if end >= v.CloseNs {
+		return true, nil
+	}
+	return true, []*domain.Event{domain.PooledEvent(domain.NewAuctionEvent(end))}
+}
+
+// Wake runs the auction for the messages collected since the last one.
+// Cancels go first, in arrival order; the orders follow in a random
+// order, so arriving earlier inside a batch earns no priority
+func (b *BatchAuction) Wake(v *Venue, ts int64) {
+	batch := b.batch
+	b.batch = nil
+	if len(batch) == 0 {
+		return
+	}
+	b.auctions++
+	cross(v, batch, ts, func(orders []*domain.Order, marker *domain.Event) {
+		b.rng.Shuffle(len(orders), func(i, j int) { orders[i], orders[j] = orders[j], orders[i] })
+		marker.Annotate("auction_id", strconv.Itoa(b.auctions))
+	})
+}
+
+// cross runs one uniform-price auction at ts for the messages a mechanism
+// kept. Cancels are matched first, in arrival order; arrange then puts the
+// orders in priority order and annotates the AUCTION record. The batch's
+// events are logged after that record, as derived events (seq_no 0) at
+// the auction's time; the order's arrival_time keeps when it arrived
+func cross(v *Venue, batch []*domain.Event, ts int64, arrange func(orders []*domain.Order, marker *domain.Event)) {
+	var orders []*domain.Order
+	events := make(map[*domain.Order]*domain.Event, len(batch))
+	for _, e := range batch {
+		v.Let(e)
+		e.Timestamp, e.SeqNo = ts, 0
+		if e.Cancel() != nil {
+			v.Host.Match(e)
+			continue
+		}
+		orders = append(orders, e.Order())
+		events[e.Order()] = e
+	}
+	marker := domain.PooledEvent(domain.NewAuctionEvent(ts))
+	arrange(orders, marker)
+
+	seq := v.Book.BeginAuction(orders, ts)
+	marker.Annotate("orders", strconv.Itoa(len(orders)))
+	if p := v.Book.AuctionPrice(); p != 0 {
+		marker.Annotate("clearing_price", v.Scale.Format(p))
+	}
+	v.Host.Record(marker)
+
+	for _, o := range seq {
+		v.Host.Match(events[o])
+	}
+
+	bbo, unfilled := v.Book.EndAuction()
+	v.Book.AssertTopOfBook()
+	for _, o := range unfilled {
+		v.Host.Kill(ts, o)
+	}
+	v.Host.Quote(ts, bbo)
+}
+
+// SessionAuction collects the messages that arrive during a session's
+// open or close auction and crosses them at one price when the phase
+// ends. Unlike a batch auction, earlier arrivals keep time priority.
+// Build adds it when the config's sessions have auction phases
+type SessionAuction struct {
+	Sessions []scenario.SessionSpan
+
+	batch    []*domain.Event
+	auctions int
+}
+
+func (*SessionAuction) Name() string   { return "session_auction" }
+func (*SessionAuction) String() string { return "session open/close auctions" }
+func (*SessionAuction) matching()      {}
+
+func (a *SessionAuction) Admit(v *Venue, e *domain.Event) (bool, []*domain.Event) {
+	span := a.span(e.Timestamp)
+	if span == nil || span.Phase(e.Timestamp) == domain.PhaseContinuous {
+		return false, nil
+	}
+	v.Keep(e)
+	a.batch = append(a.batch, e)
+	return true, nil
+}
+
+// Wake crosses the messages collected in the phase that ends at ts. The
+// runner wakes the venue at every session boundary
+func (a *SessionAuction) Wake(v *Venue, ts int64) {
+	batch := a.batch
+	a.batch = nil
+	if len(batch) == 0 {
+		return
+	}
+	a.auctions++
+	// The phase ending at ts is the one the last message arrived in
+	last := batch[len(batch)-1].Timestamp
+	span := a.span(last)
+	cross(v, batch, ts, func(_ []*domain.Order, marker *domain.Event) {
+		marker.Annotate("auction_id", strconv.Itoa(a.auctions))
+		marker.Annotate("session", span.Name)
+		if span.Phase(last) == domain.PhaseOpenAuction {
+			marker.Annotate("auction", "open")
+		} else {
+			marker.Annotate("auction", "close")
+		}
+	})
+}
+
+// span returns the session in force at t, or nil after the last
+func (a *SessionAuction) span(t int64) *scenario.SessionSpan {
+	i := sort.Search(len(a.Sessions), func(i int) bool { return a.Sessions[i].End > t })
+	if i == len(a.Sessions) {
+		return nil
+	}
+	return &a.Sessions[i]
+}
+
+// MakerTaker charges per-share fees in price units to the passive (maker)
+// and aggressing (taker) side of each fill; negative fees are rebates
+type MakerTaker struct {
+	Maker, Taker int64
+	Scale        domain.Scale // for String
+}
+
+func (MakerTaker) Name() string { return "maker_taker" }
+func (f MakerTaker) String() string {
+	return fmt.Sprintf("maker %s / taker %s", f.Scale.Format(f.Maker), f.Scale.Format(f.Taker))
+}
+
+func (f MakerTaker) Configure(v *Venue) error {
+	v.MakerFee, v.TakerFee = f.Maker, f.Taker
+	return nil
+}
+
+// Throttle caps each trader at Max messages per window of WindowNs; a
+// message over the cap waits for the next window. The background flow
+// stands for many participants and is not throttled
+type Throttle struct {
+	Max      int
+	WindowNs int64
+
+	window map[string]int64 // trader -> window index counted
+	sent   map[string]int   // trader -> messages in that window
+	passed map[uint64]bool  // orders already counted
+}
+
+func (*Throttle) Name() string { return "throttle" }
+func (t *Throttle) String() string {
+	return fmt.Sprintf("throttle %d per %s", t.Max, latency.Format(t.WindowNs))
+}
+
+func (t *Throttle) Configure(v *Venue) error {
+	if t.Max <= 0 || t.WindowNs <= 0 {
+		return fmt.Errorf("max and window must be positive")
+	}
+	t.window = make(map[string]int64)
+	t.sent = make(map[string]int)
+	t.passed = make(map[uint64]bool)
+	return nil
+}
+
+func (t *Throttle) Admit(v *Venue, e *domain.Event) (bool, []*domain.Event) {
+	order := e.Order()
+	if domain.IsBackground(order.TraderID) || t.passed[order.ID] {
+		return false, nil
+	}
+	w := e.Timestamp / t.WindowNs
+	if t.window[order.TraderID] != w {
+		t.window[order.TraderID], t.sent[order.TraderID] = w, 0
+	}
+	if t.sent[order.TraderID] < t.Max {
+		t.sent[order.TraderID]++
+		t.passed[order.ID] = true
+		return false, nil
+	}
+	until := (w + 1) * t.WindowNs
+	// A message can wait out several windows; the annotation keeps the total
+	waited, _ := e.Annotation("throttle_wait_ns")
+	prior, _ := strconv.ParseInt(waited, 10, 64)
+	e.Annotate("throttle_wait_ns", strconv.FormatInt(prior+until-e.Timestamp, 10))
+	return v.Hold(e, until)
+}
@@ -0,0 +1,85 @@
+package venue
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+// Params are a configured mechanism's parameters. Each getter requires its
+// key; Build rejects keys no getter asked for, so a typo is an error
+type Params struct {
+	values map[string]string
+	used   map[string]bool
+	scale  domain.Scale
+}
+
+func (p *Params) get(key string) (string, error) {
+	p.used[key] = true
+	v, ok := p.values[key]
+	if !ok || v == "" {
+		return "", fmt.Errorf("%s is required", key)
+	}
+	return v, nil
+}
+
+// Duration reads a positive duration, e.g. "10ms" or a count of nanoseconds
+func (p *Params) Duration(key string) (int64, error) {
+	v, err := p.get(key)
+	if err != nil {
+		return 0, err
+	}
+	ns, err := scenario.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	if ns <= 0 {
+		return 0, fmt.Errorf("%s must be positive", key)
+	}
+	return ns, nil
+}
+
+// Price reads a decimal price in the run's scale, e.g. "-0.002"
+func (p *Params) Price(key string) (int64, error) {
+	v, err := p.get(key)
+	if err != nil {
+		return 0, err
+	}
+	price, err := p.scale.Parse(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return price, nil
+}
+
+// Int reads an integer
+func (p *Params) Int(key string) (int, error) {
+	v, err := p.get(key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer, got %q", key, v)
+	}
+	return n, nil
+}
+
+// unused reports parameters the mechanism did not read
+func (p *Params) unused() error {
+	var extra []string
+	for k := range p.values {
+		if !p.used[k] {
+			extra = append(extra, k)
+		}
+	}
+	if len(extra) == 0 {
+		return nil
+	}
+	sort.Strings(extra)
+	return fmt.Errorf("unknown parameter %s", strings.Join(extra, ", "))
+}
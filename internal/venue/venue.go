@@ -0,0 +1,303 @@
+// Package venue puts the exchange's market rules in front of the order
+// book. Each rule is a Mechanism: a priority policy or fee schedule that
+// configures the venue before the run, an entry rule that holds messages
+// back, or a matching mode that collects them and crosses them later.
+// Mechanisms compose, so a speed bump and maker-taker fees are two entries
+// in the scenario's venue config rather than a special case in the runner
+package venue
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/orderbook"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+// Mechanism is one venue rule. Besides naming itself it implements any
+// of Configurer, Admitter and Waker, and the venue calls each at its point
+// in the run
+type Mechanism interface {
+	// Name is the mechanism's registered type, e.g. "speed_bump"
+	Name() string
+	// String describes it for reports, e.g. "speed bump 10 ms"
+	String() string
+}
+
+// Configurer sets the venue up before the first event: the book's
+// priority rule, the fee schedule, its own state
+type Configurer interface {
+	Configure(v *Venue) error
+}
+
+// Admitter sees each message as it arrives at the venue, in mechanism
+// order. Returning held keeps the message from the book and from the
+// mechanisms after this one; release are events to schedule, usually the
+// message itself rescheduled through Venue.Hold
+type Admitter interface {
+	Admit(v *Venue, e *domain.Event) (held bool, release []*domain.Event)
+}
+
+// Waker acts at times of its own choosing: the venue wakes it at each
+// AUCTION timer event, at each SESSION boundary and once more at the close
+type Waker interface {
+	Wake(v *Venue, ts int64)
+}
+
+// Host carries out what mechanisms decide. The runner implements it
+type Host interface {
+	// Match sends a message to the book now, logs it and its results,
+	// and recycles it
+	Match(e *domain.Event)
+	// Record logs a venue record, such as an auction marker, and recycles it
+	Record(e *domain.Event)
+	// Quote publishes a new top of book at ts
+	Quote(ts int64, bbo *domain.BBO)
+	// Kill cancels, at ts, what an IOC or FOK order left unfilled
+	Kill(ts int64, o *domain.Order)
+}
+
+// Venue is an order book behind a stack of mechanisms
+type Venue struct {
+	Book *orderbook.Book
+	// Host is set by the runner before the first event
+	Host Host
+
+	// CloseNs is the close; a message held until then never reaches the book
+	CloseNs int64
+	Scale   domain.Scale
+	// Seed is the run's seed, for mechanisms that need randomness
+	Seed int64
+
+	// Per-share fees in price units, charged to the passive and aggressing
+	// side of each fill; set by a fee mechanism
+	MakerFee, TakerFee int64
+
+	mechs []Mechanism
+	// held are events a mechanism has kept from the book; the loop must
+	// not recycle them
+	held map[*domain.Event]bool
+}
+
+// New builds cfg's venue around a fresh book
+func New(cfg *scenario.Config) (*Venue, error) {
+	mechs, err := Build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	v := &Venue{
+		Book:    orderbook.New(),
+		CloseNs: cfg.Duration,
+		Scale:   cfg.Scale(),
+		Seed:    cfg.Seed,
+		mechs:   mechs,
+		held:    make(map[*domain.Event]bool),
+	}
+	for _, m := range mechs {
+		if c, ok := m.(Configurer); ok {
+			if err := c.Configure(v); err != nil {
+				return nil, fmt.Errorf("venue %s: %w", m.Name(), err)
+			}
+		}
+	}
+	return v, nil
+}
+
+// Mechanisms returns the venue's mechanisms in the order they apply
+func (v *Venue) Mechanisms() []Mechanism {
+	return v.mechs
+}
+
+// Admit passes a message arriving at the venue through its admitters. It
+// returns true if one holds the message back, along with the events that
+// will release it
+func (v *Venue) Admit(e *domain.Event) (bool, []*domain.Event) {
+	delete(v.held, e)
+	for _, m := range v.mechs {
+		a, ok := m.(Admitter)
+		if !ok {
+			continue
+		}
+		if held, release := a.Admit(v, e); held {
+			return true, release
+		}
+	}
+	return false, nil
+}
+
+// Wake wakes every Waker at ts
+func (v *Venue) Wake(ts int64) {
+	for _, m := range v.mechs {
+		if w, ok := m.(Waker); ok {
+			w.Wake(v, ts)
+		}
+	}
+}
+
+// Held reports whether a mechanism holds e
+func (v *Venue) Held(e *domain.Event) bool {
+	return v.held[e]
+}
+
+// Hold reschedules e to arrive again at until, or drops it if that is at
+// or after the close. It is what an Admitter returns to delay a message
+func (v *Venue) Hold(e *domain.Event, until int64) (bool, []*domain.Event) {
+	if until >= v.CloseNs {
+		return true, nil
+	}
+	e.Timestamp = until
+	v.held[e] = true
+	return true, []*domain.Event{e}
+}
+
+// Keep marks e as held by a mechanism that will hand it to the host itself
+func (v *Venue) Keep(e *domain.Event) {
+	v.held[e] = true
+}
+
+// Let undoes Keep before the mechanism hands e on
+func (v *Venue) Let(e *domain.Event) {
+	delete(v.held, e)
+}
+
+// TakesLiquidity reports whether an order would trade on arrival
+func (v *Venue) TakesLiquidity(o *domain.Order) bool {
+	if o.Type == domain.MarketOrder {
+		return true
+	}
+	bbo := v.Book.BBO()
+	if o.Side == domain.Buy {
+		return bbo.AskPrice > 0 && o.Price >= bbo.AskPrice
+	}
+	return bbo.BidPrice > 0 && o.Price <= bbo.BidPrice
+}
+
+// Factory builds a mechanism from its configured parameters
+type Factory func(p *Params) (Mechanism, error)
+
+// registry maps a mechanism type to its factory and a one-line summary
+var registry = map[string]struct {
+	build Factory
+	doc   string
+}{}
+
+// Register makes a mechanism type available to venue configs. It panics
+// on a duplicate, as that is a programming error
+func Register(name, doc string, build Factory) {
+	if _, dup := registry[name]; dup {
+		panic("venue: mechanism " + name + " registered twice")
+	}
+	registry[name] = struct {
+		build Factory
+		doc   string
+	}{build, doc}
+}
+
+// Type is a registered mechanism type and its parameter summary
+type Type struct {
+	Name, Doc string
+}
+
+// Types lists the registered mechanism types, sorted by name
+func Types() []Type {
+	out := make([]Type, 0, len(registry))
+	for name, r := range registry {
+		out = append(out, Type{name, r.doc})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Build turns cfg's venue config into mechanisms: the venue shorthands
+// first (priority, speed bump, min resting, batch auction), the sessions'
+// auctions, then the listed mechanisms, then the top-level fees. A type
+// may appear once
+func Build(cfg *scenario.Config) ([]Mechanism, error) {
+	vc := cfg.Venue
+	if err := vc.Validate(); err != nil {
+		return nil, err
+	}
+	var mechs []Mechanism
+	if vc.Matching == scenario.MatchProRata {
+		mechs = append(mechs, ProRata{})
+	}
+	if vc.SpeedBumpNs > 0 {
+		mechs = append(mechs, SpeedBump{DelayNs: vc.SpeedBumpNs})
+	}
+	if vc.MinRestingNs > 0 {
+		mechs = append(mechs, MinResting{Ns: vc.MinRestingNs})
+	}
+	if vc.BatchIntervalNs > 0 {
+		mechs = append(mechs, &BatchAuction{IntervalNs: vc.BatchIntervalNs})
+	}
+	if cfg.HasAuctions() {
+		mechs = append(mechs, &SessionAuction{Sessions: cfg.SessionPlan()})
+	}
+	for _, spec := range vc.Mechanisms {
+		r, ok := registry[spec.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown venue mechanism %q (%s)", spec.Type, strings.Join(typeNames(), ", "))
+		}
+		p := &Params{values: spec.Params, used: make(map[string]bool), scale: cfg.Scale()}
+		m, err := r.build(p)
+		if err == nil {
+			err = p.unused()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("venue mechanism %s: %w", spec.Type, err)
+		}
+		mechs = append(mechs, m)
+	}
+	if cfg.MakerFee != 0 || cfg.TakerFee != 0 {
+		mechs = append(mechs, MakerTaker{Maker: cfg.MakerFee, Taker: cfg.TakerFee, Scale: cfg.Scale()})
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range mechs {
+		if seen[m.Name()] {
+			return nil, fmt.Errorf("venue mechanism %s configured twice", m.Name())
+		}
+		seen[m.Name()] = true
+	}
+	// Both would cross the same held messages
+	if seen["batch_auction"] && seen["session_auction"] {
+		return nil, fmt.Errorf("batch auctions cannot run alongside session auctions")
+	}
+	return mechs, nil
+}
+
+func typeNames() []string {
+	var names []string
+	for _, t := range Types() {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// Describe summarizes cfg's venue, e.g. "pro-rata, speed bump 10 ms".
+// A venue with no matching mechanism is described as continuous FIFO
+func Describe(cfg *scenario.Config) string {
+	mechs, err := Build(cfg)
+	if err != nil {
+		return "invalid venue: " + err.Error()
+	}
+	var parts []string
+	matching := false
+	for _, m := range mechs {
+		if _, ok := m.(matcher); ok {
+			matching = true
+		}
+		parts = append(parts, m.String())
+	}
+	if !matching {
+		parts = append([]string{"continuous FIFO"}, parts...)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// matcher marks mechanisms that replace or modify price-time matching
+type matcher interface {
+	matching()
+}
@@ -0,0 +1,138 @@
+package venue
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/domain"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/latency"
+	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
+)
+
+func spec(t *testing.T, s string) scenario.MechanismSpec {
+	t.Helper()
+	m, err := scenario.ParseMechanismSpec(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// TestBuildComposes checks shorthands and listed mechanisms stack in
+// order and that a fee mechanism sets the venue's fees
+func TestBuildComposes(t *testing.T) {
+	cfg := scenario.DefaultCalm(1)
+	cfg.Venue.SpeedBumpNs = latency.MsToNs(10)
+	cfg.Venue.Mechanisms = []scenario.MechanismSpec{spec(t, "maker_taker:maker=-0.002,taker=0.003")}
+	v, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range v.Mechanisms() {
+		names = append(names, m.Name())
+	}
+	if got := strings.Join(names, " "); got != "speed_bump maker_taker" {
+		t.Errorf("mechanisms = %s", got)
+	}
+	if v.MakerFee != -20 || v.TakerFee != 30 {
+		t.Errorf("fees = %d/%d, want -20/30", v.MakerFee, v.TakerFee)
+	}
+	if got := Describe(cfg); got != "continuous FIFO, speed bump 10 ms, maker -0.0020 / taker 0.0030" {
+		t.Errorf("Describe = %q", got)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	for _, tc := range []struct {
+		venue scenario.Venue
+		want  string
+	}{
+		{scenario.Venue{}, "continuous FIFO"},
+		{scenario.Venue{Matching: scenario.MatchFIFO}, "continuous FIFO"},
+		{scenario.Venue{Matching: scenario.MatchProRata, SpeedBumpNs: latency.MsToNs(5), MinRestingNs: 1000},
+			"pro-rata, speed bump 5 ms, min resting 1 µs"},
+		{scenario.Venue{BatchIntervalNs: latency.MsToNs(50)}, "batch auction every 50 ms"},
+	} {
+		cfg := scenario.DefaultCalm(1)
+		cfg.Venue = tc.venue
+		if got := Describe(cfg); got != tc.want {
+			t.Errorf("%+v: Describe = %q, want %q", tc.venue, got, tc.want)
+		}
+	}
+}
+
+func TestBuildRejects(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(*scenario.Config)
+	}{
+		{"unknown type", func(c *scenario.Config) {
+			c.Venue.Mechanisms = []scenario.MechanismSpec{spec(t, "lottery")}
+		}},
+		{"unknown parameter", func(c *scenario.Config) {
+			c.Venue.Mechanisms = []scenario.MechanismSpec{spec(t, "speed_bump:delay=1ms,dealy=2ms")}
+		}},
+		{"missing parameter", func(c *scenario.Config) {
+			c.Venue.Mechanisms = []scenario.MechanismSpec{spec(t, "batch_auction")}
+		}},
+		{"zero duration", func(c *scenario.Config) {
+			c.Venue.Mechanisms = []scenario.MechanismSpec{spec(t, "speed_bump:delay=0s")}
+		}},
+		{"shorthand and listed", func(c *scenario.Config) {
+			c.Venue.SpeedBumpNs = 1000
+			c.Venue.Mechanisms = []scenario.MechanismSpec{spec(t, "speed_bump:delay=1ms")}
+		}},
+		{"fees twice", func(c *scenario.Config) {
+			c.MakerFee = 1
+			c.Venue.Mechanisms = []scenario.MechanismSpec{spec(t, "maker_taker:maker=0,taker=0.001")}
+		}},
+		{"bad throttle", func(c *scenario.Config) {
+			c.Venue.Mechanisms = []scenario.MechanismSpec{spec(t, "throttle:max=0,window=1ms")}
+		}},
+	} {
+		cfg := scenario.DefaultCalm(1)
+		tc.setup(cfg)
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%s accepted", tc.name)
+		}
+	}
+}
+
+// TestThrottleQueuesExcess checks a trader's messages beyond the cap wait
+// for the next window while the background flow passes
+func TestThrottleQueuesExcess(t *testing.T) {
+	cfg := scenario.DefaultCalm(1)
+	cfg.Venue.Mechanisms = []scenario.MechanismSpec{spec(t, "throttle:max=2,window=1ms")}
+	v, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arrive := func(id uint64, trader string, ts int64) *domain.Event {
+		o := &domain.Order{ID: id, TraderID: trader, Side: domain.Buy, Type: domain.LimitOrder, Price: 1000, Qty: 1, RemainingQty: 1}
+		e := domain.NewOrderEvent(ts, o)
+		return &e
+	}
+	for id := uint64(1); id <= 2; id++ {
+		if held, _ := v.Admit(arrive(id, "fast", 100)); held {
+			t.Fatalf("order %d held under the cap", id)
+		}
+	}
+	if held, _ := v.Admit(arrive(3, "background", 100)); held {
+		t.Error("background order throttled")
+	}
+	third := arrive(4, "fast", 200)
+	held, release := v.Admit(third)
+	if !held || len(release) != 1 || release[0] != third || third.Timestamp != latency.MsToNs(1) || !v.Held(third) {
+		t.Fatalf("third order: held=%v release=%v ts=%d", held, release, third.Timestamp)
+	}
+	if wait, _ := third.Annotation("throttle_wait_ns"); wait != "999800" {
+		t.Errorf("throttle_wait_ns = %s", wait)
+	}
+	if held, _ := v.Admit(third); held {
+		t.Error("third order held again in the next window")
+	}
+	if held, _ := v.Admit(third); held {
+		t.Error("an order already admitted counted twice")
+	}
+}
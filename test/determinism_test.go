@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sync"
 	"testing"
 
 	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
@@ -33,11 +35,11 @@ func TestDeterminism(t *testing.T) {
 			}
 
 			// Generate report for run 1
-			m1, err := metrics.ComputeFromLog(result1.LogPath)
+			m1, err := metrics.ComputeFromLog(result1.LogPath, result1.Config.Scale(), result1.Config.Metrics)
 			if err != nil {
 				t.Fatal(err)
 			}
-			rpt1 := report.NewReport(cfg1, m1, result1.OutputDir)
+			rpt1 := report.ForRun(cfg1, m1, result1)
 			if err := rpt1.Generate(); err != nil {
 				t.Fatalf("report gen run1: %v", err)
 			}
@@ -55,11 +57,17 @@ func TestDeterminism(t *testing.T) {
 			}
 
 			// Generate report for run 2
-			m2, err := metrics.ComputeFromLog(result2.LogPath)
+			m2, err := metrics.ComputeFromLog(result2.LogPath, result2.Config.Scale(), result2.Config.Metrics)
 			if err != nil {
 				t.Fatal(err)
 			}
-			rpt2 := report.NewReport(cfg2, m2, result2.OutputDir)
+			// As if built and run elsewhere: the report and metrics
+			// must not change with the machine
+			elsewhere := *result2.Provenance
+			elsewhere.GitCommit, elsewhere.GitModified = "0000000", true
+			elsewhere.GoVersion, elsewhere.Hostname = "go0.0", "elsewhere"
+			result2.Provenance = &elsewhere
+			rpt2 := report.ForRun(cfg2, m2, result2)
 			if err := rpt2.Generate(); err != nil {
 				t.Fatalf("report gen run2: %v", err)
 			}
@@ -131,6 +139,88 @@ func TestDeterminism(t *testing.T) {
 	}
 }
 
+// TestDeterminismUnderPerturbation reruns each scenario under different
+// GOMAXPROCS settings, serial and pipelined, and with several runs racing
+// each other. Every run gets fresh maps and so fresh hash seeds, which
+// shuffles any map iteration that has slipped past detorder
+func TestDeterminismUnderPerturbation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("reruns every scenario many times")
+	}
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+
+	type variant struct {
+		procs    int
+		pipeline bool
+	}
+	variants := []variant{{1, false}, {1, true}, {2, true}, {runtime.NumCPU(), true}, {runtime.NumCPU(), false}}
+
+	for _, name := range []string{"calm", "thin", "spike"} {
+		t.Run(name, func(t *testing.T) {
+			want := runHashes(t, name, 1, true)
+
+			for _, v := range variants {
+				runtime.GOMAXPROCS(v.procs)
+				if got := runHashes(t, name, 1, v.pipeline); got != want {
+					t.Errorf("GOMAXPROCS=%d pipeline=%v: %s, want %s", v.procs, v.pipeline, got, want)
+				}
+			}
+
+			// Concurrent runs contend for the scheduler and the object pools
+			runtime.GOMAXPROCS(runtime.NumCPU())
+			var wg sync.WaitGroup
+			results := make([]string, 4)
+			for i := range results {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					results[i] = runHashes(t, name, 1, i%2 == 0)
+				}(i)
+			}
+			wg.Wait()
+			for i, got := range results {
+				if got != want {
+					t.Errorf("concurrent run %d: %s, want %s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+// runHashes runs a scenario and returns its log and metrics hashes
+// Safe to call from multiple goroutines
+func runHashes(t *testing.T, name string, seed int64, pipeline bool) string {
+	cfg := scenario.GetConfig(name, seed)
+	runner, err := sim.NewRunner(cfg, t.TempDir())
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+	runner.Pipeline = pipeline
+	result, err := runner.Run()
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+	m, err := metrics.ComputeFromLog(result.LogPath, result.Config.Scale(), result.Config.Metrics)
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+	rpt := report.NewReport(cfg, m, result.OutputDir)
+	if err := rpt.Generate(); err != nil {
+		t.Error(err)
+		return ""
+	}
+	// Not hashFileT: this runs on goroutines where t.Fatal is not allowed
+	data, err := os.ReadFile(filepath.Join(result.OutputDir, "metrics.json"))
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+	return fmt.Sprintf("%s/%x", result.LogHash, sha256.Sum256(data))
+}
+
 func hashFileT(t *testing.T, path string) string {
 	t.Helper()
 	data, err := os.ReadFile(path)
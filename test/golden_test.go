@@ -0,0 +1,21 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/akshitanchan/execution-fairness-simulator/internal/golden"
+)
+
+// TestGoldenSnapshots fails when simulator output drifts from the
+// committed snapshots. If the change is intentional, bump provenance.Version
+// and run `fairsim golden update` from the repository root
+func TestGoldenSnapshots(t *testing.T) {
+	drift, err := golden.Check("testdata/golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, diffs := range drift {
+		t.Errorf("%s drifted from its golden snapshot:\n  %s", name, strings.Join(diffs, "\n  "))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"math"
 	"testing"
 
+	"github.com/akshitanchan/execution-fairness-simulator/internal/eventlog"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/metrics"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/scenario"
 	"github.com/akshitanchan/execution-fairness-simulator/internal/sim"
@@ -37,8 +38,13 @@ func TestIntegrationAllScenarios(t *testing.T) {
 				t.Error("no trades")
 			}
 
+			// Log must conform to the schema
+			if err := eventlog.Validate(result.LogPath); err != nil {
+				t.Fatal(err)
+			}
+
 			// Must be able to compute metrics
-			m, err := metrics.ComputeFromLog(result.LogPath)
+			m, err := metrics.ComputeFromLog(result.LogPath, result.Config.Scale(), result.Config.Metrics)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -69,12 +75,21 @@ func TestIntegrationAllScenarios(t *testing.T) {
 				t.Error("slow trader has no fills")
 			}
 
+			// Every message must go through the configured latency model
+			for _, tc := range []scenario.TraderConfig{cfg.FastTrader, cfg.SlowTrader} {
+				for msg, stats := range m[tc.ID].RealizedLatency {
+					if issues := stats.Check(tc.BaseLatencyNs(), tc.JitterNs()); len(issues) > 0 {
+						t.Errorf("%s %s realized latency: %v", tc.ID, msg, issues)
+					}
+				}
+			}
+
 			t.Logf("  Events: %d, Trades: %d", result.EventCount, result.TradeCount)
 			t.Logf("  Fast fills: %d (rate %.1f%%), Slow fills: %d (rate %.1f%%)",
 				fast.TotalFills, fast.FillRate*100,
 				slow.TotalFills, slow.FillRate*100)
 			t.Logf("  Fast TTF: %.2f ms, Slow TTF: %.2f ms",
-				fast.AvgTimeToFillNs, slow.AvgTimeToFillNs)
+				fast.AvgTimeToFillMs, slow.AvgTimeToFillMs)
 		})
 	}
 }
@@ -99,7 +114,7 @@ func TestLatencyImpactEvidence(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			m, err := metrics.ComputeFromLog(result.LogPath)
+			m, err := metrics.ComputeFromLog(result.LogPath, result.Config.Scale(), result.Config.Metrics)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -128,3 +143,37 @@ func TestLatencyImpactEvidence(t *testing.T) {
 		t.Errorf("expected measurable latency impact in at least 2 scenarios, got %d", measurableDiffs)
 	}
 }
+
+// TestPriorityInversionsOnlyOffFIFO checks price-time priority never lets
+// a later arrival jump the queue, and pro-rata matching does
+func TestPriorityInversionsOnlyOffFIFO(t *testing.T) {
+	for _, matching := range []string{scenario.MatchFIFO, scenario.MatchProRata} {
+		t.Run(matching, func(t *testing.T) {
+			cfg := scenario.GetConfig("thin", 7)
+			cfg.Venue.Matching = matching
+			runner, err := sim.NewRunner(cfg, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := runner.Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := metrics.ComputeFromLog(result.LogPath, result.Config.Scale(), result.Config.Metrics)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			inversions := 0
+			for _, tm := range m {
+				inversions += tm.PriorityInversions + tm.QueueJumps
+			}
+			if matching == scenario.MatchFIFO && inversions != 0 {
+				t.Errorf("FIFO produced %d priority inversions", inversions)
+			}
+			if matching == scenario.MatchProRata && inversions == 0 {
+				t.Error("pro-rata produced no priority inversions")
+			}
+		})
+	}
+}